@@ -0,0 +1,180 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+
+	"github.com/justinas/alice"
+	"github.com/xmidt-org/bascule"
+	"github.com/xmidt-org/bascule/basculehttp"
+	"github.com/xmidt-org/clortho"
+	"github.com/xmidt-org/glaukos/eventmetrics"
+	"github.com/xmidt-org/wrp-listener/hashTokenFactory"
+	"github.com/xmidt-org/wrp-listener/webhookClient"
+	"go.uber.org/fx"
+)
+
+const (
+	defaultHMACAlgorithm = "sha1"
+)
+
+var (
+	errInvalidHMACAlgorithm = errors.New("invalid hmac algorithm")
+	errMissingKeyTemplate   = errors.New("missing jwt key resolve template")
+
+	hmacAlgorithms = map[string]func() hash.Hash{
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+)
+
+// newHashTokenFactories creates a token factory validating the HMAC signatures of incoming requests for each
+// of the algorithms given, keyed by the algorithm name expected before the delimiter in the signature header.
+// Configuring multiple algorithms accepts signatures using any of them, such as while the secret hashing of
+// the webhook is being upgraded.  Defaults to sha1.
+func newHashTokenFactories(algorithms []string, sg webhookClient.SecretGetter) (map[bascule.Authorization]basculehttp.TokenFactory, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{defaultHMACAlgorithm}
+	}
+
+	factories := make(map[bascule.Authorization]basculehttp.TokenFactory, len(algorithms))
+	for _, algorithm := range algorithms {
+		newHash, ok := hmacAlgorithms[algorithm]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errInvalidHMACAlgorithm, algorithm)
+		}
+
+		htf, err := hashTokenFactory.New(algorithm, newHash, sg)
+		if err != nil {
+			return nil, err
+		}
+		factories[bascule.Authorization(algorithm)] = htf
+	}
+
+	return factories, nil
+}
+
+// newEventRequestSigner creates the signer of requests sent to glaukos events endpoints, such as the events
+// forwarded to the glaukos owning their device, which signs their bodies with the webhook's secret using the
+// first of the accepted algorithms.  It returns nil if incoming events aren't authenticated with signatures.
+func newEventRequestSigner(sc SecretConfig, secret string) (eventmetrics.RequestSigner, error) {
+	if len(sc.Header) == 0 || len(secret) == 0 {
+		return nil, nil
+	}
+
+	algorithm := defaultHMACAlgorithm
+	if len(sc.Algorithms) > 0 {
+		algorithm = sc.Algorithms[0]
+	}
+
+	newHash, ok := hmacAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errInvalidHMACAlgorithm, algorithm)
+	}
+
+	delimiter := sc.Delimiter
+	if len(delimiter) == 0 {
+		delimiter = basculehttp.DefaultHeaderDelimiter
+	}
+
+	return func(request *http.Request, body []byte) {
+		h := hmac.New(newHash, []byte(secret))
+		h.Write(body)
+		request.Header.Set(sc.Header, algorithm+delimiter+hex.EncodeToString(h.Sum(nil)))
+	}, nil
+}
+
+// JWTConfig configures accepting JWT bearer tokens on the event endpoint, for deployments that can't share a
+// secret with the webhook.
+type JWTConfig struct {
+	// Enabled turns on accepting JWT bearer tokens in the Authorization header.
+	Enabled bool
+
+	// Keys configures how the keys that JWTs are signed with are resolved by their key id, along with any
+	// sources that keys are refreshed from in the background.
+	Keys clortho.Config
+
+	// Leeway is the leeway given when validating the exp, nbf, and iat claims of a JWT.
+	Leeway bascule.Leeway
+}
+
+// newBearerConstructor creates the constructor validating JWT bearer tokens in the Authorization header,
+// resolving the keys they are signed with using the configured key resolver.  Keys from refresh sources
+// are fetched in the background while the application is running.
+func newBearerConstructor(config JWTConfig, defaultKeyID string, lifecycle fx.Lifecycle, options ...basculehttp.COption) (alice.Constructor, error) {
+	if len(config.Keys.Resolve.Template) == 0 {
+		return nil, errMissingKeyTemplate
+	}
+
+	keyRing := clortho.NewKeyRing()
+	resolver, err := clortho.NewResolver(
+		clortho.WithConfig(config.Keys),
+		clortho.WithKeyRing(keyRing),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwt key resolver: %w", err)
+	}
+
+	if len(config.Keys.Refresh.Sources) > 0 {
+		refresher, err := clortho.NewRefresher(clortho.WithConfig(config.Keys))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create jwt key refresher: %w", err)
+		}
+
+		refresher.AddListener(keyRing)
+		lifecycle.Append(fx.Hook{
+			OnStart: refresher.Start,
+			OnStop:  refresher.Stop,
+		})
+	}
+
+	btf := basculehttp.BearerTokenFactory{
+		DefaultKeyID: defaultKeyID,
+		Resolver:     resolver,
+		Parser:       bascule.DefaultJWTParser,
+		Leeway:       config.Leeway,
+	}
+
+	options = append(options, basculehttp.WithTokenFactory(basculehttp.BearerAuthorization, btf))
+	return basculehttp.NewConstructor(options...), nil
+}
+
+// selectConstructor authenticates requests containing the HMAC signature header using the hmac constructor,
+// and all other requests using the bearer constructor.
+func selectConstructor(header string, hmac, bearer alice.Constructor) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		hmacHandler, bearerHandler := hmac(next), bearer(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.Header.Get(header)) > 0 {
+				hmacHandler.ServeHTTP(w, r)
+				return
+			}
+			bearerHandler.ServeHTTP(w, r)
+		})
+	}
+}
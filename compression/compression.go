@@ -0,0 +1,130 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package compression decodes the gzip and deflate content encodings of http bodies, counting the bytes read
+// before and after they are decompressed.
+package compression
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	Gzip     = "gzip"
+	Deflate  = "deflate"
+	Identity = "identity"
+
+	// AcceptEncoding is the Accept-Encoding header of requests for the supported encodings.
+	AcceptEncoding = "gzip, deflate"
+
+	// EncodingLabel is the label of the content encoding of the bytes counted.
+	EncodingLabel = "content_encoding"
+
+	// CompressionLabel is the label of whether the bytes counted were compressed or uncompressed.
+	CompressionLabel = "compression"
+
+	Compressed   = "compressed"
+	Uncompressed = "uncompressed"
+)
+
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// Encoding returns the normalized content encoding of a Content-Encoding header, which is identity if the
+// header is empty.
+func Encoding(header string) string {
+	encoding := strings.ToLower(strings.TrimSpace(header))
+	if len(encoding) == 0 {
+		return Identity
+	}
+
+	return encoding
+}
+
+// NewReader returns a reader decompressing the body with the content encoding given, counting the bytes read from
+// the body and the bytes decompressed in the counter, if it isn't nil.  Closing the reader closes the body.
+func NewReader(encoding string, body io.ReadCloser, counter *prometheus.CounterVec) (io.ReadCloser, error) {
+	encoding = Encoding(encoding)
+	var read io.Reader = body
+	if counter != nil && encoding != Identity {
+		read = countingReader{reader: body, counter: counter.With(prometheus.Labels{EncodingLabel: encoding, CompressionLabel: Compressed})}
+	}
+
+	var decompressed io.ReadCloser
+	var err error
+	switch encoding {
+	case Identity:
+		decompressed = io.NopCloser(read)
+	case Gzip:
+		decompressed, err = gzip.NewReader(read)
+	case Deflate:
+		decompressed, err = zlib.NewReader(read)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, encoding)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s body: %w", encoding, err)
+	}
+
+	result := readCloser{body: body, decompressed: decompressed, reader: decompressed}
+	if counter != nil {
+		result.reader = countingReader{reader: decompressed, counter: counter.With(prometheus.Labels{EncodingLabel: encoding, CompressionLabel: Uncompressed})}
+	}
+
+	return result, nil
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	reader  io.Reader
+	counter prometheus.Counter
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	if n > 0 {
+		c.counter.Add(float64(n))
+	}
+	return n, err
+}
+
+// readCloser reads the decompressed body, closing both the decompressor and the body.
+type readCloser struct {
+	body         io.Closer
+	decompressed io.Closer
+	reader       io.Reader
+}
+
+func (r readCloser) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r readCloser) Close() error {
+	err := r.decompressed.Close()
+	if bodyErr := r.body.Close(); bodyErr != nil {
+		return bodyErr
+	}
+
+	return err
+}
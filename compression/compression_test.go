@@ -0,0 +1,145 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case Gzip:
+		w = gzip.NewWriter(&b)
+	case Deflate:
+		w = zlib.NewWriter(&b)
+	default:
+		return data
+	}
+
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestEncoding(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(Identity, Encoding(""))
+	assert.Equal(Gzip, Encoding(" GZIP "))
+	assert.Equal(Deflate, Encoding("deflate"))
+}
+
+func TestNewReader(t *testing.T) {
+	data := bytes.Repeat([]byte("some event data "), 100)
+	tests := []struct {
+		description  string
+		encoding     string
+		body         []byte
+		expectedErr  error
+		expectedFail bool
+		compressed   bool
+	}{
+		{
+			description: "No encoding",
+			body:        data,
+		},
+		{
+			description: "Identity",
+			encoding:    "identity",
+			body:        data,
+		},
+		{
+			description: "Gzip",
+			encoding:    "gzip",
+			body:        compress(t, Gzip, data),
+			compressed:  true,
+		},
+		{
+			description: "Deflate",
+			encoding:    "Deflate",
+			body:        compress(t, Deflate, data),
+			compressed:  true,
+		},
+		{
+			description: "Unsupported",
+			encoding:    "br",
+			body:        data,
+			expectedErr: ErrUnsupportedEncoding,
+		},
+		{
+			description:  "Invalid gzip",
+			encoding:     "gzip",
+			body:         data,
+			expectedFail: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testBytes", Help: "testBytes"}, []string{EncodingLabel, CompressionLabel})
+			body := &closeRecorder{Reader: bytes.NewReader(tc.body)}
+			reader, err := NewReader(tc.encoding, body, counter)
+			if tc.expectedErr != nil || tc.expectedFail {
+				if tc.expectedErr != nil {
+					assert.ErrorIs(err, tc.expectedErr)
+				}
+				assert.NotNil(err)
+				assert.Nil(reader)
+				return
+			}
+
+			if !assert.Nil(err) {
+				return
+			}
+
+			read, err := io.ReadAll(reader)
+			assert.Nil(err)
+			assert.Equal(data, read)
+			assert.Nil(reader.Close())
+			assert.True(body.closed)
+
+			encoding := Encoding(tc.encoding)
+			assert.Equal(float64(len(data)), testutil.ToFloat64(counter.WithLabelValues(encoding, Uncompressed)))
+			if tc.compressed {
+				assert.Equal(float64(len(tc.body)), testutil.ToFloat64(counter.WithLabelValues(encoding, Compressed)))
+			} else {
+				assert.Equal(1, testutil.CollectAndCount(counter))
+			}
+		})
+	}
+}
+
+func TestNewReaderWithoutCounter(t *testing.T) {
+	assert := assert.New(t)
+	data := []byte("some event data")
+	reader, err := NewReader(Gzip, io.NopCloser(bytes.NewReader(compress(t, Gzip, data))), nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	read, err := io.ReadAll(reader)
+	assert.Nil(err)
+	assert.Equal(data, read)
+}
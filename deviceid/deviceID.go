@@ -0,0 +1,111 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package deviceid normalizes and validates the device ids found in events, so that every component refers
+// to a device by the same id regardless of how the device formatted it.
+package deviceid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	MACScheme    = "mac"
+	UUIDScheme   = "uuid"
+	SerialScheme = "serial"
+	DNSScheme    = "dns"
+
+	macLength = 12
+)
+
+var (
+	ErrMalformedID   = errors.New("malformed device id")
+	ErrUnknownScheme = errors.New("unknown device id scheme")
+)
+
+// Normalize returns the canonical form of a device id, with the scheme and id lowercased and, for mac addresses,
+// the colon, dash, and dot separators removed.  Malformed ids with a known scheme are only lowercased, so that they
+// are still referred to consistently, and ids without a known scheme are returned unchanged.
+func Normalize(id string) string {
+	lowered := strings.ToLower(strings.TrimSpace(id))
+	scheme, value, _ := strings.Cut(lowered, ":")
+	switch scheme {
+	case MACScheme:
+		if mac, ok := normalizeMAC(value); ok {
+			return MACScheme + ":" + mac
+		}
+		return lowered
+	case UUIDScheme, SerialScheme, DNSScheme:
+		return lowered
+	}
+
+	return id
+}
+
+// Validate returns an error if the device id doesn't have one of the known schemes followed by an id, or if a mac
+// address isn't 12 hexadecimal digits.
+func Validate(id string) error {
+	scheme, value, found := strings.Cut(strings.TrimSpace(id), ":")
+	if !found || len(value) == 0 {
+		return fmt.Errorf("%w: %q", ErrMalformedID, id)
+	}
+
+	switch strings.ToLower(scheme) {
+	case MACScheme:
+		if _, ok := normalizeMAC(value); !ok {
+			return fmt.Errorf("%w: %q is not a mac address", ErrMalformedID, id)
+		}
+	case UUIDScheme, SerialScheme, DNSScheme:
+		if strings.ContainsAny(value, " \t/") {
+			return fmt.Errorf("%w: %q", ErrMalformedID, id)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownScheme, scheme)
+	}
+
+	return nil
+}
+
+// FromEvent returns the normalized device id from the event's destination.
+func FromEvent(e interpreter.Event) (string, error) {
+	id, err := e.DeviceID()
+	if err != nil {
+		return "", err
+	}
+
+	return Normalize(id), nil
+}
+
+// normalizeMAC removes the separators from a mac address, returning false if it isn't 12 hexadecimal digits.
+func normalizeMAC(value string) (string, bool) {
+	mac := strings.NewReplacer(":", "", "-", "", ".", "").Replace(value)
+	if len(mac) != macLength {
+		return "", false
+	}
+
+	for _, c := range mac {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return "", false
+		}
+	}
+
+	return strings.ToLower(mac), true
+}
@@ -0,0 +1,150 @@
+package deviceid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		description string
+		id          string
+		expected    string
+	}{
+		{
+			description: "canonical mac",
+			id:          "mac:112233445566",
+			expected:    "mac:112233445566",
+		},
+		{
+			description: "uppercase mac",
+			id:          "MAC:AABBCCDDEEFF",
+			expected:    "mac:aabbccddeeff",
+		},
+		{
+			description: "mac with colons",
+			id:          "mac:aa:bb:cc:dd:ee:ff",
+			expected:    "mac:aabbccddeeff",
+		},
+		{
+			description: "mac with dashes",
+			id:          "Mac:AA-BB-CC-DD-EE-FF",
+			expected:    "mac:aabbccddeeff",
+		},
+		{
+			description: "mac with dots",
+			id:          "mac:aabb.ccdd.eeff",
+			expected:    "mac:aabbccddeeff",
+		},
+		{
+			description: "invalid mac",
+			id:          "MAC:some_address",
+			expected:    "mac:some_address",
+		},
+		{
+			description: "serial",
+			id:          "Serial:ABC123",
+			expected:    "serial:abc123",
+		},
+		{
+			description: "no scheme",
+			id:          "some-deviceID",
+			expected:    "some-deviceID",
+		},
+		{
+			description: "unknown scheme",
+			id:          "IMEI:ABC",
+			expected:    "IMEI:ABC",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Normalize(tc.id))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		description string
+		id          string
+		expectedErr error
+	}{
+		{
+			description: "mac",
+			id:          "mac:112233445566",
+		},
+		{
+			description: "mac with separators",
+			id:          "MAC:11:22:33:44:55:66",
+		},
+		{
+			description: "uuid",
+			id:          "uuid:123e4567-e89b-12d3-a456-426614174000",
+		},
+		{
+			description: "serial",
+			id:          "serial:ABC123",
+		},
+		{
+			description: "dns",
+			id:          "dns:talaria.example.com",
+		},
+		{
+			description: "short mac",
+			id:          "mac:11223344556",
+			expectedErr: ErrMalformedID,
+		},
+		{
+			description: "non-hex mac",
+			id:          "mac:11223344556g",
+			expectedErr: ErrMalformedID,
+		},
+		{
+			description: "serial with space",
+			id:          "serial:ABC 123",
+			expectedErr: ErrMalformedID,
+		},
+		{
+			description: "empty id",
+			id:          "uuid:",
+			expectedErr: ErrMalformedID,
+		},
+		{
+			description: "no scheme",
+			id:          "112233445566",
+			expectedErr: ErrMalformedID,
+		},
+		{
+			description: "unknown scheme",
+			id:          "imei:112233445566",
+			expectedErr: ErrUnknownScheme,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			err := Validate(tc.id)
+			if tc.expectedErr == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tc.expectedErr))
+		})
+	}
+}
+
+func TestFromEvent(t *testing.T) {
+	assert := assert.New(t)
+	id, err := FromEvent(interpreter.Event{Destination: "event:device-status/MAC:11:22:33:44:55:66/online"})
+	assert.Nil(err)
+	assert.Equal("mac:112233445566", id)
+
+	id, err = FromEvent(interpreter.Event{Destination: "event:device-status"})
+	assert.NotNil(err)
+	assert.Empty(id)
+}
@@ -0,0 +1,277 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/xmidt-org/arrange"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	parserNameVar   = "name"
+	parserTypeLabel = "parser_type"
+)
+
+// AdminConfig configures the admin endpoints.
+type AdminConfig struct {
+	// EnableParsersEndpoint turns on the endpoints used to list the parsers and enable or disable them.
+	EnableParsersEndpoint bool
+
+	// EnableDeviceAnalysisEndpoint turns on the endpoint that runs the reboot duration parser on demand for a
+	// device, using its history of events from codex.
+	EnableDeviceAnalysisEndpoint bool
+
+	// EnableReplayEndpoint turns on the endpoint that pulls the histories of devices from codex and queues the
+	// terminal events of each of their boot cycles to be parsed, to backfill metrics after an outage.
+	EnableReplayEndpoint bool
+
+	// EnableSnapshotEndpoint turns on the endpoint that returns a snapshot of the current values of glaukos's
+	// metrics, as JSON or OpenMetrics.
+	EnableSnapshotEndpoint bool
+
+	// EnableConfigEndpoint turns on the endpoint that returns the runtime configuration, with secrets redacted.
+	EnableConfigEndpoint bool
+
+	// RedactKeys are the config keys whose values are redacted by the config endpoint, along with the keys of
+	// passwords, secrets, tokens, and other credentials, which are always redacted.
+	RedactKeys []string
+}
+
+// ParserState describes a parser, whether it is enabled, and how many events it found unparsable.
+type ParserState struct {
+	ParserDescription
+	Enabled         bool    `json:"enabled"`
+	UnparsableCount float64 `json:"unparsableCount"`
+}
+
+type parserToggle struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ParsersAdmin lists the parsers and enables or disables them through the kill switch.
+type ParsersAdmin struct {
+	parsers          []ParserDescription
+	killSwitch       *queue.KillSwitch
+	unparsableCounts *prometheus.CounterVec
+	logger           *zap.Logger
+}
+
+// NewParsersAdmin creates a new ParsersAdmin.  The unparsable counts are read from the counter given,
+// labeled by parser.
+func NewParsersAdmin(parsers []queue.Parser, killSwitch *queue.KillSwitch, unparsableCounts *prometheus.CounterVec, logger *zap.Logger) *ParsersAdmin {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &ParsersAdmin{
+		parsers:          DescribeParsers(parsers),
+		killSwitch:       killSwitch,
+		unparsableCounts: unparsableCounts,
+		logger:           logger,
+	}
+}
+
+// States returns the state of each parser.
+func (a *ParsersAdmin) States() []ParserState {
+	counts := a.counts()
+	states := make([]ParserState, 0, len(a.parsers))
+	for _, p := range a.parsers {
+		states = append(states, ParserState{
+			ParserDescription: p,
+			Enabled:           a.killSwitch.ParserEnabled(p.Name),
+			UnparsableCount:   counts[p.Name],
+		})
+	}
+
+	return states
+}
+
+// State returns the state of the parser with the name given, returning false if there is no such parser.
+func (a *ParsersAdmin) State(name string) (ParserState, bool) {
+	for _, state := range a.States() {
+		if state.Name == name {
+			return state, true
+		}
+	}
+
+	return ParserState{}, false
+}
+
+// counts sums the unparsable counts of each parser across the rest of the labels.
+func (a *ParsersAdmin) counts() map[string]float64 {
+	counts := make(map[string]float64)
+	if a.unparsableCounts == nil {
+		return counts
+	}
+
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		a.unparsableCounts.Collect(metrics)
+		close(metrics)
+	}()
+
+	for metric := range metrics {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil || m.Counter == nil {
+			continue
+		}
+		for _, label := range m.Label {
+			if label.GetName() == parserTypeLabel {
+				counts[label.GetValue()] += m.Counter.GetValue()
+			}
+		}
+	}
+
+	return counts
+}
+
+// ListHandler returns a handler that lists the state of each parser.
+func (a *ParsersAdmin) ListHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, a.States())
+	})
+}
+
+// ParserHandler returns a handler that reports the state of a parser on GET and enables or disables the
+// parser on PUT.
+func (a *ParsersAdmin) ParserHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)[parserNameVar]
+		if _, found := a.State(name); !found {
+			http.Error(w, fmt.Sprintf("parser %q not found", name), http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var toggle parserToggle
+			if err := json.NewDecoder(r.Body).Decode(&toggle); err != nil || toggle.Enabled == nil {
+				http.Error(w, "invalid parser state", http.StatusBadRequest)
+				return
+			}
+
+			if *toggle.Enabled {
+				a.killSwitch.EnableParser(name)
+			} else {
+				a.killSwitch.DisableParser(name)
+			}
+			a.logger.Warn("parser toggled", zap.String("parser", name), zap.Bool("enabled", *toggle.Enabled))
+		}
+
+		state, _ := a.State(name)
+		writeJSON(w, state)
+	})
+}
+
+// DeviceEventsHandler returns a handler that lists the device's last parsed events kept in the audit log, along
+// with the outcome of parsing them with each parser.
+func DeviceEventsHandler(auditLog *queue.AuditLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, auditLog.Events(mux.Vars(r)[deviceIDVar]))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(v) // nolint:errcheck
+}
+
+// AdminRoutesIn provides the information needed to set up the admin endpoints.
+type AdminRoutesIn struct {
+	fx.In
+	Config           AdminConfig
+	Parsers          []queue.Parser `group:"parsers"`
+	KillSwitch       *queue.KillSwitch
+	UnparsableCounts *prometheus.CounterVec `name:"total_unparsable_count" optional:"true"`
+	AuditLog         *queue.AuditLog        `optional:"true"`
+	Queue            queue.Queue            `optional:"true"`
+	EventSource      events.EventSource     `optional:"true"`
+	Gatherer         prometheus.Gatherer    `optional:"true"`
+	SnapshotConfig   SnapshotConfig         `optional:"true"`
+	Unmarshaler      arrange.Unmarshaler
+	Logger           *zap.Logger
+	Router           *mux.Router `name:"servers.primary"`
+	APIBase          string      `name:"api_base"`
+}
+
+// ConfigureAdminRoutes sets up the primary router to list the parsers and enable or disable them, to analyze a
+// device, to list a device's last parsed events, to replay the events of devices, to snapshot the metrics, and to
+// return the runtime configuration, if the endpoints are enabled.  The endpoints are protected by
+// the same auth as the events endpoint.
+func ConfigureAdminRoutes(in AdminRoutesIn) {
+	if in.Router == nil {
+		return
+	}
+
+	if in.Config.EnableDeviceAnalysisEndpoint {
+		in.Router.Handle(fmt.Sprintf("/%s/device/{%s}/analysis", in.APIBase, deviceIDVar), DeviceAnalysisHandler(deviceAnalyzers(in.Parsers))).
+			Name("device_analysis").
+			Methods("GET")
+	}
+
+	if in.AuditLog != nil {
+		in.Router.Handle(fmt.Sprintf("/%s/debug/events/{%s}", in.APIBase, deviceIDVar), DeviceEventsHandler(in.AuditLog)).
+			Name("debug_events").
+			Methods("GET")
+	}
+
+	if in.Config.EnableReplayEndpoint && in.Queue != nil && in.EventSource != nil {
+		in.Router.Handle(fmt.Sprintf("/%s/admin/replay", in.APIBase), NewReplayer(in.EventSource, in.Queue, in.Logger).Handler()).
+			Name("admin_replay").
+			Methods("POST")
+	}
+
+	if in.Config.EnableSnapshotEndpoint && in.Gatherer != nil {
+		in.Router.Handle(fmt.Sprintf("/%s/admin/snapshot", in.APIBase), NewSnapshotter(in.Gatherer, in.SnapshotConfig).Handler()).
+			Name("admin_snapshot").
+			Methods("GET")
+	}
+
+	if in.Config.EnableConfigEndpoint {
+		if handler, err := ConfigHandler(in.Unmarshaler, in.Config.RedactKeys); err != nil {
+			in.Logger.Error("failed to load the runtime config, so the config endpoint is disabled", zap.Error(err))
+		} else {
+			in.Router.Handle(fmt.Sprintf("/%s/config", in.APIBase), handler).
+				Name("config").
+				Methods("GET")
+		}
+	}
+
+	if !in.Config.EnableParsersEndpoint {
+		return
+	}
+
+	admin := NewParsersAdmin(in.Parsers, in.KillSwitch, in.UnparsableCounts, in.Logger)
+	path := fmt.Sprintf("/%s/admin/parsers", in.APIBase)
+	in.Router.Handle(path, admin.ListHandler()).
+		Name("admin_parsers").
+		Methods("GET")
+	in.Router.Handle(fmt.Sprintf("%s/{%s}", path, parserNameVar), admin.ParserHandler()).
+		Name("admin_parser").
+		Methods("GET", "PUT")
+}
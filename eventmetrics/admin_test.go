@@ -0,0 +1,230 @@
+package eventmetrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+func newTestParsersAdmin(killSwitch *queue.KillSwitch) *ParsersAdmin {
+	unparsable := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testUnparsableCount",
+		Help: "testUnparsableCount",
+	}, []string{parserTypeLabel})
+	unparsable.WithLabelValues("reboot").Add(3)
+	unparsable.WithLabelValues("unknown").Add(1)
+	parsers := []queue.Parser{testMatcherParser{testParser{name: "reboot", regexes: []string{"fully-manageable"}}}, testParser{name: "metadata"}}
+	return NewParsersAdmin(parsers, killSwitch, unparsable, zap.NewNop())
+}
+
+func TestParsersAdminStates(t *testing.T) {
+	assert := assert.New(t)
+	killSwitch := queue.NewKillSwitch(queue.KillSwitchConfig{DisabledParsers: []string{"metadata"}})
+	admin := newTestParsersAdmin(killSwitch)
+	assert.Equal([]ParserState{
+		{
+			ParserDescription: ParserDescription{Name: "reboot", EventTypeRegexes: []string{"fully-manageable"}},
+			Enabled:           true,
+			UnparsableCount:   3,
+		},
+		{
+			ParserDescription: ParserDescription{Name: "metadata", EventTypeRegexes: []string{".*"}},
+		},
+	}, admin.States())
+
+	_, found := admin.State("missing")
+	assert.False(found)
+	assert.Empty(NewParsersAdmin(nil, killSwitch, nil, nil).counts())
+}
+
+func TestParsersAdminHandlers(t *testing.T) {
+	tests := []struct {
+		description     string
+		method          string
+		path            string
+		body            string
+		expectedCode    int
+		expectedEnabled bool
+	}{
+		{
+			description:     "Get parser",
+			method:          http.MethodGet,
+			path:            "/api/v1/admin/parsers/reboot",
+			expectedCode:    http.StatusOK,
+			expectedEnabled: true,
+		},
+		{
+			description:  "Disable parser",
+			method:       http.MethodPut,
+			path:         "/api/v1/admin/parsers/reboot",
+			body:         `{"enabled": false}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:     "Enable parser",
+			method:          http.MethodPut,
+			path:            "/api/v1/admin/parsers/reboot",
+			body:            `{"enabled": true}`,
+			expectedCode:    http.StatusOK,
+			expectedEnabled: true,
+		},
+		{
+			description:     "Invalid body",
+			method:          http.MethodPut,
+			path:            "/api/v1/admin/parsers/reboot",
+			body:            `{}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedEnabled: true,
+		},
+		{
+			description:     "Unknown parser",
+			method:          http.MethodPut,
+			path:            "/api/v1/admin/parsers/missing",
+			body:            `{"enabled": false}`,
+			expectedCode:    http.StatusNotFound,
+			expectedEnabled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			killSwitch := queue.NewKillSwitch(queue.KillSwitchConfig{})
+			admin := newTestParsersAdmin(killSwitch)
+			router := mux.NewRouter()
+			router.Handle("/api/v1/admin/parsers/{name}", admin.ParserHandler())
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+			assert.Equal(tc.expectedCode, recorder.Code)
+			assert.Equal(tc.expectedEnabled, killSwitch.ParserEnabled("reboot"))
+			if tc.expectedCode == http.StatusOK {
+				var state ParserState
+				assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &state))
+				assert.Equal("reboot", state.Name)
+				assert.Equal(tc.expectedEnabled, state.Enabled)
+				assert.Equal(3.0, state.UnparsableCount)
+			}
+		})
+	}
+}
+
+func TestConfigureAdminRoutes(t *testing.T) {
+	tests := []struct {
+		description  string
+		enabled      bool
+		expectedCode int
+	}{
+		{
+			description:  "Enabled",
+			enabled:      true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:  "Disabled",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			router := mux.NewRouter()
+			ConfigureAdminRoutes(AdminRoutesIn{
+				Config:     AdminConfig{EnableParsersEndpoint: tc.enabled},
+				Parsers:    []queue.Parser{testParser{name: "reboot"}},
+				KillSwitch: queue.NewKillSwitch(queue.KillSwitchConfig{}),
+				Router:     router,
+				APIBase:    "api/v1",
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/parsers", nil))
+			assert.Equal(tc.expectedCode, recorder.Code)
+			if tc.expectedCode == http.StatusOK {
+				var states []ParserState
+				assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &states))
+				if assert.Len(states, 1) {
+					assert.Equal("reboot", states[0].Name)
+					assert.True(states[0].Enabled)
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceEventsRoute(t *testing.T) {
+	tests := []struct {
+		description    string
+		enabled        bool
+		deviceID       string
+		expectedCode   int
+		expectedEvents int
+	}{
+		{
+			description:    "Enabled",
+			enabled:        true,
+			deviceID:       "mac:112233445566",
+			expectedCode:   http.StatusOK,
+			expectedEvents: 1,
+		},
+		{
+			description:    "Case insensitive",
+			enabled:        true,
+			deviceID:       "MAC:112233445566",
+			expectedCode:   http.StatusOK,
+			expectedEvents: 1,
+		},
+		{
+			description:  "Unknown device",
+			enabled:      true,
+			deviceID:     "mac:aabbccddeeff",
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:  "Disabled",
+			deviceID:     "mac:112233445566",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			auditLog := queue.NewAuditLog(queue.AuditLogConfig{Enabled: tc.enabled})
+			if auditLog != nil {
+				auditLog.Record(interpreter.Event{Destination: "event:device-status/mac:112233445566/online"}, map[string]string{"reboot": "success"})
+			}
+
+			router := mux.NewRouter()
+			ConfigureAdminRoutes(AdminRoutesIn{
+				Parsers:  []queue.Parser{testParser{name: "reboot"}},
+				AuditLog: auditLog,
+				Router:   router,
+				APIBase:  "api/v1",
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/debug/events/"+tc.deviceID, nil))
+			assert.Equal(tc.expectedCode, recorder.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var events []queue.AuditedEvent
+			assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &events))
+			if assert.Len(events, tc.expectedEvents) && tc.expectedEvents > 0 {
+				assert.Equal(map[string]string{"reboot": "success"}, events[0].Outcomes)
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestNewConsumer(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         Config
+		expectedConfig Config
+		expectedErr    error
+	}{
+		{
+			description: "Defaults",
+			config:      Config{URL: "amqp://localhost:5672/", Queue: "events"},
+			expectedConfig: Config{
+				URL:                 "amqp://localhost:5672/",
+				Queue:               "events",
+				ExchangeType:        defaultExchangeType,
+				BindingKeys:         []string{defaultBindingKey},
+				ConsumerTag:         defaultConsumerTag,
+				Prefetch:            defaultPrefetch,
+				ReconnectBackoff:    defaultReconnectBackoff,
+				MaxReconnectBackoff: defaultMaxReconnectBackoff,
+				RetryBackoff:        defaultRetryBackoff,
+			},
+		},
+		{
+			description: "Configured",
+			config: Config{
+				URL:                 "amqp://localhost:5672/",
+				Exchange:            "device-status",
+				ExchangeType:        "fanout",
+				Queue:               "events",
+				BindingKeys:         []string{"online", "offline"},
+				ConsumerTag:         "tag",
+				Prefetch:            5,
+				ReconnectBackoff:    2 * time.Minute,
+				MaxReconnectBackoff: time.Minute,
+				RetryBackoff:        time.Minute,
+			},
+			expectedConfig: Config{
+				URL:                 "amqp://localhost:5672/",
+				Exchange:            "device-status",
+				ExchangeType:        "fanout",
+				Queue:               "events",
+				BindingKeys:         []string{"online", "offline"},
+				ConsumerTag:         "tag",
+				Prefetch:            5,
+				ReconnectBackoff:    2 * time.Minute,
+				MaxReconnectBackoff: 2 * time.Minute,
+				RetryBackoff:        time.Minute,
+			},
+		},
+		{
+			description: "Missing url",
+			config:      Config{Queue: "events"},
+			expectedErr: errMissingURL,
+		},
+		{
+			description: "Missing queue",
+			config:      Config{URL: "amqp://localhost:5672/"},
+			expectedErr: errMissingQueue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			consumer, err := NewConsumer(tc.config, nil, Measures{}, nil)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedErr != nil {
+				assert.Nil(consumer)
+				return
+			}
+
+			if !assert.NotNil(consumer) {
+				return
+			}
+			assert.Equal(tc.expectedConfig, consumer.config)
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	testErr := errors.New("test error")
+	tests := []struct {
+		description string
+		exchange    string
+		failOn      string
+		expectedErr error
+	}{
+		{
+			description: "Bound to exchange",
+			exchange:    "device-status",
+		},
+		{
+			description: "No exchange",
+		},
+		{
+			description: "Qos error",
+			exchange:    "device-status",
+			failOn:      "Qos",
+			expectedErr: testErr,
+		},
+		{
+			description: "Queue declare error",
+			exchange:    "device-status",
+			failOn:      "QueueDeclare",
+			expectedErr: testErr,
+		},
+		{
+			description: "Exchange declare error",
+			exchange:    "device-status",
+			failOn:      "ExchangeDeclare",
+			expectedErr: testErr,
+		},
+		{
+			description: "Bind error",
+			exchange:    "device-status",
+			failOn:      "QueueBind",
+			expectedErr: testErr,
+		},
+		{
+			description: "Consume error",
+			exchange:    "device-status",
+			failOn:      "Consume",
+			expectedErr: testErr,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			errFor := func(method string) error {
+				if method == tc.failOn {
+					return testErr
+				}
+				return nil
+			}
+
+			deliveries := make(chan amqp091.Delivery)
+			ch := new(mockChannel)
+			ch.On("Qos", 10, 0, false).Return(errFor("Qos"))
+			ch.On("QueueDeclare", "events", true, false, false, false, amqp091.Table(nil)).Return(amqp091.Queue{}, errFor("QueueDeclare"))
+			ch.On("ExchangeDeclare", tc.exchange, "topic", true, false, false, false, amqp091.Table(nil)).Return(errFor("ExchangeDeclare"))
+			ch.On("QueueBind", "events", "online", tc.exchange, false, amqp091.Table(nil)).Return(errFor("QueueBind"))
+			ch.On("QueueBind", "events", "offline", tc.exchange, false, amqp091.Table(nil)).Return(nil)
+			ch.On("Consume", "events", defaultConsumerTag, false, false, false, false, amqp091.Table(nil)).Return(deliveries, errFor("Consume"))
+			conn := new(mockConnection)
+			conn.On("Channel").Return(ch, nil)
+
+			consumer, err := newConsumer(Config{
+				URL:         "amqp://localhost:5672/",
+				Exchange:    tc.exchange,
+				Queue:       "events",
+				BindingKeys: []string{"online", "offline"},
+				Prefetch:    10,
+			}, nil, nil, Measures{}, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			d, err := consumer.setup(conn)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedErr != nil {
+				return
+			}
+
+			assert.NotNil(d)
+			if len(tc.exchange) == 0 {
+				ch.AssertNotCalled(t, "ExchangeDeclare", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				ch.AssertNotCalled(t, "QueueBind", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			ch.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMessageFormat(t *testing.T) {
+	tests := []struct {
+		contentType    string
+		expectedFormat wrp.Format
+	}{
+		{contentType: "", expectedFormat: wrp.Msgpack},
+		{contentType: wrp.MimeTypeMsgpack, expectedFormat: wrp.Msgpack},
+		{contentType: wrp.MimeTypeJson, expectedFormat: wrp.JSON},
+		{contentType: "text/plain", expectedFormat: wrp.Msgpack},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.contentType, func(t *testing.T) {
+			assert.Equal(t, tc.expectedFormat, messageFormat(tc.contentType))
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	validMsg := wrp.Message{
+		Source:          "test",
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "valid",
+	}
+
+	tests := []struct {
+		description     string
+		body            []byte
+		contentType     string
+		eventErrs       []error
+		handled         *bool
+		expectedCalls   int
+		expectedAck     bool
+		expectedRequeue bool
+		expectedReason  string
+	}{
+		{
+			description:   "Parsed",
+			body:          encodeTestMsg(t, validMsg, wrp.Msgpack),
+			handled:       boolPtr(true),
+			expectedCalls: 1,
+			expectedAck:   true,
+		},
+		{
+			description:   "JSON parsed",
+			body:          encodeTestMsg(t, validMsg, wrp.JSON),
+			contentType:   wrp.MimeTypeJson,
+			handled:       boolPtr(true),
+			expectedCalls: 1,
+			expectedAck:   true,
+		},
+		{
+			description:     "Dropped by queue",
+			body:            encodeTestMsg(t, validMsg, wrp.Msgpack),
+			handled:         boolPtr(false),
+			expectedCalls:   1,
+			expectedRequeue: true,
+		},
+		{
+			description:    "Invalid message",
+			body:           []byte("invalid"),
+			expectedReason: invalidMessageReason,
+		},
+		{
+			description:    "Rejected event",
+			body:           encodeTestMsg(t, validMsg, wrp.Msgpack),
+			eventErrs:      []error{eventmetrics.InvalidEventErr{Reason: "test", Err: errors.New("rejected")}},
+			expectedCalls:  1,
+			expectedReason: rejectedEventReason,
+		},
+		{
+			description:   "Retried",
+			body:          encodeTestMsg(t, validMsg, wrp.Msgpack),
+			eventErrs:     []error{queue.TooManyRequestsErr{Message: "Queue Full"}, errors.New("queue full")},
+			handled:       boolPtr(true),
+			expectedCalls: 3,
+			expectedAck:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			acknowledger := new(mockAcknowledger)
+			acknowledger.On("Ack", uint64(3), false).Return(nil)
+			acknowledger.On("Nack", uint64(3), false, mock.Anything).Return(nil)
+
+			calls := 0
+			event := func(ctx context.Context, request interface{}) (interface{}, error) {
+				calls++
+				e := request.(interpreter.Event)
+				assert.Equal(validMsg.TransactionUUID, e.TransactionUUID)
+				if calls <= len(tc.eventErrs) {
+					return nil, tc.eventErrs[calls-1]
+				}
+
+				if done := queue.DoneFromContext(ctx); done != nil && tc.handled != nil {
+					done(*tc.handled)
+				}
+				return nil, nil
+			}
+
+			measures := Measures{
+				DroppedMessages: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{queueLabel, reasonLabel}),
+			}
+			consumer, err := newConsumer(Config{URL: "amqp://localhost:5672/", Queue: "events", RetryBackoff: time.Millisecond}, nil, event, measures, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			delivery := amqp091.Delivery{Acknowledger: acknowledger, DeliveryTag: 3, ContentType: tc.contentType, Body: tc.body}
+			assert.True(consumer.handle(context.Background(), delivery))
+			assert.Equal(tc.expectedCalls, calls)
+			if tc.expectedAck {
+				acknowledger.AssertCalled(t, "Ack", uint64(3), false)
+				acknowledger.AssertNotCalled(t, "Nack", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				acknowledger.AssertNotCalled(t, "Ack", mock.Anything, mock.Anything)
+				acknowledger.AssertCalled(t, "Nack", uint64(3), false, tc.expectedRequeue)
+			}
+
+			if len(tc.expectedReason) > 0 {
+				assert.Equal(1.0, testutil.ToFloat64(measures.DroppedMessages.WithLabelValues("events", tc.expectedReason)))
+			}
+		})
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	assert := assert.New(t)
+	msg := wrp.Message{
+		Source:          "test",
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "valid",
+	}
+
+	acknowledger := new(mockAcknowledger)
+	acknowledger.On("Ack", mock.Anything, false).Return(nil)
+
+	// the first dial fails, and the first connection closes after one message.
+	first := make(chan amqp091.Delivery, 1)
+	first <- amqp091.Delivery{Acknowledger: acknowledger, DeliveryTag: 1, Body: encodeTestMsg(t, msg, wrp.Msgpack)}
+	close(first)
+	second := make(chan amqp091.Delivery, 1)
+	second <- amqp091.Delivery{Acknowledger: acknowledger, DeliveryTag: 1, Body: encodeTestMsg(t, msg, wrp.Msgpack)}
+
+	conn := new(mockConnection)
+	conn.On("Close").Return(nil)
+	for _, deliveries := range []chan amqp091.Delivery{first, second} {
+		ch := new(mockChannel)
+		ch.On("Qos", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		ch.On("QueueDeclare", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(amqp091.Queue{}, nil)
+		ch.On("Consume", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(deliveries, nil)
+		conn.On("Channel").Return(ch, nil).Once()
+	}
+
+	dials := 0
+	dial := func(string) (connection, error) {
+		dials++
+		if dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return conn, nil
+	}
+
+	handled := make(chan struct{}, 2)
+	event := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		queue.DoneFromContext(ctx)(true)
+		handled <- struct{}{}
+		return nil, nil
+	}
+
+	measures := Measures{
+		ConsumedMessages: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "consumed"}, []string{queueLabel}),
+		Reconnects:       prometheus.NewCounter(prometheus.CounterOpts{Name: "reconnects"}),
+	}
+	consumer, err := newConsumer(Config{URL: "amqp://localhost:5672/", Queue: "events", ReconnectBackoff: time.Millisecond}, dial, event, measures, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	consumer.Start()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			assert.Fail("timed out waiting for messages to be consumed")
+		}
+	}
+	assert.Nil(consumer.Stop())
+
+	assert.Equal(3, dials)
+	conn.AssertNumberOfCalls(t, "Close", 2)
+	acknowledger.AssertNumberOfCalls(t, "Ack", 2)
+	assert.Equal(2.0, testutil.ToFloat64(measures.ConsumedMessages.WithLabelValues("events")))
+	assert.Equal(1.0, testutil.ToFloat64(measures.Reconnects))
+}
+
+func TestConsumerStopWhileRetrying(t *testing.T) {
+	assert := assert.New(t)
+	acknowledger := new(mockAcknowledger)
+	acknowledger.On("Nack", uint64(1), false, true).Return(nil).Once()
+
+	deliveries := make(chan amqp091.Delivery, 1)
+	deliveries <- amqp091.Delivery{
+		Acknowledger: acknowledger,
+		DeliveryTag:  1,
+		Body:         encodeTestMsg(t, wrp.Message{Source: "test", Destination: "event:device-status/mac:112233445566/online"}, wrp.Msgpack),
+	}
+	ch := new(mockChannel)
+	ch.On("Qos", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	ch.On("QueueDeclare", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(amqp091.Queue{}, nil)
+	ch.On("Consume", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(deliveries, nil)
+	conn := new(mockConnection)
+	conn.On("Channel").Return(ch, nil)
+	conn.On("Close").Return(nil).Once()
+
+	called := make(chan struct{}, 1)
+	event := func(context.Context, interface{}) (interface{}, error) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		return nil, errors.New("queue full")
+	}
+
+	dial := func(string) (connection, error) { return conn, nil }
+	consumer, err := newConsumer(Config{URL: "amqp://localhost:5672/", Queue: "events", RetryBackoff: time.Hour}, dial, event, Measures{}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	consumer.Start()
+	<-called
+	assert.Nil(consumer.Stop())
+
+	// the message is returned to the queue since the event was never queued.
+	acknowledger.AssertExpectations(t)
+	conn.AssertExpectations(t)
+}
+
+func encodeTestMsg(t *testing.T, msg wrp.Message, format wrp.Format) []byte {
+	var b []byte
+	assert.Nil(t, wrp.NewEncoderBytes(&b, format).Encode(msg))
+	return b
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
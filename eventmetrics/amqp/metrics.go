@@ -0,0 +1,112 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package amqp
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/fx"
+)
+
+const (
+	queueLabel  = "queue"
+	reasonLabel = "reason"
+
+	invalidMessageReason = "invalid_message"
+	rejectedEventReason  = "rejected_event"
+)
+
+// Measures contains the AMQP consumer metrics, which are only created when the consumer is enabled.
+type Measures struct {
+	fx.In
+	ConsumedMessages *prometheus.CounterVec `name:"amqp_consumed_messages_count" optional:"true"`
+	DroppedMessages  *prometheus.CounterVec `name:"amqp_dropped_messages_count" optional:"true"`
+	Reconnects       prometheus.Counter     `name:"amqp_reconnects_count" optional:"true"`
+}
+
+// ProvideMetrics builds the AMQP consumer metrics if the consumer is enabled.
+func ProvideMetrics() fx.Option {
+	return fx.Provide(
+		fx.Annotated{
+			Name: "amqp_consumed_messages_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "amqp_consumed_messages_count",
+						Help: "The total number of messages consumed from amqp",
+					},
+					queueLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "amqp_dropped_messages_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "amqp_dropped_messages_count",
+						Help: "The total number of messages consumed from amqp that were rejected instead of queued",
+					},
+					queueLabel,
+					reasonLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "amqp_reconnects_count",
+			Target: func(f *touchstone.Factory, config Config) (prometheus.Counter, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounter(
+					prometheus.CounterOpts{
+						Name: "amqp_reconnects_count",
+						Help: "The total number of times the amqp consumer reconnected after its connection closed",
+					},
+				)
+			},
+		},
+	)
+}
+
+func (m Measures) consumed(queue string) {
+	if m.ConsumedMessages != nil {
+		m.ConsumedMessages.With(prometheus.Labels{queueLabel: queue}).Add(1.0)
+	}
+}
+
+func (m Measures) dropped(queue string, reason string) {
+	if m.DroppedMessages != nil {
+		m.DroppedMessages.With(prometheus.Labels{queueLabel: queue, reasonLabel: reason}).Add(1.0)
+	}
+}
+
+func (m Measures) reconnected() {
+	if m.Reconnects != nil {
+		m.Reconnects.Inc()
+	}
+}
@@ -0,0 +1,75 @@
+package amqp
+
+import (
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockConnection struct {
+	mock.Mock
+}
+
+func (m *mockConnection) Channel() (channel, error) {
+	args := m.Called()
+	ch, _ := args.Get(0).(channel)
+	return ch, args.Error(1)
+}
+
+func (m *mockConnection) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type mockChannel struct {
+	mock.Mock
+}
+
+func (m *mockChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	args := m.Called(prefetchCount, prefetchSize, global)
+	return args.Error(0)
+}
+
+func (m *mockChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, table amqp091.Table) error {
+	args := m.Called(name, kind, durable, autoDelete, internal, noWait, table)
+	return args.Error(0)
+}
+
+func (m *mockChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, table amqp091.Table) (amqp091.Queue, error) {
+	args := m.Called(name, durable, autoDelete, exclusive, noWait, table)
+	return args.Get(0).(amqp091.Queue), args.Error(1)
+}
+
+func (m *mockChannel) QueueBind(name, key, exchange string, noWait bool, table amqp091.Table) error {
+	args := m.Called(name, key, exchange, noWait, table)
+	return args.Error(0)
+}
+
+func (m *mockChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, table amqp091.Table) (<-chan amqp091.Delivery, error) {
+	args := m.Called(queue, consumer, autoAck, exclusive, noLocal, noWait, table)
+	deliveries, _ := args.Get(0).(chan amqp091.Delivery)
+	return deliveries, args.Error(1)
+}
+
+func (m *mockChannel) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type mockAcknowledger struct {
+	mock.Mock
+}
+
+func (m *mockAcknowledger) Ack(tag uint64, multiple bool) error {
+	args := m.Called(tag, multiple)
+	return args.Error(0)
+}
+
+func (m *mockAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	args := m.Called(tag, multiple, requeue)
+	return args.Error(0)
+}
+
+func (m *mockAcknowledger) Reject(tag uint64, requeue bool) error {
+	args := m.Called(tag, requeue)
+	return args.Error(0)
+}
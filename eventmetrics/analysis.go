@@ -0,0 +1,63 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+)
+
+const (
+	deviceIDVar = "id"
+)
+
+// DeviceAnalyzer is implemented by parsers that can be run on demand for a single device, such as the reboot
+// duration parser.
+type DeviceAnalyzer interface {
+	Analyze(ctx context.Context, deviceID string) parsers.DeviceAnalysis
+}
+
+// deviceAnalyzers returns the parsers that can analyze a device.
+func deviceAnalyzers(parsers []queue.Parser) []DeviceAnalyzer {
+	var analyzers []DeviceAnalyzer
+	for _, p := range parsers {
+		if analyzer, ok := p.(DeviceAnalyzer); ok {
+			analyzers = append(analyzers, analyzer)
+		}
+	}
+
+	return analyzers
+}
+
+// DeviceAnalysisHandler returns a handler that runs each of the analyzers on the history of events of the
+// device in the path, returning their analyses.
+func DeviceAnalysisHandler(analyzers []DeviceAnalyzer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deviceID := mux.Vars(r)[deviceIDVar]
+		analyses := make([]parsers.DeviceAnalysis, 0, len(analyzers))
+		for _, analyzer := range analyzers {
+			analyses = append(analyses, analyzer.Analyze(r.Context(), deviceID))
+		}
+
+		writeJSON(w, analyses)
+	})
+}
@@ -0,0 +1,92 @@
+package eventmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+)
+
+type testAnalyzerParser struct {
+	testParser
+}
+
+func (p testAnalyzerParser) Analyze(_ context.Context, deviceID string) parsers.DeviceAnalysis {
+	return parsers.DeviceAnalysis{
+		Parser:    p.name,
+		DeviceID:  deviceID,
+		EventID:   "test-event",
+		Durations: []parsers.DurationAnalysis{{Name: "boot_to_manageable", Seconds: 60}},
+	}
+}
+
+func TestDeviceAnalysisRoute(t *testing.T) {
+	tests := []struct {
+		description      string
+		enabled          bool
+		parsers          []queue.Parser
+		expectedCode     int
+		expectedAnalyses []parsers.DeviceAnalysis
+	}{
+		{
+			description:  "enabled",
+			enabled:      true,
+			parsers:      []queue.Parser{testAnalyzerParser{testParser{name: "reboot"}}, testParser{name: "metadata"}},
+			expectedCode: http.StatusOK,
+			expectedAnalyses: []parsers.DeviceAnalysis{
+				{
+					Parser:    "reboot",
+					DeviceID:  "mac:112233445566",
+					EventID:   "test-event",
+					Durations: []parsers.DurationAnalysis{{Name: "boot_to_manageable", Seconds: 60}},
+				},
+			},
+		},
+		{
+			description:      "no analyzers",
+			enabled:          true,
+			parsers:          []queue.Parser{testParser{name: "metadata"}},
+			expectedCode:     http.StatusOK,
+			expectedAnalyses: []parsers.DeviceAnalysis{},
+		},
+		{
+			description:  "disabled",
+			parsers:      []queue.Parser{testAnalyzerParser{testParser{name: "reboot"}}},
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			router := mux.NewRouter()
+			ConfigureAdminRoutes(AdminRoutesIn{
+				Config:     AdminConfig{EnableDeviceAnalysisEndpoint: tc.enabled},
+				Parsers:    tc.parsers,
+				KillSwitch: queue.NewKillSwitch(queue.KillSwitchConfig{}),
+				Router:     router,
+				APIBase:    "api/v1",
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/device/mac:112233445566/analysis", nil))
+			assert.Equal(tc.expectedCode, recorder.Code)
+			if tc.expectedCode == http.StatusOK {
+				var analyses []parsers.DeviceAnalysis
+				assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &analyses))
+				assert.Equal(tc.expectedAnalyses, analyses)
+			}
+
+			// the parsers endpoints are enabled separately.
+			recorder = httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/parsers", nil))
+			assert.Equal(http.StatusNotFound, recorder.Code)
+		})
+	}
+}
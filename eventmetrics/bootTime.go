@@ -0,0 +1,84 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	strictBootTimeParsing  = "strict"
+	lenientBootTimeParsing = "lenient"
+)
+
+var (
+	errInvalidBootTimeParsing = errors.New("invalid boot-time parsing mode")
+)
+
+// lenientBootTimeParsingEnabled returns whether boot-times formatted as floats are accepted, returning an error if
+// the mode is neither strict nor lenient.  An empty mode is strict.
+func lenientBootTimeParsingEnabled(mode string) (bool, error) {
+	switch strings.ToLower(mode) {
+	case "", strictBootTimeParsing:
+		return false, nil
+	case lenientBootTimeParsing:
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: %q", errInvalidBootTimeParsing, mode)
+	}
+}
+
+// lenientBootTime returns the event with its boot-time rewritten as an integer if it was formatted as a float,
+// such as 1.6117e9 or 1611700000.5, truncating any fraction.  The boolean returned is true if the boot-time was
+// rewritten.  Events with integer, missing, or unparsable boot-times are returned as is.
+func lenientBootTime(e interpreter.Event) (interpreter.Event, bool) {
+	key := interpreter.BootTimeKey
+	value, found := e.Metadata[key]
+	if !found {
+		key = strings.Trim(key, "/")
+		value, found = e.Metadata[key]
+	}
+
+	if !found {
+		return e, false
+	}
+
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return e, false
+	}
+
+	bootTime, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(bootTime) || bootTime < math.MinInt64 || bootTime >= math.MaxInt64 {
+		return e, false
+	}
+
+	// copy the metadata so that the original event isn't modified.
+	metadata := make(map[string]string, len(e.Metadata))
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = strconv.FormatInt(int64(bootTime), 10)
+	e.Metadata = metadata
+	return e, true
+}
@@ -0,0 +1,149 @@
+package eventmetrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/validation"
+	"go.uber.org/zap"
+)
+
+func TestLenientBootTimeParsingEnabled(t *testing.T) {
+	tests := []struct {
+		mode            string
+		expectedLenient bool
+		expectedErr     error
+	}{
+		{mode: ""},
+		{mode: "strict"},
+		{mode: "Lenient", expectedLenient: true},
+		{mode: "loose", expectedErr: errInvalidBootTimeParsing},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.mode, func(t *testing.T) {
+			assert := assert.New(t)
+			lenient, err := lenientBootTimeParsingEnabled(tc.mode)
+			assert.Equal(tc.expectedLenient, lenient)
+			assert.True(errors.Is(err, tc.expectedErr))
+		})
+	}
+}
+
+func TestLenientBootTime(t *testing.T) {
+	tests := []struct {
+		description        string
+		metadata           map[string]string
+		lenient            bool
+		expectedBootTime   int64
+		expectedErr        error
+		expectedLenientSum float64
+	}{
+		{
+			description:      "Strict integer",
+			metadata:         map[string]string{interpreter.BootTimeKey: "1611700000"},
+			expectedBootTime: 1611700000,
+		},
+		{
+			description: "Strict float",
+			metadata:    map[string]string{interpreter.BootTimeKey: "1611700000.75"},
+			expectedErr: interpreter.ErrBootTimeParse,
+		},
+		{
+			description: "Strict exponent",
+			metadata:    map[string]string{interpreter.BootTimeKey: "1.6117e9"},
+			expectedErr: interpreter.ErrBootTimeParse,
+		},
+		{
+			description:      "Lenient integer",
+			metadata:         map[string]string{interpreter.BootTimeKey: "1611700000"},
+			lenient:          true,
+			expectedBootTime: 1611700000,
+		},
+		{
+			description:        "Lenient float",
+			metadata:           map[string]string{interpreter.BootTimeKey: "1611700000.75"},
+			lenient:            true,
+			expectedBootTime:   1611700000,
+			expectedLenientSum: 1.0,
+		},
+		{
+			description:        "Lenient exponent",
+			metadata:           map[string]string{"boot-time": "1.6117e9"},
+			lenient:            true,
+			expectedBootTime:   1611700000,
+			expectedLenientSum: 1.0,
+		},
+		{
+			description: "Lenient invalid",
+			metadata:    map[string]string{interpreter.BootTimeKey: "NaN"},
+			lenient:     true,
+			expectedErr: interpreter.ErrBootTimeParse,
+		},
+		{
+			description: "Lenient overflow",
+			metadata:    map[string]string{interpreter.BootTimeKey: "1e19"},
+			lenient:     true,
+			expectedErr: interpreter.ErrBootTimeParse,
+		},
+		{
+			description: "Lenient missing boot-time",
+			metadata:    map[string]string{},
+			lenient:     true,
+			expectedErr: interpreter.ErrBootTimeNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			m := new(mockQueue)
+			m.On("Queue", mock.Anything).Return(nil)
+			var lenientCount prometheus.Counter
+			if tc.lenient {
+				lenientCount = prometheus.NewCounter(prometheus.CounterOpts{
+					Name: "testLenientBootTimeCount",
+					Help: "testLenientBootTimeCount",
+				})
+			}
+
+			endpoints := NewEndpoints(EndpointsIn{
+				Queue:                m,
+				BirthdateValidator:   validation.TimeValidator{ValidFrom: -2 * time.Hour, ValidTo: time.Hour, Current: time.Now},
+				TimeTracker:          new(mockTimeTracker),
+				LenientBootTimeCount: lenientCount,
+				Logger:               zap.NewNop(),
+			})
+
+			metadata := make(map[string]string)
+			for k, v := range tc.metadata {
+				metadata[k] = v
+			}
+			original := interpreter.Event{Birthdate: time.Now().UnixNano(), Metadata: metadata}
+			_, err := endpoints.Event(context.Background(), original)
+			assert.Nil(err)
+			if !assert.Len(m.Calls, 1) {
+				return
+			}
+
+			queued := m.Calls[0].Arguments.Get(0).(queue.EventWithTime).Event
+			bootTime, err := queued.BootTime()
+			assert.Equal(tc.expectedBootTime, bootTime)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if lenientCount != nil {
+				assert.Equal(tc.expectedLenientSum, testutil.ToFloat64(lenientCount))
+			}
+
+			// the original event's metadata isn't modified.
+			assert.Equal(metadata, tc.metadata)
+		})
+	}
+}
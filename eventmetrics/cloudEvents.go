@@ -0,0 +1,194 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+
+	structuredCloudEventType = "application/cloudevents+json"
+	batchCloudEventType      = "application/cloudevents-batch+json"
+
+	cloudEventHeaderPrefix = "Ce-"
+)
+
+// CloudEventsConfig configures the endpoint that accepts CloudEvents, so that glaukos can be fed by event meshes
+// rather than the caduceus webhook.
+type CloudEventsConfig struct {
+	// Enabled turns on the cloudevents endpoint.
+	Enabled bool
+}
+
+// cloudEvent is a CloudEvent in the structured JSON format.  The event's data is a wrp message, encoded as JSON or
+// msgpack as given by the event's datacontenttype.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      string          `json:"data_base64"`
+}
+
+// NewCloudEventDecoder returns a go-kit DecodeRequestFunc that decodes a CloudEvent in either the structured or
+// binary HTTP binding into an interpreter.Event.  The CloudEvent's data is decoded as a wrp message, with the
+// CloudEvent's attributes filling in the message's missing fields: the id is used as the transaction uuid, the
+// source as the source, the subject as the destination, and the time as the birthdate.  If the counter is not nil,
+// any issues found while converting the message are counted by issue type.
+func NewCloudEventDecoder(conversionIssues *prometheus.CounterVec) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		body, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var ce cloudEvent
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case structuredCloudEventType:
+			ce, err = decodeStructuredCloudEvent(body)
+		case batchCloudEventType:
+			err = BadRequestErr{Message: "batched cloudevents are not supported"}
+		default:
+			ce = decodeBinaryCloudEvent(r.Header, body)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := ce.message()
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := interpreter.NewEvent(msg)
+		if event.Birthdate <= 0 && len(ce.Time) > 0 {
+			if t, timeErr := time.Parse(time.RFC3339Nano, ce.Time); timeErr == nil {
+				event.Birthdate, err = t.UnixNano(), nil
+			}
+		}
+
+		countConversionIssues(conversionIssues, findConversionIssues(msg, event, err))
+		return event, nil
+	}
+}
+
+func decodeStructuredCloudEvent(body []byte) (cloudEvent, error) {
+	var ce cloudEvent
+	if err := json.Unmarshal(body, &ce); err != nil {
+		return ce, BadRequestErr{Message: fmt.Sprintf("could not decode cloudevent: %v", err)}
+	}
+
+	if len(ce.DataBase64) > 0 {
+		data, err := base64.StdEncoding.DecodeString(ce.DataBase64)
+		if err != nil {
+			return ce, BadRequestErr{Message: fmt.Sprintf("could not decode cloudevent data_base64: %v", err)}
+		}
+		ce.Data = data
+	}
+
+	// JSON data is embedded in the event, so its content type defaults to JSON.
+	if len(ce.DataContentType) == 0 && len(ce.DataBase64) == 0 {
+		ce.DataContentType = wrp.MimeTypeJson
+	}
+
+	return ce, nil
+}
+
+// decodeBinaryCloudEvent reads the CloudEvent's attributes from the ce- prefixed headers, with the body as the
+// event's data.
+func decodeBinaryCloudEvent(header http.Header, body []byte) cloudEvent {
+	attribute := func(name string) string {
+		return header.Get(cloudEventHeaderPrefix + name)
+	}
+
+	return cloudEvent{
+		SpecVersion:     attribute("specversion"),
+		ID:              attribute("id"),
+		Source:          attribute("source"),
+		Type:            attribute("type"),
+		Subject:         attribute("subject"),
+		Time:            attribute("time"),
+		DataContentType: header.Get("Content-Type"),
+		Data:            body,
+	}
+}
+
+// message validates the CloudEvent's required attributes and decodes its data into a wrp message, filling in the
+// message's missing fields from the attributes.
+func (ce cloudEvent) message() (wrp.Message, error) {
+	if ce.SpecVersion != cloudEventsSpecVersion {
+		return wrp.Message{}, BadRequestErr{Message: fmt.Sprintf("unsupported cloudevents specversion %q", ce.SpecVersion)}
+	}
+
+	var missing []string
+	for _, attribute := range [][2]string{{"id", ce.ID}, {"source", ce.Source}, {"type", ce.Type}} {
+		if len(attribute[1]) == 0 {
+			missing = append(missing, attribute[0])
+		}
+	}
+
+	if len(missing) > 0 {
+		return wrp.Message{}, BadRequestErr{Message: fmt.Sprintf("cloudevent missing required attributes: %s", strings.Join(missing, ", "))}
+	}
+
+	var msg wrp.Message
+	if len(ce.Data) > 0 {
+		var err error
+		if msg, err = decodeMessageBytes(ce.Data, messageFormat(ce.DataContentType)); err != nil {
+			return msg, err
+		}
+	}
+
+	if len(msg.TransactionUUID) == 0 {
+		msg.TransactionUUID = ce.ID
+	}
+
+	if len(msg.Source) == 0 {
+		msg.Source = ce.Source
+	}
+
+	if len(msg.Destination) == 0 {
+		msg.Destination = ce.Subject
+	}
+
+	if msg.Type == wrp.Invalid0MessageType {
+		msg.Type = wrp.SimpleEventMessageType
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,210 @@
+package eventmetrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestCloudEventDecoder(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+
+	msg := wrp.Message{
+		Type:            wrp.SimpleEventMessageType,
+		Source:          "dns:talaria",
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "wrp-id",
+		Metadata:        map[string]string{"/boot-time": "1614707700"},
+		Payload:         []byte(`{"ts": "2021-03-02T18:00:01Z"}`),
+		PartnerIDs:      []string{"partner"},
+	}
+	event := interpreter.Event{
+		MsgType:         int(wrp.SimpleEventMessageType),
+		Source:          "dns:talaria",
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "wrp-id",
+		Metadata:        map[string]string{"/boot-time": "1614707700"},
+		Payload:         `{"ts": "2021-03-02T18:00:01Z"}`,
+		PartnerIDs:      []string{"partner"},
+		Birthdate:       now.UnixNano(),
+	}
+
+	var jsonMsg, msgpackMsg []byte
+	assert.Nil(t, wrp.NewEncoderBytes(&jsonMsg, wrp.JSON).Encode(msg))
+	assert.Nil(t, wrp.NewEncoderBytes(&msgpackMsg, wrp.Msgpack).Encode(msg))
+
+	// a minimal message relying on the cloudevent's attributes.
+	var minimalMsg []byte
+	assert.Nil(t, wrp.NewEncoderBytes(&minimalMsg, wrp.JSON).Encode(wrp.Message{
+		Type:     wrp.SimpleEventMessageType,
+		Metadata: map[string]string{"/boot-time": "1614707700"},
+	}))
+	attributesEvent := interpreter.Event{
+		MsgType:         int(wrp.SimpleEventMessageType),
+		Source:          "mesh",
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "ce-id",
+		Metadata:        map[string]string{"/boot-time": "1614707700"},
+		Birthdate:       now.UnixNano(),
+	}
+
+	structured := func(fields map[string]interface{}) []byte {
+		ce := map[string]interface{}{"specversion": "1.0", "id": "ce-id", "source": "mesh", "type": "device-status",
+			"subject": "event:device-status/mac:112233445566/online", "time": "2021-03-02T18:00:01Z"}
+		for k, v := range fields {
+			ce[k] = v
+		}
+		b, err := json.Marshal(ce)
+		assert.Nil(t, err)
+		return b
+	}
+
+	binaryHeaders := map[string]string{
+		"ce-specversion": "1.0",
+		"ce-id":          "ce-id",
+		"ce-source":      "mesh",
+		"ce-type":        "device-status",
+		"ce-subject":     "event:device-status/mac:112233445566/online",
+		"ce-time":        "2021-03-02T18:00:01Z",
+	}
+
+	tests := []struct {
+		description    string
+		body           []byte
+		headers        map[string]string
+		expectedEvent  interpreter.Event
+		expectedIssues float64
+		expectedErr    bool
+	}{
+		{
+			description:   "Structured JSON data",
+			body:          structured(map[string]interface{}{"data": json.RawMessage(jsonMsg)}),
+			headers:       map[string]string{"Content-Type": structuredCloudEventType},
+			expectedEvent: event,
+		},
+		{
+			description: "Structured base64 msgpack data",
+			body: structured(map[string]interface{}{"datacontenttype": wrp.MimeTypeMsgpack,
+				"data_base64": base64.StdEncoding.EncodeToString(msgpackMsg)}),
+			headers:       map[string]string{"Content-Type": structuredCloudEventType + "; charset=utf-8"},
+			expectedEvent: event,
+		},
+		{
+			description:   "Structured attributes fill in message",
+			body:          structured(map[string]interface{}{"data": json.RawMessage(minimalMsg)}),
+			headers:       map[string]string{"Content-Type": structuredCloudEventType},
+			expectedEvent: attributesEvent,
+		},
+		{
+			description:   "Binary msgpack data",
+			body:          msgpackMsg,
+			headers:       mergeHeaders(binaryHeaders, map[string]string{"Content-Type": wrp.MimeTypeMsgpack}),
+			expectedEvent: event,
+		},
+		{
+			description:   "Binary attributes fill in message",
+			body:          minimalMsg,
+			headers:       mergeHeaders(binaryHeaders, map[string]string{"Content-Type": wrp.MimeTypeJson}),
+			expectedEvent: attributesEvent,
+		},
+		{
+			description: "Binary without data",
+			headers:     binaryHeaders,
+			expectedEvent: interpreter.Event{
+				MsgType:         int(wrp.SimpleEventMessageType),
+				Source:          "mesh",
+				Destination:     "event:device-status/mac:112233445566/online",
+				TransactionUUID: "ce-id",
+				Birthdate:       now.UnixNano(),
+			},
+			expectedIssues: 1,
+		},
+		{
+			description: "Unsupported specversion",
+			body:        msgpackMsg,
+			headers:     mergeHeaders(binaryHeaders, map[string]string{"ce-specversion": "0.3"}),
+			expectedErr: true,
+		},
+		{
+			description: "Missing required attributes",
+			body:        structured(map[string]interface{}{"id": "", "type": ""}),
+			headers:     map[string]string{"Content-Type": structuredCloudEventType},
+			expectedErr: true,
+		},
+		{
+			description: "Invalid structured event",
+			body:        []byte("{{{"),
+			headers:     map[string]string{"Content-Type": structuredCloudEventType},
+			expectedErr: true,
+		},
+		{
+			description: "Invalid base64 data",
+			body:        structured(map[string]interface{}{"data_base64": "!!!"}),
+			headers:     map[string]string{"Content-Type": structuredCloudEventType},
+			expectedErr: true,
+		},
+		{
+			description: "Invalid data",
+			body:        []byte("{{{"),
+			headers:     mergeHeaders(binaryHeaders, map[string]string{"Content-Type": wrp.MimeTypeJson}),
+			expectedErr: true,
+		},
+		{
+			description: "Batch",
+			body:        []byte("[]"),
+			headers:     map[string]string{"Content-Type": batchCloudEventType},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testConversionIssues"}, []string{issueTypeLabel})
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tc.body))
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+
+			event, err := NewCloudEventDecoder(counter)(context.Background(), request)
+			if tc.expectedErr {
+				var statusCoder kithttp.StatusCoder
+				if assert.True(errors.As(err, &statusCoder)) {
+					assert.Equal(http.StatusBadRequest, statusCoder.StatusCode())
+				}
+				assert.Nil(event)
+				return
+			}
+
+			assert.Nil(err)
+			assert.Equal(tc.expectedEvent, event)
+			assert.Equal(tc.expectedIssues, testutil.ToFloat64(counter.WithLabelValues(missingMetadataIssue)))
+			assert.Equal(0.0, testutil.ToFloat64(counter.WithLabelValues(missingBirthdateIssue)))
+		})
+	}
+}
+
+func mergeHeaders(headers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, h := range headers {
+		for k, v := range h {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,69 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/compression"
+)
+
+const defaultMaxDecompressedBytes = 10 << 20
+
+// CompressionConfig configures accepting compressed incoming event bodies.
+type CompressionConfig struct {
+	// Enabled turns on decompressing incoming event bodies with a gzip or deflate Content-Encoding.
+	Enabled bool
+
+	// MaxBodyBytes is the maximum size of a body once it is decompressed.  Larger bodies are rejected with a 413, so
+	// that a small, highly compressed body can't exhaust memory.  Defaults to 10MiB.
+	MaxBodyBytes int64
+}
+
+// decompressRequests creates middleware that decompresses gzip or deflate encoded request bodies, rejecting the
+// bodies with other encodings.  Reading more than the max body bytes configured fails with an *http.MaxBytesError.
+// The bytes read are counted in bytes, if it isn't nil.
+func decompressRequests(config CompressionConfig, bytes *prometheus.CounterVec) mux.MiddlewareFunc {
+	maxBytes := config.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxDecompressedBytes
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := compression.NewReader(r.Header.Get("Content-Encoding"), r.Body, bytes)
+			if errors.Is(err, compression.ErrUnsupportedEncoding) {
+				r.Body.Close()
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+				return
+			} else if err != nil {
+				r.Body.Close()
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, body, maxBytes)
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
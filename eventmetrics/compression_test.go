@@ -0,0 +1,132 @@
+package eventmetrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/compression"
+)
+
+func TestDecompressRequests(t *testing.T) {
+	data := []byte(`{"msg_type":4,"source":"mac:112233445566"}`)
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(data)
+	w.Close()
+
+	tests := []struct {
+		description    string
+		encoding       string
+		body           []byte
+		expectedStatus int
+		expectedBody   []byte
+	}{
+		{
+			description:    "Gzip body",
+			encoding:       "gzip",
+			body:           compressed.Bytes(),
+			expectedStatus: http.StatusOK,
+			expectedBody:   data,
+		},
+		{
+			description:    "Uncompressed body",
+			body:           data,
+			expectedStatus: http.StatusOK,
+			expectedBody:   data,
+		},
+		{
+			description:    "Unsupported encoding",
+			encoding:       "br",
+			body:           data,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			description:    "Invalid gzip body",
+			encoding:       "gzip",
+			body:           data,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testBytes", Help: "testBytes"}, []string{compression.EncodingLabel, compression.CompressionLabel})
+			var received []byte
+			handler := decompressRequests(CompressionConfig{}, counter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Empty(r.Header.Get("Content-Encoding"))
+				received, _ = io.ReadAll(r.Body)
+			}))
+
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/events", bytes.NewReader(tc.body))
+			if len(tc.encoding) > 0 {
+				request.Header.Set("Content-Encoding", tc.encoding)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(tc.expectedStatus, recorder.Code)
+			assert.Equal(tc.expectedBody, received)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(float64(len(data)), testutil.ToFloat64(counter.WithLabelValues(compression.Encoding(tc.encoding), compression.Uncompressed)))
+			}
+		})
+	}
+}
+
+func TestDecompressRequestsMaxBodyBytes(t *testing.T) {
+	// a megabyte of zeros compresses to about a kilobyte.
+	data := make([]byte, 1<<20)
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(data)
+	w.Close()
+
+	tests := []struct {
+		description    string
+		maxBodyBytes   int64
+		expectedStatus int
+	}{
+		{
+			description:    "Default limit",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:    "Within limit",
+			maxBodyBytes:   int64(len(data)),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:    "Exceeds limit",
+			maxBodyBytes:   int64(compressed.Len()),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			handler := decompressRequests(CompressionConfig{MaxBodyBytes: tc.maxBodyBytes}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := readBody(r)
+				if err != nil {
+					w.WriteHeader(err.(RequestTooLargeErr).StatusCode())
+					return
+				}
+				assert.Len(body, len(data))
+			}))
+
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/events", bytes.NewReader(compressed.Bytes()))
+			request.Header.Set("Content-Encoding", "gzip")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(tc.expectedStatus, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,284 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	duplicateEventReason = "duplicateEvent"
+
+	transactionUUIDKeyField = "transactionUUID"
+	deviceIDKeyField        = "deviceID"
+	birthdateKeyField       = "birthdate"
+	destinationKeyField     = "destination"
+
+	memoryDedupStore = "memory"
+	redisDedupStore  = "redis"
+
+	defaultDedupWindow  = 10 * time.Minute
+	defaultDedupMaxKeys = 100000
+)
+
+var (
+	errInvalidDedupKeyField = errors.New("invalid dedup key field")
+	errInvalidDedupStore    = errors.New("invalid dedup store")
+)
+
+// DedupConfig configures dropping incoming events that were already received.
+type DedupConfig struct {
+	// Enabled turns on dropping duplicate events.
+	Enabled bool
+
+	// KeyFields are the event fields that together identify an event: transactionUUID, deviceID, birthdate,
+	// or destination.  Defaults to transactionUUID and destination.
+	KeyFields []string
+
+	// Window is how long an event's key is remembered.  Defaults to 10m.
+	Window time.Duration
+
+	// MaxKeys is the maximum number of keys remembered in memory, after which the oldest keys are forgotten.
+	// Defaults to 100000.
+	MaxKeys int
+
+	// Store is where keys are remembered, either "memory" for a single glaukos or "redis" to drop the duplicates
+	// received by any of the glaukos instances sharing the redis.  Defaults to memory.
+	Store string
+
+	// Redis configures the redis store, whose KeyPrefix defaults to "glaukos:dedup:".
+	Redis RedisConfig
+}
+
+// DedupStoreErrorsIn provides the counter of dedup store errors.
+type DedupStoreErrorsIn struct {
+	fx.In
+	StoreErrors prometheus.Counter `name:"dedup_store_errors_count"`
+}
+
+// DedupStore remembers the keys of received events.
+type DedupStore interface {
+	// Add records the key, returning false if the key was already recorded within the window.
+	Add(ctx context.Context, key string) (bool, error)
+
+	// Remove forgets the key.
+	Remove(ctx context.Context, key string) error
+}
+
+// Deduplicator remembers the keys of recently received events so that duplicates can be dropped.
+type Deduplicator struct {
+	keyFields   []string
+	store       DedupStore
+	storeErrors prometheus.Counter
+	logger      *zap.Logger
+}
+
+// NewDeduplicator creates a new Deduplicator, returning an error if a key field or the store is invalid.  Errors
+// from the store are counted in storeErrors, if it isn't nil.
+func NewDeduplicator(config DedupConfig, storeErrors prometheus.Counter, logger *zap.Logger) (*Deduplicator, error) {
+	if len(config.KeyFields) == 0 {
+		config.KeyFields = []string{transactionUUIDKeyField, destinationKeyField}
+	}
+
+	for _, field := range config.KeyFields {
+		switch field {
+		case transactionUUIDKeyField, deviceIDKeyField, birthdateKeyField, destinationKeyField:
+		default:
+			return nil, fmt.Errorf("%w: %q", errInvalidDedupKeyField, field)
+		}
+	}
+
+	if config.Window <= 0 {
+		config.Window = defaultDedupWindow
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	var store DedupStore
+	switch config.Store {
+	case "", memoryDedupStore:
+		store = newMemoryStore(config.Window, config.MaxKeys)
+	case redisDedupStore:
+		store = newRedisStore(config.Redis, config.Window)
+	default:
+		return nil, fmt.Errorf("%w: %q", errInvalidDedupStore, config.Store)
+	}
+
+	return &Deduplicator{
+		keyFields:   config.KeyFields,
+		store:       store,
+		storeErrors: storeErrors,
+		logger:      logger,
+	}, nil
+}
+
+// key builds the key of the event from the configured fields, returning false if any of the fields are empty.
+func (d *Deduplicator) key(e interpreter.Event) (string, bool) {
+	values := make([]string, 0, len(d.keyFields))
+	for _, field := range d.keyFields {
+		var value string
+		switch field {
+		case transactionUUIDKeyField:
+			value = e.TransactionUUID
+		case deviceIDKeyField:
+			value, _ = deviceid.FromEvent(e)
+		case birthdateKeyField:
+			if e.Birthdate > 0 {
+				value = strconv.FormatInt(e.Birthdate, 10)
+			}
+		case destinationKeyField:
+			value = e.Destination
+		}
+
+		if len(value) == 0 {
+			return "", false
+		}
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "\x00"), true
+}
+
+// Add records the event's key, returning false if the event is a duplicate of one received within the window.
+// Events missing any of the key fields are never treated as duplicates, nor are events whose key can't be
+// recorded because the store failed.
+func (d *Deduplicator) Add(ctx context.Context, e interpreter.Event) bool {
+	key, ok := d.key(e)
+	if !ok {
+		return true
+	}
+
+	added, err := d.store.Add(ctx, key)
+	if err != nil {
+		d.logger.Warn("failed to check for duplicate event", zap.Error(err), zap.String("event id", e.TransactionUUID))
+		d.storeFailed()
+		return true
+	}
+
+	return added
+}
+
+// Remove forgets the event's key, such as when the event couldn't be queued and may be sent again.
+func (d *Deduplicator) Remove(ctx context.Context, e interpreter.Event) {
+	key, ok := d.key(e)
+	if !ok {
+		return
+	}
+
+	if err := d.store.Remove(ctx, key); err != nil {
+		d.logger.Warn("failed to forget event", zap.Error(err), zap.String("event id", e.TransactionUUID))
+		d.storeFailed()
+	}
+}
+
+func (d *Deduplicator) storeFailed() {
+	if d.storeErrors != nil {
+		d.storeErrors.Inc()
+	}
+}
+
+type dedupEntry struct {
+	key  string
+	id   uint64
+	seen time.Time
+}
+
+// memoryStore remembers keys in memory, which only finds the duplicates received by the same glaukos.
+type memoryStore struct {
+	window  time.Duration
+	maxKeys int
+	current func() time.Time
+
+	lock   sync.Mutex
+	nextID uint64
+	seen   map[string]uint64
+	order  []dedupEntry
+}
+
+func newMemoryStore(window time.Duration, maxKeys int) *memoryStore {
+	if maxKeys <= 0 {
+		maxKeys = defaultDedupMaxKeys
+	}
+
+	return &memoryStore{
+		window:  window,
+		maxKeys: maxKeys,
+		current: time.Now,
+		seen:    make(map[string]uint64),
+	}
+}
+
+// Add records the key, returning false if the key was recorded within the window.
+func (m *memoryStore) Add(_ context.Context, key string) (bool, error) {
+	now := m.current()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.expire(now, m.maxKeys)
+	if _, found := m.seen[key]; found {
+		return false, nil
+	}
+
+	// make room for the new key.
+	m.expire(now, m.maxKeys-1)
+
+	m.nextID++
+	m.seen[key] = m.nextID
+	m.order = append(m.order, dedupEntry{key: key, id: m.nextID, seen: now})
+	return true, nil
+}
+
+// Remove forgets the key.
+func (m *memoryStore) Remove(_ context.Context, key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.seen, key)
+	return nil
+}
+
+// expire forgets the keys older than the window, then the oldest keys until at most max keys remain.
+func (m *memoryStore) expire(now time.Time, max int) {
+	windowStart := now.Add(-1 * m.window)
+	i := 0
+	for ; i < len(m.order); i++ {
+		entry := m.order[i]
+		if entry.seen.After(windowStart) && len(m.order)-i <= max {
+			break
+		}
+
+		// the key may have been removed and added again since this entry.
+		if id, found := m.seen[entry.key]; found && id == entry.id {
+			delete(m.seen, entry.key)
+		}
+	}
+
+	m.order = m.order[i:]
+}
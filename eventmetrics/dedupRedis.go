@@ -0,0 +1,63 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisDedupKeyPrefix = "glaukos:dedup:"
+)
+
+type redisClient interface {
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// redisStore remembers keys in redis, expiring them after the window.
+type redisStore struct {
+	client redisClient
+	prefix string
+	window time.Duration
+}
+
+func newRedisStore(config RedisConfig, window time.Duration) *redisStore {
+	if len(config.KeyPrefix) == 0 {
+		config.KeyPrefix = defaultRedisDedupKeyPrefix
+	}
+
+	return &redisStore{
+		client: newRedisClient(config),
+		prefix: config.KeyPrefix,
+		window: window,
+	}
+}
+
+// Add records the key unless it is already in redis, returning false if it was.
+func (r *redisStore) Add(ctx context.Context, key string) (bool, error) {
+	return r.client.SetNX(ctx, r.prefix+key, 1, r.window).Result()
+}
+
+// Remove deletes the key from redis.
+func (r *redisStore) Remove(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key).Err()
+}
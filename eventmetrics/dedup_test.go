@@ -0,0 +1,224 @@
+package eventmetrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestNewDeduplicator(t *testing.T) {
+	tests := []struct {
+		description       string
+		config            DedupConfig
+		expectedKeyFields []string
+		expectedStore     DedupStore
+		expectedErr       error
+	}{
+		{
+			description:       "Defaults",
+			expectedKeyFields: []string{transactionUUIDKeyField, destinationKeyField},
+			expectedStore:     &memoryStore{window: defaultDedupWindow, maxKeys: defaultDedupMaxKeys},
+		},
+		{
+			description: "Custom",
+			config: DedupConfig{
+				KeyFields: []string{deviceIDKeyField, transactionUUIDKeyField, birthdateKeyField, destinationKeyField},
+				Window:    time.Minute,
+				MaxKeys:   10,
+				Store:     memoryDedupStore,
+			},
+			expectedKeyFields: []string{deviceIDKeyField, transactionUUIDKeyField, birthdateKeyField, destinationKeyField},
+			expectedStore:     &memoryStore{window: time.Minute, maxKeys: 10},
+		},
+		{
+			description:       "Redis",
+			config:            DedupConfig{Window: time.Minute, Store: redisDedupStore, Redis: RedisConfig{Address: "localhost:6379"}},
+			expectedKeyFields: []string{transactionUUIDKeyField, destinationKeyField},
+			expectedStore:     &redisStore{prefix: defaultRedisDedupKeyPrefix, window: time.Minute},
+		},
+		{
+			description: "Invalid key field",
+			config:      DedupConfig{KeyFields: []string{transactionUUIDKeyField, "partnerID"}},
+			expectedErr: errInvalidDedupKeyField,
+		},
+		{
+			description: "Invalid store",
+			config:      DedupConfig{Store: "disk"},
+			expectedErr: errInvalidDedupStore,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			d, err := NewDeduplicator(tc.config, nil, nil)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedErr != nil {
+				assert.Nil(d)
+				return
+			}
+
+			assert.Equal(tc.expectedKeyFields, d.keyFields)
+			switch store := d.store.(type) {
+			case *memoryStore:
+				expected := tc.expectedStore.(*memoryStore)
+				assert.Equal(expected.window, store.window)
+				assert.Equal(expected.maxKeys, store.maxKeys)
+			case *redisStore:
+				expected := tc.expectedStore.(*redisStore)
+				assert.NotNil(store.client)
+				assert.Equal(expected.prefix, store.prefix)
+				assert.Equal(expected.window, store.window)
+			default:
+				assert.Fail("unexpected store")
+			}
+		})
+	}
+}
+
+func TestDeduplicatorKeys(t *testing.T) {
+	now := time.Now()
+	event := func(deviceID string, birthdate time.Time) interpreter.Event {
+		return interpreter.Event{
+			Destination:     "event:device-status/" + deviceID + "/online",
+			TransactionUUID: "reused-uuid",
+			Birthdate:       birthdate.UnixNano(),
+		}
+	}
+
+	// the same transaction uuid is reused by different devices and different events of a device.
+	events := []interpreter.Event{
+		event("mac:112233445566", now),
+		event("mac:665544332211", now),
+		event("mac:112233445566", now.Add(time.Minute)),
+		event("mac:112233445566", now),
+	}
+
+	tests := []struct {
+		description   string
+		keyFields     []string
+		expectedAdded []bool
+	}{
+		{
+			description:   "Transaction uuid",
+			keyFields:     []string{transactionUUIDKeyField},
+			expectedAdded: []bool{true, false, false, false},
+		},
+		{
+			description:   "Default",
+			expectedAdded: []bool{true, true, false, false},
+		},
+		{
+			description:   "Device id and transaction uuid",
+			keyFields:     []string{deviceIDKeyField, transactionUUIDKeyField},
+			expectedAdded: []bool{true, true, false, false},
+		},
+		{
+			description:   "Device id, transaction uuid, and birthdate",
+			keyFields:     []string{deviceIDKeyField, transactionUUIDKeyField, birthdateKeyField},
+			expectedAdded: []bool{true, true, true, false},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			d, err := NewDeduplicator(DedupConfig{KeyFields: tc.keyFields}, nil, nil)
+			assert.Nil(err)
+			added := make([]bool, 0, len(events))
+			for _, e := range events {
+				added = append(added, d.Add(context.Background(), e))
+			}
+			assert.Equal(tc.expectedAdded, added)
+		})
+	}
+}
+
+func TestDeduplicatorMissingKeyField(t *testing.T) {
+	assert := assert.New(t)
+	d, err := NewDeduplicator(DedupConfig{KeyFields: []string{deviceIDKeyField, transactionUUIDKeyField}}, nil, nil)
+	assert.Nil(err)
+	e := interpreter.Event{Destination: "event:device-status/mac:112233445566/online"}
+	assert.True(d.Add(context.Background(), e))
+	assert.True(d.Add(context.Background(), e))
+	assert.Empty(d.store.(*memoryStore).seen)
+}
+
+func TestDeduplicatorStoreErrors(t *testing.T) {
+	assert := assert.New(t)
+	storeErr := errors.New("test error")
+	client := new(mockRedisClient)
+	client.On("SetNX", mock.Anything, "prefix:1\x00dest", 1, time.Minute).Return(redis.NewBoolResult(false, storeErr))
+	client.On("Del", mock.Anything, []string{"prefix:1\x00dest"}).Return(redis.NewIntResult(0, storeErr))
+	storeErrors := prometheus.NewCounter(prometheus.CounterOpts{Name: "store_errors"})
+	d, err := NewDeduplicator(DedupConfig{Store: redisDedupStore}, storeErrors, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	d.store = &redisStore{client: client, prefix: "prefix:", window: time.Minute}
+
+	// events that can't be checked are never dropped.
+	e := interpreter.Event{TransactionUUID: "1", Destination: "dest"}
+	assert.True(d.Add(context.Background(), e))
+	assert.True(d.Add(context.Background(), e))
+	d.Remove(context.Background(), e)
+	assert.Equal(3.0, testutil.ToFloat64(storeErrors))
+}
+
+func TestMemoryStoreExpire(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	m := newMemoryStore(time.Minute, 2)
+	m.current = func() time.Time { return now }
+	add := func(key string) bool {
+		added, err := m.Add(context.Background(), key)
+		assert.Nil(err)
+		return added
+	}
+
+	assert.True(add("1"))
+	assert.False(add("1"))
+
+	// removed keys are forgotten.
+	assert.Nil(m.Remove(context.Background(), "1"))
+	assert.True(add("1"))
+
+	// the oldest keys are forgotten once the maximum is reached.
+	assert.True(add("2"))
+	assert.True(add("3"))
+	assert.True(add("1"))
+	assert.False(add("3"))
+
+	// keys are forgotten after the window.
+	now = now.Add(2 * time.Minute)
+	assert.True(add("3"))
+	assert.LessOrEqual(len(m.order), 2)
+}
+
+func TestRedisStore(t *testing.T) {
+	assert := assert.New(t)
+	client := new(mockRedisClient)
+	client.On("SetNX", mock.Anything, "glaukos:dedup:new", 1, time.Minute).Return(redis.NewBoolResult(true, nil)).Once()
+	client.On("SetNX", mock.Anything, "glaukos:dedup:seen", 1, time.Minute).Return(redis.NewBoolResult(false, nil)).Once()
+	client.On("Del", mock.Anything, []string{"glaukos:dedup:seen"}).Return(redis.NewIntResult(1, nil)).Once()
+	store := &redisStore{client: client, prefix: defaultRedisDedupKeyPrefix, window: time.Minute}
+
+	added, err := store.Add(context.Background(), "new")
+	assert.Nil(err)
+	assert.True(added)
+
+	added, err = store.Add(context.Background(), "seen")
+	assert.Nil(err)
+	assert.False(added)
+
+	assert.Nil(store.Remove(context.Background(), "seen"))
+	client.AssertExpectations(t)
+}
@@ -23,14 +23,22 @@ import (
 	"time"
 
 	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/interpreter/validation"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
+const (
+	reasonLabel   = "reason"
+	unknownReason = "unknown"
+)
+
 // GetLoggerFunc is the function used to get a request-specific logger from
 // its context.
 type GetLoggerFunc func(context.Context) *zap.Logger
@@ -45,29 +53,165 @@ type Endpoints struct {
 type EndpointsDecodeIn struct {
 	fx.In
 	Endpoints
-	GetLogger GetLoggerFunc
+	GetLogger        GetLoggerFunc
+	ConversionIssues *prometheus.CounterVec `name:"wrp_conversion_issues_total" optional:"true"`
+}
+
+// EndpointsIn provides everything needed to build the endpoints.
+type EndpointsIn struct {
+	fx.In
+	Queue              queue.Queue
+	BirthdateValidator validation.TimeValidation
+	EventValidator     validation.Validator `name:"incoming_event_validator"`
+	TimeTracker        queue.TimeTracker
+	DroppedEventsCount *prometheus.CounterVec `name:"dropped_events_count"`
+	Deduplicator       *Deduplicator          `optional:"true"`
+
+	// PartnerFilter filters out the events of partners this glaukos isn't scoped to.  If it is nil, events
+	// aren't filtered.
+	PartnerFilter       *PartnerFilter         `optional:"true"`
+	FilteredEventsCount *prometheus.CounterVec `name:"filtered_events_count" optional:"true"`
+
+	// Sharder drops or forwards the events of devices owned by other glaukos instances.  If it is nil, every
+	// event is parsed.
+	Sharder *Sharder `optional:"true"`
+
+	// PartnerRateLimiter limits the rate events are accepted at for each partner.  If it is nil, events
+	// aren't limited.
+	PartnerRateLimiter *PartnerRateLimiter    `optional:"true"`
+	RateLimitedCount   *prometheus.CounterVec `name:"rate_limited_events_count" optional:"true"`
+
+	// RecentEvents records the queued events in the recent history of their device.  If it is nil, events aren't
+	// recorded.
+	RecentEvents *events.RecentEventStore `optional:"true"`
+
+	// LenientBootTimeCount counts the boot-times parsed leniently.  If it is nil, boot-times are parsed strictly.
+	LenientBootTimeCount prometheus.Counter `name:"lenient_boot_time_count" optional:"true"`
+	Logger               *zap.Logger
 }
 
-func NewEndpoints(eventQueue queue.Queue, validator validation.TimeValidation, timeTracker queue.TimeTracker, logger *zap.Logger) Endpoints {
+// NewEndpoints creates the endpoints that receive incoming events and add them to the queue.
+func NewEndpoints(in EndpointsIn) Endpoints {
+	eventValidator := in.EventValidator
+	if eventValidator == nil {
+		eventValidator = validation.DefaultValidator()
+	}
+
 	return Endpoints{
-		Event: func(_ context.Context, request interface{}) (interface{}, error) {
+		Event: func(ctx context.Context, request interface{}) (interface{}, error) {
 			begin := time.Now()
 			v, ok := request.(interpreter.Event)
 			if !ok {
-				timeTracker.TrackTime(time.Since(begin))
+				in.TimeTracker.TrackTime(time.Since(begin))
 				return nil, errors.New("invalid request info: unable to convert to Event")
 			}
 
-			if valid, err := validator.Valid(time.Unix(0, v.Birthdate)); !valid {
-				logger.Error("invalid birthdate", zap.Error(err), zap.Int64("birthdate", v.Birthdate))
+			if in.PartnerFilter != nil {
+				if partner, allowed := in.PartnerFilter.Allow(v.PartnerIDs); !allowed {
+					in.Logger.Debug("filtered out event of partner", zap.String("partner", partner), zap.String("event id", v.TransactionUUID))
+					if in.FilteredEventsCount != nil {
+						in.FilteredEventsCount.With(prometheus.Labels{partnerIDLabel: partner}).Add(1.0)
+					}
+					in.TimeTracker.TrackTime(time.Since(begin))
+					// the event is out of scope, so it is accepted without being queued.
+					if done := queue.DoneFromContext(ctx); done != nil {
+						done(true)
+					}
+					return nil, nil
+				}
+			}
+
+			if in.Sharder != nil {
+				local, err := in.Sharder.Route(ctx, v)
+				if !local {
+					in.TimeTracker.TrackTime(time.Since(begin))
+					// another glaukos owns the event, so it is handled once it is dropped or forwarded.
+					if done := queue.DoneFromContext(ctx); done != nil && err == nil {
+						done(true)
+					}
+					return nil, err
+				}
+			}
+
+			if in.PartnerRateLimiter != nil {
+				if partner, allowed := in.PartnerRateLimiter.Allow(v.PartnerIDs); !allowed {
+					in.Logger.Debug("rejected event over partner rate limit", zap.String("partner", partner), zap.String("event id", v.TransactionUUID))
+					if in.RateLimitedCount != nil {
+						in.RateLimitedCount.With(prometheus.Labels{partnerIDLabel: partner}).Add(1.0)
+					}
+					in.TimeTracker.TrackTime(time.Since(begin))
+					return nil, queue.TooManyRequestsErr{Message: "Partner Rate Limit Exceeded"}
+				}
+			}
+
+			if in.LenientBootTimeCount != nil {
+				var lenient bool
+				if v, lenient = lenientBootTime(v); lenient {
+					in.LenientBootTimeCount.Inc()
+				}
+			}
+
+			if valid, err := in.BirthdateValidator.Valid(time.Unix(0, v.Birthdate)); !valid {
+				in.Logger.Error("invalid birthdate", zap.Error(err), zap.Int64("birthdate", v.Birthdate))
 				v.Birthdate = time.Now().UnixNano()
 			}
 
-			if err := eventQueue.Queue(queue.EventWithTime{Event: v, BeginTime: begin}); err != nil {
-				logger.Error("failed to queue message", zap.Error(err))
+			if valid, err := eventValidator.Valid(v); !valid {
+				in.Logger.Error("rejected invalid event", zap.Error(err), zap.String("event id", v.TransactionUUID))
+				rejected := rejectedEventErr(err)
+				if in.DroppedEventsCount != nil {
+					in.DroppedEventsCount.With(prometheus.Labels{reasonLabel: rejected.Reason}).Add(1.0)
+				}
+				in.TimeTracker.TrackTime(time.Since(begin))
+				return nil, rejected
+			}
+
+			// duplicates were already received, so they are accepted without being queued again.
+			if in.Deduplicator != nil && !in.Deduplicator.Add(ctx, v) {
+				in.Logger.Debug("dropped duplicate event", zap.String("event id", v.TransactionUUID))
+				if in.DroppedEventsCount != nil {
+					in.DroppedEventsCount.With(prometheus.Labels{reasonLabel: duplicateEventReason}).Add(1.0)
+				}
+				in.TimeTracker.TrackTime(time.Since(begin))
+				if done := queue.DoneFromContext(ctx); done != nil {
+					done(true)
+				}
+				return nil, nil
+			}
+
+			eventWithTime := queue.EventWithTime{Event: v, BeginTime: begin, SpanContext: trace.SpanContextFromContext(ctx), Done: queue.DoneFromContext(ctx)}
+			if err := in.Queue.Queue(eventWithTime); err != nil {
+				in.Logger.Error("failed to queue message", zap.Error(err))
+				// the event wasn't processed, so it shouldn't be dropped if it is sent again.
+				if in.Deduplicator != nil {
+					in.Deduplicator.Remove(ctx, v)
+				}
 				return nil, err
 			}
+
+			if in.RecentEvents != nil {
+				in.RecentEvents.Add(ctx, v)
+			}
 			return nil, nil
 		},
 	}
 }
+
+// rejectedEventErr returns the first InvalidEventErr found in the error returned by a validator,
+// falling back to an InvalidEventErr with an unknown reason.
+func rejectedEventErr(err error) InvalidEventErr {
+	errs := []error{err}
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		errs = validationErrs.Errors()
+	}
+
+	for _, e := range errs {
+		var invalidErr InvalidEventErr
+		if errors.As(e, &invalidErr) {
+			return invalidErr
+		}
+	}
+
+	return InvalidEventErr{Reason: unknownReason, Err: err}
+}
@@ -3,9 +3,15 @@ package eventmetrics
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/interpreter/validation"
 	"github.com/xmidt-org/wrp-go/v3"
@@ -28,6 +34,7 @@ func TestNewEndpoints(t *testing.T) {
 		expectedErr error
 		trackTime   bool
 		queueErr    error
+		rejected    bool
 	}{
 		{
 			description: "Not an event",
@@ -49,6 +56,16 @@ func TestNewEndpoints(t *testing.T) {
 			queueErr:    errors.New("queue error"),
 			expectedErr: errors.New("queue error"),
 		},
+		{
+			description: "Rejected Event",
+			event: interpreter.Event{
+				Birthdate: now.Add(-1 * time.Hour).UnixNano(),
+				Metadata:  map[string]string{interpreter.BootTimeKey: fmt.Sprint(now.Unix())},
+			},
+			expectedErr: errBirthdateBeforeBootTime,
+			trackTime:   true,
+			rejected:    true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -60,9 +77,26 @@ func TestNewEndpoints(t *testing.T) {
 			if tc.trackTime {
 				mockTimeTracker.On("TrackTime", mock.Anything).Once()
 			}
-			endpoints := NewEndpoints(m, tv, mockTimeTracker, logger)
+			droppedCount := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testDroppedCount",
+				Help: "testDroppedCount",
+			}, []string{reasonLabel})
+			endpoints := NewEndpoints(EndpointsIn{
+				Queue:              m,
+				BirthdateValidator: tv,
+				EventValidator:     BirthdateBootTimeValidator(time.Minute),
+				TimeTracker:        mockTimeTracker,
+				DroppedEventsCount: droppedCount,
+				Logger:             logger,
+			})
 			resp, err := endpoints.Event(context.Background(), tc.event)
 			assert.Nil(resp)
+			if tc.rejected {
+				var invalidErr InvalidEventErr
+				assert.True(errors.As(err, &invalidErr))
+				assert.Equal(1.0, testutil.ToFloat64(droppedCount.WithLabelValues(birthdateBeforeBootTimeReason)))
+				m.AssertNotCalled(t, "Queue", mock.Anything)
+			}
 			if tc.expectedErr == nil || err == nil {
 				assert.Equal(tc.expectedErr, err)
 			} else {
@@ -73,3 +107,214 @@ func TestNewEndpoints(t *testing.T) {
 	}
 
 }
+
+func TestNewEndpointsDedup(t *testing.T) {
+	tests := []struct {
+		description     string
+		queueErr        error
+		expectedQueued  int
+		expectedDropped float64
+	}{
+		{
+			description:     "Duplicate dropped",
+			expectedQueued:  1,
+			expectedDropped: 1.0,
+		},
+		{
+			description:    "Queue error",
+			queueErr:       errors.New("queue error"),
+			expectedQueued: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			m := new(mockQueue)
+			m.On("Queue", mock.Anything).Return(tc.queueErr)
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything)
+			droppedCount := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testDroppedCount",
+				Help: "testDroppedCount",
+			}, []string{reasonLabel})
+			deduplicator, err := NewDeduplicator(DedupConfig{Enabled: true}, nil, nil)
+			assert.Nil(err)
+			endpoints := NewEndpoints(EndpointsIn{
+				Queue:              m,
+				BirthdateValidator: validation.TimeValidator{ValidFrom: -2 * time.Hour, ValidTo: time.Hour, Current: time.Now},
+				TimeTracker:        mockTimeTracker,
+				DroppedEventsCount: droppedCount,
+				Deduplicator:       deduplicator,
+				Logger:             zap.NewNop(),
+			})
+
+			event := interpreter.Event{TransactionUUID: "test", Destination: "event:device-status/mac:112233445566/online", Birthdate: time.Now().UnixNano()}
+			for i := 0; i < 2; i++ {
+				resp, err := endpoints.Event(context.Background(), event)
+				assert.Nil(resp)
+				assert.Equal(tc.queueErr, err)
+			}
+			m.AssertNumberOfCalls(t, "Queue", tc.expectedQueued)
+			assert.Equal(tc.expectedDropped, testutil.ToFloat64(droppedCount.WithLabelValues(duplicateEventReason)))
+		})
+	}
+}
+
+func TestNewEndpointsPartnerRateLimit(t *testing.T) {
+	assert := assert.New(t)
+	m := new(mockQueue)
+	m.On("Queue", mock.Anything).Return(nil)
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	rateLimitedCount := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testRateLimitedCount",
+		Help: "testRateLimitedCount",
+	}, []string{partnerIDLabel})
+	endpoints := NewEndpoints(EndpointsIn{
+		Queue:              m,
+		BirthdateValidator: validation.TimeValidator{ValidFrom: -2 * time.Hour, ValidTo: time.Hour, Current: time.Now},
+		TimeTracker:        mockTimeTracker,
+		PartnerRateLimiter: NewPartnerRateLimiter(PartnerRateLimitConfig{
+			Enabled:  true,
+			Partners: []PartnerRateLimit{{PartnerID: "noisy", Rate: 0.001, Burst: 2}},
+		}),
+		RateLimitedCount: rateLimitedCount,
+		Logger:           zap.NewNop(),
+	})
+
+	for _, partner := range []string{"noisy", "noisy", "noisy", "quiet", "quiet", "quiet"} {
+		resp, err := endpoints.Event(context.Background(), interpreter.Event{PartnerIDs: []string{partner}, Birthdate: time.Now().UnixNano()})
+		assert.Nil(resp)
+		if partner == "noisy" && err != nil {
+			var statusCoder kithttp.StatusCoder
+			assert.True(errors.As(err, &statusCoder))
+			assert.Equal(http.StatusTooManyRequests, statusCoder.StatusCode())
+		}
+	}
+
+	m.AssertNumberOfCalls(t, "Queue", 5)
+	assert.Equal(1.0, testutil.ToFloat64(rateLimitedCount.WithLabelValues("noisy")))
+	assert.Equal(0.0, testutil.ToFloat64(rateLimitedCount.WithLabelValues("quiet")))
+}
+
+func TestNewEndpointsPartnerFilter(t *testing.T) {
+	assert := assert.New(t)
+	m := new(mockQueue)
+	m.On("Queue", mock.Anything).Return(nil)
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	filteredCount := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testFilteredCount",
+		Help: "testFilteredCount",
+	}, []string{partnerIDLabel})
+	endpoints := NewEndpoints(EndpointsIn{
+		Queue:               m,
+		BirthdateValidator:  validation.TimeValidator{ValidFrom: -2 * time.Hour, ValidTo: time.Hour, Current: time.Now},
+		TimeTracker:         mockTimeTracker,
+		PartnerFilter:       NewPartnerFilter(PartnerFilterConfig{Enabled: true, Deny: []string{"denied"}}),
+		FilteredEventsCount: filteredCount,
+		Logger:              zap.NewNop(),
+	})
+
+	var doneCalls int
+	ctx := queue.WithDone(context.Background(), func(ok bool) {
+		assert.True(ok)
+		doneCalls++
+	})
+	for _, partner := range []string{"denied", "denied", "allowed"} {
+		resp, err := endpoints.Event(ctx, interpreter.Event{PartnerIDs: []string{partner}, Birthdate: time.Now().UnixNano()})
+		assert.Nil(resp)
+		assert.Nil(err)
+	}
+
+	m.AssertNumberOfCalls(t, "Queue", 1)
+	assert.Equal(2, doneCalls)
+	assert.Equal(2.0, testutil.ToFloat64(filteredCount.WithLabelValues("denied")))
+	assert.Equal(0.0, testutil.ToFloat64(filteredCount.WithLabelValues("allowed")))
+}
+
+func TestNewEndpointsDone(t *testing.T) {
+	assert := assert.New(t)
+	var queued []queue.EventWithTime
+	m := new(mockQueue)
+	m.On("Queue", mock.Anything).Run(func(args mock.Arguments) {
+		queued = append(queued, args.Get(0).(queue.EventWithTime))
+	}).Return(nil)
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	deduplicator, err := NewDeduplicator(DedupConfig{Enabled: true}, nil, nil)
+	assert.Nil(err)
+	endpoints := NewEndpoints(EndpointsIn{
+		Queue:              m,
+		BirthdateValidator: validation.TimeValidator{ValidFrom: -2 * time.Hour, ValidTo: time.Hour, Current: time.Now},
+		TimeTracker:        mockTimeTracker,
+		Deduplicator:       deduplicator,
+		Logger:             zap.NewNop(),
+	})
+
+	var handled []bool
+	ctx := queue.WithDone(context.Background(), func(h bool) {
+		handled = append(handled, h)
+	})
+
+	event := interpreter.Event{TransactionUUID: "test", Destination: "event:device-status/mac:112233445566/online", Birthdate: time.Now().UnixNano()}
+	_, err = endpoints.Event(ctx, event)
+	assert.Nil(err)
+
+	// the queue calls done once the event is parsed.
+	assert.Empty(handled)
+	if assert.Len(queued, 1) && assert.NotNil(queued[0].Done) {
+		queued[0].Done(true)
+	}
+	assert.Equal([]bool{true}, handled)
+
+	// duplicates aren't queued, so they are done right away.
+	_, err = endpoints.Event(ctx, event)
+	assert.Nil(err)
+	assert.Len(queued, 1)
+	assert.Equal([]bool{true, true}, handled)
+}
+
+func TestNewEndpointsShard(t *testing.T) {
+	assert := assert.New(t)
+	m := new(mockQueue)
+	m.On("Queue", mock.Anything).Return(nil)
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	sharder, err := NewSharder(ShardConfig{Instance: "local", Members: []ShardMember{{Name: "local"}, {Name: "remote"}}}, nil, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	endpoints := NewEndpoints(EndpointsIn{
+		Queue:              m,
+		BirthdateValidator: validation.TimeValidator{ValidFrom: -2 * time.Hour, ValidTo: time.Hour, Current: time.Now},
+		TimeTracker:        mockTimeTracker,
+		Sharder:            sharder,
+		Logger:             zap.NewNop(),
+	})
+
+	var handled []bool
+	ctx := queue.WithDone(context.Background(), func(h bool) {
+		handled = append(handled, h)
+	})
+
+	// only the events of devices owned by this glaukos are queued, while the others are dropped.
+	local := 0
+	for i := 0; i < 20; i++ {
+		deviceID := fmt.Sprintf("mac:%012d", i)
+		if sharder.owner(deviceID).Name == "local" {
+			local++
+		}
+
+		resp, err := endpoints.Event(ctx, interpreter.Event{Destination: "event:device-status/" + deviceID + "/online", Birthdate: time.Now().UnixNano()})
+		assert.Nil(resp)
+		assert.Nil(err)
+	}
+
+	assert.Greater(local, 0)
+	assert.Less(local, 20)
+	m.AssertNumberOfCalls(t, "Queue", local)
+	assert.Len(handled, 20-local)
+}
@@ -0,0 +1,43 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	environmentLabel = "environment"
+)
+
+// MetricsConfig configures the labels applied to all of glaukos's metrics.
+type MetricsConfig struct {
+	// Environment is the deployment environment, such as dev, staging, or prod, added as the environment constant
+	// label to every metric registered after startup.  If this is empty, the label is omitted.
+	Environment string
+}
+
+// environmentRegisterer wraps the registerer so that every metric registered with it has the environment label.
+// If no environment is configured, the registerer is returned as is.
+func environmentRegisterer(config MetricsConfig, registerer prometheus.Registerer) prometheus.Registerer {
+	if len(config.Environment) == 0 {
+		return registerer
+	}
+
+	return prometheus.WrapRegistererWith(prometheus.Labels{environmentLabel: config.Environment}, registerer)
+}
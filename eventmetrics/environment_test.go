@@ -0,0 +1,60 @@
+package eventmetrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/fx"
+)
+
+func TestEnvironmentRegisterer(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         MetricsConfig
+		expectedLabels map[string]string
+	}{
+		{
+			description:    "Environment",
+			config:         MetricsConfig{Environment: "staging"},
+			expectedLabels: map[string]string{environmentLabel: "staging"},
+		},
+		{
+			description:    "No environment",
+			expectedLabels: map[string]string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var gatherer prometheus.Gatherer
+			app := fx.New(
+				fx.NopLogger,
+				touchstone.Provide(),
+				fx.Supply(tc.config, touchstone.Config{DisableGoCollector: true, DisableProcessCollector: true, DisableBuildInfoCollector: true}),
+				fx.Decorate(environmentRegisterer),
+				touchstone.Counter(prometheus.CounterOpts{Name: "test_count", Help: "test_count"}),
+				fx.Invoke(func(in struct {
+					fx.In
+					Counter prometheus.Counter `name:"test_count"`
+				}) {
+					in.Counter.Inc()
+				}),
+				fx.Populate(&gatherer),
+			)
+			assert.Nil(app.Err())
+
+			families, err := gatherer.Gather()
+			assert.Nil(err)
+			if assert.Len(families, 1) && assert.Len(families[0].GetMetric(), 1) {
+				labels := make(map[string]string)
+				for _, label := range families[0].GetMetric()[0].GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				assert.Equal(tc.expectedLabels, labels)
+			}
+		})
+	}
+}
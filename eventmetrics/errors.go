@@ -17,7 +17,10 @@
 
 package eventmetrics
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+)
 
 type BadRequestErr struct {
 	Message string
@@ -30,3 +33,35 @@ func (e BadRequestErr) Error() string {
 func (e BadRequestErr) StatusCode() int {
 	return http.StatusBadRequest
 }
+
+// RequestTooLargeErr is returned when a request body is larger than the maximum allowed.
+type RequestTooLargeErr struct {
+	Message string
+}
+
+func (e RequestTooLargeErr) Error() string {
+	return e.Message
+}
+
+func (e RequestTooLargeErr) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// InvalidEventErr is returned when an incoming event fails validation and is rejected
+// before reaching the parsers.  Reason is used as the label value in metrics.
+type InvalidEventErr struct {
+	Reason string
+	Err    error
+}
+
+func (e InvalidEventErr) Error() string {
+	return fmt.Sprintf("invalid event (%s): %v", e.Reason, e.Err)
+}
+
+func (e InvalidEventErr) Unwrap() error {
+	return e.Err
+}
+
+func (e InvalidEventErr) StatusCode() int {
+	return http.StatusBadRequest
+}
@@ -18,3 +18,14 @@ func TestBadRequestErr(t *testing.T) {
 	assert.Equal(message, err.Error())
 	assert.Equal(http.StatusBadRequest, err.StatusCode())
 }
+
+func TestInvalidEventErr(t *testing.T) {
+	assert := assert.New(t)
+	innerErr := errors.New("inner error")
+	err := InvalidEventErr{Reason: "test_reason", Err: innerErr}
+	var statusCoder kithttp.StatusCoder
+	assert.True(errors.As(err, &statusCoder))
+	assert.True(errors.Is(err, innerErr))
+	assert.Contains(err.Error(), "test_reason")
+	assert.Equal(http.StatusBadRequest, err.StatusCode())
+}
@@ -0,0 +1,286 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/bascule"
+	"github.com/xmidt-org/bascule/basculehttp"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	grpcEventsServiceName = "glaukos.Events"
+	grpcSubmitStreamName  = "Submit"
+
+	defaultGRPCAuthHeader = "authorization"
+)
+
+var (
+	errMissingAuthorization     = errors.New("missing authorization")
+	errUnsupportedAuthorization = errors.New("unsupported authorization type")
+)
+
+// GRPCConfig configures the gRPC server that receives streams of wrp messages from upstream services.  The
+// server is only started if an address is configured.
+type GRPCConfig struct {
+	// Address is the address the gRPC server listens on.
+	Address string
+
+	// MaxRecvMsgSize is the maximum size of a single message in bytes.  Defaults to grpc's default of 4MB.
+	MaxRecvMsgSize int
+
+	// Auth configures the authorization required to open a stream.
+	Auth GRPCAuthConfig
+}
+
+// GRPCAuthConfig configures the authorization required to open a stream on the gRPC server.
+type GRPCAuthConfig struct {
+	// Header is the metadata key of the authorization, formatted as "<type> <token>".  Defaults to authorization.
+	Header string
+
+	// Basic maps the usernames to the passwords accepted with Basic authorization.  If empty, streams are
+	// not authorized.
+	Basic map[string]string
+}
+
+// TokenValidator validates the authorization sent when opening a stream on the gRPC server.
+type TokenValidator interface {
+	Validate(ctx context.Context, authorization bascule.Authorization, token string) error
+}
+
+// TokenFactoryValidator validates tokens with the bascule token factory of their authorization type, the same
+// way the token factories validate requests to the http server.
+type TokenFactoryValidator map[bascule.Authorization]basculehttp.TokenFactory
+
+// Validate validates the token with the token factory of its authorization type.
+func (v TokenFactoryValidator) Validate(ctx context.Context, authorization bascule.Authorization, token string) error {
+	factory, ok := v[authorization]
+	if !ok {
+		return fmt.Errorf("%w: %q", errUnsupportedAuthorization, authorization)
+	}
+
+	// token factories may read the request, so they are given an empty one since a stream has no single body.
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	_, err = factory.ParseAndValidate(ctx, request, authorization, token)
+	return err
+}
+
+// NewTokenValidator creates the TokenValidator from the configured auth, returning nil if no auth is configured.
+func NewTokenValidator(config GRPCConfig) TokenValidator {
+	if len(config.Auth.Basic) == 0 {
+		return nil
+	}
+
+	return TokenFactoryValidator{
+		basculehttp.BasicAuthorization: basculehttp.BasicTokenFactory(config.Auth.Basic),
+	}
+}
+
+type grpcEventsService interface {
+	Submit(grpc.ServerStream) error
+}
+
+// grpcEventsServiceDesc describes the service without generated code.  Clients open a Submit stream and send
+// google.protobuf.BytesValue messages holding msgpack encoded wrp messages.  When the client closes the stream,
+// the number of events accepted is returned as a google.protobuf.UInt64Value.
+var grpcEventsServiceDesc = grpc.ServiceDesc{
+	ServiceName: grpcEventsServiceName,
+	HandlerType: (*grpcEventsService)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: grpcSubmitStreamName,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(grpcEventsService).Submit(stream)
+			},
+			ClientStreams: true,
+		},
+	},
+}
+
+// GRPCEventServer passes the wrp messages received through gRPC streams to the event endpoint, the same as
+// the events received through the http server.
+type GRPCEventServer struct {
+	event            endpoint.Endpoint
+	conversionIssues *prometheus.CounterVec
+	validator        TokenValidator
+	authHeader       string
+	logger           *zap.Logger
+}
+
+// NewGRPCEventServer creates a GRPCEventServer.  If the validator is nil, streams are not authorized.
+func NewGRPCEventServer(config GRPCConfig, event endpoint.Endpoint, validator TokenValidator, conversionIssues *prometheus.CounterVec, logger *zap.Logger) *GRPCEventServer {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	authHeader := strings.ToLower(config.Auth.Header)
+	if len(authHeader) == 0 {
+		authHeader = defaultGRPCAuthHeader
+	}
+
+	return &GRPCEventServer{
+		event:            event,
+		conversionIssues: conversionIssues,
+		validator:        validator,
+		authHeader:       authHeader,
+		logger:           logger,
+	}
+}
+
+// Submit receives the wrp messages of a stream until the client closes it.  Messages that can't be decoded
+// or are rejected are logged and skipped, while a failure to queue an event ends the stream so that the client
+// can retry.
+func (s *GRPCEventServer) Submit(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx); err != nil {
+		s.logger.Error("rejected unauthorized grpc stream", zap.Error(err))
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	var accepted uint64
+	for {
+		var msg wrapperspb.BytesValue
+		err := stream.RecvMsg(&msg)
+		if errors.Is(err, io.EOF) {
+			return stream.SendMsg(wrapperspb.UInt64(accepted))
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = s.handle(ctx, msg.GetValue()); err == nil {
+			accepted++
+			continue
+		}
+
+		var e kithttp.StatusCoder
+		if errors.As(err, &e) && e.StatusCode() < http.StatusInternalServerError {
+			s.logger.Error("rejected event from grpc stream", zap.Error(err))
+			continue
+		}
+
+		s.logger.Error("failed to process event from grpc stream", zap.Error(err))
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+func (s *GRPCEventServer) handle(ctx context.Context, msgBytes []byte) error {
+	msg, err := decodeMessageBytes(msgBytes, wrp.Msgpack)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.event(ctx, newEvent(msg, s.conversionIssues))
+	return err
+}
+
+// authorize validates the authorization in the stream's metadata, if a validator is configured.
+func (s *GRPCEventServer) authorize(ctx context.Context) error {
+	if s.validator == nil {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(s.authHeader)
+	if len(values) == 0 || len(values[0]) == 0 {
+		return errMissingAuthorization
+	}
+
+	authorization, token, _ := strings.Cut(values[0], " ")
+	return s.validator.Validate(ctx, bascule.Authorization(authorization), token)
+}
+
+// NewGRPCServer creates a grpc.Server serving the GRPCEventServer.
+func NewGRPCServer(config GRPCConfig, eventServer *GRPCEventServer) *grpc.Server {
+	var options []grpc.ServerOption
+	if config.MaxRecvMsgSize > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(config.MaxRecvMsgSize))
+	}
+
+	server := grpc.NewServer(options...)
+	server.RegisterService(&grpcEventsServiceDesc, eventServer)
+	return server
+}
+
+// GRPCServerIn provides everything needed to start the gRPC server.
+type GRPCServerIn struct {
+	fx.In
+	Config           GRPCConfig
+	Endpoints        Endpoints
+	TokenValidator   TokenValidator         `optional:"true"`
+	ConversionIssues *prometheus.CounterVec `name:"wrp_conversion_issues_total" optional:"true"`
+	Logger           *zap.Logger
+	Lifecycle        fx.Lifecycle
+}
+
+// StartGRPCServer starts the gRPC server with the application, if an address is configured.
+func StartGRPCServer(in GRPCServerIn) {
+	if len(in.Config.Address) == 0 {
+		return
+	}
+
+	server := NewGRPCServer(in.Config, NewGRPCEventServer(in.Config, in.Endpoints.Event, in.TokenValidator, in.ConversionIssues, in.Logger))
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			listener, err := net.Listen("tcp", in.Config.Address)
+			if err != nil {
+				return err
+			}
+
+			go server.Serve(listener) // nolint:errcheck
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				server.Stop()
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,207 @@
+package eventmetrics
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/bascule"
+	"github.com/xmidt-org/bascule/basculehttp"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestTokenFactoryValidator(t *testing.T) {
+	validator := TokenFactoryValidator{
+		basculehttp.BasicAuthorization: basculehttp.BasicTokenFactory{"user": "pass"},
+	}
+
+	tests := []struct {
+		description   string
+		authorization string
+		token         string
+		expectedErr   error
+		expectErr     bool
+	}{
+		{
+			description:   "Valid",
+			authorization: "Basic",
+			token:         base64.StdEncoding.EncodeToString([]byte("user:pass")),
+		},
+		{
+			description:   "Invalid token",
+			authorization: "Basic",
+			token:         base64.StdEncoding.EncodeToString([]byte("user:wrong")),
+			expectErr:     true,
+		},
+		{
+			description:   "Unsupported authorization",
+			authorization: "Bearer",
+			token:         "token",
+			expectedErr:   errUnsupportedAuthorization,
+			expectErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			err := validator.Validate(context.Background(), bascule.Authorization(tc.authorization), tc.token)
+			assert.Equal(tc.expectErr, err != nil)
+			if tc.expectedErr != nil {
+				assert.True(errors.Is(err, tc.expectedErr))
+			}
+		})
+	}
+}
+
+func TestNewTokenValidator(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(NewTokenValidator(GRPCConfig{}))
+	validator := NewTokenValidator(GRPCConfig{Auth: GRPCAuthConfig{Basic: map[string]string{"user": "pass"}}})
+	assert.NotNil(validator)
+	assert.Nil(validator.Validate(context.Background(), basculehttp.BasicAuthorization, base64.StdEncoding.EncodeToString([]byte("user:pass"))))
+}
+
+func TestGRPCEventServer(t *testing.T) {
+	basicAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	validMsg := wrp.Message{
+		Source:          "test",
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "abc",
+		Metadata:        map[string]string{"/boot-time": "1611700028"},
+	}
+	rejectedMsg := validMsg
+	rejectedMsg.TransactionUUID = "rejected"
+	failedMsg := validMsg
+	failedMsg.TransactionUUID = "failed"
+
+	tests := []struct {
+		description      string
+		config           GRPCConfig
+		authorization    string
+		messages         [][]byte
+		expectedAccepted uint64
+		expectedIDs      []string
+		expectedCode     codes.Code
+	}{
+		{
+			description:      "No auth",
+			messages:         [][]byte{encodeTestMsg(t, validMsg), encodeTestMsg(t, validMsg)},
+			expectedAccepted: 2,
+			expectedIDs:      []string{"abc", "abc"},
+		},
+		{
+			description:      "Valid auth",
+			config:           GRPCConfig{Auth: GRPCAuthConfig{Basic: map[string]string{"user": "pass"}}},
+			authorization:    basicAuth,
+			messages:         [][]byte{encodeTestMsg(t, validMsg)},
+			expectedAccepted: 1,
+			expectedIDs:      []string{"abc"},
+		},
+		{
+			description:      "Custom auth header",
+			config:           GRPCConfig{Auth: GRPCAuthConfig{Header: "X-Auth", Basic: map[string]string{"user": "pass"}}},
+			authorization:    basicAuth,
+			messages:         [][]byte{encodeTestMsg(t, validMsg)},
+			expectedAccepted: 1,
+			expectedIDs:      []string{"abc"},
+		},
+		{
+			description:   "Invalid auth",
+			config:        GRPCConfig{Auth: GRPCAuthConfig{Basic: map[string]string{"user": "pass"}}},
+			authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("user:wrong")),
+			messages:      [][]byte{encodeTestMsg(t, validMsg)},
+			expectedCode:  codes.Unauthenticated,
+		},
+		{
+			description:  "Missing auth",
+			config:       GRPCConfig{Auth: GRPCAuthConfig{Basic: map[string]string{"user": "pass"}}},
+			messages:     [][]byte{encodeTestMsg(t, validMsg)},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			description:      "Invalid and rejected messages skipped",
+			messages:         [][]byte{[]byte("invalid"), encodeTestMsg(t, rejectedMsg), encodeTestMsg(t, validMsg)},
+			expectedAccepted: 1,
+			expectedIDs:      []string{"rejected", "abc"},
+		},
+		{
+			description:  "Queue failure",
+			messages:     [][]byte{encodeTestMsg(t, validMsg), encodeTestMsg(t, failedMsg), encodeTestMsg(t, validMsg)},
+			expectedIDs:  []string{"abc", "failed"},
+			expectedCode: codes.Unavailable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var ids []string
+			event := func(_ context.Context, request interface{}) (interface{}, error) {
+				e := request.(interpreter.Event)
+				ids = append(ids, e.TransactionUUID)
+				switch e.TransactionUUID {
+				case "rejected":
+					return nil, InvalidEventErr{Reason: "test", Err: errors.New("rejected")}
+				case "failed":
+					return nil, errors.New("queue full")
+				}
+				return nil, nil
+			}
+
+			server := NewGRPCServer(tc.config, NewGRPCEventServer(tc.config, event, NewTokenValidator(tc.config), nil, nil))
+			listener := bufconn.Listen(1024 * 1024)
+			go server.Serve(listener) // nolint:errcheck
+			defer server.Stop()
+
+			conn, err := grpc.DialContext(context.Background(), "bufnet",
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			)
+			assert.Nil(err)
+			defer conn.Close()
+
+			ctx := context.Background()
+			if len(tc.authorization) > 0 {
+				header := tc.config.Auth.Header
+				if len(header) == 0 {
+					header = defaultGRPCAuthHeader
+				}
+				ctx = metadata.AppendToOutgoingContext(ctx, header, tc.authorization)
+			}
+
+			stream, err := conn.NewStream(ctx, &grpcEventsServiceDesc.Streams[0], "/glaukos.Events/Submit")
+			assert.Nil(err)
+			for _, m := range tc.messages {
+				// sending fails once the server ends the stream, which is found when receiving.
+				if stream.SendMsg(wrapperspb.Bytes(m)) != nil {
+					break
+				}
+			}
+			assert.Nil(stream.CloseSend())
+
+			var accepted wrapperspb.UInt64Value
+			err = stream.RecvMsg(&accepted)
+			assert.Equal(tc.expectedCode, status.Code(err))
+			assert.Equal(tc.expectedAccepted, accepted.GetValue())
+			assert.Equal(tc.expectedIDs, ids)
+		})
+	}
+}
+
+func encodeTestMsg(t *testing.T, msg wrp.Message) []byte {
+	var b []byte
+	assert.Nil(t, wrp.NewEncoderBytes(&b, wrp.Msgpack).Encode(msg))
+	return b
+}
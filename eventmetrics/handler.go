@@ -25,28 +25,37 @@ import (
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/candlelight"
 	"github.com/xmidt-org/touchstone"
 	"github.com/xmidt-org/touchstone/touchhttp"
 	"go.uber.org/fx"
 )
 
 type Handler struct {
-	Event http.Handler `name:"eventHandler"`
+	Event      http.Handler `name:"eventHandler"`
+	CloudEvent http.Handler `name:"cloudEventHandler"`
 }
 
 // NewHandlers builds handlers from endpoints and other input provided.
 func NewHandlers(in EndpointsDecodeIn) Handler {
 	return Handler{
-		Event: NewEventHandler(in.Event, in.GetLogger),
+		Event:      NewEventHandler(in.Event, in.GetLogger, NewEventDecoder(in.ConversionIssues)),
+		CloudEvent: NewEventHandler(in.Event, in.GetLogger, NewCloudEventDecoder(in.ConversionIssues)),
 	}
 }
 
-func NewEventHandler(e endpoint.Endpoint, getLogger GetLoggerFunc) http.Handler {
+func NewEventHandler(e endpoint.Endpoint, getLogger GetLoggerFunc, decode kithttp.DecodeRequestFunc) http.Handler {
+	if decode == nil {
+		decode = DecodeEvent
+	}
+
 	return kithttp.NewServer(
 		e,
-		DecodeEvent,
+		decode,
 		EncodeResponseCode(http.StatusOK),
 		kithttp.ServerErrorEncoder(EncodeError(getLogger)),
+		kithttp.ServerBefore(ForwardedFromRequest),
 	)
 }
 
@@ -59,17 +68,35 @@ type RoutesIn struct {
 	ServerBundle touchhttp.ServerBundle
 	Router       *mux.Router `name:"servers.primary"`
 	APIBase      string      `name:"api_base"`
+	Tracing      candlelight.Tracing
+	Config       Config
+
+	// IncomingBytes counts the bytes of incoming event bodies read when compressed bodies are accepted.
+	IncomingBytes *prometheus.CounterVec `name:"incoming_body_bytes_count" optional:"true"`
 }
 
-// ConfigureRoutes sets up the router provided to handle traffic for the events parsing endpoint.
+// ConfigureRoutes sets up the router provided to handle traffic for the events parsing endpoint, and the
+// cloudevents endpoint if it is enabled.  Compressed bodies are decompressed if compression is enabled.
 func ConfigureRoutes(in RoutesIn) {
 	path := fmt.Sprintf("/%s/events", in.APIBase)
 	instrumenter, err := in.ServerBundle.NewInstrumenter("servers.primary")(&touchstone.Factory{})
 	if err != nil {
 		return
 	}
-	in.Router.Use(in.AuthChain.Then)
-	in.Router.Handle(path, instrumenter.Then(in.Handler.Event)).
+	in.Router.Use(traceRequests("servers.primary", in.Tracing), in.AuthChain.Then)
+
+	decompress := func(next http.Handler) http.Handler { return next }
+	if in.Config.Compression.Enabled {
+		decompress = decompressRequests(in.Config.Compression, in.IncomingBytes)
+	}
+
+	in.Router.Handle(path, instrumenter.Then(decompress(in.Handler.Event))).
 		Name("events").
 		Methods("POST")
+
+	if in.Config.CloudEvents.Enabled && in.Handler.CloudEvent != nil {
+		in.Router.Handle(fmt.Sprintf("/%s/cloudevents", in.APIBase), instrumenter.Then(decompress(in.Handler.CloudEvent))).
+			Name("cloudevents").
+			Methods("POST")
+	}
 }
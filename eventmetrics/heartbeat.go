@@ -0,0 +1,326 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	heartbeatEventType  = "glaukos-heartbeat"
+	heartbeatParserName = "heartbeat"
+
+	defaultHeartbeatInterval  = time.Minute
+	defaultHeartbeatThreshold = 30 * time.Second
+)
+
+var errMissingHeartbeatURL = errors.New("heartbeat missing url of the events endpoint")
+
+// HeartbeatConfig configures periodically sending a synthetic heartbeat event through the events endpoint, the
+// queue, and a no-op parser, as an end-to-end liveness signal.
+type HeartbeatConfig struct {
+	// Enabled turns on the heartbeat.
+	Enabled bool
+
+	// Interval is how often a heartbeat is sent.  Defaults to 1m.
+	Interval time.Duration
+
+	// Threshold is how long a heartbeat can take to be parsed before it is counted as missed.  Defaults to 30s.
+	Threshold time.Duration
+
+	// InstanceID identifies this glaukos in the destination of its heartbeats.  Defaults to the hostname.
+	InstanceID string
+
+	// URL is the url of this glaukos's events endpoint on the primary server, such as
+	// http://localhost:4200/api/v1/events, which heartbeats are sent to over HTTP so that they pass through the
+	// server and its auth chain.  It is required when the heartbeat is enabled.
+	URL string
+
+	// Authorization is the Authorization header sent with heartbeats, such as a JWT bearer token when incoming
+	// events are authenticated with JWTs.  Heartbeats are also signed with the webhook's secret, when the secret
+	// is configured.
+	Authorization string
+}
+
+// HeartbeatMeasures are the metrics tracking the heartbeats.
+type HeartbeatMeasures struct {
+	fx.In
+	Latency prometheus.Observer `name:"heartbeat_latency_seconds" optional:"true"`
+	Missed  prometheus.Counter  `name:"heartbeat_missed_count" optional:"true"`
+	Healthy prometheus.Gauge    `name:"heartbeat_healthy" optional:"true"`
+}
+
+// Heartbeat periodically sends a synthetic heartbeat event to the events endpoint, and tracks how long each
+// heartbeat takes to reach its parser.  Heartbeats that aren't accepted by the endpoint, or that aren't parsed
+// within the threshold, are counted as missed.
+type Heartbeat struct {
+	instanceID    string
+	destination   string
+	url           string
+	authorization string
+	sign          RequestSigner
+	client        *http.Client
+	interval      time.Duration
+	threshold     time.Duration
+	measures      HeartbeatMeasures
+	current       func() time.Time
+	logger        *zap.Logger
+
+	lock    sync.Mutex
+	sent    uint64
+	pending map[string]time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewHeartbeat creates a new Heartbeat, returning nil if the heartbeat isn't enabled.  Heartbeats are signed with
+// sign, if it isn't nil.
+func NewHeartbeat(config HeartbeatConfig, sign RequestSigner, measures HeartbeatMeasures, logger *zap.Logger) (*Heartbeat, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if len(config.URL) == 0 {
+		return nil, errMissingHeartbeatURL
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = defaultHeartbeatInterval
+	}
+
+	if config.Threshold <= 0 {
+		config.Threshold = defaultHeartbeatThreshold
+	}
+
+	if len(config.InstanceID) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the hostname for the heartbeat: %w", err)
+		}
+		config.InstanceID = hostname
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Heartbeat{
+		instanceID:    config.InstanceID,
+		destination:   fmt.Sprintf("event:device-status/dns:%s/%s", strings.ToLower(config.InstanceID), heartbeatEventType),
+		url:           config.URL,
+		authorization: config.Authorization,
+		sign:          sign,
+		client:        &http.Client{Timeout: config.Threshold},
+		interval:      config.Interval,
+		threshold:     config.Threshold,
+		measures:      measures,
+		current:       time.Now,
+		logger:        logger,
+		pending:       make(map[string]time.Time),
+	}, nil
+}
+
+// Parser returns the no-op parser that the heartbeats are parsed by.
+func (h *Heartbeat) Parser() queue.Parser {
+	return heartbeatParser{heartbeat: h}
+}
+
+// beat counts the heartbeats that weren't parsed within the threshold as missed, then sends a new heartbeat.
+func (h *Heartbeat) beat(ctx context.Context) {
+	now := h.current()
+	h.lock.Lock()
+	for id, sent := range h.pending {
+		if now.Sub(sent) > h.threshold {
+			delete(h.pending, id)
+			h.missed("heartbeat not parsed within the threshold", zap.String("event id", id))
+		}
+	}
+
+	h.sent++
+	id := fmt.Sprintf("%s-%d-%d", heartbeatEventType, now.UnixNano(), h.sent)
+	h.pending[id] = now
+	h.lock.Unlock()
+
+	event := interpreter.Event{
+		MsgType:         4,
+		Source:          "glaukos",
+		Destination:     h.destination,
+		TransactionUUID: id,
+		Birthdate:       now.UnixNano(),
+		Metadata:        map[string]string{interpreter.BootTimeKey: strconv.FormatInt(now.Unix(), 10)},
+	}
+
+	if err := h.send(ctx, event); err != nil {
+		h.lock.Lock()
+		delete(h.pending, id)
+		h.missed("failed to send heartbeat", zap.Error(err), zap.String("event id", id))
+		h.lock.Unlock()
+	}
+}
+
+// send posts the heartbeat to the events endpoint, returning an error if it isn't accepted.
+func (h *Heartbeat) send(ctx context.Context, event interpreter.Event) error {
+	request, err := newEventRequest(ctx, h.url, event, h.authorization, h.sign)
+	if err != nil {
+		return err
+	}
+
+	// heartbeats are parsed by the glaukos that sent them, rather than being sharded.
+	request.Header.Set(forwardedByHeader, h.instanceID)
+	response, err := h.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body) // nolint:errcheck
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// missed counts a missed heartbeat.  The lock must be held.
+func (h *Heartbeat) missed(msg string, fields ...zap.Field) {
+	h.logger.Warn(msg, fields...)
+	if h.measures.Missed != nil {
+		h.measures.Missed.Inc()
+	}
+
+	if h.measures.Healthy != nil {
+		h.measures.Healthy.Set(0)
+	}
+}
+
+// parsed records that the heartbeat reached its parser.  Heartbeats that were already counted as missed, or that
+// were sent by another glaukos, are ignored.
+func (h *Heartbeat) parsed(event interpreter.Event) {
+	now := h.current()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	sent, found := h.pending[event.TransactionUUID]
+	if !found {
+		return
+	}
+
+	delete(h.pending, event.TransactionUUID)
+	latency := now.Sub(sent)
+	if h.measures.Latency != nil {
+		h.measures.Latency.Observe(latency.Seconds())
+	}
+
+	if latency > h.threshold {
+		h.missed("heartbeat parsed after the threshold", zap.String("event id", event.TransactionUUID), zap.Duration("latency", latency))
+		return
+	}
+
+	if h.measures.Healthy != nil {
+		h.measures.Healthy.Set(1)
+	}
+}
+
+// Start starts sending heartbeats to the events endpoint.
+func (h *Heartbeat) Start() {
+	h.done = make(chan struct{})
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.beat(context.Background())
+			case <-h.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sending heartbeats.
+func (h *Heartbeat) Stop() {
+	if h.done != nil {
+		close(h.done)
+		h.wg.Wait()
+	}
+}
+
+// heartbeatParser is the no-op parser that records when heartbeats are parsed.
+type heartbeatParser struct {
+	heartbeat *Heartbeat
+}
+
+// Parse implements the queue.Parser interface.
+func (p heartbeatParser) Parse(_ context.Context, event interpreter.Event) {
+	if eventType, err := event.EventType(); err == nil && eventType == heartbeatEventType {
+		p.heartbeat.parsed(event)
+	}
+}
+
+// Name implements the queue.Parser interface.
+func (p heartbeatParser) Name() string {
+	return heartbeatParserName
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p heartbeatParser) EventTypeRegexes() []string {
+	return []string{"^" + heartbeatEventType + "$"}
+}
+
+// HeartbeatIn provides everything needed to start the heartbeat.
+type HeartbeatIn struct {
+	fx.In
+	Heartbeat *Heartbeat `optional:"true"`
+	Lifecycle fx.Lifecycle
+}
+
+// startHeartbeat starts the heartbeat with the application, if it is enabled.
+func startHeartbeat(in HeartbeatIn) {
+	if in.Heartbeat == nil {
+		return
+	}
+
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			in.Heartbeat.Start()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			in.Heartbeat.Stop()
+			return nil
+		},
+	})
+}
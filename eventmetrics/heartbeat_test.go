@@ -0,0 +1,166 @@
+package eventmetrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+// newTestHeartbeat creates a heartbeat sending to a server that decodes the heartbeats, passing each to handle
+// along with the request, and responds with the status code handle returns.
+func newTestHeartbeat(t *testing.T, handle func(*http.Request, interpreter.Event) int) (*Heartbeat, *time.Time) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := DecodeEvent(r.Context(), r)
+		if !assert.Nil(t, err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(handle(r, event.(interpreter.Event)))
+	}))
+	t.Cleanup(server.Close)
+
+	measures := HeartbeatMeasures{
+		Latency: prometheus.NewHistogram(prometheus.HistogramOpts{Name: "testLatency", Help: "testLatency"}),
+		Missed:  prometheus.NewCounter(prometheus.CounterOpts{Name: "testMissed", Help: "testMissed"}),
+		Healthy: prometheus.NewGauge(prometheus.GaugeOpts{Name: "testHealthy", Help: "testHealthy"}),
+	}
+
+	sign := func(request *http.Request, body []byte) {
+		request.Header.Set("X-Test-Signature", "signed")
+	}
+
+	h, err := NewHeartbeat(HeartbeatConfig{Enabled: true, Threshold: time.Second, InstanceID: "Glaukos-1", URL: server.URL, Authorization: "Bearer test"}, sign, measures, nil)
+	assert.Nil(t, err)
+	now := time.Now()
+	h.current = func() time.Time { return now }
+	return h, &now
+}
+
+func TestNewHeartbeat(t *testing.T) {
+	assert := assert.New(t)
+	h, err := NewHeartbeat(HeartbeatConfig{}, nil, HeartbeatMeasures{}, nil)
+	assert.Nil(h)
+	assert.Nil(err)
+
+	h, err = NewHeartbeat(HeartbeatConfig{Enabled: true, InstanceID: "Glaukos-1"}, nil, HeartbeatMeasures{}, nil)
+	assert.Nil(h)
+	assert.ErrorIs(err, errMissingHeartbeatURL)
+
+	h, err = NewHeartbeat(HeartbeatConfig{Enabled: true, InstanceID: "Glaukos-1", URL: "http://localhost:4200/api/v1/events"}, nil, HeartbeatMeasures{}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal("event:device-status/dns:glaukos-1/glaukos-heartbeat", h.destination)
+	assert.Equal(defaultHeartbeatInterval, h.interval)
+	assert.Equal(defaultHeartbeatThreshold, h.threshold)
+}
+
+func TestHeartbeat(t *testing.T) {
+	tests := []struct {
+		description     string
+		parseAfter      time.Duration
+		status          int
+		expectedHealthy float64
+		expectedMissed  float64
+		expectedLatency uint64
+	}{
+		{
+			description:     "Parsed within threshold",
+			parseAfter:      500 * time.Millisecond,
+			status:          http.StatusOK,
+			expectedHealthy: 1,
+			expectedLatency: 1,
+		},
+		{
+			description:     "Parsed after threshold",
+			parseAfter:      2 * time.Second,
+			status:          http.StatusOK,
+			expectedMissed:  1,
+			expectedLatency: 1,
+		},
+		{
+			description:    "Rejected",
+			status:         http.StatusForbidden,
+			expectedMissed: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var sent []interpreter.Event
+			var header http.Header
+			h, now := newTestHeartbeat(t, func(r *http.Request, event interpreter.Event) int {
+				sent = append(sent, event)
+				header = r.Header
+				return tc.status
+			})
+
+			h.beat(context.Background())
+			if !assert.Len(sent, 1) {
+				return
+			}
+
+			// heartbeats pass through the auth chain, and aren't sharded.
+			assert.Equal("Bearer test", header.Get("Authorization"))
+			assert.Equal("signed", header.Get("X-Test-Signature"))
+			assert.Equal("Glaukos-1", header.Get(forwardedByHeader))
+			eventType, err := sent[0].EventType()
+			assert.Nil(err)
+			assert.Equal(heartbeatEventType, eventType)
+
+			*now = now.Add(tc.parseAfter)
+			h.Parser().Parse(context.Background(), sent[0])
+			assert.Equal(tc.expectedHealthy, testutil.ToFloat64(h.measures.Healthy))
+			assert.Equal(tc.expectedMissed, testutil.ToFloat64(h.measures.Missed))
+			metric := &dto.Metric{}
+			assert.Nil(h.measures.Latency.(prometheus.Histogram).Write(metric))
+			assert.Equal(tc.expectedLatency, metric.GetHistogram().GetSampleCount())
+			assert.Empty(h.pending)
+		})
+	}
+}
+
+func TestHeartbeatExpired(t *testing.T) {
+	assert := assert.New(t)
+	var sent []interpreter.Event
+	h, now := newTestHeartbeat(t, func(_ *http.Request, event interpreter.Event) int {
+		sent = append(sent, event)
+		return http.StatusOK
+	})
+
+	h.beat(context.Background())
+	*now = now.Add(2 * time.Second)
+	h.beat(context.Background())
+	assert.Len(sent, 2)
+	assert.Len(h.pending, 1)
+	assert.Equal(1.0, testutil.ToFloat64(h.measures.Missed))
+
+	// a heartbeat that was already counted as missed is ignored once it is parsed.
+	h.Parser().Parse(context.Background(), sent[0])
+	assert.Equal(1.0, testutil.ToFloat64(h.measures.Missed))
+	assert.Len(h.pending, 1)
+
+	h.Parser().Parse(context.Background(), sent[1])
+	assert.Equal(1.0, testutil.ToFloat64(h.measures.Healthy))
+	assert.Empty(h.pending)
+}
+
+func TestHeartbeatParserIgnoresOtherEvents(t *testing.T) {
+	assert := assert.New(t)
+	h, _ := newTestHeartbeat(t, nil)
+	h.pending["some-id"] = time.Now()
+	h.Parser().Parse(context.Background(), interpreter.Event{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "some-id"})
+	assert.Len(h.pending, 1)
+	assert.Equal([]string{"^glaukos-heartbeat$"}, heartbeatParser{}.EventTypeRegexes())
+	assert.Equal(heartbeatParserName, h.Parser().Name())
+}
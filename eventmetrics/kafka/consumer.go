@@ -0,0 +1,273 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/segmentio/kafka-go"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	latestOffset   = "latest"
+	earliestOffset = "earliest"
+
+	msgpackFormat = "msgpack"
+	jsonFormat    = "json"
+
+	defaultGroupID      = "glaukos"
+	defaultRetryBackoff = time.Second
+)
+
+var (
+	errMissingBrokers     = errors.New("no kafka brokers configured")
+	errMissingTopics      = errors.New("no kafka topics configured")
+	errInvalidStartOffset = errors.New("invalid start offset")
+	errInvalidFormat      = errors.New("invalid message format")
+)
+
+// Config configures the consumer that reads device-status events from kafka topics as an alternative to the
+// caduceus webhook.
+type Config struct {
+	// Enabled turns on consuming events from kafka.
+	Enabled bool
+
+	// Brokers are the addresses of the kafka brokers.
+	Brokers []string
+
+	// Topics are the topics consumed.
+	Topics []string
+
+	// GroupID is the consumer group, whose offsets are committed to kafka.  Defaults to glaukos.
+	GroupID string
+
+	// StartOffset is where a consumer group without committed offsets starts consuming a partition, either
+	// "latest" or "earliest".  Defaults to "latest".
+	StartOffset string
+
+	// CommitInterval is how often offsets are committed.  If 0, the offset of each message is committed once
+	// the event is queued.
+	CommitInterval time.Duration
+
+	// MaxWait is the maximum time to wait for new messages when fetching.  Defaults to kafka-go's default of 10s.
+	MaxWait time.Duration
+
+	// Format is the encoding of the wrp messages, either "msgpack" or "json".  Defaults to "msgpack".
+	Format string
+
+	// RetryBackoff is how long to wait before trying to queue an event again after the queue failed to accept it.
+	// Defaults to 1s.
+	RetryBackoff time.Duration
+}
+
+type messageReader interface {
+	FetchMessage(context.Context) (kafka.Message, error)
+	CommitMessages(context.Context, ...kafka.Message) error
+	Close() error
+}
+
+// Consumer reads wrp messages from kafka and passes them to the event endpoint, the same as the events received
+// through the webhook.  Offsets are only committed once the event is queued or dropped, so events that the queue
+// fails to accept are retried rather than lost.
+type Consumer struct {
+	reader       messageReader
+	event        endpoint.Endpoint
+	format       wrp.Format
+	retryBackoff time.Duration
+	measures     Measures
+	logger       *zap.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConsumer creates a Consumer reading from the configured topics.
+func NewConsumer(config Config, event endpoint.Endpoint, measures Measures, logger *zap.Logger) (*Consumer, error) {
+	readerConfig, err := newReaderConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := messageFormat(config.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConsumer(kafka.NewReader(readerConfig), event, format, config.RetryBackoff, measures, logger), nil
+}
+
+func newConsumer(reader messageReader, event endpoint.Endpoint, format wrp.Format, retryBackoff time.Duration, measures Measures, logger *zap.Logger) *Consumer {
+	if retryBackoff <= 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Consumer{
+		reader:       reader,
+		event:        event,
+		format:       format,
+		retryBackoff: retryBackoff,
+		measures:     measures,
+		logger:       logger,
+	}
+}
+
+func newReaderConfig(config Config) (kafka.ReaderConfig, error) {
+	if len(config.Brokers) == 0 {
+		return kafka.ReaderConfig{}, errMissingBrokers
+	}
+
+	if len(config.Topics) == 0 {
+		return kafka.ReaderConfig{}, errMissingTopics
+	}
+
+	if len(config.GroupID) == 0 {
+		config.GroupID = defaultGroupID
+	}
+
+	readerConfig := kafka.ReaderConfig{
+		Brokers:        config.Brokers,
+		GroupID:        config.GroupID,
+		GroupTopics:    config.Topics,
+		CommitInterval: config.CommitInterval,
+		MaxWait:        config.MaxWait,
+	}
+
+	switch config.StartOffset {
+	case "", latestOffset:
+		readerConfig.StartOffset = kafka.LastOffset
+	case earliestOffset:
+		readerConfig.StartOffset = kafka.FirstOffset
+	default:
+		return kafka.ReaderConfig{}, fmt.Errorf("%w: %q", errInvalidStartOffset, config.StartOffset)
+	}
+
+	return readerConfig, readerConfig.Validate()
+}
+
+func messageFormat(format string) (wrp.Format, error) {
+	switch format {
+	case "", msgpackFormat:
+		return wrp.Msgpack, nil
+	case jsonFormat:
+		return wrp.JSON, nil
+	default:
+		return wrp.Msgpack, fmt.Errorf("%w: %q", errInvalidFormat, format)
+	}
+}
+
+// Start starts consuming messages.
+func (c *Consumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.run(ctx)
+}
+
+// Stop stops consuming messages and closes the connection to kafka, committing any pending offsets.
+func (c *Consumer) Stop() error {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+
+	return c.reader.Close()
+}
+
+func (c *Consumer) run(ctx context.Context) {
+	defer close(c.done)
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			c.logger.Error("failed to fetch kafka message", zap.Error(err))
+			if !c.wait(ctx) {
+				return
+			}
+			continue
+		}
+
+		c.measures.consumed(msg)
+		if !c.handle(ctx, msg) {
+			return
+		}
+
+		if err := c.reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			c.logger.Error("failed to commit kafka message", zap.Error(err), zap.String("topic", msg.Topic),
+				zap.Int("partition", msg.Partition), zap.Int64("offset", msg.Offset))
+		}
+	}
+}
+
+// handle passes the message to the event endpoint, retrying while the queue fails to accept the event.  It returns
+// false if the consumer was stopped before the event was queued.
+func (c *Consumer) handle(ctx context.Context, msg kafka.Message) bool {
+	var wrpMsg wrp.Message
+	if err := wrp.NewDecoderBytes(msg.Value, c.format).Decode(&wrpMsg); err != nil {
+		c.logger.Error("failed to decode kafka message", zap.Error(err), zap.String("topic", msg.Topic))
+		c.measures.dropped(msg, invalidMessageReason)
+		return true
+	}
+
+	// events with an invalid birthdate are handled by the endpoint.
+	event, _ := interpreter.NewEvent(wrpMsg)
+	for {
+		_, err := c.event(ctx, event)
+		if err == nil {
+			return true
+		}
+
+		var e kithttp.StatusCoder
+		if errors.As(err, &e) && e.StatusCode() < http.StatusInternalServerError && e.StatusCode() != http.StatusTooManyRequests {
+			c.measures.dropped(msg, rejectedEventReason)
+			return true
+		}
+
+		c.logger.Warn("failed to queue kafka event, retrying", zap.Error(err), zap.String("event id", event.TransactionUUID))
+		if !c.wait(ctx) {
+			return false
+		}
+	}
+}
+
+// wait waits for the retry backoff, returning false if the consumer was stopped.
+func (c *Consumer) wait(ctx context.Context) bool {
+	timer := time.NewTimer(c.retryBackoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
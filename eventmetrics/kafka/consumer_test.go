@@ -0,0 +1,228 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestNewReaderConfig(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         Config
+		expectedConfig kafka.ReaderConfig
+		expectedErr    error
+	}{
+		{
+			description: "Defaults",
+			config:      Config{Brokers: []string{"localhost:9092"}, Topics: []string{"events"}},
+			expectedConfig: kafka.ReaderConfig{
+				Brokers:     []string{"localhost:9092"},
+				GroupID:     defaultGroupID,
+				GroupTopics: []string{"events"},
+				StartOffset: kafka.LastOffset,
+			},
+		},
+		{
+			description: "Configured",
+			config: Config{
+				Brokers:        []string{"localhost:9092"},
+				Topics:         []string{"events", "other"},
+				GroupID:        "group",
+				StartOffset:    earliestOffset,
+				CommitInterval: time.Second,
+				MaxWait:        time.Minute,
+			},
+			expectedConfig: kafka.ReaderConfig{
+				Brokers:        []string{"localhost:9092"},
+				GroupID:        "group",
+				GroupTopics:    []string{"events", "other"},
+				StartOffset:    kafka.FirstOffset,
+				CommitInterval: time.Second,
+				MaxWait:        time.Minute,
+			},
+		},
+		{
+			description: "Missing brokers",
+			config:      Config{Topics: []string{"events"}},
+			expectedErr: errMissingBrokers,
+		},
+		{
+			description: "Missing topics",
+			config:      Config{Brokers: []string{"localhost:9092"}},
+			expectedErr: errMissingTopics,
+		},
+		{
+			description: "Invalid start offset",
+			config:      Config{Brokers: []string{"localhost:9092"}, Topics: []string{"events"}, StartOffset: "middle"},
+			expectedErr: errInvalidStartOffset,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			config, err := newReaderConfig(tc.config)
+			assert.True(errors.Is(err, tc.expectedErr))
+			assert.Equal(tc.expectedConfig, config)
+		})
+	}
+}
+
+func TestMessageFormat(t *testing.T) {
+	tests := []struct {
+		description    string
+		format         string
+		expectedFormat wrp.Format
+		expectedErr    error
+	}{
+		{
+			description:    "Default",
+			expectedFormat: wrp.Msgpack,
+		},
+		{
+			description:    "Msgpack",
+			format:         msgpackFormat,
+			expectedFormat: wrp.Msgpack,
+		},
+		{
+			description:    "JSON",
+			format:         jsonFormat,
+			expectedFormat: wrp.JSON,
+		},
+		{
+			description:    "Invalid",
+			format:         "xml",
+			expectedFormat: wrp.Msgpack,
+			expectedErr:    errInvalidFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			format, err := messageFormat(tc.format)
+			assert.True(errors.Is(err, tc.expectedErr))
+			assert.Equal(tc.expectedFormat, format)
+		})
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	validMsg := wrp.Message{
+		Source:          "test",
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "valid",
+	}
+	rejectedMsg := validMsg
+	rejectedMsg.TransactionUUID = "rejected"
+	retriedMsg := validMsg
+	retriedMsg.TransactionUUID = "retried"
+	queueFullMsg := validMsg
+	queueFullMsg.TransactionUUID = "queue-full"
+
+	messages := []kafka.Message{
+		{Topic: "events", Partition: 1, Offset: 5, HighWaterMark: 10, Value: encodeTestMsg(t, validMsg)},
+		{Topic: "events", Partition: 1, Offset: 6, HighWaterMark: 10, Value: []byte("invalid")},
+		{Topic: "events", Partition: 1, Offset: 7, HighWaterMark: 10, Value: encodeTestMsg(t, rejectedMsg)},
+		{Topic: "events", Partition: 1, Offset: 8, HighWaterMark: 10, Value: encodeTestMsg(t, retriedMsg)},
+		{Topic: "events", Partition: 1, Offset: 9, HighWaterMark: 11, Value: encodeTestMsg(t, queueFullMsg)},
+	}
+
+	assert := assert.New(t)
+	reader := new(mockReader)
+	for _, msg := range messages {
+		reader.On("FetchMessage", mock.Anything).Return(msg, nil).Once()
+		reader.On("CommitMessages", mock.Anything, []kafka.Message{msg}).Return(nil).Once()
+	}
+
+	fetched := make(chan struct{})
+	reader.On("FetchMessage", mock.Anything).Run(func(args mock.Arguments) {
+		close(fetched)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(kafka.Message{}, context.Canceled).Once()
+	reader.On("Close").Return(nil).Once()
+
+	var ids []string
+	retries := map[string]int{}
+	event := func(_ context.Context, request interface{}) (interface{}, error) {
+		e := request.(interpreter.Event)
+		ids = append(ids, e.TransactionUUID)
+		switch {
+		case e.TransactionUUID == "rejected":
+			return nil, eventmetrics.InvalidEventErr{Reason: "test", Err: errors.New("rejected")}
+		case e.TransactionUUID == "retried" && retries[e.TransactionUUID] == 0:
+			retries[e.TransactionUUID]++
+			return nil, errors.New("queue full")
+		case e.TransactionUUID == "queue-full" && retries[e.TransactionUUID] == 0:
+			retries[e.TransactionUUID]++
+			return nil, queue.TooManyRequestsErr{Message: "Queue Full"}
+		}
+		return nil, nil
+	}
+
+	measures := Measures{
+		ConsumedMessages: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "consumed"}, []string{topicLabel}),
+		DroppedMessages:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{topicLabel, reasonLabel}),
+		ConsumerLag:      prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "lag"}, []string{topicLabel, partitionLabel}),
+	}
+
+	consumer := newConsumer(reader, event, wrp.Msgpack, time.Millisecond, measures, nil)
+	consumer.Start()
+	select {
+	case <-fetched:
+	case <-time.After(5 * time.Second):
+		assert.Fail("timed out waiting for messages to be consumed")
+	}
+	assert.Nil(consumer.Stop())
+
+	reader.AssertExpectations(t)
+	assert.Equal([]string{"valid", "rejected", "retried", "retried", "queue-full", "queue-full"}, ids)
+	assert.Equal(5.0, testutil.ToFloat64(measures.ConsumedMessages.WithLabelValues("events")))
+	assert.Equal(1.0, testutil.ToFloat64(measures.DroppedMessages.WithLabelValues("events", invalidMessageReason)))
+	assert.Equal(1.0, testutil.ToFloat64(measures.DroppedMessages.WithLabelValues("events", rejectedEventReason)))
+	assert.Equal(1.0, testutil.ToFloat64(measures.ConsumerLag.WithLabelValues("events", "1")))
+}
+
+func TestConsumerStopWhileRetrying(t *testing.T) {
+	assert := assert.New(t)
+	msg := kafka.Message{Topic: "events", Value: encodeTestMsg(t, wrp.Message{Source: "test", Destination: "event:device-status/mac:112233445566/online"})}
+	reader := new(mockReader)
+	reader.On("FetchMessage", mock.Anything).Return(msg, nil).Once()
+	reader.On("Close").Return(nil).Once()
+
+	called := make(chan struct{}, 1)
+	event := func(context.Context, interface{}) (interface{}, error) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		return nil, errors.New("queue full")
+	}
+
+	consumer := newConsumer(reader, event, wrp.Msgpack, time.Hour, Measures{}, nil)
+	consumer.Start()
+	<-called
+	assert.Nil(consumer.Stop())
+
+	// the offset isn't committed since the event was never queued.
+	reader.AssertExpectations(t)
+	reader.AssertNotCalled(t, "CommitMessages", mock.Anything, mock.Anything)
+}
+
+func encodeTestMsg(t *testing.T, msg wrp.Message) []byte {
+	var b []byte
+	assert.Nil(t, wrp.NewEncoderBytes(&b, wrp.Msgpack).Encode(msg))
+	return b
+}
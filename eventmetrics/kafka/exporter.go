@@ -0,0 +1,140 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"go.uber.org/zap"
+)
+
+var (
+	errMissingTopic = errors.New("no kafka topic configured")
+)
+
+// ExportConfig configures publishing the durations calculated by the reboot duration parser to a kafka topic, as
+// JSON records keyed by device id, for analysis outside of prometheus.
+type ExportConfig struct {
+	// Enabled turns on exporting durations to kafka.
+	Enabled bool
+
+	// Brokers are the addresses of the kafka brokers.
+	Brokers []string
+
+	// Topic is the topic the durations are published to.
+	Topic string
+
+	// BatchSize is the maximum number of records sent to kafka at once.  Defaults to kafka-go's default of 100.
+	BatchSize int
+
+	// BatchTimeout is how long to wait for a batch to fill before sending it.  Defaults to kafka-go's default of 1s.
+	BatchTimeout time.Duration
+
+	// MaxAttempts is the number of times a batch is attempted before its records are dropped.  Defaults to
+	// kafka-go's default of 10.
+	MaxAttempts int
+}
+
+type messageWriter interface {
+	WriteMessages(context.Context, ...kafka.Message) error
+	Close() error
+}
+
+// DurationExporter publishes durations to kafka asynchronously, so that parsing isn't slowed down by the brokers.
+// Records that can't be sent are dropped and counted.
+type DurationExporter struct {
+	writer   messageWriter
+	topic    string
+	measures Measures
+	logger   *zap.Logger
+}
+
+// NewDurationExporter creates a DurationExporter publishing to the configured topic.
+func NewDurationExporter(config ExportConfig, measures Measures, logger *zap.Logger) (*DurationExporter, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errMissingBrokers
+	}
+
+	if len(config.Topic) == 0 {
+		return nil, errMissingTopic
+	}
+
+	e := newDurationExporter(nil, config.Topic, measures, logger)
+	e.writer = &kafka.Writer{
+		Addr:         kafka.TCP(config.Brokers...),
+		Topic:        config.Topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    config.BatchSize,
+		BatchTimeout: config.BatchTimeout,
+		MaxAttempts:  config.MaxAttempts,
+		Async:        true,
+		Completion:   e.completed,
+	}
+
+	return e, nil
+}
+
+func newDurationExporter(writer messageWriter, topic string, measures Measures, logger *zap.Logger) *DurationExporter {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &DurationExporter{
+		writer:   writer,
+		topic:    topic,
+		measures: measures,
+		logger:   logger,
+	}
+}
+
+// Export implements the parsers.DurationExporter interface, queuing the record to be sent to kafka.
+func (e *DurationExporter) Export(record parsers.DurationRecord) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		e.logger.Error("failed to encode duration record", zap.Error(err), zap.String("event id", record.TransactionUUID))
+		e.measures.exportFailed(e.topic, 1)
+		return
+	}
+
+	msg := kafka.Message{Key: []byte(record.DeviceID), Value: value}
+	if err := e.writer.WriteMessages(context.Background(), msg); err != nil {
+		e.logger.Error("failed to export duration record", zap.Error(err), zap.String("event id", record.TransactionUUID))
+		e.measures.exportFailed(e.topic, 1)
+	}
+}
+
+// completed records the outcome of a batch sent to kafka.
+func (e *DurationExporter) completed(messages []kafka.Message, err error) {
+	if err != nil {
+		e.logger.Error("failed to send duration records to kafka", zap.Error(err), zap.Int("records", len(messages)))
+		e.measures.exportFailed(e.topic, len(messages))
+		return
+	}
+
+	e.measures.exported(e.topic, len(messages))
+}
+
+// Close sends the records waiting to be sent and closes the connection to kafka.
+func (e *DurationExporter) Close() error {
+	return e.writer.Close()
+}
@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+)
+
+func newTestExportMeasures() Measures {
+	return Measures{
+		ExportedRecords: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "exported"}, []string{topicLabel}),
+		ExportFailures:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "failures"}, []string{topicLabel}),
+	}
+}
+
+func TestNewDurationExporter(t *testing.T) {
+	tests := []struct {
+		description string
+		config      ExportConfig
+		expectedErr error
+	}{
+		{
+			description: "Success",
+			config:      ExportConfig{Brokers: []string{"localhost:9092"}, Topic: "durations"},
+		},
+		{
+			description: "Missing brokers",
+			config:      ExportConfig{Topic: "durations"},
+			expectedErr: errMissingBrokers,
+		},
+		{
+			description: "Missing topic",
+			config:      ExportConfig{Brokers: []string{"localhost:9092"}},
+			expectedErr: errMissingTopic,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			exporter, err := NewDurationExporter(tc.config, Measures{}, nil)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedErr != nil {
+				assert.Nil(exporter)
+				return
+			}
+
+			if assert.NotNil(exporter) {
+				assert.IsType(&kafka.Writer{}, exporter.writer)
+				assert.Nil(exporter.Close())
+			}
+		})
+	}
+}
+
+func TestDurationExporterExport(t *testing.T) {
+	record := parsers.DurationRecord{
+		DeviceID:        "mac:112233445566",
+		TransactionUUID: "test-uuid",
+		DurationType:    "boot_to_manageable",
+		Seconds:         60,
+	}
+	value, err := json.Marshal(record)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		description      string
+		writeErr         error
+		expectedFailures float64
+	}{
+		{
+			description: "Success",
+		},
+		{
+			description:      "Write error",
+			writeErr:         errors.New("writer closed"),
+			expectedFailures: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			writer := new(mockWriter)
+			writer.On("WriteMessages", mock.Anything, []kafka.Message{{Key: []byte("mac:112233445566"), Value: value}}).Return(tc.writeErr).Once()
+			measures := newTestExportMeasures()
+
+			exporter := newDurationExporter(writer, "durations", measures, nil)
+			exporter.Export(record)
+
+			writer.AssertExpectations(t)
+			assert.Equal(tc.expectedFailures, testutil.ToFloat64(measures.ExportFailures.WithLabelValues("durations")))
+		})
+	}
+}
+
+func TestDurationExporterCompleted(t *testing.T) {
+	assert := assert.New(t)
+	measures := newTestExportMeasures()
+	exporter := newDurationExporter(new(mockWriter), "durations", measures, nil)
+
+	exporter.completed(make([]kafka.Message, 3), nil)
+	exporter.completed(make([]kafka.Message, 2), errors.New("broker unavailable"))
+
+	assert.Equal(3.0, testutil.ToFloat64(measures.ExportedRecords.WithLabelValues("durations")))
+	assert.Equal(2.0, testutil.ToFloat64(measures.ExportFailures.WithLabelValues("durations")))
+}
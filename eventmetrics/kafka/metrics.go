@@ -0,0 +1,167 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kafka
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/fx"
+)
+
+const (
+	topicLabel     = "topic"
+	partitionLabel = "partition"
+	reasonLabel    = "reason"
+
+	invalidMessageReason = "invalid_message"
+	rejectedEventReason  = "rejected_event"
+)
+
+// Measures contains the kafka consumer and exporter metrics, which are only created when they are enabled.
+type Measures struct {
+	fx.In
+	ConsumedMessages *prometheus.CounterVec `name:"kafka_consumed_messages_count" optional:"true"`
+	DroppedMessages  *prometheus.CounterVec `name:"kafka_dropped_messages_count" optional:"true"`
+	ConsumerLag      *prometheus.GaugeVec   `name:"kafka_consumer_lag" optional:"true"`
+
+	// ExportedRecords and ExportFailures are only created when durations are exported to kafka.
+	ExportedRecords *prometheus.CounterVec `name:"kafka_exported_records_count" optional:"true"`
+	ExportFailures  *prometheus.CounterVec `name:"kafka_export_failures_count" optional:"true"`
+}
+
+// ProvideMetrics builds the kafka consumer and exporter metrics if they are enabled.
+func ProvideMetrics() fx.Option {
+	return fx.Provide(
+		fx.Annotated{
+			Name: "kafka_consumed_messages_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "kafka_consumed_messages_count",
+						Help: "The total number of messages consumed from kafka",
+					},
+					topicLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "kafka_dropped_messages_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "kafka_dropped_messages_count",
+						Help: "The total number of messages consumed from kafka that were dropped instead of queued",
+					},
+					topicLabel,
+					reasonLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "kafka_consumer_lag",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.GaugeVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Name: "kafka_consumer_lag",
+						Help: "The number of messages in a partition behind the last message consumed",
+					},
+					topicLabel,
+					partitionLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "kafka_exported_records_count",
+			Target: func(f *touchstone.Factory, config ExportConfig) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "kafka_exported_records_count",
+						Help: "The total number of duration records sent to kafka",
+					},
+					topicLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "kafka_export_failures_count",
+			Target: func(f *touchstone.Factory, config ExportConfig) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "kafka_export_failures_count",
+						Help: "The total number of duration records that failed to be sent to kafka and were dropped",
+					},
+					topicLabel,
+				)
+			},
+		},
+	)
+}
+
+func (m Measures) consumed(msg kafka.Message) {
+	if m.ConsumedMessages != nil {
+		m.ConsumedMessages.With(prometheus.Labels{topicLabel: msg.Topic}).Add(1.0)
+	}
+
+	if m.ConsumerLag != nil {
+		m.ConsumerLag.With(prometheus.Labels{
+			topicLabel:     msg.Topic,
+			partitionLabel: strconv.Itoa(msg.Partition),
+		}).Set(float64(msg.HighWaterMark - msg.Offset - 1))
+	}
+}
+
+func (m Measures) dropped(msg kafka.Message, reason string) {
+	if m.DroppedMessages != nil {
+		m.DroppedMessages.With(prometheus.Labels{topicLabel: msg.Topic, reasonLabel: reason}).Add(1.0)
+	}
+}
+
+func (m Measures) exported(topic string, count int) {
+	if m.ExportedRecords != nil {
+		m.ExportedRecords.With(prometheus.Labels{topicLabel: topic}).Add(float64(count))
+	}
+}
+
+func (m Measures) exportFailed(topic string, count int) {
+	if m.ExportFailures != nil {
+		m.ExportFailures.With(prometheus.Labels{topicLabel: topic}).Add(float64(count))
+	}
+}
@@ -0,0 +1,41 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockReader struct {
+	mock.Mock
+}
+
+func (m *mockReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(kafka.Message), args.Error(1)
+}
+
+func (m *mockReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	args := m.Called(ctx, msgs)
+	return args.Error(0)
+}
+
+func (m *mockReader) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type mockWriter struct {
+	mock.Mock
+}
+
+func (m *mockWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	args := m.Called(ctx, msgs)
+	return args.Error(0)
+}
+
+func (m *mockWriter) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
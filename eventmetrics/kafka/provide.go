@@ -0,0 +1,108 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kafka
+
+import (
+	"context"
+
+	"github.com/xmidt-org/arrange"
+	"github.com/xmidt-org/glaukos/eventmetrics"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// ConsumerIn provides everything needed to start the kafka consumer.
+type ConsumerIn struct {
+	fx.In
+	Config    Config
+	Endpoints eventmetrics.Endpoints
+	Measures  Measures
+	Logger    *zap.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// ExporterIn provides everything needed to create the kafka duration exporter.
+type ExporterIn struct {
+	fx.In
+	Config    ExportConfig
+	Measures  Measures
+	Logger    *zap.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// Provide creates an uber/fx option that consumes events from kafka and exports durations to kafka, if enabled,
+// starting and stopping the consumer and exporter with the application.
+func Provide() fx.Option {
+	return fx.Options(
+		ProvideMetrics(),
+		fx.Provide(
+			arrange.UnmarshalKey("kafka", Config{}),
+			arrange.UnmarshalKey("durationExport", ExportConfig{}),
+			fx.Annotated{
+				Group:  "duration_exporters,flatten",
+				Target: provideDurationExporter,
+			},
+		),
+		fx.Invoke(startConsumer),
+	)
+}
+
+func provideDurationExporter(in ExporterIn) ([]parsers.DurationExporter, error) {
+	if !in.Config.Enabled {
+		return nil, nil
+	}
+
+	exporter, err := NewDurationExporter(in.Config, in.Measures, in.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// durations still being parsed are exported before the connection is closed, since the exporter is stopped
+	// after the parsers.
+	in.Lifecycle.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			return exporter.Close()
+		},
+	})
+
+	return []parsers.DurationExporter{exporter}, nil
+}
+
+func startConsumer(in ConsumerIn) error {
+	if !in.Config.Enabled {
+		return nil
+	}
+
+	consumer, err := NewConsumer(in.Config, in.Endpoints.Event, in.Measures, in.Logger)
+	if err != nil {
+		return err
+	}
+
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			consumer.Start()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			return consumer.Stop()
+		},
+	})
+
+	return nil
+}
@@ -0,0 +1,85 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type killSwitchState struct {
+	Engaged bool `json:"engaged"`
+}
+
+// NewKillSwitchHandler returns a handler that reports the state of the kill switch on GET and sets
+// the state of the kill switch on PUT.
+func NewKillSwitchHandler(killSwitch *queue.KillSwitch, logger *zap.Logger) http.Handler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var state killSwitchState
+			if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+				http.Error(w, "invalid kill switch state", http.StatusBadRequest)
+				return
+			}
+
+			if state.Engaged {
+				killSwitch.Engage()
+			} else {
+				killSwitch.Disengage()
+			}
+			logger.Warn("kill switch toggled", zap.Bool("engaged", state.Engaged))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(killSwitchState{Engaged: killSwitch.Engaged()}) // nolint:errcheck
+	})
+}
+
+// KillSwitchRoutesIn provides the information needed to set up the kill switch endpoint.
+type KillSwitchRoutesIn struct {
+	fx.In
+	Config     queue.Config
+	KillSwitch *queue.KillSwitch
+	Logger     *zap.Logger
+	Router     *mux.Router `name:"servers.primary"`
+	APIBase    string      `name:"api_base"`
+}
+
+// ConfigureKillSwitchRoutes sets up the primary router to view and toggle the kill switch, if the endpoint
+// is enabled.  The endpoint is protected by the same auth as the events endpoint.
+func ConfigureKillSwitchRoutes(in KillSwitchRoutesIn) {
+	if !in.Config.KillSwitch.EnableEndpoint || in.Router == nil {
+		return
+	}
+
+	path := fmt.Sprintf("/%s/killswitch", in.APIBase)
+	in.Router.Handle(path, NewKillSwitchHandler(in.KillSwitch, in.Logger)).
+		Name("killswitch").
+		Methods("GET", "PUT")
+}
@@ -0,0 +1,113 @@
+package eventmetrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"go.uber.org/zap"
+)
+
+func TestKillSwitchHandler(t *testing.T) {
+	tests := []struct {
+		description     string
+		method          string
+		body            string
+		startEngaged    bool
+		expectedCode    int
+		expectedEngaged bool
+	}{
+		{
+			description:     "Get engaged",
+			method:          http.MethodGet,
+			startEngaged:    true,
+			expectedCode:    http.StatusOK,
+			expectedEngaged: true,
+		},
+		{
+			description:  "Get disengaged",
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:     "Engage",
+			method:          http.MethodPut,
+			body:            `{"engaged": true}`,
+			expectedCode:    http.StatusOK,
+			expectedEngaged: true,
+		},
+		{
+			description:  "Disengage",
+			method:       http.MethodPut,
+			body:         `{"engaged": false}`,
+			startEngaged: true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:     "Invalid body",
+			method:          http.MethodPut,
+			body:            `engaged`,
+			startEngaged:    true,
+			expectedCode:    http.StatusBadRequest,
+			expectedEngaged: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			killSwitch := queue.NewKillSwitch(queue.KillSwitchConfig{Engaged: tc.startEngaged})
+			handler := NewKillSwitchHandler(killSwitch, zap.NewNop())
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tc.method, "/killswitch", strings.NewReader(tc.body)))
+
+			assert.Equal(tc.expectedCode, recorder.Code)
+			assert.Equal(tc.expectedEngaged, killSwitch.Engaged())
+			if tc.expectedCode == http.StatusOK {
+				var state killSwitchState
+				assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &state))
+				assert.Equal(tc.expectedEngaged, state.Engaged)
+			}
+		})
+	}
+}
+
+func TestConfigureKillSwitchRoutes(t *testing.T) {
+	tests := []struct {
+		description  string
+		enabled      bool
+		expectedCode int
+	}{
+		{
+			description:  "Enabled",
+			enabled:      true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:  "Disabled",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			router := mux.NewRouter()
+			ConfigureKillSwitchRoutes(KillSwitchRoutesIn{
+				Config:     queue.Config{KillSwitch: queue.KillSwitchConfig{EnableEndpoint: tc.enabled}},
+				KillSwitch: queue.NewKillSwitch(queue.KillSwitchConfig{}),
+				Router:     router,
+				APIBase:    "api/v1",
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/killswitch", nil))
+			assert.Equal(tc.expectedCode, recorder.Code)
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package eventmetrics
 
 import (
+	"context"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/mock"
 	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
 )
 
 type mockQueue struct {
@@ -16,6 +19,20 @@ func (m *mockQueue) Queue(e queue.EventWithTime) error {
 	return args.Error(0)
 }
 
+type mockEventSource struct {
+	mock.Mock
+}
+
+func (m *mockEventSource) GetEvents(ctx context.Context, device string) []interpreter.Event {
+	args := m.Called(ctx, device)
+	return args.Get(0).([]interpreter.Event)
+}
+
+func (m *mockEventSource) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	args := m.Called(ctx, deviceIDs)
+	return args.Get(0).(map[string][]interpreter.Event)
+}
+
 type mockTimeTracker struct {
 	mock.Mock
 }
@@ -23,3 +40,61 @@ type mockTimeTracker struct {
 func (m *mockTimeTracker) TrackTime(length time.Duration) {
 	m.Called(length)
 }
+
+type mockRedisClient struct {
+	mock.Mock
+}
+
+func (m *mockRedisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	args := m.Called(ctx, key, value, expiration)
+	return args.Get(0).(*redis.BoolCmd)
+}
+
+func (m *mockRedisClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := m.Called(ctx, keys)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+type mockShardRegistry struct {
+	mock.Mock
+}
+
+func (m *mockShardRegistry) Register(ctx context.Context, member ShardMember, ttl time.Duration) error {
+	args := m.Called(ctx, member, ttl)
+	return args.Error(0)
+}
+
+func (m *mockShardRegistry) Deregister(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *mockShardRegistry) Members(ctx context.Context) ([]ShardMember, error) {
+	args := m.Called(ctx)
+	members, _ := args.Get(0).([]ShardMember)
+	return members, args.Error(1)
+}
+
+type mockRedisShardClient struct {
+	mock.Mock
+}
+
+func (m *mockRedisShardClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	args := m.Called(ctx, key, value, expiration)
+	return args.Get(0).(*redis.StatusCmd)
+}
+
+func (m *mockRedisShardClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := m.Called(ctx, keys)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedisShardClient) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	args := m.Called(ctx, cursor, match, count)
+	return args.Get(0).(*redis.ScanCmd)
+}
+
+func (m *mockRedisShardClient) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	args := m.Called(ctx, keys)
+	return args.Get(0).(*redis.SliceCmd)
+}
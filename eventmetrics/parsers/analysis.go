@@ -0,0 +1,264 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+var (
+	errNoFullyManageableEvent = taxonomy.Wrap(taxonomy.EventNotFound, errors.New("no fully-manageable event found"))
+	errNoEventClient          = errors.New("no event client")
+	errZeroDuration           = taxonomy.ErrZeroDuration
+)
+
+// DeviceAnalysis is the outcome of running the reboot duration parser on a device's latest fully-manageable
+// event, without recording any metrics.
+type DeviceAnalysis struct {
+	Parser      string             `json:"parser"`
+	DeviceID    string             `json:"deviceID"`
+	EventID     string             `json:"eventID,omitempty"`
+	Durations   []DurationAnalysis `json:"durations"`
+	CycleErrors []string           `json:"cycleErrors"`
+	EventErrors []EventErrors      `json:"eventErrors"`
+
+	// Error is the reason the event couldn't be parsed at all, such as when the history of events has no
+	// fully-manageable event.
+	Error string `json:"error,omitempty"`
+
+	// ErrorCode is the taxonomy code of Error.
+	ErrorCode int `json:"errorCode,omitempty"`
+}
+
+func (a *DeviceAnalysis) setError(err error) {
+	a.Error, a.ErrorCode = err.Error(), taxonomy.Code(err)
+}
+
+// DurationAnalysis is the outcome of a single duration calculation.
+type DurationAnalysis struct {
+	Name          string  `json:"name"`
+	Seconds       float64 `json:"seconds"`
+	StartingEvent string  `json:"startingEvent,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	ErrorCode     int     `json:"errorCode,omitempty"`
+}
+
+func (a *DurationAnalysis) setError(err error) {
+	if err != nil {
+		a.Error, a.ErrorCode = err.Error(), taxonomy.Code(err)
+	}
+}
+
+// EventErrors lists the validation errors of an event in the boot-cycle that was validated.
+type EventErrors struct {
+	EventID string   `json:"eventID"`
+	Tags    []string `json:"tags"`
+}
+
+// durationAnalyzer is implemented by duration calculators that can calculate their duration without recording it.
+type durationAnalyzer interface {
+	analyzeDuration(events []interpreter.Event, event interpreter.Event) DurationAnalysis
+}
+
+// validationAnalyzer is implemented by parser validators that can validate the events without recording the
+// errors found.
+type validationAnalyzer interface {
+	analyzeValidation(events []interpreter.Event, currentEvent interpreter.Event) (cycleErrors []string, eventErrors []EventErrors, err error)
+}
+
+// Analyze gets the history of events of a device from codex and runs the parser on the device's latest
+// fully-manageable event, returning the durations calculated and the validation errors found.  Nothing is
+// recorded in the parser's metrics.
+func (p *RebootDurationParser) Analyze(ctx context.Context, deviceID string) DeviceAnalysis {
+	analysis := DeviceAnalysis{
+		Parser:      p.name,
+		DeviceID:    deviceID,
+		Durations:   []DurationAnalysis{},
+		CycleErrors: []string{},
+		EventErrors: []EventErrors{},
+	}
+
+	if p.client == nil {
+		analysis.setError(errNoEventClient)
+		return analysis
+	}
+
+	history := p.client.GetEvents(ctx, deviceID)
+	currentEvent, found := latestFullyManageableEvent(history)
+	if !found {
+		analysis.setError(errNoFullyManageableEvent)
+		return analysis
+	}
+
+	analysis.EventID = currentEvent.TransactionUUID
+	relevantEvents, err := p.relevantEvents(history, currentEvent)
+	if err != nil {
+		analysis.setError(err)
+		return analysis
+	}
+
+	for _, parserValidator := range p.parserValidators {
+		analyzer, ok := parserValidator.(validationAnalyzer)
+		if !ok {
+			continue
+		}
+
+		cycleErrors, eventErrors, err := analyzer.analyzeValidation(relevantEvents, currentEvent)
+		if err != nil {
+			analysis.setError(err)
+			return analysis
+		}
+		analysis.CycleErrors = append(analysis.CycleErrors, cycleErrors...)
+		analysis.EventErrors = append(analysis.EventErrors, eventErrors...)
+	}
+
+	for _, calculator := range p.calculators {
+		if analyzer, ok := calculator.(durationAnalyzer); ok {
+			analysis.Durations = append(analysis.Durations, analyzer.analyzeDuration(relevantEvents, currentEvent))
+		}
+	}
+
+	return analysis
+}
+
+// latestFullyManageableEvent returns the fully-manageable event with the newest boot-time, using the newest
+// birthdate to break ties.
+func latestFullyManageableEvent(events []interpreter.Event) (interpreter.Event, bool) {
+	var (
+		latest   interpreter.Event
+		found    bool
+		latestBT int64
+	)
+
+	for _, event := range events {
+		if eventType, err := event.EventType(); err != nil || eventType != fullyManageableEventType {
+			continue
+		}
+
+		bootTime, _ := event.BootTime()
+		if !found || bootTime > latestBT || (bootTime == latestBT && event.Birthdate > latest.Birthdate) {
+			latest, latestBT, found = event, bootTime, true
+		}
+	}
+
+	return latest, found
+}
+
+func (c bootDurationCalculator) analyzeDuration(_ []interpreter.Event, event interpreter.Event) DurationAnalysis {
+	analysis := DurationAnalysis{Name: c.name}
+	bootDuration, timesFound := calculateBootDuration(event)
+	analysis.Seconds = bootDuration
+	analysis.setError(durationError(bootDuration, timesFound))
+	return analysis
+}
+
+func (c *EventToCurrentCalculator) analyzeDuration(events []interpreter.Event, event interpreter.Event) DurationAnalysis {
+	analysis := DurationAnalysis{Name: c.name}
+	startingEvent, err := untimedFinder(c.eventFinder).Find(events, event)
+	if err != nil {
+		analysis.setError(errEventNotFound)
+		return analysis
+	}
+
+	var endFinder Finder
+	if c.endFinder != nil {
+		endFinder = untimedFinder(c.endFinder)
+	}
+
+	endingEvent, err := c.endingEvent(endFinder, events, event)
+	if err != nil {
+		analysis.StartingEvent = startingEvent.TransactionUUID
+		analysis.setError(errEventNotFound)
+		return analysis
+	}
+
+	timeElapsed, timesFound := c.timeElapsed(startingEvent, endingEvent)
+	analysis.StartingEvent = startingEvent.TransactionUUID
+	analysis.Seconds = timeElapsed
+	analysis.setError(durationError(timeElapsed, timesFound))
+	return analysis
+}
+
+// durationError describes why a duration would be rejected.  Whether zero durations are recorded depends on
+// the parser's zero duration policy, so they are always reported.
+func durationError(duration float64, timesFound bool) error {
+	switch {
+	case timesFound && duration == 0:
+		return errZeroDuration
+	case duration <= 0:
+		return errCalculation
+	default:
+		return nil
+	}
+}
+
+// untimedFinder returns the finder without the timing of its scans, so that analyzing a device isn't recorded
+// in the finder_scan_seconds histogram.
+func untimedFinder(finder Finder) Finder {
+	if timed, ok := finder.(timedFinder); ok {
+		return timed.finder
+	}
+
+	return finder
+}
+
+func (p *parserValidator) analyzeValidation(events []interpreter.Event, currentEvent interpreter.Event) ([]string, []EventErrors, error) {
+	if !p.shouldActivate(events, currentEvent) {
+		return nil, nil, nil
+	}
+
+	cycle, err := p.cycleParser.Parse(events, currentEvent)
+	if err != nil {
+		return nil, nil, errFatal
+	}
+
+	var eventErrors []EventErrors
+	for _, event := range cycle {
+		if valid, err := p.eventValidator.Valid(event); !valid {
+			eventErrors = append(eventErrors, EventErrors{EventID: event.TransactionUUID, Tags: errorTags(err)})
+		}
+	}
+
+	var cycleErrors []string
+	if valid, err := p.cycleValidator.Valid(cycle); !valid {
+		cycleErrors = errorTags(err)
+	}
+
+	return cycleErrors, eventErrors, nil
+}
+
+// errorTags returns the tags of a validation error, falling back to the unknown tag for errors without tags.  Events
+// rejected for an implausible boot-time have their own reason.
+func errorTags(err error) []string {
+	var taggedErrs validation.TaggedErrors
+	var taggedErr validation.TaggedError
+	if errors.Is(err, ErrImplausibleBootTime) {
+		return []string{implausibleBootTimeReason}
+	} else if errors.As(err, &taggedErrs) {
+		return validation.TagsToStrings(taggedErrs.UniqueTags())
+	} else if errors.As(err, &taggedErr) {
+		return []string{taggedErr.Tag().String()}
+	}
+
+	return []string{validation.Unknown.String()}
+}
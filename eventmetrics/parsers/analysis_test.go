@@ -0,0 +1,161 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/validation"
+	"go.uber.org/zap"
+)
+
+func TestAnalyze(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+
+	var (
+		rebootPending = interpreter.Event{
+			Destination:     "event:device-status/mac:112233445566/reboot-pending",
+			TransactionUUID: "reboot-pending",
+			Metadata:        map[string]string{interpreter.BootTimeKey: fmt.Sprint(now.Add(-1 * time.Hour).Unix())},
+			Birthdate:       now.Add(-5 * time.Minute).UnixNano(),
+		}
+		oldManageable = interpreter.Event{
+			Destination:     "event:device-status/mac:112233445566/fully-manageable",
+			TransactionUUID: "old",
+			Metadata:        map[string]string{interpreter.BootTimeKey: fmt.Sprint(now.Add(-1 * time.Hour).Unix())},
+			Birthdate:       now.Add(-50 * time.Minute).UnixNano(),
+		}
+		latestManageable = interpreter.Event{
+			Destination:     "event:device-status/mac:112233445566/fully-manageable",
+			TransactionUUID: "latest",
+			Metadata:        map[string]string{interpreter.BootTimeKey: fmt.Sprint(now.Unix())},
+			Birthdate:       now.Add(2 * time.Minute).UnixNano(),
+		}
+	)
+
+	tests := []struct {
+		description      string
+		history          []interpreter.Event
+		cycleValid       bool
+		cycleErr         error
+		eventValid       bool
+		eventErr         error
+		finderErr        error
+		expectedAnalysis DeviceAnalysis
+	}{
+		{
+			description: "no fully-manageable event",
+			history:     []interpreter.Event{rebootPending},
+			expectedAnalysis: DeviceAnalysis{
+				Error:     errNoFullyManageableEvent.Error(),
+				ErrorCode: taxonomy.EventNotFound.Code,
+			},
+		},
+		{
+			description: "valid",
+			history:     []interpreter.Event{oldManageable, rebootPending, latestManageable},
+			cycleValid:  true,
+			eventValid:  true,
+			expectedAnalysis: DeviceAnalysis{
+				EventID: "latest",
+				Durations: []DurationAnalysis{
+					{Name: "boot_to_manageable", Seconds: 120},
+					{Name: "reboot_to_manageable", Seconds: 420, StartingEvent: "reboot-pending"},
+				},
+			},
+		},
+		{
+			description: "invalid",
+			history:     []interpreter.Event{oldManageable, rebootPending, latestManageable},
+			cycleErr:    testTaggedErrors{tags: []validation.Tag{validation.InvalidBootTime, validation.InvalidBootTime}},
+			eventErr:    testTaggedError{tag: validation.InvalidBirthdate},
+			finderErr:   errEventNotFound,
+			expectedAnalysis: DeviceAnalysis{
+				EventID:     "latest",
+				CycleErrors: []string{validation.InvalidBootTime.String()},
+				EventErrors: []EventErrors{
+					{EventID: "latest", Tags: []string{validation.InvalidBirthdate.String()}},
+					{EventID: "reboot-pending", Tags: []string{validation.InvalidBirthdate.String()}},
+				},
+				Durations: []DurationAnalysis{
+					{Name: "boot_to_manageable", Seconds: 120},
+					{Name: "reboot_to_manageable", Error: errEventNotFound.Error(), ErrorCode: taxonomy.EventNotFound.Code},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", "mac:112233445566").Return(tc.history)
+			eventsParser := new(mockEventsParser)
+			eventsParser.On("Parse", mock.Anything, mock.Anything).Return([]interpreter.Event{latestManageable, rebootPending}, nil)
+			cycleValidator := new(mockCycleValidator)
+			cycleValidator.On("Valid", mock.Anything).Return(tc.cycleValid, tc.cycleErr)
+			eventValidator := new(mockValidator)
+			eventValidator.On("Valid", mock.Anything).Return(tc.eventValid, tc.eventErr)
+			finder := new(mockFinder)
+			finder.On("Find", mock.Anything, mock.Anything).Return(rebootPending, tc.finderErr)
+
+			var recorded int
+			record := func(interpreter.Event, bool, error) { recorded++ }
+			parser := RebootDurationParser{
+				name:                 "test_reboot_parser",
+				logger:               zap.NewNop(),
+				relevantEventsParser: eventsParser,
+				client:               client,
+				parserValidators: []ParserValidator{
+					NewParserValidator(
+						cycleValidation{parser: eventsParser, validator: cycleValidator, callback: record},
+						eventValidation{validator: eventValidator, callback: record},
+						nil,
+					),
+				},
+				calculators: []DurationCalculator{
+					bootDurationCalculator{name: "boot_to_manageable"},
+					&EventToCurrentCalculator{name: "reboot_to_manageable", eventFinder: finder},
+				},
+			}
+
+			expected := tc.expectedAnalysis
+			expected.Parser = "test_reboot_parser"
+			expected.DeviceID = "mac:112233445566"
+			if expected.Durations == nil {
+				expected.Durations = []DurationAnalysis{}
+			}
+			if expected.CycleErrors == nil {
+				expected.CycleErrors = []string{}
+			}
+			if expected.EventErrors == nil {
+				expected.EventErrors = []EventErrors{}
+			}
+
+			assert.Equal(expected, parser.Analyze(context.Background(), "mac:112233445566"))
+			assert.Zero(recorded)
+		})
+	}
+}
+
+func TestLatestFullyManageableEvent(t *testing.T) {
+	events := []interpreter.Event{
+		{Destination: "event:device-status/mac:112233445566/fully-manageable", TransactionUUID: "1", Metadata: map[string]string{interpreter.BootTimeKey: "100"}, Birthdate: 300},
+		{Destination: "event:device-status/mac:112233445566/fully-manageable", TransactionUUID: "2", Metadata: map[string]string{interpreter.BootTimeKey: "200"}, Birthdate: 100},
+		{Destination: "event:device-status/mac:112233445566/fully-manageable", TransactionUUID: "3", Metadata: map[string]string{interpreter.BootTimeKey: "200"}, Birthdate: 200},
+		{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "4", Metadata: map[string]string{interpreter.BootTimeKey: "300"}, Birthdate: 300},
+	}
+
+	event, found := latestFullyManageableEvent(events)
+	assert.True(t, found)
+	assert.Equal(t, "3", event.TransactionUUID)
+
+	_, found = latestFullyManageableEvent(events[3:])
+	assert.False(t, found)
+}
@@ -0,0 +1,421 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/bascule/basculechecks"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+const (
+	onlineEventType  = "online"
+	offlineEventType = "offline"
+
+	partnerLabelType = "partner"
+
+	defaultAvailabilityWindow         = 24 * time.Hour
+	defaultAvailabilityUpdateInterval = time.Minute
+	defaultMaxTrackedDevices          = 10000
+	maxTransitionsPerDevice           = 200
+	otherFirmwareLabelValue           = "other"
+)
+
+var (
+	errNilGauge                  = errors.New("gauge cannot be nil")
+	errMissingFirmwareBucketName = errors.New("firmware bucket missing name")
+	errInvalidFirmwareBucket     = errors.New("invalid firmware bucket")
+)
+
+// AvailabilityConfig configures the availability parser.
+type AvailabilityConfig struct {
+	// Enabled turns on the availability parser.
+	Enabled bool
+
+	// Window is the length of the rolling window availability is calculated over.  Defaults to 24h.
+	Window time.Duration
+
+	// UpdateInterval is the minimum time between updates of the availability gauge.  Defaults to 1m.
+	UpdateInterval time.Duration
+
+	// MaxTrackedDevices is the maximum number of devices tracked at a time.  Events from new devices are
+	// ignored once this is reached, until devices not seen within the window are evicted when the gauge is
+	// updated.  Defaults to 10000.
+	MaxTrackedDevices int
+
+	// LabelBy determines the label the availability gauge is grouped by.  Options are "firmware" or
+	// "partner".  Defaults to "firmware".
+	LabelBy string
+
+	// FirmwareBuckets group firmware into the label values of the availability gauge, guarding against
+	// unbounded label cardinality.  Firmware is labeled by the first bucket it matches, and firmware matching
+	// none of them is labeled as "other".
+	FirmwareBuckets []FirmwareBucketConfig
+
+	// MetricPrefix is prepended, followed by an underscore, to the name of the availability gauge.
+	MetricPrefix string
+}
+
+// FirmwareBucketConfig groups the firmware matching a pattern under a single label value.
+type FirmwareBucketConfig struct {
+	// Name is the label value of the firmware in the bucket.
+	Name string
+
+	// Pattern is the regular expression matching the firmware in the bucket.
+	Pattern string
+}
+
+// firmwareBucket is a compiled FirmwareBucketConfig.
+type firmwareBucket struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// newFirmwareBuckets compiles the firmware buckets, returning an error if a bucket is invalid.
+func newFirmwareBuckets(configs []FirmwareBucketConfig) ([]firmwareBucket, error) {
+	buckets := make([]firmwareBucket, 0, len(configs))
+	for _, config := range configs {
+		if len(config.Name) == 0 {
+			return nil, errMissingFirmwareBucketName
+		}
+
+		pattern, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", errInvalidFirmwareBucket, config.Name, err)
+		}
+		buckets = append(buckets, firmwareBucket{name: config.Name, pattern: pattern})
+	}
+
+	return buckets, nil
+}
+
+// transition is a device going online or offline.
+type transition struct {
+	at     time.Time
+	online bool
+}
+
+type deviceAvailability struct {
+	label string
+
+	// transitions are the times the device went online or offline, ordered by birthdate.
+	transitions []transition
+
+	// observedSince is the birthdate of the first event of the device, before which its state is unknown.
+	observedSince time.Time
+	lastSeen      time.Time
+}
+
+// add inserts the transition in birthdate order, so that late events don't reopen closed intervals.
+func (d *deviceAvailability) add(t transition) {
+	if d.observedSince.IsZero() || t.at.Before(d.observedSince) {
+		d.observedSince = t.at
+	}
+
+	i := sort.Search(len(d.transitions), func(i int) bool {
+		return d.transitions[i].at.After(t.at)
+	})
+	d.transitions = append(d.transitions, transition{})
+	copy(d.transitions[i+1:], d.transitions[i:])
+	d.transitions[i] = t
+
+	// the state before the oldest transition kept is unknown.
+	if len(d.transitions) > maxTransitionsPerDevice {
+		d.transitions = d.transitions[len(d.transitions)-maxTransitionsPerDevice:]
+		d.observedSince = d.transitions[0].at
+	}
+}
+
+// availability returns the fraction of the part of the window ending at now that the device was observed
+// for that it was online, and false if the device wasn't observed during the window.
+func (d *deviceAvailability) availability(now time.Time, window time.Duration) (float64, bool) {
+	windowStart := now.Add(-1 * window)
+	if d.observedSince.After(windowStart) {
+		windowStart = d.observedSince
+	}
+
+	if !now.After(windowStart) {
+		return 0, false
+	}
+
+	var (
+		online      time.Duration
+		onlineSince time.Time
+	)
+	for _, t := range d.transitions {
+		switch {
+		case t.online && onlineSince.IsZero():
+			onlineSince = t.at
+		case !t.online && !onlineSince.IsZero():
+			online += overlap(onlineSince, t.at, windowStart, now)
+			onlineSince = time.Time{}
+		}
+	}
+
+	if !onlineSince.IsZero() {
+		online += overlap(onlineSince, now, windowStart, now)
+	}
+
+	return online.Seconds() / now.Sub(windowStart).Seconds(), true
+}
+
+// prune removes the transitions before the window, keeping the last one before it since it gives the state
+// at the start of the window.
+func (d *deviceAvailability) prune(windowStart time.Time) {
+	i := 0
+	for i+1 < len(d.transitions) && !d.transitions[i+1].at.After(windowStart) {
+		i++
+	}
+	d.transitions = d.transitions[i:]
+}
+
+func overlap(start time.Time, end time.Time, windowStart time.Time, windowEnd time.Time) time.Duration {
+	if start.Before(windowStart) {
+		start = windowStart
+	}
+
+	if end.After(windowEnd) {
+		end = windowEnd
+	}
+
+	if !end.After(start) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
+
+// AvailabilityParser tracks the online and offline events of devices and calculates the fraction of the time each
+// device was observed within a rolling window that it was online, exposing the average availability grouped by
+// bucketed firmware or partner.
+type AvailabilityParser struct {
+	name              string
+	window            time.Duration
+	updateInterval    time.Duration
+	maxTrackedDevices int
+	labelByPartner    bool
+	firmwareBuckets   []firmwareBucket
+	gauge             *prometheus.GaugeVec
+	logger            *zap.Logger
+	current           func() time.Time
+
+	lock       sync.Mutex
+	devices    map[string]*deviceAvailability
+	lastUpdate time.Time
+
+	// labels are the label values the gauge was last set for.
+	labels map[string]bool
+}
+
+// NewAvailabilityParser creates a new AvailabilityParser.
+func NewAvailabilityParser(config AvailabilityConfig, gauge *prometheus.GaugeVec, logger *zap.Logger) (*AvailabilityParser, error) {
+	if gauge == nil {
+		return nil, errNilGauge
+	}
+
+	if config.Window <= 0 {
+		config.Window = defaultAvailabilityWindow
+	}
+
+	if config.UpdateInterval <= 0 {
+		config.UpdateInterval = defaultAvailabilityUpdateInterval
+	}
+
+	if config.MaxTrackedDevices <= 0 {
+		config.MaxTrackedDevices = defaultMaxTrackedDevices
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	buckets, err := newFirmwareBuckets(config.FirmwareBuckets)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AvailabilityParser{
+		name:              "availability",
+		window:            config.Window,
+		updateInterval:    config.UpdateInterval,
+		maxTrackedDevices: config.MaxTrackedDevices,
+		labelByPartner:    config.LabelBy == partnerLabelType,
+		firmwareBuckets:   buckets,
+		gauge:             gauge,
+		logger:            logger,
+		current:           time.Now,
+		devices:           make(map[string]*deviceAvailability),
+	}, nil
+}
+
+// Name implements the Parser interface.
+func (p *AvailabilityParser) Name() string {
+	return p.name
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p *AvailabilityParser) EventTypeRegexes() []string {
+	return []string{"^" + onlineEventType + "$", "^" + offlineEventType + "$"}
+}
+
+// Parse records the online or offline event for the device and updates the availability gauge if needed.
+func (p *AvailabilityParser) Parse(_ context.Context, event interpreter.Event) {
+	eventType, err := event.EventType()
+	if err != nil || (eventType != onlineEventType && eventType != offlineEventType) {
+		return
+	}
+
+	deviceID, err := deviceid.FromEvent(event)
+	if err != nil || event.Birthdate <= 0 {
+		return
+	}
+
+	now := p.current()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	device, found := p.devices[deviceID]
+	if !found {
+		// inactive devices are only evicted when the gauge is updated, rather than searching all of the tracked
+		// devices for every new device.
+		if len(p.devices) >= p.maxTrackedDevices && now.Sub(p.lastUpdate) >= p.updateInterval {
+			p.updateGauge(now)
+		}
+
+		if len(p.devices) >= p.maxTrackedDevices {
+			p.logger.Debug("max tracked devices reached, ignoring device", zap.String("device id", deviceID))
+			return
+		}
+
+		device = &deviceAvailability{}
+		p.devices[deviceID] = device
+	}
+
+	p.record(device, eventType, event, now)
+	if now.Sub(p.lastUpdate) >= p.updateInterval {
+		p.updateGauge(now)
+	}
+}
+
+func (p *AvailabilityParser) record(device *deviceAvailability, eventType string, event interpreter.Event, now time.Time) {
+	device.label = p.label(event)
+	device.lastSeen = now
+	device.add(transition{at: time.Unix(0, event.Birthdate), online: eventType == onlineEventType})
+	device.prune(now.Add(-1 * p.window))
+}
+
+func (p *AvailabilityParser) label(event interpreter.Event) string {
+	if p.labelByPartner {
+		return basculechecks.DeterminePartnerMetric(event.PartnerIDs)
+	}
+
+	_, firmware, _ := getHardwareFirmware(event)
+	for _, bucket := range p.firmwareBuckets {
+		if bucket.pattern.MatchString(firmware) {
+			return bucket.name
+		}
+	}
+
+	return otherFirmwareLabelValue
+}
+
+// updateGauge sets the gauge to the average availability of the tracked devices for each label.  Once the max
+// tracked devices is reached, the devices that have not been seen within the window are evicted.  The gauge is never reset, so that scrapes don't see it empty,
+// and only the labels without devices anymore are deleted.
+func (p *AvailabilityParser) updateGauge(now time.Time) {
+	windowStart := now.Add(-1 * p.window)
+	evict := len(p.devices) >= p.maxTrackedDevices
+	totals := make(map[string]float64)
+	counts := make(map[string]int)
+	for id, device := range p.devices {
+		if evict && device.lastSeen.Before(windowStart) {
+			delete(p.devices, id)
+			continue
+		}
+
+		availability, observed := device.availability(now, p.window)
+		if !observed {
+			continue
+		}
+		totals[device.label] += availability
+		counts[device.label]++
+	}
+
+	labels := make(map[string]bool, len(totals))
+	for label, total := range totals {
+		p.gauge.With(prometheus.Labels{p.labelName(): label}).Set(total / float64(counts[label]))
+		labels[label] = true
+	}
+
+	for label := range p.labels {
+		if !labels[label] {
+			p.gauge.Delete(prometheus.Labels{p.labelName(): label})
+		}
+	}
+	p.labels = labels
+	p.lastUpdate = now
+}
+
+func (p *AvailabilityParser) labelName() string {
+	if p.labelByPartner {
+		return partnerIDLabel
+	}
+	return firmwareLabel
+}
+
+// createAvailabilityParsers creates the availability parser if it is enabled.
+func createAvailabilityParsers(f *touchstone.Factory, config AvailabilityConfig, logger *zap.Logger) ([]queue.Parser, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if f == nil {
+		return nil, errNilFactory
+	}
+
+	label := firmwareLabel
+	if config.LabelBy == partnerLabelType {
+		label = partnerIDLabel
+	}
+
+	gauge, err := f.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricName(config.MetricPrefix, "device_availability"),
+		Help: "the average fraction of the window that devices were online",
+	}, label)
+	if err != nil {
+		return nil, err
+	}
+
+	parser, err := NewAvailabilityParser(config, gauge, logger.With(zap.String("parser", "availability")))
+	if err != nil {
+		return nil, err
+	}
+
+	return []queue.Parser{parser}, nil
+}
@@ -0,0 +1,265 @@
+package parsers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+func newTestAvailabilityGauge(label string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "testAvailability",
+		Help: "testAvailability",
+	}, []string{label})
+}
+
+func availabilityEvent(deviceID string, eventType string, birthdate time.Time, fw string, partner string) interpreter.Event {
+	return interpreter.Event{
+		Destination: "event:device-status/" + deviceID + "/" + eventType,
+		Birthdate:   birthdate.UnixNano(),
+		Metadata:    map[string]string{firmwareMetadataKey: fw},
+		PartnerIDs:  []string{partner},
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	window := 10 * time.Hour
+	tests := []struct {
+		description  string
+		events       []interpreter.Event
+		expected     float64
+		expectedSeen bool
+	}{
+		{
+			description: "Online whole window",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-12*time.Hour), "fw", "partner"),
+			},
+			expected: 1.0,
+		},
+		{
+			description: "Online then offline",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-8*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-4*time.Hour), "fw", "partner"),
+			},
+			expected: 0.5,
+		},
+		{
+			description: "Multiple sessions",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-9*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-8*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-6*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-3*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-1*time.Hour), "fw", "partner"),
+			},
+			expected: 5.0 / 9.0,
+		},
+		{
+			description: "Session crossing window start",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-15*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-8*time.Hour), "fw", "partner"),
+			},
+			expected: 0.2,
+		},
+		{
+			description: "Session before window",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-15*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-11*time.Hour), "fw", "partner"),
+			},
+			expected: 0.0,
+		},
+		{
+			description: "Duplicate online",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-5*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-2*time.Hour), "fw", "partner"),
+			},
+			expected: 1.0,
+		},
+		{
+			description: "Offline without online",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-5*time.Hour), "fw", "partner"),
+			},
+			expected: 0.0,
+		},
+		{
+			description: "Late online event",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-8*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-4*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-6*time.Hour), "fw", "partner"),
+			},
+			expected: 0.5,
+		},
+		{
+			description: "Late offline event",
+			events: []interpreter.Event{
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-4*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", offlineEventType, now.Add(-6*time.Hour), "fw", "partner"),
+				availabilityEvent("mac:112233445566", onlineEventType, now.Add(-8*time.Hour), "fw", "partner"),
+			},
+			expected: 0.75,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			gauge := newTestAvailabilityGauge(firmwareLabel)
+			parser, err := NewAvailabilityParser(AvailabilityConfig{Window: window, FirmwareBuckets: []FirmwareBucketConfig{{Name: "fw", Pattern: "^fw"}}}, gauge, zap.NewNop())
+			assert.Nil(err)
+			parser.current = func() time.Time { return now }
+			for _, event := range tc.events {
+				parser.Parse(context.Background(), event)
+			}
+
+			device := parser.devices["mac:112233445566"]
+			assert.NotNil(device)
+			availability, observed := device.availability(now, window)
+			assert.True(observed)
+			assert.InDelta(tc.expected, availability, 0.0001)
+
+			parser.updateGauge(now)
+			assert.InDelta(tc.expected, testutil.ToFloat64(gauge.WithLabelValues("fw")), 0.0001)
+		})
+	}
+}
+
+func TestAvailabilityGaugeLabels(t *testing.T) {
+	assert := assert.New(t)
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(err)
+	gauge := newTestAvailabilityGauge(partnerIDLabel)
+	parser, err := NewAvailabilityParser(AvailabilityConfig{Window: 10 * time.Hour, LabelBy: "partner"}, gauge, nil)
+	assert.Nil(err)
+	parser.current = func() time.Time { return now }
+
+	parser.Parse(context.Background(), availabilityEvent("mac:112233445566", onlineEventType, now.Add(-10*time.Hour), "fw", "partner1"))
+	parser.Parse(context.Background(), availabilityEvent("mac:aabbccddeeff", onlineEventType, now.Add(-4*time.Hour), "fw", "partner1"))
+	parser.Parse(context.Background(), availabilityEvent("mac:aabbccddeeff", offlineEventType, now.Add(-2*time.Hour), "fw", "partner1"))
+	parser.Parse(context.Background(), availabilityEvent("mac:ffeeddccbbaa", offlineEventType, now.Add(-2*time.Hour), "fw", "partner2"))
+	parser.updateGauge(now)
+
+	assert.InDelta(0.75, testutil.ToFloat64(gauge.WithLabelValues("partner1")), 0.0001)
+	assert.InDelta(0.0, testutil.ToFloat64(gauge.WithLabelValues("partner2")), 0.0001)
+
+	// labels without devices are deleted once the devices are evicted.
+	delete(parser.devices, "mac:ffeeddccbbaa")
+	parser.updateGauge(now)
+	assert.Equal(1, testutil.CollectAndCount(gauge))
+}
+
+func TestAvailabilityFirmwareBuckets(t *testing.T) {
+	assert := assert.New(t)
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(err)
+	gauge := newTestAvailabilityGauge(firmwareLabel)
+	parser, err := NewAvailabilityParser(AvailabilityConfig{
+		Window: 10 * time.Hour,
+		FirmwareBuckets: []FirmwareBucketConfig{
+			{Name: "tg1682", Pattern: "^TG1682_"},
+			{Name: "tg3482", Pattern: "^TG3482"},
+		},
+	}, gauge, nil)
+	assert.Nil(err)
+	parser.current = func() time.Time { return now }
+
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000001", onlineEventType, now.Add(-1*time.Hour), "TG1682_3.8p1s1_PROD_sey", "partner"))
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000002", onlineEventType, now.Add(-1*time.Hour), "TG1682_3.9p2s1_PROD_sey", "partner"))
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000003", onlineEventType, now.Add(-1*time.Hour), "TG3482PC2_4.2s12_PROD_sey", "partner"))
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000004", onlineEventType, now.Add(-1*time.Hour), "CGM4140COM_4.4p1s6_PROD_sey", "partner"))
+	parser.updateGauge(now)
+
+	assert.Equal(3, testutil.CollectAndCount(gauge))
+	for _, label := range []string{"tg1682", "tg3482", otherFirmwareLabelValue} {
+		assert.InDelta(1.0, testutil.ToFloat64(gauge.WithLabelValues(label)), 0.0001)
+	}
+
+	_, err = NewAvailabilityParser(AvailabilityConfig{FirmwareBuckets: []FirmwareBucketConfig{{Pattern: "^TG"}}}, gauge, nil)
+	assert.ErrorIs(err, errMissingFirmwareBucketName)
+	_, err = NewAvailabilityParser(AvailabilityConfig{FirmwareBuckets: []FirmwareBucketConfig{{Name: "tg", Pattern: "[TG"}}}, gauge, nil)
+	assert.ErrorIs(err, errInvalidFirmwareBucket)
+}
+
+func TestAvailabilityMaxTrackedDevices(t *testing.T) {
+	assert := assert.New(t)
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(err)
+	parser, err := NewAvailabilityParser(AvailabilityConfig{Window: time.Hour, UpdateInterval: 2 * time.Hour, MaxTrackedDevices: 2}, newTestAvailabilityGauge(firmwareLabel), nil)
+	assert.Nil(err)
+
+	parser.current = func() time.Time { return now.Add(-2 * time.Hour) }
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000001", onlineEventType, now.Add(-2*time.Hour), "fw", "partner"))
+	parser.current = func() time.Time { return now.Add(-30 * time.Minute) }
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000002", onlineEventType, now.Add(-30*time.Minute), "fw", "partner"))
+
+	// inactive devices aren't evicted until the gauge is updated
+	parser.current = func() time.Time { return now.Add(-20 * time.Minute) }
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000003", onlineEventType, now.Add(-20*time.Minute), "fw", "partner"))
+	assert.Len(parser.devices, 2)
+	assert.NotContains(parser.devices, "mac:000000000003")
+
+	// the inactive device is evicted to make room once the gauge is updated
+	parser.current = func() time.Time { return now }
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000003", onlineEventType, now, "fw", "partner"))
+	assert.Len(parser.devices, 2)
+	assert.NotContains(parser.devices, "mac:000000000001")
+	assert.Contains(parser.devices, "mac:000000000003")
+
+	// no inactive devices, so the new device is ignored
+	parser.Parse(context.Background(), availabilityEvent("mac:000000000004", onlineEventType, now, "fw", "partner"))
+	assert.Len(parser.devices, 2)
+	assert.NotContains(parser.devices, "mac:000000000004")
+}
+
+func TestAvailabilityIgnoredEvents(t *testing.T) {
+	parser, err := NewAvailabilityParser(AvailabilityConfig{}, newTestAvailabilityGauge(firmwareLabel), nil)
+	assert.Nil(t, err)
+	parser.Parse(context.Background(), availabilityEvent("mac:112233445566", "fully-manageable", time.Now(), "fw", "partner"))
+	parser.Parse(context.Background(), interpreter.Event{Destination: "event:device-status/online", Birthdate: time.Now().UnixNano()})
+	parser.Parse(context.Background(), availabilityEvent("mac:112233445566", onlineEventType, time.Time{}, "fw", "partner"))
+	assert.Empty(t, parser.devices)
+	assert.Equal(t, "availability", parser.Name())
+	assert.Equal(t, []string{"^online$", "^offline$"}, parser.EventTypeRegexes())
+}
+
+func TestNewAvailabilityParser(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewAvailabilityParser(AvailabilityConfig{}, nil, nil)
+	assert.ErrorIs(err, errNilGauge)
+
+	parser, err := NewAvailabilityParser(AvailabilityConfig{}, newTestAvailabilityGauge(firmwareLabel), nil)
+	assert.Nil(err)
+	assert.Equal(defaultAvailabilityWindow, parser.window)
+	assert.Equal(defaultAvailabilityUpdateInterval, parser.updateInterval)
+	assert.Equal(defaultMaxTrackedDevices, parser.maxTrackedDevices)
+}
+
+func TestCreateAvailabilityParsers(t *testing.T) {
+	assert := assert.New(t)
+	parsers, err := createAvailabilityParsers(nil, AvailabilityConfig{}, zap.NewNop())
+	assert.Nil(err)
+	assert.Empty(parsers)
+
+	_, err = createAvailabilityParsers(nil, AvailabilityConfig{Enabled: true}, zap.NewNop())
+	assert.ErrorIs(err, errNilFactory)
+
+	f := touchstone.NewFactory(touchstone.Config{}, zap.NewNop(), prometheus.NewPedanticRegistry())
+	parsers, err = createAvailabilityParsers(f, AvailabilityConfig{Enabled: true}, zap.NewNop())
+	assert.Nil(err)
+	assert.Len(parsers, 1)
+}
@@ -0,0 +1,102 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+)
+
+// batchEventClient wraps an EventClient for the length of a batch of events, only getting the history
+// of events for each device once so that events in the batch from the same device share the lookup.
+// Parsers get the histories of all of the devices in the batch up front with GetEventsBatch, so that the
+// lookups happen concurrently.  It is not safe for concurrent use.
+type batchEventClient struct {
+	client EventClient
+	events map[string][]interpreter.Event
+}
+
+func newBatchEventClient(client EventClient) *batchEventClient {
+	return &batchEventClient{
+		client: client,
+		events: make(map[string][]interpreter.Event),
+	}
+}
+
+// GetEvents implements the EventClient interface.
+func (b *batchEventClient) GetEvents(ctx context.Context, deviceID string) []interpreter.Event {
+	if events, found := b.events[deviceID]; found {
+		return events
+	}
+
+	events := b.client.GetEvents(ctx, deviceID)
+	b.events[deviceID] = events
+	return events
+}
+
+// GetEventsBatch implements the EventClient interface, only getting the events of the devices that haven't
+// been looked up during the batch.
+func (b *batchEventClient) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	results := make(map[string][]interpreter.Event, len(deviceIDs))
+	var missing []string
+	for _, deviceID := range deviceIDs {
+		if events, found := b.events[deviceID]; found {
+			results[deviceID] = events
+		} else {
+			missing = append(missing, deviceID)
+		}
+	}
+
+	if len(missing) == 0 {
+		return results
+	}
+
+	for deviceID, events := range b.client.GetEventsBatch(ctx, missing) {
+		b.events[deviceID] = events
+		results[deviceID] = events
+	}
+
+	return results
+}
+
+// batchDeviceIDs returns the device ids of the events in the batch with one of the event types given.
+func batchDeviceIDs(events []interpreter.Event, eventTypes ...string) []string {
+	var deviceIDs []string
+	for _, event := range events {
+		if t, err := event.EventType(); err != nil || !containsEventType(eventTypes, t) {
+			continue
+		}
+
+		if deviceID, err := deviceid.FromEvent(event); err == nil {
+			deviceIDs = append(deviceIDs, deviceID)
+		}
+	}
+
+	return deviceIDs
+}
+
+func containsEventType(eventTypes []string, eventType string) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package parsers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestBatchEventClient(t *testing.T) {
+	assert := assert.New(t)
+	device1Events := []interpreter.Event{{TransactionUUID: "1"}, {TransactionUUID: "2"}}
+	device2Events := []interpreter.Event{{TransactionUUID: "3"}}
+	client := new(mockEventClient)
+	client.On("GetEvents", "device-1").Return(device1Events).Once()
+	client.On("GetEvents", "device-2").Return(device2Events).Once()
+	client.On("GetEvents", "device-3").Return([]interpreter.Event{}).Once()
+
+	batchClient := newBatchEventClient(client)
+	for i := 0; i < 3; i++ {
+		assert.Equal(device1Events, batchClient.GetEvents(context.Background(), "device-1"))
+		assert.Equal(device2Events, batchClient.GetEvents(context.Background(), "device-2"))
+		assert.Empty(batchClient.GetEvents(context.Background(), "device-3"))
+	}
+
+	client.AssertExpectations(t)
+	client.AssertNumberOfCalls(t, "GetEvents", 3)
+
+	// a new batch gets the events again.
+	client.On("GetEvents", "device-1").Return(device1Events).Once()
+	assert.Equal(device1Events, newBatchEventClient(client).GetEvents(context.Background(), "device-1"))
+	client.AssertNumberOfCalls(t, "GetEvents", 4)
+}
+
+func TestBatchEventClientGetEventsBatch(t *testing.T) {
+	assert := assert.New(t)
+	device1Events := []interpreter.Event{{TransactionUUID: "1"}}
+	device2Events := []interpreter.Event{{TransactionUUID: "2"}}
+	client := new(mockEventClient)
+	client.On("GetEvents", "device-1").Return(device1Events).Once()
+	client.On("GetEvents", "device-2").Return(device2Events).Once()
+
+	batchClient := newBatchEventClient(client)
+	assert.Equal(device1Events, batchClient.GetEvents(context.Background(), "device-1"))
+
+	// only the devices not yet looked up during the batch are fetched.
+	results := batchClient.GetEventsBatch(context.Background(), []string{"device-1", "device-2"})
+	assert.Equal(map[string][]interpreter.Event{"device-1": device1Events, "device-2": device2Events}, results)
+	assert.Equal(device2Events, batchClient.GetEvents(context.Background(), "device-2"))
+	assert.Equal(results, batchClient.GetEventsBatch(context.Background(), []string{"device-1", "device-2"}))
+	client.AssertExpectations(t)
+	client.AssertNumberOfCalls(t, "GetEvents", 2)
+}
+
+func TestBatchDeviceIDs(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{
+		{Destination: "event:device-status/mac:112233445566/fully-manageable/1614265173"},
+		{Destination: "event:device-status/mac:112233445566/online"},
+		{Destination: "event:device-status/mac:aabbccddeeff/fully-manageable/1614265173"},
+		{Destination: "invalid"},
+	}
+
+	assert.Equal([]string{"mac:112233445566", "mac:aabbccddeeff"}, batchDeviceIDs(events, fullyManageableEventType))
+	assert.Equal([]string{"mac:112233445566"}, batchDeviceIDs(events, "online"))
+	assert.Empty(batchDeviceIDs(events, "offline"))
+	assert.Equal([]string{"mac:112233445566", "mac:112233445566", "mac:aabbccddeeff"}, batchDeviceIDs(events, fullyManageableEventType, "online"))
+}
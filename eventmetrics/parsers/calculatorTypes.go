@@ -0,0 +1,101 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
+)
+
+const (
+	eventToCurrentCalculatorType = "event-to-current"
+	bootToEventCalculatorType    = "boot-to-event"
+	betweenEventsCalculatorType  = "between-events"
+
+	endFinderSuffix = "_end"
+)
+
+var (
+	errUnknownCalculatorType = errors.New("unknown calculator type")
+	errMissingEndEventType   = errors.New("end event type cannot be blank")
+)
+
+// finderFunc creates a finder of the events with the event type in the session type given, named after the
+// calculation that uses it.
+type finderFunc func(eventType string, sessionType string, name string) Finder
+
+// calculatorFactory configures the calculator of a time elapsed calculation of the calculator type it is registered
+// under.  The calculator's finder is already set to the finder of the calculation's event type.
+type calculatorFactory func(calculator *EventToCurrentCalculator, config TimeElapsedConfig, newFinder finderFunc) error
+
+// calculatorFactories are the calculator types that time elapsed calculations can be configured with.
+var calculatorFactories = map[string]calculatorFactory{
+	eventToCurrentCalculatorType: eventToCurrentCalculator,
+	bootToEventCalculatorType:    bootToEventCalculator,
+	betweenEventsCalculatorType:  betweenEventsCalculator,
+}
+
+// calculatorType returns the calculator type of the config, defaulting to event-to-current.
+func calculatorType(config TimeElapsedConfig) string {
+	if len(config.CalculatorType) == 0 {
+		return eventToCurrentCalculatorType
+	}
+
+	return strings.ToLower(config.CalculatorType)
+}
+
+// configureCalculator configures the calculator using the factory of the config's calculator type, returning an
+// error if the calculator type is unknown.
+func configureCalculator(calculator *EventToCurrentCalculator, config TimeElapsedConfig, newFinder finderFunc) error {
+	factory, found := calculatorFactories[calculatorType(config)]
+	if !found {
+		return fmt.Errorf("%w: %s", errUnknownCalculatorType, config.CalculatorType)
+	}
+
+	return factory(calculator, config, newFinder)
+}
+
+// eventToCurrentCalculator calculates the time between the found event and the current event.
+func eventToCurrentCalculator(calculator *EventToCurrentCalculator, config TimeElapsedConfig, _ finderFunc) error {
+	calculator.startSource = enums.ParseTimeSource(config.StartTimeSource)
+	calculator.endSource = enums.ParseTimeSource(config.EndTimeSource)
+	return nil
+}
+
+// bootToEventCalculator calculates the time between the boot-time and the birthdate of the found event.
+func bootToEventCalculator(calculator *EventToCurrentCalculator, _ TimeElapsedConfig, _ finderFunc) error {
+	calculator.endFinder = calculator.eventFinder
+	calculator.startSource = enums.BootTimeSource
+	calculator.endSource = enums.BirthdateSource
+	return nil
+}
+
+// betweenEventsCalculator calculates the time between the found event and the event with the end event type.
+func betweenEventsCalculator(calculator *EventToCurrentCalculator, config TimeElapsedConfig, newFinder finderFunc) error {
+	if len(config.EndEventType) == 0 {
+		return fmt.Errorf("%w: %s", errMissingEndEventType, config.Name)
+	}
+
+	calculator.endFinder = newFinder(config.EndEventType, config.EndSessionType, config.Name+endFinderSuffix)
+	calculator.startSource = enums.ParseTimeSource(config.StartTimeSource)
+	calculator.endSource = enums.ParseTimeSource(config.EndTimeSource)
+	return nil
+}
@@ -0,0 +1,158 @@
+package parsers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+func TestConfigureCalculator(t *testing.T) {
+	startFinder := history.CurrentSessionFinder(validation.DestinationValidator("online"))
+	endFinder := history.CurrentSessionFinder(validation.DestinationValidator("offline"))
+	tests := []struct {
+		description         string
+		config              TimeElapsedConfig
+		expectedEndFinder   Finder
+		expectedStartSource enums.TimeSource
+		expectedEndSource   enums.TimeSource
+		expectedFinderArgs  []string
+		expectedErr         error
+	}{
+		{
+			description:         "Default",
+			config:              TimeElapsedConfig{Name: "test", StartTimeSource: "boot-time"},
+			expectedStartSource: enums.BootTimeSource,
+			expectedEndSource:   enums.BirthdateSource,
+		},
+		{
+			description:         "Event to current",
+			config:              TimeElapsedConfig{Name: "test", CalculatorType: "Event-To-Current", EndTimeSource: "boot-time"},
+			expectedStartSource: enums.BirthdateSource,
+			expectedEndSource:   enums.BootTimeSource,
+		},
+		{
+			description:         "Boot to event",
+			config:              TimeElapsedConfig{Name: "test", CalculatorType: "boot-to-event", StartTimeSource: "birthdate"},
+			expectedEndFinder:   startFinder,
+			expectedStartSource: enums.BootTimeSource,
+			expectedEndSource:   enums.BirthdateSource,
+		},
+		{
+			description:         "Between events",
+			config:              TimeElapsedConfig{Name: "test", CalculatorType: "between-events", EndEventType: "offline", EndSessionType: "previous"},
+			expectedEndFinder:   endFinder,
+			expectedStartSource: enums.BirthdateSource,
+			expectedEndSource:   enums.BirthdateSource,
+			expectedFinderArgs:  []string{"offline", "previous", "test_end"},
+		},
+		{
+			description: "Between events missing end event type",
+			config:      TimeElapsedConfig{Name: "test", CalculatorType: "between-events"},
+			expectedErr: errMissingEndEventType,
+		},
+		{
+			description: "Unknown type",
+			config:      TimeElapsedConfig{Name: "test", CalculatorType: "unknown"},
+			expectedErr: errUnknownCalculatorType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var finderArgs []string
+			newFinder := func(eventType string, sessionType string, name string) Finder {
+				finderArgs = []string{eventType, sessionType, name}
+				return endFinder
+			}
+
+			calculator := &EventToCurrentCalculator{eventFinder: startFinder}
+			err := configureCalculator(calculator, tc.config, newFinder)
+			assert.ErrorIs(err, tc.expectedErr)
+			if tc.expectedErr != nil {
+				return
+			}
+
+			assert.Equal(fmt.Sprint(tc.expectedEndFinder), fmt.Sprint(calculator.endFinder))
+			assert.Equal(tc.expectedStartSource, calculator.startSource)
+			assert.Equal(tc.expectedEndSource, calculator.endSource)
+			assert.Equal(tc.expectedFinderArgs, finderArgs)
+		})
+	}
+}
+
+func TestCalculatorTypes(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	previousBoot := now.Add(-1 * time.Hour)
+	currentBoot := now.Add(-10 * time.Minute)
+	events := []interpreter.Event{
+		sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+		sessionEvent(rebootPendingEventType, previousBoot, now.Add(-15*time.Minute), "2"),
+		sessionEvent(offlineEventType, previousBoot, now.Add(-14*time.Minute), "3"),
+		sessionEvent(onlineEventType, currentBoot, now.Add(-8*time.Minute), "4"),
+	}
+	currentEvent := sessionEvent(fullyManageableEventType, currentBoot, now, "current")
+
+	tests := []struct {
+		description      string
+		config           TimeElapsedConfig
+		expectedDuration float64
+		expectedErr      error
+	}{
+		{
+			description:      "Event to current",
+			config:           TimeElapsedConfig{Name: "test", EventType: "online", SessionType: "current"},
+			expectedDuration: (8 * time.Minute).Seconds(),
+		},
+		{
+			description:      "Boot to event",
+			config:           TimeElapsedConfig{Name: "test", EventType: "online", SessionType: "current", CalculatorType: "boot-to-event"},
+			expectedDuration: (2 * time.Minute).Seconds(),
+		},
+		{
+			description: "Between events",
+			config: TimeElapsedConfig{Name: "test", EventType: "reboot-pending", SessionType: "previous",
+				CalculatorType: "between-events", EndEventType: "online", EndSessionType: "current"},
+			expectedDuration: (7 * time.Minute).Seconds(),
+		},
+		{
+			description: "Between events end not found",
+			config: TimeElapsedConfig{Name: "test", EventType: "reboot-pending", SessionType: "previous",
+				CalculatorType: "between-events", EndEventType: "operational", EndSessionType: "current"},
+			expectedErr: errEventNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			newFinder := func(eventType string, sessionType string, _ string) Finder {
+				if enums.ParseSessionType(sessionType) == enums.Previous {
+					return history.LastSessionFinder(validation.DestinationValidator(eventType))
+				}
+				return history.CurrentSessionFinder(validation.DestinationValidator(eventType))
+			}
+
+			var duration float64
+			calculator, err := NewEventToCurrentCalculator(newFinder(tc.config.EventType, tc.config.SessionType, tc.config.Name),
+				func(_ interpreter.Event, _ interpreter.Event, d float64) { duration = d }, nil)
+			if !assert.Nil(err) {
+				return
+			}
+			assert.Nil(configureCalculator(calculator, tc.config, newFinder))
+
+			assert.Equal(tc.expectedErr, calculator.Calculate(events, currentEvent))
+			assert.Equal(tc.expectedDuration, duration)
+
+			analysis := calculator.analyzeDuration(events, currentEvent)
+			assert.Equal(tc.expectedDuration, analysis.Seconds)
+		})
+	}
+}
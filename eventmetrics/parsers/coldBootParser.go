@@ -0,0 +1,193 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+const (
+	coldBootParserName = "cold_boot_duration_parser"
+)
+
+// ColdBootConfig configures the cold boot parser.
+type ColdBootConfig struct {
+	// Enabled turns on the cold boot parser.
+	Enabled bool
+
+	// MetricPrefix is prepended, followed by an underscore, to the name of the cold boot histogram.
+	MetricPrefix string
+
+	// NativeHistograms configures exposing the cold boot histogram as a native histogram.
+	NativeHistograms NativeHistogramConfig
+}
+
+// ColdBootParser is triggered by fully-manageable events and records the time between the boot-time and the
+// fully-manageable event of cold boots, which are sessions that weren't preceded by a reboot-pending event, such as
+// when a device is powered on.  Reboots, which are preceded by a reboot-pending event, are left to the reboot
+// duration parser.
+type ColdBootParser struct {
+	name                  string
+	rebootPendingFinder   Finder
+	fullyManageableFinder Finder
+	client                EventClient
+	histogram             prometheus.ObserverVec
+	measures              Measures
+	logger                *zap.Logger
+}
+
+// NewColdBootParser creates a new ColdBootParser.
+func NewColdBootParser(client EventClient, histogram prometheus.ObserverVec, measures Measures, logger *zap.Logger) (*ColdBootParser, error) {
+	if client == nil {
+		return nil, errNilEventClient
+	}
+
+	if histogram == nil {
+		return nil, errNilHistogram
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &ColdBootParser{
+		name:                  coldBootParserName,
+		rebootPendingFinder:   measures.timeFinder(history.LastSessionFinder(validation.DestinationValidator(rebootPendingEventType)), coldBootParserName, rebootPendingFinderName, logger),
+		fullyManageableFinder: measures.timeFinder(history.CurrentSessionFinder(validation.DestinationValidator(fullyManageableEventType)), coldBootParserName, fullyManageableFinderName, logger),
+		client:                client,
+		histogram:             histogram,
+		measures:              measures,
+		logger:                logger,
+	}, nil
+}
+
+// Name implements the Parser interface.
+func (p *ColdBootParser) Name() string {
+	return p.name
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p *ColdBootParser) EventTypeRegexes() []string {
+	return []string{"^" + fullyManageableEventType + "$"}
+}
+
+// Parse records the time between the boot-time and birthdate of the fully-manageable event if the device cold booted.
+func (p *ColdBootParser) Parse(ctx context.Context, currentEvent interpreter.Event) {
+	p.parse(ctx, currentEvent, p.client)
+}
+
+// ParseBatch implements the queue.BatchParser interface, parsing each event in the batch while only getting
+// the history of events from codex once for each device in the batch.
+func (p *ColdBootParser) ParseBatch(ctx context.Context, events []interpreter.Event) {
+	client := newBatchEventClient(p.client)
+	client.GetEventsBatch(ctx, batchDeviceIDs(events, fullyManageableEventType))
+	for _, event := range events {
+		p.parse(ctx, event, client)
+	}
+}
+
+func (p *ColdBootParser) parse(ctx context.Context, currentEvent interpreter.Event, client EventClient) {
+	eventType, err := currentEvent.EventType()
+	if err != nil || eventType != fullyManageableEventType {
+		return
+	}
+
+	deviceID, err := deviceid.FromEvent(currentEvent)
+	if err != nil {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	bootTime, err := currentEvent.BootTime()
+	if err != nil || bootTime <= 0 {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	events := client.GetEvents(ctx, deviceID)
+
+	// a reboot-pending event in the previous session means the device rebooted rather than cold booted.
+	if _, err := p.rebootPendingFinder.Find(events, currentEvent); err == nil {
+		return
+	}
+
+	// only the first fully-manageable event of the session is recorded.
+	if previous, err := p.fullyManageableFinder.Find(events, currentEvent); err == nil && previous.Birthdate < currentEvent.Birthdate {
+		p.logger.Debug("not the first fully-manageable event of the session", zap.String("device id", deviceID), zap.String("first event", previous.TransactionUUID))
+		return
+	}
+
+	duration := time.Unix(0, currentEvent.Birthdate).Sub(time.Unix(bootTime, 0)).Seconds()
+	if currentEvent.Birthdate <= 0 || duration <= 0 {
+		p.logger.Error("invalid cold boot duration calculated", zap.String("device id", deviceID), zap.Float64("duration", duration))
+		p.addToUnparsableCounters(currentEvent, errCalculation)
+		return
+	}
+
+	p.measures.addDuration(p.histogram, duration, currentEvent)
+}
+
+func (p *ColdBootParser) addToUnparsableCounters(event interpreter.Event, err error, compared ...interpreter.Event) {
+	p.measures.AddTotalUnparsable(p.name)
+	p.measures.AddUnparsableEventType(p.name, err, event)
+	p.measures.AddUnparsableRatio(event)
+	p.measures.LogUnparsable(p.name, err, event, compared)
+}
+
+// createColdBootParsers creates the cold boot parser if it is enabled.
+func createColdBootParsers(f *touchstone.Factory, config ColdBootConfig, client events.EventSource, measures Measures, logger *zap.Logger) ([]queue.Parser, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if f == nil {
+		return nil, errNilFactory
+	}
+
+	opts := config.NativeHistograms.apply(prometheus.HistogramOpts{
+		Name:        metricName(config.MetricPrefix, "cold_boot_to_manageable"),
+		Help:        "time elapsed between a device cold booting, without a preceding reboot-pending event, and the fully-manageable event in s",
+		Buckets:     []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 900, 1200, 1500, 1800, 3600, 7200, 14400, 21600},
+		ConstLabels: measures.ConfigVariantLabels,
+	})
+	histogram, err := f.NewHistogramVec(opts, firmwareLabel, hardwareLabel, rebootReasonLabel)
+	if err != nil {
+		return nil, err
+	}
+	measures.recordBuckets(opts)
+
+	parser, err := NewColdBootParser(parserEventClient(client, coldBootParserName, sessionHistory), histogram, measures, logger.With(zap.String("parser", coldBootParserName)))
+	if err != nil {
+		return nil, err
+	}
+
+	return []queue.Parser{parser}, nil
+}
@@ -0,0 +1,147 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestColdBootParse(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	previousBoot := now.Add(-48 * time.Hour)
+	currentBoot := now.Add(-5 * time.Minute)
+	currentEvent := sessionEvent(fullyManageableEventType, currentBoot, now, "current")
+	currentSession := []interpreter.Event{
+		sessionEvent(onlineEventType, currentBoot, currentBoot.Add(time.Minute), "online"),
+		currentEvent,
+	}
+
+	tests := []struct {
+		description        string
+		event              interpreter.Event
+		history            []interpreter.Event
+		expectedDuration   float64
+		expectedCount      uint64
+		expectedUnparsable float64
+	}{
+		{
+			description: "Cold boot",
+			event:       currentEvent,
+			history: append([]interpreter.Event{
+				sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+				sessionEvent(offlineEventType, previousBoot, currentBoot.Add(-1*time.Minute), "2"),
+			}, currentSession...),
+			expectedDuration: now.Sub(currentBoot).Seconds(),
+			expectedCount:    1,
+		},
+		{
+			description:      "First session",
+			event:            currentEvent,
+			history:          currentSession,
+			expectedDuration: now.Sub(currentBoot).Seconds(),
+			expectedCount:    1,
+		},
+		{
+			description: "Reboot",
+			event:       currentEvent,
+			history: append([]interpreter.Event{
+				sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+				sessionEvent(rebootPendingEventType, previousBoot, currentBoot.Add(-1*time.Minute), "2"),
+			}, currentSession...),
+		},
+		{
+			description: "Later fully-manageable event",
+			event:       currentEvent,
+			history: append([]interpreter.Event{
+				sessionEvent(fullyManageableEventType, currentBoot, currentBoot.Add(2*time.Minute), "first"),
+			}, currentSession...),
+		},
+		{
+			description:        "Birthdate before boot-time",
+			event:              sessionEvent(fullyManageableEventType, currentBoot, currentBoot.Add(-1*time.Minute), "current"),
+			history:            currentSession,
+			expectedUnparsable: 1.0,
+		},
+		{
+			description:        "Missing boot-time",
+			event:              interpreter.Event{Destination: fmt.Sprintf("event:device-status/%s/fully-manageable", testUptimeDeviceID)},
+			expectedUnparsable: 1.0,
+		},
+		{
+			description: "Wrong event type",
+			event:       sessionEvent(onlineEventType, currentBoot, now, "current"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", testUptimeDeviceID).Return(tc.history)
+			histogram := newTestUptimeHistogram()
+			unparsable := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testUnparsable",
+				Help: "testUnparsable",
+			}, []string{parserLabel})
+
+			parser, err := NewColdBootParser(client, histogram, Measures{TotalUnparsableCount: unparsable}, zap.NewNop())
+			assert.Nil(err)
+			parser.Parse(context.Background(), tc.event)
+
+			assert.Equal(tc.expectedUnparsable, testutil.ToFloat64(unparsable.WithLabelValues(coldBootParserName)))
+			metric := &dto.Metric{}
+			observer := histogram.With(prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason"})
+			assert.Nil(observer.(prometheus.Metric).Write(metric))
+			assert.Equal(tc.expectedCount, metric.GetHistogram().GetSampleCount())
+			assert.Equal(tc.expectedDuration, metric.GetHistogram().GetSampleSum())
+		})
+	}
+}
+
+func TestNewColdBootParser(t *testing.T) {
+	assert := assert.New(t)
+	histogram := newTestUptimeHistogram()
+
+	parser, err := NewColdBootParser(nil, histogram, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilEventClient, err)
+
+	parser, err = NewColdBootParser(new(mockEventClient), nil, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilHistogram, err)
+
+	parser, err = NewColdBootParser(new(mockEventClient), histogram, Measures{}, nil)
+	assert.Nil(err)
+	assert.Equal(coldBootParserName, parser.Name())
+	assert.Equal([]string{"^fully-manageable$"}, parser.EventTypeRegexes())
+}
+
+func TestCreateColdBootParsers(t *testing.T) {
+	assert := assert.New(t)
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+
+	parsers, err := createColdBootParsers(testFactory, ColdBootConfig{}, nil, Measures{}, zap.NewNop())
+	assert.Nil(err)
+	assert.Empty(parsers)
+
+	parsers, err = createColdBootParsers(nil, ColdBootConfig{Enabled: true}, nil, Measures{}, zap.NewNop())
+	assert.Equal(errNilFactory, err)
+	assert.Empty(parsers)
+
+	buckets := make(map[string]string)
+	parsers, err = createColdBootParsers(testFactory, ColdBootConfig{Enabled: true, MetricPrefix: "test"}, nil, Measures{HistogramBuckets: buckets}, zap.NewNop())
+	assert.Equal(errNilEventClient, err)
+	assert.Empty(parsers)
+	assert.Contains(buckets, "test_cold_boot_to_manageable")
+}
@@ -0,0 +1,230 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+const (
+	crashLoopParserName = "crash_loop_parser"
+
+	defaultCrashLoopWindow    = time.Hour
+	defaultCrashLoopThreshold = 5
+)
+
+var (
+	errNilCounter = errors.New("counter cannot be nil")
+)
+
+// CrashLoopConfig configures the crash loop parser.
+type CrashLoopConfig struct {
+	// Enabled turns on the crash loop parser.
+	Enabled bool
+
+	// Window is the length of time, ending at the boot-time of the new session, that a device's reboots
+	// are counted over.  Defaults to 1h.
+	Window time.Duration
+
+	// Threshold is the number of boots within the window, including the new session, at which a device
+	// is considered to be crash looping.  Defaults to 5.
+	Threshold int
+
+	// MetricPrefix is prepended, followed by an underscore, to the name of the crash loop counter.
+	MetricPrefix string
+}
+
+// CrashLoopParser is triggered by the first online event of a session and counts the distinct boot-times
+// in the device's history of events within the window.  Devices that booted at least the threshold number of
+// times within the window are counted as crash looping and logged.
+type CrashLoopParser struct {
+	name         string
+	window       time.Duration
+	threshold    int
+	onlineFinder Finder
+	client       EventClient
+	counter      *prometheus.CounterVec
+	measures     Measures
+	logger       *zap.Logger
+}
+
+// NewCrashLoopParser creates a new CrashLoopParser.
+func NewCrashLoopParser(config CrashLoopConfig, client EventClient, counter *prometheus.CounterVec, measures Measures, logger *zap.Logger) (*CrashLoopParser, error) {
+	if client == nil {
+		return nil, errNilEventClient
+	}
+
+	if counter == nil {
+		return nil, errNilCounter
+	}
+
+	if config.Window <= 0 {
+		config.Window = defaultCrashLoopWindow
+	}
+
+	if config.Threshold <= 0 {
+		config.Threshold = defaultCrashLoopThreshold
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &CrashLoopParser{
+		name:         crashLoopParserName,
+		window:       config.Window,
+		threshold:    config.Threshold,
+		onlineFinder: measures.timeFinder(history.CurrentSessionFinder(validation.DestinationValidator(onlineEventType)), crashLoopParserName, onlineFinderName, logger),
+		client:       client,
+		counter:      counter,
+		measures:     measures,
+		logger:       logger,
+	}, nil
+}
+
+// Name implements the Parser interface.
+func (p *CrashLoopParser) Name() string {
+	return p.name
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p *CrashLoopParser) EventTypeRegexes() []string {
+	return []string{"^" + onlineEventType + "$"}
+}
+
+// Parse counts the device as crash looping if the online event is the first of its session and the device
+// booted at least the threshold number of times within the window.
+func (p *CrashLoopParser) Parse(ctx context.Context, currentEvent interpreter.Event) {
+	p.parse(ctx, currentEvent, p.client)
+}
+
+// ParseBatch implements the queue.BatchParser interface, parsing each event in the batch while only getting
+// the history of events from codex once for each device in the batch.
+func (p *CrashLoopParser) ParseBatch(ctx context.Context, events []interpreter.Event) {
+	client := newBatchEventClient(p.client)
+	client.GetEventsBatch(ctx, batchDeviceIDs(events, onlineEventType))
+	for _, event := range events {
+		p.parse(ctx, event, client)
+	}
+}
+
+func (p *CrashLoopParser) parse(ctx context.Context, currentEvent interpreter.Event, client EventClient) {
+	eventType, err := currentEvent.EventType()
+	if err != nil || eventType != onlineEventType {
+		return
+	}
+
+	deviceID, err := deviceid.FromEvent(currentEvent)
+	if err != nil {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	bootTime, err := currentEvent.BootTime()
+	if err != nil || bootTime <= 0 {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	events := client.GetEvents(ctx, deviceID)
+
+	// each session is only counted once, when its first online event is parsed.
+	if previous, err := p.onlineFinder.Find(events, currentEvent); err == nil && previous.Birthdate < currentEvent.Birthdate {
+		return
+	}
+
+	boots := countBoots(events, bootTime, p.window)
+	if boots < p.threshold {
+		return
+	}
+
+	hardware, firmware, _ := getHardwareFirmware(currentEvent)
+	p.logger.Warn("device crash looping", zap.String("device id", deviceID), zap.Int("boots", boots),
+		zap.Duration("window", p.window), zap.String("firmware", firmware), zap.String("hardware", hardware))
+	p.counter.With(prometheus.Labels{firmwareLabel: firmware, hardwareLabel: hardware}).Add(1.0)
+}
+
+// countBoots returns the number of distinct boot-times within the window ending at the current boot-time,
+// including the current boot-time.
+func countBoots(events []interpreter.Event, currentBootTime int64, window time.Duration) int {
+	windowStart := time.Unix(currentBootTime, 0).Add(-1 * window).Unix()
+	bootTimes := map[int64]bool{currentBootTime: true}
+	for _, event := range events {
+		bootTime, err := event.BootTime()
+		if err != nil || bootTime < windowStart || bootTime > currentBootTime {
+			continue
+		}
+		bootTimes[bootTime] = true
+	}
+
+	return len(bootTimes)
+}
+
+func (p *CrashLoopParser) addToUnparsableCounters(event interpreter.Event, err error, compared ...interpreter.Event) {
+	p.measures.AddTotalUnparsable(p.name)
+	p.measures.AddUnparsableEventType(p.name, err, event)
+	p.measures.AddUnparsableRatio(event)
+	p.measures.LogUnparsable(p.name, err, event, compared)
+}
+
+// createCrashLoopParsers creates the crash loop parser if it is enabled.
+func createCrashLoopParsers(f *touchstone.Factory, config CrashLoopConfig, client events.EventSource, measures Measures, logger *zap.Logger) ([]queue.Parser, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if f == nil {
+		return nil, errNilFactory
+	}
+
+	counter, err := f.NewCounterVec(prometheus.CounterOpts{
+		Name:        metricName(config.MetricPrefix, "crash_loop_devices_count"),
+		Help:        "number of sessions started by devices that booted at least the crash loop threshold number of times within the window",
+		ConstLabels: measures.ConfigVariantLabels,
+	}, firmwareLabel, hardwareLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	// the device's history is needed back to the oldest session that could be counted.
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = defaultCrashLoopThreshold
+	}
+
+	parser, err := NewCrashLoopParser(config, parserEventClient(client, crashLoopParserName, events.TrimConfig{MaxSessions: threshold}), counter, measures, logger.With(zap.String("parser", crashLoopParserName)))
+	if err != nil {
+		return nil, err
+	}
+
+	return []queue.Parser{parser}, nil
+}
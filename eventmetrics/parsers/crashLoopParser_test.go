@@ -0,0 +1,158 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestCrashLoopCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testCrashLoop",
+		Help: "testCrashLoop",
+	}, []string{firmwareLabel, hardwareLabel})
+}
+
+func TestCrashLoopParse(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	currentBoot := now.Add(-1 * time.Minute)
+	currentEvent := sessionEvent(onlineEventType, currentBoot, now, "current")
+	recentBoots := []interpreter.Event{
+		sessionEvent(onlineEventType, now.Add(-50*time.Minute), now.Add(-49*time.Minute), "1"),
+		sessionEvent(offlineEventType, now.Add(-50*time.Minute), now.Add(-45*time.Minute), "2"),
+		sessionEvent(onlineEventType, now.Add(-40*time.Minute), now.Add(-39*time.Minute), "3"),
+		sessionEvent(onlineEventType, now.Add(-20*time.Minute), now.Add(-19*time.Minute), "4"),
+	}
+
+	tests := []struct {
+		description        string
+		config             CrashLoopConfig
+		event              interpreter.Event
+		history            []interpreter.Event
+		expectedCount      float64
+		expectedUnparsable float64
+	}{
+		{
+			description:   "Crash looping",
+			config:        CrashLoopConfig{Threshold: 4},
+			event:         currentEvent,
+			history:       append(recentBoots, currentEvent),
+			expectedCount: 1.0,
+		},
+		{
+			description: "Below threshold",
+			config:      CrashLoopConfig{Threshold: 5},
+			event:       currentEvent,
+			history:     append(recentBoots, currentEvent),
+		},
+		{
+			description: "Boots outside window",
+			config:      CrashLoopConfig{Threshold: 4, Window: 30 * time.Minute},
+			event:       currentEvent,
+			history:     append(recentBoots, currentEvent),
+		},
+		{
+			description: "Later online event",
+			config:      CrashLoopConfig{Threshold: 4},
+			event:       currentEvent,
+			history: append(recentBoots,
+				sessionEvent(onlineEventType, currentBoot, currentBoot.Add(time.Second), "first"),
+				currentEvent,
+			),
+		},
+		{
+			description:        "Missing boot-time",
+			event:              interpreter.Event{Destination: fmt.Sprintf("event:device-status/%s/online", testUptimeDeviceID)},
+			expectedUnparsable: 1.0,
+		},
+		{
+			description: "Wrong event type",
+			config:      CrashLoopConfig{Threshold: 1},
+			event:       sessionEvent(offlineEventType, currentBoot, now, "current"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", testUptimeDeviceID).Return(tc.history)
+			counter := newTestCrashLoopCounter()
+			unparsable := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testUnparsable",
+				Help: "testUnparsable",
+			}, []string{parserLabel})
+
+			parser, err := NewCrashLoopParser(tc.config, client, counter, Measures{TotalUnparsableCount: unparsable}, zap.NewNop())
+			assert.Nil(err)
+			parser.Parse(context.Background(), tc.event)
+
+			assert.Equal(tc.expectedCount, testutil.ToFloat64(counter.WithLabelValues("fw", "hw")))
+			assert.Equal(tc.expectedUnparsable, testutil.ToFloat64(unparsable.WithLabelValues(crashLoopParserName)))
+		})
+	}
+}
+
+func TestCountBoots(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	events := []interpreter.Event{
+		sessionEvent(onlineEventType, now.Add(-2*time.Hour), now, "1"),
+		sessionEvent(onlineEventType, now.Add(-30*time.Minute), now, "2"),
+		sessionEvent(offlineEventType, now.Add(-30*time.Minute), now, "3"),
+		sessionEvent(onlineEventType, now.Add(time.Minute), now, "4"),
+		{Destination: "event:device-status/mac:112233445566/online"},
+	}
+
+	assert.Equal(t, 2, countBoots(events, now.Unix(), time.Hour))
+	assert.Equal(t, 3, countBoots(events, now.Unix(), 3*time.Hour))
+	assert.Equal(t, 1, countBoots(nil, now.Unix(), time.Hour))
+}
+
+func TestNewCrashLoopParser(t *testing.T) {
+	assert := assert.New(t)
+	counter := newTestCrashLoopCounter()
+
+	parser, err := NewCrashLoopParser(CrashLoopConfig{}, nil, counter, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilEventClient, err)
+
+	parser, err = NewCrashLoopParser(CrashLoopConfig{}, new(mockEventClient), nil, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilCounter, err)
+
+	parser, err = NewCrashLoopParser(CrashLoopConfig{}, new(mockEventClient), counter, Measures{}, nil)
+	assert.Nil(err)
+	assert.Equal(crashLoopParserName, parser.Name())
+	assert.Equal(defaultCrashLoopWindow, parser.window)
+	assert.Equal(defaultCrashLoopThreshold, parser.threshold)
+	assert.Equal([]string{"^online$"}, parser.EventTypeRegexes())
+}
+
+func TestCreateCrashLoopParsers(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+
+	parsers, err := createCrashLoopParsers(testFactory, CrashLoopConfig{}, nil, Measures{}, zap.NewNop())
+	assert.Nil(err)
+	assert.Empty(parsers)
+
+	parsers, err = createCrashLoopParsers(nil, CrashLoopConfig{Enabled: true}, nil, Measures{}, zap.NewNop())
+	assert.Equal(errNilFactory, err)
+	assert.Empty(parsers)
+
+	parsers, err = createCrashLoopParsers(testFactory, CrashLoopConfig{Enabled: true, MetricPrefix: "test"}, nil, Measures{}, zap.NewNop())
+	assert.Equal(errNilEventClient, err)
+	assert.Empty(parsers)
+}
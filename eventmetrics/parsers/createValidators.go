@@ -42,6 +42,9 @@ type EventValidationConfig struct {
 	ValidEventTypes            []string
 	MinBootDuration            time.Duration
 	BirthdateAlignmentDuration time.Duration
+
+	// Rules are the rules checked by the rule validator.
+	Rules []ValidationRuleConfig
 }
 
 func createEventValidator(config EventValidationConfig) (validation.Validator, error) {
@@ -74,6 +77,8 @@ func createEventValidator(config EventValidationConfig) (validation.Validator, e
 		return validation.EventTypeValidator(config.ValidEventTypes), nil
 	case enums.ConsistentDeviceIDValidation:
 		return validation.ConsistentDeviceIDValidator(), nil
+	case enums.RuleValidation:
+		return createRuleValidators(config.Rules)
 	default:
 		return nil, errNonExistentKey
 	}
@@ -95,6 +100,8 @@ func createCycleValidator(config CycleValidationConfig) (history.CycleValidator,
 		}), nil
 	case enums.EventOrderValidation:
 		return history.EventOrderValidator(config.EventOrder), nil
+	case enums.MonotonicBootTimeValidation:
+		return monotonicBootTimeValidator(), nil
 	default:
 		return nil, errNonExistentKey
 	}
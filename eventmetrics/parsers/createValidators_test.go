@@ -1,11 +1,16 @@
 package parsers
 
 import (
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
+	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
 )
 
 func TestCreateEventValidator(t *testing.T) {
@@ -23,6 +28,7 @@ func TestCreateEventValidator(t *testing.T) {
 		{key: enums.BirthdateAlignmentValidation},
 		{key: enums.ValidEventTypeValidation},
 		{key: enums.ConsistentDeviceIDValidation},
+		{key: enums.RuleValidation},
 	}
 
 	for _, tc := range tests {
@@ -40,6 +46,66 @@ func TestCreateEventValidator(t *testing.T) {
 	}
 }
 
+func TestCreateEventValidators(t *testing.T) {
+	configs := []EventValidationConfig{
+		{Key: enums.ValidEventTypeValidation, ValidEventTypes: []string{"online"}},
+		{Key: enums.BootTimeValidation},
+	}
+	offline := func(bootTime int64) interpreter.Event {
+		return interpreter.Event{
+			Destination: "event:device-status/mac:112233445566/offline",
+			Metadata:    map[string]string{interpreter.BootTimeKey: fmt.Sprint(bootTime)},
+		}
+	}
+
+	tests := []struct {
+		description    string
+		bounds         BootTimeBoundsConfig
+		event          interpreter.Event
+		expectedBounds bool
+		expectedErrs   int
+	}{
+		{
+			description:  "bounds disabled",
+			event:        offline(1),
+			expectedErrs: 2,
+		},
+		{
+			description:    "implausible boot-time checked first",
+			bounds:         BootTimeBoundsConfig{Enabled: true},
+			event:          offline(1),
+			expectedBounds: true,
+		},
+		{
+			description:  "plausible boot-time",
+			bounds:       BootTimeBoundsConfig{Enabled: true},
+			event:        offline(time.Now().Add(-1 * time.Hour).Unix()),
+			expectedErrs: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			validator, err := createEventValidators(configs, tc.bounds)
+			if !assert.Nil(err) {
+				return
+			}
+
+			valid, err := validator.Valid(tc.event)
+			assert.False(valid)
+			assert.Equal(tc.expectedBounds, errors.Is(err, ErrImplausibleBootTime))
+			var errs validation.Errors
+			if tc.expectedErrs > 0 && assert.True(errors.As(err, &errs)) {
+				assert.Len(errs, tc.expectedErrs)
+			}
+		})
+	}
+
+	_, err := createEventValidators([]EventValidationConfig{{Key: enums.UnknownEventValidation}}, BootTimeBoundsConfig{Enabled: true})
+	assert.Equal(t, errNonExistentKey, err)
+}
+
 func TestCreateCycleValidator(t *testing.T) {
 	tests := []struct {
 		key         enums.CycleValidationType
@@ -54,6 +120,7 @@ func TestCreateCycleValidator(t *testing.T) {
 		{key: enums.SessionOnlineValidation},
 		{key: enums.SessionOfflineValidation},
 		{key: enums.EventOrderValidation},
+		{key: enums.MonotonicBootTimeValidation},
 	}
 
 	for _, tc := range tests {
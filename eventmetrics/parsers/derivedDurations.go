@@ -0,0 +1,184 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+)
+
+const (
+	differenceOperation = "difference"
+	ratioOperation      = "ratio"
+)
+
+var (
+	errUnknownDerivedOperation = errors.New("unknown derived duration operation")
+	errUnknownDerivedOperand   = errors.New("derived duration references an unknown calculation")
+)
+
+// DerivedDurationConfig configures a metric derived from the durations of two of the reboot duration parser's
+// calculations, recorded whenever both calculations succeed for the same fully-manageable event.
+type DerivedDurationConfig struct {
+	// Name is the name of the derived histogram.  Like the calculations' names, it is prefixed with the parser's
+	// metric prefix.
+	Name string
+
+	// Operation determines how the durations are combined: "difference" records the first duration minus the
+	// second and "ratio" records the first duration divided by the second.  Defaults to "difference".
+	Operation string
+
+	// First and Second are the names of the calculations combined, such as boot_to_manageable or the name of a
+	// time elapsed calculation.
+	First  string
+	Second string
+}
+
+// derivedOperations are the operations that derived durations can be configured with.
+var derivedOperations = map[string]func(first float64, second float64) float64{
+	differenceOperation: func(first float64, second float64) float64 {
+		return first - second
+	},
+	ratioOperation: func(first float64, second float64) float64 {
+		return first / second
+	},
+}
+
+// derivedDuration records the combination of the durations of two calculations.
+type derivedDuration struct {
+	name      string
+	operation func(first float64, second float64) float64
+	first     durationAnalyzer
+	second    durationAnalyzer
+	histogram prometheus.ObserverVec
+	partners  durationPartners
+}
+
+// observe recalculates the durations of both calculations, without recording them again, and records their
+// combination if both succeeded.  Results that aren't positive are not recorded.
+func (d derivedDuration) observe(m Measures, events []interpreter.Event, currentEvent interpreter.Event) bool {
+	first := d.first.analyzeDuration(events, currentEvent)
+	second := d.second.analyzeDuration(events, currentEvent)
+	if len(first.Error) > 0 || len(second.Error) > 0 {
+		return false
+	}
+
+	value := d.operation(first.Seconds, second.Seconds)
+	if value <= 0 {
+		return false
+	}
+
+	m.addPartnerDuration(d.histogram, value, currentEvent, d.partners)
+	m.exportDuration(d.name, value, currentEvent, nil)
+	return true
+}
+
+// derivedOperation returns the operation of the config, defaulting to difference.
+func derivedOperation(config DerivedDurationConfig) string {
+	if len(config.Operation) == 0 {
+		return differenceOperation
+	}
+
+	return strings.ToLower(config.Operation)
+}
+
+// calculatorName returns the name of the histogram a duration calculator records to.
+func calculatorName(calculator DurationCalculator) string {
+	switch c := calculator.(type) {
+	case bootDurationCalculator:
+		return c.name
+	case *EventToCurrentCalculator:
+		return c.name
+	default:
+		return ""
+	}
+}
+
+// createDerivedDurations creates the derived durations of the parser's config from its calculators, creating
+// their histograms as configured for the parser.
+func createDerivedDurations(f *touchstone.Factory, parserConfig RebootParserConfig, m Measures, calculators []DurationCalculator) ([]derivedDuration, error) {
+	if len(parserConfig.DerivedDurations) == 0 {
+		return nil, nil
+	}
+
+	analyzers := make(map[string]durationAnalyzer, len(calculators))
+	for _, calculator := range calculators {
+		analyzer, ok := calculator.(durationAnalyzer)
+		if name := calculatorName(calculator); ok && len(name) > 0 {
+			analyzers[name] = analyzer
+		}
+	}
+
+	partners := newDurationPartners(parserConfig.PartnerLabel)
+	derived := make([]derivedDuration, len(parserConfig.DerivedDurations))
+	for i, config := range parserConfig.DerivedDurations {
+		if len(config.Name) == 0 {
+			return nil, errBlankHistogramName
+		}
+
+		operationName := derivedOperation(config)
+		operation, found := derivedOperations[operationName]
+		if !found {
+			return nil, fmt.Errorf("%w: %s", errUnknownDerivedOperation, config.Operation)
+		}
+
+		name := metricName(parserConfig.MetricPrefix, config.Name)
+		first, found := analyzers[metricName(parserConfig.MetricPrefix, config.First)]
+		if !found {
+			return nil, fmt.Errorf("%w: %s first %q", errUnknownDerivedOperand, config.Name, config.First)
+		}
+
+		second, found := analyzers[metricName(parserConfig.MetricPrefix, config.Second)]
+		if !found {
+			return nil, fmt.Errorf("%w: %s second %q", errUnknownDerivedOperand, config.Name, config.Second)
+		}
+
+		options := prometheus.HistogramOpts{
+			Name:        name,
+			Help:        fmt.Sprintf("%s minus %s in s", config.First, config.Second),
+			Buckets:     []float64{5, 10, 15, 30, 60, 120, 180, 240, 300, 600, 900, 1200, 1800, 3600},
+			ConstLabels: m.ConfigVariantLabels,
+		}
+
+		if operationName == ratioOperation {
+			options.Help = fmt.Sprintf("%s divided by %s", config.First, config.Second)
+			options.Buckets = []float64{0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10}
+		}
+
+		options = parserConfig.NativeHistograms.apply(options)
+		if err := m.addTimeElapsedObserver(f, parserConfig.Summaries, options, partners.labelNames()...); err != nil {
+			return nil, err
+		}
+
+		derived[i] = derivedDuration{
+			name:      name,
+			operation: operation,
+			first:     first,
+			second:    second,
+			histogram: m.TimeElapsedHistograms[name],
+			partners:  partners,
+		}
+	}
+
+	return derived, nil
+}
@@ -0,0 +1,185 @@
+package parsers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestDerivedCalculators(t *testing.T, prefix string) []DurationCalculator {
+	calculator, err := NewEventToCurrentCalculator(history.LastSessionFinder(validation.DestinationValidator(rebootPendingEventType)),
+		func(_ interpreter.Event, _ interpreter.Event, _ float64) {}, nil)
+	assert.Nil(t, err)
+	calculator.name = metricName(prefix, "reboot_to_manageable")
+
+	return []DurationCalculator{
+		bootDurationCalculator{name: metricName(prefix, "boot_to_manageable")},
+		calculator,
+		&mockDurationCalculator{},
+	}
+}
+
+func TestCreateDerivedDurations(t *testing.T) {
+	tests := []struct {
+		description   string
+		prefix        string
+		configs       []DerivedDurationConfig
+		expectedNames []string
+		expectedErr   error
+	}{
+		{
+			description: "None",
+		},
+		{
+			description: "Success",
+			configs: []DerivedDurationConfig{
+				{Name: "reboot_minus_boot", First: "reboot_to_manageable", Second: "boot_to_manageable"},
+				{Name: "reboot_boot_ratio", Operation: "Ratio", First: "reboot_to_manageable", Second: "boot_to_manageable"},
+			},
+			expectedNames: []string{"reboot_minus_boot", "reboot_boot_ratio"},
+		},
+		{
+			description:   "Prefixed",
+			prefix:        "test",
+			configs:       []DerivedDurationConfig{{Name: "reboot_minus_boot", First: "reboot_to_manageable", Second: "boot_to_manageable"}},
+			expectedNames: []string{"test_reboot_minus_boot"},
+		},
+		{
+			description: "Blank name",
+			configs:     []DerivedDurationConfig{{First: "reboot_to_manageable", Second: "boot_to_manageable"}},
+			expectedErr: errBlankHistogramName,
+		},
+		{
+			description: "Unknown operation",
+			configs:     []DerivedDurationConfig{{Name: "test", Operation: "sum", First: "reboot_to_manageable", Second: "boot_to_manageable"}},
+			expectedErr: errUnknownDerivedOperation,
+		},
+		{
+			description: "Unknown first calculation",
+			configs:     []DerivedDurationConfig{{Name: "test", First: "unknown", Second: "boot_to_manageable"}},
+			expectedErr: errUnknownDerivedOperand,
+		},
+		{
+			description: "Unknown second calculation",
+			configs:     []DerivedDurationConfig{{Name: "test", First: "reboot_to_manageable", Second: "unknown"}},
+			expectedErr: errUnknownDerivedOperand,
+		},
+		{
+			description: "Name used by a calculation",
+			configs:     []DerivedDurationConfig{{Name: "reboot_to_manageable", First: "reboot_to_manageable", Second: "boot_to_manageable"}},
+			expectedErr: errNewHistogram,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+			testMeasures := Measures{TimeElapsedHistograms: map[string]prometheus.ObserverVec{
+				metricName(tc.prefix, "reboot_to_manageable"): prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "reboot_to_manageable"}, []string{}),
+			}}
+			config := RebootParserConfig{MetricPrefix: tc.prefix, DerivedDurations: tc.configs}
+
+			derived, err := createDerivedDurations(testFactory, config, testMeasures, newTestDerivedCalculators(t, tc.prefix))
+			assert.ErrorIs(err, tc.expectedErr)
+			if tc.expectedErr != nil {
+				assert.Nil(derived)
+				return
+			}
+
+			if !assert.Len(derived, len(tc.expectedNames)) {
+				return
+			}
+
+			for i, name := range tc.expectedNames {
+				assert.Equal(name, derived[i].name)
+				assert.NotNil(derived[i].histogram)
+				assert.Contains(testMeasures.TimeElapsedHistograms, name)
+			}
+		})
+	}
+}
+
+func TestDerivedDurationObserve(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	previousBoot := now.Add(-1 * time.Hour)
+	currentBoot := now.Add(-10 * time.Minute)
+	events := []interpreter.Event{
+		sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+		sessionEvent(rebootPendingEventType, previousBoot, now.Add(-15*time.Minute), "2"),
+	}
+	currentEvent := sessionEvent(fullyManageableEventType, currentBoot, now, "current")
+
+	tests := []struct {
+		description      string
+		config           DerivedDurationConfig
+		events           []interpreter.Event
+		expectedObserved bool
+		expectedSum      float64
+	}{
+		{
+			description:      "Difference",
+			config:           DerivedDurationConfig{Name: "test", First: "reboot_to_manageable", Second: "boot_to_manageable"},
+			events:           events,
+			expectedObserved: true,
+			expectedSum:      (5 * time.Minute).Seconds(),
+		},
+		{
+			description:      "Ratio",
+			config:           DerivedDurationConfig{Name: "test", Operation: "ratio", First: "reboot_to_manageable", Second: "boot_to_manageable"},
+			events:           events,
+			expectedObserved: true,
+			expectedSum:      1.5,
+		},
+		{
+			description: "Negative difference",
+			config:      DerivedDurationConfig{Name: "test", First: "boot_to_manageable", Second: "reboot_to_manageable"},
+			events:      events,
+		},
+		{
+			description: "Calculation failed",
+			config:      DerivedDurationConfig{Name: "test", First: "reboot_to_manageable", Second: "boot_to_manageable"},
+			events:      events[:1],
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			registry := prometheus.NewPedanticRegistry()
+			testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+			testMeasures := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
+			config := RebootParserConfig{DerivedDurations: []DerivedDurationConfig{tc.config}}
+
+			derived, err := createDerivedDurations(testFactory, config, testMeasures, newTestDerivedCalculators(t, ""))
+			if !assert.Nil(err) || !assert.Len(derived, 1) {
+				return
+			}
+
+			assert.Equal(tc.expectedObserved, derived[0].observe(testMeasures, tc.events, currentEvent))
+
+			families, err := registry.Gather()
+			assert.Nil(err)
+			if !tc.expectedObserved {
+				assert.Empty(families)
+				return
+			}
+
+			if !assert.Len(families, 1) {
+				return
+			}
+
+			histogram := families[0].GetMetric()[0].GetHistogram()
+			assert.Equal(uint64(1), histogram.GetSampleCount())
+			assert.InDelta(tc.expectedSum, histogram.GetSampleSum(), 0.0001)
+		})
+	}
+}
@@ -23,7 +23,9 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
 	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/interpreter/history"
 	"github.com/xmidt-org/interpreter/validation"
@@ -32,8 +34,10 @@ import (
 )
 
 var (
-	errCalculation   = errors.New("time elapsed calculation error")
-	errEventNotFound = errors.New("event not found")
+	errCalculation        = taxonomy.ErrCalculation
+	errNegativeDuration   = taxonomy.ErrNegativeDuration
+	errDurationOutOfRange = taxonomy.ErrDurationOutOfRange
+	errEventNotFound      = taxonomy.ErrEventNotFound
 
 	errMissingFinder = errors.New("missing Finder")
 )
@@ -47,20 +51,65 @@ func (cf CalculatorFunc) Calculate(events []interpreter.Event, event interpreter
 	return cf(events, event)
 }
 
+// DurationBounds limits the durations that are recorded to a sane range.
+type DurationBounds struct {
+	// Max is the longest duration recorded.  Longer durations are rejected as out of range.  If it is zero,
+	// durations are not limited.
+	Max time.Duration
+
+	// ClockSkewTolerance is how far below zero a duration can be, due to the clocks of the times compared
+	// disagreeing, and still be recorded.  These durations are recorded as zero, regardless of the zero
+	// duration policy.  Defaults to 0, rejecting all negative durations.
+	ClockSkewTolerance time.Duration
+}
+
+// bound checks that the duration is within the bounds, returning the duration to record and whether it was
+// a negative duration within the clock-skew tolerance.
+func (b DurationBounds) bound(duration float64) (float64, bool, error) {
+	switch {
+	case b.Max > 0 && duration > b.Max.Seconds():
+		return duration, false, errDurationOutOfRange
+	case duration < 0 && -duration <= b.ClockSkewTolerance.Seconds():
+		return 0, true, nil
+	case duration < 0:
+		return duration, false, errNegativeDuration
+	}
+
+	return duration, false, nil
+}
+
 // BootDurationCalculator returns a CalculatorFunc that calculates the time between the birthdate and the boot-time
-// of an event, calling the successCallback if a duration is successfully calculated.
-func BootDurationCalculator(logger *zap.Logger, successCallback func(interpreter.Event, float64)) CalculatorFunc {
+// of an event, calling the successCallback if a duration is successfully calculated.  If a duration of exactly zero
+// is calculated, zeroDuration is called to determine whether it is recorded; if zeroDuration is nil, it is rejected.
+// Durations outside of the bounds are rejected.
+func BootDurationCalculator(logger *zap.Logger, successCallback func(interpreter.Event, float64), zeroDuration func() bool, bounds DurationBounds) CalculatorFunc {
 	return func(events []interpreter.Event, event interpreter.Event) error {
-		bootTime, _ := event.BootTime()
-		bootTimeUnix := time.Unix(bootTime, 0)
-		birthdateUnix := time.Unix(0, event.Birthdate)
-		var bootDuration float64
-		if bootTime > 0 && event.Birthdate > 0 {
-			bootDuration = birthdateUnix.Sub(bootTimeUnix).Seconds()
+		bootDuration, timesFound := calculateBootDuration(event)
+		if timesFound {
+			duration, skewed, err := bounds.bound(bootDuration)
+			if err != nil {
+				deviceID, _ := deviceid.FromEvent(event)
+				logger.Error("invalid time calculated", zap.Error(err), zap.String("deviceID", deviceID), zap.Float64("invalid time elapsed", bootDuration), zap.String("incoming event", event.TransactionUUID))
+				return err
+			}
+
+			if skewed {
+				if successCallback != nil {
+					successCallback(event, duration)
+				}
+				return nil
+			}
+		}
+
+		if timesFound && bootDuration == 0 && zeroDuration != nil && zeroDuration() {
+			if successCallback != nil {
+				successCallback(event, bootDuration)
+			}
+			return nil
 		}
 
 		if bootDuration <= 0 {
-			deviceID, _ := event.DeviceID()
+			deviceID, _ := deviceid.FromEvent(event)
 			logger.Error("invalid time calculated", zap.String("deviceID", deviceID), zap.Float64("invalid time elapsed", bootDuration), zap.String("incoming event", event.TransactionUUID))
 			return errCalculation
 		}
@@ -73,11 +122,44 @@ func BootDurationCalculator(logger *zap.Logger, successCallback func(interpreter
 	}
 }
 
+// calculateBootDuration returns the time between the boot-time and birthdate of the event, returning false if
+// either time is missing.
+func calculateBootDuration(event interpreter.Event) (float64, bool) {
+	bootTime, _ := event.BootTime()
+	if bootTime <= 0 || event.Birthdate <= 0 {
+		return 0, false
+	}
+
+	return time.Unix(0, event.Birthdate).Sub(time.Unix(bootTime, 0)).Seconds(), true
+}
+
+// bootDurationCalculator is the boot duration calculator of the reboot duration parser, named after its histogram.
+type bootDurationCalculator struct {
+	CalculatorFunc
+	name string
+}
+
 // EventToCurrentCalculator calculates the difference between the current event and a previous event.
 type EventToCurrentCalculator struct {
+	name            string
 	eventFinder     Finder
 	successCallback func(currentEvent interpreter.Event, foundEvent interpreter.Event, duration float64)
 	logger          *zap.Logger
+
+	// endFinder finds the event the duration ends at.  If it is nil, the duration ends at the current event.
+	endFinder Finder
+
+	// startSource and endSource determine which times of the starting and ending event are used.
+	// Both default to the birthdate.
+	startSource enums.TimeSource
+	endSource   enums.TimeSource
+
+	// zeroDuration is called when a duration of exactly zero is calculated, returning whether it should be
+	// recorded.  If it is nil, zero durations are rejected.
+	zeroDuration func() bool
+
+	// bounds limits the durations recorded.
+	bounds DurationBounds
 }
 
 // NewEventToCurrentCalculator creates a new EventToCurrentCalculator and an error if the finder is nil.
@@ -103,7 +185,8 @@ func NewEventToCurrentCalculator(eventFinder Finder, successCallback func(curren
 	}, nil
 }
 
-// Calculate implements the DurationCalculator interface by subtracting the birthdates of the two events.
+// Calculate implements the DurationCalculator interface by subtracting the times of the two events, using the
+// birthdates unless configured otherwise.
 func (c *EventToCurrentCalculator) Calculate(events []interpreter.Event, event interpreter.Event) error {
 	if c.logger == nil {
 		c.logger = zap.NewNop()
@@ -113,21 +196,49 @@ func (c *EventToCurrentCalculator) Calculate(events []interpreter.Event, event i
 		return errMissingFinder
 	}
 
-	currentBirthdate := time.Unix(0, event.Birthdate)
 	startingEvent, err := c.eventFinder.Find(events, event)
 	if err != nil {
 		c.logger.Error("time calculation error", zap.Error(err))
 		return errEventNotFound
 	}
 
-	startingEventTime := time.Unix(0, startingEvent.Birthdate)
-	var timeElapsed float64
-	if event.Birthdate > 0 && startingEvent.Birthdate > 0 {
-		timeElapsed = currentBirthdate.Sub(startingEventTime).Seconds()
+	endingEvent, err := c.endingEvent(c.endFinder, events, event)
+	if err != nil {
+		c.logger.Error("time calculation error", zap.Error(err))
+		return errEventNotFound
+	}
+
+	timeElapsed, timesFound := c.timeElapsed(startingEvent, endingEvent)
+	if timesFound {
+		duration, skewed, err := c.bounds.bound(timeElapsed)
+		if err != nil {
+			deviceID, _ := deviceid.FromEvent(event)
+			c.logger.Error("time calculation error",
+				zap.Error(err),
+				zap.String("deviceID", deviceID),
+				zap.String("incoming event", event.TransactionUUID),
+				zap.String("comparison event", startingEvent.TransactionUUID),
+				zap.Float64("time calculated", timeElapsed))
+			return err
+		}
+
+		if skewed {
+			if c.successCallback != nil {
+				c.successCallback(event, startingEvent, duration)
+			}
+			return nil
+		}
+	}
+
+	if timesFound && timeElapsed == 0 && c.zeroDuration != nil && c.zeroDuration() {
+		if c.successCallback != nil {
+			c.successCallback(event, startingEvent, timeElapsed)
+		}
+		return nil
 	}
 
 	if timeElapsed <= 0 {
-		deviceID, _ := event.DeviceID()
+		deviceID, _ := deviceid.FromEvent(event)
 		c.logger.Error("time calculation error",
 			zap.String("deviceID", deviceID),
 			zap.String("incoming event", event.TransactionUUID),
@@ -143,41 +254,107 @@ func (c *EventToCurrentCalculator) Calculate(events []interpreter.Event, event i
 	return nil
 }
 
-// createDurationCalculators creates a list of DurationCalculators from config.
-func createDurationCalculators(f *touchstone.Factory, configs []TimeElapsedConfig, m Measures, loggerIn RebootLoggerIn) ([]DurationCalculator, error) {
-	calculators := make([]DurationCalculator, len(configs))
-	for i, config := range configs {
+// endingEvent returns the event found by the end finder, or the current event if there is no end finder.
+func (c *EventToCurrentCalculator) endingEvent(endFinder Finder, events []interpreter.Event, event interpreter.Event) (interpreter.Event, error) {
+	if endFinder == nil {
+		return event, nil
+	}
+
+	return endFinder.Find(events, event)
+}
+
+// timeElapsed returns the time between the starting event and the event, using the configured time sources.
+// It returns false if either time is missing.
+func (c *EventToCurrentCalculator) timeElapsed(startingEvent interpreter.Event, event interpreter.Event) (float64, bool) {
+	endTime, endFound := eventTime(event, c.endSource)
+	startTime, startFound := eventTime(startingEvent, c.startSource)
+	if !endFound || !startFound {
+		return 0, false
+	}
+
+	return endTime.Sub(startTime).Seconds(), true
+}
+
+// eventTime returns the time of the event from the source given, returning false if the time is missing.
+func eventTime(event interpreter.Event, source enums.TimeSource) (time.Time, bool) {
+	if source == enums.BootTimeSource {
+		bootTime, err := event.BootTime()
+		if err != nil || bootTime <= 0 {
+			return time.Time{}, false
+		}
+
+		return time.Unix(bootTime, 0), true
+	}
+
+	if event.Birthdate <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, event.Birthdate), true
+}
+
+// createDurationCalculators creates a list of DurationCalculators from config, handling zero durations and creating the
+// histograms as configured for the parser.
+func createDurationCalculators(f *touchstone.Factory, configs []TimeElapsedConfig, m Measures, parserConfig RebootParserConfig, loggerIn RebootLoggerIn) ([]DurationCalculator, error) {
+	zeroPolicy := enums.ParseZeroDurationPolicy(parserConfig.ZeroDurationPolicy)
+	partners := newDurationPartners(parserConfig.PartnerLabel)
+	newFinder := func(eventType string, sessionType string, name string) Finder {
+		var finder Finder
+		if enums.ParseSessionType(sessionType) == enums.Previous {
+			finder = history.LastSessionFinder(validation.DestinationValidator(eventType))
+			finder = explainFinder(parserConfig.FinderDiagnostics, finder, name, previousSessionSelectionReason, loggerIn.Logger)
+		} else {
+			finder = history.CurrentSessionFinder(validation.DestinationValidator(eventType))
+			finder = explainFinder(parserConfig.FinderDiagnostics, finder, name, currentSessionSelectionReason, loggerIn.Logger)
+		}
+		return m.timeFinder(finder, rebootDurationParserName, name, loggerIn.Logger)
+	}
+
+	for _, config := range configs {
 		if len(config.Name) == 0 {
 			return nil, errBlankHistogramName
 		}
+	}
+
+	configs, err := expandChains(configs)
+	if err != nil {
+		return nil, err
+	}
 
-		options := prometheus.HistogramOpts{
-			Name:    config.Name,
-			Help:    fmt.Sprintf("time elapsed between a %s event and fully-manageable event in s", config.EventType),
-			Buckets: []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 900, 1200, 1500, 1800, 3600, 7200, 14400, 21600},
+	calculators := make([]DurationCalculator, len(configs))
+	for i, config := range configs {
+		endEventType := fullyManageableEventType
+		if calculatorType(config) == betweenEventsCalculatorType && len(config.EndEventType) > 0 {
+			endEventType = config.EndEventType
 		}
 
-		if err := m.addTimeElapsedHistogram(f, options, firmwareLabel, hardwareLabel, rebootReasonLabel); err != nil {
+		options := parserConfig.NativeHistograms.apply(prometheus.HistogramOpts{
+			Name:        config.Name,
+			Help:        fmt.Sprintf("time elapsed between a %s event and %s event in s", config.EventType, endEventType),
+			Buckets:     []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 900, 1200, 1500, 1800, 3600, 7200, 14400, 21600},
+			ConstLabels: m.ConfigVariantLabels,
+		})
+
+		if err := m.addTimeElapsedObserver(f, parserConfig.Summaries, options, partners.labelNames()...); err != nil {
 			return nil, err
 		}
 
-		sessionType := enums.ParseSessionType(config.SessionType)
-		var finder Finder
-		if sessionType == enums.Previous {
-			finder = history.LastSessionFinder(validation.DestinationValidator(config.EventType))
-		} else {
-			finder = history.CurrentSessionFinder(validation.DestinationValidator(config.EventType))
+		callback, err := createTimeElapsedCallback(m, config.Name, partners)
+		if err != nil {
+			return nil, err
 		}
 
-		callback, err := createTimeElapsedCallback(m, config.Name)
+		calculator, err := NewEventToCurrentCalculator(newFinder(config.EventType, config.SessionType, config.Name), callback, loggerIn.Logger)
 		if err != nil {
 			return nil, err
 		}
 
-		calculator, err := NewEventToCurrentCalculator(finder, callback, loggerIn.Logger)
-		if err != nil {
+		calculator.name = config.Name
+		if err := configureCalculator(calculator, config, newFinder); err != nil {
 			return nil, err
 		}
+		calculator.zeroDuration = m.zeroDurationFunc(config.Name, zeroPolicy)
+		calculator.bounds = parserConfig.TimeElapsedBounds
 
 		calculators[i] = calculator
 	}
@@ -186,19 +363,21 @@ func createDurationCalculators(f *touchstone.Factory, configs []TimeElapsedConfi
 }
 
 // returns a callback that adds to the bootToManageable histogram for boot duration calculations
-func createBootDurationCallback(m Measures) (func(interpreter.Event, float64), error) {
+func createBootDurationCallback(m Measures, config RebootParserConfig) (func(interpreter.Event, float64), error) {
 	if m.BootToManageableHistogram == nil {
 		return nil, errNilBootHistogram
 	}
 
+	partners := newDurationPartners(config.PartnerLabel)
+	name := metricName(config.MetricPrefix, bootToManageableOpts.Name)
 	return func(event interpreter.Event, duration float64) {
-		labels := getTimeElapsedHistogramLabels(event)
-		m.BootToManageableHistogram.With(labels).Observe(duration)
+		m.addPartnerDuration(m.BootToManageableHistogram, duration, event, partners)
+		m.exportDuration(name, duration, event, nil)
 	}, nil
 }
 
-// returns a callback for time elapsed calculations
-func createTimeElapsedCallback(m Measures, name string) (func(interpreter.Event, interpreter.Event, float64), error) {
+// returns a callback for time elapsed calculations, labeling durations with the event's partner if partners is non-nil
+func createTimeElapsedCallback(m Measures, name string, partners durationPartners) (func(interpreter.Event, interpreter.Event, float64), error) {
 	if m.TimeElapsedHistograms == nil {
 		return nil, errNilHistogram
 	}
@@ -208,8 +387,7 @@ func createTimeElapsedCallback(m Measures, name string) (func(interpreter.Event,
 	}
 
 	return func(currentEvent interpreter.Event, startingEvent interpreter.Event, duration float64) {
-		labels := getTimeElapsedHistogramLabels(currentEvent)
-		histogram := m.TimeElapsedHistograms[name]
-		histogram.With(labels).Observe(duration)
+		m.addPartnerDuration(m.TimeElapsedHistograms[name], duration, currentEvent, partners)
+		m.exportDuration(name, duration, currentEvent, &startingEvent)
 	}, nil
 }
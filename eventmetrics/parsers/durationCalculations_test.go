@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/touchstone"
 	"github.com/xmidt-org/touchstone/touchtest"
@@ -22,6 +24,7 @@ func TestBootTimeCalculator(t *testing.T) {
 	tests := []struct {
 		description         string
 		event               interpreter.Event
+		bounds              DurationBounds
 		expectedTimeElapsed float64
 		expectedErr         error
 	}{
@@ -84,7 +87,53 @@ func TestBootTimeCalculator(t *testing.T) {
 				Birthdate: now.Add(-2 * time.Minute).UnixNano(),
 			},
 			expectedTimeElapsed: -1,
-			expectedErr:         errCalculation,
+			expectedErr:         errNegativeDuration,
+		},
+		{
+			description: "birthdate less than boot-time within clock-skew tolerance",
+			event: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+				},
+				Birthdate: now.Add(-2 * time.Second).UnixNano(),
+			},
+			bounds:              DurationBounds{ClockSkewTolerance: 5 * time.Second},
+			expectedTimeElapsed: 0,
+		},
+		{
+			description: "birthdate less than boot-time beyond clock-skew tolerance",
+			event: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+				},
+				Birthdate: now.Add(-10 * time.Second).UnixNano(),
+			},
+			bounds:              DurationBounds{ClockSkewTolerance: 5 * time.Second},
+			expectedTimeElapsed: -1,
+			expectedErr:         errNegativeDuration,
+		},
+		{
+			description: "duration at max",
+			event: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Add(-24 * time.Hour).Unix()),
+				},
+				Birthdate: now.UnixNano(),
+			},
+			bounds:              DurationBounds{Max: 24 * time.Hour},
+			expectedTimeElapsed: (24 * time.Hour).Seconds(),
+		},
+		{
+			description: "duration over max",
+			event: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Add(-25 * time.Hour).Unix()),
+				},
+				Birthdate: now.UnixNano(),
+			},
+			bounds:              DurationBounds{Max: 24 * time.Hour},
+			expectedTimeElapsed: -1,
+			expectedErr:         errDurationOutOfRange,
 		},
 	}
 
@@ -93,7 +142,7 @@ func TestBootTimeCalculator(t *testing.T) {
 			assert := assert.New(t)
 			calculator := BootDurationCalculator(zap.NewNop(), func(_ interpreter.Event, duration float64) {
 				assert.Equal(tc.expectedTimeElapsed, duration)
-			})
+			}, nil, tc.bounds)
 			err := calculator.Calculate([]interpreter.Event{}, tc.event)
 			assert.Equal(tc.expectedErr, err)
 		})
@@ -144,6 +193,7 @@ func TestEventToCurrentCalculator(t *testing.T) {
 		currentEvent        interpreter.Event
 		finderEvent         interpreter.Event
 		finderErr           error
+		bounds              DurationBounds
 		logger              *zap.Logger
 		expectedTimeElapsed float64
 		expectedErr         error
@@ -236,7 +286,41 @@ func TestEventToCurrentCalculator(t *testing.T) {
 				},
 				Birthdate: now.UnixNano(),
 			},
-			expectedErr: errCalculation,
+			expectedErr: errNegativeDuration,
+		},
+		{
+			description: "negative time elapsed within clock-skew tolerance",
+			currentEvent: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+				},
+				Birthdate: now.Add(-1 * time.Second).UnixNano(),
+			},
+			finderEvent: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+				},
+				Birthdate: now.UnixNano(),
+			},
+			bounds:              DurationBounds{ClockSkewTolerance: time.Second},
+			expectedTimeElapsed: 0,
+		},
+		{
+			description: "time elapsed over max",
+			currentEvent: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+				},
+				Birthdate: now.UnixNano(),
+			},
+			finderEvent: interpreter.Event{
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+				},
+				Birthdate: now.Add(-2 * time.Hour).UnixNano(),
+			},
+			bounds:      DurationBounds{Max: time.Hour},
+			expectedErr: errDurationOutOfRange,
 		},
 	}
 
@@ -248,6 +332,7 @@ func TestEventToCurrentCalculator(t *testing.T) {
 			calculator := EventToCurrentCalculator{
 				logger:      tc.logger,
 				eventFinder: finder,
+				bounds:      tc.bounds,
 				successCallback: func(_ interpreter.Event, _ interpreter.Event, duration float64) {
 					assert.Equal(tc.expectedTimeElapsed, duration)
 				},
@@ -258,6 +343,98 @@ func TestEventToCurrentCalculator(t *testing.T) {
 	}
 }
 
+func TestZeroDuration(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+	// boot-times have second precision, so a birthdate on the second is the same instant.
+	zeroEvent := interpreter.Event{
+		Metadata: map[string]string{
+			interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+		},
+		Birthdate: now.UnixNano(),
+	}
+	positiveEvent := interpreter.Event{
+		Metadata: map[string]string{
+			interpreter.BootTimeKey: fmt.Sprint(now.Add(-1 * time.Minute).Unix()),
+		},
+		Birthdate: now.UnixNano(),
+	}
+
+	tests := []struct {
+		description      string
+		policy           enums.ZeroDurationPolicy
+		event            interpreter.Event
+		expectedErr      error
+		expectedRecorded bool
+		expectedZeros    float64
+	}{
+		{
+			description:   "Reject zero",
+			policy:        enums.RejectZeroDuration,
+			event:         zeroEvent,
+			expectedErr:   errCalculation,
+			expectedZeros: 1,
+		},
+		{
+			description:      "Record zero",
+			policy:           enums.RecordZeroDuration,
+			event:            zeroEvent,
+			expectedRecorded: true,
+			expectedZeros:    1,
+		},
+		{
+			description:      "Record policy with positive duration",
+			policy:           enums.RecordZeroDuration,
+			event:            positiveEvent,
+			expectedRecorded: true,
+		},
+		{
+			description: "Record policy with missing boot-time",
+			policy:      enums.RecordZeroDuration,
+			event:       interpreter.Event{Birthdate: now.UnixNano()},
+			expectedErr: errCalculation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			t.Run("boot duration", func(t *testing.T) {
+				assert := assert.New(t)
+				m := Measures{ZeroDurationCount: prometheus.NewCounterVec(zeroDurationOpts, []string{calculationLabel})}
+				var recorded bool
+				calculator := BootDurationCalculator(zap.NewNop(), func(_ interpreter.Event, _ float64) {
+					recorded = true
+				}, m.zeroDurationFunc("boot_to_manageable", tc.policy), DurationBounds{})
+				err := calculator.Calculate([]interpreter.Event{}, tc.event)
+				assert.Equal(tc.expectedErr, err)
+				assert.Equal(tc.expectedRecorded, recorded)
+				assert.Equal(tc.expectedZeros, testutil.ToFloat64(m.ZeroDurationCount.WithLabelValues("boot_to_manageable")))
+			})
+
+			t.Run("event to current", func(t *testing.T) {
+				assert := assert.New(t)
+				m := Measures{ZeroDurationCount: prometheus.NewCounterVec(zeroDurationOpts, []string{calculationLabel})}
+				finder := new(mockFinder)
+				finder.On("Find", mock.Anything, mock.Anything).Return(tc.event, nil)
+				var recorded bool
+				calculator := EventToCurrentCalculator{
+					logger:      zap.NewNop(),
+					eventFinder: finder,
+					successCallback: func(_ interpreter.Event, _ interpreter.Event, _ float64) {
+						recorded = true
+					},
+					startSource:  enums.BootTimeSource,
+					zeroDuration: m.zeroDurationFunc("test", tc.policy),
+				}
+				err := calculator.Calculate([]interpreter.Event{}, tc.event)
+				assert.Equal(tc.expectedErr, err)
+				assert.Equal(tc.expectedRecorded, recorded)
+				assert.Equal(tc.expectedZeros, testutil.ToFloat64(m.ZeroDurationCount.WithLabelValues("test")))
+			})
+		})
+	}
+}
+
 func TestCreateDurationCalculators(t *testing.T) {
 	tests := []struct {
 		description string
@@ -311,6 +488,28 @@ func TestCreateDurationCalculators(t *testing.T) {
 			},
 			expectedErr: errBlankHistogramName,
 		},
+		{
+			description: "unknown calculator type",
+			configs: []TimeElapsedConfig{
+				TimeElapsedConfig{
+					Name:           "test",
+					EventType:      "test-event-type",
+					CalculatorType: "unknown",
+				},
+			},
+			expectedErr: errUnknownCalculatorType,
+		},
+		{
+			description: "missing end event type",
+			configs: []TimeElapsedConfig{
+				TimeElapsedConfig{
+					Name:           "test",
+					EventType:      "test-event-type",
+					CalculatorType: "between-events",
+				},
+			},
+			expectedErr: errMissingEndEventType,
+		},
 	}
 
 	for _, tc := range tests {
@@ -319,7 +518,7 @@ func TestCreateDurationCalculators(t *testing.T) {
 			testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
 
 			testMeasures := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
-			durationCalculators, err := createDurationCalculators(testFactory, tc.configs, testMeasures, RebootLoggerIn{Logger: zap.NewNop()})
+			durationCalculators, err := createDurationCalculators(testFactory, tc.configs, testMeasures, RebootParserConfig{}, RebootLoggerIn{Logger: zap.NewNop()})
 
 			if tc.expectedErr != nil {
 				assert.True(errors.Is(err, tc.expectedErr))
@@ -351,7 +550,7 @@ func TestCreateDurationCalculatorsHistogramErr(t *testing.T) {
 	}
 
 	testMeasures.addTimeElapsedHistogram(testFactory, options)
-	durationCalculators, err := createDurationCalculators(testFactory, []TimeElapsedConfig{config}, testMeasures, RebootLoggerIn{Logger: zap.NewNop()})
+	durationCalculators, err := createDurationCalculators(testFactory, []TimeElapsedConfig{config}, testMeasures, RebootParserConfig{}, RebootLoggerIn{Logger: zap.NewNop()})
 	assert.True(errors.Is(err, errNewHistogram))
 	assert.Nil(durationCalculators)
 }
@@ -396,7 +595,7 @@ func TestCreateBootDurationCallback(t *testing.T) {
 	actualRegistry := prometheus.NewPedanticRegistry()
 	expectedRegistry.Register(expectedHistogram)
 	actualRegistry.Register(m.BootToManageableHistogram)
-	callback, err := createBootDurationCallback(m)
+	callback, err := createBootDurationCallback(m, RebootParserConfig{})
 	assert.Nil(err)
 	callback(currentEvent, 5.0)
 	expectedHistogram.WithLabelValues(fwVal, hwVal, rebootReason).Observe(5.0)
@@ -404,7 +603,7 @@ func TestCreateBootDurationCallback(t *testing.T) {
 	testAssert.Expect(expectedRegistry)
 	assert.True(testAssert.GatherAndCompare(actualRegistry))
 
-	nilCallback, err := createBootDurationCallback(Measures{})
+	nilCallback, err := createBootDurationCallback(Measures{}, RebootParserConfig{})
 	assert.Nil(nilCallback)
 	assert.Equal(errNilBootHistogram, err)
 }
@@ -454,7 +653,7 @@ func TestCreateTimeElapsedCallback(t *testing.T) {
 	actualRegistry := prometheus.NewPedanticRegistry()
 	expectedRegistry.Register(expectedHistogram)
 	actualRegistry.Register(actualHistogram)
-	callback, err := createTimeElapsedCallback(m, histogramKey)
+	callback, err := createTimeElapsedCallback(m, histogramKey, nil)
 	assert.Nil(err)
 	callback(currentEvent, interpreter.Event{}, 5.0)
 	expectedHistogram.WithLabelValues(fwVal, hwVal, rebootReason).Observe(5.0)
@@ -462,7 +661,117 @@ func TestCreateTimeElapsedCallback(t *testing.T) {
 	testAssert.Expect(expectedRegistry)
 	assert.True(testAssert.GatherAndCompare(actualRegistry))
 
-	nilCallback, err := createTimeElapsedCallback(Measures{}, histogramKey)
+	nilCallback, err := createTimeElapsedCallback(Measures{}, histogramKey, nil)
 	assert.Nil(nilCallback)
 	assert.Equal(errNilHistogram, err)
 }
+
+func TestCreateDurationCalculatorsConfigVariant(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+	testMeasures := Measures{
+		TimeElapsedHistograms: make(map[string]prometheus.ObserverVec),
+		ConfigVariantLabels:   prometheus.Labels{configVariantLabel: "canary"},
+	}
+	config := TimeElapsedConfig{
+		Name:        "test_hist",
+		SessionType: "current",
+		EventType:   "test-event-type",
+	}
+
+	_, err := createDurationCalculators(testFactory, []TimeElapsedConfig{config}, testMeasures, RebootParserConfig{}, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+	testMeasures.TimeElapsedHistograms[config.Name].With(prometheus.Labels{hardwareLabel: "hw", firmwareLabel: "fw", rebootReasonLabel: "reason"}).Observe(1)
+
+	families, err := registry.Gather()
+	assert.Nil(err)
+	assert.Len(families, 1)
+	var variant string
+	for _, label := range families[0].GetMetric()[0].GetLabel() {
+		if label.GetName() == configVariantLabel {
+			variant = label.GetValue()
+		}
+	}
+	assert.Equal("canary", variant)
+}
+
+func TestEventToCurrentCalculatorTimeSources(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+	previousBootTime := now.Add(-2 * time.Hour)
+	rebootPendingBirthdate := now.Add(-10 * time.Minute)
+	currentBootTime := now.Add(-5 * time.Minute)
+
+	rebootPendingEvent := interpreter.Event{
+		Destination: "event:device-status/mac:112233445566/reboot-pending",
+		Metadata:    map[string]string{interpreter.BootTimeKey: fmt.Sprint(previousBootTime.Unix())},
+		Birthdate:   rebootPendingBirthdate.UnixNano(),
+	}
+	fullyManageableEvent := interpreter.Event{
+		Destination: "event:device-status/mac:112233445566/fully-manageable",
+		Metadata:    map[string]string{interpreter.BootTimeKey: fmt.Sprint(currentBootTime.Unix())},
+		Birthdate:   now.UnixNano(),
+	}
+
+	tests := []struct {
+		description         string
+		config              TimeElapsedConfig
+		finderEvent         interpreter.Event
+		expectedTimeElapsed float64
+		expectedErr         error
+	}{
+		{
+			description:         "birthdate to birthdate",
+			config:              TimeElapsedConfig{},
+			finderEvent:         rebootPendingEvent,
+			expectedTimeElapsed: now.Sub(rebootPendingBirthdate).Seconds(),
+		},
+		{
+			description:         "boot-time to birthdate",
+			config:              TimeElapsedConfig{StartTimeSource: "boot-time"},
+			finderEvent:         rebootPendingEvent,
+			expectedTimeElapsed: now.Sub(previousBootTime).Seconds(),
+		},
+		{
+			description:         "birthdate to boot-time",
+			config:              TimeElapsedConfig{StartTimeSource: "birthdate", EndTimeSource: "boot-time"},
+			finderEvent:         rebootPendingEvent,
+			expectedTimeElapsed: currentBootTime.Sub(rebootPendingBirthdate).Seconds(),
+		},
+		{
+			description:         "boot-time to boot-time",
+			config:              TimeElapsedConfig{StartTimeSource: "boot-time", EndTimeSource: "boot-time"},
+			finderEvent:         rebootPendingEvent,
+			expectedTimeElapsed: currentBootTime.Sub(previousBootTime).Seconds(),
+		},
+		{
+			description: "missing boot-time",
+			config:      TimeElapsedConfig{StartTimeSource: "boot-time"},
+			finderEvent: interpreter.Event{Birthdate: rebootPendingBirthdate.UnixNano()},
+			expectedErr: errCalculation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			finder := new(mockFinder)
+			finder.On("Find", mock.Anything, mock.Anything).Return(tc.finderEvent, nil)
+			var timeElapsed float64
+			calculator := EventToCurrentCalculator{
+				logger:      zap.NewNop(),
+				eventFinder: finder,
+				successCallback: func(_ interpreter.Event, _ interpreter.Event, duration float64) {
+					timeElapsed = duration
+				},
+				startSource: enums.ParseTimeSource(tc.config.StartTimeSource),
+				endSource:   enums.ParseTimeSource(tc.config.EndTimeSource),
+			}
+
+			err := calculator.Calculate([]interpreter.Event{}, fullyManageableEvent)
+			assert.Equal(tc.expectedErr, err)
+			assert.Equal(tc.expectedTimeElapsed, timeElapsed)
+		})
+	}
+}
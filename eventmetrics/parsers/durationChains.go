@@ -0,0 +1,114 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	chainCalculatorType = "chain"
+
+	chainLegSuffix = "_leg"
+)
+
+var (
+	errShortChain            = errors.New("chain needs at least one event")
+	errMissingChainEventType = errors.New("chain event type cannot be blank")
+)
+
+// ChainEventConfig is an event in the chain of events of a time elapsed calculation with the chain calculator type.
+type ChainEventConfig struct {
+	// Name is the name of the histogram of the time elapsed between this event and the next event in the chain,
+	// or the fully-manageable event if this is the last event.  Like the calculation's name, it is prefixed with
+	// the parser's metric prefix.  Defaults to the calculation's name followed by _leg and the event's position in
+	// the chain, starting at 1.
+	Name string
+
+	// EventType and SessionType determine the event found.  SessionType defaults to the previous session.
+	EventType   string
+	SessionType string
+
+	// TimeSource is the time of the event used, either "birthdate" or "boot-time".  Defaults to "birthdate".
+	TimeSource string
+}
+
+// expandChains replaces each time elapsed calculation with the chain calculator type with the calculations of
+// each leg of its chain, from each event to the next and from the last event to the fully-manageable event, along
+// with the calculation of the total from the first event to the fully-manageable event, which is recorded under
+// the chain calculation's name.  Every leg is calculated from the same history of events.
+func expandChains(configs []TimeElapsedConfig) ([]TimeElapsedConfig, error) {
+	expanded := make([]TimeElapsedConfig, 0, len(configs))
+	for _, config := range configs {
+		if calculatorType(config) != chainCalculatorType {
+			expanded = append(expanded, config)
+			continue
+		}
+
+		if len(config.Chain) == 0 {
+			return nil, fmt.Errorf("%w: %s", errShortChain, config.Name)
+		}
+
+		for i, event := range config.Chain {
+			if len(event.EventType) == 0 {
+				return nil, fmt.Errorf("%w: %s event %d", errMissingChainEventType, config.Name, i+1)
+			}
+
+			leg := TimeElapsedConfig{
+				Name:            chainLegName(config.Name, event, i),
+				EventType:       event.EventType,
+				SessionType:     event.SessionType,
+				StartTimeSource: event.TimeSource,
+				EndTimeSource:   config.EndTimeSource,
+				CalculatorType:  eventToCurrentCalculatorType,
+			}
+
+			if i < len(config.Chain)-1 {
+				next := config.Chain[i+1]
+				leg.CalculatorType = betweenEventsCalculatorType
+				leg.EndEventType = next.EventType
+				leg.EndSessionType = next.SessionType
+				leg.EndTimeSource = next.TimeSource
+			}
+
+			expanded = append(expanded, leg)
+		}
+
+		first := config.Chain[0]
+		expanded = append(expanded, TimeElapsedConfig{
+			Name:            config.Name,
+			EventType:       first.EventType,
+			SessionType:     first.SessionType,
+			StartTimeSource: first.TimeSource,
+			EndTimeSource:   config.EndTimeSource,
+			CalculatorType:  eventToCurrentCalculatorType,
+		})
+	}
+
+	return expanded, nil
+}
+
+// chainLegName returns the name of the leg of the chain starting at the event at index i.
+func chainLegName(name string, event ChainEventConfig, i int) string {
+	if len(event.Name) > 0 {
+		return event.Name
+	}
+
+	return fmt.Sprintf("%s%s%d", name, chainLegSuffix, i+1)
+}
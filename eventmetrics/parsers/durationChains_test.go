@@ -0,0 +1,131 @@
+package parsers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestExpandChains(t *testing.T) {
+	tests := []struct {
+		description string
+		configs     []TimeElapsedConfig
+		expected    []TimeElapsedConfig
+		expectedErr error
+	}{
+		{
+			description: "No chains",
+			configs:     []TimeElapsedConfig{{Name: "test", EventType: "online"}},
+			expected:    []TimeElapsedConfig{{Name: "test", EventType: "online"}},
+		},
+		{
+			description: "Chain",
+			configs: []TimeElapsedConfig{
+				{Name: "other", EventType: "online"},
+				{
+					Name:           "reboot_chain",
+					CalculatorType: "Chain",
+					EndTimeSource:  "birthdate",
+					Chain: []ChainEventConfig{
+						{EventType: "reboot-pending", SessionType: "previous"},
+						{Name: "offline_to_online", EventType: "offline", SessionType: "previous", TimeSource: "birthdate"},
+						{EventType: "online", SessionType: "current", TimeSource: "boot-time"},
+					},
+				},
+			},
+			expected: []TimeElapsedConfig{
+				{Name: "other", EventType: "online"},
+				{Name: "reboot_chain_leg1", EventType: "reboot-pending", SessionType: "previous", CalculatorType: "between-events",
+					EndEventType: "offline", EndSessionType: "previous", EndTimeSource: "birthdate"},
+				{Name: "offline_to_online", EventType: "offline", SessionType: "previous", StartTimeSource: "birthdate",
+					CalculatorType: "between-events", EndEventType: "online", EndSessionType: "current", EndTimeSource: "boot-time"},
+				{Name: "reboot_chain_leg3", EventType: "online", SessionType: "current", StartTimeSource: "boot-time",
+					CalculatorType: "event-to-current", EndTimeSource: "birthdate"},
+				{Name: "reboot_chain", EventType: "reboot-pending", SessionType: "previous", CalculatorType: "event-to-current",
+					EndTimeSource: "birthdate"},
+			},
+		},
+		{
+			description: "Empty chain",
+			configs:     []TimeElapsedConfig{{Name: "reboot_chain", CalculatorType: "chain"}},
+			expectedErr: errShortChain,
+		},
+		{
+			description: "Missing event type",
+			configs: []TimeElapsedConfig{{Name: "reboot_chain", CalculatorType: "chain",
+				Chain: []ChainEventConfig{{EventType: "reboot-pending"}, {SessionType: "current"}}}},
+			expectedErr: errMissingChainEventType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			expanded, err := expandChains(tc.configs)
+			assert.True(errors.Is(err, tc.expectedErr))
+			assert.Equal(tc.expected, expanded)
+		})
+	}
+}
+
+func TestChainCalculators(t *testing.T) {
+	assert := assert.New(t)
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(err)
+	previousBoot := now.Add(-1 * time.Hour)
+	currentBoot := now.Add(-10 * time.Minute)
+	events := []interpreter.Event{
+		sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+		sessionEvent(rebootPendingEventType, previousBoot, now.Add(-15*time.Minute), "2"),
+		sessionEvent(offlineEventType, previousBoot, now.Add(-14*time.Minute), "3"),
+		sessionEvent(onlineEventType, currentBoot, now.Add(-8*time.Minute), "4"),
+	}
+	currentEvent := sessionEvent(fullyManageableEventType, currentBoot, now, "current")
+
+	configs := prefixTimeElapsedConfigs("reboot", []TimeElapsedConfig{
+		{
+			Name:           "chain",
+			CalculatorType: "chain",
+			Chain: []ChainEventConfig{
+				{EventType: "reboot-pending", SessionType: "previous"},
+				{Name: "offline_to_online", EventType: "offline", SessionType: "previous"},
+				{EventType: "online", SessionType: "current"},
+			},
+		},
+	})
+
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+	m := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
+	calculators, err := createDurationCalculators(testFactory, configs, m, RebootParserConfig{}, RebootLoggerIn{Logger: zap.NewNop()})
+	if !assert.Nil(err) || !assert.Len(calculators, 4) {
+		return
+	}
+
+	for _, calculator := range calculators {
+		assert.Nil(calculator.Calculate(events, currentEvent))
+	}
+
+	expected := map[string]float64{
+		"reboot_chain_leg1":        time.Minute.Seconds(),
+		"reboot_offline_to_online": (6 * time.Minute).Seconds(),
+		"reboot_chain_leg3":        (8 * time.Minute).Seconds(),
+		"reboot_chain":             (15 * time.Minute).Seconds(),
+	}
+	for _, calculator := range calculators {
+		name := calculatorName(calculator)
+		seconds, found := expected[name]
+		if assert.True(found, name) {
+			assert.Equal(seconds, calculator.(durationAnalyzer).analyzeDuration(events, currentEvent).Seconds, name)
+		}
+		assert.Equal(1, testutil.CollectAndCount(registry, name), name)
+	}
+}
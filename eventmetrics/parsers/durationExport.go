@@ -0,0 +1,90 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"time"
+
+	"github.com/xmidt-org/bascule/basculechecks"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+)
+
+// DurationRecord describes a duration calculated by the reboot duration parser, for analysis outside of prometheus.
+type DurationRecord struct {
+	DeviceID        string    `json:"deviceID"`
+	TransactionUUID string    `json:"transactionUUID"`
+	Firmware        string    `json:"firmware"`
+	Hardware        string    `json:"hardware"`
+	RebootReason    string    `json:"rebootReason"`
+	PartnerID       string    `json:"partnerID"`
+	DurationType    string    `json:"durationType"`
+	Seconds         float64   `json:"seconds"`
+	BootTime        time.Time `json:"bootTime"`
+	Birthdate       time.Time `json:"birthdate"`
+
+	// StartingEventID is the transaction uuid of the event the duration started at, if the duration was
+	// calculated from another event.
+	StartingEventID string `json:"startingEventID,omitempty"`
+}
+
+// DurationExporter publishes the durations calculated by the reboot duration parser, in addition to their
+// observation in prometheus.  Export must not block the parser.
+type DurationExporter interface {
+	Export(record DurationRecord)
+}
+
+// newDurationRecord creates the record of the duration calculated for the event.
+func newDurationRecord(durationType string, seconds float64, event interpreter.Event) DurationRecord {
+	deviceID, _ := deviceid.FromEvent(event)
+	bootTime, _ := event.BootTime()
+	labels := getTimeElapsedHistogramLabels(event)
+	record := DurationRecord{
+		DeviceID:        deviceID,
+		TransactionUUID: event.TransactionUUID,
+		Firmware:        labels[firmwareLabel],
+		Hardware:        labels[hardwareLabel],
+		RebootReason:    labels[rebootReasonLabel],
+		PartnerID:       basculechecks.DeterminePartnerMetric(event.PartnerIDs),
+		DurationType:    durationType,
+		Seconds:         seconds,
+		Birthdate:       time.Unix(0, event.Birthdate).UTC(),
+	}
+
+	if bootTime > 0 {
+		record.BootTime = time.Unix(bootTime, 0).UTC()
+	}
+
+	return record
+}
+
+// exportDuration publishes the duration calculated for the event to each of the duration exporters configured.
+func (m Measures) exportDuration(durationType string, seconds float64, event interpreter.Event, startingEvent *interpreter.Event) {
+	if len(m.DurationExporters) == 0 {
+		return
+	}
+
+	record := newDurationRecord(durationType, seconds, event)
+	if startingEvent != nil {
+		record.StartingEventID = startingEvent.TransactionUUID
+	}
+
+	for _, exporter := range m.DurationExporters {
+		exporter.Export(record)
+	}
+}
@@ -0,0 +1,114 @@
+package parsers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestNewDurationRecord(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	bootTime := now.Add(-5 * time.Minute)
+
+	tests := []struct {
+		description    string
+		event          interpreter.Event
+		expectedRecord DurationRecord
+	}{
+		{
+			description: "All fields",
+			event: interpreter.Event{
+				Destination:     "event:device-status/mac:112233445566/fully-manageable",
+				TransactionUUID: "test-uuid",
+				PartnerIDs:      []string{"comcast"},
+				Birthdate:       now.UnixNano(),
+				Metadata: map[string]string{
+					interpreter.BootTimeKey: "1614707700",
+					firmwareMetadataKey:     "fw",
+					hardwareMetadataKey:     "hw",
+					rebootReasonMetadataKey: "reason",
+				},
+			},
+			expectedRecord: DurationRecord{
+				DeviceID:        "mac:112233445566",
+				TransactionUUID: "test-uuid",
+				Firmware:        "fw",
+				Hardware:        "hw",
+				RebootReason:    "reason",
+				PartnerID:       "comcast",
+				DurationType:    "test",
+				Seconds:         300,
+				BootTime:        bootTime.UTC(),
+				Birthdate:       now.UTC(),
+			},
+		},
+		{
+			description: "Missing fields",
+			event: interpreter.Event{
+				Destination: "event:device-status/mac:112233445566/fully-manageable",
+				Birthdate:   now.UnixNano(),
+			},
+			expectedRecord: DurationRecord{
+				DeviceID:     "mac:112233445566",
+				Firmware:     unknownLabelValue,
+				Hardware:     unknownLabelValue,
+				RebootReason: unknownLabelValue,
+				PartnerID:    "none",
+				DurationType: "test",
+				Seconds:      300,
+				Birthdate:    now.UTC(),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedRecord, newDurationRecord("test", 300, tc.event))
+		})
+	}
+}
+
+func TestExportDuration(t *testing.T) {
+	assert := assert.New(t)
+	event := interpreter.Event{
+		Destination:     "event:device-status/mac:112233445566/fully-manageable",
+		TransactionUUID: "current",
+	}
+	startingEvent := interpreter.Event{TransactionUUID: "starting"}
+
+	// no exporter configured.
+	Measures{}.exportDuration("test", 5, event, nil)
+
+	exporter := new(mockDurationExporter)
+	m := Measures{
+		BootToManageableHistogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "boot_to_manageable"}, []string{firmwareLabel, hardwareLabel, rebootReasonLabel}),
+		TimeElapsedHistograms: map[string]prometheus.ObserverVec{
+			"test_reboot_to_manageable": prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_reboot_to_manageable"}, []string{firmwareLabel, hardwareLabel, rebootReasonLabel}),
+		},
+		DurationExporters: []DurationExporter{exporter},
+	}
+
+	bootRecord := newDurationRecord("test_boot_to_manageable", 5, event)
+	rebootRecord := newDurationRecord("test_reboot_to_manageable", 10, event)
+	rebootRecord.StartingEventID = "starting"
+	exporter.On("Export", bootRecord).Once()
+	exporter.On("Export", rebootRecord).Once()
+
+	bootCallback, err := createBootDurationCallback(m, RebootParserConfig{MetricPrefix: "test"})
+	if !assert.Nil(err) {
+		return
+	}
+	bootCallback(event, 5)
+
+	rebootCallback, err := createTimeElapsedCallback(m, "test_reboot_to_manageable", nil)
+	if !assert.Nil(err) {
+		return
+	}
+	rebootCallback(event, startingEvent, 10)
+
+	exporter.AssertExpectations(t)
+}
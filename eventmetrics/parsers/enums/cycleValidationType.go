@@ -11,6 +11,7 @@ const (
 	SessionOnlineValidation
 	SessionOfflineValidation
 	EventOrderValidation
+	MonotonicBootTimeValidation
 )
 
 const (
@@ -20,6 +21,7 @@ const (
 	SessionOnlineValidationStr       = "session-online"
 	SessionOfflineValidationStr      = "session-offline"
 	EventOrderValidationStr          = "event-order"
+	MonotonicBootTimeValidationStr   = "monotonic-boot-time"
 )
 
 func (v *CycleValidationType) UnmarshalText(text []byte) error {
@@ -34,6 +36,8 @@ func (v *CycleValidationType) UnmarshalText(text []byte) error {
 		*v = SessionOfflineValidation
 	case EventOrderValidationStr:
 		*v = EventOrderValidation
+	case MonotonicBootTimeValidationStr:
+		*v = MonotonicBootTimeValidation
 	default:
 		*v = UnknownCycleValidation
 	}
@@ -53,6 +57,8 @@ func (v CycleValidationType) String() string {
 		return SessionOfflineValidationStr
 	case EventOrderValidation:
 		return EventOrderValidationStr
+	case MonotonicBootTimeValidation:
+		return MonotonicBootTimeValidationStr
 	}
 
 	return UnknownCycleValidationStr
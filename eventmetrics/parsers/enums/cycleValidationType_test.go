@@ -41,6 +41,10 @@ func TestCycleValidationUnmarshalText(t *testing.T) {
 			key:          ConsistentMetadataValidationStr,
 			expectedType: ConsistentMetadataValidation,
 		},
+		{
+			key:          MonotonicBootTimeValidationStr,
+			expectedType: MonotonicBootTimeValidation,
+		},
 		{
 			key:          "abc-random-efg",
 			expectedType: UnknownCycleValidation,
@@ -12,6 +12,7 @@ const (
 	BirthdateAlignmentValidation
 	ValidEventTypeValidation
 	ConsistentDeviceIDValidation
+	RuleValidation
 )
 
 const (
@@ -22,6 +23,7 @@ const (
 	BirthdateAlignmentValidationStr = "birthdate-alignment"
 	ValidEventTypeValidationStr     = "valid-event-type"
 	ConsistentDeviceIDValidationStr = "consistent-device-id"
+	RuleValidationStr               = "rule"
 )
 
 func (v *EventValidationType) UnmarshalText(text []byte) error {
@@ -38,6 +40,8 @@ func (v *EventValidationType) UnmarshalText(text []byte) error {
 		*v = ValidEventTypeValidation
 	case ConsistentDeviceIDValidationStr:
 		*v = ConsistentDeviceIDValidation
+	case RuleValidationStr:
+		*v = RuleValidation
 	default:
 		*v = UnknownEventValidation
 	}
@@ -59,6 +63,8 @@ func (v EventValidationType) String() string {
 		return ValidEventTypeValidationStr
 	case ConsistentDeviceIDValidation:
 		return ConsistentDeviceIDValidationStr
+	case RuleValidation:
+		return RuleValidationStr
 	}
 
 	return UnknownEventValidationStr
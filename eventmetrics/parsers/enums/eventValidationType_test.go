@@ -41,6 +41,10 @@ func TestEventValidationUnmarshalText(t *testing.T) {
 			key:          BootTimeValidationStr,
 			expectedType: BootTimeValidation,
 		},
+		{
+			key:          RuleValidationStr,
+			expectedType: RuleValidation,
+		},
 		{
 			key:          "abc-random-efg",
 			expectedType: UnknownEventValidation,
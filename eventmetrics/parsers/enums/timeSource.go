@@ -0,0 +1,44 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package enums
+
+import "strings"
+
+// TimeSource is an enum to determine which time of an event is used when calculating durations.
+type TimeSource int
+
+const (
+	BirthdateSource TimeSource = iota
+	BootTimeSource
+)
+
+var (
+	timeSourceUnmarshal = map[string]TimeSource{
+		"birthdate": BirthdateSource,
+		"boot-time": BootTimeSource,
+	}
+)
+
+// ParseTimeSource returns the TimeSource enum when given a string.
+func ParseTimeSource(source string) TimeSource {
+	source = strings.ToLower(source)
+	if value, ok := timeSourceUnmarshal[source]; ok {
+		return value
+	}
+	return BirthdateSource
+}
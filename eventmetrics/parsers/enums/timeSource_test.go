@@ -0,0 +1,39 @@
+package enums
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeSource(t *testing.T) {
+	tests := []struct {
+		testStr      string
+		expectedType TimeSource
+	}{
+		{
+			testStr:      "birthdate",
+			expectedType: BirthdateSource,
+		},
+		{
+			testStr:      "boot-time",
+			expectedType: BootTimeSource,
+		},
+		{
+			testStr:      "Boot-Time",
+			expectedType: BootTimeSource,
+		},
+		{
+			testStr:      "random",
+			expectedType: BirthdateSource,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testStr, func(t *testing.T) {
+			assert := assert.New(t)
+			res := ParseTimeSource(tc.testStr)
+			assert.Equal(tc.expectedType, res)
+		})
+	}
+}
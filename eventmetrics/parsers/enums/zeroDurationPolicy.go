@@ -0,0 +1,44 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package enums
+
+import "strings"
+
+// ZeroDurationPolicy is an enum to determine how a duration calculated as exactly zero is handled.
+type ZeroDurationPolicy int
+
+const (
+	RejectZeroDuration ZeroDurationPolicy = iota
+	RecordZeroDuration
+)
+
+var (
+	zeroDurationPolicyUnmarshal = map[string]ZeroDurationPolicy{
+		"reject": RejectZeroDuration,
+		"record": RecordZeroDuration,
+	}
+)
+
+// ParseZeroDurationPolicy returns the ZeroDurationPolicy enum when given a string.
+func ParseZeroDurationPolicy(policy string) ZeroDurationPolicy {
+	policy = strings.ToLower(policy)
+	if value, ok := zeroDurationPolicyUnmarshal[policy]; ok {
+		return value
+	}
+	return RejectZeroDuration
+}
@@ -0,0 +1,39 @@
+package enums
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseZeroDurationPolicy(t *testing.T) {
+	tests := []struct {
+		testStr      string
+		expectedType ZeroDurationPolicy
+	}{
+		{
+			testStr:      "reject",
+			expectedType: RejectZeroDuration,
+		},
+		{
+			testStr:      "record",
+			expectedType: RecordZeroDuration,
+		},
+		{
+			testStr:      "Record",
+			expectedType: RecordZeroDuration,
+		},
+		{
+			testStr:      "random",
+			expectedType: RejectZeroDuration,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testStr, func(t *testing.T) {
+			assert := assert.New(t)
+			res := ParseZeroDurationPolicy(tc.testStr)
+			assert.Equal(tc.expectedType, res)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	deviceIDExemplarLabel        = "device_id"
+	transactionUUIDExemplarLabel = "transaction_uuid"
+
+	// maxExemplarValueRunes bounds each exemplar label value so that the exemplar's labels stay under
+	// prometheus.ExemplarMaxRunes.
+	maxExemplarValueRunes = 48
+)
+
+// ExemplarsConfig configures attaching exemplars to the duration histogram observations, linking each
+// observation to the device and event it was calculated from.  Exemplars are only exposed when metrics are
+// scraped using the OpenMetrics format.
+type ExemplarsConfig struct {
+	// Enabled turns on attaching the device id and transaction uuid of the triggering event as exemplars.
+	Enabled bool
+}
+
+// durationExemplar returns the exemplar labels identifying the event, truncating long values.
+func durationExemplar(event interpreter.Event) prometheus.Labels {
+	deviceID, _ := deviceid.FromEvent(event)
+	return prometheus.Labels{
+		deviceIDExemplarLabel:        truncateRunes(deviceID, maxExemplarValueRunes),
+		transactionUUIDExemplarLabel: truncateRunes(event.TransactionUUID, maxExemplarValueRunes),
+	}
+}
+
+// observeDuration adds the duration to the histogram with the labels given, attaching the event's exemplar if
+// exemplars are enabled and supported by the histogram.
+func observeDuration(histogram prometheus.ObserverVec, duration float64, labels prometheus.Labels, event interpreter.Event, exemplars bool) {
+	if histogram == nil {
+		return
+	}
+
+	observer := histogram.With(labels)
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && exemplars {
+		exemplarObserver.ObserveWithExemplar(duration, durationExemplar(event))
+		return
+	}
+
+	observer.Observe(duration)
+}
+
+// addDuration adds the duration to the histogram, attaching the event's exemplar if exemplars are enabled.
+func (m Measures) addDuration(histogram prometheus.ObserverVec, duration float64, event interpreter.Event) {
+	m.addPartnerDuration(histogram, duration, event, nil)
+}
+
+// addPartnerDuration adds the duration to the histogram, labeling it with the event's partner if partners is
+// non-nil.
+func (m Measures) addPartnerDuration(histogram prometheus.ObserverVec, duration float64, event interpreter.Event, partners durationPartners) {
+	observeDuration(histogram, duration, partners.labels(event), event, m.DurationExemplars)
+}
+
+func truncateRunes(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+
+	return string(runes[:max])
+}
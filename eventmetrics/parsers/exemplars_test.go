@@ -0,0 +1,94 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestObserveDuration(t *testing.T) {
+	longID := strings.Repeat("a", 100)
+	tests := []struct {
+		description      string
+		event            interpreter.Event
+		exemplars        bool
+		expectedExemplar map[string]string
+	}{
+		{
+			description: "Exemplar",
+			event: interpreter.Event{
+				Destination:     "event:device-status/mac:112233445566/fully-manageable",
+				TransactionUUID: "abc123",
+			},
+			exemplars: true,
+			expectedExemplar: map[string]string{
+				deviceIDExemplarLabel:        "mac:112233445566",
+				transactionUUIDExemplarLabel: "abc123",
+			},
+		},
+		{
+			description: "Truncated exemplar",
+			event: interpreter.Event{
+				Destination:     "event:device-status/mac:112233445566/fully-manageable",
+				TransactionUUID: longID,
+			},
+			exemplars: true,
+			expectedExemplar: map[string]string{
+				deviceIDExemplarLabel:        "mac:112233445566",
+				transactionUUIDExemplarLabel: longID[:maxExemplarValueRunes],
+			},
+		},
+		{
+			description: "Exemplars disabled",
+			event: interpreter.Event{
+				Destination:     "event:device-status/mac:112233445566/fully-manageable",
+				TransactionUUID: "abc123",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "testDuration",
+				Help:    "testDuration",
+				Buckets: []float64{60, 120},
+			}, []string{firmwareLabel, hardwareLabel, rebootReasonLabel})
+			Measures{DurationExemplars: tc.exemplars}.addDuration(histogram, 90, tc.event)
+
+			metric := &dto.Metric{}
+			observer := histogram.With(getTimeElapsedHistogramLabels(tc.event))
+			assert.Nil(observer.(prometheus.Metric).Write(metric))
+			assert.Equal(uint64(1), metric.GetHistogram().GetSampleCount())
+
+			buckets := metric.GetHistogram().GetBucket()
+			if !assert.Len(buckets, 2) {
+				return
+			}
+
+			exemplar := buckets[1].GetExemplar()
+			if tc.expectedExemplar == nil {
+				assert.Nil(exemplar)
+				return
+			}
+
+			labels := make(map[string]string)
+			for _, label := range exemplar.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			assert.Equal(tc.expectedExemplar, labels)
+			assert.Equal(90.0, exemplar.GetValue())
+		})
+	}
+}
+
+func TestAddDurationNilHistogram(t *testing.T) {
+	assert.NotPanics(t, func() {
+		AddDuration(nil, 90, interpreter.Event{})
+	})
+}
@@ -0,0 +1,107 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+const (
+	rebootPendingFinderName = "reboot_pending"
+
+	// the reasons a finder selects an event.
+	previousSessionSelectionReason = "earliest_valid_event_in_previous_session"
+	currentSessionSelectionReason  = "earliest_valid_event_in_current_session"
+	bootTimeSelectionReason        = "previous_session_boot_time"
+)
+
+// FinderDiagnosticsConfig configures logging why a parser's finders selected the events they returned, to help
+// investigate durations calculated from the wrong comparison event.
+type FinderDiagnosticsConfig struct {
+	// Enabled turns on logging the selection reason each time a finder selects an event.
+	Enabled bool
+
+	// Finders restricts the diagnostics to the named finders.  A time elapsed calculation's finder is named
+	// after the calculation, and the reboot duration parser's reboot-pending finder is named reboot_pending.
+	// If this is empty, the diagnostics are logged for all of the parser's finders.
+	Finders []string
+}
+
+// explainingFinder logs the reason its finder selected an event.
+type explainingFinder struct {
+	finder Finder
+	name   string
+	reason string
+	logger *zap.Logger
+}
+
+// explainFinder wraps the finder so that it logs the reason it selected an event if diagnostics are enabled for it.
+// Otherwise, the finder is returned as is so that there is no overhead.
+func explainFinder(config FinderDiagnosticsConfig, finder Finder, name string, reason string, logger *zap.Logger) Finder {
+	if !config.Enabled || finder == nil || !config.includes(name) {
+		return finder
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return explainingFinder{
+		finder: finder,
+		name:   name,
+		reason: reason,
+		logger: logger,
+	}
+}
+
+// Find implements the Finder interface.
+func (f explainingFinder) Find(events []interpreter.Event, incomingEvent interpreter.Event) (interpreter.Event, error) {
+	event, err := f.finder.Find(events, incomingEvent)
+	if err != nil {
+		return event, err
+	}
+
+	deviceID, _ := deviceid.FromEvent(incomingEvent)
+	bootTime, _ := event.BootTime()
+	f.logger.Info("finder selected event",
+		zap.String("finder", f.name),
+		zap.String("reason", f.reason),
+		zap.String("deviceID", deviceID),
+		zap.String("incoming event", incomingEvent.TransactionUUID),
+		zap.String("selected event", event.TransactionUUID),
+		zap.String("selected destination", event.Destination),
+		zap.Int64("selected boot-time", bootTime),
+		zap.Int64("selected birthdate", event.Birthdate))
+	return event, nil
+}
+
+func (c FinderDiagnosticsConfig) includes(name string) bool {
+	if len(c.Finders) == 0 {
+		return true
+	}
+
+	for _, finder := range c.Finders {
+		if finder == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExplainFinder(t *testing.T) {
+	finder := new(mockFinder)
+	tests := []struct {
+		description string
+		config      FinderDiagnosticsConfig
+		wrapped     bool
+	}{
+		{
+			description: "Disabled",
+			config:      FinderDiagnosticsConfig{Finders: []string{"test"}},
+		},
+		{
+			description: "Enabled for all finders",
+			config:      FinderDiagnosticsConfig{Enabled: true},
+			wrapped:     true,
+		},
+		{
+			description: "Enabled for finder",
+			config:      FinderDiagnosticsConfig{Enabled: true, Finders: []string{"other", "test"}},
+			wrapped:     true,
+		},
+		{
+			description: "Enabled for other finders",
+			config:      FinderDiagnosticsConfig{Enabled: true, Finders: []string{"other"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			explained := explainFinder(tc.config, finder, "test", currentSessionSelectionReason, nil)
+			if tc.wrapped {
+				assert.IsType(explainingFinder{}, explained)
+			} else {
+				assert.Equal(finder, explained)
+			}
+		})
+	}
+}
+
+func TestExplainingFinderFind(t *testing.T) {
+	now := time.Now()
+	event := func(eventType string, id string, bootTime time.Time, birthdate time.Time) interpreter.Event {
+		return interpreter.Event{
+			Destination:     fmt.Sprintf("event:device-status/mac:112233445566/%s", eventType),
+			TransactionUUID: id,
+			Birthdate:       birthdate.UnixNano(),
+			Metadata: map[string]string{
+				interpreter.BootTimeKey: fmt.Sprint(bootTime.Unix()),
+			},
+		}
+	}
+
+	previousBootTime := now.Add(-2 * time.Hour)
+	currentBootTime := now.Add(-1 * time.Minute)
+	events := []interpreter.Event{
+		event("reboot-pending", "previous-reboot-pending", previousBootTime, now.Add(-5*time.Minute)),
+		event("online", "current-online", currentBootTime, now.Add(-40*time.Second)),
+		event("online", "current-online-later", currentBootTime, now.Add(-20*time.Second)),
+	}
+	incoming := event("fully-manageable", "incoming", currentBootTime, now)
+
+	tests := []struct {
+		description    string
+		finder         Finder
+		reason         string
+		expectedEvent  string
+		expectedErr    error
+		expectedLogged bool
+	}{
+		{
+			description:    "Current session",
+			finder:         history.CurrentSessionFinder(validation.DestinationValidator("online")),
+			reason:         currentSessionSelectionReason,
+			expectedEvent:  "current-online",
+			expectedLogged: true,
+		},
+		{
+			description:    "Previous session",
+			finder:         history.LastSessionFinder(validation.DestinationValidator(rebootPendingEventType)),
+			reason:         previousSessionSelectionReason,
+			expectedEvent:  "previous-reboot-pending",
+			expectedLogged: true,
+		},
+		{
+			description: "Not found",
+			finder:      history.LastSessionFinder(validation.DestinationValidator("online")),
+			reason:      previousSessionSelectionReason,
+			expectedErr: history.EventNotFoundErr,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			core, logs := observer.New(zapcore.InfoLevel)
+			finder := explainFinder(FinderDiagnosticsConfig{Enabled: true}, tc.finder, "test", tc.reason, zap.New(core))
+			found, err := finder.Find(events, incoming)
+			assert.True(errors.Is(err, tc.expectedErr))
+			assert.Equal(tc.expectedEvent, found.TransactionUUID)
+
+			selected := logs.FilterMessage("finder selected event")
+			if !tc.expectedLogged {
+				assert.Zero(selected.Len())
+				return
+			}
+
+			if assert.Equal(1, selected.Len()) {
+				fields := selected.All()[0].ContextMap()
+				assert.Equal("test", fields["finder"])
+				assert.Equal(tc.reason, fields["reason"])
+				assert.Equal("incoming", fields["incoming event"])
+				assert.Equal(tc.expectedEvent, fields["selected event"])
+			}
+		})
+	}
+}
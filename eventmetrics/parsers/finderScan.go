@@ -0,0 +1,95 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+const (
+	fullyManageableFinderName = "fully_manageable"
+)
+
+// FinderScansConfig configures timing how long finders take to search through a device's history of events,
+// to surface the devices and histories that make parsing slow.
+type FinderScansConfig struct {
+	// Enabled turns on the finder_scan_seconds histogram, labeled by parser and finder.
+	Enabled bool
+
+	// SlowThreshold is how long a scan can take before the device id and number of events scanned are logged.
+	// If this is 0, slow scans are not logged.
+	SlowThreshold time.Duration
+}
+
+// timedFinder records how long its finder takes to find an event.
+type timedFinder struct {
+	finder        Finder
+	name          string
+	parser        string
+	observer      prometheus.Observer
+	slowThreshold time.Duration
+	current       func() time.Time
+	logger        *zap.Logger
+}
+
+// timeFinder wraps the finder so that its scans are timed if the finder_scan_seconds histogram exists.
+// Otherwise, the finder is returned as is so that there is no overhead.
+func (m Measures) timeFinder(finder Finder, parser string, name string, logger *zap.Logger) Finder {
+	if m.FinderScanSeconds == nil || finder == nil {
+		return finder
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return timedFinder{
+		finder:        finder,
+		name:          name,
+		parser:        parser,
+		observer:      m.FinderScanSeconds.With(prometheus.Labels{parserLabel: parser, finderLabel: name}),
+		slowThreshold: m.FinderScanSlowThreshold,
+		current:       time.Now,
+		logger:        logger,
+	}
+}
+
+// Find implements the Finder interface.
+func (f timedFinder) Find(events []interpreter.Event, incomingEvent interpreter.Event) (interpreter.Event, error) {
+	begin := f.current()
+	event, err := f.finder.Find(events, incomingEvent)
+	elapsed := f.current().Sub(begin)
+	f.observer.Observe(elapsed.Seconds())
+
+	if f.slowThreshold > 0 && elapsed > f.slowThreshold {
+		deviceID, _ := deviceid.FromEvent(incomingEvent)
+		f.logger.Warn("slow finder scan",
+			zap.String("parser", f.parser),
+			zap.String("finder", f.name),
+			zap.String("deviceID", deviceID),
+			zap.Int("event count", len(events)),
+			zap.Duration("scan time", elapsed))
+	}
+
+	return event, err
+}
@@ -0,0 +1,98 @@
+package parsers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTimeFinder(t *testing.T) {
+	assert := assert.New(t)
+	finder := new(mockFinder)
+	assert.Equal(finder, Measures{}.timeFinder(finder, "parser", "finder", nil))
+
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "testFinderScan",
+		Help: "testFinderScan",
+	}, []string{parserLabel, finderLabel})
+	assert.IsType(timedFinder{}, Measures{FinderScanSeconds: histogram}.timeFinder(finder, "parser", "finder", nil))
+}
+
+func TestTimedFinderFind(t *testing.T) {
+	tests := []struct {
+		description    string
+		scanTime       time.Duration
+		slowThreshold  time.Duration
+		expectedLogged bool
+	}{
+		{
+			description:   "Fast scan",
+			scanTime:      time.Millisecond,
+			slowThreshold: 100 * time.Millisecond,
+		},
+		{
+			description:    "Slow scan",
+			scanTime:       time.Second,
+			slowThreshold:  100 * time.Millisecond,
+			expectedLogged: true,
+		},
+		{
+			description: "No threshold",
+			scanTime:    time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			events := []interpreter.Event{{TransactionUUID: "1"}, {TransactionUUID: "2"}}
+			incoming := interpreter.Event{Destination: "event:device-status/mac:112233445566/fully-manageable"}
+			finder := new(mockFinder)
+			finder.On("Find", mock.Anything, mock.Anything).Return(events[0], nil)
+			histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name: "testFinderScan",
+				Help: "testFinderScan",
+			}, []string{parserLabel, finderLabel})
+			core, logs := observer.New(zapcore.InfoLevel)
+			measures := Measures{FinderScanSeconds: histogram, FinderScanSlowThreshold: tc.slowThreshold}
+
+			timed := measures.timeFinder(finder, "parser", "finder", zap.New(core)).(timedFinder)
+			now := time.Now()
+			timed.current = func() time.Time {
+				current := now
+				now = now.Add(tc.scanTime)
+				return current
+			}
+
+			found, err := timed.Find(events, incoming)
+			assert.Nil(err)
+			assert.Equal(events[0], found)
+
+			metric := &dto.Metric{}
+			assert.Nil(histogram.WithLabelValues("parser", "finder").(prometheus.Metric).Write(metric))
+			assert.Equal(uint64(1), metric.GetHistogram().GetSampleCount())
+			assert.Equal(tc.scanTime.Seconds(), metric.GetHistogram().GetSampleSum())
+
+			slow := logs.FilterMessage("slow finder scan")
+			if !tc.expectedLogged {
+				assert.Zero(slow.Len())
+				return
+			}
+
+			if assert.Equal(1, slow.Len()) {
+				fields := slow.All()[0].ContextMap()
+				assert.Equal("mac:112233445566", fields["deviceID"])
+				assert.Equal(int64(len(events)), fields["event count"])
+				assert.Equal(tc.scanTime, fields["scan time"])
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+const (
+	firstOnlineParserName = "first_online_duration_parser"
+	onlineFinderName      = "online"
+)
+
+// FirstOnlineConfig configures the first online parser.
+type FirstOnlineConfig struct {
+	// Enabled turns on the first online parser.
+	Enabled bool
+
+	// MetricPrefix is prepended, followed by an underscore, to the name of the first online histogram.
+	MetricPrefix string
+
+	// NativeHistograms configures exposing the first online histogram as a native histogram.
+	NativeHistograms NativeHistogramConfig
+}
+
+// FirstOnlineParser is triggered by online events and records the time between the reboot-pending event of the
+// previous session and the first online event of the new session, measuring how long a reboot takes until the
+// device is back online rather than until it is fully-manageable.
+type FirstOnlineParser struct {
+	name                string
+	rebootPendingFinder Finder
+	onlineFinder        Finder
+	client              EventClient
+	histogram           prometheus.ObserverVec
+	measures            Measures
+	logger              *zap.Logger
+}
+
+// NewFirstOnlineParser creates a new FirstOnlineParser.
+func NewFirstOnlineParser(client EventClient, histogram prometheus.ObserverVec, measures Measures, logger *zap.Logger) (*FirstOnlineParser, error) {
+	if client == nil {
+		return nil, errNilEventClient
+	}
+
+	if histogram == nil {
+		return nil, errNilHistogram
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &FirstOnlineParser{
+		name:                firstOnlineParserName,
+		rebootPendingFinder: measures.timeFinder(history.LastSessionFinder(validation.DestinationValidator(rebootPendingEventType)), firstOnlineParserName, rebootPendingFinderName, logger),
+		onlineFinder:        measures.timeFinder(history.CurrentSessionFinder(validation.DestinationValidator(onlineEventType)), firstOnlineParserName, onlineFinderName, logger),
+		client:              client,
+		histogram:           histogram,
+		measures:            measures,
+		logger:              logger,
+	}, nil
+}
+
+// Name implements the Parser interface.
+func (p *FirstOnlineParser) Name() string {
+	return p.name
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p *FirstOnlineParser) EventTypeRegexes() []string {
+	return []string{"^" + onlineEventType + "$"}
+}
+
+// Parse records the time between the reboot-pending event of the previous session and the online event if it is
+// the first online event of the session.
+func (p *FirstOnlineParser) Parse(ctx context.Context, currentEvent interpreter.Event) {
+	p.parse(ctx, currentEvent, p.client)
+}
+
+// ParseBatch implements the queue.BatchParser interface, parsing each event in the batch while only getting
+// the history of events from codex once for each device in the batch.
+func (p *FirstOnlineParser) ParseBatch(ctx context.Context, events []interpreter.Event) {
+	client := newBatchEventClient(p.client)
+	client.GetEventsBatch(ctx, batchDeviceIDs(events, onlineEventType))
+	for _, event := range events {
+		p.parse(ctx, event, client)
+	}
+}
+
+func (p *FirstOnlineParser) parse(ctx context.Context, currentEvent interpreter.Event, client EventClient) {
+	eventType, err := currentEvent.EventType()
+	if err != nil || eventType != onlineEventType {
+		return
+	}
+
+	deviceID, err := deviceid.FromEvent(currentEvent)
+	if err != nil {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	if bootTime, err := currentEvent.BootTime(); err != nil || bootTime <= 0 {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	events := client.GetEvents(ctx, deviceID)
+
+	// only the first online event of the session is recorded.
+	if previous, err := p.onlineFinder.Find(events, currentEvent); err == nil && previous.Birthdate < currentEvent.Birthdate {
+		p.logger.Debug("not the first online event of the session", zap.String("device id", deviceID), zap.String("first event", previous.TransactionUUID))
+		return
+	}
+
+	// without a reboot-pending event in the previous session, the device didn't reboot.
+	rebootPending, err := p.rebootPendingFinder.Find(events, currentEvent)
+	if err != nil {
+		return
+	}
+
+	duration := time.Unix(0, currentEvent.Birthdate).Sub(time.Unix(0, rebootPending.Birthdate)).Seconds()
+	if currentEvent.Birthdate <= 0 || rebootPending.Birthdate <= 0 || duration <= 0 {
+		p.logger.Error("invalid first online duration calculated", zap.String("device id", deviceID), zap.Float64("duration", duration),
+			zap.String("reboot-pending event", rebootPending.TransactionUUID))
+		p.addToUnparsableCounters(currentEvent, errCalculation, rebootPending)
+		return
+	}
+
+	p.measures.addDuration(p.histogram, duration, currentEvent)
+}
+
+func (p *FirstOnlineParser) addToUnparsableCounters(event interpreter.Event, err error, compared ...interpreter.Event) {
+	p.measures.AddTotalUnparsable(p.name)
+	p.measures.AddUnparsableEventType(p.name, err, event)
+	p.measures.AddUnparsableRatio(event)
+	p.measures.LogUnparsable(p.name, err, event, compared)
+}
+
+// createFirstOnlineParsers creates the first online parser if it is enabled.
+func createFirstOnlineParsers(f *touchstone.Factory, config FirstOnlineConfig, client events.EventSource, measures Measures, logger *zap.Logger) ([]queue.Parser, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if f == nil {
+		return nil, errNilFactory
+	}
+
+	opts := config.NativeHistograms.apply(prometheus.HistogramOpts{
+		Name:        metricName(config.MetricPrefix, "reboot_to_first_online"),
+		Help:        "time elapsed between a reboot-pending event and the first online event of the new session in s",
+		Buckets:     []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 900, 1200, 1500, 1800, 3600, 7200, 14400, 21600},
+		ConstLabels: measures.ConfigVariantLabels,
+	})
+	histogram, err := f.NewHistogramVec(opts, firmwareLabel, hardwareLabel, rebootReasonLabel)
+	if err != nil {
+		return nil, err
+	}
+	measures.recordBuckets(opts)
+
+	parser, err := NewFirstOnlineParser(parserEventClient(client, firstOnlineParserName, sessionHistory), histogram, measures, logger.With(zap.String("parser", firstOnlineParserName)))
+	if err != nil {
+		return nil, err
+	}
+
+	return []queue.Parser{parser}, nil
+}
@@ -0,0 +1,139 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFirstOnlineParse(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	previousBoot := now.Add(-48 * time.Hour)
+	currentBoot := now.Add(-5 * time.Minute)
+	rebootPending := now.Add(-7 * time.Minute)
+	currentEvent := sessionEvent(onlineEventType, currentBoot, now, "current")
+	previousSession := []interpreter.Event{
+		sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+		sessionEvent(rebootPendingEventType, previousBoot, rebootPending, "2"),
+	}
+
+	tests := []struct {
+		description        string
+		event              interpreter.Event
+		history            []interpreter.Event
+		expectedDuration   float64
+		expectedCount      uint64
+		expectedUnparsable float64
+	}{
+		{
+			description:      "Reboot",
+			event:            currentEvent,
+			history:          append(previousSession, currentEvent),
+			expectedDuration: now.Sub(rebootPending).Seconds(),
+			expectedCount:    1,
+		},
+		{
+			description: "Cold boot",
+			event:       currentEvent,
+			history: []interpreter.Event{
+				sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+				currentEvent,
+			},
+		},
+		{
+			description: "Later online event",
+			event:       currentEvent,
+			history: append(previousSession,
+				sessionEvent(onlineEventType, currentBoot, currentBoot.Add(time.Minute), "first"),
+				currentEvent,
+			),
+		},
+		{
+			description:        "Online before reboot-pending",
+			event:              sessionEvent(onlineEventType, currentBoot, rebootPending.Add(-1*time.Minute), "current"),
+			history:            previousSession,
+			expectedUnparsable: 1.0,
+		},
+		{
+			description:        "Missing boot-time",
+			event:              interpreter.Event{Destination: fmt.Sprintf("event:device-status/%s/online", testUptimeDeviceID)},
+			expectedUnparsable: 1.0,
+		},
+		{
+			description: "Wrong event type",
+			event:       sessionEvent(fullyManageableEventType, currentBoot, now, "current"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", testUptimeDeviceID).Return(tc.history)
+			histogram := newTestUptimeHistogram()
+			unparsable := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testUnparsable",
+				Help: "testUnparsable",
+			}, []string{parserLabel})
+
+			parser, err := NewFirstOnlineParser(client, histogram, Measures{TotalUnparsableCount: unparsable}, zap.NewNop())
+			assert.Nil(err)
+			parser.Parse(context.Background(), tc.event)
+
+			assert.Equal(tc.expectedUnparsable, testutil.ToFloat64(unparsable.WithLabelValues(firstOnlineParserName)))
+			metric := &dto.Metric{}
+			observer := histogram.With(prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason"})
+			assert.Nil(observer.(prometheus.Metric).Write(metric))
+			assert.Equal(tc.expectedCount, metric.GetHistogram().GetSampleCount())
+			assert.Equal(tc.expectedDuration, metric.GetHistogram().GetSampleSum())
+		})
+	}
+}
+
+func TestNewFirstOnlineParser(t *testing.T) {
+	assert := assert.New(t)
+	histogram := newTestUptimeHistogram()
+
+	parser, err := NewFirstOnlineParser(nil, histogram, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilEventClient, err)
+
+	parser, err = NewFirstOnlineParser(new(mockEventClient), nil, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilHistogram, err)
+
+	parser, err = NewFirstOnlineParser(new(mockEventClient), histogram, Measures{}, nil)
+	assert.Nil(err)
+	assert.Equal(firstOnlineParserName, parser.Name())
+	assert.Equal([]string{"^online$"}, parser.EventTypeRegexes())
+}
+
+func TestCreateFirstOnlineParsers(t *testing.T) {
+	assert := assert.New(t)
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+
+	parsers, err := createFirstOnlineParsers(testFactory, FirstOnlineConfig{}, nil, Measures{}, zap.NewNop())
+	assert.Nil(err)
+	assert.Empty(parsers)
+
+	parsers, err = createFirstOnlineParsers(nil, FirstOnlineConfig{Enabled: true}, nil, Measures{}, zap.NewNop())
+	assert.Equal(errNilFactory, err)
+	assert.Empty(parsers)
+
+	buckets := make(map[string]string)
+	parsers, err = createFirstOnlineParsers(testFactory, FirstOnlineConfig{Enabled: true, MetricPrefix: "test"}, nil, Measures{HistogramBuckets: buckets}, zap.NewNop())
+	assert.Equal(errNilEventClient, err)
+	assert.Empty(parsers)
+	assert.Contains(buckets, "test_reboot_to_first_online")
+}
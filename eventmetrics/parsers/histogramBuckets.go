@@ -0,0 +1,116 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const nativeBucketDefinition = "native"
+
+// HistogramBucketsConfig configures checking, at startup, whether the bucket definitions of the duration histograms
+// changed since the last start.  Series of a histogram with different buckets are incompatible with the series
+// already scraped, so a changed histogram should be renamed.
+type HistogramBucketsConfig struct {
+	// StateFile is the file the bucket definitions are stored in between starts.  If this is empty, the check
+	// is skipped.
+	StateFile string
+}
+
+// BucketDefinitionsIn provides the bucket definitions of the duration histograms, keyed by metric name.
+type BucketDefinitionsIn struct {
+	fx.In
+	Definitions map[string]string `name:"histogram_buckets"`
+}
+
+// bucketDefinition describes the buckets of a histogram, which is either "native" for a native histogram
+// or the upper bounds of the classic buckets.
+func bucketDefinition(o prometheus.HistogramOpts) string {
+	if o.NativeHistogramBucketFactor > 1 && len(o.Buckets) == 0 {
+		return nativeBucketDefinition
+	}
+
+	buckets := o.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	bounds := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		bounds[i] = strconv.FormatFloat(bucket, 'g', -1, 64)
+	}
+
+	return strings.Join(bounds, ",")
+}
+
+// recordBuckets records the bucket definition of the histogram so that changes across restarts can be detected.
+func (m *Measures) recordBuckets(o prometheus.HistogramOpts) {
+	if m.HistogramBuckets != nil {
+		m.HistogramBuckets[o.Name] = bucketDefinition(o)
+	}
+}
+
+// checkHistogramBuckets warns about each histogram whose bucket definition differs from the one stored in the
+// state file, then stores the current definitions.  Definitions of histograms that no longer exist are kept, so
+// that a histogram that is disabled and later enabled again is still checked.
+func checkHistogramBuckets(config HistogramBucketsConfig, definitions map[string]string, logger *zap.Logger) error {
+	if len(config.StateFile) == 0 {
+		return nil
+	}
+
+	stored := make(map[string]string)
+	data, err := os.ReadFile(config.StateFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read histogram bucket state file: %w", err)
+	default:
+		if err := json.Unmarshal(data, &stored); err != nil {
+			logger.Warn("ignoring invalid histogram bucket state file", zap.String("file", config.StateFile), zap.Error(err))
+			stored = make(map[string]string)
+		}
+	}
+
+	for name, definition := range definitions {
+		if previous, found := stored[name]; found && previous != definition {
+			logger.Warn("histogram buckets changed since the last start, so its new series are incompatible with the existing series; consider renaming the metric",
+				zap.String("metric", name), zap.String("previous buckets", previous), zap.String("current buckets", definition))
+		}
+		stored[name] = definition
+	}
+
+	if data, err = json.MarshalIndent(stored, "", "  "); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(config.StateFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write histogram bucket state file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package parsers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBucketDefinition(t *testing.T) {
+	tests := []struct {
+		description string
+		opts        prometheus.HistogramOpts
+		expected    string
+	}{
+		{
+			description: "Classic",
+			opts:        prometheus.HistogramOpts{Buckets: []float64{0.5, 60, 120}},
+			expected:    "0.5,60,120",
+		},
+		{
+			description: "Default buckets",
+			expected:    "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10",
+		},
+		{
+			description: "Native",
+			opts:        NativeHistogramConfig{Enabled: true}.apply(bootToManageableOpts),
+			expected:    nativeBucketDefinition,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, bucketDefinition(tc.opts))
+		})
+	}
+}
+
+func TestCheckHistogramBuckets(t *testing.T) {
+	tests := []struct {
+		description     string
+		stored          string
+		definitions     map[string]string
+		expectedWarning bool
+		expectedStored  string
+	}{
+		{
+			description:    "No state file",
+			definitions:    map[string]string{"boot_to_manageable": "60,120"},
+			expectedStored: `{"boot_to_manageable":"60,120"}`,
+		},
+		{
+			description:    "Unchanged",
+			stored:         `{"boot_to_manageable":"60,120"}`,
+			definitions:    map[string]string{"boot_to_manageable": "60,120"},
+			expectedStored: `{"boot_to_manageable":"60,120"}`,
+		},
+		{
+			description:     "Changed",
+			stored:          `{"boot_to_manageable":"60,120"}`,
+			definitions:     map[string]string{"boot_to_manageable": nativeBucketDefinition},
+			expectedWarning: true,
+			expectedStored:  `{"boot_to_manageable":"native"}`,
+		},
+		{
+			description:    "New and removed histograms",
+			stored:         `{"session_uptime":"60,300"}`,
+			definitions:    map[string]string{"boot_to_manageable": "60,120"},
+			expectedStored: `{"boot_to_manageable":"60,120","session_uptime":"60,300"}`,
+		},
+		{
+			description:    "Invalid state file",
+			stored:         "invalid",
+			definitions:    map[string]string{"boot_to_manageable": "60,120"},
+			expectedStored: `{"boot_to_manageable":"60,120"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			file := filepath.Join(t.TempDir(), "buckets.json")
+			if len(tc.stored) > 0 {
+				assert.Nil(os.WriteFile(file, []byte(tc.stored), 0644))
+			}
+
+			core, logs := observer.New(zapcore.WarnLevel)
+			err := checkHistogramBuckets(HistogramBucketsConfig{StateFile: file}, tc.definitions, zap.New(core))
+			assert.Nil(err)
+
+			changed := logs.FilterField(zap.String("metric", "boot_to_manageable"))
+			if tc.expectedWarning {
+				assert.Equal(1, changed.Len())
+			} else {
+				assert.Zero(changed.Len())
+			}
+
+			data, err := os.ReadFile(file)
+			assert.Nil(err)
+			assert.JSONEq(tc.expectedStored, string(data))
+		})
+	}
+}
+
+func TestCheckHistogramBucketsSkipped(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	assert.Nil(checkHistogramBuckets(HistogramBucketsConfig{}, map[string]string{"boot_to_manageable": "60,120"}, zap.NewNop()))
+	entries, err := os.ReadDir(dir)
+	assert.Nil(err)
+	assert.Empty(entries)
+
+	// the state file can't be read from a directory.
+	assert.NotNil(checkHistogramBuckets(HistogramBucketsConfig{StateFile: dir}, nil, zap.NewNop()))
+}
+
+func TestRecordBuckets(t *testing.T) {
+	assert := assert.New(t)
+	var m Measures
+	m.recordBuckets(bootToManageableOpts)
+	m.HistogramBuckets = make(map[string]string)
+	m.recordBuckets(bootToManageableOpts)
+	assert.Equal(map[string]string{bootToManageableOpts.Name: bucketDefinition(bootToManageableOpts)}, m.HistogramBuckets)
+}
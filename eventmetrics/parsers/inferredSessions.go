@@ -0,0 +1,226 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+)
+
+const (
+	inferredSessionSuffix = "_inferred_session"
+
+	defaultInferredSessionGap = time.Hour
+)
+
+// InferredSessionsConfig configures inferring the sessions of devices that don't send boot-times, grouping their
+// events into sessions separated by large gaps between birthdates.
+type InferredSessionsConfig struct {
+	// Enabled turns on calculating the time elapsed calculations in inferred sessions when none of a device's
+	// events have a boot-time.  The durations are recorded in histograms named after the calculation, followed
+	// by _inferred_session.
+	Enabled bool
+
+	// GapThreshold is the gap between the birthdates of consecutive events after which a new session is
+	// inferred to have started.  Defaults to 1h.
+	GapThreshold time.Duration
+}
+
+// inferSessions groups the events with birthdates into sessions, sorted oldest to newest, starting a new session
+// whenever the gap between the birthdates of consecutive events is larger than the threshold.
+func inferSessions(events []interpreter.Event, gapThreshold time.Duration) [][]interpreter.Event {
+	sorted := make([]interpreter.Event, 0, len(events))
+	for _, event := range events {
+		if event.Birthdate > 0 {
+			sorted = append(sorted, event)
+		}
+	}
+
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].Birthdate < sorted[b].Birthdate
+	})
+
+	var sessions [][]interpreter.Event
+	for i, event := range sorted {
+		if i == 0 || time.Duration(event.Birthdate-sorted[i-1].Birthdate) > gapThreshold {
+			sessions = append(sessions, []interpreter.Event{})
+		}
+		sessions[len(sessions)-1] = append(sessions[len(sessions)-1], event)
+	}
+
+	return sessions
+}
+
+// missingBootTimes returns true if none of the events have a boot-time.
+func missingBootTimes(events []interpreter.Event) bool {
+	for _, event := range events {
+		if bootTime, err := event.BootTime(); err == nil && bootTime > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inferredSessionFinder finds the earliest valid event in the incoming event's inferred session or the
+// inferred session before it.
+type inferredSessionFinder struct {
+	validator    validation.Validator
+	previous     bool
+	gapThreshold time.Duration
+}
+
+// Find implements the Finder interface.
+func (f inferredSessionFinder) Find(events []interpreter.Event, incomingEvent interpreter.Event) (interpreter.Event, error) {
+	if incomingEvent.Birthdate <= 0 {
+		return interpreter.Event{}, errEventNotFound
+	}
+
+	// the incoming event is included so that its session can be found.
+	all := make([]interpreter.Event, 0, len(events)+1)
+	all = append(all, events...)
+	sessions := inferSessions(append(all, incomingEvent), f.gapThreshold)
+	target := -1
+	for i, session := range sessions {
+		if incomingEvent.Birthdate >= session[0].Birthdate && incomingEvent.Birthdate <= session[len(session)-1].Birthdate {
+			target = i
+			break
+		}
+	}
+
+	if f.previous {
+		target--
+	}
+
+	if target < 0 {
+		return interpreter.Event{}, errEventNotFound
+	}
+
+	for _, event := range sessions[target] {
+		if event.TransactionUUID == incomingEvent.TransactionUUID {
+			continue
+		}
+
+		if valid, _ := f.validator.Valid(event); valid {
+			return event, nil
+		}
+	}
+
+	return interpreter.Event{}, errEventNotFound
+}
+
+// createInferredDurationCalculators creates the calculators for the time elapsed calculations in inferred sessions,
+// if inferring sessions is enabled.
+func createInferredDurationCalculators(f *touchstone.Factory, configs []TimeElapsedConfig, m Measures, parserConfig RebootParserConfig, loggerIn RebootLoggerIn) ([]DurationCalculator, error) {
+	inferred := parserConfig.InferredSessions
+	if !inferred.Enabled {
+		return nil, nil
+	}
+
+	if inferred.GapThreshold <= 0 {
+		inferred.GapThreshold = defaultInferredSessionGap
+	}
+
+	zeroPolicy := enums.ParseZeroDurationPolicy(parserConfig.ZeroDurationPolicy)
+	partners := newDurationPartners(parserConfig.PartnerLabel)
+	calculators := make([]DurationCalculator, 0, len(configs))
+	for _, config := range configs {
+		if len(config.Name) == 0 {
+			return nil, errBlankHistogramName
+		}
+
+		// the other calculator types need boot-times or the session of a second event, so they can't be
+		// calculated in inferred sessions.
+		if calculatorType(config) != eventToCurrentCalculatorType {
+			continue
+		}
+
+		name := config.Name + inferredSessionSuffix
+		options := parserConfig.NativeHistograms.apply(prometheus.HistogramOpts{
+			Name:        name,
+			Help:        fmt.Sprintf("time elapsed between a %s event and fully-manageable event in s, in sessions inferred from birthdates", config.EventType),
+			Buckets:     []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 900, 1200, 1500, 1800, 3600, 7200, 14400, 21600},
+			ConstLabels: m.ConfigVariantLabels,
+		})
+
+		if err := m.addTimeElapsedObserver(f, parserConfig.Summaries, options, partners.labelNames()...); err != nil {
+			return nil, err
+		}
+
+		finder := inferredSessionFinder{
+			validator:    validation.DestinationValidator(config.EventType),
+			previous:     enums.ParseSessionType(config.SessionType) == enums.Previous,
+			gapThreshold: inferred.GapThreshold,
+		}
+
+		callback, err := createTimeElapsedCallback(m, name, partners)
+		if err != nil {
+			return nil, err
+		}
+
+		calculator, err := NewEventToCurrentCalculator(m.timeFinder(finder, rebootDurationParserName, name, loggerIn.Logger), callback, loggerIn.Logger)
+		if err != nil {
+			return nil, err
+		}
+
+		// without boot-times, the birthdates are the only times that can be used.
+		calculator.zeroDuration = m.zeroDurationFunc(name, zeroPolicy)
+		calculator.bounds = parserConfig.TimeElapsedBounds
+		calculators = append(calculators, calculator)
+	}
+
+	return calculators, nil
+}
+
+// parseInferredSessions calculates the durations in the inferred sessions of each device referenced by an event
+// without a boot-time.
+func (p *RebootDurationParser) parseInferredSessions(ctx context.Context, currentEvent interpreter.Event, client EventClient) {
+	if _, err := deviceid.FromEvent(currentEvent); err != nil {
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	for _, deviceID := range p.deviceIDs(currentEvent) {
+		events := client.GetEvents(ctx, deviceID)
+		if !missingBootTimes(events) {
+			p.addToUnparsableCounters(currentEvent, errFatal, events...)
+			continue
+		}
+
+		var calculationErr error
+		for _, calculator := range p.inferredCalculators {
+			if err := calculator.Calculate(events, currentEvent); err != nil && !errors.Is(err, errEventNotFound) && calculationErr == nil {
+				calculationErr = calculationError(err)
+			}
+		}
+
+		if calculationErr != nil {
+			p.addToUnparsableCounters(currentEvent, calculationErr, events...)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package parsers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func inferredEvent(eventType string, birthdate time.Time, id string) interpreter.Event {
+	return interpreter.Event{
+		Destination:     "event:device-status/mac:112233445566/" + eventType,
+		Birthdate:       birthdate.UnixNano(),
+		TransactionUUID: id,
+		Metadata: map[string]string{
+			hardwareMetadataKey: "hw",
+			firmwareMetadataKey: "fw",
+		},
+	}
+}
+
+func TestInferSessions(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	events := []interpreter.Event{
+		inferredEvent(onlineEventType, now.Add(-10*time.Minute), "4"),
+		inferredEvent(onlineEventType, now.Add(-5*time.Hour), "1"),
+		inferredEvent(rebootPendingEventType, now.Add(-4*time.Hour), "2"),
+		inferredEvent(onlineEventType, time.Time{}, "missing birthdate"),
+		inferredEvent(fullyManageableEventType, now, "5"),
+		inferredEvent(offlineEventType, now.Add(-3*time.Hour-30*time.Minute), "3"),
+	}
+
+	sessions := inferSessions(events, time.Hour)
+	var ids [][]string
+	for _, session := range sessions {
+		var sessionIDs []string
+		for _, event := range session {
+			sessionIDs = append(sessionIDs, event.TransactionUUID)
+		}
+		ids = append(ids, sessionIDs)
+	}
+	assert.Equal([][]string{{"1", "2", "3"}, {"4", "5"}}, ids)
+	assert.Empty(inferSessions(nil, time.Hour))
+}
+
+func TestMissingBootTimes(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	events := []interpreter.Event{inferredEvent(onlineEventType, now, "1")}
+	assert.True(missingBootTimes(events))
+	assert.True(missingBootTimes(nil))
+
+	withBootTime := inferredEvent(offlineEventType, now, "2")
+	withBootTime.Metadata[interpreter.BootTimeKey] = "1611700000"
+	assert.False(missingBootTimes(append(events, withBootTime)))
+}
+
+func TestInferredSessionFinder(t *testing.T) {
+	now := time.Now()
+	incoming := inferredEvent(fullyManageableEventType, now, "incoming")
+	events := []interpreter.Event{
+		inferredEvent(onlineEventType, now.Add(-5*time.Hour), "previous-online"),
+		inferredEvent(rebootPendingEventType, now.Add(-4*time.Hour), "previous-reboot-pending"),
+		inferredEvent(onlineEventType, now.Add(-2*time.Minute), "current-online"),
+		inferredEvent(onlineEventType, now.Add(-1*time.Minute), "current-online-later"),
+		incoming,
+	}
+
+	tests := []struct {
+		description   string
+		eventType     string
+		previous      bool
+		incoming      interpreter.Event
+		expectedEvent string
+		expectedErr   error
+	}{
+		{
+			description:   "Current session",
+			eventType:     onlineEventType,
+			incoming:      incoming,
+			expectedEvent: "current-online",
+		},
+		{
+			description:   "Previous session",
+			eventType:     rebootPendingEventType,
+			previous:      true,
+			incoming:      incoming,
+			expectedEvent: "previous-reboot-pending",
+		},
+		{
+			description: "Not in session",
+			eventType:   rebootPendingEventType,
+			incoming:    incoming,
+			expectedErr: errEventNotFound,
+		},
+		{
+			description: "No previous session",
+			eventType:   onlineEventType,
+			previous:    true,
+			incoming:    inferredEvent(fullyManageableEventType, now.Add(-6*time.Hour), "first"),
+			expectedErr: errEventNotFound,
+		},
+		{
+			description: "Missing birthdate",
+			eventType:   onlineEventType,
+			incoming:    inferredEvent(fullyManageableEventType, time.Time{}, "missing"),
+			expectedErr: errEventNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			finder := inferredSessionFinder{
+				validator:    validation.DestinationValidator(tc.eventType),
+				previous:     tc.previous,
+				gapThreshold: time.Hour,
+			}
+
+			event, err := finder.Find(events, tc.incoming)
+			assert.Equal(tc.expectedErr, err)
+			assert.Equal(tc.expectedEvent, event.TransactionUUID)
+		})
+	}
+}
+
+func TestParseInferredSessions(t *testing.T) {
+	now := time.Now()
+	incoming := inferredEvent(fullyManageableEventType, now, "incoming")
+	rebootPending := inferredEvent(rebootPendingEventType, now.Add(-4*time.Hour), "reboot-pending")
+	withBootTime := inferredEvent(rebootPendingEventType, now.Add(-4*time.Hour), "boot-time")
+	withBootTime.Metadata = map[string]string{interpreter.BootTimeKey: "1611700000"}
+
+	tests := []struct {
+		description        string
+		history            []interpreter.Event
+		expectedCount      uint64
+		expectedDuration   float64
+		expectedUnparsable float64
+	}{
+		{
+			description: "Clustered birthdates",
+			history: []interpreter.Event{
+				inferredEvent(onlineEventType, now.Add(-5*time.Hour), "online"),
+				rebootPending,
+				inferredEvent(onlineEventType, now.Add(-2*time.Minute), "current-online"),
+				incoming,
+			},
+			expectedCount:    1,
+			expectedDuration: now.Sub(now.Add(-4 * time.Hour)).Seconds(),
+		},
+		{
+			description: "No previous session",
+			history: []interpreter.Event{
+				inferredEvent(onlineEventType, now.Add(-2*time.Minute), "current-online"),
+				incoming,
+			},
+		},
+		{
+			description:        "History with boot-times",
+			history:            []interpreter.Event{withBootTime, incoming},
+			expectedUnparsable: 1.0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			f := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+			m := Measures{
+				TotalUnparsableCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "testTotalUnparsable",
+					Help: "testTotalUnparsable",
+				}, []string{parserLabel}),
+				RebootUnparsableCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "testRebootUnparsable",
+					Help: "testRebootUnparsable",
+				}, []string{firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel}),
+				TimeElapsedHistograms: make(map[string]prometheus.ObserverVec),
+			}
+
+			configs := []TimeElapsedConfig{{Name: "reboot_to_manageable", SessionType: "previous", EventType: rebootPendingEventType}}
+			parserConfig := RebootParserConfig{InferredSessions: InferredSessionsConfig{Enabled: true, GapThreshold: time.Hour}}
+			calculators, err := createInferredDurationCalculators(f, configs, m, parserConfig, RebootLoggerIn{Logger: zap.NewNop()})
+			assert.Nil(err)
+			assert.Len(calculators, 1)
+
+			client := new(mockEventClient)
+			client.On("GetEvents", "mac:112233445566").Return(tc.history)
+			parser := RebootDurationParser{
+				name:                "test_reboot_parser",
+				client:              client,
+				inferredCalculators: calculators,
+				measures:            m,
+				logger:              zap.NewNop(),
+			}
+
+			parser.Parse(context.Background(), incoming)
+			assert.Equal(tc.expectedUnparsable, testutil.ToFloat64(m.TotalUnparsableCount.WithLabelValues("test_reboot_parser")))
+
+			histogram := m.TimeElapsedHistograms["reboot_to_manageable"+inferredSessionSuffix]
+			metric := &dto.Metric{}
+			assert.Nil(histogram.With(getTimeElapsedHistogramLabels(incoming)).(prometheus.Metric).Write(metric))
+			assert.Equal(tc.expectedCount, metric.GetHistogram().GetSampleCount())
+			assert.InDelta(tc.expectedDuration, metric.GetHistogram().GetSampleSum(), 0.000001)
+		})
+	}
+}
+
+func TestCreateInferredDurationCalculators(t *testing.T) {
+	assert := assert.New(t)
+	f := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+	configs := []TimeElapsedConfig{{Name: "reboot_to_manageable", EventType: rebootPendingEventType}}
+
+	calculators, err := createInferredDurationCalculators(f, configs, Measures{}, RebootParserConfig{}, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+	assert.Empty(calculators)
+
+	enabled := RebootParserConfig{InferredSessions: InferredSessionsConfig{Enabled: true}}
+	calculators, err = createInferredDurationCalculators(f, []TimeElapsedConfig{{}}, Measures{}, enabled, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Equal(errBlankHistogramName, err)
+	assert.Empty(calculators)
+
+	m := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
+	calculators, err = createInferredDurationCalculators(f, configs, m, enabled, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+	assert.Len(calculators, 1)
+	assert.Contains(m.TimeElapsedHistograms, "reboot_to_manageable"+inferredSessionSuffix)
+	assert.Equal(defaultInferredSessionGap, calculators[0].(*EventToCurrentCalculator).eventFinder.(inferredSessionFinder).gapThreshold)
+
+	// calculator types other than event-to-current aren't calculated in inferred sessions.
+	f = touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+	m = Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
+	configs = append(configs, TimeElapsedConfig{Name: "boot_to_online", EventType: "online", CalculatorType: bootToEventCalculatorType})
+	calculators, err = createInferredDurationCalculators(f, configs, m, enabled, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+	assert.Len(calculators, 1)
+	assert.NotContains(m.TimeElapsedHistograms, "boot_to_online"+inferredSessionSuffix)
+}
@@ -0,0 +1,221 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+// MeasuresConfig configures the Measures created by NewMeasures.
+type MeasuresConfig struct {
+	// Metrics is the touchstone configuration used to create the metrics.
+	Metrics touchstone.Config
+
+	// DeferRegistration delays registering each metric until it is first used, rather than registering the
+	// metrics when the Measures are created.
+	DeferRegistration bool
+
+	// Logger logs the metrics that fail to register when their registration is deferred.  Defaults to a nop
+	// logger.
+	Logger *zap.Logger
+}
+
+// NewMeasures creates the event-related metrics outside of an fx application, registering them with the
+// registerer given.  Each call creates its own metrics, so Measures created against separate registerers
+// don't conflict with each other.
+func NewMeasures(registerer prometheus.Registerer, config MeasuresConfig) (Measures, error) {
+	logger := config.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	m := Measures{
+		TimeElapsedHistograms: make(map[string]prometheus.ObserverVec),
+	}
+
+	if config.DeferRegistration {
+		m.registration = &lazyRegistration{
+			registerer: registerer,
+			factory:    touchstone.NewFactory(config.Metrics, logger, unregistered{}),
+			logger:     logger,
+		}
+		return m, m.registration.newMeasures(&m)
+	}
+
+	f := touchstone.NewFactory(config.Metrics, logger, registerer)
+	var err error
+	if m.MetadataFields, err = f.NewCounterVec(metadataFieldsOpts, metadataKeyLabel); err != nil {
+		return Measures{}, err
+	}
+
+	if m.TotalUnparsableCount, err = f.NewCounterVec(totalUnparsableOpts, parserLabel); err != nil {
+		return Measures{}, err
+	}
+
+	if m.RebootUnparsableCount, err = f.NewCounterVec(rebootUnparsableOpts, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel); err != nil {
+		return Measures{}, err
+	}
+
+	if m.EventErrorTags, err = f.NewCounterVec(eventErrorsOpts, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel); err != nil {
+		return Measures{}, err
+	}
+
+	if m.BootCycleErrorTags, err = f.NewCounterVec(bootCycleErrorsOpts, reasonLabel, partnerIDLabel); err != nil {
+		return Measures{}, err
+	}
+
+	if m.RebootCycleErrorTags, err = f.NewCounterVec(rebootCycleErrorsOpts, reasonLabel, partnerIDLabel); err != nil {
+		return Measures{}, err
+	}
+
+	if m.ZeroDurationCount, err = f.NewCounterVec(zeroDurationOpts, calculationLabel); err != nil {
+		return Measures{}, err
+	}
+
+	if m.BootToManageableHistogram, err = f.NewHistogramVec(bootToManageableOpts, firmwareLabel, hardwareLabel, rebootReasonLabel); err != nil {
+		return Measures{}, err
+	}
+
+	return m, nil
+}
+
+// lazyRegistration creates metrics that register themselves with the registerer the first time a child
+// metric is created, such as by With or WithLabelValues, so that metrics are only registered once they're
+// used and no locking is added once they are.
+type lazyRegistration struct {
+	registerer prometheus.Registerer
+
+	// factory creates the metrics without registering them.
+	factory *touchstone.Factory
+	logger  *zap.Logger
+}
+
+// newMeasures creates the metrics of the Measures.
+func (l *lazyRegistration) newMeasures(m *Measures) error {
+	var err error
+	if m.MetadataFields, err = l.newCounterVec(metadataFieldsOpts, metadataKeyLabel); err != nil {
+		return err
+	}
+
+	if m.TotalUnparsableCount, err = l.newCounterVec(totalUnparsableOpts, parserLabel); err != nil {
+		return err
+	}
+
+	if m.RebootUnparsableCount, err = l.newCounterVec(rebootUnparsableOpts, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel); err != nil {
+		return err
+	}
+
+	if m.EventErrorTags, err = l.newCounterVec(eventErrorsOpts, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel); err != nil {
+		return err
+	}
+
+	if m.BootCycleErrorTags, err = l.newCounterVec(bootCycleErrorsOpts, reasonLabel, partnerIDLabel); err != nil {
+		return err
+	}
+
+	if m.RebootCycleErrorTags, err = l.newCounterVec(rebootCycleErrorsOpts, reasonLabel, partnerIDLabel); err != nil {
+		return err
+	}
+
+	if m.ZeroDurationCount, err = l.newCounterVec(zeroDurationOpts, calculationLabel); err != nil {
+		return err
+	}
+
+	m.BootToManageableHistogram, err = l.newHistogramVec(bootToManageableOpts, firmwareLabel, hardwareLabel, rebootReasonLabel)
+	return err
+}
+
+// newCounterVec creates a counter vec that is registered when it is first used.
+func (l *lazyRegistration) newCounterVec(o prometheus.CounterOpts, labelNames ...string) (*prometheus.CounterVec, error) {
+	counter, err := l.factory.NewCounterVec(o, labelNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	vec := new(prometheus.CounterVec)
+	vec.MetricVec = l.newMetricVec(counter, vec, func(lvs ...string) prometheus.Metric {
+		return counter.WithLabelValues(lvs...)
+	})
+	return vec, nil
+}
+
+// newHistogramVec creates a histogram vec that is registered when it is first used.
+func (l *lazyRegistration) newHistogramVec(o prometheus.HistogramOpts, labelNames ...string) (prometheus.ObserverVec, error) {
+	histogram, err := l.factory.NewHistogramVec(o, labelNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	vec := new(prometheus.HistogramVec)
+	vec.MetricVec = l.newMetricVec(histogram, vec, observerMetric(histogram))
+	return vec, nil
+}
+
+// newSummaryVec creates a summary vec that is registered when it is first used.
+func (l *lazyRegistration) newSummaryVec(o prometheus.SummaryOpts, labelNames ...string) (prometheus.ObserverVec, error) {
+	summary, err := l.factory.NewSummaryVec(o, labelNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	vec := new(prometheus.SummaryVec)
+	vec.MetricVec = l.newMetricVec(summary, vec, observerMetric(summary))
+	return vec, nil
+}
+
+// newMetricVec creates the MetricVec of vec, whose children are created by newMetric from the unregistered
+// metric and which registers vec when its first child is created.  Registration errors are logged, since
+// they can't be returned to the caller using the metric.
+func (l *lazyRegistration) newMetricVec(metric prometheus.Collector, vec prometheus.Collector, newMetric func(lvs ...string) prometheus.Metric) *prometheus.MetricVec {
+	var once sync.Once
+	desc := describe(metric)
+	return prometheus.NewMetricVec(desc, func(lvs ...string) prometheus.Metric {
+		once.Do(func() {
+			if err := l.registerer.Register(vec); err != nil {
+				l.logger.Error("failed to register metric", zap.Stringer("metric", desc), zap.Error(err))
+			}
+		})
+		return newMetric(lvs...)
+	})
+}
+
+// observerMetric creates the children of an ObserverVec, all of which are metrics.
+func observerMetric(vec prometheus.ObserverVec) func(lvs ...string) prometheus.Metric {
+	return func(lvs ...string) prometheus.Metric {
+		return vec.WithLabelValues(lvs...).(prometheus.Metric)
+	}
+}
+
+// describe returns the single Desc of a metric vec.
+func describe(c prometheus.Collector) *prometheus.Desc {
+	ch := make(chan *prometheus.Desc, 1)
+	c.Describe(ch)
+	return <-ch
+}
+
+// unregistered is a prometheus.Registerer that doesn't register anything, used to create metrics that are
+// registered later.
+type unregistered struct{}
+
+func (unregistered) Register(prometheus.Collector) error  { return nil }
+func (unregistered) MustRegister(...prometheus.Collector) {}
+func (unregistered) Unregister(prometheus.Collector) bool { return false }
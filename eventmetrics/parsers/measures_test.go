@@ -0,0 +1,105 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewMeasuresSeparateRegistries(t *testing.T) {
+	tests := []struct {
+		description string
+		deferred    bool
+	}{
+		{
+			description: "Immediate registration",
+		},
+		{
+			description: "Deferred registration",
+			deferred:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			registries := []*prometheus.Registry{prometheus.NewPedanticRegistry(), prometheus.NewPedanticRegistry()}
+			for _, registry := range registries {
+				m, err := NewMeasures(registry, MeasuresConfig{DeferRegistration: tc.deferred})
+				assert.Nil(err)
+				assert.NotNil(m.TotalUnparsableCount)
+
+				families, err := registry.Gather()
+				assert.Nil(err)
+				assert.Empty(families)
+
+				m.AddTotalUnparsable("test")
+				families, err = registry.Gather()
+				assert.Nil(err)
+				assert.Equal([]string{"total_unparsable_count"}, familyNames(families))
+			}
+		})
+	}
+}
+
+func TestNewMeasuresDeferred(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	m, err := NewMeasures(registry, MeasuresConfig{DeferRegistration: true})
+	assert.Nil(err)
+	assert.Nil(m.addTimeElapsedHistogram(nil, prometheus.HistogramOpts{Name: "test_elapsed", Help: "test_elapsed"}, "key"))
+
+	families, err := registry.Gather()
+	assert.Nil(err)
+	assert.Empty(families)
+
+	// metrics used directly are registered as well as those used by the helpers.
+	m.RebootUnparsableCount.WithLabelValues("fw", "hw", "partner", "reason").Inc()
+	m.RebootUnparsableCount.WithLabelValues("fw", "hw", "partner", "other").Inc()
+	m.BootToManageableHistogram.WithLabelValues("fw", "hw", "reason").Observe(1.0)
+	m.TimeElapsedHistograms["test_elapsed"].WithLabelValues("value").Observe(1.0)
+	AddEventError(m.EventErrorTags, interpreter.Event{}, "tag")
+	m.AddTotalUnparsable("test")
+
+	families, err = registry.Gather()
+	assert.Nil(err)
+	assert.ElementsMatch([]string{"reboot_unparsable_count", "boot_to_manageable", "test_elapsed", "event_errors",
+		"total_unparsable_count"}, familyNames(families))
+	assert.Equal(2, testutil.CollectAndCount(m.RebootUnparsableCount))
+}
+
+func TestNewMeasuresDuplicate(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	first, err := NewMeasures(registry, MeasuresConfig{})
+	assert.Nil(err)
+
+	_, err = NewMeasures(registry, MeasuresConfig{})
+	assert.NotNil(err)
+
+	// deferred metrics can't fail until they're used, at which point the error is logged.
+	core, logs := observer.New(zapcore.ErrorLevel)
+	m, err := NewMeasures(registry, MeasuresConfig{DeferRegistration: true, Logger: zap.New(core)})
+	assert.Nil(err)
+
+	first.AddTotalUnparsable("test")
+	m.AddTotalUnparsable("test")
+	m.AddTotalUnparsable("test")
+	assert.Equal(1, logs.FilterMessage("failed to register metric").Len())
+	assert.Equal(1.0, testutil.ToFloat64(first.TotalUnparsableCount))
+}
+
+func familyNames(families []*dto.MetricFamily) []string {
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	return names
+}
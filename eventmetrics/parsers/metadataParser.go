@@ -18,7 +18,9 @@
 package parsers
 
 import (
+	"context"
 	"strings"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
@@ -30,17 +32,74 @@ const (
 	metadataKeyLabel = "metadata_key"
 
 	noMetadataFoundErr = "no_metadata_found"
+
+	otherMetadataKey       = "other"
+	defaultMaxMetadataKeys = 100
 )
 
+// MetadataConfig configures which metadata keys the metadata parser counts, guarding against unbounded
+// cardinality of the metadata_key label.
+type MetadataConfig struct {
+	// Allowed are the metadata keys that are counted.  Other keys are counted as "other".  If this is empty,
+	// all keys are allowed.
+	Allowed []string
+
+	// Denied are the metadata keys that are never counted.
+	Denied []string
+
+	// MaxKeys is the maximum number of distinct metadata keys counted.  Once reached, new keys are counted
+	// as "other".  Defaults to 100.
+	MaxKeys int
+}
+
 // MetadataParser parses messages coming in and counts the various metadata keys of each request.
 type MetadataParser struct {
 	measures Measures
 	name     string
 	logger   *zap.Logger
+
+	allowed      map[string]bool
+	denied       map[string]bool
+	maxKeys      int
+	distinctKeys prometheus.Gauge
+
+	lock sync.Mutex
+	keys map[string]bool
+}
+
+// NewMetadataParser creates a new MetadataParser.  If distinctKeys isn't nil, it is set to the number of
+// distinct metadata keys counted.
+func NewMetadataParser(config MetadataConfig, measures Measures, distinctKeys prometheus.Gauge, logger *zap.Logger) *MetadataParser {
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = defaultMaxMetadataKeys
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &MetadataParser{
+		measures:     measures,
+		name:         "metadata",
+		logger:       logger,
+		allowed:      keySet(config.Allowed),
+		denied:       keySet(config.Denied),
+		maxKeys:      config.MaxKeys,
+		distinctKeys: distinctKeys,
+		keys:         make(map[string]bool),
+	}
+}
+
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[strings.Trim(key, "/")] = true
+	}
+	return set
 }
 
 // Parse gathers metrics for each metadata key.
-func (m *MetadataParser) Parse(event interpreter.Event) {
+func (m *MetadataParser) Parse(_ context.Context, event interpreter.Event) {
 	if len(event.Metadata) < 1 {
 		m.measures.TotalUnparsableCount.With(prometheus.Labels{parserLabel: m.name, reasonLabel: noMetadataFoundErr}).Add(1.0)
 		m.logger.Error("no metadata found")
@@ -48,9 +107,49 @@ func (m *MetadataParser) Parse(event interpreter.Event) {
 	}
 
 	for key := range event.Metadata {
-		trimmedKey := strings.Trim(key, "/")
-		m.measures.MetadataFields.With(prometheus.Labels{metadataKeyLabel: trimmedKey}).Add(1.0)
+		label, ok := m.label(strings.Trim(key, "/"))
+		if !ok {
+			continue
+		}
+		m.measures.MetadataFields.With(prometheus.Labels{metadataKeyLabel: label}).Add(1.0)
+	}
+}
+
+// label returns the metadata_key label value of the key, returning false if the key is denied.  Keys that
+// aren't allowed or that are beyond the maximum number of distinct keys are labeled as "other".
+func (m *MetadataParser) label(key string) (string, bool) {
+	if m.denied[key] {
+		return "", false
+	}
+
+	if len(m.allowed) > 0 && !m.allowed[key] {
+		return otherMetadataKey, true
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.keys == nil {
+		m.keys = make(map[string]bool)
 	}
+
+	if m.keys[key] {
+		return key, true
+	}
+
+	if m.maxKeys > 0 && len(m.keys) >= m.maxKeys {
+		return otherMetadataKey, true
+	}
+
+	m.keys[key] = true
+	if m.distinctKeys != nil {
+		m.distinctKeys.Set(float64(len(m.keys)))
+	}
+	return key, true
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.  Every event is parsed.
+func (m *MetadataParser) EventTypeRegexes() []string {
+	return []string{".*"}
 }
 
 // Name returns the name of the parser. Implements the Parser interface.
@@ -1,9 +1,11 @@
 package parsers
 
 import (
+	"context"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/touchstone/touchtest"
@@ -13,6 +15,7 @@ import (
 func TestName(t *testing.T) {
 	metadataParser := MetadataParser{name: "test_parser"}
 	assert.Equal(t, "test_parser", metadataParser.Name())
+	assert.Equal(t, []string{".*"}, metadataParser.EventTypeRegexes())
 }
 
 func TestParse(t *testing.T) {
@@ -101,7 +104,7 @@ func TestParse(t *testing.T) {
 				name:     "metadata_parser",
 			}
 
-			mp.Parse(tc.message)
+			mp.Parse(context.Background(), tc.message)
 			for key := range tc.message.Metadata {
 				expectedMetadataCounter.With(prometheus.Labels{metadataKeyLabel: key}).Inc()
 			}
@@ -204,7 +207,7 @@ func TestMultipleParse(t *testing.T) {
 	}
 
 	for _, msg := range messages {
-		mp.Parse(msg)
+		mp.Parse(context.Background(), msg)
 		for key := range msg.Metadata {
 			expectedMetadataCounter.WithLabelValues(key).Inc()
 		}
@@ -218,3 +221,63 @@ func TestMultipleParse(t *testing.T) {
 	testAssert.Expect(expectedRegistry)
 	assert.True(testAssert.GatherAndCompare(actualRegistry))
 }
+
+func TestNewMetadataParser(t *testing.T) {
+	assert := assert.New(t)
+	parser := NewMetadataParser(MetadataConfig{Allowed: []string{"/boot-time"}, Denied: []string{"trust"}}, Measures{}, nil, nil)
+	assert.Equal("metadata", parser.Name())
+	assert.Equal(defaultMaxMetadataKeys, parser.maxKeys)
+	assert.Equal(map[string]bool{"boot-time": true}, parser.allowed)
+	assert.Equal(map[string]bool{"trust": true}, parser.denied)
+	assert.NotNil(parser.logger)
+}
+
+func TestMetadataLabel(t *testing.T) {
+	tests := []struct {
+		description          string
+		config               MetadataConfig
+		keys                 []string
+		expectedLabels       []string
+		expectedDistinctKeys float64
+	}{
+		{
+			description:          "All allowed",
+			config:               MetadataConfig{MaxKeys: 5},
+			keys:                 []string{"trust", "boot-time", "trust"},
+			expectedLabels:       []string{"trust", "boot-time", "trust"},
+			expectedDistinctKeys: 2,
+		},
+		{
+			description:          "Allowed and denied",
+			config:               MetadataConfig{Allowed: []string{"trust", "boot-time"}, Denied: []string{"boot-time"}},
+			keys:                 []string{"trust", "boot-time", "random"},
+			expectedLabels:       []string{"trust", "", otherMetadataKey},
+			expectedDistinctKeys: 1,
+		},
+		{
+			description:          "Max keys",
+			config:               MetadataConfig{MaxKeys: 2},
+			keys:                 []string{"trust", "boot-time", "random", "trust", "other-random"},
+			expectedLabels:       []string{"trust", "boot-time", otherMetadataKey, "trust", otherMetadataKey},
+			expectedDistinctKeys: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testDistinctKeys", Help: "testDistinctKeys"})
+			parser := NewMetadataParser(tc.config, Measures{}, gauge, nil)
+
+			labels := make([]string, len(tc.keys))
+			for i, key := range tc.keys {
+				label, ok := parser.label(key)
+				assert.Equal(len(label) > 0, ok)
+				labels[i] = label
+			}
+
+			assert.Equal(tc.expectedLabels, labels)
+			assert.Equal(tc.expectedDistinctKeys, testutil.ToFloat64(gauge))
+		})
+	}
+}
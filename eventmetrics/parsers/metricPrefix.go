@@ -0,0 +1,81 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	errInvalidMetricPrefix   = errors.New("invalid metric prefix")
+	errDuplicateMetricPrefix = errors.New("metric prefix used by more than one parser")
+
+	metricPrefixRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// metricName returns the name of a parser's metric, prefixed by the parser's metric prefix if it has one.
+func metricName(prefix string, name string) string {
+	if len(prefix) == 0 || len(name) == 0 {
+		return name
+	}
+
+	return fmt.Sprintf("%s_%s", prefix, name)
+}
+
+// prefixTimeElapsedConfigs returns a copy of the configs with the metric prefix applied to the histogram names.
+func prefixTimeElapsedConfigs(prefix string, configs []TimeElapsedConfig) []TimeElapsedConfig {
+	prefixed := make([]TimeElapsedConfig, len(configs))
+	for i, config := range configs {
+		config.Name = metricName(prefix, config.Name)
+		if len(config.Chain) > 0 {
+			chain := make([]ChainEventConfig, len(config.Chain))
+			for j, event := range config.Chain {
+				event.Name = metricName(prefix, event.Name)
+				chain[j] = event
+			}
+			config.Chain = chain
+		}
+		prefixed[i] = config
+	}
+
+	return prefixed
+}
+
+// validateMetricPrefixes checks that the parsers' metric prefixes are valid metric names and that
+// no two parsers share a prefix.
+func validateMetricPrefixes(prefixes map[string]string) error {
+	used := make(map[string]string)
+	for parser, prefix := range prefixes {
+		if len(prefix) == 0 {
+			continue
+		}
+
+		if !metricPrefixRegex.MatchString(prefix) {
+			return fmt.Errorf("%w: %s prefix %q", errInvalidMetricPrefix, parser, prefix)
+		}
+
+		if other, found := used[prefix]; found {
+			return fmt.Errorf("%w: %q used by %s and %s", errDuplicateMetricPrefix, prefix, other, parser)
+		}
+		used[prefix] = parser
+	}
+
+	return nil
+}
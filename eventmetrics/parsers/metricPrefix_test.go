@@ -0,0 +1,80 @@
+package parsers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestMetricName(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("session_uptime", metricName("", "session_uptime"))
+	assert.Equal("session_session_uptime", metricName("session", "session_uptime"))
+	assert.Equal("", metricName("reboot", ""))
+}
+
+func TestValidateMetricPrefixes(t *testing.T) {
+	tests := []struct {
+		description string
+		prefixes    map[string]string
+		expectedErr error
+	}{
+		{
+			description: "No prefixes",
+			prefixes:    map[string]string{"a": "", "b": ""},
+		},
+		{
+			description: "Unique prefixes",
+			prefixes:    map[string]string{"a": "reboot", "b": "session", "c": ""},
+		},
+		{
+			description: "Invalid prefix",
+			prefixes:    map[string]string{"a": "reboot-parser"},
+			expectedErr: errInvalidMetricPrefix,
+		},
+		{
+			description: "Duplicate prefix",
+			prefixes:    map[string]string{"a": "reboot", "b": "reboot"},
+			expectedErr: errDuplicateMetricPrefix,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			err := validateMetricPrefixes(tc.prefixes)
+			assert.True(errors.Is(err, tc.expectedErr), err)
+		})
+	}
+}
+
+func TestMetricPrefixRegisteredNames(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+
+	testMeasures := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
+	configs := prefixTimeElapsedConfigs("reboot", []TimeElapsedConfig{{Name: "to_manageable", EventType: "reboot-pending"}})
+	_, err := createDurationCalculators(testFactory, configs, testMeasures, RebootParserConfig{}, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+
+	_, err = createSessionUptimeParsers(testFactory, SessionUptimeConfig{Enabled: true, MetricPrefix: "session"}, &events.CodexClient{}, Measures{}, zap.NewNop())
+	assert.Nil(err)
+
+	testMeasures.TimeElapsedHistograms["reboot_to_manageable"].With(prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason"}).Observe(1)
+	families, err := registry.Gather()
+	assert.Nil(err)
+	assert.Equal([]string{"reboot_to_manageable"}, familyNames(families))
+
+	// registering the unprefixed names doesn't collide with the prefixed metrics
+	_, err = testFactory.NewHistogramVec(prometheus.HistogramOpts{Name: "session_uptime", Help: "session_uptime"}, firmwareLabel)
+	assert.Nil(err)
+	_, err = testFactory.NewHistogramVec(prometheus.HistogramOpts{Name: "session_session_uptime", Help: "session_uptime"}, firmwareLabel)
+	assert.NotNil(err)
+}
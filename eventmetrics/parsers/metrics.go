@@ -20,27 +20,93 @@ package parsers
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/arrange"
 	"github.com/xmidt-org/bascule/basculechecks"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/touchstone"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
 const (
-	parserLabel = "parser_type"
-	reasonLabel = "reason"
+	parserLabel        = "parser_type"
+	reasonLabel        = "reason"
+	configVariantLabel = "config_variant"
+	eventTypeLabel     = "event_type"
+	calculationLabel   = "calculation"
+	finderLabel        = "finder"
+	otherEventType     = "other"
 )
 
 var (
-	errNilFactory   = errors.New("factory cannot be nil")
-	errNewHistogram = errors.New("unable to create new histogram")
+	errNilFactory           = errors.New("factory cannot be nil")
+	errNewHistogram         = errors.New("unable to create new histogram")
+	errInvalidConfigVariant = errors.New("config variant is not one of the allowed variants")
 )
 
+// ConfigVariantConfig configures the config_variant label added to the duration and unparsable metrics,
+// allowing metrics from replicas running different configurations to be compared.
+type ConfigVariantConfig struct {
+	// Name is the config variant of this replica.  If this is empty, no label is added.
+	Name string
+
+	// Allowed is the small set of variant names that Name must be one of, guarding against
+	// unbounded label cardinality.
+	Allowed []string
+}
+
+// UnparsableEventTypesConfig configures the unparsable_event_types_count metric, which breaks down the
+// reasons events are unparsable by the event type of the triggering event.
+type UnparsableEventTypesConfig struct {
+	// Enabled turns on the metric.
+	Enabled bool
+
+	// EventTypes are the event types, in addition to the standard device-status event types, that
+	// are used as label values.  All other event types are counted as "other".
+	EventTypes []string
+}
+
+// knownEventTypes returns the set of event types that are used as event_type label values.
+func knownEventTypes(config UnparsableEventTypesConfig) map[string]bool {
+	eventTypes := map[string]bool{
+		interpreter.OnlineEventType:          true,
+		interpreter.OfflineEventType:         true,
+		interpreter.RebootPendingEventType:   true,
+		interpreter.FullyManageableEventType: true,
+		interpreter.OperationalEventType:     true,
+	}
+
+	for _, eventType := range config.EventTypes {
+		eventTypes[eventType] = true
+	}
+
+	return eventTypes
+}
+
+// configVariantLabels returns the constant labels to add to the duration and unparsable metrics, returning an
+// error if the configured variant isn't allowed.
+func configVariantLabels(config ConfigVariantConfig) (prometheus.Labels, error) {
+	if len(config.Name) == 0 {
+		return nil, nil
+	}
+
+	for _, allowed := range config.Allowed {
+		if config.Name == allowed {
+			return prometheus.Labels{configVariantLabel: config.Name}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errInvalidConfigVariant, config.Name)
+}
+
 // Measures tracks the various event-related metrics.
 type Measures struct {
-	fx.In
+	fx.In                     `ignore-unexported:"true"`
 	MetadataFields            *prometheus.CounterVec            `name:"metadata_fields"`
 	TotalUnparsableCount      *prometheus.CounterVec            `name:"total_unparsable_count"`
 	RebootUnparsableCount     *prometheus.CounterVec            `name:"reboot_unparsable_count"`
@@ -49,78 +115,228 @@ type Measures struct {
 	RebootCycleErrorTags      *prometheus.CounterVec            `name:"reboot_cycle_errors"`
 	BootToManageableHistogram prometheus.ObserverVec            `name:"boot_to_manageable"`
 	TimeElapsedHistograms     map[string]prometheus.ObserverVec `name:"time_elapsed_histograms"`
+	ConfigVariantLabels       prometheus.Labels                 `name:"config_variant_labels" optional:"true"`
+	UnparsableEventTypeCount  *prometheus.CounterVec            `name:"unparsable_event_types_count" optional:"true"`
+	UnparsableEventTypes      map[string]bool                   `name:"unparsable_event_types" optional:"true"`
+	ZeroDurationCount         *prometheus.CounterVec            `name:"zero_duration_count"`
+	NewDeviceCount            *prometheus.CounterVec            `name:"new_device_count" optional:"true"`
+	HistogramBuckets          map[string]string                 `name:"histogram_buckets" optional:"true"`
+	FinderScanSeconds         prometheus.ObserverVec            `name:"finder_scan_seconds" optional:"true"`
+	FinderScanSlowThreshold   time.Duration                     `name:"finder_scan_slow_threshold" optional:"true"`
+	DurationExemplars         bool                              `name:"duration_exemplars" optional:"true"`
+	UnparsableLogger          *UnparsableLogger                 `name:"unparsable_logger" optional:"true"`
+	UnparsableRatio           *UnparsableRatio                  `name:"unparsable_ratio" optional:"true"`
+	DurationExporters         []DurationExporter                `group:"duration_exporters"`
+
+	// registration creates the metrics added later, such as the time elapsed histograms, when registration
+	// is deferred.
+	registration *lazyRegistration
+}
+
+// ConfigVariantLabelsIn provides the constant labels identifying the config variant.
+type ConfigVariantLabelsIn struct {
+	fx.In
+	Labels prometheus.Labels `name:"config_variant_labels"`
 }
 
+var (
+	metadataFieldsOpts = prometheus.CounterOpts{
+		Name: "metadata_fields",
+		Help: "the metadata fields coming from each event received",
+	}
+	eventErrorsOpts = prometheus.CounterOpts{
+		Name: "event_errors",
+		Help: "individual event errors",
+	}
+	bootCycleErrorsOpts = prometheus.CounterOpts{
+		Name: "boot_cycle_errors",
+		Help: "cycle errors",
+	}
+	rebootCycleErrorsOpts = prometheus.CounterOpts{
+		Name: "reboot_cycle_errors",
+		Help: "cycle errors",
+	}
+	totalUnparsableOpts = prometheus.CounterOpts{
+		Name: "total_unparsable_count",
+		Help: "events that are unparsable, labeled by the parser name",
+	}
+	rebootUnparsableOpts = prometheus.CounterOpts{
+		Name: "reboot_unparsable_count",
+		Help: "events that are not able to be fully processed, labeled by reason",
+	}
+	bootToManageableOpts = prometheus.HistogramOpts{
+		Name:    "boot_to_manageable",
+		Help:    "time elapsed between a device booting and fully-manageable event",
+		Buckets: []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 900, 1200, 1500, 1800, 3600, 7200, 14400, 21600},
+	}
+	zeroDurationOpts = prometheus.CounterOpts{
+		Name: "zero_duration_count",
+		Help: "durations calculated as exactly zero, labeled by the calculation, whether or not they were recorded",
+	}
+)
+
 // ProvideEventMetrics builds the event-related metrics and makes them available to the container.
 func ProvideEventMetrics() fx.Option {
 	return fx.Options(
-		touchstone.CounterVec(
-			prometheus.CounterOpts{
-				Name: "metadata_fields",
-				Help: "the metadata fields coming from each event received",
+		touchstone.CounterVec(metadataFieldsOpts, metadataKeyLabel),
+		touchstone.CounterVec(eventErrorsOpts, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel),
+		touchstone.CounterVec(bootCycleErrorsOpts, reasonLabel, partnerIDLabel),
+		touchstone.CounterVec(rebootCycleErrorsOpts, reasonLabel, partnerIDLabel),
+		touchstone.CounterVec(zeroDurationOpts, calculationLabel),
+		fx.Provide(
+			arrange.UnmarshalKey("configVariant", ConfigVariantConfig{}),
+			fx.Annotated{
+				Name:   "config_variant_labels",
+				Target: configVariantLabels,
 			},
-			metadataKeyLabel,
-		),
-		touchstone.CounterVec(
-			prometheus.CounterOpts{
+			fx.Annotated{
 				Name: "total_unparsable_count",
-				Help: "events that are unparsable, labeled by the parser name",
+				Target: func(f *touchstone.Factory, in ConfigVariantLabelsIn) (*prometheus.CounterVec, error) {
+					opts := totalUnparsableOpts
+					opts.ConstLabels = in.Labels
+					return f.NewCounterVec(opts, parserLabel)
+				},
 			},
-			parserLabel,
-		),
-		touchstone.CounterVec(
-			prometheus.CounterOpts{
+			fx.Annotated{
 				Name: "reboot_unparsable_count",
-				Help: "events that are not able to be fully processed, labeled by reason",
+				Target: func(f *touchstone.Factory, config RebootParserConfig, in ConfigVariantLabelsIn) (*prometheus.CounterVec, error) {
+					opts := rebootUnparsableOpts
+					opts.Name = metricName(config.MetricPrefix, opts.Name)
+					opts.ConstLabels = in.Labels
+					return f.NewCounterVec(opts, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel)
+				},
 			},
-			firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel,
-		),
-		touchstone.CounterVec(
-			prometheus.CounterOpts{
-				Name: "event_errors",
-				Help: "individual event errors",
+			fx.Annotated{
+				Name: "boot_to_manageable",
+				Target: func(f *touchstone.Factory, config RebootParserConfig, in ConfigVariantLabelsIn, buckets BucketDefinitionsIn) (prometheus.ObserverVec, error) {
+					return newBootToManageableVec(f, config, in.Labels, buckets.Definitions)
+				},
 			},
-			firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel,
-		),
-		touchstone.CounterVec(
-			prometheus.CounterOpts{
-				Name: "boot_cycle_errors",
-				Help: "cycle errors",
+			arrange.UnmarshalKey("unparsableEventTypes", UnparsableEventTypesConfig{}),
+			fx.Annotated{
+				Name:   "unparsable_event_types",
+				Target: knownEventTypes,
 			},
-			reasonLabel, partnerIDLabel,
-		),
-		touchstone.CounterVec(
-			prometheus.CounterOpts{
-				Name: "reboot_cycle_errors",
-				Help: "cycle errors",
+			fx.Annotated{
+				Name: "unparsable_event_types_count",
+				Target: func(f *touchstone.Factory, config UnparsableEventTypesConfig, in ConfigVariantLabelsIn) (*prometheus.CounterVec, error) {
+					if !config.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name:        "unparsable_event_types_count",
+							Help:        "events that are unparsable, labeled by the parser name, reason, and event type",
+							ConstLabels: in.Labels,
+						},
+						parserLabel, reasonLabel, eventTypeLabel,
+					)
+				},
 			},
-			reasonLabel, partnerIDLabel,
-		),
-		touchstone.HistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "boot_to_manageable",
-				Help:    "time elapsed between a device booting and fully-manageable event",
-				Buckets: []float64{60, 120, 180, 240, 300, 360, 420, 480, 540, 600, 900, 1200, 1500, 1800, 3600, 7200, 14400, 21600},
+			arrange.UnmarshalKey("newDevices", NewDevicesConfig{}),
+			fx.Annotated{
+				Name: "new_device_count",
+				Target: func(f *touchstone.Factory, config NewDevicesConfig, in ConfigVariantLabelsIn) (*prometheus.CounterVec, error) {
+					if !config.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name:        "new_device_count",
+							Help:        "events from likely brand-new devices that can't be parsed because the device has no history, labeled by the parser name",
+							ConstLabels: in.Labels,
+						},
+						parserLabel,
+					)
+				},
 			},
-			firmwareLabel, hardwareLabel, rebootReasonLabel,
-		),
-		fx.Provide(
 			fx.Annotated{
 				Name: "time_elapsed_histograms",
 				Target: func() map[string]prometheus.ObserverVec {
 					return make(map[string]prometheus.ObserverVec)
 				},
 			},
+			arrange.UnmarshalKey("exemplars", ExemplarsConfig{}),
+			fx.Annotated{
+				Name: "duration_exemplars",
+				Target: func(config ExemplarsConfig) bool {
+					return config.Enabled
+				},
+			},
+			arrange.UnmarshalKey("finderScans", FinderScansConfig{}),
+			fx.Annotated{
+				Name: "finder_scan_seconds",
+				Target: func(f *touchstone.Factory, config FinderScansConfig) (prometheus.ObserverVec, error) {
+					if !config.Enabled {
+						return nil, nil
+					}
+
+					return f.NewHistogramVec(
+						prometheus.HistogramOpts{
+							Name:    "finder_scan_seconds",
+							Help:    "time taken by finders to search through a device's history of events in s, labeled by the parser and finder",
+							Buckets: []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+						},
+						parserLabel, finderLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "finder_scan_slow_threshold",
+				Target: func(config FinderScansConfig) time.Duration {
+					return config.SlowThreshold
+				},
+			},
+			arrange.UnmarshalKey("unparsableEventLogging", UnparsableLogConfig{}),
+			fx.Annotated{
+				Name: "unparsable_logger",
+				Target: func(config UnparsableLogConfig, logger *zap.Logger) *UnparsableLogger {
+					return NewUnparsableLogger(config, logger)
+				},
+			},
+			arrange.UnmarshalKey("unparsableRatio", UnparsableRatioConfig{}),
+			fx.Annotated{
+				Name:   "unparsable_ratio",
+				Target: newUnparsableRatio,
+			},
+			fx.Annotated{
+				Name: "histogram_buckets",
+				Target: func() map[string]string {
+					return make(map[string]string)
+				},
+			},
 		),
 	)
 }
 
+// newBootToManageableVec creates the boot_to_manageable histogram, or summary, of the reboot duration parser,
+// recording the histogram's buckets in definitions.
+func newBootToManageableVec(f *touchstone.Factory, config RebootParserConfig, labels prometheus.Labels, definitions map[string]string) (prometheus.ObserverVec, error) {
+	opts := config.NativeHistograms.apply(bootToManageableOpts)
+	opts.Name = metricName(config.MetricPrefix, opts.Name)
+	opts.ConstLabels = labels
+	if !config.Summaries.Enabled && definitions != nil {
+		definitions[opts.Name] = bucketDefinition(opts)
+	}
+	return newDurationVec(f, config.Summaries, opts, newDurationPartners(config.PartnerLabel).labelNames()...)
+}
+
 func (m *Measures) addTimeElapsedHistogram(f *touchstone.Factory, o prometheus.HistogramOpts, labelNames ...string) error {
-	if f == nil {
+	if f == nil && m.registration == nil {
 		return errNilFactory
 	}
 
-	histogram, err := f.NewHistogramVec(o, labelNames...)
+	var (
+		histogram prometheus.ObserverVec
+		err       error
+	)
+	if m.registration != nil {
+		histogram, err = m.registration.newHistogramVec(o, labelNames...)
+	} else {
+		histogram, err = f.NewHistogramVec(o, labelNames...)
+	}
 	if err != nil {
 		return fmt.Errorf("%w: %v", errNewHistogram, err)
 	}
@@ -134,6 +350,7 @@ func (m *Measures) addTimeElapsedHistogram(f *touchstone.Factory, o prometheus.H
 	}
 
 	m.TimeElapsedHistograms[o.Name] = histogram
+	m.recordBuckets(o)
 	return nil
 }
 
@@ -151,13 +368,52 @@ func (m *Measures) AddTotalUnparsable(parserName string) {
 	}
 }
 
-// AddRebootUnparsable adds to the RebootUnparsable counter.
-func (m *Measures) AddRebootUnparsable(reason string, event interpreter.Event) {
+// AddUnparsableEventType adds to the unparsable event types counter, labeled by the reason of err's kind, using
+// "other" as the event type if the event's type isn't one of the known event types.
+func (m *Measures) AddUnparsableEventType(parserName string, err error, event interpreter.Event) {
+	if m.UnparsableEventTypeCount != nil {
+		eventType, typeErr := event.EventType()
+		if typeErr != nil {
+			eventType = unknownLabelValue
+		} else if !m.UnparsableEventTypes[eventType] {
+			eventType = otherEventType
+		}
+
+		m.UnparsableEventTypeCount.With(prometheus.Labels{parserLabel: parserName,
+			reasonLabel: taxonomy.Reason(err), eventTypeLabel: eventType}).Add(1.0)
+	}
+}
+
+// AddRebootUnparsable adds to the RebootUnparsable counter, labeled by the reason of err's kind.
+func (m *Measures) AddRebootUnparsable(err error, event interpreter.Event) {
 	if m.RebootUnparsableCount != nil {
 		hardwareVal, firmwareVal, _ := getHardwareFirmware(event)
 		partner := basculechecks.DeterminePartnerMetric(event.PartnerIDs)
 		m.RebootUnparsableCount.With(prometheus.Labels{firmwareLabel: firmwareVal,
-			hardwareLabel: hardwareVal, partnerIDLabel: partner, reasonLabel: reason}).Add(1.0)
+			hardwareLabel: hardwareVal, partnerIDLabel: partner, reasonLabel: taxonomy.Reason(err)}).Add(1.0)
+	}
+}
+
+// AddNewDevice adds to the new device counter.
+func (m *Measures) AddNewDevice(parserName string) {
+	if m.NewDeviceCount != nil {
+		m.NewDeviceCount.With(prometheus.Labels{parserLabel: parserName}).Add(1.0)
+	}
+}
+
+// AddZeroDuration adds to the zero duration counter.
+func (m *Measures) AddZeroDuration(calculation string) {
+	if m.ZeroDurationCount != nil {
+		m.ZeroDurationCount.With(prometheus.Labels{calculationLabel: calculation}).Add(1.0)
+	}
+}
+
+// zeroDurationFunc returns a function that counts a zero duration for the calculation and returns whether
+// the zero duration should be recorded, according to the policy.
+func (m *Measures) zeroDurationFunc(calculation string, policy enums.ZeroDurationPolicy) func() bool {
+	return func() bool {
+		m.AddZeroDuration(calculation)
+		return policy == enums.RecordZeroDuration
 	}
 }
 
@@ -179,12 +435,11 @@ func AddCycleError(counter *prometheus.CounterVec, event interpreter.Event, erro
 	}
 }
 
-// AddDuration adds the duration to the specific histogram.
-func AddDuration(histogram prometheus.ObserverVec, duration float64, event interpreter.Event) {
-	if histogram != nil {
-		labels := getTimeElapsedHistogramLabels(event)
-		histogram.With(labels).Observe(duration)
-	}
+// AddDuration adds the duration to the specific histogram.  If allowedPartners are given, the histogram must have
+// the partner_id label, which is set to the event's partner if it is allowed or "other" otherwise.
+func AddDuration(histogram prometheus.ObserverVec, duration float64, event interpreter.Event, allowedPartners ...string) {
+	partners := newDurationPartners(PartnerLabelConfig{Enabled: len(allowedPartners) > 0, Allowed: allowedPartners})
+	observeDuration(histogram, duration, partners.labels(event), event, false)
 }
 
 // get hardware and firmware values from event metadata, returning false if either one or both are not found
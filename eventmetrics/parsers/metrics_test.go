@@ -8,6 +8,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/touchstone"
 	"github.com/xmidt-org/touchstone/touchtest"
@@ -18,6 +19,10 @@ const (
 	testReason = "testReason"
 )
 
+var (
+	testReasonErr = taxonomy.New(taxonomy.Kind{Reason: testReason, Code: 100}, "test error")
+)
+
 func TestAddMetadata(t *testing.T) {
 	m := Measures{
 		MetadataFields: prometheus.NewCounterVec(
@@ -94,13 +99,13 @@ func TestAddRebootUnparsable(t *testing.T) {
 
 	expectedRebootUnparsableCount.With(prometheus.Labels{firmwareLabel: "fw",
 		hardwareLabel: "hw", partnerIDLabel: "partner", reasonLabel: testReason}).Add(1.0)
-	m.AddRebootUnparsable(testReason, testEvent)
+	m.AddRebootUnparsable(testReasonErr, testEvent)
 	testAssert := touchtest.New(t)
 	testAssert.Expect(expectedRegistry)
 	assert.True(t, testAssert.GatherAndCompare(actualRegistry))
 
 	m = Measures{}
-	m.AddRebootUnparsable(testReason, testEvent)
+	m.AddRebootUnparsable(testReasonErr, testEvent)
 }
 
 func TestAddEventError(t *testing.T) {
@@ -432,3 +437,92 @@ func testHistogramExistsErr(t *testing.T) {
 	)
 	assert.NotNil(measures.TimeElapsedHistograms[o.Name])
 }
+
+func TestConfigVariantLabels(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         ConfigVariantConfig
+		expectedLabels prometheus.Labels
+		expectedErr    error
+	}{
+		{
+			description: "No variant",
+			config:      ConfigVariantConfig{Allowed: []string{"a", "b"}},
+		},
+		{
+			description:    "Allowed variant",
+			config:         ConfigVariantConfig{Name: "b", Allowed: []string{"a", "b"}},
+			expectedLabels: prometheus.Labels{configVariantLabel: "b"},
+		},
+		{
+			description: "Variant not allowed",
+			config:      ConfigVariantConfig{Name: "c", Allowed: []string{"a", "b"}},
+			expectedErr: errInvalidConfigVariant,
+		},
+		{
+			description: "No allowed variants",
+			config:      ConfigVariantConfig{Name: "a"},
+			expectedErr: errInvalidConfigVariant,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			labels, err := configVariantLabels(tc.config)
+			assert.Equal(tc.expectedLabels, labels)
+			assert.ErrorIs(err, tc.expectedErr)
+		})
+	}
+}
+
+func TestAddUnparsableEventType(t *testing.T) {
+	tests := []struct {
+		description       string
+		destination       string
+		expectedEventType string
+	}{
+		{
+			description:       "Known event type",
+			destination:       "event:device-status/mac:112233445566/fully-manageable/1614265173",
+			expectedEventType: "fully-manageable",
+		},
+		{
+			description:       "Configured event type",
+			destination:       "event:device-status/mac:112233445566/custom-event",
+			expectedEventType: "custom-event",
+		},
+		{
+			description:       "Other event type",
+			destination:       "event:device-status/mac:112233445566/random-event",
+			expectedEventType: otherEventType,
+		},
+		{
+			description:       "Invalid destination",
+			destination:       "some-destination",
+			expectedEventType: unknownLabelValue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			m := Measures{
+				UnparsableEventTypeCount: prometheus.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "unparsableEventTypes",
+						Help: "unparsableEventTypes",
+					},
+					[]string{parserLabel, reasonLabel, eventTypeLabel},
+				),
+				UnparsableEventTypes: knownEventTypes(UnparsableEventTypesConfig{EventTypes: []string{"custom-event"}}),
+			}
+
+			m.AddUnparsableEventType("testParser", testReasonErr, interpreter.Event{Destination: tc.destination})
+			assert.Equal(1.0, testutil.ToFloat64(m.UnparsableEventTypeCount.WithLabelValues("testParser", testReason, tc.expectedEventType)))
+		})
+	}
+
+	m := Measures{}
+	m.AddUnparsableEventType("testParser", testReasonErr, interpreter.Event{})
+}
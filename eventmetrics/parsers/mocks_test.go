@@ -1,6 +1,7 @@
 package parsers
 
 import (
+	"context"
 	"github.com/stretchr/testify/mock"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/interpreter/validation"
@@ -46,11 +47,23 @@ type mockEventClient struct {
 	mock.Mock
 }
 
-func (m *mockEventClient) GetEvents(deviceID string) []interpreter.Event {
+func (m *mockEventClient) GetEvents(_ context.Context, deviceID string) []interpreter.Event {
 	args := m.Called(deviceID)
 	return args.Get(0).([]interpreter.Event)
 }
 
+// GetEventsBatch gets the events of each device with GetEvents, so that tests only set up GetEvents.
+func (m *mockEventClient) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	results := make(map[string][]interpreter.Event, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		if _, found := results[deviceID]; !found {
+			results[deviceID] = m.GetEvents(ctx, deviceID)
+		}
+	}
+
+	return results
+}
+
 type mockFinder struct {
 	mock.Mock
 }
@@ -105,3 +118,20 @@ func (t testTaggedErrors) UniqueTags() []validation.Tag {
 	}
 	return tags
 }
+
+type mockCalculator struct {
+	mock.Mock
+}
+
+func (m *mockCalculator) Calculate(events []interpreter.Event, event interpreter.Event) error {
+	args := m.Called(events, event)
+	return args.Error(0)
+}
+
+type mockDurationExporter struct {
+	mock.Mock
+}
+
+func (m *mockDurationExporter) Export(record DurationRecord) {
+	m.Called(record)
+}
@@ -0,0 +1,75 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultNativeHistogramBucketFactor     = 1.1
+	defaultNativeHistogramMaxBucketNumber  = 160
+	defaultNativeHistogramMinResetDuration = time.Hour
+)
+
+// NativeHistogramConfig configures exposing a parser's duration histograms as Prometheus native histograms,
+// which have sparse, exponential buckets instead of the fixed classic buckets.
+type NativeHistogramConfig struct {
+	// Enabled turns on native histograms.  If false, classic histograms are used.
+	Enabled bool
+
+	// BucketFactor is the maximum growth factor from one bucket to the next, determining the resolution
+	// of the histograms.  It must be greater than 1.  Defaults to 1.1.
+	BucketFactor float64
+
+	// MaxBucketNumber is the maximum number of buckets in each histogram before its resolution is
+	// reduced or it is reset.  Defaults to 160.
+	MaxBucketNumber uint32
+
+	// MinResetDuration is the minimum time between resets of a histogram that reached its maximum
+	// number of buckets.  Defaults to 1h.
+	MinResetDuration time.Duration
+}
+
+// apply returns the histogram options with the classic buckets replaced by native histogram settings
+// if native histograms are enabled.
+func (c NativeHistogramConfig) apply(o prometheus.HistogramOpts) prometheus.HistogramOpts {
+	if !c.Enabled {
+		return o
+	}
+
+	if c.BucketFactor <= 1 {
+		c.BucketFactor = defaultNativeHistogramBucketFactor
+	}
+
+	if c.MaxBucketNumber == 0 {
+		c.MaxBucketNumber = defaultNativeHistogramMaxBucketNumber
+	}
+
+	if c.MinResetDuration <= 0 {
+		c.MinResetDuration = defaultNativeHistogramMinResetDuration
+	}
+
+	o.Buckets = nil
+	o.NativeHistogramBucketFactor = c.BucketFactor
+	o.NativeHistogramMaxBucketNumber = c.MaxBucketNumber
+	o.NativeHistogramMinResetDuration = c.MinResetDuration
+	return o
+}
@@ -0,0 +1,98 @@
+package parsers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNativeHistogramConfigApply(t *testing.T) {
+	classic := prometheus.HistogramOpts{
+		Name:    "test",
+		Buckets: []float64{60, 120},
+	}
+
+	tests := []struct {
+		description  string
+		config       NativeHistogramConfig
+		expectedOpts prometheus.HistogramOpts
+	}{
+		{
+			description:  "Disabled",
+			expectedOpts: classic,
+		},
+		{
+			description: "Defaults",
+			config:      NativeHistogramConfig{Enabled: true},
+			expectedOpts: prometheus.HistogramOpts{
+				Name:                            "test",
+				NativeHistogramBucketFactor:     defaultNativeHistogramBucketFactor,
+				NativeHistogramMaxBucketNumber:  defaultNativeHistogramMaxBucketNumber,
+				NativeHistogramMinResetDuration: defaultNativeHistogramMinResetDuration,
+			},
+		},
+		{
+			description: "Custom",
+			config: NativeHistogramConfig{
+				Enabled:          true,
+				BucketFactor:     1.5,
+				MaxBucketNumber:  20,
+				MinResetDuration: time.Minute,
+			},
+			expectedOpts: prometheus.HistogramOpts{
+				Name:                            "test",
+				NativeHistogramBucketFactor:     1.5,
+				NativeHistogramMaxBucketNumber:  20,
+				NativeHistogramMinResetDuration: time.Minute,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedOpts, tc.config.apply(classic))
+		})
+	}
+}
+
+func TestNativeHistogramDurations(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+	testMeasures := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
+	config := RebootParserConfig{NativeHistograms: NativeHistogramConfig{Enabled: true}}
+
+	_, err := createDurationCalculators(testFactory, []TimeElapsedConfig{{Name: "reboot_to_manageable", EventType: "reboot-pending"}}, testMeasures, config, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+
+	event := interpreter.Event{
+		Metadata: map[string]string{
+			firmwareMetadataKey:     "fw",
+			hardwareMetadataKey:     "hw",
+			rebootReasonMetadataKey: "reason",
+		},
+	}
+	for _, duration := range []float64{45, 90, 90, 600} {
+		AddDuration(testMeasures.TimeElapsedHistograms["reboot_to_manageable"], duration, event)
+	}
+
+	families, err := registry.Gather()
+	assert.Nil(err)
+	if assert.Len(families, 1) {
+		assert.Equal("reboot_to_manageable", families[0].GetName())
+		histogram := families[0].GetMetric()[0].GetHistogram()
+		assert.Equal(uint64(4), histogram.GetSampleCount())
+		assert.Equal(825.0, histogram.GetSampleSum())
+		// native histograms have a schema and sparse buckets rather than classic buckets.
+		assert.Empty(histogram.GetBucket())
+		assert.NotNil(histogram.Schema)
+		assert.NotEmpty(histogram.GetPositiveSpan())
+		assert.NotEmpty(histogram.GetPositiveDelta())
+	}
+}
@@ -0,0 +1,69 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"github.com/xmidt-org/interpreter"
+)
+
+// NewDevicesConfig configures counting the events of likely brand-new devices separately from unparsable events.
+type NewDevicesConfig struct {
+	// Enabled turns on counting likely brand-new devices in the new_device_count metric instead of
+	// the unparsable metrics.
+	Enabled bool
+}
+
+// isNewDevice returns true if the event is likely from a device seen for the first time: the history of events has
+// no session before the current event's session, while the start of the current session is either the current event
+// or found in the history.  A history without the start of the current session is treated as missing history rather
+// than a new device.
+func isNewDevice(events []interpreter.Event, currentEvent interpreter.Event) bool {
+	currentBootTime, err := currentEvent.BootTime()
+	if err != nil || currentBootTime <= 0 {
+		return false
+	}
+
+	sessionStarted := isSessionStart(currentEvent, currentBootTime)
+	for _, event := range events {
+		bootTime, err := event.BootTime()
+		if err != nil || bootTime <= 0 {
+			continue
+		}
+
+		if bootTime < currentBootTime {
+			return false
+		}
+
+		if isSessionStart(event, currentBootTime) {
+			sessionStarted = true
+		}
+	}
+
+	return sessionStarted
+}
+
+// isSessionStart returns true if the event is the online event that starts the session with the boot-time given.
+func isSessionStart(event interpreter.Event, bootTime int64) bool {
+	eventType, err := event.EventType()
+	if err != nil || eventType != interpreter.OnlineEventType {
+		return false
+	}
+
+	eventBootTime, _ := event.BootTime()
+	return eventBootTime == bootTime
+}
@@ -0,0 +1,181 @@
+package parsers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"go.uber.org/zap"
+)
+
+func TestIsNewDevice(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	previousBoot := now.Add(-48 * time.Hour)
+	currentBoot := now.Add(-1 * time.Hour)
+	onlineEvent := sessionEvent(onlineEventType, currentBoot, currentBoot.Add(time.Minute), "online")
+	fullyManageableEvent := sessionEvent(fullyManageableEventType, currentBoot, now, "fully-manageable")
+
+	tests := []struct {
+		description  string
+		events       []interpreter.Event
+		currentEvent interpreter.Event
+		expected     bool
+	}{
+		{
+			description:  "First event is the session start",
+			currentEvent: onlineEvent,
+			expected:     true,
+		},
+		{
+			description:  "Session start in history",
+			events:       []interpreter.Event{onlineEvent, sessionEvent(interpreter.OperationalEventType, currentBoot, now.Add(-1*time.Minute), "1")},
+			currentEvent: fullyManageableEvent,
+			expected:     true,
+		},
+		{
+			description:  "Empty history without session start",
+			currentEvent: fullyManageableEvent,
+		},
+		{
+			description:  "History without session start",
+			events:       []interpreter.Event{sessionEvent(interpreter.OperationalEventType, currentBoot, now.Add(-1*time.Minute), "1")},
+			currentEvent: fullyManageableEvent,
+		},
+		{
+			description:  "Previous session in history",
+			events:       []interpreter.Event{sessionEvent(offlineEventType, previousBoot, currentBoot, "1"), onlineEvent},
+			currentEvent: fullyManageableEvent,
+		},
+		{
+			description:  "Online event from another session",
+			events:       []interpreter.Event{sessionEvent(onlineEventType, now, now, "1")},
+			currentEvent: fullyManageableEvent,
+		},
+		{
+			description:  "Missing boot-time",
+			currentEvent: interpreter.Event{Destination: "event:device-status/mac:112233445566/online"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isNewDevice(tc.events, tc.currentEvent))
+		})
+	}
+}
+
+func TestNewDeviceParsers(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	currentBoot := now.Add(-1 * time.Hour)
+	onlineEvent := sessionEvent(onlineEventType, currentBoot, currentBoot.Add(time.Minute), "online")
+	fullyManageableEvent := sessionEvent(fullyManageableEventType, currentBoot, now, "fully-manageable")
+	operationalEvent := sessionEvent(interpreter.OperationalEventType, currentBoot, now, "operational")
+
+	tests := []struct {
+		description        string
+		parser             func(EventClient, Measures) (queue.Parser, error)
+		event              interpreter.Event
+		history            []interpreter.Event
+		countNewDevices    bool
+		expectedNewDevice  float64
+		expectedUnparsable float64
+	}{
+		{
+			description: "Session uptime new device",
+			parser: func(client EventClient, m Measures) (queue.Parser, error) {
+				return NewSessionUptimeParser(SessionUptimeConfig{}, client, newTestUptimeHistogram(), m, zap.NewNop())
+			},
+			event:             onlineEvent,
+			countNewDevices:   true,
+			expectedNewDevice: 1,
+		},
+		{
+			description: "Session uptime new devices not counted",
+			parser: func(client EventClient, m Measures) (queue.Parser, error) {
+				return NewSessionUptimeParser(SessionUptimeConfig{}, client, newTestUptimeHistogram(), m, zap.NewNop())
+			},
+			event:              onlineEvent,
+			expectedUnparsable: 1,
+		},
+		{
+			description: "Session uptime missing history",
+			parser: func(client EventClient, m Measures) (queue.Parser, error) {
+				return NewSessionUptimeParser(SessionUptimeConfig{EventType: interpreter.OperationalEventType}, client, newTestUptimeHistogram(), m, zap.NewNop())
+			},
+			event:              operationalEvent,
+			countNewDevices:    true,
+			expectedUnparsable: 1,
+		},
+		{
+			description: "Reboot new device",
+			parser: func(client EventClient, m Measures) (queue.Parser, error) {
+				return newTestRebootParser(client, m), nil
+			},
+			event:             fullyManageableEvent,
+			history:           []interpreter.Event{onlineEvent},
+			countNewDevices:   true,
+			expectedNewDevice: 1,
+		},
+		{
+			description: "Reboot missing history",
+			parser: func(client EventClient, m Measures) (queue.Parser, error) {
+				return newTestRebootParser(client, m), nil
+			},
+			event:              fullyManageableEvent,
+			countNewDevices:    true,
+			expectedUnparsable: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", testUptimeDeviceID).Return(tc.history)
+			m := Measures{
+				TotalUnparsableCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "testUnparsable",
+					Help: "testUnparsable",
+				}, []string{parserLabel}),
+			}
+			if tc.countNewDevices {
+				m.NewDeviceCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "testNewDevices",
+					Help: "testNewDevices",
+				}, []string{parserLabel})
+			}
+
+			parser, err := tc.parser(client, m)
+			assert.Nil(err)
+			parser.Parse(context.Background(), tc.event)
+
+			assert.Equal(tc.expectedUnparsable, testutil.ToFloat64(m.TotalUnparsableCount.WithLabelValues(parser.Name())))
+			if tc.countNewDevices {
+				assert.Equal(tc.expectedNewDevice, testutil.ToFloat64(m.NewDeviceCount.WithLabelValues(parser.Name())))
+			}
+		})
+	}
+}
+
+// newTestRebootParser creates a reboot parser whose validation fails without a previous boot-cycle.
+func newTestRebootParser(client EventClient, m Measures) *RebootDurationParser {
+	validator := new(mockParserValidator)
+	validator.On("Validate", mock.Anything, mock.Anything).Return(false, errValidation)
+	return &RebootDurationParser{
+		name:                 "test_reboot_parser",
+		client:               client,
+		relevantEventsParser: history.LastCycleToCurrentParser(nil),
+		parserValidators:     []ParserValidator{validator},
+		measures:             m,
+		logger:               zap.NewNop(),
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"errors"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/interpreter/history"
 	"github.com/xmidt-org/interpreter/validation"
@@ -32,8 +34,8 @@ const (
 )
 
 var (
-	errFatal          = errors.New("fatal error")
-	errValidation     = errors.New("validation error")
+	errFatal          = taxonomy.ErrFatal
+	errValidation     = taxonomy.ErrValidation
 	errNonExistentKey = errors.New("key does not exist")
 )
 
@@ -141,7 +143,7 @@ func logCycleErr(currentEvent interpreter.Event, err error, counter *prometheus.
 		deviceIDKey = "device id"
 	)
 
-	deviceID, _ := currentEvent.DeviceID()
+	deviceID, _ := deviceid.FromEvent(currentEvent)
 	var taggedErrs validation.TaggedErrors
 	var taggedErr validation.TaggedError
 	if errors.As(err, &taggedErrs) {
@@ -167,12 +169,15 @@ func logEventError(logger *zap.Logger, counter *prometheus.CounterVec, err error
 		deviceIDKey = "device id"
 	)
 
-	deviceID, _ := event.DeviceID()
+	deviceID, _ := deviceid.FromEvent(event)
 	eventID := event.TransactionUUID
 
 	var taggedErrs validation.TaggedErrors
 	var taggedErr validation.TaggedError
-	if errors.As(err, &taggedErrs) {
+	if errors.Is(err, ErrImplausibleBootTime) {
+		logger.Info("event validation error", zap.String("tags", implausibleBootTimeReason), zap.String(eventIDKey, eventID), zap.String(deviceIDKey, deviceID))
+		AddEventError(counter, event, implausibleBootTimeReason)
+	} else if errors.As(err, &taggedErrs) {
 		logger.Info("event validation error", zap.Strings("tags", validation.TagsToStrings(taggedErrs.UniqueTags())), zap.String(eventIDKey, eventID), zap.String(deviceIDKey, deviceID))
 		for _, tag := range taggedErrs.UniqueTags() {
 			AddEventError(counter, event, tag.String())
@@ -212,6 +212,11 @@ func TestLogEventError(t *testing.T) {
 			expectedTags: []string{validation.Unknown.String()},
 			err:          errors.New("test"),
 		},
+		{
+			description:  "implausible boot-time",
+			expectedTags: []string{implausibleBootTimeReason},
+			err:          validation.InvalidBootTimeErr{OriginalErr: ErrImplausibleBootTime},
+		},
 	}
 
 	for _, tc := range tests {
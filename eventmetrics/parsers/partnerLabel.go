@@ -0,0 +1,82 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/bascule/basculechecks"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	otherPartnerLabelValue = "other"
+)
+
+// PartnerLabelConfig configures adding the partner_id label to the reboot duration parser's histograms so that
+// durations can be compared across partners.
+type PartnerLabelConfig struct {
+	// Enabled turns on the partner_id label.
+	Enabled bool
+
+	// Allowed are the partners used as label values, guarding against unbounded label cardinality.  All other
+	// partners are labeled as "other".
+	Allowed []string
+}
+
+// durationPartners is the set of partners used as partner_id label values of duration histograms.  If it is nil,
+// duration histograms don't have the partner_id label.
+type durationPartners map[string]bool
+
+func newDurationPartners(config PartnerLabelConfig) durationPartners {
+	if !config.Enabled {
+		return nil
+	}
+
+	partners := make(durationPartners, len(config.Allowed))
+	for _, partner := range config.Allowed {
+		partners[partner] = true
+	}
+
+	return partners
+}
+
+// labelNames returns the label names of a duration histogram.
+func (p durationPartners) labelNames() []string {
+	names := []string{firmwareLabel, hardwareLabel, rebootReasonLabel}
+	if p != nil {
+		names = append(names, partnerIDLabel)
+	}
+
+	return names
+}
+
+// labels returns the labels of the event's duration observation.
+func (p durationPartners) labels(event interpreter.Event) prometheus.Labels {
+	labels := getTimeElapsedHistogramLabels(event)
+	if p == nil {
+		return labels
+	}
+
+	partner := basculechecks.DeterminePartnerMetric(event.PartnerIDs)
+	if !p[partner] {
+		partner = otherPartnerLabelValue
+	}
+
+	labels[partnerIDLabel] = partner
+	return labels
+}
@@ -0,0 +1,116 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDurationPartnersLabels(t *testing.T) {
+	event := interpreter.Event{
+		PartnerIDs: []string{"comcast"},
+		Metadata: map[string]string{
+			hardwareMetadataKey:     "hw",
+			firmwareMetadataKey:     "fw",
+			rebootReasonMetadataKey: "reason",
+		},
+	}
+
+	tests := []struct {
+		description        string
+		config             PartnerLabelConfig
+		event              interpreter.Event
+		expectedLabelNames []string
+		expectedLabels     prometheus.Labels
+	}{
+		{
+			description:        "Disabled",
+			config:             PartnerLabelConfig{Allowed: []string{"comcast"}},
+			event:              event,
+			expectedLabelNames: []string{firmwareLabel, hardwareLabel, rebootReasonLabel},
+			expectedLabels:     prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason"},
+		},
+		{
+			description:        "Allowed partner",
+			config:             PartnerLabelConfig{Enabled: true, Allowed: []string{"comcast", "sky"}},
+			event:              event,
+			expectedLabelNames: []string{firmwareLabel, hardwareLabel, rebootReasonLabel, partnerIDLabel},
+			expectedLabels:     prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason", partnerIDLabel: "comcast"},
+		},
+		{
+			description:        "Other partner",
+			config:             PartnerLabelConfig{Enabled: true, Allowed: []string{"sky"}},
+			event:              event,
+			expectedLabelNames: []string{firmwareLabel, hardwareLabel, rebootReasonLabel, partnerIDLabel},
+			expectedLabels:     prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason", partnerIDLabel: otherPartnerLabelValue},
+		},
+		{
+			description:        "No allowed partners",
+			config:             PartnerLabelConfig{Enabled: true},
+			event:              interpreter.Event{},
+			expectedLabelNames: []string{firmwareLabel, hardwareLabel, rebootReasonLabel, partnerIDLabel},
+			expectedLabels: prometheus.Labels{firmwareLabel: unknownLabelValue, hardwareLabel: unknownLabelValue,
+				rebootReasonLabel: unknownLabelValue, partnerIDLabel: otherPartnerLabelValue},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			partners := newDurationPartners(tc.config)
+			assert.Equal(tc.expectedLabelNames, partners.labelNames())
+			assert.Equal(tc.expectedLabels, partners.labels(tc.event))
+		})
+	}
+}
+
+func TestAddDurationPartners(t *testing.T) {
+	assert := assert.New(t)
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "testPartnerHistogram",
+		Help: "testPartnerHistogram",
+	}, []string{firmwareLabel, hardwareLabel, rebootReasonLabel, partnerIDLabel})
+	event := interpreter.Event{PartnerIDs: []string{"comcast"}}
+
+	AddDuration(histogram, 5.0, event, "comcast")
+	AddDuration(histogram, 5.0, interpreter.Event{PartnerIDs: []string{"sky"}}, "comcast")
+	assert.Equal(2, testutil.CollectAndCount(histogram))
+}
+
+func TestPartnerLabelDurations(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+	testMeasures := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)}
+	config := RebootParserConfig{PartnerLabel: PartnerLabelConfig{Enabled: true, Allowed: []string{"comcast"}}}
+
+	_, err := createDurationCalculators(testFactory, []TimeElapsedConfig{{Name: "reboot_to_manageable", EventType: "reboot-pending"}}, testMeasures, config, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+
+	callback, err := createTimeElapsedCallback(testMeasures, "reboot_to_manageable", newDurationPartners(config.PartnerLabel))
+	assert.Nil(err)
+	callback(interpreter.Event{PartnerIDs: []string{"comcast"}}, interpreter.Event{}, 60.0)
+	callback(interpreter.Event{PartnerIDs: []string{"sky"}}, interpreter.Event{}, 120.0)
+
+	families, err := registry.Gather()
+	assert.Nil(err)
+	if !assert.Len(families, 1) {
+		return
+	}
+
+	partners := make(map[string]float64)
+	for _, metric := range families[0].GetMetric() {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == partnerIDLabel {
+				partners[label.GetValue()] = metric.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	assert.Equal(map[string]float64{"comcast": 60.0, otherPartnerLabelValue: 120.0}, partners)
+}
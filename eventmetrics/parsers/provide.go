@@ -25,10 +25,12 @@ import (
 	"github.com/xmidt-org/interpreter/history"
 	"github.com/xmidt-org/interpreter/validation"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xmidt-org/arrange"
 	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
 	"github.com/xmidt-org/glaukos/eventmetrics/queue"
 	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/touchstone"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
@@ -38,8 +40,11 @@ const (
 	defaultValidTo                    = time.Hour
 	defaultMinBootDuration            = 10 * time.Second
 	defaultBirthdateAlignmentDuration = 60 * time.Second
+	defaultMaxDeviceIDs               = 5
 
 	rebootPendingEventType = "reboot-pending"
+
+	rebootDurationParserName = "reboot_duration_parser"
 )
 
 var (
@@ -53,6 +58,56 @@ type RebootParserConfig struct {
 	EventValidators         []EventValidationConfig
 	CycleValidators         []CycleValidationConfig
 	TimeElapsedCalculations []TimeElapsedConfig
+	DeviceIDs               DeviceIDsConfig
+
+	// BootTimeBounds configures rejecting events with an implausible boot-time, checked before any of the
+	// EventValidators.
+	BootTimeBounds BootTimeBoundsConfig
+
+	// MetricPrefix is prepended, followed by an underscore, to the names of the parser's histograms and
+	// counters.  If this is empty, the metrics are not prefixed.
+	MetricPrefix string
+
+	// ZeroDurationPolicy determines whether durations calculated as exactly zero are rejected as calculation
+	// errors or recorded, either "reject" or "record".  Defaults to "reject".
+	ZeroDurationPolicy string
+
+	// BootDurationBounds limits the boot durations recorded, rejecting the longer durations and allowing
+	// slightly negative durations caused by clock skew between the boot-time and birthdate.
+	BootDurationBounds DurationBounds
+
+	// TimeElapsedBounds limits the durations of the time elapsed calculations recorded.
+	TimeElapsedBounds DurationBounds
+
+	// NativeHistograms configures exposing the parser's duration histograms as native histograms.
+	NativeHistograms NativeHistogramConfig
+
+	// Summaries configures exposing the parser's duration metrics as summaries instead of histograms.
+	Summaries SummaryConfig
+
+	// PartnerLabel configures adding the partner_id label to the parser's duration metrics.
+	PartnerLabel PartnerLabelConfig
+
+	// FinderDiagnostics configures logging why the parser's finders selected the events they returned.
+	FinderDiagnostics FinderDiagnosticsConfig
+
+	// InferredSessions configures inferring sessions from birthdates for devices that don't send boot-times.
+	InferredSessions InferredSessionsConfig
+
+	// DerivedDurations are the metrics derived from the durations of two of the parser's calculations.
+	DerivedDurations []DerivedDurationConfig
+}
+
+// DeviceIDsConfig configures the extraction of additional device ids from an event, so that the
+// event is processed for each of the devices it references.
+type DeviceIDsConfig struct {
+	// MetadataKey is the metadata key holding a comma-separated list of additional device ids.
+	// If this is empty, only the device id in the event's destination is used.
+	MetadataKey string
+
+	// MaxCount is the maximum number of device ids processed per event, including the device id in the
+	// event's destination.  Defaults to 5.
+	MaxCount int
 }
 
 // TimeElapsedConfig contains information for calculating the time between a fully-manageable event and another event.
@@ -60,6 +115,32 @@ type TimeElapsedConfig struct {
 	Name        string
 	SessionType string
 	EventType   string
+
+	// StartTimeSource is the time of the found event that the duration starts at, either "birthdate" or
+	// "boot-time".  Defaults to "birthdate".
+	StartTimeSource string
+
+	// EndTimeSource is the time of the fully-manageable event that the duration ends at, either "birthdate"
+	// or "boot-time".  Defaults to "birthdate".
+	EndTimeSource string
+
+	// CalculatorType determines how the duration is calculated: "event-to-current" calculates the time between
+	// the found event and the fully-manageable event, "boot-to-event" the time between the boot-time and the
+	// birthdate of the found event, "between-events" the time between the found event and the event with
+	// EndEventType, and "chain" the time between each event of Chain and the next.  Defaults to
+	// "event-to-current".
+	CalculatorType string
+
+	// EndEventType and EndSessionType determine the event that the duration ends at for the between-events
+	// calculator type.  Like SessionType, EndSessionType defaults to the previous session.
+	EndEventType   string
+	EndSessionType string
+
+	// Chain is the events, in order, of the chain calculator type, which records the time elapsed between each
+	// event and the next, and between the last event and the fully-manageable event, along with the total time
+	// from the first event to the fully-manageable event under Name.  EventType, SessionType, and
+	// StartTimeSource are ignored for chains.
+	Chain []ChainEventConfig
 }
 
 // TimeValidationConfig is the config used for time validation.
@@ -88,12 +169,15 @@ type ValidatorsIn struct {
 
 type RebootParserIn struct {
 	fx.In
-	Name             string               `name:"reboot_parser_name"`
-	Logger           *zap.Logger          `name:"reboot_parser_logger"`
-	ParserValidators []ParserValidator    `group:"reboot_parser_validators"`
-	Calculators      []DurationCalculator `group:"duration_calculators"`
-	Measures         Measures
-	CodexClient      *events.CodexClient
+	Name                string               `name:"reboot_parser_name"`
+	Logger              *zap.Logger          `name:"reboot_parser_logger"`
+	ParserValidators    []ParserValidator    `group:"reboot_parser_validators"`
+	Calculators         []DurationCalculator `group:"duration_calculators"`
+	InferredCalculators []DurationCalculator `group:"inferred_duration_calculators"`
+	Measures            Measures
+	EventSource         events.EventSource
+	Config              RebootParserConfig
+	Factory             *touchstone.Factory
 }
 
 // Provide bundles everything needed for setting up all of the event objects
@@ -107,25 +191,26 @@ func Provide() fx.Option {
 		provideParserValidators(),
 		fx.Provide(
 			arrange.UnmarshalKey("rebootDurationParser", RebootParserConfig{}),
+			arrange.UnmarshalKey("metadataParser", MetadataConfig{}),
 			arrange.UnmarshalKey("rebootDurationParser.timeElapsedCalculations", []TimeElapsedConfig{}),
+			arrange.UnmarshalKey("availabilityParser", AvailabilityConfig{}),
+			arrange.UnmarshalKey("sessionUptimeParser", SessionUptimeConfig{}),
+			arrange.UnmarshalKey("coldBootParser", ColdBootConfig{}),
+			arrange.UnmarshalKey("firstOnlineParser", FirstOnlineConfig{}),
+			arrange.UnmarshalKey("crashLoopParser", CrashLoopConfig{}),
+			arrange.UnmarshalKey("sessionDurationParser", SessionDurationConfig{}),
+			arrange.UnmarshalKey("histogramBuckets", HistogramBucketsConfig{}),
+			arrange.UnmarshalKey("shadowParser", ShadowParserConfig{}),
 			fx.Annotated{
 				Name: "reboot_parser_name",
 				Target: func() string {
-					return "reboot_duration_parser"
+					return rebootDurationParserName
 				},
 			},
 			fx.Annotated{
 				Name: "event_validator",
 				Target: func(config RebootParserConfig) (validation.Validator, error) {
-					var validators validation.Validators
-					for _, config := range config.EventValidators {
-						validator, err := createEventValidator(config)
-						if err != nil {
-							return nil, err
-						}
-						validators = append(validators, validator)
-					}
-					return validators, nil
+					return createEventValidators(config.EventValidators, config.BootTimeBounds)
 				},
 			},
 			fx.Annotated{
@@ -147,6 +232,25 @@ func Provide() fx.Option {
 				},
 			},
 		),
+		fx.Invoke(
+			func(reboot RebootParserConfig, availability AvailabilityConfig, sessionUptime SessionUptimeConfig, coldBoot ColdBootConfig, firstOnline FirstOnlineConfig,
+				crashLoop CrashLoopConfig, sessionDuration SessionDurationConfig, shadow ShadowParserConfig) error {
+				return validateMetricPrefixes(map[string]string{
+					"rebootDurationParser":  reboot.MetricPrefix,
+					"shadowParser":          shadow.metricPrefix(),
+					"availabilityParser":    availability.MetricPrefix,
+					"sessionUptimeParser":   sessionUptime.MetricPrefix,
+					"coldBootParser":        coldBoot.MetricPrefix,
+					"firstOnlineParser":     firstOnline.MetricPrefix,
+					"crashLoopParser":       crashLoop.MetricPrefix,
+					"sessionDurationParser": sessionDuration.MetricPrefix,
+				})
+			},
+			// the parsers are required so that all of the duration histograms have been created.
+			func(config HistogramBucketsConfig, buckets BucketDefinitionsIn, _ queue.ParsersIn, logger *zap.Logger) error {
+				return checkHistogramBuckets(config, buckets.Definitions, logger)
+			},
+		),
 	)
 }
 
@@ -154,47 +258,124 @@ func provideParsers() fx.Option {
 	return fx.Provide(
 		fx.Annotated{
 			Group: "parsers",
-			Target: func(measures Measures, logger *zap.Logger) queue.Parser {
-				return &MetadataParser{
-					measures: measures,
-					name:     "metadata",
-					logger:   logger.With(zap.String("parser", "metadata")),
+			Target: func(f *touchstone.Factory, config MetadataConfig, measures Measures, logger *zap.Logger) (queue.Parser, error) {
+				distinctKeys, err := f.NewGauge(prometheus.GaugeOpts{
+					Name: "metadata_distinct_keys",
+					Help: "the number of distinct metadata keys counted in metadata_fields, not including other",
+				})
+				if err != nil {
+					return nil, err
 				}
+
+				return NewMetadataParser(config, measures, distinctKeys, logger.With(zap.String("parser", "metadata"))), nil
 			},
 		},
 		fx.Annotated{
-			Group: "parsers",
-			Target: func(parserIn RebootParserIn) queue.Parser {
-				comparators := history.Comparators([]history.Comparator{
-					history.OlderBootTimeComparator(),
-				})
-
-				return &RebootDurationParser{
-					name:                 parserIn.Name,
-					relevantEventsParser: history.LastCycleToCurrentParser(comparators),
-					parserValidators:     parserIn.ParserValidators,
-					calculators:          parserIn.Calculators,
-					measures:             parserIn.Measures,
-					client:               parserIn.CodexClient,
-					logger:               parserIn.Logger,
-				}
-			},
+			Group:  "parsers",
+			Target: createRebootDurationParser,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createAvailabilityParsers,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createSessionUptimeParsers,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createColdBootParsers,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createFirstOnlineParsers,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createCrashLoopParsers,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createSessionDurationParsers,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createShadowParsers,
+		},
+		fx.Annotated{
+			Group:  "parsers,flatten",
+			Target: createUnparsableRatioParsers,
 		},
 	)
 }
 
+// createRebootDurationParser creates the reboot duration parser from its calculators and validators.
+func createRebootDurationParser(parserIn RebootParserIn) (queue.Parser, error) {
+	derivedDurations, err := createDerivedDurations(parserIn.Factory, parserIn.Config, parserIn.Measures, parserIn.Calculators)
+	if err != nil {
+		return nil, err
+	}
+
+	comparators := history.Comparators([]history.Comparator{
+		history.OlderBootTimeComparator(),
+	})
+
+	return &RebootDurationParser{
+		name:                 parserIn.Name,
+		deviceIDsKey:         parserIn.Config.DeviceIDs.MetadataKey,
+		maxDeviceIDs:         parserIn.Config.DeviceIDs.MaxCount,
+		relevantEventsParser: history.LastCycleToCurrentParser(comparators),
+		parserValidators:     parserIn.ParserValidators,
+		calculators:          parserIn.Calculators,
+		inferredCalculators:  parserIn.InferredCalculators,
+		derivedDurations:     derivedDurations,
+		measures:             parserIn.Measures,
+		client:               parserEventClient(parserIn.EventSource, parserIn.Name, sessionHistory),
+		logger:               parserIn.Logger,
+	}, nil
+}
+
+// sessionHistory is the part of a device's history needed by the parsers whose finders search the current and
+// previous sessions.
+var sessionHistory = events.TrimConfig{MaxSessions: 2}
+
+// parserEventClient returns a client that attributes its lookups to the parser, if the event source supports it,
+// returning nil if there is no event source.  The part of the history the parser needs is kept when the event
+// source trims the histories.
+func parserEventClient(source events.EventSource, parser string, needs events.TrimConfig) EventClient {
+	if source == nil {
+		return nil
+	}
+
+	if t, ok := source.(events.TrimmingEventSource); ok {
+		t.Require(needs)
+	}
+
+	if p, ok := source.(events.ParserEventSource); ok {
+		return p.ForParser(parser)
+	}
+
+	return source
+}
+
 func provideDurationCalculators() fx.Option {
 	return fx.Provide(
 		createBootDurationCallback,
 		fx.Annotated{
-			Group: "duration_calculators",
-			Target: func(callback func(interpreter.Event, float64), loggerIn RebootLoggerIn) DurationCalculator {
-				return BootDurationCalculator(loggerIn.Logger, callback)
+			Group:  "duration_calculators",
+			Target: createBootDurationCalculator,
+		},
+		fx.Annotated{
+			Group: "duration_calculators,flatten",
+			Target: func(f *touchstone.Factory, config RebootParserConfig, configs []TimeElapsedConfig, m Measures, loggerIn RebootLoggerIn) ([]DurationCalculator, error) {
+				return createDurationCalculators(f, prefixTimeElapsedConfigs(config.MetricPrefix, configs), m, config, loggerIn)
 			},
 		},
 		fx.Annotated{
-			Group:  "duration_calculators,flatten",
-			Target: createDurationCalculators,
+			Group: "inferred_duration_calculators,flatten",
+			Target: func(f *touchstone.Factory, config RebootParserConfig, configs []TimeElapsedConfig, m Measures, loggerIn RebootLoggerIn) ([]DurationCalculator, error) {
+				return createInferredDurationCalculators(f, prefixTimeElapsedConfigs(config.MetricPrefix, configs), m, config, loggerIn)
+			},
 		},
 	)
 }
@@ -202,61 +383,108 @@ func provideDurationCalculators() fx.Option {
 func provideParserValidators() fx.Option {
 	return fx.Provide(
 		fx.Annotated{
-			Group: "reboot_parser_validators",
-			Target: func(validatorsIn ValidatorsIn, loggerIn RebootLoggerIn, m Measures) ParserValidator {
-				cycleValidation := cycleValidation{
-					validator: validatorsIn.LastCycleValidator,
-					parser:    history.LastCycleParser(nil),
-					callback: func(event interpreter.Event, valid bool, err error) {
-						if !valid {
-							logCycleErr(event, err, m.BootCycleErrorTags, loggerIn.Logger)
-						}
-					},
-				}
+			Group:  "reboot_parser_validators",
+			Target: createLastCycleParserValidator,
+		},
+		fx.Annotated{
+			Group:  "reboot_parser_validators",
+			Target: createRebootParserValidator,
+		},
+	)
+}
 
-				eventValidation := eventValidation{
-					validator: validatorsIn.EventValidator,
-					callback: func(event interpreter.Event, valid bool, err error) {
-						if !valid {
-							logEventError(loggerIn.Logger, m.EventErrorTags, err, event)
-						}
-					},
-				}
+// createBootDurationCalculator creates the calculator of the time between the boot-time and the fully-manageable
+// event.
+func createBootDurationCalculator(callback func(interpreter.Event, float64), config RebootParserConfig, m Measures, loggerIn RebootLoggerIn) DurationCalculator {
+	zeroPolicy := enums.ParseZeroDurationPolicy(config.ZeroDurationPolicy)
+	name := metricName(config.MetricPrefix, bootToManageableOpts.Name)
+	return bootDurationCalculator{
+		CalculatorFunc: BootDurationCalculator(loggerIn.Logger, callback, m.zeroDurationFunc(name, zeroPolicy), config.BootDurationBounds),
+		name:           name,
+	}
+}
 
-				return NewParserValidator(
-					cycleValidation,
-					eventValidation,
-					func(_ []interpreter.Event, _ interpreter.Event) bool {
-						return true
-					},
-				)
-			},
+// createEventValidators creates a validator that checks each event against all of the configured validations.  If
+// the boot-time bounds are enabled, they are checked first, and events with an implausible boot-time are rejected
+// without any of the other validations.
+func createEventValidators(configs []EventValidationConfig, bounds BootTimeBoundsConfig) (validation.Validator, error) {
+	var validators validation.Validators
+	for _, config := range configs {
+		validator, err := createEventValidator(config)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, validator)
+	}
+
+	if !bounds.Enabled {
+		return validators, nil
+	}
+
+	boundsValidator := BootTimeBoundsValidator(bounds.MinYear, bounds.MaxAhead, time.Now)
+	return validation.ValidatorFunc(func(e interpreter.Event) (bool, error) {
+		if valid, err := boundsValidator(e); !valid {
+			return false, err
+		}
+		return validators.Valid(e)
+	}), nil
+}
+
+// createLastCycleParserValidator creates the parser validator that checks the events in the last cycle and the
+// triggering event.
+func createLastCycleParserValidator(validatorsIn ValidatorsIn, loggerIn RebootLoggerIn, m Measures) ParserValidator {
+	cycleValidation := cycleValidation{
+		validator: validatorsIn.LastCycleValidator,
+		parser:    history.LastCycleParser(nil),
+		callback: func(event interpreter.Event, valid bool, err error) {
+			if !valid {
+				logCycleErr(event, err, m.BootCycleErrorTags, loggerIn.Logger)
+			}
 		},
-		fx.Annotated{
-			Group: "reboot_parser_validators",
-			Target: func(validatorsIn ValidatorsIn, loggerIn RebootLoggerIn, m Measures) ParserValidator {
-				rebootEventFinder := history.LastSessionFinder(validation.DestinationValidator(rebootPendingEventType))
-				cycleValidation := cycleValidation{
-					validator: validatorsIn.RebootCycleValidator,
-					parser:    history.RebootParser(nil),
-					callback: func(event interpreter.Event, valid bool, err error) {
-						if !valid {
-							logCycleErr(event, err, m.RebootCycleErrorTags, loggerIn.Logger)
-						}
-					},
-				}
+	}
 
-				return NewParserValidator(
-					cycleValidation,
-					eventValidation{},
-					func(events []interpreter.Event, currentEvent interpreter.Event) bool {
-						if _, err := rebootEventFinder.Find(events, currentEvent); err != nil {
-							return false
-						}
-						return true
-					},
-				)
-			},
+	eventValidation := eventValidation{
+		validator: validatorsIn.EventValidator,
+		callback: func(event interpreter.Event, valid bool, err error) {
+			if !valid {
+				logEventError(loggerIn.Logger, m.EventErrorTags, err, event)
+			}
+		},
+	}
+
+	return NewParserValidator(
+		cycleValidation,
+		eventValidation,
+		func(_ []interpreter.Event, _ interpreter.Event) bool {
+			return true
+		},
+	)
+}
+
+// createRebootParserValidator creates the parser validator that checks the reboot cycle, only parsing events whose
+// previous session has a reboot-pending event.
+func createRebootParserValidator(validatorsIn ValidatorsIn, loggerIn RebootLoggerIn, m Measures, config RebootParserConfig) ParserValidator {
+	rebootEventFinder := explainFinder(config.FinderDiagnostics, history.LastSessionFinder(validation.DestinationValidator(rebootPendingEventType)),
+		rebootPendingFinderName, previousSessionSelectionReason, loggerIn.Logger)
+	rebootEventFinder = m.timeFinder(rebootEventFinder, rebootDurationParserName, rebootPendingFinderName, loggerIn.Logger)
+	cycleValidation := cycleValidation{
+		validator: validatorsIn.RebootCycleValidator,
+		parser:    history.RebootParser(nil),
+		callback: func(event interpreter.Event, valid bool, err error) {
+			if !valid {
+				logCycleErr(event, err, m.RebootCycleErrorTags, loggerIn.Logger)
+			}
+		},
+	}
+
+	return NewParserValidator(
+		cycleValidation,
+		eventValidation{},
+		func(events []interpreter.Event, currentEvent interpreter.Event) bool {
+			if _, err := rebootEventFinder.Find(events, currentEvent); err != nil {
+				return false
+			}
+			return true
 		},
 	)
 }
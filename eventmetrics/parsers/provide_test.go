@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/events"
 )
 
 func TestCheckTimeValidations(t *testing.T) {
@@ -70,3 +71,22 @@ func TestCheckTimeValidations(t *testing.T) {
 		})
 	}
 }
+
+type trimmingEventSource struct {
+	*mockEventClient
+	needs []events.TrimConfig
+}
+
+func (t *trimmingEventSource) Require(needs events.TrimConfig) {
+	t.needs = append(t.needs, needs)
+}
+
+func TestParserEventClient(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(parserEventClient(nil, "test", sessionHistory))
+
+	source := &trimmingEventSource{mockEventClient: new(mockEventClient)}
+	assert.Equal(source, parserEventClient(source, "test", sessionHistory))
+	assert.Equal(source, parserEventClient(source, "test", events.TrimConfig{MaxSessions: 5}))
+	assert.Equal([]events.TrimConfig{sessionHistory, {MaxSessions: 5}}, source.needs)
+}
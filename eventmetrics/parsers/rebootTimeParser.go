@@ -18,30 +18,34 @@
 package parsers
 
 import (
+	"context"
 	"errors"
 	"sort"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
 	"github.com/xmidt-org/interpreter"
 	"go.uber.org/zap"
 )
 
 const (
-	firmwareLabel        = "firmware"
-	hardwareLabel        = "hardware"
-	rebootReasonLabel    = "reboot_reason"
-	partnerIDLabel       = "partner_id"
-	validationErrReason  = "validation_error"
-	fatalErrReason       = "incoming_event_fatal_error"
-	calculationErrReason = "time_elapsed_calculation_error"
-	noHwFwReason         = "no_firmware_or_hardware_key"
+	firmwareLabel     = "firmware"
+	hardwareLabel     = "hardware"
+	rebootReasonLabel = "reboot_reason"
+	partnerIDLabel    = "partner_id"
 
 	hardwareMetadataKey     = "/hw-model"
 	firmwareMetadataKey     = "/fw-name"
 	rebootReasonMetadataKey = "/hw-last-reboot-reason"
 	invalidIncomingMsg      = "invalid incoming event"
+
+	fullyManageableEventType = "fully-manageable"
 )
 
+var errDeviceEventNotFound = errors.New("no fully-manageable event found for device")
+
 // DurationCalculator calculates the different durations in a boot cycle.
 type DurationCalculator interface {
 	Calculate([]interpreter.Event, interpreter.Event) error
@@ -55,7 +59,10 @@ type ParserValidator interface {
 
 // EventClient is an interface that provides a list of events related to a device.
 type EventClient interface {
-	GetEvents(deviceID string) []interpreter.Event
+	GetEvents(ctx context.Context, deviceID string) []interpreter.Event
+
+	// GetEventsBatch gets the lists of events related to each device, keyed by device id.
+	GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event
 }
 
 // Finder returns a specific event in a list of events.
@@ -67,9 +74,13 @@ type Finder interface {
 // and the last boot-cycle, calculating the boot and reboot durations.
 type RebootDurationParser struct {
 	name                 string
+	deviceIDsKey         string
+	maxDeviceIDs         int
 	relevantEventsParser EventsParser
 	parserValidators     []ParserValidator
 	calculators          []DurationCalculator
+	inferredCalculators  []DurationCalculator
+	derivedDurations     []derivedDuration
 	logger               *zap.Logger
 	client               EventClient
 	measures             Measures
@@ -80,6 +91,11 @@ func (p *RebootDurationParser) Name() string {
 	return p.name
 }
 
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p *RebootDurationParser) EventTypeRegexes() []string {
+	return []string{"^" + fullyManageableEventType + "$"}
+}
+
 // Parse takes an event, validates it, and calculates the time elapsed if everything is valid.
 /*
 	Steps:
@@ -90,35 +106,66 @@ func (p *RebootDurationParser) Name() string {
 	5. Parse and Validate: Go through parsers and parse and validate as needed.
 	6. Calculate time elapsed: Go through duration calculators to calculate durations and add to appropriate histograms.
 */
-func (p *RebootDurationParser) Parse(currentEvent interpreter.Event) {
+func (p *RebootDurationParser) Parse(ctx context.Context, currentEvent interpreter.Event) {
+	p.parse(ctx, currentEvent, p.client)
+}
+
+// ParseBatch implements the queue.BatchParser interface, parsing each event in the batch while only getting
+// the history of events from codex once for each device in the batch.
+func (p *RebootDurationParser) ParseBatch(ctx context.Context, events []interpreter.Event) {
+	client := newBatchEventClient(p.client)
+	client.GetEventsBatch(ctx, batchDeviceIDs(events, fullyManageableEventType))
+	for _, event := range events {
+		p.parse(ctx, event, client)
+	}
+}
+
+func (p *RebootDurationParser) parse(ctx context.Context, currentEvent interpreter.Event, client EventClient) {
 	// get hardware and firmware from metadata to use in metrics as labels
 	hardwareVal, firmwareVal, found := getHardwareFirmware(currentEvent)
 	if !found {
 		p.measures.RebootUnparsableCount.With(prometheus.Labels{hardwareLabel: hardwareVal,
-			firmwareLabel: firmwareVal, reasonLabel: noHwFwReason}).Add(1.0)
+			firmwareLabel: firmwareVal, reasonLabel: taxonomy.NoHardwareFirmware.Reason}).Add(1.0)
 	}
 
 	// Make sure event is actually a fully-manageable event. Make sure event follows event regex and is a fully-mangeable event.
 	eventType, err := currentEvent.EventType()
 	if err != nil {
-		p.addToUnparsableCounters(currentEvent, fatalErrReason)
+		p.addToUnparsableCounters(currentEvent, errFatal)
 		p.logger.Error(invalidIncomingMsg, zap.Error(err), zap.String("event destination", currentEvent.Destination))
 		return
-	} else if eventType != "fully-manageable" {
+	} else if eventType != fullyManageableEventType {
 		p.logger.Debug("wrong destination", zap.Error(err), zap.String("event destination", currentEvent.Destination))
 		return
 	}
 
+	// Without a boot-time, the durations can only be calculated in sessions inferred from birthdates.
+	if _, err := currentEvent.BootTime(); errors.Is(err, interpreter.ErrBootTimeNotFound) && len(p.inferredCalculators) > 0 {
+		p.parseInferredSessions(ctx, currentEvent, client)
+		return
+	}
+
 	// Check that event passes necessary checks. If it doesn't it is impossible to continue and we should exit.
 	if !p.basicChecks(currentEvent) {
-		p.addToUnparsableCounters(currentEvent, fatalErrReason)
+		p.addToUnparsableCounters(currentEvent, errFatal)
 		return
 	}
 
+	// Process the event for the device that sent it, and any additional devices it references.
+	for _, deviceID := range p.deviceIDs(currentEvent) {
+		p.parseDevice(ctx, deviceID, currentEvent, client)
+	}
+}
+
+// parseDevice gets the history of events for a device, validates it, and calculates the durations.
+func (p *RebootDurationParser) parseDevice(ctx context.Context, deviceID string, currentEvent interpreter.Event, client EventClient) {
 	// Get the history of events and parse events relevant to the latest boot-cycle, into a slice.
-	relevantEvents, err := p.getEvents(currentEvent)
-	if err != nil {
-		p.addToUnparsableCounters(currentEvent, fatalErrReason)
+	currentEvent, relevantEvents, err := p.getDeviceEvents(ctx, deviceID, currentEvent, client)
+	if errors.Is(err, errDeviceEventNotFound) {
+		p.logger.Debug("no fully-manageable event for referenced device", zap.String("device id", deviceID))
+		return
+	} else if err != nil {
+		p.addToUnparsableCounters(currentEvent, errFatal)
 		return
 	}
 
@@ -130,23 +177,75 @@ func (p *RebootDurationParser) Parse(currentEvent interpreter.Event) {
 	}
 
 	if !allValid {
-		p.addToUnparsableCounters(currentEvent, validationErrReason)
+		// a brand-new device has no previous boot-cycle to validate, which is expected.
+		if p.measures.NewDeviceCount != nil && isNewDevice(relevantEvents, currentEvent) {
+			p.logger.Debug("new device", zap.String("device id", deviceID))
+			p.measures.AddNewDevice(p.name)
+			return
+		}
+
+		p.addToUnparsableCounters(currentEvent, errValidation, relevantEvents...)
 		return
 	}
 
-	calculationValid := true
+	var calculationErr error
 	for _, calculator := range p.calculators {
-		if err := calculator.Calculate(relevantEvents, currentEvent); err != nil && !errors.Is(err, errEventNotFound) {
-			// no need to log in metrics if event doesn't exist
-			calculationValid = false
+		// no need to log in metrics if event doesn't exist
+		if err := calculator.Calculate(relevantEvents, currentEvent); err != nil && !errors.Is(err, errEventNotFound) && calculationErr == nil {
+			calculationErr = calculationError(err)
 		}
 	}
 
-	if !calculationValid {
-		p.addToUnparsableCounters(currentEvent, calculationErrReason)
+	if calculationErr != nil {
+		p.addToUnparsableCounters(currentEvent, calculationErr, relevantEvents...)
+		return
+	}
+
+	for _, derived := range p.derivedDurations {
+		if !derived.observe(p.measures, relevantEvents, currentEvent) {
+			p.logger.Debug("derived duration not recorded", zap.String("name", derived.name), zap.String("event id", currentEvent.TransactionUUID))
+		}
 	}
 }
 
+// deviceIDs returns the device id from the event's destination, followed by any additional device ids
+// listed in the configured metadata key, up to the configured maximum.
+func (p *RebootDurationParser) deviceIDs(event interpreter.Event) []string {
+	deviceID, _ := deviceid.FromEvent(event)
+	deviceIDs := []string{deviceID}
+	if len(p.deviceIDsKey) == 0 {
+		return deviceIDs
+	}
+
+	value, found := event.GetMetadataValue(p.deviceIDsKey)
+	if !found {
+		return deviceIDs
+	}
+
+	maxDeviceIDs := p.maxDeviceIDs
+	if maxDeviceIDs <= 0 {
+		maxDeviceIDs = defaultMaxDeviceIDs
+	}
+
+	seen := map[string]bool{deviceID: true}
+	for _, id := range strings.Split(value, ",") {
+		id = deviceid.Normalize(id)
+		if len(id) == 0 || seen[id] {
+			continue
+		}
+
+		if len(deviceIDs) >= maxDeviceIDs {
+			p.logger.Warn("too many device ids in event, ignoring the rest", zap.String("event id", event.TransactionUUID), zap.Int("max device ids", maxDeviceIDs))
+			break
+		}
+
+		seen[id] = true
+		deviceIDs = append(deviceIDs, id)
+	}
+
+	return deviceIDs
+}
+
 // check that event has a boot-time and device id
 func (p *RebootDurationParser) basicChecks(event interpreter.Event) bool {
 	if bootTime, err := event.BootTime(); err != nil || bootTime <= 0 {
@@ -154,7 +253,7 @@ func (p *RebootDurationParser) basicChecks(event interpreter.Event) bool {
 		return false
 	}
 
-	_, err := event.DeviceID()
+	_, err := deviceid.FromEvent(event)
 	if err != nil {
 		p.logger.Error(invalidIncomingMsg, zap.Error(err))
 		return false
@@ -163,18 +262,47 @@ func (p *RebootDurationParser) basicChecks(event interpreter.Event) bool {
 	return true
 }
 
-// get history of events and return relevant events
-func (p *RebootDurationParser) getEvents(currentEvent interpreter.Event) ([]interpreter.Event, error) {
-	deviceID, err := currentEvent.DeviceID()
+// getDeviceEvents gets the history of events for a specific device and returns the device's own fully-manageable
+// event along with the relevant events. The event for the device that sent currentEvent is currentEvent itself,
+// while a referenced device uses its latest fully-manageable event that isn't newer than currentEvent.
+func (p *RebootDurationParser) getDeviceEvents(ctx context.Context, deviceID string, currentEvent interpreter.Event, client EventClient) (interpreter.Event, []interpreter.Event, error) {
+	history := client.GetEvents(ctx, deviceID)
+	deviceEvent := currentEvent
+	if id, _ := deviceid.FromEvent(currentEvent); id != deviceID {
+		var found bool
+		if deviceEvent, found = referencedDeviceEvent(history, currentEvent); !found {
+			return currentEvent, []interpreter.Event{}, errDeviceEventNotFound
+		}
+	}
+
+	bootCycle, err := p.relevantEvents(history, deviceEvent)
 	if err != nil {
-		p.logger.Error("error getting device id", zap.Error(err))
-		return []interpreter.Event{}, err
+		p.logger.Info("parsing error", zap.Error(err), zap.String("event id", deviceEvent.TransactionUUID), zap.String("device id", deviceID))
+		return deviceEvent, []interpreter.Event{}, err
+	}
+
+	return deviceEvent, bootCycle, nil
+}
+
+// referencedDeviceEvent returns the latest fully-manageable event with a boot-time in a referenced device's
+// history, ignoring events born after the event that referenced the device.
+func referencedDeviceEvent(history []interpreter.Event, currentEvent interpreter.Event) (interpreter.Event, bool) {
+	candidates := make([]interpreter.Event, 0, len(history))
+	for _, event := range history {
+		if bootTime, err := event.BootTime(); err != nil || bootTime <= 0 || event.Birthdate > currentEvent.Birthdate {
+			continue
+		}
+
+		candidates = append(candidates, event)
 	}
 
-	events := p.client.GetEvents(deviceID)
-	bootCycle, err := p.relevantEventsParser.Parse(events, currentEvent)
+	return latestFullyManageableEvent(candidates)
+}
+
+// relevantEvents parses the events in the history relevant to the latest boot-cycle, sorted newest to oldest.
+func (p *RebootDurationParser) relevantEvents(history []interpreter.Event, currentEvent interpreter.Event) ([]interpreter.Event, error) {
+	bootCycle, err := p.relevantEventsParser.Parse(history, currentEvent)
 	if err != nil {
-		p.logger.Info("parsing error", zap.Error(err), zap.String("event id", currentEvent.TransactionUUID), zap.String("device id", deviceID))
 		return []interpreter.Event{}, err
 	}
 
@@ -192,7 +320,20 @@ func (p *RebootDurationParser) getEvents(currentEvent interpreter.Event) ([]inte
 
 }
 
-func (p *RebootDurationParser) addToUnparsableCounters(event interpreter.Event, reason string) {
+// calculationError classifies the error of a duration calculation, treating errors that aren't classified, such
+// as a missing finder, as calculation errors.
+func calculationError(err error) error {
+	if taxonomy.KindOf(err) == taxonomy.Unknown {
+		return taxonomy.Wrap(taxonomy.CalculationError, err)
+	}
+
+	return err
+}
+
+func (p *RebootDurationParser) addToUnparsableCounters(event interpreter.Event, err error, compared ...interpreter.Event) {
 	p.measures.AddTotalUnparsable(p.name)
-	p.measures.AddRebootUnparsable(reason, event)
+	p.measures.AddRebootUnparsable(err, event)
+	p.measures.AddUnparsableEventType(p.name, err, event)
+	p.measures.AddUnparsableRatio(event)
+	p.measures.LogUnparsable(p.name, err, event, compared)
 }
@@ -1,6 +1,7 @@
 package parsers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -11,6 +12,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/touchstone/touchtest"
 	"go.uber.org/zap"
@@ -97,7 +99,7 @@ func TestParseCalculationErr(t *testing.T) {
 				calculators:          []DurationCalculator{validDurationCalculator, invalidDurationCalculator},
 			}
 
-			parser.Parse(event)
+			parser.Parse(context.Background(), event)
 		})
 	}
 
@@ -133,6 +135,14 @@ func TestParseValidationErr(t *testing.T) {
 				},
 				[]string{parserLabel},
 			),
+			UnparsableEventTypeCount: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "unparsableEventTypes",
+					Help: "unparsableEventTypes",
+				},
+				[]string{parserLabel, reasonLabel, eventTypeLabel},
+			),
+			UnparsableEventTypes: knownEventTypes(UnparsableEventTypesConfig{}),
 		}
 
 		client                 = new(mockEventClient)
@@ -155,7 +165,8 @@ func TestParseValidationErr(t *testing.T) {
 		logger:               zap.NewNop(),
 	}
 
-	rebootParser.Parse(event)
+	rebootParser.Parse(context.Background(), event)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.UnparsableEventTypeCount.WithLabelValues("test_reboot_parser", taxonomy.ValidationError.Reason, fullyManageableEventType)))
 }
 
 func TestParseNotFullyManageable(t *testing.T) {
@@ -217,7 +228,7 @@ func TestParseNotFullyManageable(t *testing.T) {
 	actualRegistry.Register(m.TotalUnparsableCount)
 	actualRegistry.Register(m.RebootUnparsableCount)
 
-	parser.Parse(event)
+	parser.Parse(context.Background(), event)
 	testAssert := touchtest.New(t)
 	testAssert.Expect(expectedRegistry)
 	assert.True(testAssert.GatherAndCompare(actualRegistry))
@@ -319,7 +330,7 @@ func TestParseFatalErr(t *testing.T) {
 				client:               client,
 			}
 
-			parser.Parse(tc.event)
+			parser.Parse(context.Background(), tc.event)
 		})
 	}
 }
@@ -392,7 +403,7 @@ func TestParseNoFWHWErr(t *testing.T) {
 				logger:   zap.NewNop(),
 			}
 
-			parser.Parse(tc.event)
+			parser.Parse(context.Background(), tc.event)
 			if tc.expectErr {
 				assert.Equal(1.0, testutil.ToFloat64(m.RebootUnparsableCount))
 			}
@@ -406,6 +417,7 @@ func TestRebootDurationParserName(t *testing.T) {
 		name: name,
 	}
 	assert.Equal(t, name, parser.Name())
+	assert.Equal(t, []string{"^fully-manageable$"}, parser.EventTypeRegexes())
 }
 
 func TestBasicChecks(t *testing.T) {
@@ -463,8 +475,43 @@ func TestGetEvents(t *testing.T) {
 
 	logger := zap.NewNop()
 	testErr := errors.New("test")
-	client := new(mockEventClient)
-	client.On("GetEvents", mock.Anything).Return([]interpreter.Event{})
+	currentEvent := interpreter.Event{
+		Destination: "event:device-status/mac:112233445566/fully-manageable",
+		Birthdate:   now.UnixNano(),
+	}
+
+	childEvent := interpreter.Event{
+		Destination: "event:device-status/mac:aabbccddeeff/fully-manageable",
+		Metadata: map[string]string{
+			interpreter.BootTimeKey: fmt.Sprint(now.Add(-10 * time.Minute).Unix()),
+		},
+		Birthdate: now.Add(-time.Minute).UnixNano(),
+	}
+
+	childHistory := []interpreter.Event{
+		childEvent,
+		interpreter.Event{
+			Destination: "event:device-status/mac:aabbccddeeff/fully-manageable",
+			Metadata: map[string]string{
+				interpreter.BootTimeKey: fmt.Sprint(now.Add(-30 * time.Minute).Unix()),
+			},
+			Birthdate: now.Add(-20 * time.Minute).UnixNano(),
+		},
+		interpreter.Event{
+			Destination: "event:device-status/mac:aabbccddeeff/fully-manageable",
+			Metadata: map[string]string{
+				interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+			},
+			Birthdate: now.Add(time.Minute).UnixNano(),
+		},
+		interpreter.Event{
+			Destination: "event:device-status/mac:aabbccddeeff/operational",
+			Metadata: map[string]string{
+				interpreter.BootTimeKey: fmt.Sprint(now.Add(-5 * time.Minute).Unix()),
+			},
+			Birthdate: now.Add(-2 * time.Minute).UnixNano(),
+		},
+	}
 
 	events := []interpreter.Event{
 		interpreter.Event{
@@ -508,68 +555,254 @@ func TestGetEvents(t *testing.T) {
 	})
 
 	tests := []struct {
-		description    string
-		currentEvent   interpreter.Event
-		parsedEvents   []interpreter.Event
-		parseErr       error
-		expectedEvents []interpreter.Event
-		expectedErr    bool
+		description         string
+		deviceID            string
+		history             []interpreter.Event
+		parsedEvents        []interpreter.Event
+		parseErr            error
+		expectedDeviceEvent interpreter.Event
+		expectedEvents      []interpreter.Event
+		expectedErr         error
 	}{
 		{
-			description: "valid",
-			currentEvent: interpreter.Event{
-				Destination: "event:device-status/mac:112233445566/someEvent",
-			},
-			parsedEvents:   events,
-			expectedEvents: sortedEvents,
+			description:         "valid",
+			deviceID:            "mac:112233445566",
+			parsedEvents:        events,
+			expectedDeviceEvent: currentEvent,
+			expectedEvents:      sortedEvents,
 		},
 		{
-			description: "valid empty",
-			currentEvent: interpreter.Event{
-				Destination: "event:device-status/mac:112233445566/someEvent",
-			},
-			parsedEvents:   []interpreter.Event{},
-			expectedEvents: []interpreter.Event{},
+			description:         "valid empty",
+			deviceID:            "mac:112233445566",
+			parsedEvents:        []interpreter.Event{},
+			expectedDeviceEvent: currentEvent,
+			expectedEvents:      []interpreter.Event{},
 		},
 		{
-			description: "device id err",
-			currentEvent: interpreter.Event{
-				Destination: "event:device-status/someEvent",
-			},
-			expectedEvents: []interpreter.Event{},
-			expectedErr:    true,
+			description:         "referenced device",
+			deviceID:            "mac:aabbccddeeff",
+			history:             childHistory,
+			parsedEvents:        events,
+			expectedDeviceEvent: childEvent,
+			expectedEvents:      sortedEvents,
 		},
 		{
-			description: "err parsing",
-			currentEvent: interpreter.Event{
-				Destination: "event:device-status/someEvent",
-			},
-			parsedEvents:   []interpreter.Event{},
-			parseErr:       testErr,
-			expectedEvents: []interpreter.Event{},
-			expectedErr:    true,
+			description:         "referenced device without fully-manageable event",
+			deviceID:            "mac:aabbccddeeff",
+			history:             childHistory[3:],
+			expectedDeviceEvent: currentEvent,
+			expectedEvents:      []interpreter.Event{},
+			expectedErr:         errDeviceEventNotFound,
+		},
+		{
+			description:         "err parsing",
+			deviceID:            "mac:112233445566",
+			parsedEvents:        []interpreter.Event{},
+			parseErr:            testErr,
+			expectedDeviceEvent: currentEvent,
+			expectedEvents:      []interpreter.Event{},
+			expectedErr:         testErr,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.description, func(t *testing.T) {
 			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", mock.Anything).Return(tc.history)
 			eventsParser := new(mockEventsParser)
 			eventsParser.On("Parse", mock.Anything, mock.Anything).Return(tc.parsedEvents, tc.parseErr)
 			rebootParser := RebootDurationParser{
-				client:               client,
 				relevantEventsParser: eventsParser,
 				logger:               logger,
 			}
 
-			returnedEvents, err := rebootParser.getEvents(tc.currentEvent)
+			deviceEvent, returnedEvents, err := rebootParser.getDeviceEvents(context.Background(), tc.deviceID, currentEvent, client)
+			assert.Equal(tc.expectedDeviceEvent, deviceEvent)
 			assert.Equal(tc.expectedEvents, returnedEvents)
-			if tc.expectedErr {
-				assert.NotNil(err)
-			} else {
-				assert.Nil(err)
+			assert.ErrorIs(err, tc.expectedErr)
+			client.AssertCalled(t, "GetEvents", tc.deviceID)
+
+		})
+	}
+}
+
+func TestDeviceIDs(t *testing.T) {
+	const deviceIDsKey = "/downstream-device-ids"
+	destination := "event:device-status/mac:112233445566/fully-manageable"
+	tests := []struct {
+		description  string
+		deviceIDsKey string
+		maxDeviceIDs int
+		metadata     map[string]string
+		expectedIDs  []string
+	}{
+		{
+			description: "Not configured",
+			metadata:    map[string]string{deviceIDsKey: "mac:aabbccddeeff"},
+			expectedIDs: []string{"mac:112233445566"},
+		},
+		{
+			description:  "Key missing",
+			deviceIDsKey: deviceIDsKey,
+			metadata:     map[string]string{},
+			expectedIDs:  []string{"mac:112233445566"},
+		},
+		{
+			description:  "Multiple devices",
+			deviceIDsKey: deviceIDsKey,
+			metadata:     map[string]string{deviceIDsKey: "mac:aabbccddeeff, mac:112233445566,,mac:ffeeddccbbaa"},
+			expectedIDs:  []string{"mac:112233445566", "mac:aabbccddeeff", "mac:ffeeddccbbaa"},
+		},
+		{
+			description:  "Bounded",
+			deviceIDsKey: deviceIDsKey,
+			maxDeviceIDs: 2,
+			metadata:     map[string]string{deviceIDsKey: "mac:aabbccddeeff,mac:ffeeddccbbaa"},
+			expectedIDs:  []string{"mac:112233445566", "mac:aabbccddeeff"},
+		},
+		{
+			description:  "Default bound",
+			deviceIDsKey: deviceIDsKey,
+			metadata:     map[string]string{deviceIDsKey: "mac:1,mac:2,mac:3,mac:4,mac:5,mac:6"},
+			expectedIDs:  []string{"mac:112233445566", "mac:1", "mac:2", "mac:3", "mac:4"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			parser := RebootDurationParser{
+				deviceIDsKey: tc.deviceIDsKey,
+				maxDeviceIDs: tc.maxDeviceIDs,
+				logger:       zap.NewNop(),
+			}
+			event := interpreter.Event{Destination: destination, Metadata: tc.metadata}
+			assert.Equal(t, tc.expectedIDs, parser.deviceIDs(event))
+		})
+	}
+}
+
+func TestParseMultipleDevices(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+	const deviceIDsKey = "/downstream-device-ids"
+	childEvent := func(deviceID string) interpreter.Event {
+		return interpreter.Event{
+			Destination: fmt.Sprintf("event:device-status/%s/fully-manageable", deviceID),
+			Metadata: map[string]string{
+				hardwareMetadataKey:     "child-hw",
+				firmwareMetadataKey:     "child-fw",
+				interpreter.BootTimeKey: fmt.Sprint(now.Add(-time.Hour).Unix()),
+			},
+			Birthdate: now.Add(-time.Minute).UnixNano(),
+		}
+	}
+
+	tests := []struct {
+		description        string
+		metadataValue      string
+		childrenWithEvents []string
+		expectedDevices    []string
+	}{
+		{
+			description:     "Single device",
+			expectedDevices: []string{"mac:112233445566"},
+		},
+		{
+			description:        "Multiple devices",
+			metadataValue:      "mac:aabbccddeeff,mac:ffeeddccbbaa",
+			childrenWithEvents: []string{"mac:aabbccddeeff", "mac:ffeeddccbbaa"},
+			expectedDevices:    []string{"mac:112233445566", "mac:aabbccddeeff", "mac:ffeeddccbbaa"},
+		},
+		{
+			description:        "Referenced device without fully-manageable event",
+			metadataValue:      "mac:aabbccddeeff,mac:ffeeddccbbaa",
+			childrenWithEvents: []string{"mac:aabbccddeeff"},
+			expectedDevices:    []string{"mac:112233445566", "mac:aabbccddeeff", "mac:ffeeddccbbaa"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			event := interpreter.Event{
+				Destination: "event:device-status/mac:112233445566/fully-manageable",
+				Metadata: map[string]string{
+					hardwareMetadataKey:     "hw",
+					firmwareMetadataKey:     "fw",
+					interpreter.BootTimeKey: fmt.Sprint(now.Unix()),
+				},
+				Birthdate: now.UnixNano(),
+			}
+			if len(tc.metadataValue) > 0 {
+				event.Metadata[deviceIDsKey] = tc.metadataValue
+			}
+
+			client := new(mockEventClient)
+			for _, deviceID := range tc.childrenWithEvents {
+				client.On("GetEvents", deviceID).Return([]interpreter.Event{childEvent(deviceID)})
+			}
+			client.On("GetEvents", mock.Anything).Return([]interpreter.Event{})
+			eventsParser := new(mockEventsParser)
+			eventsParser.On("Parse", mock.Anything, mock.Anything).Return([]interpreter.Event{}, nil)
+			calculator := new(mockCalculator)
+			calculator.On("Calculate", mock.Anything, mock.Anything).Return(nil)
+
+			parser := RebootDurationParser{
+				name:                 "test_reboot_parser",
+				deviceIDsKey:         deviceIDsKey,
+				client:               client,
+				relevantEventsParser: eventsParser,
+				calculators:          []DurationCalculator{calculator},
+				logger:               zap.NewNop(),
 			}
 
+			parser.Parse(context.Background(), event)
+			for _, deviceID := range tc.expectedDevices {
+				client.AssertCalled(t, "GetEvents", deviceID)
+			}
+			client.AssertNumberOfCalls(t, "GetEvents", len(tc.expectedDevices))
+			calculator.AssertCalled(t, "Calculate", mock.Anything, event)
+			for _, deviceID := range tc.childrenWithEvents {
+				calculator.AssertCalled(t, "Calculate", mock.Anything, childEvent(deviceID))
+			}
+			calculator.AssertNumberOfCalls(t, "Calculate", len(tc.childrenWithEvents)+1)
+		})
+	}
+}
+
+func TestCalculationError(t *testing.T) {
+	tests := []struct {
+		description    string
+		err            error
+		expectedReason string
+	}{
+		{
+			description:    "negative duration",
+			err:            errNegativeDuration,
+			expectedReason: taxonomy.NegativeDuration.Reason,
+		},
+		{
+			description:    "out of range",
+			err:            errDurationOutOfRange,
+			expectedReason: taxonomy.DurationOutOfRange.Reason,
+		},
+		{
+			description:    "other calculation error",
+			err:            errCalculation,
+			expectedReason: taxonomy.CalculationError.Reason,
+		},
+		{
+			description:    "unclassified error",
+			err:            errMissingFinder,
+			expectedReason: taxonomy.CalculationError.Reason,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			err := calculationError(tc.err)
+			assert.Equal(t, tc.expectedReason, taxonomy.Reason(err))
+			assert.ErrorIs(t, err, tc.err)
 		})
 	}
 }
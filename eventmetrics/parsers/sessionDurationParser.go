@@ -0,0 +1,243 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+const (
+	sessionDurationParserName = "session_duration"
+	offlineFinderName         = "offline"
+)
+
+// SessionDurationConfig configures the session duration parser.
+type SessionDurationConfig struct {
+	// Enabled turns on the session duration parser.
+	Enabled bool
+
+	// MetricPrefix is prepended, followed by an underscore, to the name of the session duration histogram.
+	MetricPrefix string
+
+	// NativeHistograms configures exposing the session duration histogram as a native histogram.
+	NativeHistograms NativeHistogramConfig
+
+	// FinderDiagnostics configures logging why the parser's finder selected the previous session's event.
+	// The finder is named session_duration.
+	FinderDiagnostics FinderDiagnosticsConfig
+}
+
+// SessionDurationParser calculates the length of a session, which is the time between the session's boot-time and
+// the birthdate of the last event of the session.  The session is recorded when its first offline event is parsed,
+// or, for sessions that ended without an offline event, when the first online event of the following session is
+// parsed.  Unlike the session uptime, the session duration doesn't include the time a device was down between sessions.
+type SessionDurationParser struct {
+	name          string
+	sessionFinder Finder
+	onlineFinder  Finder
+	offlineFinder Finder
+	client        EventClient
+	histogram     prometheus.ObserverVec
+	measures      Measures
+	logger        *zap.Logger
+}
+
+// NewSessionDurationParser creates a new SessionDurationParser.
+func NewSessionDurationParser(config SessionDurationConfig, client EventClient, histogram prometheus.ObserverVec, measures Measures, logger *zap.Logger) (*SessionDurationParser, error) {
+	if client == nil {
+		return nil, errNilEventClient
+	}
+
+	if histogram == nil {
+		return nil, errNilHistogram
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	sessionFinder := explainFinder(config.FinderDiagnostics, history.LastSessionFinder(validation.DefaultValidator()), sessionDurationParserName, bootTimeSelectionReason, logger)
+	return &SessionDurationParser{
+		name:          sessionDurationParserName,
+		sessionFinder: measures.timeFinder(sessionFinder, sessionDurationParserName, sessionDurationParserName, logger),
+		onlineFinder:  measures.timeFinder(history.CurrentSessionFinder(validation.DestinationValidator(onlineEventType)), sessionDurationParserName, onlineFinderName, logger),
+		offlineFinder: measures.timeFinder(history.CurrentSessionFinder(validation.DestinationValidator(offlineEventType)), sessionDurationParserName, offlineFinderName, logger),
+		client:        client,
+		histogram:     histogram,
+		measures:      measures,
+		logger:        logger,
+	}, nil
+}
+
+// Name implements the Parser interface.
+func (p *SessionDurationParser) Name() string {
+	return p.name
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p *SessionDurationParser) EventTypeRegexes() []string {
+	return []string{"^" + onlineEventType + "$", "^" + offlineEventType + "$"}
+}
+
+// Parse records the duration of the session ended by an offline event, or of the previous session if the event
+// is the first online event of a new session.
+func (p *SessionDurationParser) Parse(ctx context.Context, currentEvent interpreter.Event) {
+	p.parse(ctx, currentEvent, p.client)
+}
+
+// ParseBatch implements the queue.BatchParser interface, parsing each event in the batch while only getting
+// the history of events from codex once for each device in the batch.
+func (p *SessionDurationParser) ParseBatch(ctx context.Context, events []interpreter.Event) {
+	client := newBatchEventClient(p.client)
+	client.GetEventsBatch(ctx, batchDeviceIDs(events, onlineEventType, offlineEventType))
+	for _, event := range events {
+		p.parse(ctx, event, client)
+	}
+}
+
+func (p *SessionDurationParser) parse(ctx context.Context, currentEvent interpreter.Event, client EventClient) {
+	eventType, err := currentEvent.EventType()
+	if err != nil || (eventType != onlineEventType && eventType != offlineEventType) {
+		return
+	}
+
+	deviceID, err := deviceid.FromEvent(currentEvent)
+	if err != nil {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	currentBootTime, err := currentEvent.BootTime()
+	if err != nil || currentBootTime <= 0 {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	events := client.GetEvents(ctx, deviceID)
+	var bootTime int64
+	var lastEvent interpreter.Event
+	if eventType == offlineEventType {
+		// only the first offline event ends the session.
+		if previous, err := p.offlineFinder.Find(events, currentEvent); err == nil && previous.Birthdate < currentEvent.Birthdate {
+			return
+		}
+		bootTime, lastEvent = currentBootTime, currentEvent
+	} else {
+		// only the first online event of a session records the previous session.
+		if previous, err := p.onlineFinder.Find(events, currentEvent); err == nil && previous.Birthdate < currentEvent.Birthdate {
+			return
+		}
+
+		previousEvent, err := p.sessionFinder.Find(events, currentEvent)
+		if err != nil {
+			if p.measures.NewDeviceCount != nil && isNewDevice(events, currentEvent) {
+				p.logger.Debug("new device", zap.String("device id", deviceID))
+				p.measures.AddNewDevice(p.name)
+				return
+			}
+
+			p.logger.Debug("previous session not found", zap.Error(err), zap.String("device id", deviceID))
+			p.addToUnparsableCounters(currentEvent, errNoPreviousSession, events...)
+			return
+		}
+
+		bootTime, _ = previousEvent.BootTime()
+		lastEvent = lastSessionEvent(events, bootTime)
+
+		// sessions ended by an offline event were already recorded when the offline event was parsed.
+		if lastEventType, err := lastEvent.EventType(); err == nil && lastEventType == offlineEventType {
+			return
+		}
+	}
+
+	duration := time.Unix(0, lastEvent.Birthdate).Sub(time.Unix(bootTime, 0)).Seconds()
+	if bootTime <= 0 || lastEvent.Birthdate <= 0 || duration <= 0 {
+		p.logger.Error("invalid session duration calculated", zap.String("device id", deviceID), zap.Float64("duration", duration),
+			zap.String("last event", lastEvent.TransactionUUID))
+		p.addToUnparsableCounters(currentEvent, errCalculation, lastEvent)
+		return
+	}
+
+	p.measures.addDuration(p.histogram, duration, lastEvent)
+}
+
+// lastSessionEvent returns the event with the latest birthdate of the session with the boot-time given.
+func lastSessionEvent(events []interpreter.Event, bootTime int64) interpreter.Event {
+	var last interpreter.Event
+	for _, event := range events {
+		if b, err := event.BootTime(); err != nil || b != bootTime {
+			continue
+		}
+
+		if event.Birthdate > last.Birthdate {
+			last = event
+		}
+	}
+
+	return last
+}
+
+func (p *SessionDurationParser) addToUnparsableCounters(event interpreter.Event, err error, compared ...interpreter.Event) {
+	p.measures.AddTotalUnparsable(p.name)
+	p.measures.AddUnparsableEventType(p.name, err, event)
+	p.measures.AddUnparsableRatio(event)
+	p.measures.LogUnparsable(p.name, err, event, compared)
+}
+
+// createSessionDurationParsers creates the session duration parser if it is enabled.
+func createSessionDurationParsers(f *touchstone.Factory, config SessionDurationConfig, client events.EventSource, measures Measures, logger *zap.Logger) ([]queue.Parser, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if f == nil {
+		return nil, errNilFactory
+	}
+
+	opts := config.NativeHistograms.apply(prometheus.HistogramOpts{
+		Name:        metricName(config.MetricPrefix, "session_duration"),
+		Help:        "time elapsed between the boot-time of a session and the last event of the session in s",
+		Buckets:     []float64{60, 300, 900, 1800, 3600, 7200, 14400, 21600, 43200, 86400, 172800, 345600, 604800, 1209600, 2592000},
+		ConstLabels: measures.ConfigVariantLabels,
+	})
+	histogram, err := f.NewHistogramVec(opts, firmwareLabel, hardwareLabel, rebootReasonLabel)
+	if err != nil {
+		return nil, err
+	}
+	measures.recordBuckets(opts)
+
+	parser, err := NewSessionDurationParser(config, parserEventClient(client, sessionDurationParserName, sessionHistory), histogram, measures, logger.With(zap.String("parser", sessionDurationParserName)))
+	if err != nil {
+		return nil, err
+	}
+
+	return []queue.Parser{parser}, nil
+}
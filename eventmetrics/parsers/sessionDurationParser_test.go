@@ -0,0 +1,176 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSessionDurationParse(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	previousBoot := now.Add(-48 * time.Hour)
+	currentBoot := now.Add(-1 * time.Minute)
+	previousLast := sessionEvent(fullyManageableEventType, previousBoot, now.Add(-2*time.Hour), "last")
+	previousSession := []interpreter.Event{
+		sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+		previousLast,
+	}
+	currentOnline := sessionEvent(onlineEventType, currentBoot, now, "current")
+	currentOffline := sessionEvent(offlineEventType, currentBoot, now, "current")
+
+	tests := []struct {
+		description        string
+		event              interpreter.Event
+		history            []interpreter.Event
+		expectedDuration   float64
+		expectedCount      uint64
+		expectedUnparsable float64
+	}{
+		{
+			description:      "Offline event",
+			event:            currentOffline,
+			history:          append(previousSession, currentOffline),
+			expectedDuration: now.Sub(currentBoot).Seconds(),
+			expectedCount:    1,
+		},
+		{
+			description: "Later offline event",
+			event:       currentOffline,
+			history: append(previousSession,
+				sessionEvent(offlineEventType, currentBoot, currentBoot.Add(time.Second), "first"),
+				currentOffline,
+			),
+		},
+		{
+			description:      "Online event",
+			event:            currentOnline,
+			history:          append(previousSession, currentOnline),
+			expectedDuration: time.Unix(0, previousLast.Birthdate).Sub(previousBoot).Seconds(),
+			expectedCount:    1,
+		},
+		{
+			description: "Previous session ended with offline",
+			event:       currentOnline,
+			history: append(previousSession,
+				sessionEvent(offlineEventType, previousBoot, now.Add(-1*time.Hour), "offline"),
+				currentOnline,
+			),
+		},
+		{
+			description: "Later online event",
+			event:       currentOnline,
+			history: append(previousSession,
+				sessionEvent(onlineEventType, currentBoot, currentBoot.Add(time.Second), "first"),
+				currentOnline,
+			),
+		},
+		{
+			description:        "No previous session",
+			event:              currentOnline,
+			history:            []interpreter.Event{currentOnline},
+			expectedUnparsable: 1.0,
+		},
+		{
+			description:        "Birthdate before boot-time",
+			event:              sessionEvent(offlineEventType, currentBoot, currentBoot.Add(-1*time.Minute), "current"),
+			expectedUnparsable: 1.0,
+		},
+		{
+			description:        "Missing boot-time",
+			event:              interpreter.Event{Destination: fmt.Sprintf("event:device-status/%s/offline", testUptimeDeviceID)},
+			expectedUnparsable: 1.0,
+		},
+		{
+			description: "Wrong event type",
+			event:       sessionEvent(fullyManageableEventType, currentBoot, now, "current"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", testUptimeDeviceID).Return(tc.history)
+			histogram := newTestUptimeHistogram()
+			unparsable := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testUnparsable",
+				Help: "testUnparsable",
+			}, []string{parserLabel})
+
+			parser, err := NewSessionDurationParser(SessionDurationConfig{}, client, histogram, Measures{TotalUnparsableCount: unparsable}, zap.NewNop())
+			assert.Nil(err)
+			parser.Parse(context.Background(), tc.event)
+
+			assert.Equal(tc.expectedUnparsable, testutil.ToFloat64(unparsable.WithLabelValues(sessionDurationParserName)))
+			metric := &dto.Metric{}
+			observer := histogram.With(prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason"})
+			assert.Nil(observer.(prometheus.Metric).Write(metric))
+			assert.Equal(tc.expectedCount, metric.GetHistogram().GetSampleCount())
+			assert.Equal(tc.expectedDuration, metric.GetHistogram().GetSampleSum())
+		})
+	}
+}
+
+func TestLastSessionEvent(t *testing.T) {
+	assert := assert.New(t)
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(err)
+	bootTime := now.Add(-1 * time.Hour)
+	events := []interpreter.Event{
+		sessionEvent(onlineEventType, bootTime, now.Add(-50*time.Minute), "1"),
+		sessionEvent(offlineEventType, bootTime, now.Add(-10*time.Minute), "2"),
+		sessionEvent(fullyManageableEventType, bootTime, now.Add(-40*time.Minute), "3"),
+		sessionEvent(onlineEventType, now, now, "4"),
+	}
+
+	assert.Equal("2", lastSessionEvent(events, bootTime.Unix()).TransactionUUID)
+	assert.Empty(lastSessionEvent(events, now.Add(-2*time.Hour).Unix()).TransactionUUID)
+}
+
+func TestNewSessionDurationParser(t *testing.T) {
+	assert := assert.New(t)
+	histogram := newTestUptimeHistogram()
+
+	parser, err := NewSessionDurationParser(SessionDurationConfig{}, nil, histogram, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilEventClient, err)
+
+	parser, err = NewSessionDurationParser(SessionDurationConfig{}, new(mockEventClient), nil, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilHistogram, err)
+
+	parser, err = NewSessionDurationParser(SessionDurationConfig{}, new(mockEventClient), histogram, Measures{}, nil)
+	assert.Nil(err)
+	assert.Equal(sessionDurationParserName, parser.Name())
+	assert.Equal([]string{"^online$", "^offline$"}, parser.EventTypeRegexes())
+}
+
+func TestCreateSessionDurationParsers(t *testing.T) {
+	assert := assert.New(t)
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+
+	parsers, err := createSessionDurationParsers(testFactory, SessionDurationConfig{}, nil, Measures{}, zap.NewNop())
+	assert.Nil(err)
+	assert.Empty(parsers)
+
+	parsers, err = createSessionDurationParsers(nil, SessionDurationConfig{Enabled: true}, nil, Measures{}, zap.NewNop())
+	assert.Equal(errNilFactory, err)
+	assert.Empty(parsers)
+
+	buckets := make(map[string]string)
+	parsers, err = createSessionDurationParsers(testFactory, SessionDurationConfig{Enabled: true, MetricPrefix: "test"}, nil, Measures{HistogramBuckets: buckets}, zap.NewNop())
+	assert.Equal(errNilEventClient, err)
+	assert.Empty(parsers)
+	assert.Contains(buckets, "test_session_duration")
+}
@@ -0,0 +1,213 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+)
+
+const (
+	sessionUptimeParserName = "session_uptime"
+)
+
+var (
+	errNilEventClient    = errors.New("event client cannot be nil")
+	errNoPreviousSession = taxonomy.ErrNoPreviousSession
+)
+
+// SessionUptimeConfig configures the session uptime parser.
+type SessionUptimeConfig struct {
+	// Enabled turns on the session uptime parser.
+	Enabled bool
+
+	// EventType is the event type that triggers the parser.  Defaults to online.
+	EventType string
+
+	// MetricPrefix is prepended, followed by an underscore, to the name of the session uptime histogram.
+	MetricPrefix string
+
+	// NativeHistograms configures exposing the session uptime histogram as a native histogram.
+	NativeHistograms NativeHistogramConfig
+
+	// FinderDiagnostics configures logging why the parser's finder selected the previous session's event.
+	// The finder is named session_uptime.
+	FinderDiagnostics FinderDiagnosticsConfig
+}
+
+// SessionUptimeParser is triggered by the first event of a session and calculates the uptime of the previous
+// session, which is the time between the previous session's boot-time and the current boot-time.
+type SessionUptimeParser struct {
+	name          string
+	eventType     string
+	sessionFinder Finder
+	client        EventClient
+	histogram     prometheus.ObserverVec
+	measures      Measures
+	logger        *zap.Logger
+}
+
+// NewSessionUptimeParser creates a new SessionUptimeParser.
+func NewSessionUptimeParser(config SessionUptimeConfig, client EventClient, histogram prometheus.ObserverVec, measures Measures, logger *zap.Logger) (*SessionUptimeParser, error) {
+	if client == nil {
+		return nil, errNilEventClient
+	}
+
+	if histogram == nil {
+		return nil, errNilHistogram
+	}
+
+	if len(config.EventType) == 0 {
+		config.EventType = onlineEventType
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	sessionFinder := explainFinder(config.FinderDiagnostics, history.LastSessionFinder(validation.DefaultValidator()), sessionUptimeParserName, bootTimeSelectionReason, logger)
+	return &SessionUptimeParser{
+		name:          sessionUptimeParserName,
+		eventType:     config.EventType,
+		sessionFinder: measures.timeFinder(sessionFinder, sessionUptimeParserName, sessionUptimeParserName, logger),
+		client:        client,
+		histogram:     histogram,
+		measures:      measures,
+		logger:        logger,
+	}, nil
+}
+
+// Name implements the Parser interface.
+func (p *SessionUptimeParser) Name() string {
+	return p.name
+}
+
+// EventTypeRegexes implements the queue.EventTypeMatcher interface.
+func (p *SessionUptimeParser) EventTypeRegexes() []string {
+	return []string{"^" + p.eventType + "$"}
+}
+
+// Parse finds the boot-time of the device's previous session and records the difference between it and the
+// current event's boot-time as the previous session's uptime.
+func (p *SessionUptimeParser) Parse(ctx context.Context, currentEvent interpreter.Event) {
+	p.parse(ctx, currentEvent, p.client)
+}
+
+// ParseBatch implements the queue.BatchParser interface, parsing each event in the batch while only getting
+// the history of events from codex once for each device in the batch.
+func (p *SessionUptimeParser) ParseBatch(ctx context.Context, events []interpreter.Event) {
+	client := newBatchEventClient(p.client)
+	client.GetEventsBatch(ctx, batchDeviceIDs(events, p.eventType))
+	for _, event := range events {
+		p.parse(ctx, event, client)
+	}
+}
+
+func (p *SessionUptimeParser) parse(ctx context.Context, currentEvent interpreter.Event, client EventClient) {
+	eventType, err := currentEvent.EventType()
+	if err != nil || eventType != p.eventType {
+		return
+	}
+
+	deviceID, err := deviceid.FromEvent(currentEvent)
+	if err != nil {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	currentBootTime, err := currentEvent.BootTime()
+	if err != nil || currentBootTime <= 0 {
+		p.logger.Error(invalidIncomingMsg, zap.Error(err))
+		p.addToUnparsableCounters(currentEvent, errFatal)
+		return
+	}
+
+	// the first session of a device has no previous session to calculate the uptime of.
+	events := client.GetEvents(ctx, deviceID)
+	previousEvent, err := p.sessionFinder.Find(events, currentEvent)
+	if err != nil {
+		if p.measures.NewDeviceCount != nil && isNewDevice(events, currentEvent) {
+			p.logger.Debug("new device", zap.String("device id", deviceID))
+			p.measures.AddNewDevice(p.name)
+			return
+		}
+
+		p.logger.Debug("previous session not found", zap.Error(err), zap.String("device id", deviceID))
+		p.addToUnparsableCounters(currentEvent, errNoPreviousSession, events...)
+		return
+	}
+
+	previousBootTime, _ := previousEvent.BootTime()
+	uptime := currentBootTime - previousBootTime
+	if previousBootTime <= 0 || uptime <= 0 {
+		p.logger.Error("invalid session uptime calculated", zap.String("device id", deviceID), zap.Int64("uptime", uptime))
+		p.addToUnparsableCounters(currentEvent, errCalculation, previousEvent)
+		return
+	}
+
+	p.measures.addDuration(p.histogram, float64(uptime), currentEvent)
+}
+
+func (p *SessionUptimeParser) addToUnparsableCounters(event interpreter.Event, err error, compared ...interpreter.Event) {
+	p.measures.AddTotalUnparsable(p.name)
+	p.measures.AddUnparsableEventType(p.name, err, event)
+	p.measures.AddUnparsableRatio(event)
+	p.measures.LogUnparsable(p.name, err, event, compared)
+}
+
+// createSessionUptimeParsers creates the session uptime parser if it is enabled.
+func createSessionUptimeParsers(f *touchstone.Factory, config SessionUptimeConfig, client events.EventSource, measures Measures, logger *zap.Logger) ([]queue.Parser, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if f == nil {
+		return nil, errNilFactory
+	}
+
+	opts := config.NativeHistograms.apply(prometheus.HistogramOpts{
+		Name:        metricName(config.MetricPrefix, "session_uptime"),
+		Help:        "time elapsed between the boot-times of consecutive sessions in s",
+		Buckets:     []float64{60, 300, 900, 1800, 3600, 7200, 14400, 21600, 43200, 86400, 172800, 345600, 604800, 1209600, 2592000},
+		ConstLabels: measures.ConfigVariantLabels,
+	})
+	histogram, err := f.NewHistogramVec(opts, firmwareLabel, hardwareLabel, rebootReasonLabel)
+	if err != nil {
+		return nil, err
+	}
+	measures.recordBuckets(opts)
+
+	parser, err := NewSessionUptimeParser(config, parserEventClient(client, sessionUptimeParserName, sessionHistory), histogram, measures, logger.With(zap.String("parser", sessionUptimeParserName)))
+	if err != nil {
+		return nil, err
+	}
+
+	return []queue.Parser{parser}, nil
+}
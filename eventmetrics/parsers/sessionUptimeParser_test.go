@@ -0,0 +1,188 @@
+package parsers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+const testUptimeDeviceID = "mac:112233445566"
+
+func sessionEvent(eventType string, bootTime time.Time, birthdate time.Time, id string) interpreter.Event {
+	return interpreter.Event{
+		Destination:     fmt.Sprintf("event:device-status/%s/%s", testUptimeDeviceID, eventType),
+		Birthdate:       birthdate.UnixNano(),
+		TransactionUUID: id,
+		Metadata: map[string]string{
+			interpreter.BootTimeKey: fmt.Sprint(bootTime.Unix()),
+			hardwareMetadataKey:     "hw",
+			firmwareMetadataKey:     "fw",
+			rebootReasonMetadataKey: "reason",
+		},
+	}
+}
+
+func newTestUptimeHistogram() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "testSessionUptime",
+		Help:    "testSessionUptime",
+		Buckets: []float64{60, 3600, 86400, 604800},
+	}, []string{firmwareLabel, hardwareLabel, rebootReasonLabel})
+}
+
+func TestSessionUptimeParse(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	firstBoot := now.Add(-72 * time.Hour)
+	secondBoot := now.Add(-48 * time.Hour)
+	currentBoot := now.Add(-1 * time.Minute)
+	currentEvent := sessionEvent(onlineEventType, currentBoot, now, "current")
+
+	tests := []struct {
+		description        string
+		event              interpreter.Event
+		history            []interpreter.Event
+		expectedUptime     float64
+		expectedCount      uint64
+		expectedUnparsable float64
+	}{
+		{
+			description: "Multiple sessions",
+			event:       currentEvent,
+			history: []interpreter.Event{
+				sessionEvent(onlineEventType, firstBoot, firstBoot.Add(time.Minute), "1"),
+				sessionEvent(offlineEventType, firstBoot, secondBoot.Add(-1*time.Minute), "2"),
+				sessionEvent(onlineEventType, secondBoot, secondBoot.Add(time.Minute), "3"),
+				sessionEvent(offlineEventType, secondBoot, currentBoot.Add(-1*time.Minute), "4"),
+				currentEvent,
+			},
+			expectedUptime: currentBoot.Sub(secondBoot).Seconds(),
+			expectedCount:  1,
+		},
+		{
+			description: "Previous session only",
+			event:       currentEvent,
+			history: []interpreter.Event{
+				sessionEvent(onlineEventType, firstBoot, firstBoot.Add(time.Minute), "1"),
+				currentEvent,
+			},
+			expectedUptime: currentBoot.Sub(firstBoot).Seconds(),
+			expectedCount:  1,
+		},
+		{
+			description: "First session",
+			event:       currentEvent,
+			history: []interpreter.Event{
+				currentEvent,
+				sessionEvent(offlineEventType, currentBoot, now.Add(time.Minute), "5"),
+			},
+			expectedUnparsable: 1.0,
+		},
+		{
+			description:        "Missing boot-time",
+			event:              interpreter.Event{Destination: fmt.Sprintf("event:device-status/%s/online", testUptimeDeviceID)},
+			expectedUnparsable: 1.0,
+		},
+		{
+			description: "Wrong event type",
+			event:       sessionEvent(offlineEventType, currentBoot, now, "current"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockEventClient)
+			client.On("GetEvents", testUptimeDeviceID).Return(tc.history)
+			histogram := newTestUptimeHistogram()
+			unparsable := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testUnparsable",
+				Help: "testUnparsable",
+			}, []string{parserLabel})
+
+			parser, err := NewSessionUptimeParser(SessionUptimeConfig{}, client, histogram, Measures{TotalUnparsableCount: unparsable}, zap.NewNop())
+			assert.Nil(err)
+			parser.Parse(context.Background(), tc.event)
+
+			assert.Equal(tc.expectedUnparsable, testutil.ToFloat64(unparsable.WithLabelValues(sessionUptimeParserName)))
+			metric := &dto.Metric{}
+			observer := histogram.With(prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason"})
+			assert.Nil(observer.(prometheus.Metric).Write(metric))
+			assert.Equal(tc.expectedCount, metric.GetHistogram().GetSampleCount())
+			assert.Equal(tc.expectedUptime, metric.GetHistogram().GetSampleSum())
+		})
+	}
+}
+
+func TestSessionUptimeParseBatch(t *testing.T) {
+	assert := assert.New(t)
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(err)
+	previousBoot := now.Add(-48 * time.Hour)
+	currentBoot := now.Add(-1 * time.Minute)
+	currentEvent := sessionEvent(onlineEventType, currentBoot, now, "current")
+	history := []interpreter.Event{
+		sessionEvent(onlineEventType, previousBoot, previousBoot.Add(time.Minute), "1"),
+		currentEvent,
+	}
+
+	client := new(mockEventClient)
+	client.On("GetEvents", testUptimeDeviceID).Return(history).Once()
+	histogram := newTestUptimeHistogram()
+	parser, err := NewSessionUptimeParser(SessionUptimeConfig{}, client, histogram, Measures{}, zap.NewNop())
+	assert.Nil(err)
+
+	// events from the same device share one lookup, while each event is still recorded.
+	parser.ParseBatch(context.Background(), []interpreter.Event{currentEvent, currentEvent, sessionEvent(offlineEventType, currentBoot, now, "offline")})
+	client.AssertExpectations(t)
+	metric := &dto.Metric{}
+	observer := histogram.With(prometheus.Labels{firmwareLabel: "fw", hardwareLabel: "hw", rebootReasonLabel: "reason"})
+	assert.Nil(observer.(prometheus.Metric).Write(metric))
+	assert.Equal(uint64(2), metric.GetHistogram().GetSampleCount())
+	assert.Equal(2*currentBoot.Sub(previousBoot).Seconds(), metric.GetHistogram().GetSampleSum())
+}
+
+func TestNewSessionUptimeParser(t *testing.T) {
+	assert := assert.New(t)
+	histogram := newTestUptimeHistogram()
+
+	parser, err := NewSessionUptimeParser(SessionUptimeConfig{}, nil, histogram, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilEventClient, err)
+
+	parser, err = NewSessionUptimeParser(SessionUptimeConfig{}, new(mockEventClient), nil, Measures{}, nil)
+	assert.Nil(parser)
+	assert.Equal(errNilHistogram, err)
+
+	parser, err = NewSessionUptimeParser(SessionUptimeConfig{EventType: "reboot-pending"}, new(mockEventClient), histogram, Measures{}, nil)
+	assert.Nil(err)
+	assert.Equal(sessionUptimeParserName, parser.Name())
+	assert.Equal([]string{"^reboot-pending$"}, parser.EventTypeRegexes())
+}
+
+func TestCreateSessionUptimeParsers(t *testing.T) {
+	assert := assert.New(t)
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+
+	parsers, err := createSessionUptimeParsers(testFactory, SessionUptimeConfig{}, nil, Measures{}, zap.NewNop())
+	assert.Nil(err)
+	assert.Empty(parsers)
+
+	parsers, err = createSessionUptimeParsers(nil, SessionUptimeConfig{Enabled: true}, nil, Measures{}, zap.NewNop())
+	assert.Equal(errNilFactory, err)
+	assert.Empty(parsers)
+
+	parsers, err = createSessionUptimeParsers(testFactory, SessionUptimeConfig{Enabled: true}, nil, Measures{}, zap.NewNop())
+	assert.Equal(errNilEventClient, err)
+	assert.Empty(parsers)
+}
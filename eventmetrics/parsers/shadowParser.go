@@ -0,0 +1,175 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/enums"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const shadowMetricPrefix = "shadow"
+
+// ShadowParserConfig configures the shadow parser, which runs a candidate reboot duration parser config on the
+// same events as the reboot duration parser so that a config change can be validated before it is promoted.  The
+// shadow parser's histograms and unparsable counters are prefixed by shadow_, keeping them separate from the
+// reboot duration parser's.
+type ShadowParserConfig struct {
+	// Enabled turns on the shadow parser.
+	Enabled bool
+
+	// RebootDurationParser is the candidate reboot duration parser config.  Its metric prefix, if it has one,
+	// follows the shadow prefix.
+	RebootDurationParser RebootParserConfig
+}
+
+// metricPrefix returns the prefix of the shadow parser's metrics, or an empty string if the shadow parser is
+// disabled.
+func (c ShadowParserConfig) metricPrefix() string {
+	if !c.Enabled {
+		return ""
+	}
+
+	if len(c.RebootDurationParser.MetricPrefix) == 0 {
+		return shadowMetricPrefix
+	}
+
+	return metricName(shadowMetricPrefix, c.RebootDurationParser.MetricPrefix)
+}
+
+// ShadowParserIn provides everything needed to create the shadow parser.
+type ShadowParserIn struct {
+	fx.In
+	Config      ShadowParserConfig
+	Measures    Measures
+	EventSource events.EventSource
+	Factory     *touchstone.Factory
+	Logger      *zap.Logger
+}
+
+// createShadowParsers creates the shadow parser, if it is enabled, the same way as the reboot duration parser but
+// from the candidate config and with its own metrics.
+func createShadowParsers(in ShadowParserIn) ([]queue.Parser, error) {
+	if !in.Config.Enabled {
+		return nil, nil
+	}
+
+	if in.Factory == nil {
+		return nil, errNilFactory
+	}
+
+	config := in.Config.RebootDurationParser
+	config.MetricPrefix = in.Config.metricPrefix()
+	name := metricName(shadowMetricPrefix, rebootDurationParserName)
+	loggerIn := RebootLoggerIn{Logger: in.Logger.With(zap.String("parser", name))}
+
+	m, err := shadowMeasures(in.Factory, config, in.Measures)
+	if err != nil {
+		return nil, err
+	}
+
+	validatorsIn := ValidatorsIn{}
+	if validatorsIn.EventValidator, err = createEventValidators(config.EventValidators, config.BootTimeBounds); err != nil {
+		return nil, err
+	}
+	if validatorsIn.LastCycleValidator, err = createCycleValidators(config.CycleValidators, enums.BootTime); err != nil {
+		return nil, err
+	}
+	if validatorsIn.RebootCycleValidator, err = createCycleValidators(config.CycleValidators, enums.Reboot); err != nil {
+		return nil, err
+	}
+
+	callback, err := createBootDurationCallback(m, config)
+	if err != nil {
+		return nil, err
+	}
+
+	timeElapsedConfigs := prefixTimeElapsedConfigs(config.MetricPrefix, config.TimeElapsedCalculations)
+	calculators, err := createDurationCalculators(in.Factory, timeElapsedConfigs, m, config, loggerIn)
+	if err != nil {
+		return nil, err
+	}
+	calculators = append([]DurationCalculator{createBootDurationCalculator(callback, config, m, loggerIn)}, calculators...)
+
+	inferredCalculators, err := createInferredDurationCalculators(in.Factory, timeElapsedConfigs, m, config, loggerIn)
+	if err != nil {
+		return nil, err
+	}
+
+	parser, err := createRebootDurationParser(RebootParserIn{
+		Name:   name,
+		Logger: loggerIn.Logger,
+		ParserValidators: []ParserValidator{
+			createLastCycleParserValidator(validatorsIn, loggerIn, m),
+			createRebootParserValidator(validatorsIn, loggerIn, m, config),
+		},
+		Calculators:         calculators,
+		InferredCalculators: inferredCalculators,
+		Measures:            m,
+		EventSource:         in.EventSource,
+		Config:              config,
+		Factory:             in.Factory,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []queue.Parser{parser}, nil
+}
+
+// shadowMeasures returns a copy of the measures with the reboot duration parser's unparsable counters, error counters,
+// and boot_to_manageable histogram replaced by ones named with the shadow parser's metric prefix.  The sinks shared
+// with the production parsers, such as the duration exporters and the unparsable ratio, are removed so that the
+// shadow parser's results never reach them.
+func shadowMeasures(f *touchstone.Factory, config RebootParserConfig, m Measures) (Measures, error) {
+	m.DurationExporters = nil
+	m.UnparsableRatio = nil
+	m.UnparsableLogger = nil
+	m.NewDeviceCount = nil
+	m.ZeroDurationCount = nil
+
+	newCounterVec := func(opts prometheus.CounterOpts, labels prometheus.Labels, labelNames ...string) (*prometheus.CounterVec, error) {
+		opts.Name = metricName(config.MetricPrefix, opts.Name)
+		opts.ConstLabels = labels
+		return f.NewCounterVec(opts, labelNames...)
+	}
+
+	var err error
+	if m.TotalUnparsableCount, err = newCounterVec(totalUnparsableOpts, m.ConfigVariantLabels, parserLabel); err != nil {
+		return m, err
+	}
+	if m.RebootUnparsableCount, err = newCounterVec(rebootUnparsableOpts, m.ConfigVariantLabels, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel); err != nil {
+		return m, err
+	}
+	if m.EventErrorTags, err = newCounterVec(eventErrorsOpts, nil, firmwareLabel, hardwareLabel, partnerIDLabel, reasonLabel); err != nil {
+		return m, err
+	}
+	if m.BootCycleErrorTags, err = newCounterVec(bootCycleErrorsOpts, nil, reasonLabel, partnerIDLabel); err != nil {
+		return m, err
+	}
+	if m.RebootCycleErrorTags, err = newCounterVec(rebootCycleErrorsOpts, nil, reasonLabel, partnerIDLabel); err != nil {
+		return m, err
+	}
+
+	m.BootToManageableHistogram, err = newBootToManageableVec(f, config, m.ConfigVariantLabels, m.HistogramBuckets)
+	return m, err
+}
@@ -0,0 +1,194 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestShadowMetricPrefix(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         ShadowParserConfig
+		expectedPrefix string
+	}{
+		{
+			description:    "disabled",
+			config:         ShadowParserConfig{RebootDurationParser: RebootParserConfig{MetricPrefix: "test"}},
+			expectedPrefix: "",
+		},
+		{
+			description:    "no prefix",
+			config:         ShadowParserConfig{Enabled: true},
+			expectedPrefix: "shadow",
+		},
+		{
+			description:    "prefix",
+			config:         ShadowParserConfig{Enabled: true, RebootDurationParser: RebootParserConfig{MetricPrefix: "test"}},
+			expectedPrefix: "shadow_test",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedPrefix, tc.config.metricPrefix())
+		})
+	}
+}
+
+func TestCreateShadowParsers(t *testing.T) {
+	tests := []struct {
+		description     string
+		config          ShadowParserConfig
+		nilFactory      bool
+		expectedMetrics []string
+		expectedErr     error
+	}{
+		{
+			description: "disabled",
+			config:      ShadowParserConfig{},
+		},
+		{
+			description: "nil factory",
+			config:      ShadowParserConfig{Enabled: true},
+			nilFactory:  true,
+			expectedErr: errNilFactory,
+		},
+		{
+			description: "success",
+			config: ShadowParserConfig{
+				Enabled: true,
+				RebootDurationParser: RebootParserConfig{
+					TimeElapsedCalculations: []TimeElapsedConfig{
+						{Name: "reboot_to_manageable", EventType: rebootPendingEventType, SessionType: "previous"},
+					},
+				},
+			},
+			expectedMetrics: []string{"shadow_boot_to_manageable", "shadow_reboot_to_manageable"},
+		},
+		{
+			description: "prefix",
+			config: ShadowParserConfig{
+				Enabled: true,
+				RebootDurationParser: RebootParserConfig{
+					MetricPrefix: "test",
+					TimeElapsedCalculations: []TimeElapsedConfig{
+						{Name: "reboot_to_manageable", EventType: rebootPendingEventType, SessionType: "previous"},
+					},
+				},
+			},
+			expectedMetrics: []string{"shadow_test_boot_to_manageable", "shadow_test_reboot_to_manageable"},
+		},
+		{
+			description: "invalid calculation",
+			config: ShadowParserConfig{
+				Enabled: true,
+				RebootDurationParser: RebootParserConfig{
+					TimeElapsedCalculations: []TimeElapsedConfig{{EventType: rebootPendingEventType}},
+				},
+			},
+			expectedErr: errBlankHistogramName,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var f *touchstone.Factory
+			if !tc.nilFactory {
+				f = touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), prometheus.NewPedanticRegistry())
+			}
+
+			buckets := make(map[string]string)
+			parsers, err := createShadowParsers(ShadowParserIn{
+				Config:   tc.config,
+				Measures: Measures{HistogramBuckets: buckets, TimeElapsedHistograms: make(map[string]prometheus.ObserverVec)},
+				Factory:  f,
+				Logger:   zap.NewNop(),
+			})
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Empty(parsers)
+				return
+			}
+
+			assert.NoError(err)
+			if !tc.config.Enabled {
+				assert.Empty(parsers)
+				return
+			}
+
+			if !assert.Len(parsers, 1) {
+				return
+			}
+			assert.Equal("shadow_reboot_duration_parser", parsers[0].Name())
+			for _, metric := range tc.expectedMetrics {
+				assert.Contains(buckets, metric)
+			}
+		})
+	}
+}
+
+func TestShadowMeasures(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	f := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+	exporter := new(mockDurationExporter)
+	production := Measures{
+		DurationExporters: []DurationExporter{exporter},
+		UnparsableRatio:   NewUnparsableRatio(UnparsableRatioConfig{}, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ratio"}, []string{firmwareLabel})),
+		UnparsableLogger:  NewUnparsableLogger(UnparsableLogConfig{SampleRate: 1}, zaptest.NewLogger(t)),
+		NewDeviceCount:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: "newDevices"}, []string{parserLabel}),
+		ZeroDurationCount: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "zeroDurations"}, []string{calculationLabel}),
+	}
+	m, err := shadowMeasures(f, RebootParserConfig{MetricPrefix: shadowMetricPrefix}, production)
+	if !assert.NoError(err) {
+		return
+	}
+
+	// the shadow parser's results don't reach the sinks shared with the production parsers.
+	assert.Empty(m.DurationExporters)
+	assert.Nil(m.UnparsableRatio)
+	assert.Nil(m.UnparsableLogger)
+	assert.Nil(m.NewDeviceCount)
+	assert.Nil(m.ZeroDurationCount)
+	callback, err := createBootDurationCallback(m, RebootParserConfig{MetricPrefix: shadowMetricPrefix})
+	if !assert.NoError(err) {
+		return
+	}
+	callback(interpreter.Event{Metadata: map[string]string{}}, 10)
+	exporter.AssertNotCalled(t, "Export", mock.Anything)
+	assert.Len(production.DurationExporters, 1)
+
+	m.AddTotalUnparsable("shadow_reboot_duration_parser")
+	m.RebootUnparsableCount.With(prometheus.Labels{firmwareLabel: "f", hardwareLabel: "h", partnerIDLabel: "p", reasonLabel: "r"}).Inc()
+	m.EventErrorTags.With(prometheus.Labels{firmwareLabel: "f", hardwareLabel: "h", partnerIDLabel: "p", reasonLabel: "r"}).Inc()
+	m.BootCycleErrorTags.With(prometheus.Labels{reasonLabel: "r", partnerIDLabel: "p"}).Inc()
+	m.RebootCycleErrorTags.With(prometheus.Labels{reasonLabel: "r", partnerIDLabel: "p"}).Inc()
+	m.BootToManageableHistogram.With(prometheus.Labels{firmwareLabel: "f", hardwareLabel: "h", rebootReasonLabel: "r"}).Observe(1)
+
+	families, err := registry.Gather()
+	if !assert.NoError(err) {
+		return
+	}
+
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.ElementsMatch([]string{
+		"shadow_total_unparsable_count",
+		"shadow_reboot_unparsable_count",
+		"shadow_event_errors",
+		"shadow_boot_cycle_errors",
+		"shadow_reboot_cycle_errors",
+		"shadow_boot_to_manageable",
+	}, names)
+}
@@ -0,0 +1,143 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/touchstone"
+)
+
+const (
+	defaultSummaryMaxAge     = 10 * time.Minute
+	defaultSummaryAgeBuckets = 5
+)
+
+var (
+	defaultSummaryObjectives = []SummaryObjective{
+		{Quantile: 0.5, Error: 0.05},
+		{Quantile: 0.9, Error: 0.01},
+		{Quantile: 0.99, Error: 0.001},
+	}
+)
+
+// SummaryObjective is a quantile calculated by a summary and its allowed absolute error.
+type SummaryObjective struct {
+	Quantile float64
+	Error    float64
+}
+
+// SummaryConfig configures exposing a parser's duration metrics as Prometheus summaries, which calculate
+// quantiles over a sliding window instead of counting observations in buckets.  Summaries can't be aggregated
+// across instances and don't support exemplars.
+type SummaryConfig struct {
+	// Enabled turns on summaries.  If false, histograms are used.
+	Enabled bool
+
+	// Objectives are the quantiles calculated along with their allowed errors.  Defaults to the 0.5, 0.9, and
+	// 0.99 quantiles.
+	Objectives []SummaryObjective
+
+	// MaxAge is the length of the sliding window observations are kept for.  Defaults to 10m.
+	MaxAge time.Duration
+
+	// AgeBuckets is the number of buckets the sliding window is divided into, determining how often old
+	// observations are dropped.  Defaults to 5.
+	AgeBuckets uint32
+}
+
+// opts returns the summary options for the duration metric with the histogram options given.
+func (c SummaryConfig) opts(o prometheus.HistogramOpts) prometheus.SummaryOpts {
+	if len(c.Objectives) == 0 {
+		c.Objectives = defaultSummaryObjectives
+	}
+
+	if c.MaxAge <= 0 {
+		c.MaxAge = defaultSummaryMaxAge
+	}
+
+	if c.AgeBuckets == 0 {
+		c.AgeBuckets = defaultSummaryAgeBuckets
+	}
+
+	objectives := make(map[float64]float64, len(c.Objectives))
+	for _, objective := range c.Objectives {
+		objectives[objective.Quantile] = objective.Error
+	}
+
+	return prometheus.SummaryOpts{
+		Namespace:   o.Namespace,
+		Subsystem:   o.Subsystem,
+		Name:        o.Name,
+		Help:        o.Help,
+		ConstLabels: o.ConstLabels,
+		Objectives:  objectives,
+		MaxAge:      c.MaxAge,
+		AgeBuckets:  c.AgeBuckets,
+	}
+}
+
+// newDurationVec creates the duration metric as a summary if summaries are enabled, or a histogram otherwise.
+func newDurationVec(f *touchstone.Factory, summaries SummaryConfig, o prometheus.HistogramOpts, labelNames ...string) (prometheus.ObserverVec, error) {
+	if f == nil {
+		return nil, errNilFactory
+	}
+
+	if summaries.Enabled {
+		return f.NewSummaryVec(summaries.opts(o), labelNames...)
+	}
+
+	return f.NewHistogramVec(o, labelNames...)
+}
+
+// addTimeElapsedObserver adds a time elapsed summary if summaries are enabled, or a time elapsed histogram otherwise.
+func (m *Measures) addTimeElapsedObserver(f *touchstone.Factory, summaries SummaryConfig, o prometheus.HistogramOpts, labelNames ...string) error {
+	if !summaries.Enabled {
+		return m.addTimeElapsedHistogram(f, o, labelNames...)
+	}
+
+	if f == nil && m.registration == nil {
+		return errNilFactory
+	}
+
+	if _, found := m.TimeElapsedHistograms[o.Name]; found {
+		return fmt.Errorf("%w: histogram already exists", errNewHistogram)
+	}
+
+	var (
+		summary prometheus.ObserverVec
+		err     error
+	)
+	if m.registration != nil {
+		summary, err = m.registration.newSummaryVec(summaries.opts(o), labelNames...)
+	} else {
+		summary, err = f.NewSummaryVec(summaries.opts(o), labelNames...)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNewHistogram, err)
+	}
+
+	if m.TimeElapsedHistograms == nil {
+		m.TimeElapsedHistograms = make(map[string]prometheus.ObserverVec)
+	}
+
+	m.TimeElapsedHistograms[o.Name] = summary
+	return nil
+}
@@ -0,0 +1,128 @@
+package parsers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSummaryConfigOpts(t *testing.T) {
+	histogramOpts := prometheus.HistogramOpts{
+		Name:        "test",
+		Help:        "test help",
+		Buckets:     []float64{60, 120},
+		ConstLabels: prometheus.Labels{"variant": "a"},
+	}
+
+	tests := []struct {
+		description  string
+		config       SummaryConfig
+		expectedOpts prometheus.SummaryOpts
+	}{
+		{
+			description: "Defaults",
+			config:      SummaryConfig{Enabled: true},
+			expectedOpts: prometheus.SummaryOpts{
+				Name:        "test",
+				Help:        "test help",
+				ConstLabels: prometheus.Labels{"variant": "a"},
+				Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+				MaxAge:      defaultSummaryMaxAge,
+				AgeBuckets:  defaultSummaryAgeBuckets,
+			},
+		},
+		{
+			description: "Custom",
+			config: SummaryConfig{
+				Enabled:    true,
+				Objectives: []SummaryObjective{{Quantile: 0.95, Error: 0.005}},
+				MaxAge:     time.Hour,
+				AgeBuckets: 10,
+			},
+			expectedOpts: prometheus.SummaryOpts{
+				Name:        "test",
+				Help:        "test help",
+				ConstLabels: prometheus.Labels{"variant": "a"},
+				Objectives:  map[float64]float64{0.95: 0.005},
+				MaxAge:      time.Hour,
+				AgeBuckets:  10,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedOpts, tc.config.opts(histogramOpts))
+		})
+	}
+}
+
+func TestNewDurationVec(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+
+	vec, err := newDurationVec(nil, SummaryConfig{}, prometheus.HistogramOpts{Name: "test"})
+	assert.Nil(vec)
+	assert.Equal(errNilFactory, err)
+
+	histogram, err := newDurationVec(testFactory, SummaryConfig{}, prometheus.HistogramOpts{Name: "test_histogram", Help: "test"}, firmwareLabel)
+	assert.Nil(err)
+	assert.IsType(&prometheus.HistogramVec{}, histogram)
+
+	summary, err := newDurationVec(testFactory, SummaryConfig{Enabled: true}, prometheus.HistogramOpts{Name: "test_summary", Help: "test"}, firmwareLabel)
+	assert.Nil(err)
+	assert.IsType(&prometheus.SummaryVec{}, summary)
+}
+
+func TestSummaryDurations(t *testing.T) {
+	assert := assert.New(t)
+	registry := prometheus.NewPedanticRegistry()
+	testFactory := touchstone.NewFactory(touchstone.Config{}, zaptest.NewLogger(t), registry)
+	buckets := make(map[string]string)
+	testMeasures := Measures{TimeElapsedHistograms: make(map[string]prometheus.ObserverVec), HistogramBuckets: buckets, DurationExemplars: true}
+	config := RebootParserConfig{Summaries: SummaryConfig{Enabled: true, Objectives: []SummaryObjective{{Quantile: 0.5, Error: 0.05}}}}
+
+	_, err := createDurationCalculators(testFactory, []TimeElapsedConfig{{Name: "reboot_to_manageable", EventType: "reboot-pending"}}, testMeasures, config, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.Nil(err)
+	// summaries have no buckets to check.
+	assert.Empty(buckets)
+
+	_, err = createDurationCalculators(testFactory, []TimeElapsedConfig{{Name: "reboot_to_manageable", EventType: "reboot-pending"}}, testMeasures, config, RebootLoggerIn{Logger: zap.NewNop()})
+	assert.ErrorIs(err, errNewHistogram)
+
+	event := interpreter.Event{
+		TransactionUUID: "test-uuid",
+		Metadata: map[string]string{
+			firmwareMetadataKey:     "fw",
+			hardwareMetadataKey:     "hw",
+			rebootReasonMetadataKey: "reason",
+		},
+	}
+	for _, duration := range []float64{45, 90, 90, 600} {
+		testMeasures.addDuration(testMeasures.TimeElapsedHistograms["reboot_to_manageable"], duration, event)
+	}
+
+	families, err := registry.Gather()
+	assert.Nil(err)
+	if !assert.Len(families, 1) {
+		return
+	}
+
+	assert.Equal("reboot_to_manageable", families[0].GetName())
+	assert.Equal(dto.MetricType_SUMMARY, families[0].GetType())
+	summary := families[0].GetMetric()[0].GetSummary()
+	assert.Equal(uint64(4), summary.GetSampleCount())
+	assert.Equal(825.0, summary.GetSampleSum())
+	if assert.Len(summary.GetQuantile(), 1) {
+		assert.Equal(0.5, summary.GetQuantile()[0].GetQuantile())
+		assert.Equal(90.0, summary.GetQuantile()[0].GetValue())
+	}
+}
@@ -0,0 +1,126 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+// Package taxonomy classifies the errors that keep parsers from parsing events.  Each kind of error has a reason,
+// used as the value of the reason label of the parsers' metrics, and a code that identifies it in logs and
+// analyses.  Both are stable, so that dashboards and alerts don't break when the internals of parsers change:
+// reasons and codes are never renamed or reused, and new kinds are given new codes.
+package taxonomy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+// Kind is a class of parser error.
+type Kind struct {
+	// Reason is the value of the reason label of the metrics counting errors of the kind.
+	Reason string
+
+	// Code identifies the kind in logs and analyses.
+	Code int
+}
+
+// The kinds of parser errors.
+var (
+	Unknown            = Kind{Reason: "unknown", Code: 1}
+	FatalError         = Kind{Reason: "incoming_event_fatal_error", Code: 2}
+	ValidationError    = Kind{Reason: "validation_error", Code: 3}
+	EventNotFound      = Kind{Reason: "event_not_found", Code: 4}
+	CalculationError   = Kind{Reason: "time_elapsed_calculation_error", Code: 5}
+	NegativeDuration   = Kind{Reason: "negative_duration", Code: 6}
+	DurationOutOfRange = Kind{Reason: "duration_out_of_range", Code: 7}
+	ZeroDuration       = Kind{Reason: "zero_duration", Code: 8}
+	NoPreviousSession  = Kind{Reason: "no_previous_session", Code: 9}
+	NoHardwareFirmware = Kind{Reason: "no_firmware_or_hardware_key", Code: 10}
+)
+
+// The errors shared by parsers.  Errors of a more specific kind wrap the error of their general kind, so that
+// ErrNegativeDuration is also an ErrCalculation.
+var (
+	ErrFatal              = New(FatalError, "fatal error")
+	ErrValidation         = New(ValidationError, "validation error")
+	ErrEventNotFound      = New(EventNotFound, "event not found")
+	ErrCalculation        = New(CalculationError, "time elapsed calculation error")
+	ErrNegativeDuration   = Wrap(NegativeDuration, fmt.Errorf("%w: negative duration", ErrCalculation))
+	ErrDurationOutOfRange = Wrap(DurationOutOfRange, fmt.Errorf("%w: duration out of range", ErrCalculation))
+	ErrZeroDuration       = New(ZeroDuration, "zero duration")
+	ErrNoPreviousSession  = New(NoPreviousSession, "no previous session")
+)
+
+// Error is an error classified by its kind.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// New creates an error of the kind with the message given.
+func New(kind Kind, message string) error {
+	return &Error{Kind: kind, Err: errors.New(message)}
+}
+
+// Wrap classifies err as an error of the kind.  If err is nil, nil is returned.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Kind: kind, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the kind of the first classified error in err's chain.  Unclassified errors from validating
+// events are validation errors, and other unclassified errors are unknown.
+func KindOf(err error) Kind {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Kind
+	}
+
+	var (
+		validationErrs validation.Errors
+		taggedErr      validation.TaggedError
+		taggedErrs     validation.TaggedErrors
+	)
+	if errors.As(err, &validationErrs) || errors.As(err, &taggedErr) || errors.As(err, &taggedErrs) {
+		return ValidationError
+	}
+
+	return Unknown
+}
+
+// Reason returns the reason label value of err's kind.
+func Reason(err error) string {
+	return KindOf(err).Reason
+}
+
+// Code returns the code of err's kind, or 0 if err is nil.
+func Code(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	return KindOf(err).Code
+}
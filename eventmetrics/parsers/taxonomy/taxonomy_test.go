@@ -0,0 +1,99 @@
+package taxonomy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		description  string
+		err          error
+		expectedKind Kind
+	}{
+		{
+			description:  "Fatal",
+			err:          ErrFatal,
+			expectedKind: FatalError,
+		},
+		{
+			description:  "Negative duration",
+			err:          ErrNegativeDuration,
+			expectedKind: NegativeDuration,
+		},
+		{
+			description:  "Wrapped out of range",
+			err:          fmt.Errorf("failed to calculate: %w", ErrDurationOutOfRange),
+			expectedKind: DurationOutOfRange,
+		},
+		{
+			description:  "Classified error",
+			err:          Wrap(EventNotFound, errors.New("no session found")),
+			expectedKind: EventNotFound,
+		},
+		{
+			description:  "Validation errors",
+			err:          validation.Errors{validation.InvalidBootTimeErr{}},
+			expectedKind: ValidationError,
+		},
+		{
+			description:  "Tagged validation error",
+			err:          validation.InvalidBirthdateErr{},
+			expectedKind: ValidationError,
+		},
+		{
+			description:  "Unknown",
+			err:          errors.New("test error"),
+			expectedKind: Unknown,
+		},
+		{
+			description:  "Nil",
+			expectedKind: Unknown,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(tc.expectedKind, KindOf(tc.err))
+			assert.Equal(tc.expectedKind.Reason, Reason(tc.err))
+			if tc.err != nil {
+				assert.Equal(tc.expectedKind.Code, Code(tc.err))
+			} else {
+				assert.Zero(Code(tc.err))
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	assert := assert.New(t)
+	assert.ErrorIs(ErrNegativeDuration, ErrCalculation)
+	assert.ErrorIs(ErrDurationOutOfRange, ErrCalculation)
+	assert.NotErrorIs(ErrCalculation, ErrNegativeDuration)
+	assert.Equal("time elapsed calculation error: negative duration", ErrNegativeDuration.Error())
+	assert.Nil(Wrap(FatalError, nil))
+
+	cause := errors.New("test error")
+	err := Wrap(FatalError, cause)
+	assert.ErrorIs(err, cause)
+	assert.Equal(cause.Error(), err.Error())
+}
+
+func TestStableKinds(t *testing.T) {
+	assert := assert.New(t)
+	kinds := []Kind{Unknown, FatalError, ValidationError, EventNotFound, CalculationError, NegativeDuration,
+		DurationOutOfRange, ZeroDuration, NoPreviousSession, NoHardwareFirmware}
+	reasons := make(map[string]bool)
+	codes := make(map[int]bool)
+	for _, kind := range kinds {
+		assert.False(reasons[kind.Reason], kind.Reason)
+		assert.False(codes[kind.Code], kind.Reason)
+		reasons[kind.Reason] = true
+		codes[kind.Code] = true
+	}
+}
@@ -0,0 +1,122 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"math/rand"
+
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+// UnparsableLogConfig configures logging the details of a sample of the events parsers can't parse, at debug
+// level, to help find why they couldn't be parsed.
+type UnparsableLogConfig struct {
+	// SampleRate is the fraction of unparsable events of each reason, between 0 and 1, that are logged.  If it
+	// is 0, only the reasons in Reasons are logged.
+	SampleRate float64
+
+	// Reasons overrides the sample rate of the unparsable events of a reason, keyed by the value of the reason
+	// label, such as time_elapsed_calculation_error.
+	Reasons map[string]float64
+}
+
+// UnparsableLogger logs the details of a sample of unparsable events, along with the events they were compared
+// to.
+type UnparsableLogger struct {
+	sampleRate float64
+	reasons    map[string]float64
+	logger     *zap.Logger
+	random     func() float64
+}
+
+// loggedEvent is the part of an event compared to an unparsable event that is logged.
+type loggedEvent struct {
+	ID          string `json:"id"`
+	Destination string `json:"destination"`
+	BootTime    int64  `json:"bootTime"`
+	Birthdate   int64  `json:"birthdate"`
+}
+
+// NewUnparsableLogger creates an UnparsableLogger, returning nil if no unparsable events are sampled.
+func NewUnparsableLogger(config UnparsableLogConfig, logger *zap.Logger) *UnparsableLogger {
+	sampled := config.SampleRate > 0
+	for _, rate := range config.Reasons {
+		sampled = sampled || rate > 0
+	}
+
+	if !sampled {
+		return nil
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &UnparsableLogger{
+		sampleRate: config.SampleRate,
+		reasons:    config.Reasons,
+		logger:     logger,
+		random:     rand.Float64, // nolint:gosec
+	}
+}
+
+// Log logs the unparsable event, and the events it was compared to, if it is sampled.
+func (l *UnparsableLogger) Log(parserName string, err error, event interpreter.Event, compared []interpreter.Event) {
+	if l == nil || !l.logger.Core().Enabled(zap.DebugLevel) {
+		return
+	}
+
+	kind := taxonomy.KindOf(err)
+	rate, found := l.reasons[kind.Reason]
+	if !found {
+		rate = l.sampleRate
+	}
+
+	if rate <= 0 || l.random() >= rate {
+		return
+	}
+
+	comparedEvents := make([]loggedEvent, 0, len(compared))
+	for _, e := range compared {
+		bootTime, _ := e.BootTime()
+		comparedEvents = append(comparedEvents, loggedEvent{ID: e.TransactionUUID, Destination: e.Destination, BootTime: bootTime, Birthdate: e.Birthdate})
+	}
+
+	deviceID, _ := deviceid.FromEvent(event)
+	bootTime, _ := event.BootTime()
+	l.logger.Debug("unparsable event",
+		zap.String("parser", parserName),
+		zap.String("reason", kind.Reason),
+		zap.Int("error code", kind.Code),
+		zap.Error(err),
+		zap.String("deviceID", deviceID),
+		zap.String("event id", event.TransactionUUID),
+		zap.String("event destination", event.Destination),
+		zap.Any("metadata", event.Metadata),
+		zap.Int64("boot-time", bootTime),
+		zap.Int64("birthdate", event.Birthdate),
+		zap.Any("compared events", comparedEvents))
+}
+
+// LogUnparsable logs the details of the unparsable event, and the events it was compared to, if it is sampled.
+func (m *Measures) LogUnparsable(parserName string, err error, event interpreter.Event, compared []interpreter.Event) {
+	m.UnparsableLogger.Log(parserName, err, event, compared)
+}
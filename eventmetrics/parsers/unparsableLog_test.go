@@ -0,0 +1,116 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers/taxonomy"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewUnparsableLogger(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(NewUnparsableLogger(UnparsableLogConfig{}, zap.NewNop()))
+	assert.Nil(NewUnparsableLogger(UnparsableLogConfig{Reasons: map[string]float64{"validation_error": 0}}, zap.NewNop()))
+	assert.NotNil(NewUnparsableLogger(UnparsableLogConfig{SampleRate: 0.1}, nil))
+	assert.NotNil(NewUnparsableLogger(UnparsableLogConfig{Reasons: map[string]float64{"validation_error": 0.5}}, zap.NewNop()))
+
+	// a nil logger logs nothing.
+	var logger *UnparsableLogger
+	logger.Log("test_parser", errFatal, interpreter.Event{}, nil)
+}
+
+func TestUnparsableLoggerLog(t *testing.T) {
+	event := interpreter.Event{
+		TransactionUUID: "current",
+		Source:          "mac:112233445566",
+		Destination:     "event:device-status/mac:112233445566/fully-manageable/1614265173",
+		Birthdate:       1614265174000000000,
+		Metadata:        map[string]string{interpreter.BootTimeKey: "1614265173", "/hw-model": "hw"},
+	}
+	compared := interpreter.Event{
+		TransactionUUID: "previous",
+		Destination:     "event:device-status/mac:112233445566/reboot-pending/1614265000",
+		Birthdate:       1614265100000000000,
+		Metadata:        map[string]string{interpreter.BootTimeKey: "1614265000"},
+	}
+
+	tests := []struct {
+		description string
+		config      UnparsableLogConfig
+		level       zapcore.Level
+		err         error
+		random      float64
+		expectedLog bool
+	}{
+		{
+			description: "Sampled",
+			config:      UnparsableLogConfig{SampleRate: 0.5},
+			level:       zapcore.DebugLevel,
+			err:         errCalculation,
+			random:      0.2,
+			expectedLog: true,
+		},
+		{
+			description: "Not sampled",
+			config:      UnparsableLogConfig{SampleRate: 0.5},
+			level:       zapcore.DebugLevel,
+			err:         errCalculation,
+			random:      0.7,
+		},
+		{
+			description: "Reason sample rate",
+			config:      UnparsableLogConfig{SampleRate: 0.1, Reasons: map[string]float64{taxonomy.CalculationError.Reason: 1}},
+			level:       zapcore.DebugLevel,
+			err:         errCalculation,
+			random:      0.7,
+			expectedLog: true,
+		},
+		{
+			description: "Reason not logged",
+			config:      UnparsableLogConfig{SampleRate: 1, Reasons: map[string]float64{taxonomy.FatalError.Reason: 0}},
+			level:       zapcore.DebugLevel,
+			err:         errFatal,
+			random:      0,
+		},
+		{
+			description: "Debug level disabled",
+			config:      UnparsableLogConfig{SampleRate: 1},
+			level:       zapcore.InfoLevel,
+			err:         errCalculation,
+			random:      0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			core, logs := observer.New(tc.level)
+			logger := NewUnparsableLogger(tc.config, zap.New(core))
+			logger.random = func() float64 { return tc.random }
+			m := Measures{UnparsableLogger: logger}
+			m.LogUnparsable("test_parser", tc.err, event, []interpreter.Event{compared})
+			if !tc.expectedLog {
+				assert.Zero(logs.Len())
+				return
+			}
+
+			if !assert.Equal(1, logs.Len()) {
+				return
+			}
+
+			fields := logs.All()[0].ContextMap()
+			assert.Equal("test_parser", fields["parser"])
+			assert.Equal(taxonomy.Reason(tc.err), fields["reason"])
+			assert.Equal(int64(taxonomy.Code(tc.err)), fields["error code"])
+			assert.Equal("mac:112233445566", fields["deviceID"])
+			assert.Equal(event.Destination, fields["event destination"])
+			assert.Equal(int64(1614265173), fields["boot-time"])
+			assert.Equal(event.Metadata, fields["metadata"])
+			assert.Equal([]loggedEvent{{ID: "previous", Destination: compared.Destination, BootTime: 1614265000, Birthdate: compared.Birthdate}}, fields["compared events"])
+		})
+	}
+}
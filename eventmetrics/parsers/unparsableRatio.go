@@ -0,0 +1,264 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/fx"
+)
+
+const (
+	unparsableRatioParserName = "unparsable_ratio"
+
+	defaultUnparsableRatioWindow   = 10 * time.Minute
+	defaultUnparsableRatioInterval = time.Minute
+)
+
+// UnparsableRatioConfig configures the rolling ratio of unparsable events to total events of each firmware
+// version.
+type UnparsableRatioConfig struct {
+	// Enabled turns on the unparsable_ratio gauge.
+	Enabled bool
+
+	// Window is how far back events are counted in the ratio.  Defaults to 10m.
+	Window time.Duration
+
+	// Interval is how often the ratio is updated.  Events are counted in buckets of this length, so the window is
+	// rounded up to a multiple of it.  Defaults to 1m.
+	Interval time.Duration
+}
+
+// unparsableRatioBucket counts the events of each firmware version received during one interval.
+type unparsableRatioBucket struct {
+	total      map[string]int
+	unparsable map[string]map[string]struct{}
+}
+
+func newUnparsableRatioBucket() unparsableRatioBucket {
+	return unparsableRatioBucket{
+		total:      make(map[string]int),
+		unparsable: make(map[string]map[string]struct{}),
+	}
+}
+
+// UnparsableRatio tracks the ratio of unparsable events to total events of each firmware version over a rolling
+// window.  An event is counted as unparsable once, no matter how many parsers couldn't parse it.
+type UnparsableRatio struct {
+	interval time.Duration
+	gauge    *prometheus.GaugeVec
+
+	lock      sync.Mutex
+	buckets   []unparsableRatioBucket
+	current   int
+	firmwares map[string]bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewUnparsableRatio creates an UnparsableRatio that sets the ratio of each firmware version in gauge.
+func NewUnparsableRatio(config UnparsableRatioConfig, gauge *prometheus.GaugeVec) *UnparsableRatio {
+	if config.Window <= 0 {
+		config.Window = defaultUnparsableRatioWindow
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = defaultUnparsableRatioInterval
+	}
+
+	count := int((config.Window + config.Interval - 1) / config.Interval)
+	buckets := make([]unparsableRatioBucket, count)
+	for i := range buckets {
+		buckets[i] = newUnparsableRatioBucket()
+	}
+
+	return &UnparsableRatio{
+		interval:  config.Interval,
+		gauge:     gauge,
+		buckets:   buckets,
+		firmwares: make(map[string]bool),
+	}
+}
+
+// Total counts the event in the total events of its firmware version.
+func (r *UnparsableRatio) Total(event interpreter.Event) {
+	if r == nil {
+		return
+	}
+
+	_, firmware, _ := getHardwareFirmware(event)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.buckets[r.current].total[firmware]++
+}
+
+// Unparsable counts the event in the unparsable events of its firmware version.
+func (r *UnparsableRatio) Unparsable(event interpreter.Event) {
+	if r == nil {
+		return
+	}
+
+	_, firmware, _ := getHardwareFirmware(event)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	bucket := r.buckets[r.current]
+	ids, found := bucket.unparsable[firmware]
+	if !found {
+		ids = make(map[string]struct{})
+		bucket.unparsable[firmware] = ids
+	}
+	ids[event.TransactionUUID] = struct{}{}
+}
+
+// update sets the gauge of each firmware version with events in the window, removes the gauges of the firmware
+// versions without any, then starts a new interval, forgetting the oldest one.
+func (r *UnparsableRatio) update() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	totals := make(map[string]int)
+	unparsables := make(map[string]int)
+	for _, bucket := range r.buckets {
+		for firmware, count := range bucket.total {
+			totals[firmware] += count
+		}
+		for firmware, ids := range bucket.unparsable {
+			unparsables[firmware] += len(ids)
+		}
+	}
+
+	for firmware := range r.firmwares {
+		if totals[firmware] == 0 {
+			r.gauge.Delete(prometheus.Labels{firmwareLabel: firmware})
+			delete(r.firmwares, firmware)
+		}
+	}
+
+	for firmware, total := range totals {
+		if total == 0 {
+			continue
+		}
+
+		// an event may be counted as unparsable before it is counted in the total, so the ratio is capped at 1.
+		ratio := float64(unparsables[firmware]) / float64(total)
+		if ratio > 1 {
+			ratio = 1
+		}
+		r.gauge.With(prometheus.Labels{firmwareLabel: firmware}).Set(ratio)
+		r.firmwares[firmware] = true
+	}
+
+	r.current = (r.current + 1) % len(r.buckets)
+	r.buckets[r.current] = newUnparsableRatioBucket()
+}
+
+// Start updates the ratios every interval until stopped.
+func (r *UnparsableRatio) Start() {
+	r.done = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.update()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops updating the ratios.
+func (r *UnparsableRatio) Stop() {
+	if r.done != nil {
+		close(r.done)
+		r.wg.Wait()
+	}
+}
+
+// newUnparsableRatio creates the UnparsableRatio, updated while the application runs, if it is enabled.
+func newUnparsableRatio(f *touchstone.Factory, config UnparsableRatioConfig, in ConfigVariantLabelsIn, lc fx.Lifecycle) (*UnparsableRatio, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	gauge, err := f.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "unparsable_ratio",
+			Help:        "the ratio of unparsable events to total events over a rolling window, labeled by firmware version",
+			ConstLabels: in.Labels,
+		},
+		firmwareLabel,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := NewUnparsableRatio(config, gauge)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ratio.Start()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			ratio.Stop()
+			return nil
+		},
+	})
+	return ratio, nil
+}
+
+// unparsableRatioParser counts every event in the total events of the unparsable ratio.
+type unparsableRatioParser struct {
+	ratio *UnparsableRatio
+}
+
+// Parse implements the queue.Parser interface.
+func (p unparsableRatioParser) Parse(_ context.Context, event interpreter.Event) {
+	p.ratio.Total(event)
+}
+
+// Name implements the queue.Parser interface.
+func (p unparsableRatioParser) Name() string {
+	return unparsableRatioParserName
+}
+
+// createUnparsableRatioParsers creates the parser counting the total events of the unparsable ratio, if it is
+// enabled.
+func createUnparsableRatioParsers(measures Measures) []queue.Parser {
+	if measures.UnparsableRatio == nil {
+		return []queue.Parser{}
+	}
+
+	return []queue.Parser{unparsableRatioParser{ratio: measures.UnparsableRatio}}
+}
+
+// AddUnparsableRatio counts the event in the unparsable events of its firmware version's unparsable ratio.
+func (m *Measures) AddUnparsableRatio(event interpreter.Event) {
+	m.UnparsableRatio.Unparsable(event)
+}
@@ -0,0 +1,136 @@
+package parsers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestNewUnparsableRatio(t *testing.T) {
+	tests := []struct {
+		description      string
+		config           UnparsableRatioConfig
+		expectedBuckets  int
+		expectedInterval time.Duration
+	}{
+		{
+			description:      "Defaults",
+			expectedBuckets:  10,
+			expectedInterval: time.Minute,
+		},
+		{
+			description:      "Window rounded up",
+			config:           UnparsableRatioConfig{Window: 5*time.Minute + time.Second, Interval: time.Minute},
+			expectedBuckets:  6,
+			expectedInterval: time.Minute,
+		},
+		{
+			description:      "Interval longer than window",
+			config:           UnparsableRatioConfig{Window: time.Minute, Interval: 2 * time.Minute},
+			expectedBuckets:  1,
+			expectedInterval: 2 * time.Minute,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			ratio := NewUnparsableRatio(tc.config, nil)
+			assert.Len(ratio.buckets, tc.expectedBuckets)
+			assert.Equal(tc.expectedInterval, ratio.interval)
+		})
+	}
+}
+
+func TestUnparsableRatio(t *testing.T) {
+	assert := assert.New(t)
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "unparsable_ratio"}, []string{firmwareLabel})
+	ratio := NewUnparsableRatio(UnparsableRatioConfig{Window: 2 * time.Minute, Interval: time.Minute}, gauge)
+
+	firmwareEvent := func(id string, firmware string) interpreter.Event {
+		return interpreter.Event{TransactionUUID: id, Metadata: map[string]string{firmwareMetadataKey: firmware}}
+	}
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		ratio.Total(firmwareEvent(id, "fw1"))
+	}
+	ratio.Total(interpreter.Event{TransactionUUID: "5"})
+
+	// an event unparsable by several parsers is only counted once.
+	ratio.Unparsable(firmwareEvent("1", "fw1"))
+	ratio.Unparsable(firmwareEvent("1", "fw1"))
+	ratio.Unparsable(interpreter.Event{TransactionUUID: "5"})
+
+	// an event counted as unparsable before its total doesn't push the ratio over 1.
+	ratio.Unparsable(firmwareEvent("6", "fw2"))
+	ratio.Unparsable(firmwareEvent("7", "fw2"))
+	ratio.Total(firmwareEvent("6", "fw2"))
+
+	ratio.update()
+	assert.Equal(0.25, testutil.ToFloat64(gauge.With(prometheus.Labels{firmwareLabel: "fw1"})))
+	assert.Equal(1.0, testutil.ToFloat64(gauge.With(prometheus.Labels{firmwareLabel: unknownLabelValue})))
+	assert.Equal(1.0, testutil.ToFloat64(gauge.With(prometheus.Labels{firmwareLabel: "fw2"})))
+
+	// the next interval adds to the ratio of the window.
+	ratio.Total(firmwareEvent("8", "fw1"))
+	ratio.Total(firmwareEvent("9", "fw1"))
+	ratio.Total(firmwareEvent("10", "fw1"))
+	ratio.Total(firmwareEvent("11", "fw1"))
+	ratio.Unparsable(firmwareEvent("8", "fw1"))
+	ratio.Unparsable(firmwareEvent("9", "fw1"))
+	ratio.update()
+	assert.Equal(0.375, testutil.ToFloat64(gauge.With(prometheus.Labels{firmwareLabel: "fw1"})))
+
+	// the first interval leaves the window, so the firmware versions only seen then are removed.
+	ratio.update()
+	assert.Equal(0.5, testutil.ToFloat64(gauge.With(prometheus.Labels{firmwareLabel: "fw1"})))
+	assert.Equal(1, testutil.CollectAndCount(gauge))
+
+	ratio.update()
+	assert.Equal(0, testutil.CollectAndCount(gauge))
+}
+
+func TestUnparsableRatioNil(t *testing.T) {
+	var ratio *UnparsableRatio
+	ratio.Total(interpreter.Event{})
+	ratio.Unparsable(interpreter.Event{})
+
+	m := Measures{}
+	m.AddUnparsableRatio(interpreter.Event{})
+
+	parsers := createUnparsableRatioParsers(m)
+	assert.Empty(t, parsers)
+}
+
+func TestUnparsableRatioParser(t *testing.T) {
+	assert := assert.New(t)
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "unparsable_ratio"}, []string{firmwareLabel})
+	m := Measures{UnparsableRatio: NewUnparsableRatio(UnparsableRatioConfig{}, gauge)}
+	parsers := createUnparsableRatioParsers(m)
+	if !assert.Len(parsers, 1) {
+		return
+	}
+
+	assert.Equal(unparsableRatioParserName, parsers[0].Name())
+	parsers[0].Parse(context.Background(), interpreter.Event{TransactionUUID: "1"})
+	parsers[0].Parse(context.Background(), interpreter.Event{TransactionUUID: "2"})
+	m.AddUnparsableRatio(interpreter.Event{TransactionUUID: "2"})
+	m.UnparsableRatio.update()
+	assert.Equal(0.5, testutil.ToFloat64(gauge.With(prometheus.Labels{firmwareLabel: unknownLabelValue})))
+}
+
+func TestUnparsableRatioStartStop(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "unparsable_ratio"}, []string{firmwareLabel})
+	ratio := NewUnparsableRatio(UnparsableRatioConfig{Window: 10 * time.Millisecond, Interval: time.Millisecond}, gauge)
+	ratio.Total(interpreter.Event{})
+	ratio.Start()
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCount(gauge) == 1
+	}, time.Second, time.Millisecond)
+	ratio.Stop()
+}
@@ -0,0 +1,264 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+const (
+	sourceRuleField      = "source"
+	destinationRuleField = "destination"
+	eventTypeRuleField   = "event-type"
+	contentTypeRuleField = "content-type"
+	payloadRuleField     = "payload"
+	sessionIDRuleField   = "session-id"
+	metadataRuleField    = "metadata"
+
+	defaultMinBootTimeYear  = 2015
+	defaultMaxBootTimeAhead = 24 * time.Hour
+
+	// implausibleBootTimeReason is the reason events rejected by the boot-time bounds are counted under.
+	implausibleBootTimeReason = "implausible_boot_time"
+)
+
+var (
+	errInvalidRuleField   = errors.New("invalid validation rule field")
+	errMissingRuleKey     = errors.New("metadata validation rule needs a key")
+	errInvalidRulePattern = errors.New("invalid validation rule pattern")
+	errInvalidRuleTag     = errors.New("invalid validation rule tag")
+	errEmptyRule          = errors.New("validation rule checks nothing")
+
+	errMissingField      = errors.New("field missing")
+	errMismatchedField   = errors.New("field doesn't match pattern")
+	errDecreasedBootTime = errors.New("boot-time decreased")
+
+	// ErrImplausibleBootTime is returned by the boot-time bounds validator when a boot-time is outside of the
+	// plausible range.
+	ErrImplausibleBootTime = errors.New("boot-time is outside of the plausible range")
+)
+
+// BootTimeBoundsConfig configures the absolute bounds that an event's boot-time must fall within.  It is used by both
+// the incoming events and the events from codex history checked by the parsers.
+type BootTimeBoundsConfig struct {
+	// Enabled turns on rejecting events with an implausible boot-time.
+	Enabled bool
+
+	// MinYear is the earliest year a boot-time can be in.  Defaults to 2015.
+	MinYear int
+
+	// MaxAhead is how far past the current time a boot-time can be.  Defaults to 24h.
+	MaxAhead time.Duration
+}
+
+// ValidationRuleConfig configures a rule of the rule event validator, which checks a field of each event, so that
+// new validations can be added without code changes.
+type ValidationRuleConfig struct {
+	// Field is the field of the event checked: "source", "destination", "event-type", "content-type", "payload",
+	// "session-id", or "metadata".
+	Field string
+
+	// Key is the metadata key checked, for the metadata field.
+	Key string
+
+	// Required rejects events whose field is missing or empty.
+	Required bool
+
+	// Pattern is a regular expression that the field must match, if it isn't empty.
+	Pattern string
+
+	// Invert rejects events whose field matches Pattern, rather than events whose field doesn't.
+	Invert bool
+
+	// Tag is the tag that events rejected by the rule are counted under in the event_errors metric, such as
+	// invalid_destination.  It must be one of the tags of interpreter's validation package.  Defaults to unknown.
+	Tag string
+}
+
+// createRuleValidators creates a validator that checks each event against all of the rules, returning an error
+// if a rule is invalid.
+func createRuleValidators(configs []ValidationRuleConfig) (validation.Validator, error) {
+	validators := make(validation.Validators, 0, len(configs))
+	for _, config := range configs {
+		validator, err := createRuleValidator(config)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, validator)
+	}
+
+	return validators, nil
+}
+
+// createRuleValidator creates the validator of a rule.
+func createRuleValidator(config ValidationRuleConfig) (validation.ValidatorFunc, error) {
+	field := strings.ToLower(config.Field)
+	getter, found := ruleFields[field]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", errInvalidRuleField, config.Field)
+	}
+
+	if field == metadataRuleField && len(config.Key) == 0 {
+		return nil, errMissingRuleKey
+	}
+
+	if !config.Required && len(config.Pattern) == 0 {
+		return nil, fmt.Errorf("%w: %s", errEmptyRule, ruleName(config))
+	}
+
+	var pattern *regexp.Regexp
+	if len(config.Pattern) > 0 {
+		var err error
+		if pattern, err = regexp.Compile(config.Pattern); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidRulePattern, err)
+		}
+	}
+
+	tag := validation.ParseTag(config.Tag)
+	if tag == validation.Unknown && len(config.Tag) > 0 && !strings.EqualFold(config.Tag, validation.UnknownStr) {
+		return nil, fmt.Errorf("%w: %q", errInvalidRuleTag, config.Tag)
+	}
+
+	name := ruleName(config)
+	return func(event interpreter.Event) (bool, error) {
+		value := getter(event, config.Key)
+		if len(value) == 0 {
+			if config.Required {
+				return false, validation.InvalidEventErr{OriginalErr: fmt.Errorf("%w: %s", errMissingField, name), ErrorTag: tag}
+			}
+			return true, nil
+		}
+
+		if pattern != nil && pattern.MatchString(value) == config.Invert {
+			return false, validation.InvalidEventErr{OriginalErr: fmt.Errorf("%w: %s", errMismatchedField, name), ErrorTag: tag}
+		}
+
+		return true, nil
+	}, nil
+}
+
+// ruleFields get the fields of events that rules can check.
+var ruleFields = map[string]func(event interpreter.Event, key string) string{
+	sourceRuleField:      func(event interpreter.Event, _ string) string { return event.Source },
+	destinationRuleField: func(event interpreter.Event, _ string) string { return event.Destination },
+	eventTypeRuleField: func(event interpreter.Event, _ string) string {
+		eventType, _ := event.EventType()
+		return eventType
+	},
+	contentTypeRuleField: func(event interpreter.Event, _ string) string { return event.ContentType },
+	payloadRuleField:     func(event interpreter.Event, _ string) string { return event.Payload },
+	sessionIDRuleField:   func(event interpreter.Event, _ string) string { return event.SessionID },
+	metadataRuleField: func(event interpreter.Event, key string) string {
+		value, _ := event.GetMetadataValue(key)
+		return value
+	},
+}
+
+// ruleName describes the field a rule checks, for its errors.
+func ruleName(config ValidationRuleConfig) string {
+	if len(config.Key) > 0 {
+		return fmt.Sprintf("%s %s", config.Field, config.Key)
+	}
+
+	return config.Field
+}
+
+// monotonicBootTimeValidator returns a CycleValidatorFunc that validates that the boot-times of the events in the
+// cycle never decrease from one event to the next, ordered by birthdate, such as when a device reports a stale
+// boot-time after rebooting.  Events without a boot-time are skipped.
+func monotonicBootTimeValidator() history.CycleValidatorFunc {
+	return func(events []interpreter.Event) (bool, error) {
+		sorted := make([]interpreter.Event, len(events))
+		copy(sorted, events)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Birthdate < sorted[j].Birthdate
+		})
+
+		var (
+			previous int64
+			invalid  []string
+		)
+		for _, event := range sorted {
+			bootTime, err := event.BootTime()
+			if err != nil || bootTime <= 0 {
+				continue
+			}
+
+			if bootTime < previous {
+				invalid = append(invalid, event.TransactionUUID)
+				continue
+			}
+			previous = bootTime
+		}
+
+		if len(invalid) > 0 {
+			return false, history.CycleValidationErr{
+				OriginalErr:       errDecreasedBootTime,
+				ErrorDetailKey:    "decreased_boot_time",
+				ErrorDetailValues: invalid,
+				ErrorTag:          validation.InvalidBootTime,
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// BootTimeBoundsValidator returns a validator that rejects events whose boot-time is before the start of
+// minYear or more than maxAhead past the current time, such as 0, negative, or far-future boot-times.
+// Events without a parsable boot-time are not rejected.
+func BootTimeBoundsValidator(minYear int, maxAhead time.Duration, current func() time.Time) validation.ValidatorFunc {
+	if minYear <= 0 {
+		minYear = defaultMinBootTimeYear
+	}
+
+	if maxAhead <= 0 {
+		maxAhead = defaultMaxBootTimeAhead
+	}
+
+	if current == nil {
+		current = time.Now
+	}
+
+	minBootTime := time.Date(minYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return func(e interpreter.Event) (bool, error) {
+		bootTime, err := e.BootTime()
+		if err != nil {
+			return true, nil
+		}
+
+		bootTimeUnix := time.Unix(bootTime, 0)
+		maxBootTime := current().Add(maxAhead)
+		if bootTimeUnix.Before(minBootTime) || bootTimeUnix.After(maxBootTime) {
+			return false, validation.InvalidBootTimeErr{
+				OriginalErr: fmt.Errorf("%w: boot-time %d not between %s and %s", ErrImplausibleBootTime, bootTime, minBootTime, maxBootTime.UTC()),
+			}
+		}
+
+		return true, nil
+	}
+}
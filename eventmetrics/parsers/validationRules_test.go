@@ -0,0 +1,315 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+func TestCreateRuleValidators(t *testing.T) {
+	tests := []struct {
+		description string
+		configs     []ValidationRuleConfig
+		expectedErr error
+	}{
+		{
+			description: "valid",
+			configs: []ValidationRuleConfig{
+				{Field: "metadata", Key: "/fw-name", Required: true},
+				{Field: "Destination", Pattern: "^event:device-status/"},
+			},
+		},
+		{
+			description: "no rules",
+		},
+		{
+			description: "invalid field",
+			configs:     []ValidationRuleConfig{{Field: "random", Required: true}},
+			expectedErr: errInvalidRuleField,
+		},
+		{
+			description: "missing metadata key",
+			configs:     []ValidationRuleConfig{{Field: "metadata", Required: true}},
+			expectedErr: errMissingRuleKey,
+		},
+		{
+			description: "empty rule",
+			configs:     []ValidationRuleConfig{{Field: "source"}},
+			expectedErr: errEmptyRule,
+		},
+		{
+			description: "invalid pattern",
+			configs:     []ValidationRuleConfig{{Field: "payload", Pattern: "[a-"}},
+			expectedErr: errInvalidRulePattern,
+		},
+		{
+			description: "invalid tag",
+			configs:     []ValidationRuleConfig{{Field: "source", Required: true, Tag: "random_tag"}},
+			expectedErr: errInvalidRuleTag,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			validator, err := createRuleValidators(tc.configs)
+			if tc.expectedErr != nil {
+				assert.True(errors.Is(err, tc.expectedErr))
+				assert.Nil(validator)
+				return
+			}
+
+			assert.Nil(err)
+			assert.NotNil(validator)
+		})
+	}
+}
+
+func TestRuleValidator(t *testing.T) {
+	event := interpreter.Event{
+		Source:      "mac:112233445566",
+		Destination: "event:device-status/mac:112233445566/online",
+		Payload:     `{"status":"ok"}`,
+		Metadata: map[string]string{
+			"/fw-name": "mock-firmware",
+		},
+	}
+
+	tests := []struct {
+		description string
+		config      ValidationRuleConfig
+		expectedErr error
+		expectedTag validation.Tag
+	}{
+		{
+			description: "required metadata present",
+			config:      ValidationRuleConfig{Field: "metadata", Key: "/fw-name", Required: true},
+		},
+		{
+			description: "required metadata missing",
+			config:      ValidationRuleConfig{Field: "metadata", Key: interpreter.BootTimeKey, Required: true, Tag: "missing_boot_time"},
+			expectedErr: errMissingField,
+			expectedTag: validation.MissingBootTime,
+		},
+		{
+			description: "optional field missing",
+			config:      ValidationRuleConfig{Field: "content-type", Pattern: "json"},
+		},
+		{
+			description: "payload matches",
+			config:      ValidationRuleConfig{Field: "payload", Pattern: `"status":"ok"`},
+		},
+		{
+			description: "payload doesn't match",
+			config:      ValidationRuleConfig{Field: "payload", Pattern: `"status":"error"`},
+			expectedErr: errMismatchedField,
+			expectedTag: validation.Unknown,
+		},
+		{
+			description: "inverted match",
+			config:      ValidationRuleConfig{Field: "event-type", Pattern: "^online$", Invert: true, Tag: "invalid_event_type"},
+			expectedErr: errMismatchedField,
+			expectedTag: validation.InvalidEventType,
+		},
+		{
+			description: "inverted no match",
+			config:      ValidationRuleConfig{Field: "source", Pattern: "^serial:", Invert: true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			validator, err := createRuleValidator(tc.config)
+			if !assert.Nil(err) {
+				return
+			}
+
+			valid, err := validator.Valid(event)
+			if tc.expectedErr == nil {
+				assert.True(valid)
+				assert.Nil(err)
+				return
+			}
+
+			assert.False(valid)
+			assert.True(errors.Is(err, tc.expectedErr))
+			var taggedErr validation.TaggedError
+			if assert.True(errors.As(err, &taggedErr)) {
+				assert.Equal(tc.expectedTag, taggedErr.Tag())
+			}
+		})
+	}
+}
+
+func TestMonotonicBootTimeValidator(t *testing.T) {
+	createEvent := func(id string, birthdate int64, bootTime string) interpreter.Event {
+		metadata := map[string]string{}
+		if len(bootTime) > 0 {
+			metadata[interpreter.BootTimeKey] = bootTime
+		}
+		return interpreter.Event{TransactionUUID: id, Birthdate: birthdate, Metadata: metadata}
+	}
+
+	tests := []struct {
+		description string
+		events      []interpreter.Event
+		expectedIDs []string
+	}{
+		{
+			description: "increasing",
+			events: []interpreter.Event{
+				createEvent("1", 100, "10"),
+				createEvent("2", 200, "10"),
+				createEvent("3", 300, "20"),
+			},
+		},
+		{
+			description: "unordered events",
+			events: []interpreter.Event{
+				createEvent("3", 300, "20"),
+				createEvent("1", 100, "10"),
+				createEvent("2", 200, "15"),
+			},
+		},
+		{
+			description: "missing boot-time",
+			events: []interpreter.Event{
+				createEvent("1", 100, "10"),
+				createEvent("2", 200, ""),
+				createEvent("3", 300, "20"),
+			},
+		},
+		{
+			description: "decreasing",
+			events: []interpreter.Event{
+				createEvent("1", 100, "10"),
+				createEvent("2", 200, "30"),
+				createEvent("3", 300, "20"),
+				createEvent("4", 400, "25"),
+				createEvent("5", 500, "40"),
+			},
+			expectedIDs: []string{"3", "4"},
+		},
+		{
+			description: "no events",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			valid, err := monotonicBootTimeValidator().Valid(tc.events)
+			if len(tc.expectedIDs) == 0 {
+				assert.True(valid)
+				assert.Nil(err)
+				return
+			}
+
+			assert.False(valid)
+			var cycleErr history.CycleValidationErr
+			if assert.True(errors.As(err, &cycleErr)) {
+				assert.True(errors.Is(err, errDecreasedBootTime))
+				assert.Equal(validation.InvalidBootTime, cycleErr.Tag())
+				assert.Equal(tc.expectedIDs, cycleErr.Fields())
+			}
+		})
+	}
+}
+
+func TestBootTimeBoundsValidator(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+	current := func() time.Time { return now }
+	tests := []struct {
+		description string
+		bootTime    string
+		minYear     int
+		maxAhead    time.Duration
+		expectedErr bool
+	}{
+		{
+			description: "Valid",
+			bootTime:    fmt.Sprint(now.Add(-1 * time.Hour).Unix()),
+		},
+		{
+			description: "Zero",
+			bootTime:    "0",
+			expectedErr: true,
+		},
+		{
+			description: "One",
+			bootTime:    "1",
+			expectedErr: true,
+		},
+		{
+			description: "Negative",
+			bootTime:    "-1000",
+			expectedErr: true,
+		},
+		{
+			description: "Far past",
+			bootTime:    fmt.Sprint(time.Date(2014, time.December, 31, 23, 59, 59, 0, time.UTC).Unix()),
+			expectedErr: true,
+		},
+		{
+			description: "Start of min year",
+			bootTime:    fmt.Sprint(time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		},
+		{
+			description: "Custom min year",
+			bootTime:    fmt.Sprint(time.Date(2018, time.June, 1, 0, 0, 0, 0, time.UTC).Unix()),
+			minYear:     2019,
+			expectedErr: true,
+		},
+		{
+			description: "Within max ahead",
+			bootTime:    fmt.Sprint(now.Add(23 * time.Hour).Unix()),
+		},
+		{
+			description: "Far future",
+			bootTime:    fmt.Sprint(now.Add(25 * time.Hour).Unix()),
+			expectedErr: true,
+		},
+		{
+			description: "Custom max ahead",
+			bootTime:    fmt.Sprint(now.Add(2 * time.Hour).Unix()),
+			maxAhead:    time.Hour,
+			expectedErr: true,
+		},
+		{
+			description: "No boot-time",
+		},
+		{
+			description: "Unparsable boot-time",
+			bootTime:    "not-a-number",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			event := interpreter.Event{Metadata: map[string]string{}}
+			if len(tc.bootTime) > 0 {
+				event.Metadata[interpreter.BootTimeKey] = tc.bootTime
+			}
+
+			valid, err := BootTimeBoundsValidator(tc.minYear, tc.maxAhead, current).Valid(event)
+			assert.Equal(!tc.expectedErr, valid)
+			if tc.expectedErr {
+				assert.True(errors.Is(err, ErrImplausibleBootTime))
+				var taggedErr validation.TaggedError
+				assert.True(errors.As(err, &taggedErr))
+				assert.Equal(validation.InvalidBootTime, taggedErr.Tag())
+			} else {
+				assert.Nil(err)
+			}
+		})
+	}
+}
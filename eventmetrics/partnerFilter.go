@@ -0,0 +1,78 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"github.com/xmidt-org/webpa-common/v2/basculechecks"
+)
+
+// PartnerFilterConfig configures accepting or rejecting incoming events by their partner ids, so that a glaukos
+// can be scoped to specific partners.
+type PartnerFilterConfig struct {
+	// Enabled turns on filtering events by partner.
+	Enabled bool
+
+	// Allow lists the partner ids whose events are accepted.  If it isn't empty, events must have at least one
+	// of these partner ids to be accepted.
+	Allow []string
+
+	// Deny lists the partner ids whose events are filtered out.  Events with any of these partner ids are
+	// filtered out, even if they have an allowed partner id.
+	Deny []string
+}
+
+// PartnerFilter filters incoming events by their partner ids.
+type PartnerFilter struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// NewPartnerFilter creates a new PartnerFilter.
+func NewPartnerFilter(config PartnerFilterConfig) *PartnerFilter {
+	return &PartnerFilter{
+		allow: partnerSet(config.Allow),
+		deny:  partnerSet(config.Deny),
+	}
+}
+
+// Allow determines the partner of an event with the partner ids given, returning the partner and whether
+// the event is accepted.
+func (p *PartnerFilter) Allow(partnerIDs []string) (string, bool) {
+	partner := basculechecks.DeterminePartnerMetric(partnerIDs)
+	allowed := len(p.allow) == 0
+	for _, id := range partnerIDs {
+		if p.deny[id] {
+			return partner, false
+		}
+
+		if p.allow[id] {
+			allowed = true
+		}
+	}
+
+	return partner, allowed
+}
+
+func partnerSet(partnerIDs []string) map[string]bool {
+	set := make(map[string]bool, len(partnerIDs))
+	for _, id := range partnerIDs {
+		set[id] = true
+	}
+
+	return set
+}
@@ -0,0 +1,76 @@
+package eventmetrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartnerFilter(t *testing.T) {
+	tests := []struct {
+		description     string
+		config          PartnerFilterConfig
+		partnerIDs      []string
+		expectedPartner string
+		expectedAllowed bool
+	}{
+		{
+			description:     "No lists",
+			partnerIDs:      []string{"partner"},
+			expectedPartner: "partner",
+			expectedAllowed: true,
+		},
+		{
+			description:     "Allowed",
+			config:          PartnerFilterConfig{Allow: []string{"partner"}},
+			partnerIDs:      []string{"partner"},
+			expectedPartner: "partner",
+			expectedAllowed: true,
+		},
+		{
+			description:     "Not allowed",
+			config:          PartnerFilterConfig{Allow: []string{"partner"}},
+			partnerIDs:      []string{"other"},
+			expectedPartner: "other",
+		},
+		{
+			description:     "One of many allowed",
+			config:          PartnerFilterConfig{Allow: []string{"partner"}},
+			partnerIDs:      []string{"other", "partner"},
+			expectedPartner: "many",
+			expectedAllowed: true,
+		},
+		{
+			description:     "No partner with allow list",
+			config:          PartnerFilterConfig{Allow: []string{"partner"}},
+			expectedPartner: "none",
+		},
+		{
+			description:     "No partner with deny list",
+			config:          PartnerFilterConfig{Deny: []string{"partner"}},
+			expectedPartner: "none",
+			expectedAllowed: true,
+		},
+		{
+			description:     "Denied",
+			config:          PartnerFilterConfig{Deny: []string{"partner"}},
+			partnerIDs:      []string{"partner"},
+			expectedPartner: "partner",
+		},
+		{
+			description:     "Denied overrides allowed",
+			config:          PartnerFilterConfig{Allow: []string{"partner"}, Deny: []string{"other"}},
+			partnerIDs:      []string{"partner", "other"},
+			expectedPartner: "many",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			partner, allowed := NewPartnerFilter(tc.config).Allow(tc.partnerIDs)
+			assert.Equal(tc.expectedPartner, partner)
+			assert.Equal(tc.expectedAllowed, allowed)
+		})
+	}
+}
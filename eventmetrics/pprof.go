@@ -0,0 +1,130 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPprofReadHeaderTimeout = 10 * time.Second
+)
+
+// PprofConfig configures the diagnostics server, which exposes net/http/pprof, expvar, and dumps of the
+// goroutines and the queue on their own listener so that they aren't reachable from the primary server.  The
+// server is only started if an address is configured.
+type PprofConfig struct {
+	// Address is the address the diagnostics server listens on, such as localhost:4203.
+	Address string
+
+	// ReadHeaderTimeout is the time allowed to read the headers of a request.  Defaults to 10s.  Profiles
+	// are written for as long as they are requested to run, so there is no write timeout.
+	ReadHeaderTimeout time.Duration
+}
+
+// PprofServerIn provides everything needed to start the diagnostics server.
+type PprofServerIn struct {
+	fx.In
+	Config    PprofConfig
+	Queue     queue.Queue
+	Logger    *zap.Logger
+	Lifecycle fx.Lifecycle
+}
+
+type queueDump struct {
+	Goroutines int          `json:"goroutines"`
+	Queue      *queue.Stats `json:"queue,omitempty"`
+}
+
+// NewPprofHandler creates the handler of the diagnostics endpoints:
+//
+//	/debug/pprof/      the net/http/pprof profiles
+//	/debug/vars        the expvar variables
+//	/debug/goroutines  the stack traces of every goroutine
+//	/debug/queue       the number of goroutines and the state of the queue, if it can report it
+func NewPprofHandler(q queue.Queue) http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+	r.Handle("/debug/vars", expvar.Handler())
+	r.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rpprof.Lookup("goroutine").WriteTo(w, 2) // nolint:errcheck
+	})
+	r.HandleFunc("/debug/queue", func(w http.ResponseWriter, _ *http.Request) {
+		dump := queueDump{Goroutines: runtime.NumGoroutine()}
+		if reporter, ok := q.(queue.StatsReporter); ok {
+			stats := reporter.Stats()
+			dump.Queue = &stats
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dump) // nolint:errcheck
+	})
+
+	return r
+}
+
+// StartPprofServer starts the diagnostics server with the application, if an address is configured.
+func StartPprofServer(in PprofServerIn) {
+	if len(in.Config.Address) == 0 {
+		return
+	}
+
+	if in.Config.ReadHeaderTimeout <= 0 {
+		in.Config.ReadHeaderTimeout = defaultPprofReadHeaderTimeout
+	}
+
+	server := &http.Server{
+		Addr:              in.Config.Address,
+		Handler:           NewPprofHandler(in.Queue),
+		ReadHeaderTimeout: in.Config.ReadHeaderTimeout,
+	}
+
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			listener, err := net.Listen("tcp", in.Config.Address)
+			if err != nil {
+				return err
+			}
+
+			in.Logger.Info("starting diagnostics server", zap.String("address", listener.Addr().String()))
+			go server.Serve(listener) // nolint:errcheck
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
+		},
+	})
+}
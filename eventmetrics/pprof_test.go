@@ -0,0 +1,107 @@
+package eventmetrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+)
+
+type mockStatsQueue struct {
+	mockQueue
+	stats queue.Stats
+}
+
+func (m *mockStatsQueue) Stats() queue.Stats {
+	return m.stats
+}
+
+func TestPprofHandler(t *testing.T) {
+	tests := []struct {
+		description     string
+		path            string
+		expectedStatus  int
+		expectedContent string
+	}{
+		{
+			description:     "Pprof index",
+			path:            "/debug/pprof/",
+			expectedStatus:  http.StatusOK,
+			expectedContent: "goroutine",
+		},
+		{
+			description:     "Named profile",
+			path:            "/debug/pprof/heap",
+			expectedStatus:  http.StatusOK,
+			expectedContent: "",
+		},
+		{
+			description:     "Expvar",
+			path:            "/debug/vars",
+			expectedStatus:  http.StatusOK,
+			expectedContent: "memstats",
+		},
+		{
+			description:     "Goroutines",
+			path:            "/debug/goroutines",
+			expectedStatus:  http.StatusOK,
+			expectedContent: "goroutine",
+		},
+		{
+			description:    "Unknown path",
+			path:           "/debug/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	handler := NewPprofHandler(new(mockQueue))
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(tc.expectedStatus, recorder.Code)
+			assert.Contains(recorder.Body.String(), tc.expectedContent)
+		})
+	}
+}
+
+func TestPprofQueueDump(t *testing.T) {
+	tests := []struct {
+		description   string
+		queue         queue.Queue
+		expectedStats *queue.Stats
+	}{
+		{
+			description:   "Queue with stats",
+			queue:         &mockStatsQueue{stats: queue.Stats{Type: "memory", Queued: 3, Size: 10, MaxWorkers: 5, Parsing: 2}},
+			expectedStats: &queue.Stats{Type: "memory", Queued: 3, Size: 10, MaxWorkers: 5, Parsing: 2},
+		},
+		{
+			description: "Queue without stats",
+			queue:       new(mockQueue),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			recorder := httptest.NewRecorder()
+			NewPprofHandler(tc.queue).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/queue", nil))
+			if !assert.Equal(http.StatusOK, recorder.Code) {
+				return
+			}
+
+			var dump queueDump
+			if !assert.Nil(json.NewDecoder(recorder.Body).Decode(&dump)) {
+				return
+			}
+
+			assert.Positive(dump.Goroutines)
+			assert.Equal(tc.expectedStats, dump.Queue)
+		})
+	}
+}
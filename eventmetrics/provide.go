@@ -21,9 +21,12 @@ import (
 	"context"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xmidt-org/arrange"
 	"github.com/xmidt-org/interpreter/validation"
+	"github.com/xmidt-org/touchstone"
 
+	"github.com/xmidt-org/glaukos/compression"
 	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
 	"github.com/xmidt-org/glaukos/eventmetrics/queue"
 	"go.uber.org/fx"
@@ -34,6 +37,50 @@ import (
 type Config struct {
 	BirthdateValidFrom time.Duration
 	BirthdateValidTo   time.Duration
+
+	// RejectBirthdateBeforeBootTime enables rejecting incoming events with a birthdate more than
+	// BirthdateBootTimeTolerance before their boot-time.
+	RejectBirthdateBeforeBootTime bool
+	BirthdateBootTimeTolerance    time.Duration
+
+	// BootTimeBounds configures rejecting incoming events with an implausible boot-time.
+	BootTimeBounds parsers.BootTimeBoundsConfig
+
+	// RejectMalformedDeviceIDs enables rejecting incoming events whose device id is malformed.
+	RejectMalformedDeviceIDs bool
+
+	// ReportConversionIssues enables counting the issues found while converting incoming wrp messages
+	// into events, such as a missing metadata map or an empty destination.
+	ReportConversionIssues bool
+
+	// Dedup configures dropping incoming events that were already received.
+	Dedup DedupConfig
+
+	// Shard configures splitting incoming events between multiple glaukos instances by device id.
+	Shard ShardConfig
+
+	// PartnerRateLimit configures limiting the rate incoming events are accepted at for each partner.
+	PartnerRateLimit PartnerRateLimitConfig
+
+	// PartnerFilter configures accepting or rejecting incoming events by partner.
+	PartnerFilter PartnerFilterConfig
+
+	// BootTimeParsing determines how incoming boot-times are parsed, either "strict" or "lenient".  Strict parsing
+	// only accepts integers, while lenient parsing also accepts boot-times formatted as floats, truncating them.
+	// Defaults to "strict".
+	BootTimeParsing string
+
+	// SchemaValidation configures validating the payloads of incoming events against JSON schemas.
+	SchemaValidation SchemaValidationConfig
+
+	// CloudEvents configures the endpoint that accepts CloudEvents in addition to wrp messages.
+	CloudEvents CloudEventsConfig
+
+	// Heartbeat configures periodically sending a synthetic event through the pipeline to check it end to end.
+	Heartbeat HeartbeatConfig
+
+	// Compression configures accepting compressed incoming event bodies.
+	Compression CompressionConfig
 }
 
 // Provide bundles everything needed for setting up the subscribe endpoint
@@ -45,6 +92,8 @@ func Provide() fx.Option {
 		queue.ProvideMetrics(),
 		fx.Provide(
 			arrange.UnmarshalKey("eventMetrics", Config{}),
+			arrange.UnmarshalKey("ready", ReadyConfig{}),
+			arrange.UnmarshalKey("admin", AdminConfig{}),
 			func(f func(context.Context) *zap.Logger) GetLoggerFunc {
 				return f
 			},
@@ -56,8 +105,240 @@ func Provide() fx.Option {
 					Current:   time.Now,
 				}
 			},
+			fx.Annotated{
+				Name:   "incoming_event_validator",
+				Target: createIncomingEventValidator,
+			},
+			fx.Annotated{
+				Name: "wrp_conversion_issues_total",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.ReportConversionIssues {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "wrp_conversion_issues_total",
+							Help: "issues found while converting incoming wrp messages into events, labeled by issue type",
+						},
+						issueTypeLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "lenient_boot_time_count",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Counter, error) {
+					lenient, err := lenientBootTimeParsingEnabled(config.BootTimeParsing)
+					if err != nil || !lenient {
+						return nil, err
+					}
+
+					return f.NewCounter(prometheus.CounterOpts{
+						Name: "lenient_boot_time_count",
+						Help: "incoming events whose boot-time was formatted as a float and truncated to an integer",
+					})
+				},
+			},
+			fx.Annotated{
+				Name: "schema_violations_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.SchemaValidation.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "schema_violations_count",
+							Help: "incoming events whose payloads don't match the schema of their event type, labeled by event type",
+						},
+						eventTypeLabel,
+					)
+				},
+			},
+			func(config Config, in SchemaViolationsIn, logger *zap.Logger) (*SchemaValidator, error) {
+				if !config.SchemaValidation.Enabled {
+					return nil, nil
+				}
+
+				return NewSchemaValidator(config.SchemaValidation, in.Violations, logger)
+			},
+			fx.Annotated{
+				Name: "dedup_store_errors_count",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Counter, error) {
+					if !config.Dedup.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounter(prometheus.CounterOpts{
+						Name: "dedup_store_errors_count",
+						Help: "incoming events that couldn't be checked for duplicates because the dedup store failed",
+					})
+				},
+			},
+			func(config Config, in DedupStoreErrorsIn, logger *zap.Logger) (*Deduplicator, error) {
+				if !config.Dedup.Enabled {
+					return nil, nil
+				}
+
+				return NewDeduplicator(config.Dedup, in.StoreErrors, logger)
+			},
+			fx.Annotated{
+				Name: "sharded_events_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.Shard.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "sharded_events_count",
+							Help: "incoming events of devices owned by another glaukos, labeled by whether they were dropped or forwarded",
+						},
+						shardActionLabel,
+					)
+				},
+			},
+			func(config Config, in ShardedEventsIn, signer RequestSignerIn, lc fx.Lifecycle, logger *zap.Logger) (*Sharder, error) {
+				if !config.Shard.Enabled {
+					return nil, nil
+				}
+
+				sharder, err := NewSharder(config.Shard, signer.Signer, in.ShardedEvents, logger)
+				if err != nil {
+					return nil, err
+				}
+
+				lc.Append(fx.Hook{
+					OnStart: func(context.Context) error {
+						sharder.Start()
+						return nil
+					},
+					OnStop: sharder.Stop,
+				})
+				return sharder, nil
+			},
+			func(config Config) *PartnerRateLimiter {
+				if !config.PartnerRateLimit.Enabled {
+					return nil
+				}
+
+				return NewPartnerRateLimiter(config.PartnerRateLimit)
+			},
+			fx.Annotated{
+				Name: "rate_limited_events_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.PartnerRateLimit.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "rate_limited_events_count",
+							Help: "incoming events rejected for exceeding the rate limit of their partner",
+						},
+						partnerIDLabel,
+					)
+				},
+			},
+			func(config Config) *PartnerFilter {
+				if !config.PartnerFilter.Enabled {
+					return nil
+				}
+
+				return NewPartnerFilter(config.PartnerFilter)
+			},
+			fx.Annotated{
+				Name: "filtered_events_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.PartnerFilter.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "filtered_events_count",
+							Help: "incoming events filtered out by their partner",
+						},
+						partnerIDLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "heartbeat_latency_seconds",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Observer, error) {
+					if !config.Heartbeat.Enabled {
+						return nil, nil
+					}
+
+					return f.NewHistogram(prometheus.HistogramOpts{
+						Name:    "heartbeat_latency_seconds",
+						Help:    "time between sending a heartbeat and it being parsed",
+						Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+					})
+				},
+			},
+			fx.Annotated{
+				Name: "heartbeat_missed_count",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Counter, error) {
+					if !config.Heartbeat.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounter(prometheus.CounterOpts{
+						Name: "heartbeat_missed_count",
+						Help: "heartbeats that failed to be sent or weren't parsed within the threshold",
+					})
+				},
+			},
+			fx.Annotated{
+				Name: "heartbeat_healthy",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Gauge, error) {
+					if !config.Heartbeat.Enabled {
+						return nil, nil
+					}
+
+					return f.NewGauge(prometheus.GaugeOpts{
+						Name: "heartbeat_healthy",
+						Help: "whether the latest heartbeat was parsed within the threshold (1) or not (0)",
+					})
+				},
+			},
+			func(config Config, signer RequestSignerIn, measures HeartbeatMeasures, logger *zap.Logger) (*Heartbeat, error) {
+				return NewHeartbeat(config.Heartbeat, signer.Signer, measures, logger)
+			},
+			fx.Annotated{
+				Group: "parsers,flatten",
+				Target: func(h *Heartbeat) []queue.Parser {
+					if h == nil {
+						return []queue.Parser{}
+					}
+
+					return []queue.Parser{h.Parser()}
+				},
+			},
+			fx.Annotated{
+				Name: "incoming_body_bytes_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.Compression.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "incoming_body_bytes_count",
+							Help: "bytes of incoming event bodies read, labeled by content encoding and whether they were compressed or uncompressed",
+						},
+						compression.EncodingLabel, compression.CompressionLabel,
+					)
+				},
+			},
 			NewEndpoints,
 			NewHandlers,
 		),
+		fx.Invoke(startHeartbeat),
+		fx.Provide(arrange.UnmarshalKey("metricsSnapshot", SnapshotConfig{})),
+		fx.Invoke(startSnapshots),
+		fx.Provide(arrange.UnmarshalKey("metrics", MetricsConfig{})),
+		fx.Decorate(environmentRegisterer),
 	)
 }
@@ -0,0 +1,148 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	defaultAuditEventsPerDevice = 20
+	defaultAuditMaxDevices      = 10000
+
+	skippedOutcome = "skipped"
+)
+
+// AuditLogConfig configures keeping the last events of each device along with the outcome of parsing them with
+// each parser, to help debug why a device's events didn't produce metrics.
+type AuditLogConfig struct {
+	// Enabled turns on the audit log.
+	Enabled bool
+
+	// EventsPerDevice is the number of events kept for each device, after which the oldest events are
+	// forgotten.  Defaults to 20.
+	EventsPerDevice int
+
+	// MaxDevices is the maximum number of devices whose events are kept, after which the events of the device
+	// whose events were parsed least recently are forgotten.  Defaults to 10000.
+	MaxDevices int
+}
+
+// AuditedEvent is an event kept in the audit log, along with the outcome of parsing it with each parser: success,
+// timeout, cancelled, panic, skipped when the event isn't sampled for the parser, or unrouted when the event
+// doesn't match the parser's route.  Parsers disabled through
+// the kill switch are left out.
+type AuditedEvent struct {
+	Event    interpreter.Event `json:"event"`
+	ParsedAt time.Time         `json:"parsedAt"`
+	Outcomes map[string]string `json:"outcomes"`
+}
+
+// AuditLog keeps a ring buffer of the last parsed events of each device.
+type AuditLog struct {
+	eventsPerDevice int
+	maxDevices      int
+	current         func() time.Time
+
+	lock    sync.Mutex
+	devices map[string]*list.Element
+	order   *list.List
+}
+
+type auditedDevice struct {
+	id     string
+	events []AuditedEvent
+	next   int
+}
+
+// NewAuditLog creates a new AuditLog, returning nil if the audit log isn't enabled.
+func NewAuditLog(config AuditLogConfig) *AuditLog {
+	if !config.Enabled {
+		return nil
+	}
+
+	if config.EventsPerDevice <= 0 {
+		config.EventsPerDevice = defaultAuditEventsPerDevice
+	}
+
+	if config.MaxDevices <= 0 {
+		config.MaxDevices = defaultAuditMaxDevices
+	}
+
+	return &AuditLog{
+		eventsPerDevice: config.EventsPerDevice,
+		maxDevices:      config.MaxDevices,
+		current:         time.Now,
+		devices:         make(map[string]*list.Element),
+		order:           list.New(),
+	}
+}
+
+// Record keeps the parsed event and its outcomes, forgetting the device's oldest event if it already has the
+// maximum number of events kept.  Events without a device id aren't kept.
+func (a *AuditLog) Record(event interpreter.Event, outcomes map[string]string) {
+	id, err := deviceid.FromEvent(event)
+	if err != nil {
+		return
+	}
+
+	audited := AuditedEvent{Event: event, ParsedAt: a.current(), Outcomes: outcomes}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	element, found := a.devices[id]
+	if found {
+		a.order.MoveToFront(element)
+	} else {
+		if a.order.Len() >= a.maxDevices {
+			oldest := a.order.Back()
+			a.order.Remove(oldest)
+			delete(a.devices, oldest.Value.(*auditedDevice).id)
+		}
+		element = a.order.PushFront(&auditedDevice{id: id, events: make([]AuditedEvent, 0, a.eventsPerDevice)})
+		a.devices[id] = element
+	}
+
+	device := element.Value.(*auditedDevice)
+	if len(device.events) < a.eventsPerDevice {
+		device.events = append(device.events, audited)
+		return
+	}
+
+	device.events[device.next] = audited
+	device.next = (device.next + 1) % a.eventsPerDevice
+}
+
+// Events returns the events kept for the device, oldest first.
+func (a *AuditLog) Events(deviceID string) []AuditedEvent {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	element, found := a.devices[deviceid.Normalize(deviceID)]
+	if !found {
+		return []AuditedEvent{}
+	}
+
+	device := element.Value.(*auditedDevice)
+	events := make([]AuditedEvent, 0, len(device.events))
+	events = append(events, device.events[device.next:]...)
+	return append(events, device.events[:device.next]...)
+}
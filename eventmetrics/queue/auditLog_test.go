@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestNewAuditLog(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(NewAuditLog(AuditLogConfig{}))
+
+	auditLog := NewAuditLog(AuditLogConfig{Enabled: true})
+	if !assert.NotNil(auditLog) {
+		return
+	}
+	assert.Equal(defaultAuditEventsPerDevice, auditLog.eventsPerDevice)
+	assert.Equal(defaultAuditMaxDevices, auditLog.maxDevices)
+
+	auditLog = NewAuditLog(AuditLogConfig{Enabled: true, EventsPerDevice: 3, MaxDevices: 2})
+	assert.Equal(3, auditLog.eventsPerDevice)
+	assert.Equal(2, auditLog.maxDevices)
+}
+
+func TestAuditLog(t *testing.T) {
+	tests := []struct {
+		description     string
+		recorded        int
+		expectedEvents  []string
+		expectedDevices int
+	}{
+		{
+			description:    "not full",
+			recorded:       2,
+			expectedEvents: []string{"0", "1"},
+		},
+		{
+			description:    "full",
+			recorded:       3,
+			expectedEvents: []string{"0", "1", "2"},
+		},
+		{
+			description:    "wrapped",
+			recorded:       7,
+			expectedEvents: []string{"4", "5", "6"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			now := time.Now()
+			auditLog := NewAuditLog(AuditLogConfig{Enabled: true, EventsPerDevice: 3})
+			auditLog.current = func() time.Time { return now }
+			for i := 0; i < tc.recorded; i++ {
+				auditLog.Record(interpreter.Event{
+					Destination:     "event:device-status/mac:112233445566/online",
+					TransactionUUID: fmt.Sprint(i),
+				}, map[string]string{"parser": successOutcome})
+			}
+
+			events := auditLog.Events("mac:112233445566")
+			ids := make([]string, 0, len(events))
+			for _, event := range events {
+				ids = append(ids, event.Event.TransactionUUID)
+				assert.Equal(now, event.ParsedAt)
+				assert.Equal(map[string]string{"parser": successOutcome}, event.Outcomes)
+			}
+			assert.Equal(tc.expectedEvents, ids)
+			assert.Empty(auditLog.Events("mac:aabbccddeeff"))
+		})
+	}
+}
+
+func TestAuditLogMaxDevices(t *testing.T) {
+	assert := assert.New(t)
+	auditLog := NewAuditLog(AuditLogConfig{Enabled: true, MaxDevices: 2})
+	record := func(id string) {
+		auditLog.Record(interpreter.Event{Destination: fmt.Sprintf("event:device-status/mac:%s/online", id)}, nil)
+	}
+
+	record("112233445566")
+	record("aabbccddeeff")
+	// the first device is used most recently, so the second device is forgotten.
+	record("112233445566")
+	record("665544332211")
+	assert.Len(auditLog.Events("mac:112233445566"), 2)
+	assert.Empty(auditLog.Events("mac:aabbccddeeff"))
+	assert.Len(auditLog.Events("MAC:665544332211"), 1)
+
+	// events without a device id aren't kept.
+	auditLog.Record(interpreter.Event{Destination: "unknown"}, nil)
+	assert.Len(auditLog.devices, 2)
+}
@@ -0,0 +1,229 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/webpa-common/v2/semaphore"
+	"go.uber.org/zap"
+)
+
+const (
+	scaleUpDirection   = "up"
+	scaleDownDirection = "down"
+	directionLabel     = "direction"
+
+	defaultAutoscaleInterval          = 10 * time.Second
+	defaultAutoscaleStep              = 1
+	defaultAutoscaleScaleUpDepth      = 0.5
+	defaultAutoscaleScaleDownDepth    = 0.1
+	defaultAutoscaleTargetUtilization = 0.8
+)
+
+// AutoscaleConfig configures growing and shrinking the number of workers parsing events between MinWorkers
+// and MaxWorkers, based on the depth of the queue and how long parsing takes.
+type AutoscaleConfig struct {
+	// Enabled turns on autoscaling.  Workers start at MinWorkers.
+	Enabled bool
+
+	// MinWorkers is the fewest workers parsing events.  Defaults to 1.
+	MinWorkers int
+
+	// Interval is how often the number of workers is adjusted.  Defaults to 10s.
+	Interval time.Duration
+
+	// Step is the number of workers added or removed at a time.  Defaults to 1.
+	Step int
+
+	// ScaleUpDepth is the fraction of the queue size the queue depth must reach for workers to be added.
+	// Defaults to 0.5.
+	ScaleUpDepth float64
+
+	// ScaleDownDepth is the fraction of the queue size the queue depth must stay under for workers to be
+	// removed.  Defaults to 0.1.
+	ScaleDownDepth float64
+
+	// TargetUtilization is the fraction of the interval the workers should spend parsing.  Workers are added when
+	// they spend more time parsing, and removed when they spend less than half of it.  Defaults to 0.8.
+	TargetUtilization float64
+}
+
+// autoscaler adjusts the number of workers by holding on to the worker semaphore's resources that aren't in use.
+// Adding workers releases held resources and removing workers acquires them.
+type autoscaler struct {
+	workers           semaphore.Interface
+	max               int
+	min               int
+	step              int
+	interval          time.Duration
+	scaleUpDepth      float64
+	scaleDownDepth    float64
+	targetUtilization float64
+	depth             func() float64
+	workerCount       prometheus.Gauge
+	scalingEvents     *prometheus.CounterVec
+	logger            *zap.Logger
+
+	current int
+	busy    atomic.Int64
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newAutoscaler creates an autoscaler of the workers, which must have max resources.  The depth function returns
+// the depth of the queue as a fraction of its size.  It returns nil if autoscaling is disabled.
+func newAutoscaler(config AutoscaleConfig, workers semaphore.Interface, max int, depth func() float64, metrics Measures, logger *zap.Logger) *autoscaler {
+	if !config.Enabled {
+		return nil
+	}
+
+	if config.MinWorkers <= 0 {
+		config.MinWorkers = 1
+	}
+
+	if config.MinWorkers > max {
+		config.MinWorkers = max
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = defaultAutoscaleInterval
+	}
+
+	if config.Step <= 0 {
+		config.Step = defaultAutoscaleStep
+	}
+
+	if config.ScaleUpDepth <= 0 || config.ScaleUpDepth > 1 {
+		config.ScaleUpDepth = defaultAutoscaleScaleUpDepth
+	}
+
+	if config.ScaleDownDepth <= 0 || config.ScaleDownDepth >= config.ScaleUpDepth {
+		config.ScaleDownDepth = defaultAutoscaleScaleDownDepth
+		if config.ScaleDownDepth >= config.ScaleUpDepth {
+			config.ScaleDownDepth = config.ScaleUpDepth / 2
+		}
+	}
+
+	if config.TargetUtilization <= 0 || config.TargetUtilization > 1 {
+		config.TargetUtilization = defaultAutoscaleTargetUtilization
+	}
+
+	a := &autoscaler{
+		workers:           workers,
+		max:               max,
+		min:               config.MinWorkers,
+		step:              config.Step,
+		interval:          config.Interval,
+		scaleUpDepth:      config.ScaleUpDepth,
+		scaleDownDepth:    config.ScaleDownDepth,
+		targetUtilization: config.TargetUtilization,
+		depth:             depth,
+		workerCount:       metrics.WorkerCount,
+		scalingEvents:     metrics.WorkerScalingEventsCount,
+		logger:            logger,
+		current:           max,
+	}
+
+	// the workers start at the minimum, holding on to the rest of the resources.
+	for a.current > a.min && a.workers.TryAcquire() {
+		a.current--
+	}
+	a.report()
+	return a
+}
+
+// Observe records the time a worker spent parsing.
+func (a *autoscaler) Observe(d time.Duration) {
+	a.busy.Add(int64(d))
+}
+
+// scale adds workers if the queue is backing up or the workers are busier than the target, and removes workers
+// if the queue is nearly empty and the workers are idle for most of the interval.
+func (a *autoscaler) scale() {
+	utilization := float64(a.busy.Swap(0)) / (float64(a.interval) * float64(a.current))
+	depth := a.depth()
+	switch {
+	case a.current < a.max && (depth >= a.scaleUpDepth || utilization > a.targetUtilization):
+		for i := 0; i < a.step && a.current < a.max; i++ {
+			a.workers.Release()
+			a.current++
+		}
+		a.scaled(scaleUpDirection, depth, utilization)
+	case a.current > a.min && depth < a.scaleDownDepth && utilization < a.targetUtilization/2:
+		removed := 0
+		// busy workers aren't waited on, since they will likely be done by the next interval.
+		for ; removed < a.step && a.current > a.min && a.workers.TryAcquire(); removed++ {
+			a.current--
+		}
+		if removed > 0 {
+			a.scaled(scaleDownDirection, depth, utilization)
+		}
+	}
+}
+
+func (a *autoscaler) scaled(direction string, depth float64, utilization float64) {
+	a.logger.Debug("scaled workers", zap.String("direction", direction), zap.Int("workers", a.current),
+		zap.Float64("queue depth", depth), zap.Float64("utilization", utilization))
+	if a.scalingEvents != nil {
+		a.scalingEvents.With(prometheus.Labels{directionLabel: direction}).Add(1.0)
+	}
+	a.report()
+}
+
+func (a *autoscaler) report() {
+	if a.workerCount != nil {
+		a.workerCount.Set(float64(a.current))
+	}
+}
+
+func (a *autoscaler) Start() {
+	a.done = make(chan struct{})
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.scale()
+			case <-a.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops adjusting the workers and releases the held resources, so that all workers are available to drain
+// the queue.
+func (a *autoscaler) Stop() {
+	if a.done == nil {
+		return
+	}
+
+	close(a.done)
+	a.wg.Wait()
+	for ; a.current < a.max; a.current++ {
+		a.workers.Release()
+	}
+	a.report()
+}
@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/webpa-common/v2/semaphore"
+	"go.uber.org/zap"
+)
+
+func newTestAutoscaleMeasures() Measures {
+	return Measures{
+		WorkerCount:              prometheus.NewGauge(prometheus.GaugeOpts{Name: "testWorkers"}),
+		WorkerScalingEventsCount: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testScalingEvents"}, []string{directionLabel}),
+	}
+}
+
+func TestNewAutoscaler(t *testing.T) {
+	tests := []struct {
+		description     string
+		config          AutoscaleConfig
+		expectedNil     bool
+		expectedMin     int
+		expectedUp      float64
+		expectedDown    float64
+		expectedWorkers int
+	}{
+		{
+			description: "Disabled",
+			expectedNil: true,
+		},
+		{
+			description:     "Defaults",
+			config:          AutoscaleConfig{Enabled: true},
+			expectedMin:     1,
+			expectedUp:      defaultAutoscaleScaleUpDepth,
+			expectedDown:    defaultAutoscaleScaleDownDepth,
+			expectedWorkers: 1,
+		},
+		{
+			description:     "Configured",
+			config:          AutoscaleConfig{Enabled: true, MinWorkers: 3, ScaleUpDepth: 0.8, ScaleDownDepth: 0.2},
+			expectedMin:     3,
+			expectedUp:      0.8,
+			expectedDown:    0.2,
+			expectedWorkers: 3,
+		},
+		{
+			description:     "Invalid values",
+			config:          AutoscaleConfig{Enabled: true, MinWorkers: 20, ScaleUpDepth: 0.05, ScaleDownDepth: 0.5},
+			expectedMin:     5,
+			expectedUp:      0.05,
+			expectedDown:    0.025,
+			expectedWorkers: 5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			metrics := newTestAutoscaleMeasures()
+			workers := semaphore.New(5)
+			a := newAutoscaler(tc.config, workers, 5, func() float64 { return 0 }, metrics, zap.NewNop())
+			if tc.expectedNil {
+				assert.Nil(a)
+				return
+			}
+
+			if !assert.NotNil(a) {
+				return
+			}
+			assert.Equal(tc.expectedMin, a.min)
+			assert.Equal(tc.expectedUp, a.scaleUpDepth)
+			assert.Equal(tc.expectedDown, a.scaleDownDepth)
+			assert.Equal(tc.expectedWorkers, a.current)
+			assert.Equal(float64(tc.expectedWorkers), testutil.ToFloat64(metrics.WorkerCount))
+
+			// only the current number of workers can be acquired.
+			for i := 0; i < tc.expectedWorkers; i++ {
+				assert.True(workers.TryAcquire())
+			}
+			assert.False(workers.TryAcquire())
+		})
+	}
+}
+
+func TestAutoscalerScale(t *testing.T) {
+	assert := assert.New(t)
+	metrics := newTestAutoscaleMeasures()
+	workers := semaphore.New(6)
+	var depth float64
+	a := newAutoscaler(AutoscaleConfig{Enabled: true, MinWorkers: 2, Step: 2, Interval: time.Second}, workers, 6, func() float64 { return depth }, metrics, zap.NewNop())
+	if !assert.NotNil(a) {
+		return
+	}
+
+	steps := []struct {
+		description     string
+		depth           float64
+		busy            time.Duration
+		acquired        int
+		expectedWorkers int
+		expectedUp      float64
+		expectedDown    float64
+	}{
+		{description: "Steady", depth: 0.2, busy: time.Second, expectedWorkers: 2},
+		{description: "Queue backing up", depth: 0.6, expectedWorkers: 4, expectedUp: 1},
+		{description: "Workers busy", depth: 0.2, busy: 4 * time.Second, expectedWorkers: 6, expectedUp: 2},
+		{description: "At maximum", depth: 1, busy: 6 * time.Second, expectedWorkers: 6, expectedUp: 2},
+		{description: "Idle", depth: 0, expectedWorkers: 4, expectedUp: 2, expectedDown: 1},
+		{description: "Workers still busy", depth: 0, acquired: 4, expectedWorkers: 4, expectedUp: 2, expectedDown: 1},
+		{description: "Idle again", depth: 0, expectedWorkers: 2, expectedUp: 2, expectedDown: 2},
+		{description: "At minimum", depth: 0, expectedWorkers: 2, expectedUp: 2, expectedDown: 2},
+	}
+
+	for _, step := range steps {
+		depth = step.depth
+		a.Observe(step.busy)
+		for i := 0; i < step.acquired; i++ {
+			workers.Acquire()
+		}
+
+		a.scale()
+		for i := 0; i < step.acquired; i++ {
+			workers.Release()
+		}
+
+		assert.Equal(step.expectedWorkers, a.current, step.description)
+		assert.Equal(float64(step.expectedWorkers), testutil.ToFloat64(metrics.WorkerCount), step.description)
+		assert.Equal(step.expectedUp, testutil.ToFloat64(metrics.WorkerScalingEventsCount.With(prometheus.Labels{directionLabel: scaleUpDirection})), step.description)
+		assert.Equal(step.expectedDown, testutil.ToFloat64(metrics.WorkerScalingEventsCount.With(prometheus.Labels{directionLabel: scaleDownDirection})), step.description)
+	}
+
+	a.Start()
+	a.Stop()
+	assert.Equal(6, a.current)
+	for i := 0; i < 6; i++ {
+		assert.True(workers.TryAcquire())
+	}
+}
@@ -0,0 +1,109 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultHighWaterMark = 0.8
+	defaultLowWaterMark  = 0.5
+	defaultRetryAfter    = 5 * time.Second
+)
+
+// BackpressureConfig configures rejecting events with a 429 and a Retry-After header once the queue fills past a
+// high-water mark, until it drains below a low-water mark, so that senders slow down before the queue is full.
+type BackpressureConfig struct {
+	// Enabled turns on backpressure.
+	Enabled bool
+
+	// HighWaterMark is the fraction of the queue size at which events start being rejected.  Defaults to 0.8.
+	HighWaterMark float64
+
+	// LowWaterMark is the fraction of the queue size the queue must drain below before events are accepted
+	// again.  Defaults to 0.5.
+	LowWaterMark float64
+
+	// RetryAfter is how long senders are told to wait before sending again, which is also sent when the queue
+	// is full.  Defaults to 5s.
+	RetryAfter time.Duration
+}
+
+// backpressure tracks whether the queue is throttling senders, starting once the queue depth reaches the
+// high-water mark and stopping once it falls below the low-water mark.
+type backpressure struct {
+	high       float64
+	low        float64
+	retryAfter time.Duration
+	throttled  prometheus.Counter
+
+	lock       sync.Mutex
+	throttling bool
+}
+
+// newBackpressure creates the backpressure of the queue, returning nil if it is disabled.
+func newBackpressure(config BackpressureConfig, throttled prometheus.Counter) *backpressure {
+	if !config.Enabled {
+		return nil
+	}
+
+	if config.HighWaterMark <= 0 || config.HighWaterMark > 1 {
+		config.HighWaterMark = defaultHighWaterMark
+	}
+
+	if config.LowWaterMark <= 0 || config.LowWaterMark >= config.HighWaterMark {
+		config.LowWaterMark = defaultLowWaterMark
+		if config.LowWaterMark >= config.HighWaterMark {
+			config.LowWaterMark = config.HighWaterMark / 2
+		}
+	}
+
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = defaultRetryAfter
+	}
+
+	return &backpressure{
+		high:       config.HighWaterMark,
+		low:        config.LowWaterMark,
+		retryAfter: config.RetryAfter,
+		throttled:  throttled,
+	}
+}
+
+// Throttle returns true if an event should be rejected at the given queue depth, as a fraction of the queue
+// size, counting the rejected events.
+func (b *backpressure) Throttle(depth float64) bool {
+	b.lock.Lock()
+	if b.throttling && depth < b.low {
+		b.throttling = false
+	} else if !b.throttling && depth >= b.high {
+		b.throttling = true
+	}
+	throttling := b.throttling
+	b.lock.Unlock()
+
+	if throttling && b.throttled != nil {
+		b.throttled.Inc()
+	}
+
+	return throttling
+}
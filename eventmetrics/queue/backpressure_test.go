@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackpressure(t *testing.T) {
+	tests := []struct {
+		description        string
+		config             BackpressureConfig
+		expectedNil        bool
+		expectedHigh       float64
+		expectedLow        float64
+		expectedRetryAfter time.Duration
+	}{
+		{
+			description: "Disabled",
+			expectedNil: true,
+		},
+		{
+			description:        "Defaults",
+			config:             BackpressureConfig{Enabled: true},
+			expectedHigh:       defaultHighWaterMark,
+			expectedLow:        defaultLowWaterMark,
+			expectedRetryAfter: defaultRetryAfter,
+		},
+		{
+			description:        "Configured",
+			config:             BackpressureConfig{Enabled: true, HighWaterMark: 0.9, LowWaterMark: 0.3, RetryAfter: time.Minute},
+			expectedHigh:       0.9,
+			expectedLow:        0.3,
+			expectedRetryAfter: time.Minute,
+		},
+		{
+			description:        "Low-water mark above high-water mark",
+			config:             BackpressureConfig{Enabled: true, HighWaterMark: 0.4, LowWaterMark: 0.6},
+			expectedHigh:       0.4,
+			expectedLow:        0.2,
+			expectedRetryAfter: defaultRetryAfter,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			b := newBackpressure(tc.config, nil)
+			if tc.expectedNil {
+				assert.Nil(b)
+				return
+			}
+
+			if !assert.NotNil(b) {
+				return
+			}
+			assert.Equal(tc.expectedHigh, b.high)
+			assert.Equal(tc.expectedLow, b.low)
+			assert.Equal(tc.expectedRetryAfter, b.retryAfter)
+		})
+	}
+}
+
+func TestBackpressureThrottle(t *testing.T) {
+	assert := assert.New(t)
+	throttled := prometheus.NewCounter(prometheus.CounterOpts{Name: "testThrottled"})
+	b := newBackpressure(BackpressureConfig{Enabled: true, HighWaterMark: 0.8, LowWaterMark: 0.5}, throttled)
+
+	steps := []struct {
+		depth    float64
+		expected bool
+	}{
+		{depth: 0.1, expected: false},
+		{depth: 0.79, expected: false},
+		{depth: 0.8, expected: true},
+		{depth: 0.6, expected: true},
+		{depth: 0.5, expected: true},
+		{depth: 0.49, expected: false},
+		{depth: 0.7, expected: false},
+		{depth: 1, expected: true},
+	}
+
+	for _, step := range steps {
+		assert.Equal(step.expected, b.Throttle(step.depth), step.depth)
+	}
+	assert.Equal(4.0, testutil.ToFloat64(throttled))
+}
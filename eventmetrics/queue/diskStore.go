@@ -0,0 +1,241 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/interpreter"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	memoryQueueType = "memory"
+	diskQueueType   = "disk"
+
+	defaultDiskPath = "glaukos-queue.db"
+)
+
+var (
+	errInvalidQueueType = errors.New("invalid queue type")
+	errStoreFull        = errors.New("store full")
+
+	eventsBucket = []byte("events")
+)
+
+// DiskConfig configures the disk-backed queue, which stores queued events in a file so that they survive
+// restarts.
+type DiskConfig struct {
+	// Path is the file the queued events are stored in.  Defaults to glaukos-queue.db.
+	Path string
+
+	// OpenTimeout is how long to wait for the lock on the file, such as when another instance is using it.
+	// Defaults to 0, which waits indefinitely.
+	OpenTimeout time.Duration
+
+	// BatchDelay is how long an event waits for other events to be written to the file with it, so that
+	// concurrent events share a single sync of the file.  Longer delays sync less often, but add to the time
+	// the sender waits for the event to be accepted.  Defaults to 10ms.
+	BatchDelay time.Duration
+
+	// MaxBatchSize is the most events written to the file together.  Defaults to 1000.
+	MaxBatchSize int
+
+	// NoSync skips syncing the file after events are written, so that writes are only limited by the page
+	// cache.  Events that were accepted but not yet synced are lost if the host, rather than just glaukos,
+	// crashes.
+	NoSync bool
+}
+
+type storedEvent struct {
+	Event     interpreter.Event `json:"event"`
+	BeginTime time.Time         `json:"begin_time"`
+	Queued    time.Time         `json:"queued"`
+}
+
+// diskStore stores queued events in a bolt database, keyed by the order they were added.  Events are removed
+// once they are parsed, so events that weren't parsed before a restart are parsed after it.
+type diskStore struct {
+	db     *bolt.DB
+	notify chan struct{}
+
+	lock  sync.Mutex
+	count int
+}
+
+func openDiskStore(config DiskConfig) (*diskStore, error) {
+	if len(config.Path) == 0 {
+		config.Path = defaultDiskPath
+	}
+
+	db, err := bolt.Open(config.Path, 0600, &bolt.Options{Timeout: config.OpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue file %s: %w", config.Path, err)
+	}
+
+	db.NoSync = config.NoSync
+	if config.BatchDelay > 0 {
+		db.MaxBatchDelay = config.BatchDelay
+	}
+	if config.MaxBatchSize > 0 {
+		db.MaxBatchSize = config.MaxBatchSize
+	}
+
+	var count int
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(eventsBucket)
+		if err != nil {
+			return err
+		}
+		count = b.Stats().KeyN
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create queue bucket: %w", err)
+	}
+
+	return &diskStore{
+		db:     db,
+		notify: make(chan struct{}, 1),
+		count:  count,
+	}, nil
+}
+
+// add stores the event, returning errStoreFull if max events are already stored.  Events added concurrently
+// are written to the file in a single transaction.
+func (s *diskStore) add(e EventWithTime, max int) error {
+	value, err := json.Marshal(storedEvent{Event: e.Event, BeginTime: e.BeginTime, Queued: e.queued})
+	if err != nil {
+		return err
+	}
+
+	// the event's place is reserved so that the lock isn't held while the event is written.
+	s.lock.Lock()
+	if s.count >= max {
+		s.lock.Unlock()
+		return errStoreFull
+	}
+	s.count++
+	s.lock.Unlock()
+
+	err = s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventsBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put(idKey(id), value)
+	})
+
+	if err != nil {
+		s.lock.Lock()
+		s.count--
+		s.lock.Unlock()
+		return err
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// after returns up to max of the oldest events stored after the id given.  Stored events that can't be
+// decoded are deleted, since they would otherwise block the events after them, and the number deleted is
+// returned.
+func (s *diskStore) after(id uint64, max int) ([]EventWithTime, int, error) {
+	var (
+		events  = make([]EventWithTime, 0, max)
+		corrupt []EventWithTime
+	)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.Seek(idKey(id + 1)); k != nil && len(events) < max; k, v = c.Next() {
+			var stored storedEvent
+			if err := json.Unmarshal(v, &stored); err != nil {
+				corrupt = append(corrupt, EventWithTime{id: binary.BigEndian.Uint64(k)})
+				continue
+			}
+			events = append(events, EventWithTime{Event: stored.Event, BeginTime: stored.BeginTime, queued: stored.Queued, id: binary.BigEndian.Uint64(k)})
+		}
+		return nil
+	})
+
+	if err != nil || len(corrupt) == 0 {
+		return events, 0, err
+	}
+
+	if err := s.remove(corrupt); err != nil {
+		return events, 0, fmt.Errorf("failed to delete undecodable events: %w", err)
+	}
+
+	return events, len(corrupt), nil
+}
+
+// remove deletes the stored events, such as once they are parsed.
+func (s *diskStore) remove(events []EventWithTime) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var removed int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventsBucket)
+		for _, e := range events {
+			if e.id == 0 || b.Get(idKey(e.id)) == nil {
+				continue
+			}
+			if err := b.Delete(idKey(e.id)); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+
+	if err == nil {
+		s.count -= removed
+	}
+
+	return err
+}
+
+// len returns the number of events stored.
+func (s *diskStore) len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.count
+}
+
+func (s *diskStore) close() error {
+	return s.db.Close()
+}
+
+func idKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
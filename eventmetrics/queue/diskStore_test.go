@@ -0,0 +1,179 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+func TestDiskStore(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "queue.db")
+	now := time.Now().Round(0)
+
+	store, err := openDiskStore(DiskConfig{Path: path})
+	assert.Nil(err)
+	for _, id := range []string{"1", "2", "3"} {
+		assert.Nil(store.add(EventWithTime{Event: interpreter.Event{TransactionUUID: id}, BeginTime: now, queued: now}, 3))
+	}
+	assert.True(errors.Is(store.add(EventWithTime{Event: interpreter.Event{TransactionUUID: "4"}}, 3), errStoreFull))
+	assert.Equal(3, store.len())
+
+	events, _, err := store.after(0, 2)
+	assert.Nil(err)
+	if !assert.Len(events, 2) {
+		return
+	}
+	assert.Equal("1", events[0].Event.TransactionUUID)
+	assert.Equal("2", events[1].Event.TransactionUUID)
+	assert.True(now.Equal(events[0].BeginTime))
+	assert.True(now.Equal(events[0].queued))
+
+	events, _, err = store.after(events[1].id, 2)
+	assert.Nil(err)
+	if !assert.Len(events, 1) {
+		return
+	}
+	assert.Equal("3", events[0].Event.TransactionUUID)
+
+	first, _, err := store.after(0, 1)
+	assert.Nil(err)
+	assert.Nil(store.remove(first))
+	// removing an event again does nothing.
+	assert.Nil(store.remove(first))
+	assert.Equal(2, store.len())
+	assert.Nil(store.close())
+
+	// events that weren't removed survive reopening the store.
+	store, err = openDiskStore(DiskConfig{Path: path})
+	assert.Nil(err)
+	defer store.close()
+	assert.Equal(2, store.len())
+	events, _, err = store.after(0, 10)
+	assert.Nil(err)
+	if !assert.Len(events, 2) {
+		return
+	}
+	assert.Equal("2", events[0].Event.TransactionUUID)
+	assert.Equal("3", events[1].Event.TransactionUUID)
+}
+
+func TestDiskStoreCorruptEvents(t *testing.T) {
+	assert := assert.New(t)
+	store, err := openDiskStore(DiskConfig{Path: filepath.Join(t.TempDir(), "queue.db"), NoSync: true, BatchDelay: time.Millisecond})
+	if !assert.Nil(err) {
+		return
+	}
+	defer store.close()
+
+	assert.Nil(store.add(EventWithTime{Event: interpreter.Event{TransactionUUID: "1"}}, 10))
+	assert.Nil(store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventsBucket)
+		id, _ := b.NextSequence()
+		return b.Put(idKey(id), []byte("not json"))
+	}))
+	store.count++
+	assert.Nil(store.add(EventWithTime{Event: interpreter.Event{TransactionUUID: "3"}}, 10))
+
+	// the undecodable event is deleted rather than blocking the events after it.
+	events, corrupt, err := store.after(0, 10)
+	assert.Nil(err)
+	assert.Equal(1, corrupt)
+	if assert.Len(events, 2) {
+		assert.Equal("1", events[0].Event.TransactionUUID)
+		assert.Equal("3", events[1].Event.TransactionUUID)
+	}
+	assert.Equal(2, store.len())
+
+	events, corrupt, err = store.after(0, 10)
+	assert.Nil(err)
+	assert.Zero(corrupt)
+	assert.Len(events, 2)
+}
+
+func TestOpenDiskStoreError(t *testing.T) {
+	_, err := openDiskStore(DiskConfig{Path: filepath.Join(t.TempDir(), "missing", "queue.db")})
+	assert.NotNil(t, err)
+}
+
+func TestDiskQueueRestart(t *testing.T) {
+	assert := assert.New(t)
+	config := Config{
+		Type: diskQueueType,
+		Disk: DiskConfig{Path: filepath.Join(t.TempDir(), "queue.db")},
+	}
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+
+	// the events are queued before the queue is started, so none are parsed before it is stopped.
+	parser := new(mockParser)
+	queue, err := newEventQueue(config, []Parser{parser}, Measures{}, mockTimeTracker, nil, zap.NewNop())
+	assert.Nil(err)
+	queue.cancel()
+	for _, id := range []string{"1", "2"} {
+		assert.Nil(queue.Queue(EventWithTime{Event: interpreter.Event{TransactionUUID: id}, BeginTime: time.Now()}))
+	}
+	queue.Start()
+	queue.Stop()
+	parser.AssertNotCalled(t, "Parse", mock.Anything)
+	assert.Equal(2, queue.store.len())
+
+	parsed := make(chan string, 2)
+	parser = new(mockParser)
+	parser.On("Parse", mock.Anything).Run(func(args mock.Arguments) {
+		parsed <- args.Get(0).(interpreter.Event).TransactionUUID
+	})
+	queue, err = newEventQueue(config, []Parser{parser}, Measures{}, mockTimeTracker, nil, zap.NewNop())
+	assert.Nil(err)
+	queue.Start()
+	assert.ElementsMatch([]string{"1", "2"}, []string{<-parsed, <-parsed})
+	queue.Stop()
+	assert.Equal(0, queue.store.len())
+	assert.Equal(context.Canceled, queue.ctx.Err())
+}
+
+func TestDiskQueueCorruptEvent(t *testing.T) {
+	assert := assert.New(t)
+	config := Config{
+		Type:      diskQueueType,
+		QueueSize: 10,
+		Disk:      DiskConfig{Path: filepath.Join(t.TempDir(), "queue.db")},
+	}
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{reasonLabel})
+
+	parsed := make(chan string, 1)
+	parser := new(mockParser)
+	parser.On("Parse", mock.Anything).Run(func(args mock.Arguments) {
+		parsed <- args.Get(0).(interpreter.Event).TransactionUUID
+	})
+	queue, err := newEventQueue(config, []Parser{parser}, Measures{DroppedEventsCount: dropped}, mockTimeTracker, nil, zap.NewNop())
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(queue.store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventsBucket)
+		id, _ := b.NextSequence()
+		return b.Put(idKey(id), []byte("not json"))
+	}))
+	queue.store.count++
+	assert.Nil(queue.Queue(EventWithTime{Event: interpreter.Event{TransactionUUID: "2"}, BeginTime: time.Now()}))
+
+	queue.Start()
+	assert.Equal("2", <-parsed)
+	queue.Stop()
+	assert.Equal(0, queue.store.len())
+	assert.Equal(1.0, testutil.ToFloat64(dropped.WithLabelValues(corruptReason)))
+}
@@ -0,0 +1,46 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import "context"
+
+type doneKey struct{}
+
+// DoneFunc is called once a queued event is handled, so that event sources can acknowledge the event only after
+// it is parsed.  Handled is false if the event was dropped without being parsed, such as when the queue is stopped,
+// and should be delivered again.  Events kept in the queue file are handled once they are stored.
+type DoneFunc func(handled bool)
+
+// WithDone returns a context that tells the event endpoint to call done once the event received with the context
+// is handled.
+func WithDone(ctx context.Context, done DoneFunc) context.Context {
+	return context.WithValue(ctx, doneKey{}, done)
+}
+
+// DoneFromContext returns the DoneFunc of the context, or nil if there isn't one.
+func DoneFromContext(ctx context.Context) DoneFunc {
+	done, _ := ctx.Value(doneKey{}).(DoneFunc)
+	return done
+}
+
+// done calls the event's DoneFunc, if it has one.
+func (e EventWithTime) done(handled bool) {
+	if e.Done != nil {
+		e.Done(handled)
+	}
+}
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/webpa-common/v2/semaphore"
+	"go.uber.org/zap"
+)
+
+func TestDoneFromContext(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(DoneFromContext(context.Background()))
+
+	var handled []bool
+	ctx := WithDone(context.Background(), func(h bool) {
+		handled = append(handled, h)
+	})
+	done := DoneFromContext(ctx)
+	if assert.NotNil(done) {
+		done(false)
+	}
+	assert.Equal([]bool{false}, handled)
+
+	// events without a DoneFunc are handled without calling anything.
+	EventWithTime{}.done(true)
+}
+
+func TestParseBatchDone(t *testing.T) {
+	tests := []struct {
+		description     string
+		engaged         bool
+		expectedHandled []bool
+		expectedParses  int
+	}{
+		{
+			description:     "Parsed",
+			expectedHandled: []bool{true, true},
+			expectedParses:  2,
+		},
+		{
+			description:     "Kill switch engaged",
+			engaged:         true,
+			expectedHandled: []bool{true, true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			parser := new(mockParser)
+			parser.On("Parse", mock.Anything).Return(nil)
+			parser.On("Name").Return("parser")
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything)
+
+			queue := EventQueue{
+				parsers:     []Parser{parser},
+				logger:      zap.NewNop(),
+				ctx:         context.Background(),
+				workers:     semaphore.New(1),
+				killSwitch:  NewKillSwitch(KillSwitchConfig{Engaged: tc.engaged}),
+				timeTracker: mockTimeTracker,
+			}
+
+			var handled []bool
+			done := func(h bool) {
+				handled = append(handled, h)
+			}
+
+			queue.workers.Acquire()
+			queue.ParseBatch([]EventWithTime{
+				{Event: interpreter.Event{TransactionUUID: "1"}, Done: done},
+				{Event: interpreter.Event{TransactionUUID: "2"}, Done: done},
+			})
+
+			assert.Equal(tc.expectedHandled, handled)
+			parser.AssertNumberOfCalls(t, "Parse", tc.expectedParses)
+		})
+	}
+}
@@ -17,10 +17,19 @@
 
 package queue
 
-import "net/http"
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 type TooManyRequestsErr struct {
 	Message string
+
+	// RetryAfter is how long the sender should wait before sending again.  If it is 0, no Retry-After header is
+	// sent.
+	RetryAfter time.Duration
 }
 
 func (e TooManyRequestsErr) Error() string {
@@ -30,3 +39,26 @@ func (e TooManyRequestsErr) Error() string {
 func (e TooManyRequestsErr) StatusCode() int {
 	return http.StatusTooManyRequests
 }
+
+// Headers returns the Retry-After header, in whole seconds rounded up, if RetryAfter is set.
+func (e TooManyRequestsErr) Headers() http.Header {
+	if e.RetryAfter <= 0 {
+		return nil
+	}
+
+	return http.Header{
+		"Retry-After": []string{strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds())))},
+	}
+}
+
+type ServiceUnavailableErr struct {
+	Message string
+}
+
+func (e ServiceUnavailableErr) Error() string {
+	return e.Message
+}
+
+func (e ServiceUnavailableErr) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/stretchr/testify/assert"
@@ -18,3 +19,21 @@ func TestTooManyRequestsErr(t *testing.T) {
 	assert.Equal(message, err.Error())
 	assert.Equal(http.StatusTooManyRequests, err.StatusCode())
 }
+
+func TestTooManyRequestsErrHeaders(t *testing.T) {
+	assert := assert.New(t)
+	var headerer kithttp.Headerer
+	assert.True(errors.As(TooManyRequestsErr{}, &headerer))
+	assert.Nil(TooManyRequestsErr{}.Headers())
+	assert.Equal("2", TooManyRequestsErr{RetryAfter: 1500 * time.Millisecond}.Headers().Get("Retry-After"))
+}
+
+func TestServiceUnavailableErr(t *testing.T) {
+	assert := assert.New(t)
+	message := "service unavailable"
+	err := ServiceUnavailableErr{Message: message}
+	var statusCoder kithttp.StatusCoder
+	assert.True(errors.As(err, &statusCoder))
+	assert.Equal(message, err.Error())
+	assert.Equal(http.StatusServiceUnavailable, err.StatusCode())
+}
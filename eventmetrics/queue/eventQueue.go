@@ -18,11 +18,16 @@
 package queue
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/xmidt-org/interpreter"
@@ -33,12 +38,17 @@ import (
 var (
 	defaultLogger = zap.NewNop()
 
-	errNoParsers = errors.New("No parsers")
+	errNoParsers      = errors.New("No parsers")
+	errParserPanicked = errors.New("parser panicked")
 )
 
 const (
 	defaultMaxWorkers   = 5
 	defaultMinQueueSize = 5
+	defaultBatchSize    = 1
+	defaultDrainTimeout = 10 * time.Second
+
+	shutdownReason = "shutdown"
 )
 
 // TimeTracker tracks the time an event is in memory.
@@ -50,6 +60,51 @@ type TimeTracker interface {
 type Config struct {
 	QueueSize  int
 	MaxWorkers int
+
+	// BatchSize is the maximum number of queued events handed to a worker to be parsed together.
+	// Defaults to 1, which parses each event on its own.
+	BatchSize int
+
+	// ParserTimeout is the maximum amount of time a parser is given to parse an event, or a batch of events
+	// for parsers that parse batches.  Defaults to 0, which doesn't set a deadline.
+	ParserTimeout time.Duration
+
+	// DrainTimeout is the maximum amount of time given to parse the events still in the queue when it is
+	// stopped.  Events left in the queue after it passes are dropped.  Defaults to 10s.
+	DrainTimeout time.Duration
+
+	// Type is the queue backend: memory, which keeps queued events in memory, or disk, which stores them
+	// in a file so that they survive restarts.  Defaults to memory.
+	Type string
+
+	// Disk configures the disk-backed queue.
+	Disk DiskConfig
+
+	// Priorities assigns priorities to events by destination.  Events with higher priorities are taken from
+	// the queue before events with lower priorities, and events matching none of the destinations have a
+	// priority of 0.  Each priority holds up to QueueSize events.
+	Priorities []PriorityConfig
+
+	IngestRate IngestRateConfig
+	KillSwitch KillSwitchConfig
+
+	// Autoscale configures adjusting the number of workers between a minimum and MaxWorkers.
+	Autoscale AutoscaleConfig
+
+	// Backpressure configures rejecting events before the queue is full.
+	Backpressure BackpressureConfig
+
+	// Sampling parses only a fraction of the devices sending events with matching destinations.
+	Sampling []SamplingRule
+
+	// Latency configures the histograms of how long events take to be parsed.
+	Latency LatencyConfig
+
+	// AuditLog configures keeping the last parsed events of each device.
+	AuditLog AuditLogConfig
+
+	// Routing configures dispatching events only to the parsers whose routes match them.
+	Routing RoutingConfig
 }
 
 // EventQueue processes incoming events
@@ -62,21 +117,90 @@ type EventQueue struct {
 	parsers     []Parser
 	metrics     Measures
 	timeTracker TimeTracker
+	ingestRate  *ingestRate
+	killSwitch  *KillSwitch
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	// tracer traces the parsing of events.  If it is nil, parsing isn't traced.
+	tracer trace.Tracer
+
+	// stopLock guards sending to the queue against the queue being closed.
+	stopLock sync.RWMutex
+	stopped  bool
+	inFlight sync.WaitGroup
+	parsing  atomic.Int64
+	draining atomic.Bool
+	drained  atomic.Int64
+	dropped  atomic.Int64
+
+	// store holds the queued events when the queue is disk-backed.  Stored events are fed into the
+	// queue until feeding is stopped, and are only removed once parsed.
+	store       *diskStore
+	stopFeeding chan struct{}
+	feeding     sync.WaitGroup
+	kept        atomic.Int64
+
+	// lanes holds the queued events instead of queue when priorities are configured.
+	lanes *lanes
+
+	// autoscaler adjusts the number of workers.  If it is nil, MaxWorkers workers parse events.
+	autoscaler *autoscaler
+
+	// backpressure rejects events while the queue is past its high-water mark.  If it is nil, events are only
+	// rejected when the queue is full.
+	backpressure *backpressure
+
+	// sampler skips the events of devices that aren't sampled.  If it is nil, every event is parsed.
+	sampler *sampler
+
+	// auditLog keeps the last parsed events of each device.  If it is nil, events aren't kept.
+	auditLog *AuditLog
+
+	// router routes events to the parsers whose routes match them.  If it is nil, every event is given to every
+	// parser.
+	router *router
 }
 
 // Parser is the interface that all glaukos parsers must implement.
 type Parser interface {
-	Parse(interpreter.Event)
+	Parse(context.Context, interpreter.Event)
 	Name() string
 }
 
+// BatchParser is implemented by parsers that can parse a batch of events together, such as to share
+// lookups between events from the same device.  Parsers that don't implement it are given each event
+// of a batch on its own.
+type BatchParser interface {
+	ParseBatch(context.Context, []interpreter.Event)
+}
+
+// EventTypeMatcher is implemented by parsers that only parse events with event types matching
+// certain regular expressions.
+type EventTypeMatcher interface {
+	EventTypeRegexes() []string
+}
+
 // EventWithTime allows for the tracking of how long an event stays in glaukos's memory.
 type EventWithTime struct {
 	Event     interpreter.Event
 	BeginTime time.Time
+
+	// SpanContext is the span of the request the event was received in, which the parsing of the event is
+	// traced within.
+	SpanContext trace.SpanContext
+
+	// Done is called once the event is parsed or dropped after being queued.  If it is nil, nothing is called.
+	Done DoneFunc
+
+	// id identifies the event in the disk store.
+	id uint64
+
+	// queued is when the event was added to the queue.
+	queued time.Time
 }
 
-func newEventQueue(config Config, parsers []Parser, metrics Measures, tracker TimeTracker, logger *zap.Logger) (*EventQueue, error) {
+func newEventQueue(config Config, parsers []Parser, metrics Measures, tracker TimeTracker, killSwitch *KillSwitch, logger *zap.Logger) (*EventQueue, error) {
 	if len(parsers) == 0 {
 		return nil, errNoParsers
 	}
@@ -89,12 +213,44 @@ func newEventQueue(config Config, parsers []Parser, metrics Measures, tracker Ti
 		config.QueueSize = defaultMinQueueSize
 	}
 
+	if config.BatchSize < defaultBatchSize {
+		config.BatchSize = defaultBatchSize
+	}
+
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = defaultDrainTimeout
+	}
+
 	if logger == nil {
 		logger = defaultLogger
 	}
 
+	eventSampler, err := newSampler(config.Sampling, metrics.SampledEventsCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var store *diskStore
+	switch config.Type {
+	case "", memoryQueueType:
+	case diskQueueType:
+		if store, err = openDiskStore(config.Disk); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", errInvalidQueueType, config.Type)
+	}
+
+	var priorityLanes *lanes
+	if len(config.Priorities) > 0 {
+		if priorityLanes, err = newLanes(config.Priorities, config.QueueSize, metrics.PriorityQueueDepth); err != nil {
+			return nil, err
+		}
+	}
+
 	queue := make(chan EventWithTime, config.QueueSize)
 	workers := semaphore.New(config.MaxWorkers)
+	ctx, cancel := context.WithCancel(context.Background())
 
 	e := EventQueue{
 		config:      config,
@@ -104,68 +260,613 @@ func newEventQueue(config Config, parsers []Parser, metrics Measures, tracker Ti
 		parsers:     parsers,
 		metrics:     metrics,
 		timeTracker: tracker,
+		ingestRate:  newIngestRate(config.IngestRate, metrics.IngestRate),
+		killSwitch:  killSwitch,
+		ctx:         ctx,
+		cancel:      cancel,
+		store:       store,
+		lanes:       priorityLanes,
+		sampler:     eventSampler,
+	}
+
+	if store != nil {
+		e.stopFeeding = make(chan struct{})
 	}
 
+	e.autoscaler = newAutoscaler(config.Autoscale, workers, config.MaxWorkers, e.depth, metrics, logger)
+	e.backpressure = newBackpressure(config.Backpressure, metrics.ThrottledEventsCount)
 	return &e, nil
 }
 
 func (e *EventQueue) Start() {
+	if e.store != nil {
+		if e.metrics.EventsQueueDepth != nil {
+			e.metrics.EventsQueueDepth.Add(float64(e.store.len()))
+		}
+		e.feeding.Add(1)
+		go e.feedEvents()
+	}
+
 	e.wg.Add(1)
 	go e.ParseEvents()
+	if e.ingestRate != nil {
+		e.ingestRate.Start()
+	}
+
+	if e.autoscaler != nil {
+		e.autoscaler.Start()
+	}
 }
 
+// Stop stops accepting events and drains the queue, waiting for the events still in the queue to be
+// parsed.  If the drain timeout passes first, the context given to parsers is cancelled so that parsers
+// waiting on lookups return early, and the events left in the queue are dropped.
 func (e *EventQueue) Stop() {
-	close(e.queue)
-	e.wg.Wait()
+	e.stopLock.Lock()
+	if e.stopped {
+		e.stopLock.Unlock()
+		return
+	}
+	e.stopped = true
+	e.draining.Store(true)
+	if e.store == nil {
+		e.closeQueue()
+	} else {
+		close(e.stopFeeding)
+	}
+	e.stopLock.Unlock()
+
+	// every worker is used to drain the queue.
+	if e.autoscaler != nil {
+		e.autoscaler.Stop()
+	}
+
+	if e.store != nil {
+		e.feeding.Wait()
+		e.closeQueue()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		e.inFlight.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(e.config.DrainTimeout)
+	select {
+	case <-done:
+		timer.Stop()
+	case <-timer.C:
+		e.logger.Warn("drain timeout passed, dropping the remaining events", zap.Duration("drain timeout", e.config.DrainTimeout))
+		e.cancel()
+		<-done
+	}
+
+	e.cancel()
+	e.logger.Info("event queue stopped", zap.Int64("drained", e.drained.Load()), zap.Int64("dropped", e.dropped.Load()),
+		zap.Int64("kept", e.kept.Load()))
+	if e.ingestRate != nil {
+		e.ingestRate.Stop()
+	}
+
+	if e.store != nil {
+		if err := e.store.close(); err != nil {
+			e.logger.Error("failed to close queue file", zap.Error(err))
+		}
+	}
 }
 
-// Queue attempts to add a message to the queue and returns an error if the queue is full.
+// Queue attempts to add a message to the queue and returns an error if the queue is full or stopped.
 func (e *EventQueue) Queue(eventWithTime EventWithTime) (err error) {
-	select {
-	case e.queue <- eventWithTime:
-		if e.metrics.EventsQueueDepth != nil {
-			e.metrics.EventsQueueDepth.Add(1.0)
+	e.stopLock.RLock()
+	defer e.stopLock.RUnlock()
+	if e.stopped {
+		if e.metrics.DroppedEventsCount != nil {
+			e.metrics.DroppedEventsCount.With(prometheus.Labels{reasonLabel: shutdownReason}).Add(1.0)
 		}
-	default:
+		e.timeTracker.TrackTime(time.Since(eventWithTime.BeginTime))
+		return ServiceUnavailableErr{Message: "Queue Stopped"}
+	}
+
+	if e.backpressure != nil && e.backpressure.Throttle(e.depth()) {
+		e.timeTracker.TrackTime(time.Since(eventWithTime.BeginTime))
+		return TooManyRequestsErr{Message: "Queue Over High-Water Mark", RetryAfter: e.backpressure.retryAfter}
+	}
+
+	eventWithTime.queued = time.Now()
+	full := false
+	if e.store != nil {
+		if err = e.store.add(eventWithTime, e.config.QueueSize); errors.Is(err, errStoreFull) {
+			full = true
+		} else if err != nil {
+			e.logger.Error("failed to store event", zap.Error(err))
+			e.timeTracker.TrackTime(time.Since(eventWithTime.BeginTime))
+			return ServiceUnavailableErr{Message: "Queue Unavailable"}
+		}
+	} else if e.lanes != nil {
+		full = !e.lanes.tryPush(eventWithTime)
+	} else {
+		select {
+		case e.queue <- eventWithTime:
+		default:
+			full = true
+		}
+	}
+
+	if full {
 		if e.metrics.DroppedEventsCount != nil {
 			e.metrics.DroppedEventsCount.With(prometheus.Labels{reasonLabel: queueFullReason}).Add(1.0)
 		}
 		e.timeTracker.TrackTime(time.Since(eventWithTime.BeginTime))
-		err = TooManyRequestsErr{Message: "Queue Full"}
+		err := TooManyRequestsErr{Message: "Queue Full"}
+		if e.backpressure != nil {
+			err.RetryAfter = e.backpressure.retryAfter
+		}
+		return err
+	}
+
+	// the queue file keeps the event until it is parsed, even across restarts.
+	if e.store != nil {
+		eventWithTime.done(true)
+	}
+
+	if e.metrics.EventsQueueDepth != nil {
+		e.metrics.EventsQueueDepth.Add(1.0)
+	}
+	if e.ingestRate != nil {
+		e.ingestRate.Mark()
 	}
 
-	return
+	return nil
+}
+
+// feedEvents feeds the events in the disk store into the queue in the order they were stored, until
+// feeding is stopped.
+func (e *EventQueue) feedEvents() {
+	defer e.feeding.Done()
+	var last uint64
+	for {
+		events, corrupt, err := e.store.after(last, e.config.QueueSize)
+		if err != nil {
+			e.logger.Error("failed to read stored events", zap.Error(err))
+		}
+
+		if corrupt > 0 {
+			e.logger.Error("deleted undecodable stored events", zap.Int("count", corrupt))
+			if e.metrics.DroppedEventsCount != nil {
+				e.metrics.DroppedEventsCount.With(prometheus.Labels{reasonLabel: corruptReason}).Add(float64(corrupt))
+			}
+			if e.metrics.EventsQueueDepth != nil {
+				e.metrics.EventsQueueDepth.Sub(float64(corrupt))
+			}
+		}
+
+		for _, event := range events {
+			if e.lanes != nil {
+				if !e.lanes.push(event, e.stopFeeding) {
+					return
+				}
+				last = event.id
+				continue
+			}
+
+			select {
+			case e.queue <- event:
+				last = event.id
+			case <-e.stopFeeding:
+				return
+			}
+		}
+
+		if len(events) == 0 || err != nil {
+			select {
+			case <-e.store.notify:
+			case <-e.stopFeeding:
+				return
+			}
+		}
+	}
 }
 
-// ParseEvents goes through the queue and calls ParseEvent on each event in the queue.
+// ParseEvents goes through the queue and hands the events in the queue to workers, either one at a time
+// or in batches of up to the configured batch size.  Once the queue is stopped and the drain timeout has
+// passed, the events left in the queue are dropped.
 func (e *EventQueue) ParseEvents() {
 	defer e.wg.Done()
-	for event := range e.queue {
-		if e.metrics.EventsQueueDepth != nil {
-			e.metrics.EventsQueueDepth.Add(-1.0)
+	for {
+		event, ok := e.dequeue()
+		if !ok {
+			return
 		}
+
+		e.markDequeued()
+		batch := []EventWithTime{event}
+		if e.config.BatchSize > 1 {
+			batch = e.nextBatch(event)
+		}
+
 		e.workers.Acquire()
-		go e.ParseEvent(event)
+		if e.ctx.Err() != nil {
+			e.workers.Release()
+			// stored events are kept to be parsed after a restart.
+			if e.store != nil {
+				e.kept.Add(int64(len(batch)))
+				continue
+			}
+			e.dropEvents(batch, shutdownReason)
+			e.dropped.Add(int64(len(batch)))
+			for _, eventWithTime := range batch {
+				eventWithTime.done(false)
+			}
+			continue
+		}
+
+		if e.draining.Load() {
+			e.drained.Add(int64(len(batch)))
+		}
+
+		e.inFlight.Add(1)
+		e.parsing.Add(1)
+		go func() {
+			defer e.inFlight.Done()
+			defer e.parsing.Add(-1)
+			begin := time.Now()
+			e.ParseBatch(batch)
+			if e.autoscaler != nil {
+				e.autoscaler.Observe(time.Since(begin))
+			}
+		}()
+	}
+}
+
+// nextBatch builds a batch starting with the given event, adding events that are already waiting in
+// the queue until the batch is full.  It never waits for more events to arrive.
+func (e *EventQueue) nextBatch(first EventWithTime) []EventWithTime {
+	batch := make([]EventWithTime, 1, e.config.BatchSize)
+	batch[0] = first
+	for len(batch) < e.config.BatchSize {
+		event, ok := e.tryDequeue()
+		if !ok {
+			return batch
+		}
+		e.markDequeued()
+		batch = append(batch, event)
 	}
+
+	return batch
+}
+
+// dequeue waits for the next event, taking events with higher priorities first.  It returns false once
+// the queue is closed and empty.
+func (e *EventQueue) dequeue() (EventWithTime, bool) {
+	if e.lanes != nil {
+		return e.lanes.pop()
+	}
+
+	event, ok := <-e.queue
+	return event, ok
 }
 
-// ParseEvent parses the metadata and boot-time of each event and generates metrics.
+// tryDequeue takes the next event like dequeue, but returns false instead of waiting if no events are
+// waiting.
+func (e *EventQueue) tryDequeue() (EventWithTime, bool) {
+	if e.lanes != nil {
+		return e.lanes.tryPop()
+	}
+
+	select {
+	case event, ok := <-e.queue:
+		return event, ok
+	default:
+		return EventWithTime{}, false
+	}
+}
+
+// queued returns the number of events waiting in the queue and the number of events it holds.
+func (e *EventQueue) queued() (int, int) {
+	switch {
+	case e.store != nil:
+		return e.store.len(), e.config.QueueSize
+	case e.lanes != nil:
+		return len(e.lanes.ready), cap(e.lanes.ready)
+	default:
+		return len(e.queue), cap(e.queue)
+	}
+}
+
+// depth returns the number of events waiting in the queue as a fraction of its size.  With priority lanes, it is
+// the depth of the fullest lane.
+func (e *EventQueue) depth() float64 {
+	if e.lanes != nil {
+		return e.lanes.fill()
+	}
+
+	queued, size := e.queued()
+	return float64(queued) / float64(size)
+}
+
+func (e *EventQueue) closeQueue() {
+	if e.lanes != nil {
+		e.lanes.close()
+		return
+	}
+
+	close(e.queue)
+}
+
+func (e *EventQueue) markDequeued() {
+	if e.metrics.EventsQueueDepth != nil {
+		e.metrics.EventsQueueDepth.Add(-1.0)
+	}
+}
+
+// ParseEvent parses the metadata and boot-time of each event and generates metrics.  If the kill switch is
+// engaged, the event is dropped without being parsed.
 func (e *EventQueue) ParseEvent(eventWithTime EventWithTime) {
+	e.ParseBatch([]EventWithTime{eventWithTime})
+}
+
+// ParseBatch parses a batch of events together.  Parsers implementing BatchParser are given the whole batch,
+// while the rest of the parsers are given each event on its own.  Events are counted and their time in memory
+// is tracked individually.  If the kill switch is engaged, the events are dropped without being parsed, and
+// parsers disabled through the kill switch are skipped.
+func (e *EventQueue) ParseBatch(batch []EventWithTime) {
 	defer e.workers.Release()
-	if e.metrics.EventsCount != nil {
-		event := eventWithTime.Event
-		partnerID := basculechecks.DeterminePartnerMetric(event.PartnerIDs)
-		eventType, err := event.EventType()
-		if err != nil {
-			e.logger.Error("unable to get event type")
-			eventType = "unknown"
+	for _, eventWithTime := range batch {
+		e.countEvent(eventWithTime.Event)
+		observeSince(e.metrics.QueueWait, eventWithTime.queued)
+	}
+
+	defer e.removeStored(batch)
+	if e.killSwitch.Engaged() {
+		e.dropEvents(batch, killSwitchEngagedReason)
+		for _, eventWithTime := range batch {
+			eventWithTime.done(true)
+		}
+		return
+	}
+
+	ctx, span := e.startBatchSpan(batch)
+	defer span.End()
+
+	parsers := e.enabledParsers()
+	skipped := e.skippedEvents(batch)
+	unrouted := e.unroutedEvents(batch, parsers)
+	outcomes := e.auditOutcomes(batch)
+	var events []interpreter.Event
+	for _, p := range parsers {
+		if batchParser, ok := p.(BatchParser); ok {
+			if skipped != nil || unrouted != nil {
+				events = parsedEvents(batch, skipped, unrouted, p)
+			} else if events == nil {
+				events = make([]interpreter.Event, 0, len(batch))
+				for _, eventWithTime := range batch {
+					events = append(events, eventWithTime.Event)
+				}
+			}
+
+			outcome := skippedOutcome
+			if len(events) > 0 {
+				parsed := events
+				outcome = e.parseWithTimeout(ctx, p, len(parsed), func(ctx context.Context) {
+					batchParser.ParseBatch(ctx, parsed)
+				})
+			}
+
+			for i := range outcomes {
+				if skip, ok := skipOutcome(skipped, unrouted, i, p); ok {
+					outcomes[i][p.Name()] = skip
+				} else {
+					outcomes[i][p.Name()] = outcome
+				}
+			}
+		}
+	}
+
+	for i, eventWithTime := range batch {
+		for _, p := range parsers {
+			if _, ok := p.(BatchParser); ok {
+				continue
+			}
+
+			outcome, skip := skipOutcome(skipped, unrouted, i, p)
+			if !skip {
+				outcome = e.parseWithTimeout(ctx, p, 1, func(ctx context.Context) {
+					p.Parse(ctx, eventWithTime.Event)
+				})
+			}
+
+			if outcomes != nil {
+				outcomes[i][p.Name()] = outcome
+			}
+		}
+		e.timeTracker.TrackTime(time.Since(eventWithTime.BeginTime))
+		observeSince(e.metrics.EventLatency, eventWithTime.BeginTime)
+		if e.auditLog != nil {
+			e.auditLog.Record(eventWithTime.Event, outcomes[i])
 		}
-		e.metrics.EventsCount.With(prometheus.Labels{partnerIDLabel: partnerID, eventDestLabel: eventType}).Add(1.0)
+		eventWithTime.done(true)
+	}
+}
+
+// auditOutcomes returns a map for the outcomes of parsing each event of the batch, or nil if the audit log
+// isn't enabled.
+func (e *EventQueue) auditOutcomes(batch []EventWithTime) []map[string]string {
+	if e.auditLog == nil {
+		return nil
+	}
+
+	outcomes := make([]map[string]string, len(batch))
+	for i := range outcomes {
+		outcomes[i] = make(map[string]string)
+	}
+
+	return outcomes
+}
+
+// skippedEvents returns the sampling rule skipping each event of the batch, or nil if sampling isn't configured.
+func (e *EventQueue) skippedEvents(batch []EventWithTime) []*samplingRule {
+	if e.sampler == nil {
+		return nil
+	}
+
+	skipped := make([]*samplingRule, len(batch))
+	for i, eventWithTime := range batch {
+		skipped[i] = e.sampler.skippedBy(eventWithTime.Event)
 	}
 
+	return skipped
+}
+
+// unroutedEvents returns the names of the parsers each event of the batch isn't routed to, or nil if routing
+// isn't enabled.
+func (e *EventQueue) unroutedEvents(batch []EventWithTime, parsers []Parser) []map[string]bool {
+	if e.router == nil {
+		return nil
+	}
+
+	unrouted := make([]map[string]bool, len(batch))
+	for i, eventWithTime := range batch {
+		unrouted[i] = e.router.unrouted(eventWithTime.Event, parsers)
+	}
+
+	return unrouted
+}
+
+// skipOutcome returns the outcome of an event of the batch that isn't given to the parser, either because it isn't
+// routed to the parser or isn't sampled for it.  It returns false if the event is given to the parser.
+func skipOutcome(skipped []*samplingRule, unrouted []map[string]bool, i int, p Parser) (string, bool) {
+	if unrouted != nil && unrouted[i][p.Name()] {
+		return unroutedOutcome, true
+	}
+
+	if skipped != nil && skipped[i] != nil && skipped[i].appliesTo(p.Name()) {
+		return skippedOutcome, true
+	}
+
+	return "", false
+}
+
+// parsedEvents returns the events of the batch that are given to the parser.
+func parsedEvents(batch []EventWithTime, skipped []*samplingRule, unrouted []map[string]bool, p Parser) []interpreter.Event {
+	events := make([]interpreter.Event, 0, len(batch))
+	for i, eventWithTime := range batch {
+		if _, skip := skipOutcome(skipped, unrouted, i, p); !skip {
+			events = append(events, eventWithTime.Event)
+		}
+	}
+
+	return events
+}
+
+// enabledParsers returns the parsers that haven't been disabled through the kill switch.
+func (e *EventQueue) enabledParsers() []Parser {
+	if e.killSwitch == nil {
+		return e.parsers
+	}
+
+	parsers := make([]Parser, 0, len(e.parsers))
 	for _, p := range e.parsers {
-		p.Parse(eventWithTime.Event)
+		if e.killSwitch.ParserEnabled(p.Name()) {
+			parsers = append(parsers, p)
+		}
+	}
+
+	return parsers
+}
+
+// removeStored removes the events from the disk store once they are handled.
+func (e *EventQueue) removeStored(batch []EventWithTime) {
+	if e.store == nil {
+		return
+	}
+
+	if err := e.store.remove(batch); err != nil {
+		e.logger.Error("failed to remove parsed events from the queue file", zap.Error(err))
 	}
+}
+
+// dropEvents counts the events as dropped for the reason given and tracks their time in memory.
+func (e *EventQueue) dropEvents(batch []EventWithTime, reason string) {
+	for _, eventWithTime := range batch {
+		if e.metrics.DroppedEventsCount != nil {
+			e.metrics.DroppedEventsCount.With(prometheus.Labels{reasonLabel: reason}).Add(1.0)
+		}
+		e.timeTracker.TrackTime(time.Since(eventWithTime.BeginTime))
+	}
+}
+
+// parseWithTimeout runs the parse function in a span of its own, with a context that is cancelled when the
+// queue stops or the configured parser timeout passes, counting the parses that ran out of time.  The time taken
+// is observed, and the number of events parsed is counted by outcome, which is returned.
+func (e *EventQueue) parseWithTimeout(ctx context.Context, p Parser, events int, parse func(context.Context)) string {
+	ctx, span := e.startSpan(ctx, "parse")
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String(parserAttribute, p.Name()))
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if e.config.ParserTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, e.config.ParserTimeout)
+	}
+	defer cancel()
+
+	begin := time.Now()
+	recovered := !e.parseRecovered(ctx, p, parse)
+	duration := time.Since(begin)
+
+	outcome := successOutcome
+	err := ctx.Err()
+	switch {
+	case recovered:
+		outcome, err = panicOutcome, errParserPanicked
+	case errors.Is(err, context.DeadlineExceeded):
+		outcome = timeoutOutcome
+		e.logger.Warn("parser timed out", zap.String("parser", p.Name()), zap.Duration("timeout", e.config.ParserTimeout))
+		if e.metrics.ParserTimeoutsCount != nil {
+			e.metrics.ParserTimeoutsCount.With(prometheus.Labels{parserLabel: p.Name()}).Add(1.0)
+		}
+	case err != nil:
+		outcome = cancelledOutcome
+	}
+	endParserSpan(span, err)
+
+	if e.metrics.ParserLatency != nil {
+		e.metrics.ParserLatency.With(prometheus.Labels{parserLabel: p.Name()}).Observe(duration.Seconds())
+	}
+
+	if e.metrics.ParserEventsCount != nil {
+		e.metrics.ParserEventsCount.With(prometheus.Labels{parserLabel: p.Name(), outcomeLabel: outcome}).Add(float64(events))
+	}
+
+	return outcome
+}
 
-	e.timeTracker.TrackTime(time.Since(eventWithTime.BeginTime))
+// parseRecovered runs the parse function, recovering from a panic in the parser so that the worker goes on to parse
+// the event with the other parsers.  It returns false if the parser panicked.
+func (e *EventQueue) parseRecovered(ctx context.Context, p Parser, parse func(context.Context)) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			parserPanicked(p, r, e.metrics.ParserPanicsCount, e.logger)
+			ok = false
+		}
+	}()
+
+	parse(ctx)
+	return true
+}
+
+func (e *EventQueue) countEvent(event interpreter.Event) {
+	if e.metrics.EventsCount == nil {
+		return
+	}
+
+	partnerID := basculechecks.DeterminePartnerMetric(event.PartnerIDs)
+	eventType, err := event.EventType()
+	if err != nil {
+		e.logger.Error("unable to get event type")
+		eventType = "unknown"
+	}
+	e.metrics.EventsCount.With(prometheus.Labels{partnerIDLabel: partnerID, eventDestLabel: eventType}).Add(1.0)
 }
@@ -1,12 +1,17 @@
 package queue
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/xmidt-org/interpreter"
@@ -34,29 +39,51 @@ func TestNewEventParser(t *testing.T) {
 			description: "Custom config success",
 			logger:      zap.NewNop(),
 			config: Config{
-				QueueSize:  100,
-				MaxWorkers: 10,
+				QueueSize:    100,
+				MaxWorkers:   10,
+				DrainTimeout: time.Minute,
 			},
 			parsers: []Parser{mockParser1, mockParser2},
 			metrics: emptyMetrics,
 			expectedEventQueue: &EventQueue{
 				logger: zap.NewNop(),
 				config: Config{
-					QueueSize:  100,
-					MaxWorkers: 10,
+					QueueSize:    100,
+					MaxWorkers:   10,
+					BatchSize:    defaultBatchSize,
+					DrainTimeout: time.Minute,
 				},
 				parsers: []Parser{mockParser1, mockParser2},
 				metrics: emptyMetrics,
 			},
 		},
+		{
+			description: "Custom batch size",
+			config: Config{
+				BatchSize: 10,
+			},
+			parsers: []Parser{mockParser1},
+			expectedEventQueue: &EventQueue{
+				logger: zap.NewNop(),
+				config: Config{
+					QueueSize:    defaultMinQueueSize,
+					MaxWorkers:   defaultMaxWorkers,
+					BatchSize:    10,
+					DrainTimeout: defaultDrainTimeout,
+				},
+				parsers: []Parser{mockParser1},
+			},
+		},
 		{
 			description: "Success with defaults",
 			parsers:     []Parser{mockParser1, mockParser2},
 			expectedEventQueue: &EventQueue{
 				logger: zap.NewNop(),
 				config: Config{
-					QueueSize:  defaultMinQueueSize,
-					MaxWorkers: defaultMaxWorkers,
+					QueueSize:    defaultMinQueueSize,
+					MaxWorkers:   defaultMaxWorkers,
+					BatchSize:    defaultBatchSize,
+					DrainTimeout: defaultDrainTimeout,
 				},
 				parsers: []Parser{mockParser1, mockParser2},
 			},
@@ -71,13 +98,19 @@ func TestNewEventParser(t *testing.T) {
 			parsers:     nil,
 			expectedErr: errNoParsers,
 		},
+		{
+			description: "Invalid queue type",
+			config:      Config{Type: "tape"},
+			parsers:     []Parser{mockParser1},
+			expectedErr: errInvalidQueueType,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.description, func(t *testing.T) {
 			assert := assert.New(t)
 
-			queue, err := newEventQueue(tc.config, tc.parsers, Measures{}, mockTimeTracker, tc.logger)
+			queue, err := newEventQueue(tc.config, tc.parsers, Measures{}, mockTimeTracker, nil, tc.logger)
 
 			if tc.expectedErr != nil || err != nil {
 				assert.True(errors.Is(err, tc.expectedErr))
@@ -90,6 +123,10 @@ func TestNewEventParser(t *testing.T) {
 				tc.expectedEventQueue.queue = queue.queue
 				tc.expectedEventQueue.workers = queue.workers
 				tc.expectedEventQueue.timeTracker = queue.timeTracker
+				assert.NotNil(queue.ctx)
+				assert.NotNil(queue.cancel)
+				tc.expectedEventQueue.ctx = queue.ctx
+				queue.cancel = nil
 
 			}
 
@@ -100,15 +137,124 @@ func TestNewEventParser(t *testing.T) {
 }
 
 func TestStop(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything).Once()
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testDroppedCount",
+		Help: "testDroppedCount",
+	}, []string{reasonLabel})
 	queue := EventQueue{
-		queue: make(chan EventWithTime, 5),
+		config:      Config{DrainTimeout: time.Minute},
+		queue:       make(chan EventWithTime, 5),
+		logger:      zap.NewNop(),
+		metrics:     Measures{DroppedEventsCount: dropped},
+		timeTracker: mockTimeTracker,
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 
 	queue.Stop()
 	_, more := <-queue.queue
-	assert.False(t, more)
+	assert.False(more)
+	assert.Equal(context.Canceled, ctx.Err())
+
+	// events queued after stopping are rejected instead of sent on the closed queue.
+	err := queue.Queue(EventWithTime{BeginTime: time.Now()})
+	assert.Equal(ServiceUnavailableErr{Message: "Queue Stopped"}, err)
+	assert.Equal(1.0, testutil.ToFloat64(dropped.WithLabelValues(shutdownReason)))
+	mockTimeTracker.AssertExpectations(t)
+
+	// stopping again does nothing.
+	queue.Stop()
 }
 
+func TestStopDrain(t *testing.T) {
+	tests := []struct {
+		description     string
+		parseTime       time.Duration
+		drainTimeout    time.Duration
+		numEvents       int
+		expectedDrained int64
+		expectedDropped int64
+	}{
+		{
+			description:     "all events drained",
+			drainTimeout:    time.Minute,
+			numEvents:       5,
+			expectedDrained: 5,
+		},
+		{
+			description:     "drain timed out",
+			parseTime:       time.Minute,
+			drainTimeout:    10 * time.Millisecond,
+			numEvents:       5,
+			expectedDrained: 1,
+			expectedDropped: 4,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything).Times(tc.numEvents)
+			dropped := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testDroppedCount",
+				Help: "testDroppedCount",
+			}, []string{reasonLabel})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			queue := EventQueue{
+				config:      Config{DrainTimeout: tc.drainTimeout, BatchSize: 1},
+				parsers:     []Parser{slowParser{parseTime: tc.parseTime}},
+				logger:      zap.NewNop(),
+				ctx:         ctx,
+				cancel:      cancel,
+				workers:     semaphore.New(1),
+				metrics:     Measures{DroppedEventsCount: dropped},
+				timeTracker: mockTimeTracker,
+				queue:       make(chan EventWithTime, tc.numEvents),
+			}
+
+			for i := 0; i < tc.numEvents; i++ {
+				queue.queue <- EventWithTime{Event: interpreter.Event{}, BeginTime: time.Now()}
+			}
+
+			// the events are all still in the queue when it is stopped.
+			queue.wg.Add(1)
+			stopped := make(chan struct{})
+			go func() {
+				queue.Stop()
+				close(stopped)
+			}()
+			assert.Eventually(queue.draining.Load, time.Second, time.Millisecond)
+			go queue.ParseEvents()
+			<-stopped
+
+			assert.Equal(tc.expectedDrained, queue.drained.Load())
+			assert.Equal(tc.expectedDropped, queue.dropped.Load())
+			assert.Equal(float64(tc.expectedDropped), testutil.ToFloat64(dropped.WithLabelValues(shutdownReason)))
+			mockTimeTracker.AssertExpectations(t)
+		})
+	}
+}
+
+// slowParser takes the parse time given to parse an event, or until its context is done.
+type slowParser struct {
+	parseTime time.Duration
+}
+
+func (p slowParser) Parse(ctx context.Context, _ interpreter.Event) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(p.parseTime):
+	}
+}
+
+func (slowParser) Name() string { return "slow" }
+
 func TestParseEvents(t *testing.T) {
 	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
 	assert.Nil(t, err)
@@ -148,6 +294,7 @@ func TestParseEvents(t *testing.T) {
 	queue := EventQueue{
 		parsers:     parsers,
 		logger:      zap.NewNop(),
+		ctx:         context.Background(),
 		workers:     semaphore.New(2),
 		metrics:     metrics,
 		timeTracker: mockTimeTracker,
@@ -255,6 +402,7 @@ func TestParseEvent(t *testing.T) {
 				},
 				parsers:     parsers,
 				logger:      zap.NewNop(),
+				ctx:         context.Background(),
 				workers:     semaphore.New(2),
 				metrics:     tc.metrics,
 				timeTracker: mockTimeTracker,
@@ -364,3 +512,493 @@ func TestQueue(t *testing.T) {
 		})
 	}
 }
+
+func TestQueueBackpressure(t *testing.T) {
+	assert := assert.New(t)
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	throttled := prometheus.NewCounter(prometheus.CounterOpts{Name: "testThrottled"})
+	q := EventQueue{
+		logger:       zap.NewNop(),
+		workers:      semaphore.New(2),
+		queue:        make(chan EventWithTime, 10),
+		timeTracker:  mockTimeTracker,
+		backpressure: newBackpressure(BackpressureConfig{Enabled: true, HighWaterMark: 0.8, LowWaterMark: 0.5, RetryAfter: time.Second}, throttled),
+	}
+
+	for i := 0; i < 8; i++ {
+		assert.Nil(q.Queue(EventWithTime{BeginTime: time.Now()}))
+	}
+
+	err := q.Queue(EventWithTime{BeginTime: time.Now()})
+	var tooManyRequests TooManyRequestsErr
+	if !assert.ErrorAs(err, &tooManyRequests) {
+		return
+	}
+	assert.Equal(time.Second, tooManyRequests.RetryAfter)
+	assert.Len(q.queue, 8)
+
+	// events are rejected until the queue drains below the low-water mark.
+	for i := 0; i < 3; i++ {
+		<-q.queue
+	}
+	assert.NotNil(q.Queue(EventWithTime{BeginTime: time.Now()}))
+	<-q.queue
+	assert.Nil(q.Queue(EventWithTime{BeginTime: time.Now()}))
+	assert.Equal(2.0, testutil.ToFloat64(throttled))
+}
+
+func TestParseEventsBatched(t *testing.T) {
+	tests := []struct {
+		description     string
+		batchSize       int
+		numEvents       int
+		expectedBatches int
+		engaged         bool
+	}{
+		{
+			description:     "Batch size 1",
+			batchSize:       1,
+			numEvents:       5,
+			expectedBatches: 5,
+		},
+		{
+			description:     "Partial last batch",
+			batchSize:       4,
+			numEvents:       10,
+			expectedBatches: 3,
+		},
+		{
+			description:     "Single batch",
+			batchSize:       20,
+			numEvents:       10,
+			expectedBatches: 1,
+		},
+		{
+			description:     "Kill switch engaged",
+			batchSize:       4,
+			numEvents:       10,
+			expectedBatches: 0,
+			engaged:         true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			events := make([]interpreter.Event, 0, tc.numEvents)
+			for i := 0; i < tc.numEvents; i++ {
+				events = append(events, interpreter.Event{
+					Destination:     "event:device-status/mac:112233445566/online",
+					PartnerIDs:      []string{"test1"},
+					TransactionUUID: fmt.Sprint(i),
+				})
+			}
+
+			parser := new(mockParser)
+			parser.On("Parse", mock.Anything)
+			parser.On("Name").Return("parser")
+			batchParser := new(mockBatchParser)
+			batchParser.On("ParseBatch", mock.Anything)
+			batchParser.On("Name").Return("batchParser")
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything).Times(tc.numEvents)
+			metrics := Measures{
+				EventsQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+					Name: "testEventsGauge",
+					Help: "testEventsGauge",
+				}),
+				EventsCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "testEventsCount",
+					Help: "testEventsCount",
+				}, []string{partnerIDLabel, eventDestLabel}),
+				DroppedEventsCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+					Name: "testDroppedCount",
+					Help: "testDroppedCount",
+				}, []string{reasonLabel}),
+			}
+
+			maxWorkers := 3
+			queue := EventQueue{
+				config:      Config{BatchSize: tc.batchSize},
+				parsers:     []Parser{parser, batchParser},
+				logger:      zap.NewNop(),
+				ctx:         context.Background(),
+				workers:     semaphore.New(maxWorkers),
+				metrics:     metrics,
+				timeTracker: mockTimeTracker,
+				queue:       make(chan EventWithTime, tc.numEvents),
+				killSwitch:  NewKillSwitch(KillSwitchConfig{Engaged: tc.engaged}),
+			}
+
+			for _, event := range events {
+				queue.queue <- EventWithTime{Event: event, BeginTime: time.Now()}
+			}
+			metrics.EventsQueueDepth.Set(float64(tc.numEvents))
+			close(queue.queue)
+
+			queue.wg.Add(1)
+			queue.ParseEvents()
+			queue.wg.Wait()
+			// wait for all workers to finish.
+			for i := 0; i < maxWorkers; i++ {
+				queue.workers.Acquire()
+			}
+
+			assert.Equal(0.0, testutil.ToFloat64(metrics.EventsQueueDepth))
+			assert.Equal(float64(tc.numEvents), testutil.ToFloat64(metrics.EventsCount.WithLabelValues("test1", "online")))
+			mockTimeTracker.AssertExpectations(t)
+
+			if tc.engaged {
+				assert.Equal(float64(tc.numEvents), testutil.ToFloat64(metrics.DroppedEventsCount.WithLabelValues(killSwitchEngagedReason)))
+				parser.AssertNotCalled(t, "Parse", mock.Anything)
+				batchParser.AssertNotCalled(t, "ParseBatch", mock.Anything)
+				return
+			}
+
+			// every event is given to each parser exactly once, and never to the batch parser's Parse.
+			parser.AssertNumberOfCalls(t, "Parse", tc.numEvents)
+			batchParser.AssertNotCalled(t, "Parse", mock.Anything)
+			batchParser.AssertNumberOfCalls(t, "ParseBatch", tc.expectedBatches)
+			var batched []interpreter.Event
+			for _, call := range batchParser.Calls {
+				if call.Method != "ParseBatch" {
+					continue
+				}
+				batch := call.Arguments.Get(0).([]interpreter.Event)
+				assert.LessOrEqual(len(batch), tc.batchSize)
+				batched = append(batched, batch...)
+			}
+			sort.Slice(batched, func(i, j int) bool {
+				a, _ := strconv.Atoi(batched[i].TransactionUUID)
+				b, _ := strconv.Atoi(batched[j].TransactionUUID)
+				return a < b
+			})
+			assert.Equal(events, batched)
+		})
+	}
+}
+
+func TestParseEventTimeout(t *testing.T) {
+	tests := []struct {
+		description      string
+		timeout          time.Duration
+		stopped          bool
+		expectedTimeouts float64
+		expectedOutcome  string
+	}{
+		{
+			description:      "parser timed out",
+			timeout:          10 * time.Millisecond,
+			expectedTimeouts: 1,
+			expectedOutcome:  timeoutOutcome,
+		},
+		{
+			description:     "queue stopped",
+			stopped:         true,
+			expectedOutcome: cancelledOutcome,
+		},
+		{
+			description:     "queue stopped before timeout",
+			timeout:         time.Hour,
+			stopped:         true,
+			expectedOutcome: cancelledOutcome,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything).Once()
+			timeouts := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testParserTimeouts",
+				Help: "testParserTimeouts",
+			}, []string{parserLabel})
+			parsed := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testParserEvents",
+				Help: "testParserEvents",
+			}, []string{parserLabel, outcomeLabel})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			queue := EventQueue{
+				config:      Config{ParserTimeout: tc.timeout},
+				parsers:     []Parser{blockingParser{}},
+				logger:      zap.NewNop(),
+				ctx:         ctx,
+				cancel:      cancel,
+				workers:     semaphore.New(1),
+				metrics:     Measures{ParserTimeoutsCount: timeouts, ParserEventsCount: parsed},
+				timeTracker: mockTimeTracker,
+			}
+
+			if tc.stopped {
+				cancel()
+			}
+
+			queue.workers.Acquire()
+			queue.ParseEvent(EventWithTime{Event: interpreter.Event{}, BeginTime: time.Now()})
+			cancel()
+			assert.Equal(tc.expectedTimeouts, testutil.ToFloat64(timeouts.WithLabelValues("blocking")))
+			assert.Equal(1.0, testutil.ToFloat64(parsed.WithLabelValues("blocking", tc.expectedOutcome)))
+			mockTimeTracker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestParseEventPanic(t *testing.T) {
+	tests := []struct {
+		description string
+		batch       bool
+	}{
+		{
+			description: "parser panicked",
+		},
+		{
+			description: "batch parser panicked",
+			batch:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			event := interpreter.Event{TransactionUUID: "test"}
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything).Once()
+			panics := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testParserPanics",
+				Help: "testParserPanics",
+			}, []string{parserLabel})
+			parsed := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testParserEvents",
+				Help: "testParserEvents",
+			}, []string{parserLabel, outcomeLabel})
+
+			panicking := new(mockBatchParser)
+			panicking.On("Name").Return("panicking")
+			panicking.On("Parse", event).Run(func(_ mock.Arguments) {
+				panic("parse failed")
+			})
+			panicking.On("ParseBatch", []interpreter.Event{event}).Run(func(_ mock.Arguments) {
+				panic("parse batch failed")
+			})
+
+			var p Parser = &panicking.mockParser
+			if tc.batch {
+				p = panicking
+			}
+
+			other := new(mockParser)
+			other.On("Name").Return("other").Maybe()
+			other.On("Parse", event).Once()
+
+			queue := EventQueue{
+				parsers:     []Parser{p, other},
+				logger:      zap.NewNop(),
+				ctx:         context.Background(),
+				workers:     semaphore.New(1),
+				metrics:     Measures{ParserPanicsCount: panics, ParserEventsCount: parsed},
+				timeTracker: mockTimeTracker,
+			}
+
+			queue.workers.Acquire()
+			assert.NotPanics(func() {
+				queue.ParseEvent(EventWithTime{Event: event, BeginTime: time.Now()})
+			})
+			assert.Equal(1.0, testutil.ToFloat64(panics.WithLabelValues("panicking")))
+			assert.Equal(1.0, testutil.ToFloat64(parsed.WithLabelValues("panicking", panicOutcome)))
+			assert.Equal(1.0, testutil.ToFloat64(parsed.WithLabelValues("other", successOutcome)))
+			other.AssertExpectations(t)
+			mockTimeTracker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestParseBatchParserMetrics(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{{TransactionUUID: "1"}, {TransactionUUID: "2"}, {TransactionUUID: "3"}}
+	batch := make([]EventWithTime, 0, len(events))
+	for _, event := range events {
+		batch = append(batch, EventWithTime{Event: event, BeginTime: time.Now()})
+	}
+
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything).Times(len(events))
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "testParserLatency",
+		Help: "testParserLatency",
+	}, []string{parserLabel})
+	parsed := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testParserEvents",
+		Help: "testParserEvents",
+	}, []string{parserLabel, outcomeLabel})
+
+	batchParser := new(mockBatchParser)
+	batchParser.On("Name").Return("batch")
+	batchParser.On("ParseBatch", events).Once()
+	parser := new(mockParser)
+	parser.On("Name").Return("parser")
+	parser.On("Parse", mock.Anything).Times(len(events))
+
+	queue := EventQueue{
+		parsers:     []Parser{batchParser, parser},
+		logger:      zap.NewNop(),
+		ctx:         context.Background(),
+		workers:     semaphore.New(1),
+		metrics:     Measures{ParserLatency: latency, ParserEventsCount: parsed},
+		timeTracker: mockTimeTracker,
+	}
+
+	queue.workers.Acquire()
+	queue.ParseBatch(batch)
+	batchParser.AssertExpectations(t)
+	parser.AssertExpectations(t)
+	assert.Equal(3.0, testutil.ToFloat64(parsed.WithLabelValues("batch", successOutcome)))
+	assert.Equal(3.0, testutil.ToFloat64(parsed.WithLabelValues("parser", successOutcome)))
+
+	expectedCounts := map[string]uint64{"batch": 1, "parser": 3}
+	for name, expectedCount := range expectedCounts {
+		metric := &dto.Metric{}
+		assert.Nil(latency.With(prometheus.Labels{parserLabel: name}).(prometheus.Metric).Write(metric))
+		assert.Equal(expectedCount, metric.GetHistogram().GetSampleCount(), name)
+	}
+}
+
+func TestQueueLatency(t *testing.T) {
+	assert := assert.New(t)
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "testEventLatency", Help: "testEventLatency"})
+	wait := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "testQueueWait", Help: "testQueueWait"})
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything).Times(2)
+	parser := new(mockParser)
+	parser.On("Name").Return("parser").Maybe()
+	parser.On("Parse", mock.Anything).Times(2)
+
+	queue, err := newEventQueue(Config{}, []Parser{parser}, Measures{EventLatency: latency, QueueWait: wait}, mockTimeTracker, nil, zap.NewNop())
+	if !assert.Nil(err) {
+		return
+	}
+
+	begin := time.Now().Add(-time.Minute)
+	assert.Nil(queue.Queue(EventWithTime{Event: interpreter.Event{TransactionUUID: "1"}, BeginTime: begin}))
+	event := <-queue.queue
+	assert.False(event.queued.IsZero())
+
+	// events without a queued time, such as those queued before an upgrade, aren't observed waiting.
+	queue.workers.Acquire()
+	queue.ParseBatch([]EventWithTime{event, {Event: interpreter.Event{TransactionUUID: "2"}, BeginTime: begin}})
+	parser.AssertExpectations(t)
+	mockTimeTracker.AssertExpectations(t)
+
+	metric := &dto.Metric{}
+	assert.Nil(latency.Write(metric))
+	assert.Equal(uint64(2), metric.GetHistogram().GetSampleCount())
+	assert.GreaterOrEqual(metric.GetHistogram().GetSampleSum(), 2*time.Minute.Seconds())
+
+	metric = &dto.Metric{}
+	assert.Nil(wait.Write(metric))
+	assert.Equal(uint64(1), metric.GetHistogram().GetSampleCount())
+	assert.Less(metric.GetHistogram().GetSampleSum(), time.Minute.Seconds())
+}
+
+func TestParseBatchAuditLog(t *testing.T) {
+	assert := assert.New(t)
+	destination := "event:device-status/mac:112233445566/online"
+	events := []interpreter.Event{{Destination: destination, TransactionUUID: "1"}, {Destination: destination, TransactionUUID: "2"}}
+	batch := make([]EventWithTime, 0, len(events))
+	for _, event := range events {
+		batch = append(batch, EventWithTime{Event: event, BeginTime: time.Now()})
+	}
+
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything).Times(len(events))
+	batchParser := new(mockBatchParser)
+	batchParser.On("Name").Return("batch")
+	batchParser.On("ParseBatch", events).Once()
+	parser := new(mockParser)
+	parser.On("Name").Return("parser")
+	sampled, err := newSampler([]SamplingRule{{Destination: ".*", Rate: 0, Parsers: []string{"parser"}}}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	queue := EventQueue{
+		parsers:     []Parser{batchParser, parser},
+		logger:      zap.NewNop(),
+		ctx:         context.Background(),
+		workers:     semaphore.New(1),
+		timeTracker: mockTimeTracker,
+		sampler:     sampled,
+		auditLog:    NewAuditLog(AuditLogConfig{Enabled: true}),
+	}
+
+	queue.workers.Acquire()
+	queue.ParseBatch(batch)
+	batchParser.AssertExpectations(t)
+	parser.AssertNotCalled(t, "Parse", mock.Anything)
+
+	audited := queue.auditLog.Events("mac:112233445566")
+	if !assert.Len(audited, 2) {
+		return
+	}
+	for i, event := range audited {
+		assert.Equal(events[i], event.Event)
+		assert.Equal(map[string]string{"batch": successOutcome, "parser": skippedOutcome}, event.Outcomes)
+	}
+}
+
+// blockingParser blocks until its context is done.
+type blockingParser struct{}
+
+func (blockingParser) Parse(ctx context.Context, _ interpreter.Event) {
+	<-ctx.Done()
+}
+
+func (blockingParser) Name() string { return "blocking" }
+
+type noopParser struct{}
+
+func (noopParser) Parse(context.Context, interpreter.Event) {}
+
+func (noopParser) Name() string { return "noop" }
+
+func BenchmarkParseEvents(b *testing.B) {
+	event := interpreter.Event{
+		Destination: "event:device-status/mac:112233445566/online",
+		PartnerIDs:  []string{"test1"},
+	}
+
+	for _, batchSize := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("BatchSize%d", batchSize), func(b *testing.B) {
+			queue, err := newEventQueue(
+				Config{QueueSize: 1000, MaxWorkers: 10, BatchSize: batchSize},
+				[]Parser{noopParser{}},
+				Measures{
+					EventsCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+						Name: "testEventsCount",
+						Help: "testEventsCount",
+					}, []string{partnerIDLabel, eventDestLabel}),
+				},
+				&timeTracker{TimeInMemory: prometheus.NewHistogram(prometheus.HistogramOpts{
+					Name: "testTimeInMemory",
+					Help: "testTimeInMemory",
+				})},
+				nil,
+				zap.NewNop(),
+			)
+			assert.Nil(b, err)
+
+			b.ResetTimer()
+			queue.Start()
+			for i := 0; i < b.N; i++ {
+				queue.queue <- EventWithTime{Event: event, BeginTime: time.Now()}
+			}
+			queue.Stop()
+			// wait for all workers to finish.
+			for i := 0; i < queue.config.MaxWorkers; i++ {
+				queue.workers.Acquire()
+			}
+		})
+	}
+}
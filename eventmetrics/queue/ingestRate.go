@@ -0,0 +1,113 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultIngestRateAlpha = 0.2
+)
+
+// IngestRateConfig configures the smoothed ingest rate gauge.
+type IngestRateConfig struct {
+	// Interval is how often the ingest rate gauge is updated.  If this is 0, the gauge is not updated.
+	Interval time.Duration
+
+	// Alpha is the smoothing factor of the exponentially weighted moving average, between 0 and 1.
+	// Higher values weigh recent ticks more heavily.  Defaults to 0.2.
+	Alpha float64
+}
+
+// ingestRate tracks an exponentially weighted moving average of the number of events enqueued per second.
+type ingestRate struct {
+	count    int64
+	rate     float64
+	alpha    float64
+	interval time.Duration
+	gauge    prometheus.Gauge
+
+	initialized bool
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+func newIngestRate(config IngestRateConfig, gauge prometheus.Gauge) *ingestRate {
+	if config.Interval <= 0 || gauge == nil {
+		return nil
+	}
+
+	if config.Alpha <= 0 || config.Alpha > 1 {
+		config.Alpha = defaultIngestRateAlpha
+	}
+
+	return &ingestRate{
+		alpha:    config.Alpha,
+		interval: config.Interval,
+		gauge:    gauge,
+	}
+}
+
+// Mark records that an event has been enqueued.
+func (r *ingestRate) Mark() {
+	atomic.AddInt64(&r.count, 1)
+}
+
+// tick folds the events counted since the last tick into the moving average and updates the gauge.
+func (r *ingestRate) tick() {
+	count := atomic.SwapInt64(&r.count, 0)
+	current := float64(count) / r.interval.Seconds()
+	if r.initialized {
+		r.rate += r.alpha * (current - r.rate)
+	} else {
+		r.rate = current
+		r.initialized = true
+	}
+
+	r.gauge.Set(r.rate)
+}
+
+func (r *ingestRate) Start() {
+	r.done = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.tick()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+func (r *ingestRate) Stop() {
+	if r.done != nil {
+		close(r.done)
+		r.wg.Wait()
+	}
+}
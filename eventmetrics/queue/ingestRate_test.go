@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIngestRate(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testIngestRate", Help: "testIngestRate"})
+	tests := []struct {
+		description   string
+		config        IngestRateConfig
+		gauge         prometheus.Gauge
+		expectedNil   bool
+		expectedAlpha float64
+	}{
+		{
+			description: "Disabled",
+			config:      IngestRateConfig{},
+			gauge:       gauge,
+			expectedNil: true,
+		},
+		{
+			description: "Nil gauge",
+			config:      IngestRateConfig{Interval: time.Second},
+			expectedNil: true,
+		},
+		{
+			description:   "Default alpha",
+			config:        IngestRateConfig{Interval: time.Second},
+			gauge:         gauge,
+			expectedAlpha: defaultIngestRateAlpha,
+		},
+		{
+			description:   "Custom alpha",
+			config:        IngestRateConfig{Interval: time.Second, Alpha: 0.5},
+			gauge:         gauge,
+			expectedAlpha: 0.5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			r := newIngestRate(tc.config, tc.gauge)
+			if tc.expectedNil {
+				assert.Nil(r)
+				return
+			}
+			assert.Equal(tc.expectedAlpha, r.alpha)
+			assert.Equal(tc.config.Interval, r.interval)
+		})
+	}
+}
+
+func TestIngestRateSteady(t *testing.T) {
+	assert := assert.New(t)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testIngestRate", Help: "testIngestRate"})
+	r := newIngestRate(IngestRateConfig{Interval: 2 * time.Second, Alpha: 0.3}, gauge)
+
+	// a burst before the steady state should decay away
+	for i := 0; i < 100; i++ {
+		r.Mark()
+	}
+	r.tick()
+	assert.Equal(50.0, testutil.ToFloat64(gauge))
+
+	for tick := 0; tick < 30; tick++ {
+		for i := 0; i < 20; i++ {
+			r.Mark()
+		}
+		r.tick()
+	}
+
+	assert.InDelta(10.0, testutil.ToFloat64(gauge), 0.01)
+}
+
+func TestIngestRateStartStop(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testIngestRate", Help: "testIngestRate"})
+	r := newIngestRate(IngestRateConfig{Interval: time.Millisecond}, gauge)
+	r.Start()
+	r.Mark()
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(gauge) > 0
+	}, time.Second, time.Millisecond)
+	r.Stop()
+}
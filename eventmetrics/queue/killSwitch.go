@@ -0,0 +1,99 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	killSwitchEngagedReason = "killSwitchEngaged"
+)
+
+// KillSwitchConfig configures the kill switch that stops events from being parsed.
+type KillSwitchConfig struct {
+	// Engaged determines whether the kill switch is engaged at startup.
+	Engaged bool
+
+	// EnableEndpoint turns on the endpoint used to view and toggle the kill switch.
+	EnableEndpoint bool
+
+	// DisabledParsers are the names of the parsers disabled at startup.
+	DisabledParsers []string
+}
+
+// KillSwitch stops all parsers from running while engaged.  Events continue to be drained from the
+// queue but are dropped instead of parsed.
+type KillSwitch struct {
+	engaged atomic.Bool
+
+	lock     sync.RWMutex
+	disabled map[string]bool
+}
+
+// NewKillSwitch creates a KillSwitch in the state given by the config.
+func NewKillSwitch(config KillSwitchConfig) *KillSwitch {
+	k := &KillSwitch{disabled: make(map[string]bool, len(config.DisabledParsers))}
+	k.engaged.Store(config.Engaged)
+	for _, name := range config.DisabledParsers {
+		k.disabled[name] = true
+	}
+	return k
+}
+
+// Engage stops events from being parsed.
+func (k *KillSwitch) Engage() {
+	k.engaged.Store(true)
+}
+
+// Disengage allows events to be parsed again.
+func (k *KillSwitch) Disengage() {
+	k.engaged.Store(false)
+}
+
+// Engaged returns whether the kill switch is engaged.  A nil KillSwitch is never engaged.
+func (k *KillSwitch) Engaged() bool {
+	return k != nil && k.engaged.Load()
+}
+
+// DisableParser stops the parser with the name given from parsing events.
+func (k *KillSwitch) DisableParser(name string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.disabled[name] = true
+}
+
+// EnableParser allows the parser with the name given to parse events again.
+func (k *KillSwitch) EnableParser(name string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	delete(k.disabled, name)
+}
+
+// ParserEnabled returns whether the parser with the name given is allowed to parse events.  A nil
+// KillSwitch never disables parsers.
+func (k *KillSwitch) ParserEnabled(name string) bool {
+	if k == nil {
+		return true
+	}
+
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return !k.disabled[name]
+}
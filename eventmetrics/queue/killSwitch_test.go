@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/webpa-common/v2/semaphore"
+	"go.uber.org/zap"
+)
+
+func TestKillSwitch(t *testing.T) {
+	assert := assert.New(t)
+	var nilSwitch *KillSwitch
+	assert.False(nilSwitch.Engaged())
+
+	k := NewKillSwitch(KillSwitchConfig{})
+	assert.False(k.Engaged())
+	k.Engage()
+	assert.True(k.Engaged())
+	k.Disengage()
+	assert.False(k.Engaged())
+
+	assert.True(NewKillSwitch(KillSwitchConfig{Engaged: true}).Engaged())
+}
+
+func TestKillSwitchParsers(t *testing.T) {
+	assert := assert.New(t)
+	var nilSwitch *KillSwitch
+	assert.True(nilSwitch.ParserEnabled("parser"))
+
+	k := NewKillSwitch(KillSwitchConfig{DisabledParsers: []string{"parser"}})
+	assert.False(k.ParserEnabled("parser"))
+	assert.True(k.ParserEnabled("other"))
+	k.EnableParser("parser")
+	assert.True(k.ParserEnabled("parser"))
+	k.DisableParser("other")
+	assert.False(k.ParserEnabled("other"))
+	assert.False(k.Engaged())
+}
+
+func TestParseEventDisabledParser(t *testing.T) {
+	event := EventWithTime{
+		Event:     interpreter.Event{Destination: "event:device-status/mac:112233445566/online"},
+		BeginTime: time.Now(),
+	}
+
+	enabled := new(mockParser)
+	enabled.On("Parse", mock.Anything).Return(nil)
+	enabled.On("Name").Return("enabled")
+	disabled := new(mockBatchParser)
+	disabled.On("Name").Return("disabled")
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything).Once()
+
+	queue := EventQueue{
+		parsers:     []Parser{enabled, disabled},
+		logger:      zap.NewNop(),
+		ctx:         context.Background(),
+		workers:     semaphore.New(1),
+		timeTracker: mockTimeTracker,
+		killSwitch:  NewKillSwitch(KillSwitchConfig{DisabledParsers: []string{"disabled"}}),
+	}
+
+	queue.workers.Acquire()
+	queue.ParseEvent(event)
+	enabled.AssertCalled(t, "Parse", event.Event)
+	disabled.AssertNotCalled(t, "ParseBatch", mock.Anything)
+	mockTimeTracker.AssertExpectations(t)
+}
+
+func TestParseEventKillSwitch(t *testing.T) {
+	tests := []struct {
+		description     string
+		engaged         bool
+		expectedDropped float64
+	}{
+		{
+			description:     "Engaged",
+			engaged:         true,
+			expectedDropped: 1.0,
+		},
+		{
+			description: "Disengaged",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			event := EventWithTime{
+				Event:     interpreter.Event{Destination: "event:device-status/mac:112233445566/online"},
+				BeginTime: time.Now(),
+			}
+
+			parser := new(mockParser)
+			parser.On("Parse", mock.Anything).Return(nil)
+			parser.On("Name").Return("parser")
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything).Once()
+			dropped := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testDroppedEvents",
+				Help: "testDroppedEvents",
+			}, []string{reasonLabel})
+
+			queue := EventQueue{
+				parsers:     []Parser{parser},
+				logger:      zap.NewNop(),
+				ctx:         context.Background(),
+				workers:     semaphore.New(1),
+				metrics:     Measures{DroppedEventsCount: dropped},
+				timeTracker: mockTimeTracker,
+				killSwitch:  NewKillSwitch(KillSwitchConfig{Engaged: tc.engaged}),
+			}
+
+			queue.workers.Acquire()
+			queue.ParseEvent(event)
+			assert.Equal(tc.expectedDropped, testutil.ToFloat64(dropped.WithLabelValues(killSwitchEngagedReason)))
+			if tc.engaged {
+				parser.AssertNotCalled(t, "Parse", mock.Anything)
+			} else {
+				parser.AssertCalled(t, "Parse", event.Event)
+			}
+			mockTimeTracker.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// LatencyConfig configures the histograms of how long events take to be parsed.
+type LatencyConfig struct {
+	// Buckets are the upper bounds, in s, of the buckets of event_latency_seconds, the time between an event
+	// being received and being parsed by every parser.  Defaults to 0.005s through 10s.
+	Buckets []float64
+
+	// WaitBuckets are the upper bounds, in s, of the buckets of queue_wait_seconds, the time an event waits in
+	// the queue before a worker starts parsing it.  Defaults to Buckets.
+	WaitBuckets []float64
+}
+
+// latencyOpts returns the options of the event_latency_seconds histogram.
+func latencyOpts(config LatencyConfig) prometheus.HistogramOpts {
+	buckets := config.Buckets
+	if len(buckets) == 0 {
+		buckets = defaultLatencyBuckets
+	}
+
+	return prometheus.HistogramOpts{
+		Name:    "event_latency_seconds",
+		Help:    "The amount of time between an event being received and being parsed by every parser in s",
+		Buckets: buckets,
+	}
+}
+
+// waitOpts returns the options of the queue_wait_seconds histogram.
+func waitOpts(config LatencyConfig) prometheus.HistogramOpts {
+	buckets := config.WaitBuckets
+	if len(buckets) == 0 {
+		buckets = latencyOpts(config).Buckets
+	}
+
+	return prometheus.HistogramOpts{
+		Name:    "queue_wait_seconds",
+		Help:    "The amount of time an event waits in the queue before a worker starts parsing it in s",
+		Buckets: buckets,
+	}
+}
+
+// observeSince observes the time since the given time in s, unless the observer is nil or the time is unknown.
+func observeSince(o prometheus.Observer, t time.Time) {
+	if o == nil || t.IsZero() {
+		return
+	}
+
+	o.Observe(time.Since(t).Seconds())
+}
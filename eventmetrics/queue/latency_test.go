@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyOpts(t *testing.T) {
+	tests := []struct {
+		description         string
+		config              LatencyConfig
+		expectedBuckets     []float64
+		expectedWaitBuckets []float64
+	}{
+		{
+			description:         "defaults",
+			expectedBuckets:     defaultLatencyBuckets,
+			expectedWaitBuckets: defaultLatencyBuckets,
+		},
+		{
+			description:         "wait buckets default to buckets",
+			config:              LatencyConfig{Buckets: []float64{1, 10, 60}},
+			expectedBuckets:     []float64{1, 10, 60},
+			expectedWaitBuckets: []float64{1, 10, 60},
+		},
+		{
+			description:         "custom",
+			config:              LatencyConfig{Buckets: []float64{1, 10, 60}, WaitBuckets: []float64{0.1, 1}},
+			expectedBuckets:     []float64{1, 10, 60},
+			expectedWaitBuckets: []float64{0.1, 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(tc.expectedBuckets, latencyOpts(tc.config).Buckets)
+			assert.Equal(tc.expectedWaitBuckets, waitOpts(tc.config).Buckets)
+		})
+	}
+}
+
+func TestObserveSince(t *testing.T) {
+	assert := assert.New(t)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test"})
+	assert.NotPanics(func() {
+		observeSince(nil, time.Now())
+	})
+
+	observeSince(histogram, time.Time{})
+	observeSince(histogram, time.Now().Add(-time.Second))
+	metric := &dto.Metric{}
+	assert.Nil(histogram.Write(metric))
+	assert.Equal(uint64(1), metric.GetHistogram().GetSampleCount())
+	assert.GreaterOrEqual(metric.GetHistogram().GetSampleSum(), 1.0)
+}
@@ -29,7 +29,15 @@ const (
 	partnerIDLabel  = "partner_id"
 	reasonLabel     = "reason"
 	queueFullReason = "queue_full"
+	corruptReason   = "corrupt_stored_event"
 	eventDestLabel  = "event_destination"
+	parserLabel     = "parser_type"
+	outcomeLabel    = "outcome"
+
+	successOutcome   = "success"
+	timeoutOutcome   = "timeout"
+	cancelledOutcome = "cancelled"
+	panicOutcome     = "panic"
 )
 
 // Measures contains the various queue-related metrics.
@@ -38,6 +46,41 @@ type Measures struct {
 	EventsQueueDepth   prometheus.Gauge       `name:"events_queue_depth"`
 	EventsCount        *prometheus.CounterVec `name:"events_count"`
 	DroppedEventsCount *prometheus.CounterVec `name:"dropped_events_count"`
+	IngestRate         prometheus.Gauge       `name:"ingest_rate"`
+
+	// PriorityQueueDepth is the depth of the queue of each priority, when priorities are configured.
+	PriorityQueueDepth *prometheus.GaugeVec `name:"priority_queue_depth"`
+
+	// ParserTimeoutsCount counts the parses that ran past the configured parser timeout.
+	ParserTimeoutsCount *prometheus.CounterVec `name:"parser_timeouts_count"`
+
+	// ParserPanicsCount counts the panics recovered from in the parsers.
+	ParserPanicsCount *prometheus.CounterVec `name:"parser_panics_total"`
+
+	// ParserLatency observes how long each parser takes to parse an event, or a batch of events.
+	ParserLatency prometheus.ObserverVec `name:"parser_latency_seconds"`
+
+	// ParserEventsCount counts the events parsed by each parser, by the outcome of the parse.
+	ParserEventsCount *prometheus.CounterVec `name:"parser_events_count"`
+
+	// EventLatency observes the time between events being received and parsed, and QueueWait the time
+	// events wait in the queue for a worker.
+	EventLatency prometheus.Observer `name:"event_latency_seconds"`
+	QueueWait    prometheus.Observer `name:"queue_wait_seconds"`
+
+	// WorkerCount and WorkerScalingEventsCount track the workers when autoscaling is enabled.
+	WorkerCount              prometheus.Gauge       `name:"queue_workers" optional:"true"`
+	WorkerScalingEventsCount *prometheus.CounterVec `name:"queue_worker_scaling_events_count" optional:"true"`
+
+	// ThrottledEventsCount counts the events rejected while the queue is past its high-water mark.
+	ThrottledEventsCount prometheus.Counter `name:"throttled_events_count" optional:"true"`
+
+	// SampledEventsCount counts the events matching sampling rules, by rule and whether they were sampled or
+	// skipped.
+	SampledEventsCount *prometheus.CounterVec `name:"sampled_events_count" optional:"true"`
+
+	// RoutedEventsCount counts the events routed to each parser, when routing is enabled.
+	RoutedEventsCount *prometheus.CounterVec `name:"routed_events_count" optional:"true"`
 }
 
 type TimeTrackIn struct {
@@ -69,6 +112,142 @@ func ProvideMetrics() fx.Option {
 			},
 			reasonLabel,
 		),
+		touchstone.Gauge(
+			prometheus.GaugeOpts{
+				Name: "ingest_rate",
+				Help: "The smoothed rate of events enqueued per second",
+			},
+		),
+		touchstone.GaugeVec(
+			prometheus.GaugeOpts{
+				Name: "priority_queue_depth",
+				Help: "The depth of the event queue of each priority",
+			},
+			priorityLabel,
+		),
+		touchstone.CounterVec(
+			prometheus.CounterOpts{
+				Name: "parser_timeouts_count",
+				Help: "The total number of parses that ran past the parser timeout",
+			},
+			parserLabel,
+		),
+		touchstone.CounterVec(
+			prometheus.CounterOpts{
+				Name: "parser_panics_total",
+				Help: "The total number of panics recovered from in the parsers",
+			},
+			parserLabel,
+		),
+		touchstone.HistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "parser_latency_seconds",
+				Help:    "The amount of time each parser takes to parse an event, or a batch of events, in s",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			},
+			parserLabel,
+		),
+		touchstone.CounterVec(
+			prometheus.CounterOpts{
+				Name: "parser_events_count",
+				Help: "The total number of events parsed by each parser, labeled by the outcome of the parse",
+			},
+			parserLabel,
+			outcomeLabel,
+		),
+		fx.Provide(
+			fx.Annotated{
+				Name: "event_latency_seconds",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Observer, error) {
+					return f.NewHistogram(latencyOpts(config.Latency))
+				},
+			},
+			fx.Annotated{
+				Name: "queue_wait_seconds",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Observer, error) {
+					return f.NewHistogram(waitOpts(config.Latency))
+				},
+			},
+			fx.Annotated{
+				Name: "queue_workers",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Gauge, error) {
+					if !config.Autoscale.Enabled {
+						return nil, nil
+					}
+
+					return f.NewGauge(
+						prometheus.GaugeOpts{
+							Name: "queue_workers",
+							Help: "The current number of workers parsing events",
+						},
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "queue_worker_scaling_events_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.Autoscale.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "queue_worker_scaling_events_count",
+							Help: "Number of times workers were added or removed, labeled by direction",
+						},
+						directionLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "throttled_events_count",
+				Target: func(f *touchstone.Factory, config Config) (prometheus.Counter, error) {
+					if !config.Backpressure.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounter(
+						prometheus.CounterOpts{
+							Name: "throttled_events_count",
+							Help: "Number of events rejected with a 429 because the queue was past its high-water mark",
+						},
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "sampled_events_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if len(config.Sampling) == 0 {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "sampled_events_count",
+							Help: "Number of events matching sampling rules, labeled by rule and whether they were sampled or skipped",
+						},
+						ruleLabel,
+						samplingLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "routed_events_count",
+				Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+					if !config.Routing.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "routed_events_count",
+							Help: "Number of events routed to each parser",
+						},
+						parserLabel,
+					)
+				},
+			},
+		),
 		touchstone.Histogram(
 			prometheus.HistogramOpts{
 				Name:    "time_in_memory",
@@ -0,0 +1,150 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Middleware wraps a Parser, layering a cross-cutting concern such as tracing, panic recovery, timing, or event
+// filtering onto the parser without modifying it.
+type Middleware func(Parser) Parser
+
+// ParseFunc parses the events given to a parser, which are the single event given to Parse or the batch given to
+// ParseBatch.
+type ParseFunc func(context.Context, []interpreter.Event)
+
+// MiddlewaresIn provides the middlewares wrapping every parser given to the queue.
+type MiddlewaresIn struct {
+	fx.In
+	Middlewares []Middleware `group:"parser_middlewares"`
+}
+
+// AroundParse creates a Middleware that calls around in place of each of the parser's Parse and ParseBatch calls.
+// around is given the wrapped parser, the events to parse, and next, which parses the events given to it with the
+// wrapped parser, so that around can act before and after the events are parsed or change the events parsed.
+// Parsers implementing BatchParser are still BatchParsers once wrapped.
+func AroundParse(around func(ctx context.Context, p Parser, events []interpreter.Event, next ParseFunc)) Middleware {
+	return func(p Parser) Parser {
+		w := wrappedParser{Parser: p, around: around}
+		if batchParser, ok := p.(BatchParser); ok {
+			return wrappedBatchParser{wrappedParser: w, batchParser: batchParser}
+		}
+
+		return w
+	}
+}
+
+// ApplyMiddlewares wraps the parser with the middlewares, the first of which is the outermost.
+func ApplyMiddlewares(p Parser, middlewares ...Middleware) Parser {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		p = middlewares[i](p)
+	}
+
+	return p
+}
+
+// Recovery is a Middleware that recovers from panics in the parser, logging the panic along with its stack trace
+// and counting it in panics, if it isn't nil, so that the worker parsing the events keeps going.  The queue
+// already recovers from panics in the parsers it is given, so Recovery is for parsers used on their own, or for
+// recovering within another middleware.
+func Recovery(panics *prometheus.CounterVec, logger *zap.Logger) Middleware {
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	return AroundParse(func(ctx context.Context, p Parser, events []interpreter.Event, next ParseFunc) {
+		defer func() {
+			if r := recover(); r != nil {
+				parserPanicked(p, r, panics, logger)
+			}
+		}()
+
+		next(ctx, events)
+	})
+}
+
+// parserPanicked logs the panic recovered from the parser along with the stack trace, counting it in panics if it
+// isn't nil.
+func parserPanicked(p Parser, r interface{}, panics *prometheus.CounterVec, logger *zap.Logger) {
+	logger.Error("recovered from parser panic", zap.String("parser", p.Name()), zap.Any("panic", r), zap.Stack("stack"))
+	if panics != nil {
+		panics.With(prometheus.Labels{parserLabel: p.Name()}).Add(1.0)
+	}
+}
+
+// Latency is a Middleware that observes how long each of the parser's Parse and ParseBatch calls take in s,
+// labeled by the parser.  The queue already observes how long the parsers it is given take, including the time
+// taken by their middlewares, so Latency is for timing parsers used on their own, or within other middlewares.
+func Latency(histogram prometheus.ObserverVec) Middleware {
+	return AroundParse(func(ctx context.Context, p Parser, events []interpreter.Event, next ParseFunc) {
+		begin := time.Now()
+		defer func() {
+			histogram.With(prometheus.Labels{parserLabel: p.Name()}).Observe(time.Since(begin).Seconds())
+		}()
+
+		next(ctx, events)
+	})
+}
+
+// wrapParsers wraps each of the parsers with the middlewares.
+func wrapParsers(parsers []Parser, middlewares []Middleware) []Parser {
+	if len(middlewares) == 0 {
+		return parsers
+	}
+
+	wrapped := make([]Parser, len(parsers))
+	for i, p := range parsers {
+		wrapped[i] = ApplyMiddlewares(p, middlewares...)
+	}
+
+	return wrapped
+}
+
+type wrappedParser struct {
+	Parser
+	around func(context.Context, Parser, []interpreter.Event, ParseFunc)
+}
+
+func (w wrappedParser) Parse(ctx context.Context, event interpreter.Event) {
+	w.around(ctx, w.Parser, []interpreter.Event{event}, func(ctx context.Context, events []interpreter.Event) {
+		for _, event := range events {
+			w.Parser.Parse(ctx, event)
+		}
+	})
+}
+
+type wrappedBatchParser struct {
+	wrappedParser
+	batchParser BatchParser
+}
+
+func (w wrappedBatchParser) ParseBatch(ctx context.Context, events []interpreter.Event) {
+	w.around(ctx, w.Parser, events, func(ctx context.Context, events []interpreter.Event) {
+		// like the queue, empty batches aren't given to the parser.
+		if len(events) > 0 {
+			w.batchParser.ParseBatch(ctx, events)
+		}
+	})
+}
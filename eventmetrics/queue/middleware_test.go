@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+func TestAroundParse(t *testing.T) {
+	tests := []struct {
+		description   string
+		batch         bool
+		filter        bool
+		expectedBatch bool
+	}{
+		{
+			description: "parser",
+		},
+		{
+			description:   "batch parser",
+			batch:         true,
+			expectedBatch: true,
+		},
+		{
+			description: "filtered",
+			filter:      true,
+		},
+		{
+			description:   "filtered batch",
+			batch:         true,
+			filter:        true,
+			expectedBatch: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			events := []interpreter.Event{{TransactionUUID: "1"}, {TransactionUUID: "2"}}
+			var p Parser
+			mp := new(mockBatchParser)
+			mp.On("Name").Return("test")
+			p = mp
+			if !tc.batch {
+				p = &mp.mockParser
+			}
+
+			var around [][]interpreter.Event
+			middleware := AroundParse(func(ctx context.Context, p Parser, events []interpreter.Event, next ParseFunc) {
+				assert.Equal("test", p.Name())
+				around = append(around, events)
+				if tc.filter {
+					events = events[:0]
+				}
+				next(ctx, events)
+			})
+
+			wrapped := middleware(p)
+			assert.Equal("test", wrapped.Name())
+			batchParser, ok := wrapped.(BatchParser)
+			assert.Equal(tc.expectedBatch, ok)
+
+			if !tc.filter {
+				mp.On("Parse", events[0]).Once()
+				mp.On("ParseBatch", events).Maybe()
+			}
+
+			wrapped.Parse(context.Background(), events[0])
+			if ok {
+				batchParser.ParseBatch(context.Background(), events)
+			}
+
+			expectedAround := [][]interpreter.Event{{events[0]}}
+			if ok {
+				expectedAround = append(expectedAround, events)
+			}
+			assert.Equal(expectedAround, around)
+			mp.AssertExpectations(t)
+			if tc.filter {
+				mp.AssertNotCalled(t, "Parse", mock.Anything)
+				mp.AssertNotCalled(t, "ParseBatch", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestApplyMiddlewares(t *testing.T) {
+	assert := assert.New(t)
+	var calls []string
+	middleware := func(name string) Middleware {
+		return AroundParse(func(ctx context.Context, _ Parser, events []interpreter.Event, next ParseFunc) {
+			calls = append(calls, name)
+			next(ctx, events)
+		})
+	}
+
+	mp := new(mockParser)
+	mp.On("Parse", mock.Anything).Run(func(_ mock.Arguments) {
+		calls = append(calls, "parser")
+	})
+
+	p := ApplyMiddlewares(mp, middleware("first"), middleware("second"))
+	p.Parse(context.Background(), interpreter.Event{})
+	assert.Equal([]string{"first", "second", "parser"}, calls)
+
+	assert.Equal(mp, ApplyMiddlewares(mp))
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		description string
+		batch       bool
+	}{
+		{
+			description: "parse",
+		},
+		{
+			description: "parse batch",
+			batch:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			mp := new(mockBatchParser)
+			mp.On("Name").Return("test")
+			mp.On("Parse", mock.Anything).Run(func(_ mock.Arguments) {
+				panic("parse failed")
+			})
+			mp.On("ParseBatch", mock.Anything).Run(func(_ mock.Arguments) {
+				panic("parse batch failed")
+			})
+
+			panics := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_panics"}, []string{parserLabel})
+			p := Recovery(panics, zap.NewNop())(mp)
+			assert.NotPanics(func() {
+				if tc.batch {
+					p.(BatchParser).ParseBatch(context.Background(), []interpreter.Event{{}})
+				} else {
+					p.Parse(context.Background(), interpreter.Event{})
+				}
+			})
+			assert.Equal(1.0, testutil.ToFloat64(panics.With(prometheus.Labels{parserLabel: "test"})))
+		})
+	}
+}
+
+func TestLatency(t *testing.T) {
+	assert := assert.New(t)
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_latency"}, []string{parserLabel})
+	mp := new(mockBatchParser)
+	mp.On("Name").Return("test")
+	mp.On("Parse", mock.Anything)
+	mp.On("ParseBatch", mock.Anything).Run(func(_ mock.Arguments) {
+		panic("parse batch failed")
+	})
+
+	p := ApplyMiddlewares(mp, Recovery(nil, nil), Latency(histogram))
+	p.Parse(context.Background(), interpreter.Event{})
+	p.(BatchParser).ParseBatch(context.Background(), []interpreter.Event{{}})
+	assert.Equal(1, testutil.CollectAndCount(histogram))
+	metric := &dto.Metric{}
+	assert.Nil(histogram.With(prometheus.Labels{parserLabel: "test"}).(prometheus.Metric).Write(metric))
+	assert.Equal(uint64(2), metric.GetHistogram().GetSampleCount())
+}
+
+func TestWrapParsers(t *testing.T) {
+	assert := assert.New(t)
+	parsers := []Parser{new(mockParser), new(mockBatchParser)}
+	assert.Equal(parsers, wrapParsers(parsers, nil))
+
+	wrapped := wrapParsers(parsers, []Middleware{Recovery(nil, nil)})
+	if !assert.Len(wrapped, 2) {
+		return
+	}
+	assert.IsType(wrappedParser{}, wrapped[0])
+	assert.IsType(wrappedBatchParser{}, wrapped[1])
+}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -11,7 +12,7 @@ type mockParser struct {
 	mock.Mock
 }
 
-func (mp *mockParser) Parse(event interpreter.Event) {
+func (mp *mockParser) Parse(_ context.Context, event interpreter.Event) {
 	mp.Called(event)
 }
 
@@ -27,3 +28,11 @@ type mockTimeTracker struct {
 func (m *mockTimeTracker) TrackTime(length time.Duration) {
 	m.Called(length)
 }
+
+type mockBatchParser struct {
+	mockParser
+}
+
+func (mp *mockBatchParser) ParseBatch(_ context.Context, events []interpreter.Event) {
+	mp.Called(events)
+}
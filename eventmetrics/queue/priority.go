@@ -0,0 +1,206 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	priorityLabel   = "priority"
+	defaultPriority = 0
+)
+
+var (
+	errInvalidPriority = errors.New("invalid priority")
+)
+
+// PriorityConfig assigns a priority to the events with matching destinations.
+type PriorityConfig struct {
+	// Destination is the regular expression matched against the destinations of events.
+	Destination string
+
+	// Priority is the priority of the matching events.  It must be above 0, the priority of events not
+	// matching any destination.
+	Priority int
+}
+
+type lane struct {
+	priority string
+	regexes  []*regexp.Regexp
+	queue    chan EventWithTime
+}
+
+// lanes holds queued events in a lane per priority, so that events with higher priorities are taken from
+// the queue first.  Each event added to a lane is followed by a token in ready, so that waiting on ready
+// waits on all of the lanes.
+type lanes struct {
+	lanes []*lane
+	ready chan struct{}
+	depth *prometheus.GaugeVec
+}
+
+// newLanes creates a lane for each priority configured, along with a lane for events of the default
+// priority.  Each lane holds up to size events.
+func newLanes(configs []PriorityConfig, size int, depth *prometheus.GaugeVec) (*lanes, error) {
+	byPriority := make(map[int]*lane)
+	for _, config := range configs {
+		if config.Priority <= defaultPriority {
+			return nil, fmt.Errorf("%w: priority of destination %q must be above %d", errInvalidPriority, config.Destination, defaultPriority)
+		}
+
+		regex, err := regexp.Compile(config.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid destination regex %q: %v", errInvalidPriority, config.Destination, err)
+		}
+
+		l, found := byPriority[config.Priority]
+		if !found {
+			l = &lane{priority: strconv.Itoa(config.Priority), queue: make(chan EventWithTime, size)}
+			byPriority[config.Priority] = l
+		}
+		l.regexes = append(l.regexes, regex)
+	}
+
+	priorities := make([]int, 0, len(byPriority))
+	for priority := range byPriority {
+		priorities = append(priorities, priority)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	l := &lanes{
+		lanes: make([]*lane, 0, len(priorities)+1),
+		ready: make(chan struct{}, size*(len(priorities)+1)),
+		depth: depth,
+	}
+
+	for _, priority := range priorities {
+		l.lanes = append(l.lanes, byPriority[priority])
+	}
+	l.lanes = append(l.lanes, &lane{priority: strconv.Itoa(defaultPriority), queue: make(chan EventWithTime, size)})
+	return l, nil
+}
+
+// laneFor returns the lane of the highest priority with a destination matching the event.
+func (l *lanes) laneFor(e interpreter.Event) *lane {
+	for _, ln := range l.lanes {
+		for _, regex := range ln.regexes {
+			if regex.MatchString(e.Destination) {
+				return ln
+			}
+		}
+	}
+
+	return l.lanes[len(l.lanes)-1]
+}
+
+// tryPush adds the event to its lane, returning false if the lane is full.
+func (l *lanes) tryPush(e EventWithTime) bool {
+	ln := l.laneFor(e.Event)
+	select {
+	case ln.queue <- e:
+		l.added(ln)
+		return true
+	default:
+		return false
+	}
+}
+
+// push adds the event to its lane, waiting for room in the lane until stop is closed.
+func (l *lanes) push(e EventWithTime, stop <-chan struct{}) bool {
+	ln := l.laneFor(e.Event)
+	select {
+	case ln.queue <- e:
+		l.added(ln)
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+func (l *lanes) added(ln *lane) {
+	if l.depth != nil {
+		l.depth.With(prometheus.Labels{priorityLabel: ln.priority}).Add(1.0)
+	}
+	l.ready <- struct{}{}
+}
+
+// pop waits for an event and takes it from the lane of the highest priority with events waiting.  It returns
+// false once the lanes are closed and empty.
+func (l *lanes) pop() (EventWithTime, bool) {
+	if _, ok := <-l.ready; !ok {
+		return EventWithTime{}, false
+	}
+
+	return l.take(), true
+}
+
+// tryPop takes an event like pop, but returns false instead of waiting if no events are waiting.
+func (l *lanes) tryPop() (EventWithTime, bool) {
+	select {
+	case _, ok := <-l.ready:
+		if !ok {
+			return EventWithTime{}, false
+		}
+		return l.take(), true
+	default:
+		return EventWithTime{}, false
+	}
+}
+
+// take takes an event from the lane of the highest priority with events waiting.  It must only be called
+// after taking a token from ready, which guarantees one of the lanes has an event.
+func (l *lanes) take() EventWithTime {
+	for {
+		for _, ln := range l.lanes {
+			select {
+			case e := <-ln.queue:
+				if l.depth != nil {
+					l.depth.With(prometheus.Labels{priorityLabel: ln.priority}).Add(-1.0)
+				}
+				return e
+			default:
+			}
+		}
+	}
+}
+
+// fill returns the number of events waiting in the fullest lane as a fraction of the lane's size, as events are
+// rejected once their lane is full no matter how empty the other lanes are.
+func (l *lanes) fill() float64 {
+	var fill float64
+	for _, ln := range l.lanes {
+		if f := float64(len(ln.queue)) / float64(cap(ln.queue)); f > fill {
+			fill = f
+		}
+	}
+
+	return fill
+}
+
+// close stops the lanes, after which the events still waiting can be taken.
+func (l *lanes) close() {
+	close(l.ready)
+}
@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+func TestNewLanesErrors(t *testing.T) {
+	tests := []struct {
+		description string
+		configs     []PriorityConfig
+	}{
+		{
+			description: "zero priority",
+			configs:     []PriorityConfig{{Destination: ".*/online$", Priority: 0}},
+		},
+		{
+			description: "negative priority",
+			configs:     []PriorityConfig{{Destination: ".*/online$", Priority: -1}},
+		},
+		{
+			description: "invalid regex",
+			configs:     []PriorityConfig{{Destination: "[", Priority: 1}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			l, err := newLanes(tc.configs, 5, nil)
+			assert.Nil(t, l)
+			assert.True(t, errors.Is(err, errInvalidPriority))
+		})
+	}
+}
+
+func TestLanes(t *testing.T) {
+	assert := assert.New(t)
+	depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "testPriorityDepth",
+		Help: "testPriorityDepth",
+	}, []string{priorityLabel})
+	l, err := newLanes([]PriorityConfig{
+		{Destination: ".*/reboot-pending$", Priority: 1},
+		{Destination: ".*/fully-manageable$", Priority: 2},
+		{Destination: ".*/operational$", Priority: 1},
+	}, 2, depth)
+	assert.Nil(err)
+
+	for _, id := range []string{"online", "reboot-pending", "operational", "fully-manageable", "offline"} {
+		assert.True(l.tryPush(EventWithTime{Event: interpreter.Event{Destination: "event:device-status/mac:112233445566/" + id}}))
+	}
+	// the default lane already holds two events.
+	assert.False(l.tryPush(EventWithTime{Event: interpreter.Event{Destination: "event:device-status/mac:112233445566/offline"}}))
+
+	assert.Equal(1.0, l.fill())
+	assert.Equal(1.0, testutil.ToFloat64(depth.WithLabelValues("2")))
+	assert.Equal(2.0, testutil.ToFloat64(depth.WithLabelValues("1")))
+	assert.Equal(2.0, testutil.ToFloat64(depth.WithLabelValues("0")))
+
+	l.close()
+	var popped []string
+	for {
+		event, ok := l.pop()
+		if !ok {
+			break
+		}
+		popped = append(popped, event.Event.Destination)
+	}
+
+	assert.Equal([]string{
+		"event:device-status/mac:112233445566/fully-manageable",
+		"event:device-status/mac:112233445566/reboot-pending",
+		"event:device-status/mac:112233445566/operational",
+		"event:device-status/mac:112233445566/online",
+		"event:device-status/mac:112233445566/offline",
+	}, popped)
+	for _, priority := range []string{"0", "1", "2"} {
+		assert.Equal(0.0, testutil.ToFloat64(depth.WithLabelValues(priority)))
+	}
+
+	_, ok := l.tryPop()
+	assert.False(ok)
+	assert.Equal(0.0, l.fill())
+}
+
+func TestQueuePriorities(t *testing.T) {
+	assert := assert.New(t)
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	queue, err := newEventQueue(Config{
+		BatchSize:  10,
+		Priorities: []PriorityConfig{{Destination: ".*/fully-manageable$", Priority: 1}},
+	}, []Parser{new(mockParser)}, Measures{}, mockTimeTracker, nil, zap.NewNop())
+	assert.Nil(err)
+
+	assert.Nil(queue.Queue(EventWithTime{Event: interpreter.Event{Destination: "event:device-status/mac:112233445566/online"}, BeginTime: time.Now()}))
+	assert.Nil(queue.Queue(EventWithTime{Event: interpreter.Event{Destination: "event:device-status/mac:112233445566/fully-manageable"}, BeginTime: time.Now()}))
+
+	first, ok := queue.dequeue()
+	assert.True(ok)
+	batch := queue.nextBatch(first)
+	if assert.Len(batch, 2) {
+		assert.Equal("event:device-status/mac:112233445566/fully-manageable", batch[0].Event.Destination)
+		assert.Equal("event:device-status/mac:112233445566/online", batch[1].Event.Destination)
+	}
+
+	_, err = newEventQueue(Config{
+		Priorities: []PriorityConfig{{Destination: ".*", Priority: 0}},
+	}, []Parser{new(mockParser)}, Measures{}, mockTimeTracker, nil, zap.NewNop())
+	assert.True(errors.Is(err, errInvalidPriority))
+}
+
+func TestQueuePrioritiesBackpressure(t *testing.T) {
+	assert := assert.New(t)
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything)
+	queue, err := newEventQueue(Config{
+		QueueSize:    10,
+		Priorities:   []PriorityConfig{{Destination: ".*/fully-manageable$", Priority: 1}},
+		Backpressure: BackpressureConfig{Enabled: true, HighWaterMark: 0.8, LowWaterMark: 0.5},
+	}, []Parser{new(mockParser)}, Measures{}, mockTimeTracker, nil, zap.NewNop())
+	assert.Nil(err)
+
+	online := EventWithTime{Event: interpreter.Event{Destination: "event:device-status/mac:112233445566/online"}, BeginTime: time.Now()}
+	for i := 0; i < 8; i++ {
+		assert.Nil(queue.Queue(online))
+	}
+
+	// the default lane is past the high-water mark, even though the queue as a whole is less than half full.
+	assert.Equal(0.8, queue.depth())
+	var tooMany TooManyRequestsErr
+	assert.True(errors.As(queue.Queue(online), &tooMany))
+	assert.Equal("Queue Over High-Water Mark", tooMany.Message)
+	assert.True(errors.As(queue.Queue(EventWithTime{Event: interpreter.Event{Destination: "event:device-status/mac:112233445566/fully-manageable"}, BeginTime: time.Now()}), &tooMany))
+
+	// throttling stops once the fullest lane drains below the low-water mark.
+	for i := 0; i < 4; i++ {
+		_, ok := queue.dequeue()
+		assert.True(ok)
+	}
+	assert.Equal(0.4, queue.depth())
+	assert.Nil(queue.Queue(online))
+}
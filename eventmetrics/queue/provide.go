@@ -21,6 +21,7 @@ import (
 	"context"
 
 	"github.com/xmidt-org/arrange"
+	"github.com/xmidt-org/candlelight"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
@@ -45,13 +46,30 @@ func Provide() fx.Option {
 				TimeInMemory: in.TimeInMemory,
 			}
 		},
-		func(config Config, lc fx.Lifecycle, parsersIn ParsersIn, metrics Measures, tracker TimeTracker, logger *zap.Logger) (Queue, error) {
-			e, err := newEventQueue(config, parsersIn.Parsers, metrics, tracker, logger)
+		func(config Config) *KillSwitch {
+			return NewKillSwitch(config.KillSwitch)
+		},
+		func(config Config) *AuditLog {
+			return NewAuditLog(config.AuditLog)
+		},
+		func(config Config, lc fx.Lifecycle, parsersIn ParsersIn, middlewaresIn MiddlewaresIn, metrics Measures, tracker TimeTracker, killSwitch *KillSwitch,
+			auditLog *AuditLog, tracing candlelight.Tracing, logger *zap.Logger) (Queue, error) {
+			// the routes are declared by the parsers themselves, so they are found before the parsers are wrapped.
+			router, err := newRouter(config.Routing, parsersIn.Parsers, metrics.RoutedEventsCount)
+			if err != nil {
+				return nil, err
+			}
+
+			parsers := wrapParsers(parsersIn.Parsers, middlewaresIn.Middlewares)
+			e, err := newEventQueue(config, parsers, metrics, tracker, killSwitch, logger)
 
 			if err != nil {
 				return nil, err
 			}
 
+			e.tracer = tracing.TracerProvider().Tracer(tracerName)
+			e.auditLog = auditLog
+			e.router = router
 			lc.Append(fx.Hook{
 				OnStart: func(context context.Context) error {
 					e.Start()
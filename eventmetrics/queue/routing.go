@@ -0,0 +1,147 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	// unroutedOutcome is the outcome of an event that doesn't match the parser's route.
+	unroutedOutcome = "unrouted"
+)
+
+var errInvalidRoute = errors.New("invalid route")
+
+// RoutingConfig configures dispatching events only to the parsers whose routes match them, instead of giving
+// every event to every parser.
+type RoutingConfig struct {
+	// Enabled turns on routing.  Parsers implementing EventTypeMatcher are routed the events with matching event
+	// types, and the rest of the parsers are routed every event.
+	Enabled bool
+
+	// Routes replace the routes parsers declare with destination regexes.
+	Routes []RouteConfig
+}
+
+// RouteConfig routes the events with matching destinations to a parser.
+type RouteConfig struct {
+	// Parser is the name of the parser.
+	Parser string
+
+	// Destinations are the regular expressions matched against the destinations of events.  Events matching any
+	// of them are routed to the parser.
+	Destinations []string
+}
+
+// route matches the events routed to a parser, either by destination or by event type.
+type route struct {
+	regexes     []*regexp.Regexp
+	destination bool
+}
+
+// matches returns true if the event, with the event type given, is routed to the parser.
+func (r route) matches(e interpreter.Event, eventType string) bool {
+	value := eventType
+	if r.destination {
+		value = e.Destination
+	}
+
+	for _, regex := range r.regexes {
+		if regex.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// router decides which parsers each event is routed to, counting the events routed to each parser.
+type router struct {
+	routes map[string]route
+	counts *prometheus.CounterVec
+}
+
+// newRouter creates the routing table of the parsers, returning nil if routing isn't enabled.  Parsers without a
+// route are routed every event.
+func newRouter(config RoutingConfig, parsers []Parser, counts *prometheus.CounterVec) (*router, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	r := &router{routes: make(map[string]route), counts: counts}
+	for _, p := range parsers {
+		matcher, ok := p.(EventTypeMatcher)
+		if !ok {
+			continue
+		}
+
+		regexes, err := compileRoute(p.Name(), matcher.EventTypeRegexes())
+		if err != nil {
+			return nil, err
+		}
+		r.routes[p.Name()] = route{regexes: regexes}
+	}
+
+	for _, rc := range config.Routes {
+		regexes, err := compileRoute(rc.Parser, rc.Destinations)
+		if err != nil {
+			return nil, err
+		}
+		r.routes[rc.Parser] = route{regexes: regexes, destination: true}
+	}
+
+	return r, nil
+}
+
+func compileRoute(parser string, expressions []string) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, len(expressions))
+	for i, expression := range expressions {
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid regex %q of parser %q: %v", errInvalidRoute, expression, parser, err)
+		}
+		regexes[i] = regex
+	}
+
+	return regexes, nil
+}
+
+// unrouted returns the names of the parsers the event isn't routed to.
+func (r *router) unrouted(e interpreter.Event, parsers []Parser) map[string]bool {
+	eventType, _ := e.EventType()
+	unrouted := make(map[string]bool)
+	for _, p := range parsers {
+		name := p.Name()
+		if rt, found := r.routes[name]; found && !rt.matches(e, eventType) {
+			unrouted[name] = true
+			continue
+		}
+
+		if r.counts != nil {
+			r.counts.With(prometheus.Labels{parserLabel: name}).Add(1.0)
+		}
+	}
+
+	return unrouted
+}
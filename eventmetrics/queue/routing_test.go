@@ -0,0 +1,149 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/webpa-common/v2/semaphore"
+	"go.uber.org/zap"
+)
+
+// eventTypeParser is a parser that declares the event types it parses.
+type eventTypeParser struct {
+	mockParser
+	regexes []string
+}
+
+func (p *eventTypeParser) EventTypeRegexes() []string {
+	return p.regexes
+}
+
+func TestNewRouter(t *testing.T) {
+	online := &eventTypeParser{regexes: []string{"^online$"}}
+	online.On("Name").Return("online")
+	all := new(mockParser)
+	all.On("Name").Return("all")
+	tests := []struct {
+		description      string
+		config           RoutingConfig
+		expectedNil      bool
+		expectedErr      error
+		expectedUnrouted map[string]map[string]bool
+	}{
+		{
+			description: "Disabled",
+			expectedNil: true,
+		},
+		{
+			description: "Declared routes",
+			config:      RoutingConfig{Enabled: true},
+			expectedUnrouted: map[string]map[string]bool{
+				"event:device-status/mac:112233445566/online":  {},
+				"event:device-status/mac:112233445566/offline": {"online": true},
+			},
+		},
+		{
+			description: "Configured routes",
+			config: RoutingConfig{
+				Enabled: true,
+				Routes: []RouteConfig{
+					{Parser: "online", Destinations: []string{"/offline$"}},
+					{Parser: "all", Destinations: []string{"/online$"}},
+				},
+			},
+			expectedUnrouted: map[string]map[string]bool{
+				"event:device-status/mac:112233445566/online":  {"online": true},
+				"event:device-status/mac:112233445566/offline": {"all": true},
+			},
+		},
+		{
+			description: "Invalid regex",
+			config: RoutingConfig{
+				Enabled: true,
+				Routes:  []RouteConfig{{Parser: "all", Destinations: []string{"["}}},
+			},
+			expectedErr: errInvalidRoute,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			r, err := newRouter(tc.config, []Parser{online, all}, nil)
+			if tc.expectedErr != nil {
+				assert.True(errors.Is(err, tc.expectedErr))
+				return
+			}
+
+			if !assert.Nil(err) {
+				return
+			}
+
+			if tc.expectedNil {
+				assert.Nil(r)
+				return
+			}
+
+			for destination, expected := range tc.expectedUnrouted {
+				assert.Equal(expected, r.unrouted(interpreter.Event{Destination: destination}, []Parser{online, all}), destination)
+			}
+		})
+	}
+}
+
+func TestParseBatchRouting(t *testing.T) {
+	assert := assert.New(t)
+	online := interpreter.Event{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "1"}
+	offline := interpreter.Event{Destination: "event:device-status/mac:112233445566/offline", TransactionUUID: "2"}
+	batch := []EventWithTime{{Event: online, BeginTime: time.Now()}, {Event: offline, BeginTime: time.Now()}}
+
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything).Times(len(batch))
+	batchParser := new(mockBatchParser)
+	batchParser.On("Name").Return("batch")
+	batchParser.On("ParseBatch", []interpreter.Event{offline}).Once()
+	parser := &eventTypeParser{regexes: []string{"^online$"}}
+	parser.On("Name").Return("parser")
+	parser.On("Parse", online).Once()
+
+	routed := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testRoutedCount"}, []string{parserLabel})
+	r, err := newRouter(RoutingConfig{
+		Enabled: true,
+		Routes:  []RouteConfig{{Parser: "batch", Destinations: []string{"/offline$"}}},
+	}, []Parser{batchParser, parser}, routed)
+	if !assert.Nil(err) {
+		return
+	}
+
+	queue := EventQueue{
+		parsers:     []Parser{batchParser, parser},
+		logger:      zap.NewNop(),
+		ctx:         context.Background(),
+		workers:     semaphore.New(1),
+		timeTracker: mockTimeTracker,
+		router:      r,
+		auditLog:    NewAuditLog(AuditLogConfig{Enabled: true}),
+	}
+
+	queue.workers.Acquire()
+	queue.ParseBatch(batch)
+	batchParser.AssertExpectations(t)
+	parser.AssertExpectations(t)
+	parser.AssertNotCalled(t, "Parse", offline)
+	assert.Equal(1.0, testutil.ToFloat64(routed.WithLabelValues("batch")))
+	assert.Equal(1.0, testutil.ToFloat64(routed.WithLabelValues("parser")))
+
+	audited := queue.auditLog.Events("mac:112233445566")
+	if !assert.Len(audited, 2) {
+		return
+	}
+	assert.Equal(map[string]string{"batch": unroutedOutcome, "parser": successOutcome}, audited[0].Outcomes)
+	assert.Equal(map[string]string{"batch": successOutcome, "parser": unroutedOutcome}, audited[1].Outcomes)
+}
@@ -0,0 +1,144 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	ruleLabel          = "rule"
+	samplingLabel      = "result"
+	sampledResult      = "sampled"
+	skippedResult      = "skipped"
+	samplingResolution = 10000
+)
+
+var errInvalidSamplingRule = errors.New("invalid sampling rule")
+
+// SamplingRule parses a fraction of the devices sending events with matching destinations.  Devices are chosen by
+// hashing their device ids, so a device is either parsed for all of its matching events or none of them, and
+// parsers still see complete cycles for the devices that are sampled.
+type SamplingRule struct {
+	// Destination is the regular expression matched against the destinations of events.
+	Destination string
+
+	// Rate is the fraction of devices whose matching events are parsed, between 0 and 1.
+	Rate float64
+
+	// Parsers are the names of the parsers the rule applies to.  If it is empty, the rule applies to all parsers.
+	Parsers []string
+}
+
+type samplingRule struct {
+	destination string
+	regex       *regexp.Regexp
+	threshold   uint64
+	parsers     map[string]bool
+}
+
+// appliesTo returns true if events skipped by the rule are skipped by the parser.
+func (r *samplingRule) appliesTo(parser string) bool {
+	return len(r.parsers) == 0 || r.parsers[parser]
+}
+
+// sampler decides which events are skipped by the sampling rules, counting the sampled and skipped events.
+type sampler struct {
+	rules  []*samplingRule
+	counts *prometheus.CounterVec
+}
+
+// newSampler creates a sampler of the rules, returning nil if there are no rules.
+func newSampler(rules []SamplingRule, counts *prometheus.CounterVec) (*sampler, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	s := &sampler{counts: counts}
+	for _, rule := range rules {
+		if rule.Rate < 0 || rule.Rate > 1 {
+			return nil, fmt.Errorf("%w: rate %v of destination %q must be between 0 and 1", errInvalidSamplingRule, rule.Rate, rule.Destination)
+		}
+
+		regex, err := regexp.Compile(rule.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid destination regex %q: %v", errInvalidSamplingRule, rule.Destination, err)
+		}
+
+		var parsers map[string]bool
+		if len(rule.Parsers) > 0 {
+			parsers = make(map[string]bool, len(rule.Parsers))
+			for _, parser := range rule.Parsers {
+				parsers[parser] = true
+			}
+		}
+
+		s.rules = append(s.rules, &samplingRule{
+			destination: rule.Destination,
+			regex:       regex,
+			threshold:   uint64(rule.Rate * samplingResolution),
+			parsers:     parsers,
+		})
+	}
+
+	return s, nil
+}
+
+// skippedBy returns the rule that skips the event, or nil if the event is parsed.  Only the first rule matching
+// the event's destination applies.  Events without a device id are always parsed.
+func (s *sampler) skippedBy(e interpreter.Event) *samplingRule {
+	for _, rule := range s.rules {
+		if !rule.regex.MatchString(e.Destination) {
+			continue
+		}
+
+		deviceID, err := deviceid.FromEvent(e)
+		if err != nil {
+			return nil
+		}
+
+		// every rule uses the same hash, so the devices sampled at a rate are also sampled at higher rates.
+		sampled := xxhash.Sum64String(deviceID)%samplingResolution < rule.threshold
+		s.count(rule, sampled)
+		if sampled {
+			return nil
+		}
+		return rule
+	}
+
+	return nil
+}
+
+func (s *sampler) count(rule *samplingRule, sampled bool) {
+	if s.counts == nil {
+		return
+	}
+
+	result := skippedResult
+	if sampled {
+		result = sampledResult
+	}
+	s.counts.With(prometheus.Labels{ruleLabel: rule.destination, samplingLabel: result}).Add(1.0)
+}
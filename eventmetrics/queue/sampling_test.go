@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/webpa-common/v2/semaphore"
+	"go.uber.org/zap"
+)
+
+func TestNewSampler(t *testing.T) {
+	tests := []struct {
+		description string
+		rules       []SamplingRule
+		expectedNil bool
+		expectedErr error
+	}{
+		{
+			description: "No rules",
+			expectedNil: true,
+		},
+		{
+			description: "Valid",
+			rules:       []SamplingRule{{Destination: "online", Rate: 0.1}, {Destination: "offline", Rate: 1, Parsers: []string{"metadata"}}},
+		},
+		{
+			description: "Invalid rate",
+			rules:       []SamplingRule{{Destination: "online", Rate: 1.5}},
+			expectedErr: errInvalidSamplingRule,
+		},
+		{
+			description: "Invalid regex",
+			rules:       []SamplingRule{{Destination: "[", Rate: 0.1}},
+			expectedErr: errInvalidSamplingRule,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			s, err := newSampler(tc.rules, nil)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(s)
+				return
+			}
+
+			assert.Nil(err)
+			if tc.expectedNil {
+				assert.Nil(s)
+				return
+			}
+
+			if assert.NotNil(s) {
+				assert.Len(s.rules, len(tc.rules))
+			}
+		})
+	}
+}
+
+func TestSamplerSkippedBy(t *testing.T) {
+	assert := assert.New(t)
+	counts := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testSampled"}, []string{ruleLabel, samplingLabel})
+	s, err := newSampler([]SamplingRule{
+		{Destination: "/online$", Rate: 0.5},
+		{Destination: "/offline$", Rate: 0},
+		{Destination: "/reboot-pending$", Rate: 1},
+	}, counts)
+	if !assert.Nil(err) {
+		return
+	}
+
+	skippedDevices := 0
+	for i := 0; i < 1000; i++ {
+		device := fmt.Sprintf("mac:%012x", i)
+		online := s.skippedBy(interpreter.Event{Destination: "event:device-status/" + device + "/online"})
+		// the same device is always sampled or skipped, regardless of case.
+		assert.Equal(online, s.skippedBy(interpreter.Event{Destination: "event:device-status/MAC:" + device[4:] + "/online"}))
+		if online != nil {
+			skippedDevices++
+		}
+
+		assert.NotNil(s.skippedBy(interpreter.Event{Destination: "event:device-status/" + device + "/offline"}))
+		assert.Nil(s.skippedBy(interpreter.Event{Destination: "event:device-status/" + device + "/reboot-pending"}))
+		assert.Nil(s.skippedBy(interpreter.Event{Destination: "event:device-status/" + device + "/fully-manageable"}))
+	}
+
+	assert.InDelta(500, skippedDevices, 75)
+	assert.Equal(2000.0-float64(2*skippedDevices), testutil.ToFloat64(counts.With(prometheus.Labels{ruleLabel: "/online$", samplingLabel: sampledResult})))
+	assert.Equal(1000.0, testutil.ToFloat64(counts.With(prometheus.Labels{ruleLabel: "/offline$", samplingLabel: skippedResult})))
+	assert.Equal(1000.0, testutil.ToFloat64(counts.With(prometheus.Labels{ruleLabel: "/reboot-pending$", samplingLabel: sampledResult})))
+
+	// events without a device id are always parsed.
+	assert.Nil(s.skippedBy(interpreter.Event{Destination: "bad/offline"}))
+}
+
+func TestParseBatchSampled(t *testing.T) {
+	assert := assert.New(t)
+	online := interpreter.Event{Destination: "event:device-status/mac:112233445566/online"}
+	offline := interpreter.Event{Destination: "event:device-status/mac:112233445566/offline"}
+
+	parser := new(mockParser)
+	parser.On("Name").Return("parser")
+	parser.On("Parse", offline).Once()
+	otherParser := new(mockParser)
+	otherParser.On("Name").Return("other")
+	otherParser.On("Parse", mock.Anything).Twice()
+	batchParser := new(mockBatchParser)
+	batchParser.On("Name").Return("batch")
+	batchParser.On("ParseBatch", []interpreter.Event{offline}).Once()
+
+	s, err := newSampler([]SamplingRule{{Destination: "/online$", Rate: 0, Parsers: []string{"parser", "batch"}}}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	mockTimeTracker := new(mockTimeTracker)
+	mockTimeTracker.On("TrackTime", mock.Anything).Twice()
+	q := EventQueue{
+		logger:      zap.NewNop(),
+		workers:     semaphore.New(1),
+		parsers:     []Parser{parser, otherParser, batchParser},
+		timeTracker: mockTimeTracker,
+		ctx:         context.Background(),
+		sampler:     s,
+	}
+
+	q.workers.Acquire()
+	q.ParseBatch([]EventWithTime{{Event: online, BeginTime: time.Now()}, {Event: offline, BeginTime: time.Now()}})
+	parser.AssertExpectations(t)
+	otherParser.AssertExpectations(t)
+	batchParser.AssertExpectations(t)
+	mockTimeTracker.AssertExpectations(t)
+}
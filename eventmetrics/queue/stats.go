@@ -0,0 +1,76 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+// Stats describes the current state of the queue, such as for diagnosing workers that are stuck or a queue
+// that has stalled.
+type Stats struct {
+	// Type is the queue backend, either memory or disk.
+	Type string `json:"type"`
+
+	// Queued is the number of events waiting in the queue.
+	Queued int `json:"queued"`
+
+	// Size is the number of events the queue holds.
+	Size int `json:"size"`
+
+	// MaxWorkers is the maximum number of batches of events parsed at once.
+	MaxWorkers int `json:"maxWorkers"`
+
+	// Parsing is the number of batches of events currently being parsed.
+	Parsing int64 `json:"parsing"`
+
+	// Stopped is whether the queue has stopped accepting events.
+	Stopped bool `json:"stopped"`
+
+	// Drained, Dropped, and Kept are the number of events parsed, dropped, and kept in the disk store while
+	// the queue was stopping.
+	Drained int64 `json:"drained"`
+	Dropped int64 `json:"dropped"`
+	Kept    int64 `json:"kept"`
+}
+
+// StatsReporter is implemented by queues that can describe their current state.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// Stats returns the current state of the queue.
+func (e *EventQueue) Stats() Stats {
+	queueType := memoryQueueType
+	if e.store != nil {
+		queueType = diskQueueType
+	}
+
+	e.stopLock.RLock()
+	stopped := e.stopped
+	e.stopLock.RUnlock()
+
+	queued, size := e.queued()
+	return Stats{
+		Type:       queueType,
+		Queued:     queued,
+		Size:       size,
+		MaxWorkers: e.config.MaxWorkers,
+		Parsing:    e.parsing.Load(),
+		Stopped:    stopped,
+		Drained:    e.drained.Load(),
+		Dropped:    e.dropped.Load(),
+		Kept:       e.kept.Load(),
+	}
+}
@@ -0,0 +1,31 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+	parser := new(mockParser)
+	parser.On("Name").Return("parser").Maybe()
+	queue, err := newEventQueue(Config{QueueSize: 10, MaxWorkers: 8}, []Parser{parser}, Measures{}, new(mockTimeTracker), nil, zap.NewNop())
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(queue.Queue(EventWithTime{Event: interpreter.Event{TransactionUUID: "1"}}))
+	assert.Nil(queue.Queue(EventWithTime{Event: interpreter.Event{TransactionUUID: "2"}}))
+	assert.Equal(Stats{Type: memoryQueueType, Queued: 2, Size: 10, MaxWorkers: 8}, queue.Stats())
+
+	queue.parsing.Add(1)
+	queue.stopLock.Lock()
+	queue.stopped = true
+	queue.stopLock.Unlock()
+	stats := queue.Stats()
+	assert.True(stats.Stopped)
+	assert.Equal(int64(1), stats.Parsing)
+}
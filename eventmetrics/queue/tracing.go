@@ -0,0 +1,82 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/xmidt-org/glaukos/eventmetrics/queue"
+
+	parserAttribute    = "glaukos.parser"
+	eventIDAttribute   = "glaukos.event_id"
+	batchSizeAttribute = "glaukos.batch_size"
+)
+
+// startSpan starts a span using the queue's tracer.  If the queue isn't traced, the span isn't recorded.
+func (e *EventQueue) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	tracer := e.tracer
+	if tracer == nil {
+		tracer = trace.NewNoopTracerProvider().Tracer(tracerName)
+	}
+
+	return tracer.Start(ctx, name, opts...)
+}
+
+// startBatchSpan starts the span of parsing a batch of events.  The time each event waited in the queue is
+// recorded as a span in the trace of the request the event was received in.  A single event is parsed within
+// that trace as well, while a batch of events is parsed in a new trace linked to the traces of its events.
+func (e *EventQueue) startBatchSpan(batch []EventWithTime) (context.Context, trace.Span) {
+	now := time.Now()
+	links := make([]trace.Link, 0, len(batch))
+	for _, eventWithTime := range batch {
+		ctx := trace.ContextWithRemoteSpanContext(e.ctx, eventWithTime.SpanContext)
+		_, span := e.startSpan(ctx, "queue wait",
+			trace.WithTimestamp(eventWithTime.BeginTime),
+			trace.WithAttributes(attribute.String(eventIDAttribute, eventWithTime.Event.TransactionUUID)),
+		)
+		span.End(trace.WithTimestamp(now))
+
+		if eventWithTime.SpanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: eventWithTime.SpanContext})
+		}
+	}
+
+	opts := []trace.SpanStartOption{trace.WithAttributes(attribute.Int(batchSizeAttribute, len(batch)))}
+	if len(batch) == 1 {
+		return e.startSpan(trace.ContextWithRemoteSpanContext(e.ctx, batch[0].SpanContext), "parse events", opts...)
+	}
+
+	opts = append(opts, trace.WithNewRoot(), trace.WithLinks(links...))
+	return e.startSpan(e.ctx, "parse events", opts...)
+}
+
+// endParserSpan ends the span of a parser, marking it as failed if the parser ran out of time.
+func endParserSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
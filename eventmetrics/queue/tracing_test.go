@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/webpa-common/v2/semaphore"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestParseBatchTracing(t *testing.T) {
+	tests := []struct {
+		description   string
+		batchSize     int
+		expectedLinks int
+	}{
+		{
+			description: "single event",
+			batchSize:   1,
+		},
+		{
+			description:   "batch",
+			batchSize:     3,
+			expectedLinks: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			recorder := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+			batch := make([]EventWithTime, 0, tc.batchSize)
+			for i := 0; i < tc.batchSize; i++ {
+				_, span := tracer.Start(context.Background(), "request")
+				span.End()
+				batch = append(batch, EventWithTime{
+					Event:       interpreter.Event{TransactionUUID: "test"},
+					BeginTime:   time.Now().Add(-1 * time.Minute),
+					SpanContext: span.SpanContext(),
+				})
+			}
+
+			parser := new(mockParser)
+			parser.On("Parse", mock.Anything)
+			parser.On("Name").Return("test parser")
+			mockTimeTracker := new(mockTimeTracker)
+			mockTimeTracker.On("TrackTime", mock.Anything)
+			queue := EventQueue{
+				parsers:     []Parser{parser},
+				logger:      zap.NewNop(),
+				ctx:         context.Background(),
+				workers:     semaphore.New(1),
+				timeTracker: mockTimeTracker,
+				tracer:      tracer,
+			}
+
+			queue.workers.Acquire()
+			queue.ParseBatch(batch)
+
+			spans := make(map[string][]sdktrace.ReadOnlySpan)
+			for _, span := range recorder.Ended() {
+				spans[span.Name()] = append(spans[span.Name()], span)
+			}
+
+			if !assert.Len(spans["queue wait"], tc.batchSize) || !assert.Len(spans["parse events"], 1) || !assert.Len(spans["parse"], tc.batchSize) {
+				return
+			}
+
+			for i, span := range spans["queue wait"] {
+				assert.Equal(batch[i].SpanContext.TraceID(), span.SpanContext().TraceID())
+				assert.Equal(batch[i].BeginTime, span.StartTime())
+			}
+
+			parseEvents := spans["parse events"][0]
+			assert.Len(parseEvents.Links(), tc.expectedLinks)
+			if tc.batchSize == 1 {
+				assert.Equal(batch[0].SpanContext.SpanID(), parseEvents.Parent().SpanID())
+			} else {
+				assert.False(parseEvents.Parent().IsValid())
+			}
+
+			for _, span := range spans["parse"] {
+				assert.Equal(parseEvents.SpanContext().SpanID(), span.Parent().SpanID())
+				assert.Contains(span.Attributes(), attribute.String(parserAttribute, "test parser"))
+			}
+		})
+	}
+}
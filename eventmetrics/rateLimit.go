@@ -0,0 +1,120 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"math"
+	"sync"
+
+	"github.com/xmidt-org/webpa-common/v2/basculechecks"
+	"golang.org/x/time/rate"
+)
+
+const (
+	partnerIDLabel = "partner_id"
+)
+
+// RateLimit configures the rate events are accepted at.
+type RateLimit struct {
+	// Rate is the number of events accepted per second.  If it is 0, events are not limited.
+	Rate float64
+
+	// Burst is the number of events that can be accepted at once.  Defaults to the rate, rounded up.
+	Burst int
+}
+
+// PartnerRateLimit configures the rate events are accepted at for a partner.
+type PartnerRateLimit struct {
+	PartnerID string
+	Rate      float64
+	Burst     int
+}
+
+// PartnerRateLimitConfig configures limiting the rate incoming events are accepted at for each partner, so
+// that a single partner can't fill the queue.
+type PartnerRateLimitConfig struct {
+	// Enabled turns on the rate limits.
+	Enabled bool
+
+	// Default is the rate limit of each partner not listed in Partners.
+	Default RateLimit
+
+	// Partners are the rate limits of specific partners.
+	Partners []PartnerRateLimit
+}
+
+// PartnerRateLimiter limits the rate incoming events are accepted at, with a separate limit for each partner.
+type PartnerRateLimiter struct {
+	defaultLimit RateLimit
+	limits       map[string]RateLimit
+
+	lock     sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewPartnerRateLimiter creates a new PartnerRateLimiter.
+func NewPartnerRateLimiter(config PartnerRateLimitConfig) *PartnerRateLimiter {
+	limits := make(map[string]RateLimit, len(config.Partners))
+	for _, partner := range config.Partners {
+		limits[partner.PartnerID] = RateLimit{Rate: partner.Rate, Burst: partner.Burst}
+	}
+
+	return &PartnerRateLimiter{
+		defaultLimit: config.Default,
+		limits:       limits,
+		limiters:     make(map[string]*rate.Limiter),
+	}
+}
+
+// Allow determines the partner of an event with the partner ids given, returning the partner and whether
+// the event is within the partner's rate limit.
+func (p *PartnerRateLimiter) Allow(partnerIDs []string) (string, bool) {
+	partner := basculechecks.DeterminePartnerMetric(partnerIDs)
+	limiter := p.limiter(partner)
+	if limiter == nil {
+		return partner, true
+	}
+
+	return partner, limiter.Allow()
+}
+
+// limiter returns the rate limiter of the partner, or nil if the partner's events aren't limited.
+func (p *PartnerRateLimiter) limiter(partner string) *rate.Limiter {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if limiter, found := p.limiters[partner]; found {
+		return limiter
+	}
+
+	limit, found := p.limits[partner]
+	if !found {
+		limit = p.defaultLimit
+	}
+
+	var limiter *rate.Limiter
+	if limit.Rate > 0 {
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(limit.Rate))
+		}
+		limiter = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+	}
+
+	p.limiters[partner] = limiter
+	return limiter
+}
@@ -0,0 +1,79 @@
+package eventmetrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartnerRateLimiter(t *testing.T) {
+	tests := []struct {
+		description     string
+		config          PartnerRateLimitConfig
+		partnerIDs      []string
+		expectedPartner string
+		expectedAllowed int
+	}{
+		{
+			description: "Unlimited by default",
+			config: PartnerRateLimitConfig{
+				Partners: []PartnerRateLimit{{PartnerID: "other", Rate: 0.001, Burst: 1}},
+			},
+			partnerIDs:      []string{"partner"},
+			expectedPartner: "partner",
+			expectedAllowed: 10,
+		},
+		{
+			description: "Default limit",
+			config: PartnerRateLimitConfig{
+				Default: RateLimit{Rate: 0.001, Burst: 3},
+			},
+			partnerIDs:      []string{"partner"},
+			expectedPartner: "partner",
+			expectedAllowed: 3,
+		},
+		{
+			description: "Partner limit",
+			config: PartnerRateLimitConfig{
+				Default:  RateLimit{Rate: 0.001, Burst: 3},
+				Partners: []PartnerRateLimit{{PartnerID: "partner", Rate: 0.001, Burst: 5}},
+			},
+			partnerIDs:      []string{"partner"},
+			expectedPartner: "partner",
+			expectedAllowed: 5,
+		},
+		{
+			description: "Burst defaults to rate",
+			config: PartnerRateLimitConfig{
+				Partners: []PartnerRateLimit{{PartnerID: "partner", Rate: 1.5}},
+			},
+			partnerIDs:      []string{"partner"},
+			expectedPartner: "partner",
+			expectedAllowed: 2,
+		},
+		{
+			description: "No partner",
+			config: PartnerRateLimitConfig{
+				Partners: []PartnerRateLimit{{PartnerID: "none", Rate: 0.001, Burst: 1}},
+			},
+			expectedPartner: "none",
+			expectedAllowed: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			limiter := NewPartnerRateLimiter(tc.config)
+			allowed := 0
+			for i := 0; i < 10; i++ {
+				partner, ok := limiter.Allow(tc.partnerIDs)
+				assert.Equal(tc.expectedPartner, partner)
+				if ok {
+					allowed++
+				}
+			}
+			assert.Equal(tc.expectedAllowed, allowed)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"go.uber.org/fx"
+)
+
+const (
+	verboseQueryParam = "verbose"
+)
+
+// ReadyConfig configures the readiness endpoint.
+type ReadyConfig struct {
+	// ExposeParsers allows the readiness endpoint to list the active parsers and the event types they
+	// match when the verbose query parameter is set.
+	ExposeParsers bool
+}
+
+// ParserDescription describes an active parser and the event types it parses.
+type ParserDescription struct {
+	Name             string   `json:"name"`
+	EventTypeRegexes []string `json:"eventTypeRegexes"`
+}
+
+type readyResponse struct {
+	Status  string              `json:"status"`
+	Parsers []ParserDescription `json:"parsers,omitempty"`
+}
+
+// DescribeParsers returns the descriptions of the parsers given.  Parsers that don't implement
+// queue.EventTypeMatcher are assumed to parse every event.
+func DescribeParsers(parsers []queue.Parser) []ParserDescription {
+	descriptions := make([]ParserDescription, 0, len(parsers))
+	for _, p := range parsers {
+		regexes := []string{".*"}
+		if matcher, ok := p.(queue.EventTypeMatcher); ok {
+			regexes = matcher.EventTypeRegexes()
+		}
+		descriptions = append(descriptions, ParserDescription{
+			Name:             p.Name(),
+			EventTypeRegexes: regexes,
+		})
+	}
+
+	return descriptions
+}
+
+// NewReadyHandler returns a handler that reports the service as ready.  If the parsers are exposed and the
+// verbose query parameter is true, the active parsers are included in the response.
+func NewReadyHandler(config ReadyConfig, parsers []queue.Parser) http.Handler {
+	descriptions := DescribeParsers(parsers)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := readyResponse{Status: "ready"}
+		if verbose, _ := strconv.ParseBool(r.URL.Query().Get(verboseQueryParam)); verbose && config.ExposeParsers {
+			response.Parsers = descriptions
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response) // nolint:errcheck
+	})
+}
+
+// ReadyRoutesIn provides the information needed to set up the readiness endpoint.
+type ReadyRoutesIn struct {
+	fx.In
+	Config  ReadyConfig
+	Parsers []queue.Parser `group:"parsers"`
+	Router  *mux.Router    `name:"servers.health"`
+}
+
+// ConfigureReadyRoutes sets up the router provided to handle readiness checks.
+func ConfigureReadyRoutes(in ReadyRoutesIn) {
+	if in.Router != nil {
+		in.Router.Handle("/ready", NewReadyHandler(in.Config, in.Parsers)).Methods("GET")
+	}
+}
@@ -0,0 +1,100 @@
+package eventmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+)
+
+type testParser struct {
+	name    string
+	regexes []string
+}
+
+func (p testParser) Parse(_ context.Context, _ interpreter.Event) {}
+
+func (p testParser) Name() string {
+	return p.name
+}
+
+type testMatcherParser struct {
+	testParser
+}
+
+func (p testMatcherParser) EventTypeRegexes() []string {
+	return p.regexes
+}
+
+func TestDescribeParsers(t *testing.T) {
+	parsers := []queue.Parser{
+		testParser{name: "all"},
+		testMatcherParser{testParser{name: "matcher", regexes: []string{"^online$", "^offline$"}}},
+	}
+
+	expected := []ParserDescription{
+		{Name: "all", EventTypeRegexes: []string{".*"}},
+		{Name: "matcher", EventTypeRegexes: []string{"^online$", "^offline$"}},
+	}
+	assert.Equal(t, expected, DescribeParsers(parsers))
+}
+
+func TestReadyHandler(t *testing.T) {
+	parsers := []queue.Parser{
+		testParser{name: "metadata"},
+		testMatcherParser{testParser{name: "reboot", regexes: []string{"^fully-manageable$"}}},
+	}
+
+	tests := []struct {
+		description     string
+		config          ReadyConfig
+		url             string
+		expectedParsers []ParserDescription
+	}{
+		{
+			description: "Default",
+			config:      ReadyConfig{ExposeParsers: true},
+			url:         "/ready",
+		},
+		{
+			description: "Verbose",
+			config:      ReadyConfig{ExposeParsers: true},
+			url:         "/ready?verbose=true",
+			expectedParsers: []ParserDescription{
+				{Name: "metadata", EventTypeRegexes: []string{".*"}},
+				{Name: "reboot", EventTypeRegexes: []string{"^fully-manageable$"}},
+			},
+		},
+		{
+			description: "Verbose not exposed",
+			url:         "/ready?verbose=true",
+		},
+		{
+			description: "Invalid verbose",
+			config:      ReadyConfig{ExposeParsers: true},
+			url:         "/ready?verbose=abc",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			router := mux.NewRouter()
+			ConfigureReadyRoutes(ReadyRoutesIn{Config: tc.config, Parsers: parsers, Router: router})
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			assert.Equal(http.StatusOK, rec.Code)
+
+			var response readyResponse
+			assert.Nil(json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal("ready", response.Status)
+			assert.Equal(tc.expectedParsers, response.Parsers)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisTimeout = time.Second
+)
+
+// RedisConfig configures the connection to a redis server shared by the glaukos instances.
+type RedisConfig struct {
+	// Address is the host:port of the redis server.
+	Address string
+
+	// Username and Password authenticate with the redis server, if set.
+	Username string
+	Password string
+
+	// DB is the redis database used.  Defaults to 0.
+	DB int
+
+	// KeyPrefix is prepended to the keys stored in redis, so that different uses of the same redis don't collide.
+	KeyPrefix string
+
+	// Timeout is the maximum time to connect to, read from, or write to redis.  Defaults to 1s.
+	Timeout time.Duration
+}
+
+func newRedisClient(config RedisConfig) *redis.Client {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultRedisTimeout
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:         config.Address,
+		Username:     config.Username,
+		Password:     config.Password,
+		DB:           config.DB,
+		DialTimeout:  config.Timeout,
+		ReadTimeout:  config.Timeout,
+		WriteTimeout: config.Timeout,
+	})
+}
@@ -0,0 +1,181 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/glaukos/events"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+// ReplayRequest is the body of a replay request: the devices whose events are replayed, and the time range
+// their events' birthdates must be within.
+type ReplayRequest struct {
+	DeviceIDs []string  `json:"deviceIDs"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// ReplayResult is the outcome of replaying a device's events.
+type ReplayResult struct {
+	DeviceID string `json:"deviceID"`
+
+	// Found is the number of the device's events within the time range.
+	Found int `json:"found"`
+
+	// Replayed is the number of terminal events queued to be parsed.
+	Replayed int `json:"replayed"`
+
+	// Failed is the number of terminal events that couldn't be queued, such as when the queue is full.
+	Failed int `json:"failed"`
+}
+
+// Replayer pulls the histories of devices from codex and queues the terminal events of each of their boot
+// cycles to be parsed, to backfill the metrics of events glaukos missed, such as during an outage.
+type Replayer struct {
+	source  events.EventSource
+	queue   queue.Queue
+	current func() time.Time
+	logger  *zap.Logger
+}
+
+// NewReplayer creates a new Replayer.
+func NewReplayer(source events.EventSource, q queue.Queue, logger *zap.Logger) *Replayer {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Replayer{
+		source:  source,
+		queue:   q,
+		current: time.Now,
+		logger:  logger,
+	}
+}
+
+// Replay queues the terminal events of each of the devices' boot cycles within the time range to be parsed,
+// returning the outcome for each device.
+func (r *Replayer) Replay(ctx context.Context, req ReplayRequest) []ReplayResult {
+	results := make([]ReplayResult, 0, len(req.DeviceIDs))
+	histories := r.source.GetEventsBatch(ctx, req.DeviceIDs)
+	for _, deviceID := range req.DeviceIDs {
+		result := ReplayResult{DeviceID: deviceID}
+		inRange := eventsInRange(histories[deviceID], req.From, req.To)
+		result.Found = len(inRange)
+		for _, event := range terminalEvents(inRange) {
+			if err := r.queue.Queue(queue.EventWithTime{Event: event, BeginTime: r.current()}); err != nil {
+				r.logger.Warn("failed to queue replayed event", zap.Error(err), zap.String("deviceID", deviceID), zap.String("event id", event.TransactionUUID))
+				result.Failed++
+				continue
+			}
+			result.Replayed++
+		}
+
+		r.logger.Info("replayed device events", zap.String("deviceID", deviceID), zap.Int("found", result.Found),
+			zap.Int("replayed", result.Replayed), zap.Int("failed", result.Failed))
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Handler returns a handler that replays the events of the devices in the ReplayRequest body.
+func (r *Replayer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		var req ReplayRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid replay request", http.StatusBadRequest)
+			return
+		}
+
+		if len(req.DeviceIDs) == 0 {
+			http.Error(w, "no device ids to replay", http.StatusBadRequest)
+			return
+		}
+
+		if req.To.IsZero() {
+			req.To = r.current()
+		}
+
+		if req.From.After(req.To) {
+			http.Error(w, "from must not be after to", http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, r.Replay(request.Context(), req))
+	})
+}
+
+// eventsInRange returns the events whose birthdates are within from and to, inclusive.
+func eventsInRange(history []interpreter.Event, from time.Time, to time.Time) []interpreter.Event {
+	var inRange []interpreter.Event
+	for _, event := range history {
+		birthdate := time.Unix(0, event.Birthdate)
+		if birthdate.Before(from) || birthdate.After(to) {
+			continue
+		}
+		inRange = append(inRange, event)
+	}
+
+	return inRange
+}
+
+// terminalEvents returns the latest event of each event type in each boot cycle, such as a cycle's
+// fully-manageable and offline events, which the parsers calculate the cycle's durations from.  The events are
+// returned oldest first so that they are parsed in the order they were sent.
+func terminalEvents(history []interpreter.Event) []interpreter.Event {
+	type cycleEvent struct {
+		bootTime  int64
+		eventType string
+	}
+
+	latest := make(map[cycleEvent]interpreter.Event)
+	for _, event := range history {
+		bootTime, err := event.BootTime()
+		if err != nil || bootTime <= 0 {
+			continue
+		}
+
+		eventType, err := event.EventType()
+		if err != nil {
+			continue
+		}
+
+		key := cycleEvent{bootTime: bootTime, eventType: eventType}
+		if found, ok := latest[key]; !ok || event.Birthdate > found.Birthdate {
+			latest[key] = event
+		}
+	}
+
+	terminal := make([]interpreter.Event, 0, len(latest))
+	for _, event := range latest {
+		terminal = append(terminal, event)
+	}
+
+	sort.Slice(terminal, func(i, j int) bool {
+		return terminal[i].Birthdate < terminal[j].Birthdate
+	})
+	return terminal
+}
@@ -0,0 +1,178 @@
+package eventmetrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+)
+
+func TestTerminalEvents(t *testing.T) {
+	assert := assert.New(t)
+	history := []interpreter.Event{
+		{TransactionUUID: "online-1", Destination: "event:device-status/mac:112233445566/online", Birthdate: 300,
+			Metadata: map[string]string{interpreter.BootTimeKey: "100"}},
+		{TransactionUUID: "fully-manageable-1", Destination: "event:device-status/mac:112233445566/fully-manageable", Birthdate: 200,
+			Metadata: map[string]string{interpreter.BootTimeKey: "100"}},
+		{TransactionUUID: "online-1-older", Destination: "event:device-status/mac:112233445566/online", Birthdate: 150,
+			Metadata: map[string]string{interpreter.BootTimeKey: "100"}},
+		{TransactionUUID: "online-2", Destination: "event:device-status/mac:112233445566/online", Birthdate: 500,
+			Metadata: map[string]string{interpreter.BootTimeKey: "400"}},
+		{TransactionUUID: "no-boot-time", Destination: "event:device-status/mac:112233445566/online", Birthdate: 600},
+		{TransactionUUID: "no-event-type", Destination: "mac:112233445566/config", Birthdate: 700,
+			Metadata: map[string]string{interpreter.BootTimeKey: "400"}},
+	}
+
+	var ids []string
+	for _, event := range terminalEvents(history) {
+		ids = append(ids, event.TransactionUUID)
+	}
+	assert.Equal([]string{"fully-manageable-1", "online-1", "online-2"}, ids)
+}
+
+func TestEventsInRange(t *testing.T) {
+	assert := assert.New(t)
+	from := time.Unix(100, 0)
+	to := time.Unix(200, 0)
+	history := []interpreter.Event{
+		{TransactionUUID: "before", Birthdate: time.Unix(99, 0).UnixNano()},
+		{TransactionUUID: "from", Birthdate: from.UnixNano()},
+		{TransactionUUID: "within", Birthdate: time.Unix(150, 0).UnixNano()},
+		{TransactionUUID: "to", Birthdate: to.UnixNano()},
+		{TransactionUUID: "after", Birthdate: time.Unix(201, 0).UnixNano()},
+	}
+
+	var ids []string
+	for _, event := range eventsInRange(history, from, to) {
+		ids = append(ids, event.TransactionUUID)
+	}
+	assert.Equal([]string{"from", "within", "to"}, ids)
+}
+
+func TestReplayerHandler(t *testing.T) {
+	now := time.Unix(1000, 0)
+	history := []interpreter.Event{
+		{TransactionUUID: "online", Destination: "event:device-status/mac:112233445566/online", Birthdate: time.Unix(500, 0).UnixNano(),
+			Metadata: map[string]string{interpreter.BootTimeKey: "400"}},
+		{TransactionUUID: "fully-manageable", Destination: "event:device-status/mac:112233445566/fully-manageable", Birthdate: time.Unix(600, 0).UnixNano(),
+			Metadata: map[string]string{interpreter.BootTimeKey: "400"}},
+		{TransactionUUID: "too-old", Destination: "event:device-status/mac:112233445566/online", Birthdate: time.Unix(100, 0).UnixNano(),
+			Metadata: map[string]string{interpreter.BootTimeKey: "50"}},
+	}
+
+	tests := []struct {
+		description     string
+		body            string
+		queueErr        error
+		expectedCode    int
+		expectedResults []ReplayResult
+	}{
+		{
+			description:  "Success",
+			body:         `{"deviceIDs": ["mac:112233445566", "mac:aabbccddeeff"], "from": "1970-01-01T00:05:00Z"}`,
+			expectedCode: http.StatusOK,
+			expectedResults: []ReplayResult{
+				{DeviceID: "mac:112233445566", Found: 2, Replayed: 2},
+				{DeviceID: "mac:aabbccddeeff"},
+			},
+		},
+		{
+			description:  "Queue failure",
+			body:         `{"deviceIDs": ["mac:112233445566"]}`,
+			queueErr:     errors.New("queue full"),
+			expectedCode: http.StatusOK,
+			expectedResults: []ReplayResult{
+				{DeviceID: "mac:112233445566", Found: 3, Failed: 3},
+			},
+		},
+		{
+			description:  "Invalid body",
+			body:         `{"deviceIDs": `,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description:  "No devices",
+			body:         `{"deviceIDs": []}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description:  "Invalid range",
+			body:         `{"deviceIDs": ["mac:112233445566"], "from": "1970-01-01T00:05:00Z", "to": "1970-01-01T00:01:00Z"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			source := new(mockEventSource)
+			source.On("GetEventsBatch", mock.Anything, mock.Anything).Return(map[string][]interpreter.Event{"mac:112233445566": history})
+			q := new(mockQueue)
+			q.On("Queue", mock.Anything).Return(tc.queueErr)
+
+			replayer := NewReplayer(source, q, nil)
+			replayer.current = func() time.Time { return now }
+			recorder := httptest.NewRecorder()
+			replayer.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/admin/replay", bytes.NewBufferString(tc.body)))
+			assert.Equal(tc.expectedCode, recorder.Code)
+			if tc.expectedCode != http.StatusOK {
+				q.AssertNotCalled(t, "Queue", mock.Anything)
+				return
+			}
+
+			var results []ReplayResult
+			assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &results))
+			assert.Equal(tc.expectedResults, results)
+			for _, call := range q.Calls {
+				assert.Equal(now, call.Arguments.Get(0).(queue.EventWithTime).BeginTime)
+			}
+		})
+	}
+}
+
+func TestReplayRoute(t *testing.T) {
+	tests := []struct {
+		description  string
+		enabled      bool
+		expectedCode int
+	}{
+		{
+			description:  "Enabled",
+			enabled:      true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:  "Disabled",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			source := new(mockEventSource)
+			source.On("GetEventsBatch", mock.Anything, mock.Anything).Return(map[string][]interpreter.Event{})
+			router := mux.NewRouter()
+			ConfigureAdminRoutes(AdminRoutesIn{
+				Config:      AdminConfig{EnableReplayEndpoint: tc.enabled},
+				Queue:       new(mockQueue),
+				EventSource: source,
+				Router:      router,
+				APIBase:     "api/v1",
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/admin/replay", bytes.NewBufferString(`{"deviceIDs": ["mac:112233445566"]}`)))
+			assert.Equal(tc.expectedCode, recorder.Code)
+		})
+	}
+}
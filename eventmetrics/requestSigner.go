@@ -0,0 +1,35 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"net/http"
+
+	"go.uber.org/fx"
+)
+
+// RequestSigner signs a request sent to the events endpoint of a glaukos with the body given, so that the
+// request is authenticated like the events sent by the webhook.
+type RequestSigner func(request *http.Request, body []byte)
+
+// RequestSignerIn provides the signer of requests sent to glaukos events endpoints, which is nil when incoming
+// events aren't authenticated with HMAC signatures.
+type RequestSignerIn struct {
+	fx.In
+	Signer RequestSigner `name:"event_request_signer" optional:"true"`
+}
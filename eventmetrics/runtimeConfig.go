@@ -0,0 +1,143 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/xmidt-org/arrange"
+)
+
+const redactedValue = "<redacted>"
+
+var (
+	// sensitiveKeys are the config keys whose values are always redacted.
+	sensitiveKeys = []string{"key", "basic"}
+
+	// sensitiveKeyParts redact the values of the config keys containing them.
+	sensitiveKeyParts = []string{"password", "secret", "token", "authorization", "credential"}
+)
+
+// configRedactor redacts the secrets in the runtime configuration.
+type configRedactor struct {
+	keys  map[string]bool
+	parts []string
+}
+
+// newConfigRedactor creates a configRedactor that also redacts the values of the additional keys given.
+func newConfigRedactor(additionalKeys []string) configRedactor {
+	keys := make(map[string]bool, len(sensitiveKeys)+len(additionalKeys))
+	for _, key := range append(append([]string{}, sensitiveKeys...), additionalKeys...) {
+		keys[strings.ToLower(key)] = true
+	}
+
+	return configRedactor{keys: keys, parts: sensitiveKeyParts}
+}
+
+// sensitive returns true if the values of the key are secret.
+func (c configRedactor) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	if c.keys[key] {
+		return true
+	}
+
+	for _, part := range c.parts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact returns a copy of the value with the values of sensitive keys, and the passwords in urls, redacted.
+// Empty values are left as they are, so that it is clear when a secret isn't set.
+func (c configRedactor) redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if c.sensitive(key) && !empty(item) {
+				redacted[key] = redactedValue
+			} else {
+				redacted[key] = c.redact(item)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = c.redact(item)
+		}
+		return redacted
+	case string:
+		return redactURL(v)
+	}
+
+	return value
+}
+
+// redactURL redacts the password of a url with user info, returning any other string unchanged.
+func redactURL(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+
+	if _, found := u.User.Password(); !found {
+		return value
+	}
+
+	return u.Redacted()
+}
+
+func empty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+
+	return false
+}
+
+// ConfigHandler returns a handler that returns the runtime configuration loaded by the unmarshaler as JSON, with
+// its secrets redacted, so that operators can verify what a running glaukos loaded.  The values of the additional
+// keys given are also redacted.  The configuration is loaded once, returning an error if it can't be loaded.
+func ConfigHandler(unmarshaler arrange.Unmarshaler, additionalKeys []string) (http.Handler, error) {
+	var settings map[string]interface{}
+	if err := unmarshaler.Unmarshal(&settings); err != nil {
+		return nil, err
+	}
+
+	redacted := newConfigRedactor(additionalKeys).redact(settings)
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, redacted)
+	}), nil
+}
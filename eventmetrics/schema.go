@@ -0,0 +1,174 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	schemaViolationReason = "schemaViolation"
+	eventTypeLabel        = "event_type"
+)
+
+var (
+	errSchemaViolation     = errors.New("event payload does not match schema")
+	errMissingSchema       = errors.New("schema or file must be set")
+	errMissingSchemaType   = errors.New("schema event type cannot be blank")
+	errDuplicateSchemaType = errors.New("event type has more than one schema")
+)
+
+// SchemaValidationConfig configures validating the payloads of incoming events against JSON schemas.
+type SchemaValidationConfig struct {
+	// Enabled turns on validating payloads.
+	Enabled bool
+
+	// Reject enables rejecting events whose payloads don't match the schema of their event type.  If false,
+	// schema violations are only counted and logged.
+	Reject bool
+
+	// Schemas are the schemas of each event type.  Events with an event type without a schema aren't validated.
+	Schemas []EventSchemaConfig
+}
+
+// EventSchemaConfig is the JSON schema that the payloads of an event type must match.
+type EventSchemaConfig struct {
+	// EventType is the event type the schema applies to, such as online or fully-manageable.
+	EventType string
+
+	// Schema is the JSON schema.
+	Schema string
+
+	// File is the path of a file containing the JSON schema, used if Schema is empty.
+	File string
+}
+
+// SchemaViolationsIn provides the counter of schema violations.
+type SchemaViolationsIn struct {
+	fx.In
+	Violations *prometheus.CounterVec `name:"schema_violations_count"`
+}
+
+// SchemaValidator validates the payloads of events against the JSON schema of their event type, counting
+// the events that don't match.
+type SchemaValidator struct {
+	schemas    map[string]*jsonschema.Schema
+	reject     bool
+	violations *prometheus.CounterVec
+	logger     *zap.Logger
+}
+
+// NewSchemaValidator compiles the configured schemas, returning an error if any of them are invalid.
+func NewSchemaValidator(config SchemaValidationConfig, violations *prometheus.CounterVec, logger *zap.Logger) (*SchemaValidator, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	compiler := jsonschema.NewCompiler()
+	schemas := make(map[string]*jsonschema.Schema, len(config.Schemas))
+	for _, s := range config.Schemas {
+		if len(s.EventType) == 0 {
+			return nil, errMissingSchemaType
+		}
+
+		if _, found := schemas[s.EventType]; found {
+			return nil, fmt.Errorf("%w: %s", errDuplicateSchemaType, s.EventType)
+		}
+
+		source := s.Schema
+		if len(source) == 0 {
+			if len(s.File) == 0 {
+				return nil, fmt.Errorf("%w: %s", errMissingSchema, s.EventType)
+			}
+
+			data, err := os.ReadFile(s.File)
+			if err != nil {
+				return nil, err
+			}
+			source = string(data)
+		}
+
+		url := fmt.Sprintf("%s.json", s.EventType)
+		if err := compiler.AddResource(url, strings.NewReader(source)); err != nil {
+			return nil, fmt.Errorf("invalid %s schema: %w", s.EventType, err)
+		}
+
+		schema, err := compiler.Compile(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s schema: %w", s.EventType, err)
+		}
+		schemas[s.EventType] = schema
+	}
+
+	return &SchemaValidator{
+		schemas:    schemas,
+		reject:     config.Reject,
+		violations: violations,
+		logger:     logger,
+	}, nil
+}
+
+// Valid implements the validation.Validator interface, counting events whose payloads don't match the schema
+// of their event type.  Invalid events are only rejected if rejection is enabled.
+func (s *SchemaValidator) Valid(e interpreter.Event) (bool, error) {
+	eventType, err := e.EventType()
+	if err != nil {
+		return true, nil
+	}
+
+	schema, found := s.schemas[eventType]
+	if !found {
+		return true, nil
+	}
+
+	if err := validatePayload(schema, e.Payload); err != nil {
+		if s.violations != nil {
+			s.violations.With(prometheus.Labels{eventTypeLabel: eventType}).Add(1.0)
+		}
+
+		s.logger.Debug("event payload does not match schema", zap.Error(err), zap.String("event type", eventType),
+			zap.String("event id", e.TransactionUUID))
+		if s.reject {
+			return false, InvalidEventErr{
+				Reason: schemaViolationReason,
+				Err:    fmt.Errorf("%w: %v", errSchemaViolation, err),
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func validatePayload(schema *jsonschema.Schema, payload string) error {
+	var v interface{}
+	if err := json.Unmarshal([]byte(payload), &v); err != nil {
+		return err
+	}
+
+	return schema.Validate(v)
+}
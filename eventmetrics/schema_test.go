@@ -0,0 +1,161 @@
+package eventmetrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+)
+
+const testOnlineSchema = `{
+	"type": "object",
+	"required": ["id", "ts"],
+	"properties": {
+		"id": {"type": "string"},
+		"ts": {"type": "string"}
+	}
+}`
+
+func TestNewSchemaValidator(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "offline.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`{"type": "object"}`), 0600))
+
+	tests := []struct {
+		description     string
+		config          SchemaValidationConfig
+		expectedErr     error
+		expectedSchemas []string
+	}{
+		{
+			description: "Inline and file schemas",
+			config: SchemaValidationConfig{Schemas: []EventSchemaConfig{
+				{EventType: "online", Schema: testOnlineSchema},
+				{EventType: "offline", File: file},
+			}},
+			expectedSchemas: []string{"online", "offline"},
+		},
+		{
+			description: "Missing event type",
+			config:      SchemaValidationConfig{Schemas: []EventSchemaConfig{{Schema: testOnlineSchema}}},
+			expectedErr: errMissingSchemaType,
+		},
+		{
+			description: "Duplicate event type",
+			config: SchemaValidationConfig{Schemas: []EventSchemaConfig{
+				{EventType: "online", Schema: testOnlineSchema},
+				{EventType: "online", Schema: testOnlineSchema},
+			}},
+			expectedErr: errDuplicateSchemaType,
+		},
+		{
+			description: "Missing schema",
+			config:      SchemaValidationConfig{Schemas: []EventSchemaConfig{{EventType: "online"}}},
+			expectedErr: errMissingSchema,
+		},
+		{
+			description: "Missing file",
+			config:      SchemaValidationConfig{Schemas: []EventSchemaConfig{{EventType: "online", File: filepath.Join(t.TempDir(), "missing.json")}}},
+			expectedErr: os.ErrNotExist,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			validator, err := NewSchemaValidator(tc.config, nil, nil)
+			if tc.expectedErr != nil {
+				assert.Nil(validator)
+				assert.ErrorIs(err, tc.expectedErr)
+				return
+			}
+
+			assert.Nil(err)
+			if !assert.NotNil(validator) {
+				return
+			}
+			assert.Len(validator.schemas, len(tc.expectedSchemas))
+			for _, eventType := range tc.expectedSchemas {
+				assert.Contains(validator.schemas, eventType)
+			}
+		})
+	}
+
+	validator, err := NewSchemaValidator(SchemaValidationConfig{Schemas: []EventSchemaConfig{{EventType: "online", Schema: `{"type": 5}`}}}, nil, nil)
+	assert.Nil(t, validator)
+	assert.NotNil(t, err)
+}
+
+func TestSchemaValidatorValid(t *testing.T) {
+	tests := []struct {
+		description        string
+		reject             bool
+		event              interpreter.Event
+		expectedValid      bool
+		expectedViolations float64
+	}{
+		{
+			description:   "Valid payload",
+			event:         interpreter.Event{Destination: "event:device-status/mac:112233445566/online", Payload: `{"id": "mac:112233445566", "ts": "2021-03-02T18:00:01Z"}`},
+			expectedValid: true,
+		},
+		{
+			description:        "Invalid payload",
+			event:              interpreter.Event{Destination: "event:device-status/mac:112233445566/online", Payload: `{"id": 5}`},
+			expectedValid:      true,
+			expectedViolations: 1.0,
+		},
+		{
+			description:        "Invalid payload rejected",
+			reject:             true,
+			event:              interpreter.Event{Destination: "event:device-status/mac:112233445566/online", Payload: `{"id": 5}`},
+			expectedViolations: 1.0,
+		},
+		{
+			description:        "Payload not JSON",
+			reject:             true,
+			event:              interpreter.Event{Destination: "event:device-status/mac:112233445566/online", Payload: "not json"},
+			expectedViolations: 1.0,
+		},
+		{
+			description:   "Event type without schema",
+			reject:        true,
+			event:         interpreter.Event{Destination: "event:device-status/mac:112233445566/offline", Payload: "not json"},
+			expectedValid: true,
+		},
+		{
+			description:   "Invalid destination",
+			reject:        true,
+			event:         interpreter.Event{Destination: "invalid"},
+			expectedValid: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			violations := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testSchemaViolations",
+				Help: "testSchemaViolations",
+			}, []string{eventTypeLabel})
+			config := SchemaValidationConfig{Reject: tc.reject, Schemas: []EventSchemaConfig{{EventType: "online", Schema: testOnlineSchema}}}
+			validator, err := NewSchemaValidator(config, violations, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			valid, err := validator.Valid(tc.event)
+			assert.Equal(tc.expectedValid, valid)
+			if tc.expectedValid {
+				assert.Nil(err)
+			} else {
+				assert.Equal(schemaViolationReason, rejectedEventErr(err).Reason)
+				assert.ErrorIs(err, errSchemaViolation)
+			}
+			assert.Equal(tc.expectedViolations, testutil.ToFloat64(violations.WithLabelValues("online")))
+		})
+	}
+}
@@ -0,0 +1,495 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	dropShardAction    = "drop"
+	forwardShardAction = "forward"
+
+	staticShardDiscovery = "static"
+	redisShardDiscovery  = "redis"
+
+	shardActionLabel           = "action"
+	droppedShardOutcome        = "dropped"
+	forwardedShardOutcome      = "forwarded"
+	forwardFailedOutcome       = "forward_failed"
+	forwardedByHeader          = "X-Glaukos-Forwarded-By"
+	defaultRedisShardKeyPrefix = "glaukos:shards:"
+
+	defaultShardVirtualNodes      = 100
+	defaultShardForwardTimeout    = 5 * time.Second
+	defaultShardHeartbeatInterval = 10 * time.Second
+)
+
+var (
+	errMissingShardInstance    = errors.New("no shard instance name")
+	errShardInstanceNotMember  = errors.New("shard instance isn't one of the members")
+	errMissingShardURL         = errors.New("shard member missing url to forward events to")
+	errInvalidShardAction      = errors.New("invalid shard action")
+	errInvalidShardDiscovery   = errors.New("invalid shard discovery")
+	errMissingShardForwardAuth = errors.New("forwarding events requires the webhook secret or a forward authorization")
+)
+
+// ShardConfig configures splitting incoming events between multiple glaukos instances by device id, so that
+// each device's events are only parsed by one instance.
+type ShardConfig struct {
+	// Enabled turns on sharding.
+	Enabled bool
+
+	// Instance is the name of this glaukos among the members.  Defaults to the hostname.
+	Instance string
+
+	// URL is the url of this glaukos's events endpoint, which other members forward events to.  It is only used
+	// when members are discovered through redis.
+	URL string
+
+	// Members are the glaukos instances sharing the events, including this one, when discovery is static.
+	Members []ShardMember
+
+	// Discovery is how the members are found, either "static" to use the configured members or "redis" for each
+	// member to register itself in redis.  Defaults to static.
+	Discovery string
+
+	// Redis configures the redis used for discovery, whose KeyPrefix defaults to "glaukos:shards:".
+	Redis RedisConfig
+
+	// HeartbeatInterval is how often this glaukos registers itself in redis and refreshes the members.  Members
+	// that haven't registered within three intervals are removed.  Defaults to 10s.
+	HeartbeatInterval time.Duration
+
+	// VirtualNodes is the number of points each member has on the hash ring, which evens out the shards.
+	// Defaults to 100.
+	VirtualNodes int
+
+	// Action is what is done with the events of devices owned by another member, either "drop" when every member
+	// receives every event, or "forward" to send the event to its owner.  Defaults to drop.
+	Action string
+
+	// ForwardTimeout is the maximum time to forward an event.  Defaults to 5s.
+	ForwardTimeout time.Duration
+
+	// ForwardAuthorization is the Authorization header sent with forwarded events, such as a JWT bearer token
+	// when incoming events are authenticated with JWTs.  Forwarded events are also signed with the webhook's
+	// secret, when the secret is configured.
+	ForwardAuthorization string
+}
+
+// ShardMember is a glaukos instance that owns a shard of the devices.
+type ShardMember struct {
+	// Name identifies the instance.
+	Name string
+
+	// URL is the events endpoint of the instance, which events are forwarded to.
+	URL string
+}
+
+// ShardedEventsIn provides the counter of events owned by other shards.
+type ShardedEventsIn struct {
+	fx.In
+	ShardedEvents *prometheus.CounterVec `name:"sharded_events_count"`
+}
+
+// hashRing assigns keys to members using consistent hashing, so that few keys move when members change.
+type hashRing struct {
+	points []uint64
+	owners map[uint64]string
+}
+
+func newHashRing(members []string, virtualNodes int) *hashRing {
+	r := &hashRing{owners: make(map[uint64]string, len(members)*virtualNodes)}
+	for _, member := range members {
+		for i := 0; i < virtualNodes; i++ {
+			point := hashKey(member + "#" + strconv.Itoa(i))
+			// ties go to the lowest name, so that every member builds the same ring.
+			if owner, found := r.owners[point]; found && owner < member {
+				continue
+			}
+			r.owners[point] = member
+		}
+	}
+
+	for point := range r.owners {
+		r.points = append(r.points, point)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// owner returns the member owning the key, or an empty string if the ring has no members.
+func (r *hashRing) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func hashKey(key string) uint64 {
+	return xxhash.Sum64String(key)
+}
+
+// shardRegistry is where members register themselves so that they can find each other.
+type shardRegistry interface {
+	Register(ctx context.Context, member ShardMember, ttl time.Duration) error
+	Deregister(ctx context.Context, name string) error
+	Members(ctx context.Context) ([]ShardMember, error)
+}
+
+type httpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Sharder finds the glaukos instance that owns each device, dropping or forwarding the events of devices owned by
+// other instances.
+type Sharder struct {
+	self          ShardMember
+	virtualNodes  int
+	action        string
+	forwardAuth   string
+	sign          RequestSigner
+	client        httpDoer
+	registry      shardRegistry
+	heartbeat     time.Duration
+	shardedEvents *prometheus.CounterVec
+	logger        *zap.Logger
+
+	lock sync.RWMutex
+	ring *hashRing
+	urls map[string]string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSharder creates a Sharder, returning an error if the configuration is invalid.  Forwarded events are signed
+// with sign, if it isn't nil.  Events owned by other shards are counted in shardedEvents, if it isn't nil.
+func NewSharder(config ShardConfig, sign RequestSigner, shardedEvents *prometheus.CounterVec, logger *zap.Logger) (*Sharder, error) {
+	if len(config.Instance) == 0 {
+		config.Instance, _ = os.Hostname()
+		if len(config.Instance) == 0 {
+			return nil, errMissingShardInstance
+		}
+	}
+
+	if len(config.Action) == 0 {
+		config.Action = dropShardAction
+	}
+
+	if config.Action != dropShardAction && config.Action != forwardShardAction {
+		return nil, fmt.Errorf("%w: %q", errInvalidShardAction, config.Action)
+	}
+
+	// forwarded events would be rejected by the other members without either.
+	if config.Action == forwardShardAction && sign == nil && len(config.ForwardAuthorization) == 0 {
+		return nil, errMissingShardForwardAuth
+	}
+
+	if config.VirtualNodes <= 0 {
+		config.VirtualNodes = defaultShardVirtualNodes
+	}
+
+	if config.ForwardTimeout <= 0 {
+		config.ForwardTimeout = defaultShardForwardTimeout
+	}
+
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = defaultShardHeartbeatInterval
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	s := &Sharder{
+		self:          ShardMember{Name: config.Instance, URL: config.URL},
+		virtualNodes:  config.VirtualNodes,
+		action:        config.Action,
+		forwardAuth:   config.ForwardAuthorization,
+		sign:          sign,
+		client:        &http.Client{Timeout: config.ForwardTimeout},
+		heartbeat:     config.HeartbeatInterval,
+		shardedEvents: shardedEvents,
+		logger:        logger,
+	}
+
+	switch config.Discovery {
+	case "", staticShardDiscovery:
+		if err := s.checkMembers(config.Members); err != nil {
+			return nil, err
+		}
+		s.setMembers(config.Members)
+	case redisShardDiscovery:
+		if config.Action == forwardShardAction && len(config.URL) == 0 {
+			return nil, fmt.Errorf("%w: %q", errMissingShardURL, config.Instance)
+		}
+		s.registry = newRedisShardRegistry(config.Redis)
+		// until the members are found, this glaukos parses every event.
+		s.setMembers([]ShardMember{s.self})
+	default:
+		return nil, fmt.Errorf("%w: %q", errInvalidShardDiscovery, config.Discovery)
+	}
+
+	return s, nil
+}
+
+func (s *Sharder) checkMembers(members []ShardMember) error {
+	found := false
+	for _, m := range members {
+		if m.Name == s.self.Name {
+			found = true
+			continue
+		}
+
+		if s.action == forwardShardAction && len(m.URL) == 0 {
+			return fmt.Errorf("%w: %q", errMissingShardURL, m.Name)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %q", errShardInstanceNotMember, s.self.Name)
+	}
+
+	return nil
+}
+
+// setMembers rebuilds the hash ring with the members given.
+func (s *Sharder) setMembers(members []ShardMember) {
+	names := make([]string, 0, len(members))
+	urls := make(map[string]string, len(members))
+	for _, m := range members {
+		names = append(names, m.Name)
+		urls[m.Name] = m.URL
+	}
+
+	ring := newHashRing(names, s.virtualNodes)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ring = ring
+	s.urls = urls
+}
+
+// owner returns the member owning the device.
+func (s *Sharder) owner(deviceID string) ShardMember {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	name := s.ring.owner(deviceid.Normalize(deviceID))
+	return ShardMember{Name: name, URL: s.urls[name]}
+}
+
+// Route returns true if the event should be parsed by this glaukos.  The events of devices owned by other members
+// are dropped or forwarded to their owner, returning an error if the event couldn't be forwarded.  Events without
+// a device id, or that were forwarded by another member, are always parsed here.
+func (s *Sharder) Route(ctx context.Context, e interpreter.Event) (bool, error) {
+	deviceID, err := deviceid.FromEvent(e)
+	if err != nil || forwarded(ctx) {
+		return true, nil
+	}
+
+	owner := s.owner(deviceID)
+	if len(owner.Name) == 0 || owner.Name == s.self.Name {
+		return true, nil
+	}
+
+	if s.action == dropShardAction {
+		s.count(droppedShardOutcome)
+		return false, nil
+	}
+
+	if err := s.forward(ctx, owner, e); err != nil {
+		s.logger.Error("failed to forward event", zap.Error(err), zap.String("owner", owner.Name), zap.String("event id", e.TransactionUUID))
+		s.count(forwardFailedOutcome)
+		return false, queue.ServiceUnavailableErr{Message: "Failed to Forward Event"}
+	}
+
+	s.count(forwardedShardOutcome)
+	return false, nil
+}
+
+// forward sends the event to the member's events endpoint as a msgpack wrp message.
+func (s *Sharder) forward(ctx context.Context, owner ShardMember, e interpreter.Event) error {
+	request, err := newEventRequest(ctx, owner.URL, e, s.forwardAuth, s.sign)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set(forwardedByHeader, s.self.Name)
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d from %s", response.StatusCode, owner.Name)
+	}
+
+	return nil
+}
+
+// newEventRequest builds the request posting the event to an events endpoint as a msgpack encoded wrp message,
+// with the Authorization header given, if there is one, and signed, if sign isn't nil.
+func newEventRequest(ctx context.Context, url string, e interpreter.Event, authorization string, sign RequestSigner) (*http.Request, error) {
+	msg := wrp.Message{
+		Type:            wrp.MessageType(e.MsgType),
+		Source:          e.Source,
+		Destination:     e.Destination,
+		TransactionUUID: e.TransactionUUID,
+		ContentType:     e.ContentType,
+		Metadata:        e.Metadata,
+		Payload:         []byte(e.Payload),
+		PartnerIDs:      e.PartnerIDs,
+		SessionID:       e.SessionID,
+	}
+
+	var body []byte
+	if err := wrp.NewEncoderBytes(&body, wrp.Msgpack).Encode(msg); err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", wrp.MimeTypeMsgpack)
+	if len(authorization) > 0 {
+		request.Header.Set("Authorization", authorization)
+	}
+	if sign != nil {
+		sign(request, body)
+	}
+
+	return request, nil
+}
+
+func (s *Sharder) count(outcome string) {
+	if s.shardedEvents != nil {
+		s.shardedEvents.With(prometheus.Labels{shardActionLabel: outcome}).Add(1.0)
+	}
+}
+
+// Start starts registering this glaukos and refreshing the members, if they are discovered through redis.
+func (s *Sharder) Start() {
+	if s.registry == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.refresh(ctx)
+	go s.run(ctx)
+}
+
+// Stop stops refreshing the members and deregisters this glaukos, so that its shard moves to the other members.
+func (s *Sharder) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+
+	s.cancel()
+	<-s.done
+	return s.registry.Deregister(ctx, s.self.Name)
+}
+
+func (s *Sharder) run(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// refresh registers this glaukos and rebuilds the ring from the registered members.  The current members are kept
+// if redis fails.
+func (s *Sharder) refresh(ctx context.Context) {
+	if err := s.registry.Register(ctx, s.self, 3*s.heartbeat); err != nil {
+		s.logger.Error("failed to register shard member", zap.Error(err))
+		return
+	}
+
+	members, err := s.registry.Members(ctx)
+	if err != nil {
+		s.logger.Error("failed to get shard members", zap.Error(err))
+		return
+	}
+
+	found := false
+	for _, m := range members {
+		if m.Name == s.self.Name {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		members = append(members, s.self)
+	}
+
+	s.setMembers(members)
+}
+
+type forwardedKey struct{}
+
+// ForwardedFromRequest marks the context of events forwarded by another glaukos, so that they are parsed here
+// rather than sharded again.
+func ForwardedFromRequest(ctx context.Context, r *http.Request) context.Context {
+	if len(r.Header.Get(forwardedByHeader)) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, forwardedKey{}, true)
+}
+
+func forwarded(ctx context.Context) bool {
+	f, _ := ctx.Value(forwardedKey{}).(bool)
+	return f
+}
@@ -0,0 +1,103 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisShardClient interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+}
+
+// redisShardRegistry registers each member as a key holding its url, which expires unless the member keeps
+// registering itself.
+type redisShardRegistry struct {
+	client redisShardClient
+	prefix string
+}
+
+func newRedisShardRegistry(config RedisConfig) *redisShardRegistry {
+	if len(config.KeyPrefix) == 0 {
+		config.KeyPrefix = defaultRedisShardKeyPrefix
+	}
+
+	return &redisShardRegistry{
+		client: newRedisClient(config),
+		prefix: config.KeyPrefix,
+	}
+}
+
+// Register sets the member's key, expiring it after the ttl.
+func (r *redisShardRegistry) Register(ctx context.Context, member ShardMember, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+member.Name, member.URL, ttl).Err()
+}
+
+// Deregister deletes the member's key.
+func (r *redisShardRegistry) Deregister(ctx context.Context, name string) error {
+	return r.client.Del(ctx, r.prefix+name).Err()
+}
+
+// Members returns the members whose keys haven't expired.
+func (r *redisShardRegistry) Members(ctx context.Context) ([]ShardMember, error) {
+	var (
+		keys   []string
+		cursor uint64
+	)
+
+	for {
+		page, next, err := r.client.Scan(ctx, cursor, r.prefix+"*", 100).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, page...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]ShardMember, 0, len(keys))
+	for i, key := range keys {
+		// keys may expire between the scan and the get.
+		url, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		members = append(members, ShardMember{Name: strings.TrimPrefix(key, r.prefix), URL: url})
+	}
+
+	return members, nil
+}
@@ -0,0 +1,325 @@
+package eventmetrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestNewSharder(t *testing.T) {
+	members := []ShardMember{{Name: "a", URL: "http://a/api/v1/events"}, {Name: "b", URL: "http://b/api/v1/events"}}
+	sign := func(*http.Request, []byte) {}
+	tests := []struct {
+		description      string
+		config           ShardConfig
+		sign             RequestSigner
+		expectedAction   string
+		expectedRegistry bool
+		expectedErr      error
+	}{
+		{
+			description:    "Static",
+			config:         ShardConfig{Instance: "a", Members: members},
+			expectedAction: dropShardAction,
+		},
+		{
+			description:    "Static forward",
+			config:         ShardConfig{Instance: "a", Members: members, Action: forwardShardAction},
+			sign:           sign,
+			expectedAction: forwardShardAction,
+		},
+		{
+			description:    "Static forward with authorization",
+			config:         ShardConfig{Instance: "a", Members: members, Action: forwardShardAction, ForwardAuthorization: "Bearer jwt"},
+			expectedAction: forwardShardAction,
+		},
+		{
+			description: "Forward without authentication",
+			config:      ShardConfig{Instance: "a", Members: members, Action: forwardShardAction},
+			expectedErr: errMissingShardForwardAuth,
+		},
+		{
+			description:      "Redis",
+			config:           ShardConfig{Instance: "a", URL: "http://a/api/v1/events", Discovery: redisShardDiscovery, Action: forwardShardAction},
+			sign:             sign,
+			expectedAction:   forwardShardAction,
+			expectedRegistry: true,
+		},
+		{
+			description: "Not a member",
+			config:      ShardConfig{Instance: "c", Members: members},
+			expectedErr: errShardInstanceNotMember,
+		},
+		{
+			description: "Member missing url",
+			config:      ShardConfig{Instance: "a", Members: []ShardMember{{Name: "a"}, {Name: "b"}}, Action: forwardShardAction},
+			sign:        sign,
+			expectedErr: errMissingShardURL,
+		},
+		{
+			description: "Redis missing url",
+			config:      ShardConfig{Instance: "a", Discovery: redisShardDiscovery, Action: forwardShardAction},
+			sign:        sign,
+			expectedErr: errMissingShardURL,
+		},
+		{
+			description: "Invalid action",
+			config:      ShardConfig{Instance: "a", Members: members, Action: "ignore"},
+			expectedErr: errInvalidShardAction,
+		},
+		{
+			description: "Invalid discovery",
+			config:      ShardConfig{Instance: "a", Members: members, Discovery: "dns"},
+			expectedErr: errInvalidShardDiscovery,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			s, err := NewSharder(tc.config, tc.sign, nil, nil)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedErr != nil {
+				assert.Nil(s)
+				return
+			}
+
+			assert.Equal(tc.expectedAction, s.action)
+			assert.Equal(defaultShardVirtualNodes, s.virtualNodes)
+			assert.Equal(tc.expectedRegistry, s.registry != nil)
+		})
+	}
+}
+
+func TestHashRing(t *testing.T) {
+	assert := assert.New(t)
+	three := newHashRing([]string{"a", "b", "c"}, defaultShardVirtualNodes)
+	two := newHashRing([]string{"a", "b"}, defaultShardVirtualNodes)
+	assert.Empty(newHashRing(nil, defaultShardVirtualNodes).owner("key"))
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("mac:%012d", i)
+		owner := three.owner(key)
+		counts[owner]++
+
+		// only the keys of the removed member move.
+		if owner != "c" {
+			assert.Equal(owner, two.owner(key))
+		}
+	}
+
+	for _, member := range []string{"a", "b", "c"} {
+		assert.Greater(counts[member], 500, member)
+	}
+}
+
+func TestSharderRoute(t *testing.T) {
+	var received []wrp.Message
+	var headers []http.Header
+	status := http.StatusOK
+	sign := func(r *http.Request, body []byte) {
+		r.Header.Set("X-Test-Signature", fmt.Sprintf("%x", sha256.Sum256(body)))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(body)), r.Header.Get("X-Test-Signature"))
+		var msg wrp.Message
+		assert.Nil(t, wrp.NewDecoderBytes(body, wrp.Msgpack).Decode(&msg))
+		received = append(received, msg)
+		headers = append(headers, r.Header)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	members := []ShardMember{{Name: "local"}, {Name: "remote", URL: server.URL}}
+	ring := newHashRing([]string{"local", "remote"}, defaultShardVirtualNodes)
+	var localDevice, remoteDevice string
+	for i := 0; len(localDevice) == 0 || len(remoteDevice) == 0; i++ {
+		device := fmt.Sprintf("mac:%012d", i)
+		if ring.owner(device) == "local" {
+			localDevice = device
+		} else {
+			remoteDevice = device
+		}
+	}
+
+	event := func(deviceID string) interpreter.Event {
+		return interpreter.Event{
+			MsgType:         int(wrp.SimpleEventMessageType),
+			Source:          deviceID,
+			Destination:     "event:device-status/" + deviceID + "/online",
+			TransactionUUID: "test",
+			Metadata:        map[string]string{interpreter.BootTimeKey: "1"},
+		}
+	}
+
+	forwardedCtx := ForwardedFromRequest(context.Background(), &http.Request{Header: http.Header{forwardedByHeader: []string{"remote"}}})
+	tests := []struct {
+		description       string
+		action            string
+		ctx               context.Context
+		event             interpreter.Event
+		status            int
+		expectedLocal     bool
+		expectedErr       bool
+		expectedForwarded bool
+		expectedOutcome   string
+	}{
+		{
+			description:   "Local device",
+			action:        forwardShardAction,
+			event:         event(localDevice),
+			expectedLocal: true,
+		},
+		{
+			description:   "Missing device id",
+			action:        forwardShardAction,
+			event:         interpreter.Event{Destination: "event:device-status"},
+			expectedLocal: true,
+		},
+		{
+			description:   "Already forwarded",
+			action:        forwardShardAction,
+			ctx:           forwardedCtx,
+			event:         event(remoteDevice),
+			expectedLocal: true,
+		},
+		{
+			description:     "Dropped",
+			action:          dropShardAction,
+			event:           event(remoteDevice),
+			expectedOutcome: droppedShardOutcome,
+		},
+		{
+			description:       "Forwarded",
+			action:            forwardShardAction,
+			event:             event(remoteDevice),
+			status:            http.StatusOK,
+			expectedForwarded: true,
+			expectedOutcome:   forwardedShardOutcome,
+		},
+		{
+			description:       "Forward failed",
+			action:            forwardShardAction,
+			event:             event(remoteDevice),
+			status:            http.StatusTooManyRequests,
+			expectedErr:       true,
+			expectedForwarded: true,
+			expectedOutcome:   forwardFailedOutcome,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			received, headers, status = nil, nil, tc.status
+			shardedEvents := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "sharded"}, []string{shardActionLabel})
+			s, err := NewSharder(ShardConfig{Instance: "local", Members: members, Action: tc.action, ForwardAuthorization: "Bearer jwt"}, sign, shardedEvents, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			local, err := s.Route(ctx, tc.event)
+			assert.Equal(tc.expectedLocal, local)
+			if tc.expectedErr {
+				var unavailable queue.ServiceUnavailableErr
+				assert.True(errors.As(err, &unavailable))
+			} else {
+				assert.Nil(err)
+			}
+
+			if tc.expectedForwarded {
+				if assert.Len(received, 1) {
+					assert.Equal(tc.event.Destination, received[0].Destination)
+					assert.Equal(tc.event.TransactionUUID, received[0].TransactionUUID)
+					assert.Equal(tc.event.Metadata, received[0].Metadata)
+					assert.Equal("local", headers[0].Get(forwardedByHeader))
+					assert.Equal("Bearer jwt", headers[0].Get("Authorization"))
+					assert.Equal(wrp.MimeTypeMsgpack, headers[0].Get("Content-Type"))
+				}
+			} else {
+				assert.Empty(received)
+			}
+
+			if len(tc.expectedOutcome) > 0 {
+				assert.Equal(1.0, testutil.ToFloat64(shardedEvents.WithLabelValues(tc.expectedOutcome)))
+			}
+		})
+	}
+}
+
+func TestSharderRefresh(t *testing.T) {
+	assert := assert.New(t)
+	registryErr := errors.New("test error")
+	registry := new(mockShardRegistry)
+	self := ShardMember{Name: "local", URL: "http://local"}
+	registry.On("Register", mock.Anything, self, 3*time.Hour).Return(nil)
+	registry.On("Members", mock.Anything).Return([]ShardMember{{Name: "remote", URL: "http://remote"}}, nil).Once()
+	registry.On("Members", mock.Anything).Return(nil, registryErr).Once()
+	registry.On("Deregister", mock.Anything, "local").Return(nil).Once()
+
+	s, err := NewSharder(ShardConfig{Instance: "local", URL: "http://local", Discovery: redisShardDiscovery, HeartbeatInterval: time.Hour}, nil, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+	s.registry = registry
+
+	// every device belongs to this glaukos until the members are found.
+	assert.Equal("local", s.owner("mac:112233445566").Name)
+
+	// this glaukos is included even if its registration expired.
+	s.Start()
+	assert.Len(s.ring.owners, 2*defaultShardVirtualNodes)
+	assert.Equal("http://remote", s.urls["remote"])
+	assert.Equal("http://local", s.urls["local"])
+
+	// the members are kept when redis fails.
+	s.refresh(context.Background())
+	assert.Len(s.urls, 2)
+
+	assert.Nil(s.Stop(context.Background()))
+	registry.AssertExpectations(t)
+}
+
+func TestRedisShardRegistry(t *testing.T) {
+	assert := assert.New(t)
+	client := new(mockRedisShardClient)
+	client.On("Set", mock.Anything, "glaukos:shards:a", "http://a", time.Minute).Return(redis.NewStatusResult("OK", nil)).Once()
+	client.On("Del", mock.Anything, []string{"glaukos:shards:a"}).Return(redis.NewIntResult(1, nil)).Once()
+	client.On("Scan", mock.Anything, uint64(0), "glaukos:shards:*", int64(100)).
+		Return(redis.NewScanCmdResult([]string{"glaukos:shards:a"}, 5, nil)).Once()
+	client.On("Scan", mock.Anything, uint64(5), "glaukos:shards:*", int64(100)).
+		Return(redis.NewScanCmdResult([]string{"glaukos:shards:b", "glaukos:shards:c"}, 0, nil)).Once()
+	client.On("MGet", mock.Anything, []string{"glaukos:shards:a", "glaukos:shards:b", "glaukos:shards:c"}).
+		Return(redis.NewSliceResult([]interface{}{"http://a", nil, "http://c"}, nil)).Once()
+
+	registry := &redisShardRegistry{client: client, prefix: defaultRedisShardKeyPrefix}
+	assert.Nil(registry.Register(context.Background(), ShardMember{Name: "a", URL: "http://a"}, time.Minute))
+	members, err := registry.Members(context.Background())
+	assert.Nil(err)
+	assert.Equal([]ShardMember{{Name: "a", URL: "http://a"}, {Name: "c", URL: "http://c"}}, members)
+	assert.Nil(registry.Deregister(context.Background(), "a"))
+	client.AssertExpectations(t)
+}
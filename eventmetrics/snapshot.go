@@ -0,0 +1,358 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	jsonSnapshotFormat        = "json"
+	openMetricsSnapshotFormat = "openmetrics"
+
+	snapshotFormatParam = "format"
+
+	defaultSnapshotInterval = time.Hour
+)
+
+var (
+	errInvalidSnapshotFormat = errors.New("invalid snapshot format")
+	errMissingSnapshotPath   = errors.New("no snapshot path configured")
+
+	// defaultSnapshotExcludePrefixes are the prefixes of the metrics registered by the prometheus setup rather
+	// than glaukos.
+	defaultSnapshotExcludePrefixes = []string{"go_", "process_", "promhttp_"}
+)
+
+// SnapshotConfig configures dumping the current values of glaukos's metrics, for offline analysis or to compare
+// against after recovering from a disaster.
+type SnapshotConfig struct {
+	// Enabled turns on periodically writing a snapshot of the metrics to Path.
+	Enabled bool
+
+	// Path is the file the snapshot is written to, replacing the previous snapshot.  If it contains a %s, it is
+	// replaced with the time of the snapshot, formatted as 20060102T150405Z, so that every snapshot is kept.
+	Path string
+
+	// Interval is how often a snapshot is written.  Defaults to 1h.
+	Interval time.Duration
+
+	// Format is the format snapshots are written in, either "json" or "openmetrics".  Defaults to json.
+	Format string
+
+	// ExcludePrefixes are the prefixes of the metrics left out of snapshots.  Defaults to the go_, process_, and
+	// promhttp_ metrics, which aren't glaukos's.
+	ExcludePrefixes []string
+}
+
+// MetricsSnapshot is the JSON snapshot of the metrics.
+type MetricsSnapshot struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Metrics   []SnapshotMetric `json:"metrics"`
+}
+
+// SnapshotMetric is a metric and the values of each of its series in a JSON snapshot.
+type SnapshotMetric struct {
+	Name   string           `json:"name"`
+	Type   string           `json:"type"`
+	Help   string           `json:"help"`
+	Series []SnapshotSeries `json:"series"`
+}
+
+// SnapshotSeries is the value of a series in a JSON snapshot.  Counters and gauges have a value, histograms have
+// their cumulative bucket counts keyed by upper bound, and summaries have their quantiles.
+type SnapshotSeries struct {
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Value     *float64           `json:"value,omitempty"`
+	Count     *uint64            `json:"count,omitempty"`
+	Sum       *float64           `json:"sum,omitempty"`
+	Buckets   map[string]uint64  `json:"buckets,omitempty"`
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+}
+
+// Snapshotter dumps the current values of the metrics gathered.
+type Snapshotter struct {
+	gatherer        prometheus.Gatherer
+	excludePrefixes []string
+	current         func() time.Time
+}
+
+// NewSnapshotter creates a new Snapshotter of the metrics gathered by gatherer.
+func NewSnapshotter(gatherer prometheus.Gatherer, config SnapshotConfig) *Snapshotter {
+	excludePrefixes := config.ExcludePrefixes
+	if len(excludePrefixes) == 0 {
+		excludePrefixes = defaultSnapshotExcludePrefixes
+	}
+
+	return &Snapshotter{
+		gatherer:        gatherer,
+		excludePrefixes: excludePrefixes,
+		current:         time.Now,
+	}
+}
+
+// gather gathers the metrics that aren't excluded.
+func (s *Snapshotter) gather() ([]*dto.MetricFamily, error) {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	included := make([]*dto.MetricFamily, 0, len(families))
+	for _, family := range families {
+		if !s.excluded(family.GetName()) {
+			included = append(included, family)
+		}
+	}
+
+	return included, nil
+}
+
+func (s *Snapshotter) excluded(name string) bool {
+	for _, prefix := range s.excludePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write writes a snapshot of the metrics in the format given.
+func (s *Snapshotter) Write(w io.Writer, format string) error {
+	families, err := s.gather()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "", jsonSnapshotFormat:
+		return json.NewEncoder(w).Encode(newMetricsSnapshot(s.current(), families))
+	case openMetricsSnapshotFormat:
+		encoder := expfmt.NewEncoder(w, expfmt.FmtOpenMetrics_1_0_0)
+		for _, family := range families {
+			if err := encoder.Encode(family); err != nil {
+				return err
+			}
+		}
+		return encoder.(expfmt.Closer).Close()
+	default:
+		return fmt.Errorf("%w: %q", errInvalidSnapshotFormat, format)
+	}
+}
+
+// WriteFile writes a snapshot of the metrics in the format given to the file at path, replacing the file only
+// once the snapshot is complete.  A %s in the path is replaced with the time of the snapshot.
+func (s *Snapshotter) WriteFile(path string, format string) (string, error) {
+	if len(path) == 0 {
+		return "", errMissingSnapshotPath
+	}
+
+	if strings.Contains(path, "%s") {
+		path = fmt.Sprintf(path, s.current().UTC().Format("20060102T150405Z"))
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return path, err
+	}
+
+	err = s.Write(file, format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name()) // nolint:errcheck
+	}
+
+	return path, err
+}
+
+// Handler returns a handler that returns a snapshot of the metrics, in the format of the format query parameter.
+func (s *Snapshotter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get(snapshotFormatParam)
+		var body strings.Builder
+		if err := s.Write(&body, format); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errInvalidSnapshotFormat) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		contentType := "application/json"
+		if format == openMetricsSnapshotFormat {
+			contentType = string(expfmt.FmtOpenMetrics_1_0_0)
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body.String()) // nolint:errcheck
+	})
+}
+
+// newMetricsSnapshot converts the metric families into a JSON snapshot taken at the time given.
+func newMetricsSnapshot(timestamp time.Time, families []*dto.MetricFamily) MetricsSnapshot {
+	snapshot := MetricsSnapshot{Timestamp: timestamp, Metrics: make([]SnapshotMetric, 0, len(families))}
+	for _, family := range families {
+		metric := SnapshotMetric{
+			Name:   family.GetName(),
+			Type:   strings.ToLower(family.GetType().String()),
+			Help:   family.GetHelp(),
+			Series: make([]SnapshotSeries, 0, len(family.GetMetric())),
+		}
+
+		for _, m := range family.GetMetric() {
+			metric.Series = append(metric.Series, newSnapshotSeries(m))
+		}
+		snapshot.Metrics = append(snapshot.Metrics, metric)
+	}
+
+	return snapshot
+}
+
+func newSnapshotSeries(m *dto.Metric) SnapshotSeries {
+	var series SnapshotSeries
+	if len(m.GetLabel()) > 0 {
+		series.Labels = make(map[string]string, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			series.Labels[label.GetName()] = label.GetValue()
+		}
+	}
+
+	switch {
+	case m.Counter != nil:
+		series.Value = m.Counter.Value
+	case m.Gauge != nil:
+		series.Value = m.Gauge.Value
+	case m.Untyped != nil:
+		series.Value = m.Untyped.Value
+	case m.Histogram != nil:
+		series.Count = m.Histogram.SampleCount
+		series.Sum = m.Histogram.SampleSum
+		series.Buckets = make(map[string]uint64, len(m.Histogram.GetBucket()))
+		for _, bucket := range m.Histogram.GetBucket() {
+			series.Buckets[formatBound(bucket.GetUpperBound())] = bucket.GetCumulativeCount()
+		}
+	case m.Summary != nil:
+		series.Count = m.Summary.SampleCount
+		series.Sum = m.Summary.SampleSum
+		series.Quantiles = make(map[string]float64, len(m.Summary.GetQuantile()))
+		for _, quantile := range m.Summary.GetQuantile() {
+			series.Quantiles[formatBound(quantile.GetQuantile())] = quantile.GetValue()
+		}
+	}
+
+	return series
+}
+
+func formatBound(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// SnapshotsIn provides everything needed to periodically write snapshots of the metrics.
+type SnapshotsIn struct {
+	fx.In
+	Config    SnapshotConfig
+	Gatherer  prometheus.Gatherer
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+}
+
+// startSnapshots periodically writes snapshots of the metrics while the application runs, if it is enabled.  A
+// final snapshot is written when the application stops.
+func startSnapshots(in SnapshotsIn) error {
+	if !in.Config.Enabled {
+		return nil
+	}
+
+	if len(in.Config.Path) == 0 {
+		return errMissingSnapshotPath
+	}
+
+	switch in.Config.Format {
+	case "", jsonSnapshotFormat, openMetricsSnapshotFormat:
+	default:
+		return fmt.Errorf("%w: %q", errInvalidSnapshotFormat, in.Config.Format)
+	}
+
+	interval := in.Config.Interval
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	snapshotter := NewSnapshotter(in.Gatherer, in.Config)
+	write := func() {
+		path, err := snapshotter.WriteFile(in.Config.Path, in.Config.Format)
+		if err != nil {
+			in.Logger.Error("failed to write metrics snapshot", zap.Error(err), zap.String("path", path))
+			return
+		}
+		in.Logger.Debug("wrote metrics snapshot", zap.String("path", path))
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						write()
+					case <-done:
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			wg.Wait()
+			write()
+			return nil
+		},
+	})
+	return nil
+}
@@ -0,0 +1,232 @@
+package eventmetrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/zap"
+)
+
+func newTestSnapshotRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "total_unparsable_count", Help: "unparsable events"}, []string{"parser_type"})
+	counter.WithLabelValues("reboot").Add(3)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "boot_time_duration", Help: "boot durations", Buckets: []float64{60, 120}})
+	histogram.Observe(90)
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{Name: "reboot_duration", Help: "reboot durations", Objectives: map[float64]float64{0.5: 0.05}})
+	summary.Observe(10)
+	goGauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines", Help: "goroutines"})
+	registry.MustRegister(counter, histogram, summary, goGauge)
+	return registry
+}
+
+func TestSnapshotterWriteJSON(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Unix(1614265173, 0).UTC()
+	snapshotter := NewSnapshotter(newTestSnapshotRegistry(), SnapshotConfig{})
+	snapshotter.current = func() time.Time { return now }
+
+	var buf bytes.Buffer
+	if !assert.Nil(snapshotter.Write(&buf, "")) {
+		return
+	}
+
+	var snapshot MetricsSnapshot
+	if !assert.Nil(json.Unmarshal(buf.Bytes(), &snapshot)) {
+		return
+	}
+
+	assert.Equal(now, snapshot.Timestamp)
+	metrics := make(map[string]SnapshotMetric)
+	for _, m := range snapshot.Metrics {
+		metrics[m.Name] = m
+	}
+	assert.Len(metrics, 3)
+	assert.NotContains(metrics, "go_goroutines")
+
+	counter := metrics["total_unparsable_count"]
+	assert.Equal("counter", counter.Type)
+	if assert.Len(counter.Series, 1) {
+		assert.Equal(map[string]string{"parser_type": "reboot"}, counter.Series[0].Labels)
+		assert.Equal(3.0, *counter.Series[0].Value)
+	}
+
+	histogram := metrics["boot_time_duration"]
+	assert.Equal("histogram", histogram.Type)
+	if assert.Len(histogram.Series, 1) {
+		assert.Equal(map[string]uint64{"60": 0, "120": 1}, histogram.Series[0].Buckets)
+		assert.Equal(uint64(1), *histogram.Series[0].Count)
+		assert.Equal(90.0, *histogram.Series[0].Sum)
+		assert.Nil(histogram.Series[0].Value)
+	}
+
+	summary := metrics["reboot_duration"]
+	assert.Equal("summary", summary.Type)
+	if assert.Len(summary.Series, 1) {
+		assert.Equal(map[string]float64{"0.5": 10}, summary.Series[0].Quantiles)
+	}
+}
+
+func TestSnapshotterWriteOpenMetrics(t *testing.T) {
+	assert := assert.New(t)
+	snapshotter := NewSnapshotter(newTestSnapshotRegistry(), SnapshotConfig{ExcludePrefixes: []string{"boot_"}})
+
+	var buf bytes.Buffer
+	if !assert.Nil(snapshotter.Write(&buf, openMetricsSnapshotFormat)) {
+		return
+	}
+
+	assert.Contains(buf.String(), `total_unparsable_count{parser_type="reboot"} 3`)
+	assert.Contains(buf.String(), "go_goroutines")
+	assert.NotContains(buf.String(), "boot_time_duration")
+	assert.True(strings.HasSuffix(buf.String(), "# EOF\n"))
+}
+
+func TestSnapshotterWriteInvalidFormat(t *testing.T) {
+	snapshotter := NewSnapshotter(newTestSnapshotRegistry(), SnapshotConfig{})
+	assert.ErrorIs(t, snapshotter.Write(&bytes.Buffer{}, "xml"), errInvalidSnapshotFormat)
+}
+
+func TestSnapshotterWriteFile(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	snapshotter := NewSnapshotter(newTestSnapshotRegistry(), SnapshotConfig{})
+	snapshotter.current = func() time.Time { return time.Date(2021, 2, 25, 15, 0, 0, 0, time.UTC) }
+
+	path, err := snapshotter.WriteFile(filepath.Join(dir, "snapshot-%s.json"), jsonSnapshotFormat)
+	assert.Nil(err)
+	assert.Equal(filepath.Join(dir, "snapshot-20210225T150000Z.json"), path)
+	contents, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Contains(string(contents), "total_unparsable_count")
+
+	// failed snapshots don't leave a file behind.
+	_, err = snapshotter.WriteFile(filepath.Join(dir, "invalid.json"), "xml")
+	assert.ErrorIs(err, errInvalidSnapshotFormat)
+	files, err := os.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(files, 1)
+
+	_, err = snapshotter.WriteFile("", jsonSnapshotFormat)
+	assert.ErrorIs(err, errMissingSnapshotPath)
+}
+
+func TestSnapshotRoute(t *testing.T) {
+	tests := []struct {
+		description         string
+		enabled             bool
+		query               string
+		expectedCode        int
+		expectedContentType string
+	}{
+		{
+			description:         "JSON",
+			enabled:             true,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json",
+		},
+		{
+			description:         "OpenMetrics",
+			enabled:             true,
+			query:               "?format=openmetrics",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/openmetrics-text",
+		},
+		{
+			description:  "Invalid format",
+			enabled:      true,
+			query:        "?format=xml",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description:  "Disabled",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			router := mux.NewRouter()
+			ConfigureAdminRoutes(AdminRoutesIn{
+				Config:   AdminConfig{EnableSnapshotEndpoint: tc.enabled},
+				Gatherer: newTestSnapshotRegistry(),
+				Router:   router,
+				APIBase:  "api/v1",
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/admin/snapshot"+tc.query, nil))
+			assert.Equal(tc.expectedCode, recorder.Code)
+			if tc.expectedCode == http.StatusOK {
+				assert.Contains(recorder.Header().Get("Content-Type"), tc.expectedContentType)
+				assert.Contains(recorder.Body.String(), "total_unparsable_count")
+			}
+		})
+	}
+}
+
+func TestStartSnapshots(t *testing.T) {
+	tests := []struct {
+		description   string
+		config        SnapshotConfig
+		expectedErr   error
+		expectedFiles int
+	}{
+		{
+			description: "Disabled",
+		},
+		{
+			description:   "Enabled",
+			config:        SnapshotConfig{Enabled: true, Path: "snapshot.json", Interval: time.Millisecond},
+			expectedFiles: 1,
+		},
+		{
+			description: "Missing path",
+			config:      SnapshotConfig{Enabled: true},
+			expectedErr: errMissingSnapshotPath,
+		},
+		{
+			description: "Invalid format",
+			config:      SnapshotConfig{Enabled: true, Path: "snapshot.json", Format: "xml"},
+			expectedErr: errInvalidSnapshotFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			dir := t.TempDir()
+			if len(tc.config.Path) > 0 {
+				tc.config.Path = filepath.Join(dir, tc.config.Path)
+			}
+
+			lc := fxtest.NewLifecycle(t)
+			err := startSnapshots(SnapshotsIn{
+				Config:    tc.config,
+				Gatherer:  newTestSnapshotRegistry(),
+				Lifecycle: lc,
+				Logger:    zap.NewNop(),
+			})
+			assert.ErrorIs(err, tc.expectedErr)
+			lc.RequireStart()
+			time.Sleep(5 * time.Millisecond)
+			lc.RequireStop()
+
+			files, err := os.ReadDir(dir)
+			assert.Nil(err)
+			assert.Len(files, tc.expectedFiles)
+		})
+	}
+}
@@ -0,0 +1,404 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package sqs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awssqs "github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	jsonFormat    = "json"
+	msgpackFormat = "msgpack"
+
+	snsNotificationType = "Notification"
+
+	defaultMaxMessages       = 10
+	maxMaxMessages           = 10
+	defaultWaitTime          = 20 * time.Second
+	maxWaitTime              = 20 * time.Second
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultRetryBackoff      = time.Second
+)
+
+var (
+	errMissingQueueURL   = errors.New("no sqs queue url configured")
+	errInvalidFormat     = errors.New("invalid message format")
+	errInvalidSNSMessage = errors.New("invalid sns notification")
+)
+
+// Config configures the consumer that polls device-status events from an AWS SQS queue as an alternative to the
+// caduceus webhook.
+type Config struct {
+	// Enabled turns on consuming events from SQS.
+	Enabled bool
+
+	// QueueURL is the url of the queue polled.
+	QueueURL string
+
+	// Region is the AWS region of the queue.  If empty, the region is found the same as the AWS cli, such as from
+	// the AWS_REGION environment variable.
+	Region string
+
+	// Endpoint overrides the SQS endpoint, such as for testing against a local SQS.
+	Endpoint string
+
+	// SNSEnvelope is true if the queue is subscribed to an SNS topic without raw message delivery, so that each
+	// message body is an SNS notification whose message is the event.
+	SNSEnvelope bool
+
+	// Format is the encoding of the wrp messages, either "json" or "msgpack".  Since message bodies are text,
+	// msgpack messages are base64 encoded.  Defaults to "json".
+	Format string
+
+	// MaxMessages is the maximum number of messages received at once, between 1 and 10.  Defaults to 10.
+	MaxMessages int
+
+	// WaitTime is how long to long poll for messages when the queue is empty, at most 20s.  Defaults to 20s.
+	WaitTime time.Duration
+
+	// VisibilityTimeout is how long a received message is hidden from other consumers.  The timeout is extended
+	// until the event is parsed, after which the message is deleted.  Defaults to 30s.
+	VisibilityTimeout time.Duration
+
+	// RetryBackoff is how long to wait before polling again after receiving messages fails, or before trying to
+	// queue an event again after the queue failed to accept it.  Defaults to 1s.
+	RetryBackoff time.Duration
+}
+
+type client interface {
+	ReceiveMessageWithContext(aws.Context, *awssqs.ReceiveMessageInput, ...request.Option) (*awssqs.ReceiveMessageOutput, error)
+	DeleteMessageWithContext(aws.Context, *awssqs.DeleteMessageInput, ...request.Option) (*awssqs.DeleteMessageOutput, error)
+	ChangeMessageVisibilityWithContext(aws.Context, *awssqs.ChangeMessageVisibilityInput, ...request.Option) (*awssqs.ChangeMessageVisibilityOutput, error)
+}
+
+// snsNotification is the envelope of a message sent to the queue by an SNS topic.
+type snsNotification struct {
+	Type    string
+	Message string
+}
+
+// Consumer polls wrp messages from an SQS queue and passes them to the event endpoint, the same as the events
+// received through the webhook.  Messages are kept hidden from other consumers until the event is parsed, then
+// deleted, so events lost when glaukos stops are received again.
+type Consumer struct {
+	config   Config
+	queue    string
+	format   wrp.Format
+	client   client
+	event    endpoint.Endpoint
+	measures Measures
+	logger   *zap.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConsumer creates a Consumer polling the configured queue, using the default AWS credentials.
+func NewConsumer(config Config, event endpoint.Endpoint, measures Measures, logger *zap.Logger) (*Consumer, error) {
+	awsConfig := aws.NewConfig()
+	if len(config.Region) > 0 {
+		awsConfig = awsConfig.WithRegion(config.Region)
+	}
+
+	if len(config.Endpoint) > 0 {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConsumer(config, awssqs.New(sess), event, measures, logger)
+}
+
+func newConsumer(config Config, client client, event endpoint.Endpoint, measures Measures, logger *zap.Logger) (*Consumer, error) {
+	if len(config.QueueURL) == 0 {
+		return nil, errMissingQueueURL
+	}
+
+	format, err := messageFormat(config.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MaxMessages <= 0 || config.MaxMessages > maxMaxMessages {
+		config.MaxMessages = defaultMaxMessages
+	}
+
+	if config.WaitTime <= 0 || config.WaitTime > maxWaitTime {
+		config.WaitTime = defaultWaitTime
+	}
+
+	if config.VisibilityTimeout < time.Second {
+		config.VisibilityTimeout = defaultVisibilityTimeout
+	}
+
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Consumer{
+		config:   config,
+		queue:    path.Base(config.QueueURL),
+		format:   format,
+		client:   client,
+		event:    event,
+		measures: measures,
+		logger:   logger,
+	}, nil
+}
+
+func messageFormat(format string) (wrp.Format, error) {
+	switch format {
+	case "", jsonFormat:
+		return wrp.JSON, nil
+	case msgpackFormat:
+		return wrp.Msgpack, nil
+	default:
+		return wrp.JSON, fmt.Errorf("%w: %q", errInvalidFormat, format)
+	}
+}
+
+// Start starts polling for messages.
+func (c *Consumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.run(ctx)
+}
+
+// Stop stops polling for messages.  Messages whose events are still queued are deleted once the events are parsed.
+func (c *Consumer) Stop() error {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+
+	return nil
+}
+
+func (c *Consumer) run(ctx context.Context) {
+	defer close(c.done)
+	for {
+		output, err := c.client.ReceiveMessageWithContext(ctx, &awssqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(c.config.QueueURL),
+			MaxNumberOfMessages: aws.Int64(int64(c.config.MaxMessages)),
+			WaitTimeSeconds:     aws.Int64(int64(c.config.WaitTime / time.Second)),
+			VisibilityTimeout:   aws.Int64(int64(c.config.VisibilityTimeout / time.Second)),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			c.logger.Error("failed to receive sqs messages", zap.Error(err), zap.String("queue", c.queue))
+			c.measures.receiveFailed(c.queue)
+			if !wait(ctx, c.config.RetryBackoff) {
+				return
+			}
+			continue
+		}
+
+		for i, msg := range output.Messages {
+			c.measures.received(c.queue)
+			if !c.handle(ctx, msg) {
+				// the remaining messages are received again once their visibility timeout expires.
+				for _, m := range output.Messages[i+1:] {
+					c.changeVisibility(m, 0)
+				}
+				return
+			}
+		}
+	}
+}
+
+// handle passes the message to the event endpoint, retrying while the queue fails to accept the event.  It returns
+// false if the consumer was stopped before the event was queued.
+func (c *Consumer) handle(ctx context.Context, msg *awssqs.Message) bool {
+	wrpMsg, reason, err := c.decode(aws.StringValue(msg.Body))
+	if err != nil {
+		c.logger.Error("failed to decode sqs message", zap.Error(err), zap.String("queue", c.queue),
+			zap.String("message id", aws.StringValue(msg.MessageId)))
+		c.measures.parseFailed(c.queue, reason)
+		c.delete(msg)
+		return true
+	}
+
+	// events with an invalid birthdate are handled by the endpoint.
+	event, _ := interpreter.NewEvent(wrpMsg)
+	inflight := c.track(msg)
+	ctx = queue.WithDone(ctx, inflight.done)
+	for {
+		_, err := c.event(ctx, event)
+		if err == nil {
+			return true
+		}
+
+		var e kithttp.StatusCoder
+		if errors.As(err, &e) && e.StatusCode() < http.StatusInternalServerError && e.StatusCode() != http.StatusTooManyRequests {
+			c.measures.parseFailed(c.queue, rejectedEventReason)
+			inflight.done(true)
+			return true
+		}
+
+		c.logger.Warn("failed to queue sqs event, retrying", zap.Error(err), zap.String("event id", event.TransactionUUID))
+		if !wait(ctx, c.config.RetryBackoff) {
+			inflight.done(false)
+			return false
+		}
+	}
+}
+
+// decode gets the wrp message from the message body, returning the reason the message is invalid if it can't.
+func (c *Consumer) decode(body string) (wrp.Message, string, error) {
+	if c.config.SNSEnvelope {
+		var notification snsNotification
+		if err := json.Unmarshal([]byte(body), &notification); err != nil {
+			return wrp.Message{}, invalidEnvelopeReason, err
+		}
+
+		if notification.Type != snsNotificationType {
+			return wrp.Message{}, invalidEnvelopeReason, fmt.Errorf("%w: unexpected type %q", errInvalidSNSMessage, notification.Type)
+		}
+
+		body = notification.Message
+	}
+
+	msgBytes := []byte(body)
+	if c.format == wrp.Msgpack {
+		var err error
+		if msgBytes, err = base64.StdEncoding.DecodeString(body); err != nil {
+			return wrp.Message{}, invalidMessageReason, err
+		}
+	}
+
+	var msg wrp.Message
+	if err := wrp.NewDecoderBytes(msgBytes, c.format).Decode(&msg); err != nil {
+		return wrp.Message{}, invalidMessageReason, err
+	}
+
+	return msg, "", nil
+}
+
+// inflight keeps a received message hidden from other consumers until its event is handled.
+type inflight struct {
+	consumer *Consumer
+	msg      *awssqs.Message
+	once     sync.Once
+	stop     chan struct{}
+}
+
+// track starts extending the visibility timeout of the message until its event is handled.
+func (c *Consumer) track(msg *awssqs.Message) *inflight {
+	i := &inflight{
+		consumer: c,
+		msg:      msg,
+		stop:     make(chan struct{}),
+	}
+
+	go i.extend()
+	return i
+}
+
+// extend extends the visibility timeout of the message each time half of it has passed.
+func (i *inflight) extend() {
+	ticker := time.NewTicker(i.consumer.config.VisibilityTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-i.stop:
+			return
+		case <-ticker.C:
+			i.consumer.changeVisibility(i.msg, i.consumer.config.VisibilityTimeout)
+		}
+	}
+}
+
+// done deletes the message once its event is handled, or makes it visible to be received again if the event was
+// dropped without being parsed.
+func (i *inflight) done(handled bool) {
+	i.once.Do(func() {
+		close(i.stop)
+		if handled {
+			i.consumer.delete(i.msg)
+			return
+		}
+
+		i.consumer.changeVisibility(i.msg, 0)
+	})
+}
+
+func (c *Consumer) delete(msg *awssqs.Message) {
+	_, err := c.client.DeleteMessageWithContext(context.Background(), &awssqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.config.QueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		c.logger.Error("failed to delete sqs message", zap.Error(err), zap.String("queue", c.queue),
+			zap.String("message id", aws.StringValue(msg.MessageId)))
+		c.measures.deleteFailed(c.queue)
+	}
+}
+
+func (c *Consumer) changeVisibility(msg *awssqs.Message, timeout time.Duration) {
+	_, err := c.client.ChangeMessageVisibilityWithContext(context.Background(), &awssqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.config.QueueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(int64(timeout / time.Second)),
+	})
+	if err != nil {
+		c.logger.Error("failed to change sqs message visibility", zap.Error(err), zap.String("queue", c.queue),
+			zap.String("message id", aws.StringValue(msg.MessageId)))
+	}
+}
+
+// wait waits for the backoff, returning false if the consumer was stopped.
+func wait(ctx context.Context, backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
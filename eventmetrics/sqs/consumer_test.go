@@ -0,0 +1,435 @@
+package sqs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awssqs "github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+const testQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/events"
+
+var testMsg = wrp.Message{
+	Type:            wrp.SimpleEventMessageType,
+	Source:          "test",
+	Destination:     "event:device-status/mac:112233445566/online",
+	TransactionUUID: "valid",
+}
+
+func TestNewConsumer(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         Config
+		expectedConfig Config
+		expectedFormat wrp.Format
+		expectedErr    error
+	}{
+		{
+			description: "Defaults",
+			config:      Config{QueueURL: testQueueURL},
+			expectedConfig: Config{
+				QueueURL:          testQueueURL,
+				MaxMessages:       defaultMaxMessages,
+				WaitTime:          defaultWaitTime,
+				VisibilityTimeout: defaultVisibilityTimeout,
+				RetryBackoff:      defaultRetryBackoff,
+			},
+			expectedFormat: wrp.JSON,
+		},
+		{
+			description: "Out of range",
+			config:      Config{QueueURL: testQueueURL, MaxMessages: 20, WaitTime: time.Minute, VisibilityTimeout: time.Millisecond},
+			expectedConfig: Config{
+				QueueURL:          testQueueURL,
+				MaxMessages:       defaultMaxMessages,
+				WaitTime:          defaultWaitTime,
+				VisibilityTimeout: defaultVisibilityTimeout,
+				RetryBackoff:      defaultRetryBackoff,
+			},
+			expectedFormat: wrp.JSON,
+		},
+		{
+			description: "Configured",
+			config: Config{
+				QueueURL:          testQueueURL,
+				SNSEnvelope:       true,
+				Format:            msgpackFormat,
+				MaxMessages:       5,
+				WaitTime:          5 * time.Second,
+				VisibilityTimeout: time.Minute,
+				RetryBackoff:      time.Minute,
+			},
+			expectedConfig: Config{
+				QueueURL:          testQueueURL,
+				SNSEnvelope:       true,
+				Format:            msgpackFormat,
+				MaxMessages:       5,
+				WaitTime:          5 * time.Second,
+				VisibilityTimeout: time.Minute,
+				RetryBackoff:      time.Minute,
+			},
+			expectedFormat: wrp.Msgpack,
+		},
+		{
+			description: "Missing queue url",
+			config:      Config{},
+			expectedErr: errMissingQueueURL,
+		},
+		{
+			description: "Invalid format",
+			config:      Config{QueueURL: testQueueURL, Format: "xml"},
+			expectedErr: errInvalidFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			consumer, err := newConsumer(tc.config, new(mockClient), nil, Measures{}, nil)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedErr != nil {
+				assert.Nil(consumer)
+				return
+			}
+
+			if !assert.NotNil(consumer) {
+				return
+			}
+			assert.Equal(tc.expectedConfig, consumer.config)
+			assert.Equal(tc.expectedFormat, consumer.format)
+			assert.Equal("events", consumer.queue)
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	jsonBody := encodeTestMsg(t, testMsg, wrp.JSON)
+	msgpackBody := base64.StdEncoding.EncodeToString([]byte(encodeTestMsg(t, testMsg, wrp.Msgpack)))
+	tests := []struct {
+		description    string
+		config         Config
+		body           string
+		expectedReason string
+	}{
+		{
+			description: "JSON",
+			body:        jsonBody,
+		},
+		{
+			description: "Msgpack",
+			config:      Config{Format: msgpackFormat},
+			body:        msgpackBody,
+		},
+		{
+			description: "SNS envelope",
+			config:      Config{SNSEnvelope: true},
+			body:        snsBody(t, snsNotificationType, jsonBody),
+		},
+		{
+			description: "SNS envelope msgpack",
+			config:      Config{SNSEnvelope: true, Format: msgpackFormat},
+			body:        snsBody(t, snsNotificationType, msgpackBody),
+		},
+		{
+			description:    "Invalid JSON",
+			body:           "invalid",
+			expectedReason: invalidMessageReason,
+		},
+		{
+			description:    "Invalid base64",
+			config:         Config{Format: msgpackFormat},
+			body:           "!!!",
+			expectedReason: invalidMessageReason,
+		},
+		{
+			description:    "Invalid SNS envelope",
+			config:         Config{SNSEnvelope: true},
+			body:           "invalid",
+			expectedReason: invalidEnvelopeReason,
+		},
+		{
+			description:    "Unexpected SNS type",
+			config:         Config{SNSEnvelope: true},
+			body:           snsBody(t, "SubscriptionConfirmation", jsonBody),
+			expectedReason: invalidEnvelopeReason,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			tc.config.QueueURL = testQueueURL
+			consumer, err := newConsumer(tc.config, new(mockClient), nil, Measures{}, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			msg, reason, err := consumer.decode(tc.body)
+			assert.Equal(tc.expectedReason, reason)
+			if len(tc.expectedReason) > 0 {
+				assert.NotNil(err)
+				return
+			}
+
+			assert.Nil(err)
+			assert.Equal(testMsg.TransactionUUID, msg.TransactionUUID)
+			assert.Equal(testMsg.Destination, msg.Destination)
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		description        string
+		body               string
+		eventErrs          []error
+		handled            *bool
+		expectedCalls      int
+		expectedDelete     bool
+		expectedVisibility bool
+		expectedReason     string
+	}{
+		{
+			description:    "Parsed",
+			body:           encodeTestMsg(t, testMsg, wrp.JSON),
+			handled:        boolPtr(true),
+			expectedCalls:  1,
+			expectedDelete: true,
+		},
+		{
+			description:        "Dropped by queue",
+			body:               encodeTestMsg(t, testMsg, wrp.JSON),
+			handled:            boolPtr(false),
+			expectedCalls:      1,
+			expectedVisibility: true,
+		},
+		{
+			description:    "Invalid message",
+			body:           "invalid",
+			expectedDelete: true,
+			expectedReason: invalidMessageReason,
+		},
+		{
+			description:    "Rejected event",
+			body:           encodeTestMsg(t, testMsg, wrp.JSON),
+			eventErrs:      []error{eventmetrics.InvalidEventErr{Reason: "test", Err: errors.New("rejected")}},
+			expectedCalls:  1,
+			expectedDelete: true,
+			expectedReason: rejectedEventReason,
+		},
+		{
+			description:    "Retried",
+			body:           encodeTestMsg(t, testMsg, wrp.JSON),
+			eventErrs:      []error{queue.TooManyRequestsErr{Message: "Queue Full"}, errors.New("queue full")},
+			handled:        boolPtr(true),
+			expectedCalls:  3,
+			expectedDelete: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockClient)
+			deleteInput := &awssqs.DeleteMessageInput{QueueUrl: aws.String(testQueueURL), ReceiptHandle: aws.String("receipt")}
+			visibilityInput := &awssqs.ChangeMessageVisibilityInput{QueueUrl: aws.String(testQueueURL), ReceiptHandle: aws.String("receipt"), VisibilityTimeout: aws.Int64(0)}
+			client.On("DeleteMessageWithContext", mock.Anything, deleteInput).Return(nil)
+			client.On("ChangeMessageVisibilityWithContext", mock.Anything, visibilityInput).Return(nil)
+
+			calls := 0
+			event := func(ctx context.Context, request interface{}) (interface{}, error) {
+				calls++
+				e := request.(interpreter.Event)
+				assert.Equal(testMsg.TransactionUUID, e.TransactionUUID)
+				if calls <= len(tc.eventErrs) {
+					return nil, tc.eventErrs[calls-1]
+				}
+
+				if done := queue.DoneFromContext(ctx); done != nil && tc.handled != nil {
+					done(*tc.handled)
+				}
+				return nil, nil
+			}
+
+			measures := Measures{
+				ParseFailures: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "parse_failures"}, []string{queueLabel, reasonLabel}),
+			}
+			consumer, err := newConsumer(Config{QueueURL: testQueueURL, RetryBackoff: time.Millisecond}, client, event, measures, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			msg := &awssqs.Message{MessageId: aws.String("id"), ReceiptHandle: aws.String("receipt"), Body: aws.String(tc.body)}
+			assert.True(consumer.handle(context.Background(), msg))
+			assert.Equal(tc.expectedCalls, calls)
+			if tc.expectedDelete {
+				client.AssertCalled(t, "DeleteMessageWithContext", mock.Anything, deleteInput)
+			} else {
+				client.AssertNotCalled(t, "DeleteMessageWithContext", mock.Anything, mock.Anything)
+			}
+
+			if tc.expectedVisibility {
+				client.AssertCalled(t, "ChangeMessageVisibilityWithContext", mock.Anything, visibilityInput)
+			} else {
+				client.AssertNotCalled(t, "ChangeMessageVisibilityWithContext", mock.Anything, mock.Anything)
+			}
+
+			if len(tc.expectedReason) > 0 {
+				assert.Equal(1.0, testutil.ToFloat64(measures.ParseFailures.WithLabelValues("events", tc.expectedReason)))
+			}
+		})
+	}
+}
+
+func TestInflightExtend(t *testing.T) {
+	assert := assert.New(t)
+	extended := make(chan struct{}, 1)
+	client := new(mockClient)
+	client.On("ChangeMessageVisibilityWithContext", mock.Anything, &awssqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(testQueueURL),
+		ReceiptHandle:     aws.String("receipt"),
+		VisibilityTimeout: aws.Int64(1),
+	}).Run(func(mock.Arguments) {
+		select {
+		case extended <- struct{}{}:
+		default:
+		}
+	}).Return(nil)
+	client.On("DeleteMessageWithContext", mock.Anything, mock.Anything).Return(nil).Once()
+
+	consumer, err := newConsumer(Config{QueueURL: testQueueURL, VisibilityTimeout: time.Second}, client, nil, Measures{}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	inflight := consumer.track(&awssqs.Message{ReceiptHandle: aws.String("receipt")})
+	select {
+	case <-extended:
+	case <-time.After(5 * time.Second):
+		assert.Fail("timed out waiting for the visibility timeout to be extended")
+	}
+
+	// the message is only deleted once, however many times done is called.
+	inflight.done(true)
+	inflight.done(true)
+	client.AssertExpectations(t)
+}
+
+func TestConsumer(t *testing.T) {
+	assert := assert.New(t)
+	client := new(mockClient)
+	client.On("ReceiveMessageWithContext", mock.Anything, mock.Anything).Return(nil, errors.New("test error")).Once()
+	client.On("ReceiveMessageWithContext", mock.Anything, &awssqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(testQueueURL),
+		MaxNumberOfMessages: aws.Int64(defaultMaxMessages),
+		WaitTimeSeconds:     aws.Int64(20),
+		VisibilityTimeout:   aws.Int64(30),
+	}).Return(&awssqs.ReceiveMessageOutput{
+		Messages: []*awssqs.Message{
+			{ReceiptHandle: aws.String("first"), Body: aws.String(encodeTestMsg(t, testMsg, wrp.JSON))},
+			{ReceiptHandle: aws.String("second"), Body: aws.String(encodeTestMsg(t, testMsg, wrp.JSON))},
+		},
+	}, nil).Once()
+
+	received := make(chan struct{})
+	client.On("ReceiveMessageWithContext", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		close(received)
+		<-args.Get(0).(context.Context).Done()
+	}).Return(nil, context.Canceled).Once()
+	client.On("DeleteMessageWithContext", mock.Anything, mock.Anything).Return(nil).Twice()
+
+	event := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		queue.DoneFromContext(ctx)(true)
+		return nil, nil
+	}
+
+	measures := Measures{
+		ReceivedMessages: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "received"}, []string{queueLabel}),
+		ReceiveFailures:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "receive_failures"}, []string{queueLabel}),
+	}
+	consumer, err := newConsumer(Config{QueueURL: testQueueURL, RetryBackoff: time.Millisecond}, client, event, measures, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	consumer.Start()
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		assert.Fail("timed out waiting for messages to be received")
+	}
+	assert.Nil(consumer.Stop())
+
+	client.AssertExpectations(t)
+	assert.Equal(2.0, testutil.ToFloat64(measures.ReceivedMessages.WithLabelValues("events")))
+	assert.Equal(1.0, testutil.ToFloat64(measures.ReceiveFailures.WithLabelValues("events")))
+}
+
+func TestConsumerStopWhileRetrying(t *testing.T) {
+	assert := assert.New(t)
+	client := new(mockClient)
+	client.On("ReceiveMessageWithContext", mock.Anything, mock.Anything).Return(&awssqs.ReceiveMessageOutput{
+		Messages: []*awssqs.Message{
+			{ReceiptHandle: aws.String("first"), Body: aws.String(encodeTestMsg(t, testMsg, wrp.JSON))},
+			{ReceiptHandle: aws.String("second"), Body: aws.String(encodeTestMsg(t, testMsg, wrp.JSON))},
+		},
+	}, nil).Once()
+	for _, receipt := range []string{"first", "second"} {
+		client.On("ChangeMessageVisibilityWithContext", mock.Anything, &awssqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String(testQueueURL),
+			ReceiptHandle:     aws.String(receipt),
+			VisibilityTimeout: aws.Int64(0),
+		}).Return(nil).Once()
+	}
+
+	called := make(chan struct{}, 1)
+	event := func(context.Context, interface{}) (interface{}, error) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		return nil, errors.New("queue full")
+	}
+
+	consumer, err := newConsumer(Config{QueueURL: testQueueURL, RetryBackoff: time.Hour}, client, event, Measures{}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	consumer.Start()
+	<-called
+	assert.Nil(consumer.Stop())
+
+	// both messages are made visible again since neither event was queued.
+	client.AssertExpectations(t)
+	client.AssertNotCalled(t, "DeleteMessageWithContext", mock.Anything, mock.Anything)
+}
+
+func encodeTestMsg(t *testing.T, msg wrp.Message, format wrp.Format) string {
+	var b []byte
+	assert.Nil(t, wrp.NewEncoderBytes(&b, format).Encode(msg))
+	return string(b)
+}
+
+func snsBody(t *testing.T, notificationType string, message string) string {
+	b, err := json.Marshal(snsNotification{Type: notificationType, Message: message})
+	assert.Nil(t, err)
+	return string(b)
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,137 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package sqs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/fx"
+)
+
+const (
+	queueLabel  = "queue"
+	reasonLabel = "reason"
+
+	invalidEnvelopeReason = "invalid_envelope"
+	invalidMessageReason  = "invalid_message"
+	rejectedEventReason   = "rejected_event"
+)
+
+// Measures contains the SQS consumer metrics, which are only created when the consumer is enabled.
+type Measures struct {
+	fx.In
+	ReceivedMessages *prometheus.CounterVec `name:"sqs_received_messages_count" optional:"true"`
+	ReceiveFailures  *prometheus.CounterVec `name:"sqs_receive_failures_count" optional:"true"`
+	DeleteFailures   *prometheus.CounterVec `name:"sqs_delete_failures_count" optional:"true"`
+	ParseFailures    *prometheus.CounterVec `name:"sqs_parse_failures_count" optional:"true"`
+}
+
+// ProvideMetrics builds the SQS consumer metrics if the consumer is enabled.
+func ProvideMetrics() fx.Option {
+	return fx.Provide(
+		fx.Annotated{
+			Name: "sqs_received_messages_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "sqs_received_messages_count",
+						Help: "The total number of messages received from sqs",
+					},
+					queueLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "sqs_receive_failures_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "sqs_receive_failures_count",
+						Help: "The total number of failed requests to receive messages from sqs",
+					},
+					queueLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "sqs_delete_failures_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "sqs_delete_failures_count",
+						Help: "The total number of handled messages that failed to be deleted from sqs and will be received again",
+					},
+					queueLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "sqs_parse_failures_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "sqs_parse_failures_count",
+						Help: "The total number of messages received from sqs that were dropped instead of queued",
+					},
+					queueLabel,
+					reasonLabel,
+				)
+			},
+		},
+	)
+}
+
+func (m Measures) received(queue string) {
+	if m.ReceivedMessages != nil {
+		m.ReceivedMessages.With(prometheus.Labels{queueLabel: queue}).Add(1.0)
+	}
+}
+
+func (m Measures) receiveFailed(queue string) {
+	if m.ReceiveFailures != nil {
+		m.ReceiveFailures.With(prometheus.Labels{queueLabel: queue}).Add(1.0)
+	}
+}
+
+func (m Measures) deleteFailed(queue string) {
+	if m.DeleteFailures != nil {
+		m.DeleteFailures.With(prometheus.Labels{queueLabel: queue}).Add(1.0)
+	}
+}
+
+func (m Measures) parseFailed(queue string, reason string) {
+	if m.ParseFailures != nil {
+		m.ParseFailures.With(prometheus.Labels{queueLabel: queue, reasonLabel: reason}).Add(1.0)
+	}
+}
@@ -0,0 +1,28 @@
+package sqs
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awssqs "github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockClient struct {
+	mock.Mock
+}
+
+func (m *mockClient) ReceiveMessageWithContext(ctx aws.Context, input *awssqs.ReceiveMessageInput, _ ...request.Option) (*awssqs.ReceiveMessageOutput, error) {
+	args := m.Called(ctx, input)
+	output, _ := args.Get(0).(*awssqs.ReceiveMessageOutput)
+	return output, args.Error(1)
+}
+
+func (m *mockClient) DeleteMessageWithContext(ctx aws.Context, input *awssqs.DeleteMessageInput, _ ...request.Option) (*awssqs.DeleteMessageOutput, error) {
+	args := m.Called(ctx, input)
+	return &awssqs.DeleteMessageOutput{}, args.Error(0)
+}
+
+func (m *mockClient) ChangeMessageVisibilityWithContext(ctx aws.Context, input *awssqs.ChangeMessageVisibilityInput, _ ...request.Option) (*awssqs.ChangeMessageVisibilityOutput, error) {
+	args := m.Called(ctx, input)
+	return &awssqs.ChangeMessageVisibilityOutput{}, args.Error(0)
+}
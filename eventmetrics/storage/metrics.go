@@ -0,0 +1,106 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/touchstone"
+	"go.uber.org/fx"
+)
+
+const (
+	reasonLabel = "reason"
+)
+
+// Measures contains the storage writer metrics, which are only created when the writer is enabled.
+type Measures struct {
+	fx.In
+	WrittenRecords prometheus.Counter     `name:"storage_written_records_count" optional:"true"`
+	DroppedRecords *prometheus.CounterVec `name:"storage_dropped_records_count" optional:"true"`
+	WriteRetries   prometheus.Counter     `name:"storage_write_retries_count" optional:"true"`
+}
+
+// ProvideMetrics builds the storage writer metrics if the writer is enabled.
+func ProvideMetrics() fx.Option {
+	return fx.Provide(
+		fx.Annotated{
+			Name: "storage_written_records_count",
+			Target: func(f *touchstone.Factory, config Config) (prometheus.Counter, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounter(
+					prometheus.CounterOpts{
+						Name: "storage_written_records_count",
+						Help: "The total number of duration records written to storage",
+					},
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "storage_dropped_records_count",
+			Target: func(f *touchstone.Factory, config Config) (*prometheus.CounterVec, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounterVec(
+					prometheus.CounterOpts{
+						Name: "storage_dropped_records_count",
+						Help: "The total number of duration records dropped instead of written to storage, labeled by reason",
+					},
+					reasonLabel,
+				)
+			},
+		},
+		fx.Annotated{
+			Name: "storage_write_retries_count",
+			Target: func(f *touchstone.Factory, config Config) (prometheus.Counter, error) {
+				if !config.Enabled {
+					return nil, nil
+				}
+
+				return f.NewCounter(
+					prometheus.CounterOpts{
+						Name: "storage_write_retries_count",
+						Help: "The total number of times a batch of duration records was written to storage again after failing",
+					},
+				)
+			},
+		},
+	)
+}
+
+func (m Measures) written(count int) {
+	if m.WrittenRecords != nil {
+		m.WrittenRecords.Add(float64(count))
+	}
+}
+
+func (m Measures) dropped(reason string, count int) {
+	if m.DroppedRecords != nil {
+		m.DroppedRecords.With(prometheus.Labels{reasonLabel: reason}).Add(float64(count))
+	}
+}
+
+func (m Measures) retried() {
+	if m.WriteRetries != nil {
+		m.WriteRetries.Inc()
+	}
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+)
+
+type mockInserter struct {
+	mock.Mock
+}
+
+func (m *mockInserter) Insert(ctx context.Context, records []parsers.DurationRecord) error {
+	args := m.Called(ctx, records)
+	return args.Error(0)
+}
+
+func (m *mockInserter) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
@@ -0,0 +1,83 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+
+	"github.com/xmidt-org/arrange"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// WriterIn provides everything needed to create the storage writer.
+type WriterIn struct {
+	fx.In
+	Config    Config
+	Measures  Measures
+	Logger    *zap.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// Provide creates an uber/fx option that writes durations to storage, if enabled, starting and stopping the
+// writer with the application.
+func Provide() fx.Option {
+	return fx.Options(
+		ProvideMetrics(),
+		fx.Provide(
+			arrange.UnmarshalKey("durationStorage", Config{}),
+			fx.Annotated{
+				Group:  "duration_exporters,flatten",
+				Target: provideWriter,
+			},
+		),
+	)
+}
+
+func provideWriter(in WriterIn) ([]parsers.DurationExporter, error) {
+	if !in.Config.Enabled {
+		return nil, nil
+	}
+
+	inserter, err := newSQLInserter(in.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := newWriter(inserter, in.Config, in.Measures, in.Logger)
+
+	// the writer is stopped after the parsers, so the durations still being parsed are written.
+	in.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if in.Config.CreateTable {
+				if err := inserter.CreateTable(ctx); err != nil {
+					return err
+				}
+			}
+
+			writer.Start()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			return writer.Stop()
+		},
+	})
+
+	return []parsers.DurationExporter{writer}, nil
+}
@@ -0,0 +1,183 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	// registers the postgres driver with database/sql.
+	_ "github.com/lib/pq"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+)
+
+const (
+	postgresDriver = "postgres"
+	defaultTable   = "boot_durations"
+
+	// maxQueryParams is the maximum number of parameters postgres allows in a statement.
+	maxQueryParams = 65535
+)
+
+var (
+	errInvalidTable = errors.New("invalid table name")
+
+	tableRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+	// durationColumns are the columns of the table, in the order of the values of durationValues.
+	durationColumns = []string{
+		"device_id",
+		"transaction_uuid",
+		"firmware",
+		"hardware",
+		"reboot_reason",
+		"partner_id",
+		"duration_type",
+		"seconds",
+		"boot_time",
+		"birthdate",
+		"starting_event_id",
+	}
+
+	maxBatchSize = maxQueryParams / len(durationColumns)
+)
+
+const createTableQuery = `CREATE TABLE IF NOT EXISTS %s (
+	device_id TEXT NOT NULL,
+	transaction_uuid TEXT NOT NULL,
+	firmware TEXT NOT NULL,
+	hardware TEXT NOT NULL,
+	reboot_reason TEXT NOT NULL,
+	partner_id TEXT NOT NULL,
+	duration_type TEXT NOT NULL,
+	seconds DOUBLE PRECISION NOT NULL,
+	boot_time TIMESTAMPTZ,
+	birthdate TIMESTAMPTZ NOT NULL,
+	starting_event_id TEXT NOT NULL
+)`
+
+// sqlInserter inserts duration records into a postgres table.
+type sqlInserter struct {
+	db    *sql.DB
+	table string
+}
+
+func newSQLInserter(config Config) (*sqlInserter, error) {
+	if len(config.DataSource) == 0 {
+		return nil, errMissingDataSource
+	}
+
+	table, err := tableName(config.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(postgresDriver, config.DataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlInserter{db: db, table: table}, nil
+}
+
+// tableName validates the table name, which can't be passed to the database as a query parameter, defaulting to
+// boot_durations.
+func tableName(table string) (string, error) {
+	if len(table) == 0 {
+		return defaultTable, nil
+	}
+
+	if !tableRegex.MatchString(table) {
+		return "", fmt.Errorf("%w: %q", errInvalidTable, table)
+	}
+
+	return table, nil
+}
+
+// CreateTable creates the table if it doesn't exist.
+func (s *sqlInserter) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(createTableQuery, s.table))
+	return err
+}
+
+// Insert implements the batchInserter interface, inserting the records with a single statement.
+func (s *sqlInserter) Insert(ctx context.Context, records []parsers.DurationRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(records)*len(durationColumns))
+	for _, record := range records {
+		args = append(args, durationValues(record)...)
+	}
+
+	_, err := s.db.ExecContext(ctx, insertQuery(s.table, len(records)), args...)
+	return err
+}
+
+// Close implements the batchInserter interface.
+func (s *sqlInserter) Close() error {
+	return s.db.Close()
+}
+
+// insertQuery returns the statement inserting the number of rows given, using postgres' numbered parameters.
+func insertQuery(table string, rows int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(durationColumns, ", "))
+	param := 1
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		params := make([]string, len(durationColumns))
+		for j := range params {
+			params[j] = fmt.Sprintf("$%d", param)
+			param++
+		}
+		fmt.Fprintf(&b, "(%s)", strings.Join(params, ", "))
+	}
+
+	return b.String()
+}
+
+// durationValues returns the values of the record's row.  A missing boot-time is stored as null.
+func durationValues(record parsers.DurationRecord) []interface{} {
+	var bootTime interface{}
+	if !record.BootTime.IsZero() {
+		bootTime = record.BootTime
+	}
+
+	return []interface{}{
+		record.DeviceID,
+		record.TransactionUUID,
+		record.Firmware,
+		record.Hardware,
+		record.RebootReason,
+		record.PartnerID,
+		record.DurationType,
+		record.Seconds,
+		bootTime,
+		record.Birthdate,
+		record.StartingEventID,
+	}
+}
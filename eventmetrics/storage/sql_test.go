@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+)
+
+func TestNewSQLInserter(t *testing.T) {
+	tests := []struct {
+		description   string
+		config        Config
+		expectedTable string
+		expectedErr   error
+	}{
+		{
+			description:   "Default table",
+			config:        Config{DataSource: "postgres://localhost/glaukos"},
+			expectedTable: defaultTable,
+		},
+		{
+			description:   "Schema qualified table",
+			config:        Config{DataSource: "postgres://localhost/glaukos", Table: "analytics.durations"},
+			expectedTable: "analytics.durations",
+		},
+		{
+			description: "Missing data source",
+			expectedErr: errMissingDataSource,
+		},
+		{
+			description: "Invalid table",
+			config:      Config{DataSource: "postgres://localhost/glaukos", Table: "durations; DROP TABLE durations"},
+			expectedErr: errInvalidTable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			inserter, err := newSQLInserter(tc.config)
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedErr != nil {
+				assert.Nil(inserter)
+				return
+			}
+
+			if assert.NotNil(inserter) {
+				assert.Equal(tc.expectedTable, inserter.table)
+				assert.Nil(inserter.Close())
+			}
+		})
+	}
+}
+
+func TestInsertQuery(t *testing.T) {
+	columns := "device_id, transaction_uuid, firmware, hardware, reboot_reason, partner_id, duration_type, seconds, boot_time, birthdate, starting_event_id"
+	assert.Equal(t, "INSERT INTO boot_durations ("+columns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)", insertQuery("boot_durations", 1))
+	assert.Equal(t, "INSERT INTO boot_durations ("+columns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11), "+
+		"($12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)", insertQuery("boot_durations", 2))
+}
+
+func TestDurationValues(t *testing.T) {
+	now := time.Now().UTC()
+	record := parsers.DurationRecord{
+		DeviceID:        "mac:112233445566",
+		TransactionUUID: "test-uuid",
+		Firmware:        "fw",
+		Hardware:        "hw",
+		RebootReason:    "reason",
+		PartnerID:       "partner",
+		DurationType:    "boot_to_manageable",
+		Seconds:         60,
+		BootTime:        now.Add(-time.Minute),
+		Birthdate:       now,
+		StartingEventID: "starting",
+	}
+
+	values := durationValues(record)
+	assert.Len(t, values, len(durationColumns))
+	assert.Equal(t, []interface{}{"mac:112233445566", "test-uuid", "fw", "hw", "reason", "partner", "boot_to_manageable", 60.0,
+		now.Add(-time.Minute), now, "starting"}, values)
+
+	// a missing boot-time is stored as null.
+	record.BootTime = time.Time{}
+	assert.Nil(t, durationValues(record)[8])
+}
@@ -0,0 +1,250 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 10 * time.Second
+	defaultBufferSize    = 10000
+	defaultMaxRetries    = 3
+	defaultRetryBackoff  = time.Second
+
+	bufferFullReason   = "buffer_full"
+	writeFailureReason = "write_failure"
+)
+
+var (
+	errMissingDataSource = errors.New("no data source configured")
+)
+
+// Config configures writing each duration calculated by the reboot duration parser as a row in a postgres table,
+// for per-device historical analysis beyond the prometheus aggregations.
+type Config struct {
+	// Enabled turns on writing durations to the database.
+	Enabled bool
+
+	// DataSource is the postgres connection string.
+	DataSource string
+
+	// Table is the table the durations are written to.  Defaults to boot_durations.
+	Table string
+
+	// CreateTable creates the table on startup if it doesn't exist.
+	CreateTable bool
+
+	// BatchSize is the maximum number of rows written at once, up to the 5957 rows postgres allows in a single
+	// statement.  Defaults to 100.
+	BatchSize int
+
+	// FlushInterval is the longest a row waits to be written while a batch fills.  Defaults to 10s.
+	FlushInterval time.Duration
+
+	// BufferSize is the maximum number of rows waiting to be written, after which new rows are dropped.
+	// Defaults to 10000.
+	BufferSize int
+
+	// MaxRetries is the number of times a failed batch is written again before its rows are dropped.  If it is
+	// negative, failed batches aren't retried.  Defaults to 3.
+	MaxRetries int
+
+	// RetryBackoff is how long to wait before writing a failed batch again, doubling after each retry.
+	// Defaults to 1s.
+	RetryBackoff time.Duration
+}
+
+// batchInserter writes a batch of duration records to storage.
+type batchInserter interface {
+	Insert(ctx context.Context, records []parsers.DurationRecord) error
+	Close() error
+}
+
+// Writer buffers the durations exported by the reboot duration parser and writes them to storage in batches,
+// retrying failed batches.  Durations are dropped rather than blocking the parser when the buffer is full.
+type Writer struct {
+	inserter      batchInserter
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+	measures      Measures
+	logger        *zap.Logger
+
+	records chan parsers.DurationRecord
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func newWriter(inserter batchInserter, config Config, measures Measures, logger *zap.Logger) *Writer {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	} else if config.BatchSize > maxBatchSize {
+		config.BatchSize = maxBatchSize
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultBufferSize
+	}
+
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Writer{
+		inserter:      inserter,
+		batchSize:     config.BatchSize,
+		flushInterval: config.FlushInterval,
+		maxRetries:    config.MaxRetries,
+		retryBackoff:  config.RetryBackoff,
+		measures:      measures,
+		logger:        logger,
+		records:       make(chan parsers.DurationRecord, config.BufferSize),
+	}
+}
+
+// Export implements the parsers.DurationExporter interface, buffering the record to be written.
+func (w *Writer) Export(record parsers.DurationRecord) {
+	select {
+	case w.records <- record:
+	default:
+		w.logger.Warn("storage buffer full, dropping duration record", zap.String("event id", record.TransactionUUID))
+		w.measures.dropped(bufferFullReason, 1)
+	}
+}
+
+// Start starts writing the buffered records.
+func (w *Writer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.run(ctx)
+}
+
+// Stop writes the records still buffered, without retrying failed batches, and closes the connection to storage.
+func (w *Writer) Stop() error {
+	if w.cancel != nil {
+		w.cancel()
+		<-w.done
+	}
+
+	return w.inserter.Close()
+}
+
+func (w *Writer) run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]parsers.DurationRecord, 0, w.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			w.drain(batch)
+			return
+		case record := <-w.records:
+			batch = append(batch, record)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		w.write(ctx, batch)
+		batch = make([]parsers.DurationRecord, 0, w.batchSize)
+	}
+}
+
+// drain writes the batch and the records left in the buffer once the writer is stopped.
+func (w *Writer) drain(batch []parsers.DurationRecord) {
+	for {
+		select {
+		case record := <-w.records:
+			batch = append(batch, record)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		default:
+			if len(batch) > 0 {
+				w.write(context.Background(), batch)
+			}
+			return
+		}
+
+		w.write(context.Background(), batch)
+		batch = make([]parsers.DurationRecord, 0, w.batchSize)
+	}
+}
+
+// write inserts the batch, retrying with an increasing backoff until the retries run out or the writer is stopped.
+func (w *Writer) write(ctx context.Context, batch []parsers.DurationRecord) {
+	backoff := w.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.inserter.Insert(context.Background(), batch)
+		if err == nil {
+			w.measures.written(len(batch))
+			return
+		}
+
+		if attempt >= w.maxRetries || !w.wait(ctx, backoff) {
+			w.logger.Error("failed to write duration records, dropping them", zap.Error(err), zap.Int("records", len(batch)))
+			w.measures.dropped(writeFailureReason, len(batch))
+			return
+		}
+
+		w.logger.Warn("failed to write duration records, retrying", zap.Error(err), zap.Int("records", len(batch)))
+		w.measures.retried()
+		backoff *= 2
+	}
+}
+
+// wait waits for the backoff, returning false if the writer was stopped.
+func (w *Writer) wait(ctx context.Context, backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+)
+
+func newTestMeasures() Measures {
+	return Measures{
+		WrittenRecords: prometheus.NewCounter(prometheus.CounterOpts{Name: "written"}),
+		DroppedRecords: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{reasonLabel}),
+		WriteRetries:   prometheus.NewCounter(prometheus.CounterOpts{Name: "retries"}),
+	}
+}
+
+func testRecords(ids ...string) []parsers.DurationRecord {
+	records := make([]parsers.DurationRecord, len(ids))
+	for i, id := range ids {
+		records[i] = parsers.DurationRecord{TransactionUUID: id}
+	}
+
+	return records
+}
+
+func TestNewWriterDefaults(t *testing.T) {
+	tests := []struct {
+		description     string
+		config          Config
+		expectedBatch   int
+		expectedRetries int
+	}{
+		{
+			description:     "Defaults",
+			expectedBatch:   defaultBatchSize,
+			expectedRetries: defaultMaxRetries,
+		},
+		{
+			description:     "Batch size over postgres limit",
+			config:          Config{BatchSize: 10000, MaxRetries: 1},
+			expectedBatch:   maxBatchSize,
+			expectedRetries: 1,
+		},
+		{
+			description:   "Retries disabled",
+			config:        Config{BatchSize: 5, MaxRetries: -1},
+			expectedBatch: 5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			writer := newWriter(new(mockInserter), tc.config, Measures{}, nil)
+			assert.Equal(tc.expectedBatch, writer.batchSize)
+			assert.Equal(tc.expectedRetries, writer.maxRetries)
+			assert.Equal(defaultFlushInterval, writer.flushInterval)
+			assert.Equal(defaultRetryBackoff, writer.retryBackoff)
+			assert.Equal(defaultBufferSize, cap(writer.records))
+		})
+	}
+}
+
+func TestWriterBatches(t *testing.T) {
+	assert := assert.New(t)
+	inserted := make(chan []parsers.DurationRecord, 2)
+	inserter := new(mockInserter)
+	inserter.On("Insert", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		inserted <- args.Get(1).([]parsers.DurationRecord)
+	}).Return(nil)
+	inserter.On("Close").Return(nil).Once()
+	measures := newTestMeasures()
+
+	writer := newWriter(inserter, Config{BatchSize: 2, FlushInterval: time.Hour}, measures, nil)
+	writer.Start()
+	for _, record := range testRecords("1", "2", "3") {
+		writer.Export(record)
+	}
+
+	select {
+	case batch := <-inserted:
+		assert.Equal(testRecords("1", "2"), batch)
+	case <-time.After(5 * time.Second):
+		assert.Fail("timed out waiting for a full batch to be written")
+	}
+
+	// the partial batch is written when the writer is stopped.
+	assert.Nil(writer.Stop())
+	assert.Equal(testRecords("3"), <-inserted)
+	inserter.AssertExpectations(t)
+	assert.Equal(3.0, testutil.ToFloat64(measures.WrittenRecords))
+}
+
+func TestWriterFlushInterval(t *testing.T) {
+	assert := assert.New(t)
+	inserted := make(chan []parsers.DurationRecord, 1)
+	inserter := new(mockInserter)
+	inserter.On("Insert", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		inserted <- args.Get(1).([]parsers.DurationRecord)
+	}).Return(nil).Once()
+	inserter.On("Close").Return(nil).Once()
+
+	writer := newWriter(inserter, Config{BatchSize: 10, FlushInterval: 10 * time.Millisecond}, Measures{}, nil)
+	writer.Start()
+	writer.Export(testRecords("1")[0])
+
+	select {
+	case batch := <-inserted:
+		assert.Equal(testRecords("1"), batch)
+	case <-time.After(5 * time.Second):
+		assert.Fail("timed out waiting for the batch to be flushed")
+	}
+
+	assert.Nil(writer.Stop())
+	inserter.AssertExpectations(t)
+}
+
+func TestWriterRetries(t *testing.T) {
+	tests := []struct {
+		description     string
+		failures        int
+		expectedInserts int
+		expectedWritten float64
+		expectedDropped float64
+		expectedRetries float64
+	}{
+		{
+			description:     "Success after retry",
+			failures:        1,
+			expectedInserts: 2,
+			expectedWritten: 2,
+			expectedRetries: 1,
+		},
+		{
+			description:     "Retries exhausted",
+			failures:        3,
+			expectedInserts: 3,
+			expectedDropped: 2,
+			expectedRetries: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			inserter := new(mockInserter)
+			inserter.On("Insert", mock.Anything, testRecords("1", "2")).Return(errors.New("connection refused")).Times(tc.failures)
+			inserter.On("Insert", mock.Anything, testRecords("1", "2")).Return(nil)
+			measures := newTestMeasures()
+
+			writer := newWriter(inserter, Config{MaxRetries: 2, RetryBackoff: time.Millisecond}, measures, nil)
+			writer.write(context.Background(), testRecords("1", "2"))
+
+			inserter.AssertNumberOfCalls(t, "Insert", tc.expectedInserts)
+			assert.Equal(tc.expectedWritten, testutil.ToFloat64(measures.WrittenRecords))
+			assert.Equal(tc.expectedDropped, testutil.ToFloat64(measures.DroppedRecords.WithLabelValues(writeFailureReason)))
+			assert.Equal(tc.expectedRetries, testutil.ToFloat64(measures.WriteRetries))
+		})
+	}
+}
+
+func TestWriterBufferFull(t *testing.T) {
+	assert := assert.New(t)
+	measures := newTestMeasures()
+	writer := newWriter(new(mockInserter), Config{BufferSize: 1}, measures, nil)
+
+	// the writer isn't started, so the second record doesn't fit in the buffer.
+	writer.Export(testRecords("1")[0])
+	writer.Export(testRecords("2")[0])
+
+	assert.Len(writer.records, 1)
+	assert.Equal(1.0, testutil.ToFloat64(measures.DroppedRecords.WithLabelValues(bufferFullReason)))
+}
@@ -0,0 +1,81 @@
+package eventmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/candlelight"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceRequests(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		description    string
+		traceParent    string
+		status         int
+		expectedStatus codes.Code
+	}{
+		{
+			description: "new trace",
+			status:      http.StatusOK,
+		},
+		{
+			description: "continued trace",
+			traceParent: traceParent,
+			status:      http.StatusOK,
+		},
+		{
+			description:    "server error",
+			status:         http.StatusServiceUnavailable,
+			expectedStatus: codes.Error,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			recorder := tracetest.NewSpanRecorder()
+			tracing, err := candlelight.New(candlelight.Config{
+				Provider: "test",
+				Providers: map[string]candlelight.ProviderConstructor{
+					"test": func(candlelight.Config) (trace.TracerProvider, error) {
+						return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), nil
+					},
+				},
+			})
+			if !assert.Nil(err) {
+				return
+			}
+
+			var handledSpan trace.SpanContext
+			handler := traceRequests("test", tracing)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handledSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(tc.status)
+			}))
+
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/events", nil)
+			if len(tc.traceParent) > 0 {
+				request.Header.Set("traceparent", tc.traceParent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+
+			spans := recorder.Ended()
+			if !assert.Len(spans, 1) {
+				return
+			}
+			assert.Equal("POST /api/v1/events", spans[0].Name())
+			assert.Equal(spans[0].SpanContext(), handledSpan)
+			assert.Equal(tc.expectedStatus, spans[0].Status().Code)
+			assert.Equal(len(tc.traceParent) > 0, spans[0].Parent().IsRemote())
+			if len(tc.traceParent) > 0 {
+				assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+			}
+		})
+	}
+}
@@ -25,11 +25,22 @@ import (
 	"net/http"
 
 	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/wrp-go/v3"
 	"go.uber.org/zap"
 )
 
+const (
+	issueTypeLabel = "issue_type"
+
+	missingMetadataIssue    = "missing_metadata"
+	emptySourceIssue        = "empty_source"
+	emptyDestinationIssue   = "empty_destination"
+	invalidDestinationIssue = "invalid_destination"
+	missingBirthdateIssue   = "missing_birthdate"
+)
+
 // EncodeResponseCode creates a go-kit EncodeResponseFunc that returns the
 // response code given.
 func EncodeResponseCode(statusCode int) kithttp.EncodeResponseFunc {
@@ -42,6 +53,7 @@ func EncodeResponseCode(statusCode int) kithttp.EncodeResponseFunc {
 // EncodeError logs the error provided using the logger in the context.  The
 // log message includes any details given.  If the error includes a status code,
 // that is the status code given in the response.  Otherwise, a 500 is sent.
+// Any headers included in the error are added to the response.
 func EncodeError(getLogger GetLoggerFunc) kithttp.ErrorEncoder {
 	return func(ctx context.Context, err error, response http.ResponseWriter) {
 		statusCode := http.StatusInternalServerError
@@ -51,6 +63,15 @@ func EncodeError(getLogger GetLoggerFunc) kithttp.ErrorEncoder {
 			statusCode = e.StatusCode()
 		}
 
+		var headerer kithttp.Headerer
+		if errors.As(err, &headerer) {
+			for name, values := range headerer.Headers() {
+				for _, value := range values {
+					response.Header().Add(name, value)
+				}
+			}
+		}
+
 		// get logger from context and log error
 		logger := getLogger(ctx)
 		if logger != nil {
@@ -62,20 +83,109 @@ func EncodeError(getLogger GetLoggerFunc) kithttp.ErrorEncoder {
 }
 
 // DecodeEvent decodes the request body into a wrp.Message type.
-func DecodeEvent(_ context.Context, r *http.Request) (interface{}, error) {
-	var msg wrp.Message
-	var err error
-	msgBytes, err := io.ReadAll(r.Body)
-	r.Body.Close()
+func DecodeEvent(ctx context.Context, r *http.Request) (interface{}, error) {
+	return NewEventDecoder(nil)(ctx, r)
+}
+
+// NewEventDecoder returns a go-kit DecodeRequestFunc that decodes the request body into a wrp.Message type and
+// converts it to an interpreter.Event.  The body is decoded as JSON or msgpack based on the request's Content-Type,
+// defaulting to msgpack.  If the counter is not nil, any issues found while converting the message are counted by
+// issue type.
+func NewEventDecoder(conversionIssues *prometheus.CounterVec) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		msg, err := decodeMessage(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return newEvent(msg, conversionIssues), nil
+	}
+}
+
+// newEvent converts the wrp.Message into an interpreter.Event, counting any issues found if the counter is not nil.
+func newEvent(msg wrp.Message, conversionIssues *prometheus.CounterVec) interpreter.Event {
+	event, err := interpreter.NewEvent(msg)
+	countConversionIssues(conversionIssues, findConversionIssues(msg, event, err))
+	return event
+}
+
+// countConversionIssues counts the issues by issue type if the counter is not nil.
+func countConversionIssues(conversionIssues *prometheus.CounterVec, issues []string) {
+	if conversionIssues == nil {
+		return
+	}
+
+	for _, issue := range issues {
+		conversionIssues.With(prometheus.Labels{issueTypeLabel: issue}).Add(1.0)
+	}
+}
+
+// findConversionIssues returns the issues found while converting a wrp.Message into an interpreter.Event.
+func findConversionIssues(msg wrp.Message, event interpreter.Event, conversionErr error) []string {
+	var issues []string
+	if msg.Metadata == nil {
+		issues = append(issues, missingMetadataIssue)
+	}
+
+	if len(msg.Source) == 0 {
+		issues = append(issues, emptySourceIssue)
+	}
+
+	if len(msg.Destination) == 0 {
+		issues = append(issues, emptyDestinationIssue)
+	} else if !interpreter.EventRegex.MatchString(msg.Destination) {
+		issues = append(issues, invalidDestinationIssue)
+	}
+
+	if conversionErr != nil || event.Birthdate <= 0 {
+		issues = append(issues, missingBirthdateIssue)
+	}
+
+	return issues
+}
+
+func decodeMessage(r *http.Request) (wrp.Message, error) {
+	msgBytes, err := readBody(r)
 	if err != nil {
+		return wrp.Message{}, err
+	}
+
+	return decodeMessageBytes(msgBytes, messageFormat(r.Header.Get("Content-Type")))
+}
+
+// readBody reads and closes the body of a request, returning a RequestTooLargeErr if the body is larger than its
+// limit.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return nil, RequestTooLargeErr{Message: fmt.Sprintf("request body is larger than %d bytes", maxBytesErr.Limit)}
+	} else if err != nil {
 		return nil, BadRequestErr{Message: fmt.Sprintf("could not read request body: %v", err)}
 	}
 
-	err = wrp.NewDecoderBytes(msgBytes, wrp.Msgpack).Decode(&msg)
+	return body, nil
+}
+
+// messageFormat returns the wrp format of a request body with the content type given.  Bodies without a content
+// type, or with a content type that isn't a wrp format, are decoded as msgpack, which caduceus delivers.
+func messageFormat(contentType string) wrp.Format {
+	format, err := wrp.FormatFromContentType(contentType, wrp.Msgpack)
+	if err != nil {
+		return wrp.Msgpack
+	}
+
+	return format
+}
+
+// decodeMessageBytes decodes the bytes encoded in the format given into a wrp.Message.
+func decodeMessageBytes(msgBytes []byte, format wrp.Format) (wrp.Message, error) {
+	var msg wrp.Message
+	err := wrp.NewDecoderBytes(msgBytes, format).Decode(&msg)
 	if err != nil {
-		return nil, BadRequestErr{Message: fmt.Sprintf("could not decode request body: %v", err)}
+		return msg, BadRequestErr{Message: fmt.Sprintf("could not decode request body: %v", err)}
 	}
 
-	event, _ := interpreter.NewEvent(msg)
-	return event, nil
+	return msg, nil
 }
@@ -11,7 +11,10 @@ import (
 	"time"
 
 	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/eventmetrics/queue"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/wrp-go/v3"
 	"go.uber.org/zap"
@@ -39,12 +42,19 @@ func TestEncodeError(t *testing.T) {
 		description        string
 		err                error
 		expectedStatusCode int
+		expectedRetryAfter string
 	}{
 		{
 			description:        "Status Coder Error",
 			err:                BadRequestErr{Message: "bad request"},
 			expectedStatusCode: http.StatusBadRequest,
 		},
+		{
+			description:        "Headerer Error",
+			err:                queue.TooManyRequestsErr{Message: "too many requests", RetryAfter: 5 * time.Second},
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRetryAfter: "5",
+		},
 		{
 			description:        "Non-Status Coder Error",
 			err:                errors.New("bad request"),
@@ -59,6 +69,7 @@ func TestEncodeError(t *testing.T) {
 			rec := httptest.NewRecorder()
 			f(context.Background(), tc.err, rec)
 			assert.Equal(tc.expectedStatusCode, rec.Code)
+			assert.Equal(tc.expectedRetryAfter, rec.Header().Get("Retry-After"))
 		})
 	}
 }
@@ -100,17 +111,56 @@ func TestDecodeEvent(t *testing.T) {
 	tests := []struct {
 		description   string
 		request       interface{}
+		format        wrp.Format
+		contentType   string
 		expectedEvent interpreter.Event
 		expectedErr   bool
 	}{
 		{
 			description:   "Success",
 			request:       goodMsg,
+			format:        wrp.Msgpack,
+			expectedEvent: goodEvent,
+		},
+		{
+			description:   "Msgpack content type",
+			request:       goodMsg,
+			format:        wrp.Msgpack,
+			contentType:   wrp.MimeTypeMsgpack,
+			expectedEvent: goodEvent,
+		},
+		{
+			description:   "JSON content type",
+			request:       goodMsg,
+			format:        wrp.JSON,
+			contentType:   wrp.MimeTypeJson,
+			expectedEvent: goodEvent,
+		},
+		{
+			description:   "JSON content type with charset",
+			request:       goodMsg,
+			format:        wrp.JSON,
+			contentType:   "application/json; charset=utf-8",
+			expectedEvent: goodEvent,
+		},
+		{
+			description:   "Unknown content type",
+			request:       goodMsg,
+			format:        wrp.Msgpack,
+			contentType:   "application/octet-stream",
 			expectedEvent: goodEvent,
 		},
 		{
 			description: "Error decoding msgpack",
 			request:     "{{{",
+			format:      wrp.Msgpack,
+			expectedErr: true,
+		},
+		{
+			description: "Error decoding json",
+			request:     goodMsg,
+			format:      wrp.Msgpack,
+			contentType: wrp.MimeTypeJson,
 			expectedErr: true,
 		},
 	}
@@ -119,10 +169,13 @@ func TestDecodeEvent(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			var marshaledMsg []byte
 			var err error
-			err = wrp.NewEncoderBytes(&marshaledMsg, wrp.Msgpack).Encode(tc.request)
+			err = wrp.NewEncoderBytes(&marshaledMsg, tc.format).Encode(tc.request)
 			assert.Nil(err)
 			request, e := http.NewRequest(http.MethodGet, "/", bytes.NewReader(marshaledMsg))
 			assert.Nil(e)
+			if len(tc.contentType) > 0 {
+				request.Header.Set("Content-Type", tc.contentType)
+			}
 			msg, err := DecodeEvent(context.Background(), request)
 			if !tc.expectedErr {
 				assert.Equal(tc.expectedEvent, msg)
@@ -135,3 +188,105 @@ func TestDecodeEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestEventDecoderConversionIssues(t *testing.T) {
+	timeString := "2021-03-02T18:00:01Z"
+	goodMsg := func() wrp.Message {
+		return wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "mac:112233445566",
+			Destination: "event:device-status/mac:112233445566/online",
+			Metadata:    map[string]string{"key1": "val1"},
+			Payload:     []byte(fmt.Sprintf(`{"ts": "%s"}`, timeString)),
+		}
+	}
+
+	tests := []struct {
+		description    string
+		modify         func(*wrp.Message)
+		expectedIssues []string
+	}{
+		{
+			description: "No issues",
+			modify:      func(_ *wrp.Message) {},
+		},
+		{
+			description:    "Missing metadata",
+			modify:         func(m *wrp.Message) { m.Metadata = nil },
+			expectedIssues: []string{missingMetadataIssue},
+		},
+		{
+			description:    "Empty source",
+			modify:         func(m *wrp.Message) { m.Source = "" },
+			expectedIssues: []string{emptySourceIssue},
+		},
+		{
+			description:    "Empty destination",
+			modify:         func(m *wrp.Message) { m.Destination = "" },
+			expectedIssues: []string{emptyDestinationIssue},
+		},
+		{
+			description:    "Invalid destination",
+			modify:         func(m *wrp.Message) { m.Destination = "some-destination" },
+			expectedIssues: []string{invalidDestinationIssue},
+		},
+		{
+			description:    "Missing birthdate",
+			modify:         func(m *wrp.Message) { m.Payload = []byte(`{}`) },
+			expectedIssues: []string{missingBirthdateIssue},
+		},
+		{
+			description: "Multiple issues",
+			modify: func(m *wrp.Message) {
+				m.Metadata = nil
+				m.Destination = ""
+			},
+			expectedIssues: []string{missingMetadataIssue, emptyDestinationIssue},
+		},
+	}
+
+	allIssues := []string{missingMetadataIssue, emptySourceIssue, emptyDestinationIssue, invalidDestinationIssue, missingBirthdateIssue}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			msg := goodMsg()
+			tc.modify(&msg)
+			var marshaledMsg []byte
+			assert.Nil(wrp.NewEncoderBytes(&marshaledMsg, wrp.Msgpack).Encode(msg))
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(marshaledMsg))
+
+			counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testConversionIssues",
+				Help: "testConversionIssues",
+			}, []string{issueTypeLabel})
+			event, err := NewEventDecoder(counter)(context.Background(), request)
+			assert.Nil(err)
+			assert.IsType(interpreter.Event{}, event)
+
+			expected := make(map[string]bool)
+			for _, issue := range tc.expectedIssues {
+				expected[issue] = true
+			}
+			for _, issue := range allIssues {
+				expectedCount := 0.0
+				if expected[issue] {
+					expectedCount = 1.0
+				}
+				assert.Equal(expectedCount, testutil.ToFloat64(counter.WithLabelValues(issue)), issue)
+			}
+		})
+	}
+}
+
+func TestEventDecoderErr(t *testing.T) {
+	assert := assert.New(t)
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testConversionIssues",
+		Help: "testConversionIssues",
+	}, []string{issueTypeLabel})
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("{{{")))
+	event, err := NewEventDecoder(counter)(context.Background(), request)
+	assert.Nil(event)
+	assert.NotNil(err)
+	assert.Equal(0, testutil.CollectAndCount(counter))
+}
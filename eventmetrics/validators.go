@@ -0,0 +1,121 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package eventmetrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+const (
+	birthdateBeforeBootTimeReason = "birthdateBeforeBoottime"
+	implausibleBootTimeReason     = "implausibleBoottime"
+	malformedDeviceIDReason       = "malformedDeviceID"
+)
+
+var errBirthdateBeforeBootTime = errors.New("birthdate is before boot-time")
+
+// BirthdateBootTimeValidator returns a validator that rejects events whose birthdate is more than the tolerance
+// before their boot-time, as a device cannot create an event before it has booted. Events without a boot-time
+// or birthdate are not rejected.
+func BirthdateBootTimeValidator(tolerance time.Duration) validation.ValidatorFunc {
+	return func(e interpreter.Event) (bool, error) {
+		bootTime, err := e.BootTime()
+		if err != nil || bootTime <= 0 || e.Birthdate <= 0 {
+			return true, nil
+		}
+
+		bootTimeUnix := time.Unix(bootTime, 0)
+		birthdate := time.Unix(0, e.Birthdate)
+		if birthdate.Before(bootTimeUnix.Add(-1 * tolerance)) {
+			return false, InvalidEventErr{
+				Reason: birthdateBeforeBootTimeReason,
+				Err:    fmt.Errorf("%w: birthdate %s, boot-time %s", errBirthdateBeforeBootTime, birthdate.UTC(), bootTimeUnix.UTC()),
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// BootTimeBoundsValidator returns a validator that rejects events whose boot-time is before the start of
+// minYear or more than maxAhead past the current time, using the same bounds as the parsers' boot-time-bounds
+// event validator.  This catches garbage values such as 0, negative, or far-future boot-times before any
+// relative validation is done.
+func BootTimeBoundsValidator(minYear int, maxAhead time.Duration, current func() time.Time) validation.ValidatorFunc {
+	bounds := parsers.BootTimeBoundsValidator(minYear, maxAhead, current)
+	return func(e interpreter.Event) (bool, error) {
+		if valid, err := bounds(e); !valid {
+			return false, InvalidEventErr{
+				Reason: implausibleBootTimeReason,
+				Err:    err,
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// DeviceIDValidator returns a validator that rejects events whose destination doesn't have a well-formed device
+// id, such as a mac address that isn't 12 hexadecimal digits or an unknown scheme.
+func DeviceIDValidator() validation.ValidatorFunc {
+	return func(e interpreter.Event) (bool, error) {
+		id, err := e.DeviceID()
+		if err == nil {
+			err = deviceid.Validate(id)
+		}
+
+		if err != nil {
+			return false, InvalidEventErr{
+				Reason: malformedDeviceIDReason,
+				Err:    err,
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// createIncomingEventValidator builds the validators that every incoming event must pass before it is queued,
+// validating payloads against their schemas last if schemas is non-nil.
+func createIncomingEventValidator(config Config, schemas *SchemaValidator) validation.Validator {
+	var validators validation.Validators
+	if config.RejectMalformedDeviceIDs {
+		validators = append(validators, DeviceIDValidator())
+	}
+
+	if config.BootTimeBounds.Enabled {
+		validators = append(validators, BootTimeBoundsValidator(config.BootTimeBounds.MinYear, config.BootTimeBounds.MaxAhead, time.Now))
+	}
+
+	if config.RejectBirthdateBeforeBootTime {
+		validators = append(validators, BirthdateBootTimeValidator(config.BirthdateBootTimeTolerance))
+	}
+
+	if schemas != nil {
+		validators = append(validators, schemas)
+	}
+
+	return validators
+}
@@ -0,0 +1,220 @@
+package eventmetrics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+func TestBirthdateBootTimeValidator(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+	tolerance := 5 * time.Second
+	tests := []struct {
+		description string
+		bootTime    string
+		birthdate   int64
+		expectedErr bool
+	}{
+		{
+			description: "Birthdate after boot-time",
+			bootTime:    fmt.Sprint(now.Unix()),
+			birthdate:   now.Add(time.Minute).UnixNano(),
+		},
+		{
+			description: "Birthdate equal to boot-time",
+			bootTime:    fmt.Sprint(now.Unix()),
+			birthdate:   now.UnixNano(),
+		},
+		{
+			description: "Birthdate before boot-time at tolerance",
+			bootTime:    fmt.Sprint(now.Unix()),
+			birthdate:   now.Add(-1 * tolerance).UnixNano(),
+		},
+		{
+			description: "Birthdate before boot-time beyond tolerance",
+			bootTime:    fmt.Sprint(now.Unix()),
+			birthdate:   now.Add(-1 * tolerance).Add(-1 * time.Millisecond).UnixNano(),
+			expectedErr: true,
+		},
+		{
+			description: "Birthdate well before boot-time",
+			bootTime:    fmt.Sprint(now.Unix()),
+			birthdate:   now.Add(-1 * time.Hour).UnixNano(),
+			expectedErr: true,
+		},
+		{
+			description: "No boot-time",
+			birthdate:   now.Add(-1 * time.Hour).UnixNano(),
+		},
+		{
+			description: "No birthdate",
+			bootTime:    fmt.Sprint(now.Unix()),
+		},
+	}
+
+	validator := BirthdateBootTimeValidator(tolerance)
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			event := interpreter.Event{Birthdate: tc.birthdate, Metadata: map[string]string{}}
+			if len(tc.bootTime) > 0 {
+				event.Metadata[interpreter.BootTimeKey] = tc.bootTime
+			}
+
+			valid, err := validator.Valid(event)
+			assert.Equal(!tc.expectedErr, valid)
+			if tc.expectedErr {
+				var invalidErr InvalidEventErr
+				assert.True(errors.As(err, &invalidErr))
+				assert.Equal(birthdateBeforeBootTimeReason, invalidErr.Reason)
+				assert.True(errors.Is(err, errBirthdateBeforeBootTime))
+			} else {
+				assert.Nil(err)
+			}
+		})
+	}
+}
+
+func TestBootTimeBoundsValidator(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:01Z")
+	assert.Nil(t, err)
+	current := func() time.Time { return now }
+	tests := []struct {
+		description string
+		bootTime    string
+		minYear     int
+		maxAhead    time.Duration
+		expectedErr bool
+	}{
+		{
+			description: "Valid",
+			bootTime:    fmt.Sprint(now.Add(-1 * time.Hour).Unix()),
+		},
+		{
+			description: "Zero",
+			bootTime:    "0",
+			expectedErr: true,
+		},
+		{
+			description: "Custom min year",
+			bootTime:    fmt.Sprint(time.Date(2018, time.June, 1, 0, 0, 0, 0, time.UTC).Unix()),
+			minYear:     2019,
+			expectedErr: true,
+		},
+		{
+			description: "Custom max ahead",
+			bootTime:    fmt.Sprint(now.Add(2 * time.Hour).Unix()),
+			maxAhead:    time.Hour,
+			expectedErr: true,
+		},
+		{
+			description: "No boot-time",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			event := interpreter.Event{Metadata: map[string]string{}}
+			if len(tc.bootTime) > 0 {
+				event.Metadata[interpreter.BootTimeKey] = tc.bootTime
+			}
+
+			valid, err := BootTimeBoundsValidator(tc.minYear, tc.maxAhead, current).Valid(event)
+			assert.Equal(!tc.expectedErr, valid)
+			if tc.expectedErr {
+				var invalidErr InvalidEventErr
+				assert.True(errors.As(err, &invalidErr))
+				assert.Equal(implausibleBootTimeReason, invalidErr.Reason)
+				assert.True(errors.Is(err, parsers.ErrImplausibleBootTime))
+			} else {
+				assert.Nil(err)
+			}
+		})
+	}
+}
+
+func TestDeviceIDValidator(t *testing.T) {
+	tests := []struct {
+		description string
+		destination string
+		expectedErr error
+	}{
+		{
+			description: "Valid",
+			destination: "event:device-status/mac:112233445566/online",
+		},
+		{
+			description: "Valid with separators",
+			destination: "event:device-status/MAC:11:22:33:44:55:66/online",
+		},
+		{
+			description: "Malformed mac",
+			destination: "event:device-status/mac:some_address/online",
+			expectedErr: deviceid.ErrMalformedID,
+		},
+		{
+			description: "Missing device id",
+			destination: "event:device-status",
+			expectedErr: interpreter.ErrParseDeviceID,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			valid, err := DeviceIDValidator()(interpreter.Event{Destination: tc.destination})
+			if tc.expectedErr == nil {
+				assert.True(valid)
+				assert.Nil(err)
+				return
+			}
+
+			assert.False(valid)
+			assert.True(errors.Is(err, tc.expectedErr))
+			assert.Equal(malformedDeviceIDReason, rejectedEventErr(err).Reason)
+		})
+	}
+}
+
+func TestCreateIncomingEventValidator(t *testing.T) {
+	now := time.Now()
+	event := interpreter.Event{
+		Birthdate: now.Add(-1 * time.Hour).UnixNano(),
+		Metadata:  map[string]string{interpreter.BootTimeKey: fmt.Sprint(now.Unix())},
+	}
+
+	valid, err := createIncomingEventValidator(Config{}, nil).Valid(event)
+	assert.True(t, valid)
+	assert.Nil(t, err)
+
+	valid, err = createIncomingEventValidator(Config{RejectBirthdateBeforeBootTime: true}, nil).Valid(event)
+	assert.False(t, valid)
+	assert.Equal(t, birthdateBeforeBootTimeReason, rejectedEventErr(err).Reason)
+
+	// absolute bounds are checked before relative validation
+	event.Metadata[interpreter.BootTimeKey] = "1"
+	config := Config{RejectBirthdateBeforeBootTime: true, BootTimeBounds: parsers.BootTimeBoundsConfig{Enabled: true}}
+	valid, err = createIncomingEventValidator(config, nil).Valid(event)
+	assert.False(t, valid)
+	assert.Equal(t, implausibleBootTimeReason, rejectedEventErr(err).Reason)
+
+	valid, err = createIncomingEventValidator(Config{RejectMalformedDeviceIDs: true}, nil).Valid(interpreter.Event{Destination: "event:device-status/mac:1234/online"})
+	assert.False(t, valid)
+	assert.Equal(t, malformedDeviceIDReason, rejectedEventErr(err).Reason)
+}
+
+func TestRejectedEventErr(t *testing.T) {
+	invalidErr := InvalidEventErr{Reason: "test", Err: errors.New("test error")}
+	assert.Equal(t, invalidErr, rejectedEventErr(invalidErr))
+	assert.Equal(t, invalidErr, rejectedEventErr(validation.Errors{errors.New("other"), invalidErr}))
+	assert.Equal(t, unknownReason, rejectedEventErr(errors.New("test error")).Reason)
+}
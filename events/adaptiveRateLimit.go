@@ -0,0 +1,217 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/httpaux"
+)
+
+const (
+	defaultAdaptiveTargetLatency    = time.Second
+	defaultAdaptiveDecreaseFactor   = 0.5
+	defaultAdaptiveRecoveryInterval = time.Second
+)
+
+var errInvalidAdaptiveRateLimit = errors.New("adaptive rate limit requires a maximum number of requests")
+
+// AdaptiveRateLimitConfig configures lowering the rate of codex requests when codex is slow or failing, and
+// raising it gradually back to the configured rate once codex recovers.
+type AdaptiveRateLimitConfig struct {
+	// Enabled turns on adapting the rate limit.  The configured requests per tick is the maximum rate.
+	Enabled bool
+
+	// MinRequests is the lowest number of requests per tick the rate is lowered to.  Defaults to 1.
+	MinRequests int
+
+	// TargetLatency is the codex response time above which the rate is lowered.  Defaults to 1s.
+	TargetLatency time.Duration
+
+	// DecreaseFactor is multiplied with the rate when codex is slow, responds with a 429 or 5xx, or can't be
+	// reached.  It must be between 0 and 1.  Defaults to 0.5.
+	DecreaseFactor float64
+
+	// IncreaseRequests is the number of requests per tick added to the rate every recovery interval while codex
+	// is healthy.  Defaults to a tenth of the maximum rate, and at least 1.
+	IncreaseRequests int
+
+	// RecoveryInterval is the minimum time between changes to the rate, so that a burst of slow responses only
+	// lowers the rate once.  Defaults to 1s.
+	RecoveryInterval time.Duration
+}
+
+// adaptiveLimiter is a ratelimit.Limiter whose rate is lowered when codex responses are slow or fail and raised
+// when they succeed.
+type adaptiveLimiter struct {
+	per              time.Duration
+	maxRate          float64
+	minRate          float64
+	increase         float64
+	decreaseFactor   float64
+	targetLatency    time.Duration
+	recoveryInterval time.Duration
+	effectiveRate    prometheus.Gauge
+
+	current func() time.Time
+	sleep   func(time.Duration)
+
+	lock       sync.Mutex
+	rate       float64
+	last       time.Time
+	lastChange time.Time
+}
+
+func newAdaptiveLimiter(config RateLimitConfig, effectiveRate prometheus.Gauge) (*adaptiveLimiter, error) {
+	if config.Requests <= 0 {
+		return nil, errInvalidAdaptiveRateLimit
+	}
+
+	if config.Tick <= 0 {
+		config.Tick = time.Second
+	}
+
+	adaptive := config.Adaptive
+	if adaptive.MinRequests <= 0 {
+		adaptive.MinRequests = 1
+	}
+
+	if adaptive.MinRequests > config.Requests {
+		adaptive.MinRequests = config.Requests
+	}
+
+	if adaptive.TargetLatency <= 0 {
+		adaptive.TargetLatency = defaultAdaptiveTargetLatency
+	}
+
+	if adaptive.DecreaseFactor <= 0 || adaptive.DecreaseFactor >= 1 {
+		adaptive.DecreaseFactor = defaultAdaptiveDecreaseFactor
+	}
+
+	if adaptive.IncreaseRequests <= 0 {
+		adaptive.IncreaseRequests = config.Requests / 10
+		if adaptive.IncreaseRequests < 1 {
+			adaptive.IncreaseRequests = 1
+		}
+	}
+
+	if adaptive.RecoveryInterval <= 0 {
+		adaptive.RecoveryInterval = defaultAdaptiveRecoveryInterval
+	}
+
+	l := &adaptiveLimiter{
+		per:              config.Tick,
+		maxRate:          float64(config.Requests),
+		minRate:          float64(adaptive.MinRequests),
+		increase:         float64(adaptive.IncreaseRequests),
+		decreaseFactor:   adaptive.DecreaseFactor,
+		targetLatency:    adaptive.TargetLatency,
+		recoveryInterval: adaptive.RecoveryInterval,
+		effectiveRate:    effectiveRate,
+		current:          time.Now,
+		sleep:            time.Sleep,
+		rate:             float64(config.Requests),
+	}
+
+	l.report()
+	return l, nil
+}
+
+// Take blocks until the next request is allowed at the current rate, without allowing any slack.
+func (l *adaptiveLimiter) Take() time.Time {
+	l.lock.Lock()
+	now := l.current()
+	next := l.last.Add(time.Duration(float64(l.per) / l.rate))
+	if next.Before(now) {
+		next = now
+	}
+	l.last = next
+	l.lock.Unlock()
+
+	if wait := next.Sub(now); wait > 0 {
+		l.sleep(wait)
+	}
+
+	return next
+}
+
+// Observe adjusts the rate from the outcome of a codex request.  A status code of -1 means codex couldn't be
+// reached.
+func (l *adaptiveLimiter) Observe(latency time.Duration, statusCode int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.current()
+	if now.Sub(l.lastChange) < l.recoveryInterval {
+		return
+	}
+
+	failed := statusCode < 0 || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+	rate := l.rate
+	if failed || latency > l.targetLatency {
+		rate *= l.decreaseFactor
+		if rate < l.minRate {
+			rate = l.minRate
+		}
+	} else {
+		rate += l.increase
+		if rate > l.maxRate {
+			rate = l.maxRate
+		}
+	}
+
+	if rate == l.rate {
+		return
+	}
+
+	l.rate = rate
+	l.lastChange = now
+	l.report()
+}
+
+// report sets the gauge to the current rate in requests per second.
+func (l *adaptiveLimiter) report() {
+	if l.effectiveRate != nil {
+		l.effectiveRate.Set(l.rate / l.per.Seconds())
+	}
+}
+
+// adaptiveClient reports the latency and status code of each codex request to the adaptive limiter.
+type adaptiveClient struct {
+	next    httpaux.Client
+	limiter *adaptiveLimiter
+}
+
+func (c adaptiveClient) Do(request *http.Request) (*http.Response, error) {
+	begin := c.limiter.current()
+	response, err := c.next.Do(request)
+	statusCode := -1
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+
+	// requests cancelled by glaukos say nothing about the health of codex.
+	if err == nil || request.Context().Err() == nil {
+		c.limiter.Observe(c.limiter.current().Sub(begin), statusCode)
+	}
+
+	return response, err
+}
@@ -0,0 +1,213 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewAdaptiveLimiter(t *testing.T) {
+	tests := []struct {
+		description      string
+		config           RateLimitConfig
+		expectedErr      error
+		expectedMinRate  float64
+		expectedIncrease float64
+		expectedFactor   float64
+		expectedRate     float64
+	}{
+		{
+			description: "No maximum",
+			config:      RateLimitConfig{Adaptive: AdaptiveRateLimitConfig{Enabled: true}},
+			expectedErr: errInvalidAdaptiveRateLimit,
+		},
+		{
+			description:      "Defaults",
+			config:           RateLimitConfig{Requests: 50, Adaptive: AdaptiveRateLimitConfig{Enabled: true}},
+			expectedMinRate:  1,
+			expectedIncrease: 5,
+			expectedFactor:   defaultAdaptiveDecreaseFactor,
+			expectedRate:     50,
+		},
+		{
+			description: "Configured",
+			config: RateLimitConfig{
+				Requests: 50,
+				Tick:     time.Minute,
+				Adaptive: AdaptiveRateLimitConfig{
+					Enabled:          true,
+					MinRequests:      5,
+					DecreaseFactor:   0.8,
+					IncreaseRequests: 2,
+				},
+			},
+			expectedMinRate:  5,
+			expectedIncrease: 2,
+			expectedFactor:   0.8,
+			expectedRate:     50.0 / 60,
+		},
+		{
+			description: "Invalid values",
+			config: RateLimitConfig{
+				Requests: 5,
+				Adaptive: AdaptiveRateLimitConfig{
+					Enabled:        true,
+					MinRequests:    10,
+					DecreaseFactor: 2,
+				},
+			},
+			expectedMinRate:  5,
+			expectedIncrease: 1,
+			expectedFactor:   defaultAdaptiveDecreaseFactor,
+			expectedRate:     5,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testEffectiveRate"})
+			limiter, err := newAdaptiveLimiter(tc.config, gauge)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(limiter)
+				return
+			}
+
+			if !assert.Nil(err) || !assert.NotNil(limiter) {
+				return
+			}
+
+			assert.Equal(tc.expectedMinRate, limiter.minRate)
+			assert.Equal(tc.expectedIncrease, limiter.increase)
+			assert.Equal(tc.expectedFactor, limiter.decreaseFactor)
+			assert.InDelta(tc.expectedRate, testutil.ToFloat64(gauge), 0.0001)
+		})
+	}
+}
+
+func TestAdaptiveLimiterObserve(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testEffectiveRate"})
+	limiter, err := newAdaptiveLimiter(RateLimitConfig{
+		Requests: 100,
+		Adaptive: AdaptiveRateLimitConfig{Enabled: true, MinRequests: 10, IncreaseRequests: 20},
+	}, gauge)
+	if !assert.Nil(err) {
+		return
+	}
+	limiter.current = func() time.Time { return now }
+
+	steps := []struct {
+		description  string
+		advance      time.Duration
+		latency      time.Duration
+		statusCode   int
+		expectedRate float64
+	}{
+		{description: "Healthy at maximum", latency: time.Millisecond, statusCode: http.StatusOK, expectedRate: 100},
+		{description: "Too many requests", latency: time.Millisecond, statusCode: http.StatusTooManyRequests, expectedRate: 50},
+		{description: "Failure within recovery interval", advance: time.Millisecond, latency: time.Millisecond, statusCode: http.StatusServiceUnavailable, expectedRate: 50},
+		{description: "Slow", advance: time.Second, latency: 2 * time.Second, statusCode: http.StatusOK, expectedRate: 25},
+		{description: "Unreachable", advance: time.Second, latency: time.Millisecond, statusCode: -1, expectedRate: 12.5},
+		{description: "Minimum", advance: time.Second, latency: time.Millisecond, statusCode: http.StatusInternalServerError, expectedRate: 10},
+		{description: "Recovering", advance: time.Second, latency: time.Millisecond, statusCode: http.StatusOK, expectedRate: 30},
+		{description: "Recovering within recovery interval", advance: time.Millisecond, latency: time.Millisecond, statusCode: http.StatusOK, expectedRate: 30},
+		{description: "Client error is healthy", advance: time.Second, latency: time.Millisecond, statusCode: http.StatusNotFound, expectedRate: 50},
+		{description: "Recovered", advance: 10 * time.Second, latency: time.Millisecond, statusCode: http.StatusOK, expectedRate: 70},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		limiter.Observe(step.latency, step.statusCode)
+		assert.Equal(step.expectedRate, limiter.rate, step.description)
+		assert.Equal(step.expectedRate, testutil.ToFloat64(gauge), step.description)
+	}
+}
+
+func TestAdaptiveLimiterTake(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	limiter, err := newAdaptiveLimiter(RateLimitConfig{Requests: 10, Adaptive: AdaptiveRateLimitConfig{Enabled: true}}, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	var waits []time.Duration
+	limiter.current = func() time.Time { return now }
+	limiter.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	assert.Equal(now, limiter.Take())
+	assert.Equal(now.Add(100*time.Millisecond), limiter.Take())
+	limiter.rate = 5
+	assert.Equal(now.Add(300*time.Millisecond), limiter.Take())
+	assert.Equal([]time.Duration{100 * time.Millisecond, 300 * time.Millisecond}, waits)
+
+	// time spent idle isn't saved up for later requests.
+	now = now.Add(time.Minute)
+	assert.Equal(now, limiter.Take())
+}
+
+func TestAdaptiveClient(t *testing.T) {
+	tests := []struct {
+		description  string
+		response     *http.Response
+		err          error
+		cancel       bool
+		expectedRate float64
+	}{
+		{
+			description:  "Success",
+			response:     &http.Response{StatusCode: http.StatusOK},
+			expectedRate: 10,
+		},
+		{
+			description:  "Failure",
+			response:     &http.Response{StatusCode: http.StatusServiceUnavailable},
+			expectedRate: 5,
+		},
+		{
+			description:  "Unreachable",
+			err:          errors.New("test error"),
+			expectedRate: 5,
+		},
+		{
+			description:  "Cancelled",
+			err:          context.Canceled,
+			cancel:       true,
+			expectedRate: 10,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			limiter, err := newAdaptiveLimiter(RateLimitConfig{Requests: 10, Adaptive: AdaptiveRateLimitConfig{Enabled: true}}, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			client := new(mockClient)
+			client.On("Do", mock.Anything).Return(tc.response, tc.err)
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			_, err = adaptiveClient{next: client, limiter: limiter}.Do(request) // nolint:bodyclose
+			assert.Equal(tc.err, err)
+			assert.Equal(tc.expectedRate, limiter.rate)
+		})
+	}
+}
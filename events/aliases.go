@@ -0,0 +1,347 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAliasLookupTimeout  = 5 * time.Second
+	defaultAliasLookupCacheTTL = time.Hour
+	defaultAliasCacheSize      = 10000
+)
+
+// AliasConfig configures merging the histories of the ids a device reports under, such as both a mac: and a
+// serial: id, so that parsers find the events sent under any of them.
+type AliasConfig struct {
+	// Enabled turns on merging the histories of aliases.
+	Enabled bool
+
+	// Static lists the aliases of devices, keyed by device id.  Aliases are symmetric, so listing serial:abc as an
+	// alias of mac:112233445566 also makes mac:112233445566 an alias of serial:abc.
+	Static map[string][]string
+
+	// Lookup configures getting the aliases of devices from a lookup service, in addition to the static aliases.
+	Lookup AliasLookupConfig
+}
+
+// AliasLookupConfig configures the service the aliases of devices are looked up from.
+type AliasLookupConfig struct {
+	// URL is the url of the service, with a %s that is replaced with the device id.  A GET must return a JSON list
+	// of the device's aliases.  If it is empty, only the static aliases are used.
+	URL string
+
+	// Timeout is the longest a lookup can take before only the static aliases are used.  Defaults to 5s.
+	Timeout time.Duration
+
+	// CacheTTL is how long the aliases looked up for a device are kept before being looked up again.
+	// Defaults to 1h.
+	CacheTTL time.Duration
+
+	// CacheSize is the maximum number of devices whose aliases are cached, after which the least recently used
+	// devices are evicted.  Defaults to 10000.
+	CacheSize int
+}
+
+// aliasResolver finds the ids a device reports under.
+type aliasResolver struct {
+	static map[string][]string
+	lookup AliasLookupConfig
+	client *http.Client
+	logger *zap.Logger
+
+	current func() time.Time
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	calls   map[string]*aliasCall
+}
+
+type cachedAliases struct {
+	device  string
+	aliases []string
+	expires time.Time
+}
+
+// aliasCall is a lookup in progress, which concurrent lookups of the same device wait for rather than calling
+// the lookup service again.
+type aliasCall struct {
+	done    chan struct{}
+	aliases []string
+}
+
+func newAliasResolver(config AliasConfig, logger *zap.Logger) *aliasResolver {
+	if config.Lookup.Timeout <= 0 {
+		config.Lookup.Timeout = defaultAliasLookupTimeout
+	}
+
+	if config.Lookup.CacheTTL <= 0 {
+		config.Lookup.CacheTTL = defaultAliasLookupCacheTTL
+	}
+
+	if config.Lookup.CacheSize <= 0 {
+		config.Lookup.CacheSize = defaultAliasCacheSize
+	}
+
+	static := make(map[string][]string)
+	for device, aliases := range config.Static {
+		device = deviceid.Normalize(device)
+		for _, alias := range aliases {
+			alias = deviceid.Normalize(alias)
+			static[device] = append(static[device], alias)
+			static[alias] = append(static[alias], device)
+		}
+	}
+
+	return &aliasResolver{
+		static:  static,
+		lookup:  config.Lookup,
+		client:  &http.Client{Timeout: config.Lookup.Timeout},
+		logger:  logger,
+		current: time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		calls:   make(map[string]*aliasCall),
+	}
+}
+
+// Aliases returns the device id followed by its aliases, normalized and without duplicates.  Aliases of aliases
+// are not followed.
+func (r *aliasResolver) Aliases(ctx context.Context, device string) []string {
+	device = deviceid.Normalize(device)
+	ids := []string{device}
+	seen := map[string]bool{device: true}
+	add := func(aliases []string) {
+		for _, alias := range aliases {
+			alias = deviceid.Normalize(alias)
+			if len(alias) > 0 && !seen[alias] {
+				seen[alias] = true
+				ids = append(ids, alias)
+			}
+		}
+	}
+
+	add(r.static[device])
+	add(r.lookedUp(ctx, device))
+	return ids
+}
+
+// lookedUp returns the aliases the lookup service returns for the device, caching them.  If the lookup fails, no
+// aliases are returned and the failure is cached like a successful lookup, so that a failing service isn't
+// called for every event.  Concurrent lookups of the same device share one call to the lookup service.
+func (r *aliasResolver) lookedUp(ctx context.Context, device string) []string {
+	if len(r.lookup.URL) == 0 {
+		return nil
+	}
+
+	now := r.current()
+	r.lock.Lock()
+	if aliases, found := r.cached(device, now); found {
+		r.lock.Unlock()
+		return aliases
+	}
+
+	if call, found := r.calls[device]; found {
+		r.lock.Unlock()
+		select {
+		case <-call.done:
+			return call.aliases
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	call := &aliasCall{done: make(chan struct{})}
+	r.calls[device] = call
+	r.lock.Unlock()
+
+	aliases, err := r.get(ctx, device)
+	if err != nil {
+		r.logger.Warn("failed to look up device aliases", zap.Error(err), zap.String("deviceID", device))
+	}
+
+	r.lock.Lock()
+	delete(r.calls, device)
+	r.add(device, aliases, now)
+	r.lock.Unlock()
+
+	call.aliases = aliases
+	close(call.done)
+	return aliases
+}
+
+// cached returns the device's cached aliases, if they haven't expired.  The lock must be held.
+func (r *aliasResolver) cached(device string, now time.Time) ([]string, bool) {
+	element, found := r.entries[device]
+	if !found {
+		return nil, false
+	}
+
+	if !now.Before(element.Value.(*cachedAliases).expires) {
+		r.remove(element)
+		return nil, false
+	}
+
+	r.order.MoveToFront(element)
+	return element.Value.(*cachedAliases).aliases, true
+}
+
+// add caches the device's aliases, evicting the least recently used device if the cache is full.  The lock must
+// be held.
+func (r *aliasResolver) add(device string, aliases []string, now time.Time) {
+	entry := &cachedAliases{device: device, aliases: aliases, expires: now.Add(r.lookup.CacheTTL)}
+	if element, found := r.entries[device]; found {
+		element.Value = entry
+		r.order.MoveToFront(element)
+		return
+	}
+
+	for r.order.Len() >= r.lookup.CacheSize {
+		r.remove(r.order.Back())
+	}
+
+	r.entries[device] = r.order.PushFront(entry)
+}
+
+func (r *aliasResolver) remove(element *list.Element) {
+	r.order.Remove(element)
+	delete(r.entries, element.Value.(*cachedAliases).device)
+}
+
+func (r *aliasResolver) get(ctx context.Context, device string) ([]string, error) {
+	address := r.lookup.URL
+	if strings.Contains(address, "%s") {
+		address = fmt.Sprintf(address, url.PathEscape(device))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var aliases []string
+	if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// aliasEventSource merges the histories of a device's aliases into the device's history.
+type aliasEventSource struct {
+	next     EventSource
+	resolver *aliasResolver
+}
+
+// GetEvents implements the EventSource interface, getting the histories of the device and its aliases in one
+// batch.
+func (a aliasEventSource) GetEvents(ctx context.Context, device string) []interpreter.Event {
+	ids := a.resolver.Aliases(ctx, device)
+	if len(ids) == 1 {
+		return a.next.GetEvents(ctx, device)
+	}
+
+	histories := a.next.GetEventsBatch(ctx, ids)
+	return mergeHistories(histories, ids)
+}
+
+// GetEventsBatch implements the EventSource interface, getting the histories of every device and alias in one
+// batch.
+func (a aliasEventSource) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	aliases := make(map[string][]string, len(deviceIDs))
+	var ids []string
+	seen := make(map[string]bool)
+	for _, deviceID := range deviceIDs {
+		aliases[deviceID] = a.resolver.Aliases(ctx, deviceID)
+		for _, id := range aliases[deviceID] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	histories := a.next.GetEventsBatch(ctx, ids)
+	results := make(map[string][]interpreter.Event, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		results[deviceID] = mergeHistories(histories, aliases[deviceID])
+	}
+
+	return results
+}
+
+// ForParser implements the ParserEventSource interface, attributing the lookups to the parser if the wrapped
+// event source can.
+func (a aliasEventSource) ForParser(parser string) EventSource {
+	if p, ok := a.next.(ParserEventSource); ok {
+		return aliasEventSource{next: p.ForParser(parser), resolver: a.resolver}
+	}
+
+	return a
+}
+
+// mergeHistories merges the histories of the ids, dropping events found under more than one id, newest first
+// like codex returns them.
+func mergeHistories(histories map[string][]interpreter.Event, ids []string) []interpreter.Event {
+	var merged []interpreter.Event
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		for _, event := range histories[id] {
+			if len(event.TransactionUUID) > 0 {
+				if seen[event.TransactionUUID] {
+					continue
+				}
+				seen[event.TransactionUUID] = true
+			}
+			merged = append(merged, event)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Birthdate > merged[j].Birthdate
+	})
+	return merged
+}
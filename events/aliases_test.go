@@ -0,0 +1,198 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+func TestAliasResolverStatic(t *testing.T) {
+	tests := []struct {
+		description string
+		device      string
+		expected    []string
+	}{
+		{
+			description: "Device",
+			device:      "mac:112233445566",
+			expected:    []string{"mac:112233445566", "serial:abc", "serial:def"},
+		},
+		{
+			description: "Alias",
+			device:      "SERIAL:ABC",
+			expected:    []string{"serial:abc", "mac:112233445566"},
+		},
+		{
+			description: "No aliases",
+			device:      "mac:aabbccddeeff",
+			expected:    []string{"mac:aabbccddeeff"},
+		},
+	}
+
+	resolver := newAliasResolver(AliasConfig{Static: map[string][]string{
+		"mac:11:22:33:44:55:66": {"serial:abc", "serial:def", "serial:abc"},
+	}}, zap.NewNop())
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, resolver.Aliases(context.Background(), tc.device))
+		})
+	}
+}
+
+func TestAliasResolverLookup(t *testing.T) {
+	assert := assert.New(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/aliases/mac:112233445566":
+			fmt.Fprint(w, `["serial:abc", "mac:112233445566"]`)
+		case "/aliases/mac:aabbccddeeff":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	resolver := newAliasResolver(AliasConfig{
+		Static: map[string][]string{"mac:112233445566": {"serial:xyz"}},
+		Lookup: AliasLookupConfig{URL: server.URL + "/aliases/%s", CacheTTL: time.Minute},
+	}, zap.NewNop())
+	resolver.current = func() time.Time { return now }
+
+	ctx := context.Background()
+	assert.Equal([]string{"mac:112233445566", "serial:xyz", "serial:abc"}, resolver.Aliases(ctx, "mac:112233445566"))
+	assert.Equal([]string{"mac:aabbccddeeff"}, resolver.Aliases(ctx, "mac:aabbccddeeff"))
+	assert.Equal([]string{"mac:665544332211"}, resolver.Aliases(ctx, "mac:665544332211"))
+	assert.Equal(int32(3), atomic.LoadInt32(&requests))
+
+	// lookups, including failed ones, are cached until they expire.
+	resolver.Aliases(ctx, "mac:112233445566")
+	resolver.Aliases(ctx, "mac:665544332211")
+	assert.Equal(int32(3), atomic.LoadInt32(&requests))
+
+	now = now.Add(time.Minute)
+	resolver.Aliases(ctx, "mac:112233445566")
+	assert.Equal(int32(4), atomic.LoadInt32(&requests))
+	assert.Len(resolver.entries, 3)
+}
+
+func TestAliasResolverCacheSize(t *testing.T) {
+	assert := assert.New(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `["serial:abc"]`)
+	}))
+	defer server.Close()
+
+	resolver := newAliasResolver(AliasConfig{
+		Lookup: AliasLookupConfig{URL: server.URL + "/aliases/%s", CacheSize: 2},
+	}, zap.NewNop())
+
+	ctx := context.Background()
+	resolver.Aliases(ctx, "mac:000000000001")
+	resolver.Aliases(ctx, "mac:000000000002")
+	resolver.Aliases(ctx, "mac:000000000001")
+	resolver.Aliases(ctx, "mac:000000000003")
+	assert.Equal(int32(3), atomic.LoadInt32(&requests))
+	assert.Len(resolver.entries, 2)
+
+	// the least recently used device was evicted.
+	resolver.Aliases(ctx, "mac:000000000001")
+	assert.Equal(int32(3), atomic.LoadInt32(&requests))
+	resolver.Aliases(ctx, "mac:000000000002")
+	assert.Equal(int32(4), atomic.LoadInt32(&requests))
+}
+
+func TestAliasResolverConcurrentLookups(t *testing.T) {
+	assert := assert.New(t)
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		fmt.Fprint(w, `["serial:abc"]`)
+	}))
+	defer server.Close()
+
+	resolver := newAliasResolver(AliasConfig{
+		Lookup: AliasLookupConfig{URL: server.URL + "/aliases/%s"},
+	}, zap.NewNop())
+
+	const lookups = 10
+	results := make(chan []string, lookups)
+	for i := 0; i < lookups; i++ {
+		go func() {
+			results <- resolver.Aliases(context.Background(), "mac:112233445566")
+		}()
+	}
+
+	// wait for the first lookup to reach the service before releasing it.
+	assert.Eventually(func() bool { return atomic.LoadInt32(&requests) == 1 }, time.Second, time.Millisecond)
+	close(release)
+	for i := 0; i < lookups; i++ {
+		assert.Equal([]string{"mac:112233445566", "serial:abc"}, <-results)
+	}
+
+	assert.Equal(int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestAliasEventSource(t *testing.T) {
+	assert := assert.New(t)
+	file := &fileEventSource{events: map[string][]interpreter.Event{
+		"mac:112233445566": {
+			{TransactionUUID: "1", Birthdate: 100},
+			{TransactionUUID: "3", Birthdate: 300},
+		},
+		"serial:abc": {
+			{TransactionUUID: "2", Birthdate: 200},
+			{TransactionUUID: "3", Birthdate: 300},
+		},
+		"mac:aabbccddeeff": {
+			{TransactionUUID: "4", Birthdate: 400},
+		},
+	}}
+
+	source := aliasEventSource{
+		next:     file,
+		resolver: newAliasResolver(AliasConfig{Static: map[string][]string{"mac:112233445566": {"serial:abc"}}}, zap.NewNop()),
+	}
+
+	ids := func(events []interpreter.Event) []string {
+		var result []string
+		for _, event := range events {
+			result = append(result, event.TransactionUUID)
+		}
+		return result
+	}
+
+	ctx := context.Background()
+	assert.Equal([]string{"3", "2", "1"}, ids(source.GetEvents(ctx, "mac:112233445566")))
+	assert.Equal([]string{"3", "2", "1"}, ids(source.GetEvents(ctx, "serial:abc")))
+	assert.Equal([]string{"4"}, ids(source.GetEvents(ctx, "mac:aabbccddeeff")))
+
+	batch := source.GetEventsBatch(ctx, []string{"serial:abc", "mac:aabbccddeeff"})
+	assert.Len(batch, 2)
+	assert.Equal([]string{"3", "2", "1"}, ids(batch["serial:abc"]))
+	assert.Equal([]string{"4"}, ids(batch["mac:aabbccddeeff"]))
+
+	// the file event source can't attribute lookups to parsers.
+	assert.Equal(source, source.ForParser("reboot"))
+
+	codexSource := aliasEventSource{next: &CodexClient{}, resolver: source.resolver}
+	parserSource, ok := codexSource.ForParser("reboot").(aliasEventSource)
+	if assert.True(ok) {
+		assert.Equal(&ParserClient{client: &CodexClient{}, parser: "reboot"}, parserSource.next)
+	}
+}
@@ -0,0 +1,79 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	defaultBatchConcurrency = 10
+)
+
+// GetEventsBatch queries codex for the events of each device, with up to the configured number of requests in
+// flight at once.  The requests share the client's rate limiter, circuit breaker, and cache.
+func (c *CodexClient) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	return c.getEventsBatch(ctx, deviceIDs, "")
+}
+
+// GetEventsBatch queries codex for the events of each device, attributing failed lookups to the parser.
+func (p *ParserClient) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	return p.client.getEventsBatch(ctx, deviceIDs, p.parser)
+}
+
+func (c *CodexClient) getEventsBatch(ctx context.Context, deviceIDs []string, parser string) map[string][]interpreter.Event {
+	concurrency := c.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	var (
+		lock    sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string][]interpreter.Event, len(deviceIDs))
+		started = make(map[string]bool, len(deviceIDs))
+		limit   = make(chan struct{}, concurrency)
+	)
+
+	for _, deviceID := range deviceIDs {
+		if started[deviceID] {
+			continue
+		}
+
+		started[deviceID] = true
+		limit <- struct{}{}
+		wg.Add(1)
+		go func(deviceID string) {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+
+			events := c.getEvents(ctx, deviceID, parser)
+			lock.Lock()
+			results[deviceID] = events
+			lock.Unlock()
+		}(deviceID)
+	}
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,135 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+)
+
+// concurrencyClient responds with an event whose transaction uuid is the request path, tracking the most
+// requests in flight at once.
+type concurrencyClient struct {
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+	requests    int
+}
+
+func (c *concurrencyClient) Do(req *http.Request) (*http.Response, error) {
+	c.lock.Lock()
+	c.inFlight++
+	c.requests++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.lock.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	c.lock.Lock()
+	c.inFlight--
+	c.lock.Unlock()
+
+	body, err := json.Marshal([]interpreter.Event{{TransactionUUID: req.URL.Path}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+func TestGetEventsBatch(t *testing.T) {
+	tests := []struct {
+		description         string
+		concurrency         int
+		deviceIDs           []string
+		expectedRequests    int
+		expectedMaxInFlight int
+	}{
+		{
+			description:         "Bounded concurrency",
+			concurrency:         2,
+			deviceIDs:           []string{"1", "2", "3", "4", "5"},
+			expectedRequests:    5,
+			expectedMaxInFlight: 2,
+		},
+		{
+			description:         "Default concurrency",
+			deviceIDs:           []string{"1", "2", "3"},
+			expectedRequests:    3,
+			expectedMaxInFlight: 3,
+		},
+		{
+			description:         "Duplicate devices",
+			concurrency:         5,
+			deviceIDs:           []string{"1", "2", "1", "2"},
+			expectedRequests:    2,
+			expectedMaxInFlight: 2,
+		},
+		{
+			description: "No devices",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(concurrencyClient)
+			auth := new(mockAcquirer)
+			auth.On("Acquire").Return("test", nil)
+			c := CodexClient{
+				Address:          "codex",
+				Logger:           zap.NewNop(),
+				Client:           client,
+				CircuitBreaker:   gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+				Auth:             auth,
+				RateLimiter:      ratelimit.NewUnlimited(),
+				BatchConcurrency: tc.concurrency,
+			}
+
+			results := c.ForParser("parser").GetEventsBatch(context.Background(), tc.deviceIDs)
+			assert.Len(results, tc.expectedRequests)
+			for _, deviceID := range tc.deviceIDs {
+				assert.Equal([]interpreter.Event{{TransactionUUID: fmt.Sprintf("codex/api/v1/device/%s/events", deviceID)}}, results[deviceID])
+			}
+
+			assert.Equal(tc.expectedRequests, client.requests)
+			assert.LessOrEqual(client.maxInFlight, tc.expectedMaxInFlight)
+			if tc.expectedRequests > 0 {
+				assert.Greater(client.maxInFlight, 0)
+			}
+		})
+	}
+}
+
+func TestGetEventsBatchFailure(t *testing.T) {
+	assert := assert.New(t)
+	client := new(mockClient)
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	client.On("Do", mock.Anything).Return(nil, fmt.Errorf("test error"))
+	c := CodexClient{
+		Logger:         zap.NewNop(),
+		Client:         client,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+	}
+
+	// failed lookups return an empty list, the same as GetEvents.
+	results := c.GetEventsBatch(context.Background(), []string{"1", "2"})
+	assert.Equal(map[string][]interpreter.Event{"1": {}, "2": {}}, results)
+}
@@ -0,0 +1,148 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	cacheResultLabel = "result"
+	cacheHit         = "hit"
+	cacheMiss        = "miss"
+
+	defaultCacheSize = 10000
+)
+
+// CacheConfig configures caching the events codex returns for a device, so that repeated lookups for the
+// same device don't all reach codex.
+type CacheConfig struct {
+	// TTL is how long a device's events are cached.  If 0, events are not cached.
+	TTL time.Duration
+
+	// Size is the maximum number of devices whose events are cached, after which the least recently used
+	// devices are evicted.  Defaults to 10000.
+	Size int
+}
+
+type cacheEntry struct {
+	device  string
+	events  []interpreter.Event
+	expires time.Time
+}
+
+// eventCache is an LRU cache of the events of devices, whose entries expire after the ttl.
+type eventCache struct {
+	ttl     time.Duration
+	size    int
+	lookups *prometheus.CounterVec
+	current func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// newEventCache creates an eventCache, returning nil if caching is disabled.
+func newEventCache(config CacheConfig, lookups *prometheus.CounterVec) *eventCache {
+	if config.TTL <= 0 {
+		return nil
+	}
+
+	if config.Size <= 0 {
+		config.Size = defaultCacheSize
+	}
+
+	return &eventCache{
+		ttl:     config.TTL,
+		size:    config.Size,
+		lookups: lookups,
+		current: time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns a copy of the device's cached events, if they haven't expired.
+func (c *eventCache) get(device string) ([]interpreter.Event, bool) {
+	now := c.current()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, found := c.entries[device]
+	if found && now.After(element.Value.(*cacheEntry).expires) {
+		c.remove(element)
+		found = false
+	}
+
+	if !found {
+		c.addLookup(cacheMiss)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.addLookup(cacheHit)
+	return copyEvents(element.Value.(*cacheEntry).events), true
+}
+
+// add caches a copy of the device's events, evicting the least recently used device if the cache is full.
+func (c *eventCache) add(device string, events []interpreter.Event) {
+	entry := &cacheEntry{
+		device:  device,
+		events:  copyEvents(events),
+		expires: c.current().Add(c.ttl),
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, found := c.entries[device]; found {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[device] = c.order.PushFront(entry)
+}
+
+func (c *eventCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).device)
+}
+
+func (c *eventCache) addLookup(result string) {
+	if c.lookups != nil {
+		c.lookups.With(prometheus.Labels{cacheResultLabel: result}).Add(1.0)
+	}
+}
+
+// copyEvents copies the list so that callers can't modify the cached list.
+func copyEvents(events []interpreter.Event) []interpreter.Event {
+	eventsCopy := make([]interpreter.Event, len(events))
+	copy(eventsCopy, events)
+	return eventsCopy
+}
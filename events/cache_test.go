@@ -0,0 +1,197 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+)
+
+func TestNewEventCache(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(newEventCache(CacheConfig{}, nil))
+
+	cache := newEventCache(CacheConfig{TTL: time.Minute}, nil)
+	assert.NotNil(cache)
+	assert.Equal(defaultCacheSize, cache.size)
+}
+
+func TestEventCache(t *testing.T) {
+	now := time.Now()
+	events := []interpreter.Event{{TransactionUUID: "a"}}
+	otherEvents := []interpreter.Event{{TransactionUUID: "b"}}
+
+	tests := []struct {
+		description    string
+		size           int
+		added          map[string][]interpreter.Event
+		addOrder       []string
+		lookupDevice   string
+		lookupAfter    time.Duration
+		expectedEvents []interpreter.Event
+		expectedFound  bool
+	}{
+		{
+			description:    "Hit",
+			size:           2,
+			added:          map[string][]interpreter.Event{"device": events},
+			addOrder:       []string{"device"},
+			lookupDevice:   "device",
+			lookupAfter:    time.Second,
+			expectedEvents: events,
+			expectedFound:  true,
+		},
+		{
+			description:  "Miss",
+			size:         2,
+			added:        map[string][]interpreter.Event{"device": events},
+			addOrder:     []string{"device"},
+			lookupDevice: "other",
+		},
+		{
+			description:  "Expired",
+			size:         2,
+			added:        map[string][]interpreter.Event{"device": events},
+			addOrder:     []string{"device"},
+			lookupDevice: "device",
+			lookupAfter:  2 * time.Minute,
+		},
+		{
+			description:  "Least recently used evicted",
+			size:         2,
+			added:        map[string][]interpreter.Event{"device": events, "other": otherEvents, "third": events},
+			addOrder:     []string{"device", "other", "third"},
+			lookupDevice: "device",
+		},
+		{
+			description:    "Most recently used kept",
+			size:           2,
+			added:          map[string][]interpreter.Event{"device": events, "other": otherEvents, "third": events},
+			addOrder:       []string{"device", "other", "third"},
+			lookupDevice:   "third",
+			expectedEvents: events,
+			expectedFound:  true,
+		},
+		{
+			description:    "Updated",
+			size:           2,
+			added:          map[string][]interpreter.Event{"device": otherEvents},
+			addOrder:       []string{"device", "device"},
+			lookupDevice:   "device",
+			expectedEvents: otherEvents,
+			expectedFound:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			lookups := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lookups"}, []string{cacheResultLabel})
+			cache := newEventCache(CacheConfig{TTL: time.Minute, Size: tc.size}, lookups)
+			cache.current = func() time.Time { return now }
+			for _, device := range tc.addOrder {
+				cache.add(device, tc.added[device])
+			}
+
+			cache.current = func() time.Time { return now.Add(tc.lookupAfter) }
+			eventsList, found := cache.get(tc.lookupDevice)
+			assert.Equal(tc.expectedFound, found)
+			assert.Equal(tc.expectedEvents, eventsList)
+			assert.LessOrEqual(cache.order.Len(), tc.size)
+			assert.Equal(cache.order.Len(), len(cache.entries))
+			if tc.expectedFound {
+				assert.Equal(1.0, testutil.ToFloat64(lookups.WithLabelValues(cacheHit)))
+			} else {
+				assert.Equal(1.0, testutil.ToFloat64(lookups.WithLabelValues(cacheMiss)))
+			}
+		})
+	}
+}
+
+func TestEventCacheCopies(t *testing.T) {
+	assert := assert.New(t)
+	cache := newEventCache(CacheConfig{TTL: time.Minute}, nil)
+	events := []interpreter.Event{{TransactionUUID: "a"}}
+	cache.add("device", events)
+	events[0].TransactionUUID = "changed"
+
+	eventsList, found := cache.get("device")
+	assert.True(found)
+	eventsList[0].TransactionUUID = "changed again"
+
+	eventsList, found = cache.get("device")
+	assert.True(found)
+	assert.Equal([]interpreter.Event{{TransactionUUID: "a"}}, eventsList)
+}
+
+func TestGetEventsCached(t *testing.T) {
+	events := []interpreter.Event{
+		{
+			MsgType:         4,
+			Source:          "source",
+			Destination:     "destination",
+			TransactionUUID: "112233445566",
+			Birthdate:       1617152053278595600,
+		},
+	}
+
+	jsonEvents, err := json.Marshal(events)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		description    string
+		clientErr      error
+		expectedEvents []interpreter.Event
+		expectedCalls  int
+	}{
+		{
+			description:    "Success cached",
+			expectedEvents: events,
+			expectedCalls:  1,
+		},
+		{
+			description:    "Failure not cached",
+			clientErr:      errors.New("test error"),
+			expectedEvents: []interpreter.Event{},
+			expectedCalls:  2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockClient)
+			auth := new(mockAcquirer)
+			auth.On("Acquire").Return("test", nil)
+			for i := 0; i < tc.expectedCalls; i++ {
+				resp := httptest.NewRecorder()
+				resp.Write(jsonEvents)                                                    // nolint:errcheck
+				client.On("Do", mock.Anything).Return(resp.Result(), tc.clientErr).Once() // nolint:bodyclose
+			}
+
+			c := CodexClient{
+				Logger:         zap.NewNop(),
+				Client:         client,
+				CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+				Auth:           auth,
+				RateLimiter:    ratelimit.NewUnlimited(),
+				Cache:          newEventCache(CacheConfig{TTL: time.Minute}, nil),
+			}
+
+			assert.Equal(tc.expectedEvents, c.GetEvents(context.Background(), "some-deviceID"))
+			assert.Equal(tc.expectedEvents, c.ForParser("parser").GetEvents(context.Background(), "some-deviceID"))
+			client.AssertNumberOfCalls(t, "Do", tc.expectedCalls)
+		})
+	}
+}
@@ -18,23 +18,43 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sony/gobreaker"
 	"github.com/xmidt-org/bascule/acquire"
+	"github.com/xmidt-org/candlelight"
+	"github.com/xmidt-org/glaukos/deviceid"
 	"github.com/xmidt-org/httpaux"
 	"github.com/xmidt-org/interpreter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/ratelimit"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
+const (
+	tracerName        = "github.com/xmidt-org/glaukos/events"
+	deviceIDAttribute = "glaukos.device_id"
+
+	// maxDiscardedBody is the most of an unsuccessful response's body read so that the connection can be reused.
+	maxDiscardedBody = 4096
+)
+
+var errUnsuccessfulStatus = errors.New("unsuccessful response status code")
+
 // CodexClient is the client used to get events from codex.
 type CodexClient struct {
 	Address        string
@@ -44,43 +64,217 @@ type CodexClient struct {
 	RateLimiter    ratelimit.Limiter
 	Logger         *zap.Logger
 	Metrics        Measures
+
+	// signer determines whether requests are sent with an Authorization header, as its signature can replace it.
+	// Requests are signed by the client as they are sent.  If this is nil, requests are not signed.
+	signer *requestSigner
+
+	// LogSampleRate is the fraction of requests, between 0 and 1, whose outcome is logged.
+	LogSampleRate float64
+	random        func() float64
+
+	// Cache caches the events of devices.  If this is nil, every lookup is sent to codex.
+	Cache *eventCache
+
+	// Recent is the recent history of devices recorded from incoming events, which is used instead of the cache
+	// and codex when it is complete.  If this is nil, the history is never consulted.
+	Recent *RecentEventStore
+
+	// BatchConcurrency is the maximum number of requests in flight when getting the events of a batch of
+	// devices.  Defaults to 10.
+	BatchConcurrency int
+
+	// Tracing traces requests to codex within the trace of the context given, propagating the trace to codex.
+	// The zero value doesn't record any spans.
+	Tracing candlelight.Tracing
+
+	// GraphQL queries codex's GraphQL endpoint for only the fields of events that parsers use.  If this is nil,
+	// the full events are gotten from codex's REST endpoint.
+	GraphQL *graphQLQuery
+
+	// TimeRange narrows lookups to the window configured for the parser making them.  If this is nil, a device's
+	// entire history is gotten.
+	TimeRange *timeRange
+
+	// Stream decodes the events in codex's responses as they are read, discarding the events that aren't needed
+	// and truncating the responses that exceed the limits.  If this is nil, the entire response is read before it
+	// is decoded.
+	Stream *eventStream
+
+	// truncatedLogs limits how often truncated responses are logged.
+	truncatedLogs rate.Sometimes
+
+	// Backends fails over between the primary codex, at Address, and the secondary codex instances.  If this is
+	// nil, every request is sent to Address.
+	Backends *codexBackends
+
+	parserLabels parserLabels
+}
+
+// GetEvents queries codex for events related to a device.  The request and any of its retries are cancelled
+// when the context is done.
+func (c *CodexClient) GetEvents(ctx context.Context, device string) []interpreter.Event {
+	return c.getEvents(ctx, device, "")
 }
 
-// GetEvents queries codex for events related to a device.
-func (c *CodexClient) GetEvents(device string) []interpreter.Event {
+// getEvents gets the events related to a device, attributing failed lookups to the parser given.  If the parser's
+// lookups are narrowed to a time range, only the events within it are returned.
+func (c *CodexClient) getEvents(ctx context.Context, device string, parser string) []interpreter.Event {
+	device = deviceid.Normalize(device)
+	window := c.TimeRange.windowFor(parser)
+	if window <= 0 {
+		return c.lookupEvents(ctx, device, parser, 0, time.Time{})
+	}
+
+	start := c.TimeRange.start(window)
+	return filterEvents(c.lookupEvents(ctx, device, parser, window, start), start)
+}
+
+// lookupEvents gets the events related to a device from the recent history, the cache, or codex.  Only successful
+// lookups are cached, separately for each window.
+func (c *CodexClient) lookupEvents(ctx context.Context, device string, parser string, window time.Duration, start time.Time) []interpreter.Event {
+	if c.Recent != nil {
+		if eventList, found := c.Recent.get(ctx, device); found {
+			return eventList
+		}
+	}
+
+	if c.Cache == nil {
+		eventList, _ := c.requestEvents(ctx, device, parser, start)
+		return eventList
+	}
+
+	key := device
+	if window > 0 {
+		key = fmt.Sprintf("%s/%s", device, window)
+	}
+
+	if eventList, found := c.Cache.get(key); found {
+		return eventList
+	}
+
+	eventList, ok := c.requestEvents(ctx, device, parser, start)
+	if ok {
+		c.Cache.add(key, eventList)
+	}
+
+	return eventList
+}
+
+// requestEvents queries codex for events related to a device, returning false if the lookup failed.  If start
+// isn't zero, only the events received since then are requested.
+func (c *CodexClient) requestEvents(ctx context.Context, device string, parser string, start time.Time) ([]interpreter.Event, bool) {
 	eventList := make([]interpreter.Event, 0)
 
-	request, err := buildGETRequest(fmt.Sprintf("%s/api/v1/device/%s/events", c.Address, device), c.Auth)
+	ctx, span := c.Tracing.TracerProvider().Tracer(tracerName).Start(ctx, "codex get events",
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(attribute.String(deviceIDAttribute, device)),
+	)
+	defer span.End()
+
+	i, backend := c.backend()
+	var request *http.Request
+	var err error
+	if c.GraphQL != nil {
+		request, err = c.GraphQL.buildRequest(backend.address, device, c.Auth, c.signer)
+	} else {
+		address := fmt.Sprintf("%s/api/v1/device/%s/events", backend.address, device)
+		if !start.IsZero() {
+			address = c.TimeRange.addParameter(address, start)
+		}
+		request, err = buildGETRequest(address, c.Auth, c.signer)
+	}
 	if err != nil {
 		c.Logger.Error("failed to build request", zap.Error(err))
-		return eventList
+		return eventList, false
+	}
+
+	request = request.WithContext(ctx)
+	c.Tracing.Propagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+	var trace *requestTrace
+	if c.shouldSample() {
+		trace = new(requestTrace)
+		request = request.WithContext(withRequestTrace(request.Context(), trace))
+	}
+
+	begin := time.Now()
+	var data []byte
+	var streamed streamedEvents
+	streaming := c.Stream != nil
+	if streaming {
+		streamed, err = c.streamEvents(request, backend.circuitBreaker, start)
+	} else {
+		data, err = c.executeRequest(request, backend.circuitBreaker)
+	}
+
+	if c.Backends != nil {
+		c.Backends.report(i, err)
+	}
+
+	if trace != nil {
+		logSampledRequest(c.Logger, device, request, trace, time.Since(begin), err)
 	}
 
-	data, err := c.executeRequest(request)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.addParserLookupFailure(parser, err)
 		c.Logger.Error("failed to complete request", zap.Error(err))
-		return eventList
+		return eventList, false
+	}
+
+	if streaming {
+		if len(streamed.truncated) > 0 {
+			c.truncatedLogs.Do(func() {
+				c.Logger.Warn("truncated codex response exceeding limit", zap.String("device id", device), zap.String("limit", streamed.truncated), zap.Int("events", len(streamed.events)))
+			})
+		}
+		return streamed.events, true
+	}
+
+	if c.GraphQL != nil {
+		graphQLEvents, err := c.GraphQL.decode(data)
+		if err != nil {
+			c.Logger.Error("failed to read body", zap.Error(err))
+			return eventList, false
+		}
+		return graphQLEvents, true
 	}
 
 	if err = json.Unmarshal(data, &eventList); err != nil {
 		c.Logger.Error("failed to read body", zap.Error(err))
-		return eventList
+		return eventList, false
 	}
 
-	return eventList
+	return eventList, true
 }
 
-func (c *CodexClient) executeRequest(request *http.Request) ([]byte, error) {
-	c.RateLimiter.Take()
-	response, err := c.CircuitBreaker.Execute(func() (interface{}, error) {
-		return c.doRequest(request, time.Now)
-	})
+// backend returns the codex the next request is sent to, along with its index in the backends.
+func (c *CodexClient) backend() (int, codexBackend) {
+	if c.Backends == nil {
+		return 0, codexBackend{address: c.Address, circuitBreaker: c.CircuitBreaker}
+	}
+
+	return c.Backends.next()
+}
+
+func (c *CodexClient) shouldSample() bool {
+	if c.LogSampleRate <= 0 {
+		return false
+	}
+
+	random := c.random
+	if random == nil {
+		random = rand.Float64 // nolint:gosec
+	}
 
+	return random() < c.LogSampleRate
+}
+
+func (c *CodexClient) executeRequest(request *http.Request, cb *gobreaker.CircuitBreaker) ([]byte, error) {
+	response, err := c.execute(request, cb, readBody)
 	if err != nil {
-		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
-			c.Metrics.CircuitBreakerRejectedCount.With(prometheus.Labels{circuitBreakerLabel: c.CircuitBreaker.Name()}).Add(1.0)
-		}
-		c.Logger.Error("failed to make request", zap.Error(err))
 		return nil, err
 	}
 
@@ -92,7 +286,46 @@ func (c *CodexClient) executeRequest(request *http.Request) ([]byte, error) {
 	return r, nil
 }
 
-func (c *CodexClient) doRequest(req *http.Request, currentTime func() time.Time) (interface{}, error) {
+// streamEvents sends the request, decoding the events in the response as they are read.  If start isn't zero,
+// the events received before it are discarded.
+func (c *CodexClient) streamEvents(request *http.Request, cb *gobreaker.CircuitBreaker, start time.Time) (streamedEvents, error) {
+	response, err := c.execute(request, cb, func(body io.Reader) (interface{}, error) {
+		if c.GraphQL != nil {
+			return c.GraphQL.decodeStream(body, c.Stream, start)
+		}
+		return c.Stream.decode(body, start)
+	})
+	if err != nil {
+		return streamedEvents{}, err
+	}
+
+	streamed, ok := response.(streamedEvents)
+	if !ok {
+		return streamedEvents{}, errors.New("failed to convert body to events")
+	}
+
+	return streamed, nil
+}
+
+// execute sends the request through the rate limiter and circuit breaker, reading its response body with read.
+func (c *CodexClient) execute(request *http.Request, cb *gobreaker.CircuitBreaker, read func(io.Reader) (interface{}, error)) (interface{}, error) {
+	c.RateLimiter.Take()
+	response, err := cb.Execute(func() (interface{}, error) {
+		return c.doRequest(request, time.Now, read)
+	})
+
+	if err != nil {
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			c.Metrics.CircuitBreakerRejectedCount.With(prometheus.Labels{circuitBreakerLabel: cb.Name()}).Add(1.0)
+		}
+		c.Logger.Error("failed to make request", zap.Error(err))
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (c *CodexClient) doRequest(req *http.Request, currentTime func() time.Time, read func(io.Reader) (interface{}, error)) (interface{}, error) {
 	requestBegin := currentTime()
 	resp, err := c.Client.Do(req)
 	timeElapsed := currentTime().Sub(requestBegin).Seconds()
@@ -108,29 +341,50 @@ func (c *CodexClient) doRequest(req *http.Request, currentTime func() time.Time)
 	}
 
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	// unsuccessful responses are failures, so that they trip the circuit breaker and fail over the backend.
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxDiscardedBody)) // nolint:errcheck
+		return nil, fmt.Errorf("%w: %d", errUnsuccessfulStatus, resp.StatusCode)
+	}
+
+	body, err := read(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error reading body: %w", err)
 	}
 	return body, nil
 }
 
-func buildGETRequest(address string, auth acquire.Acquirer) (*http.Request, error) {
-	request, err := http.NewRequest(http.MethodGet, address, nil)
+// readBody reads the entire response body.
+func readBody(body io.Reader) (interface{}, error) {
+	return io.ReadAll(body)
+}
+
+func buildGETRequest(address string, auth acquire.Acquirer, signer *requestSigner) (*http.Request, error) {
+	return buildRequest(http.MethodGet, address, nil, auth, signer)
+}
+
+func buildRequest(method string, address string, body io.Reader, auth acquire.Acquirer, signer *requestSigner) (*http.Request, error) {
+	request, err := http.NewRequest(method, address, body)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := acquire.AddAuth(request, auth); err != nil {
-		return nil, err
+	if signer == nil || !signer.replaceAuth {
+		if err := acquire.AddAuth(request, auth); err != nil {
+			return nil, err
+		}
 	}
 
 	return request, nil
 }
 
-// logs prometheus metrics when circuit breaker state changes
+// logs prometheus metrics when circuit breaker state changes.  Each circuit breaker's open time is tracked by its
+// name, as the primary and secondary circuit breakers share this function.
 func onStateChanged(m Measures) func(string, gobreaker.State, gobreaker.State) {
-	var start time.Time
+	var (
+		lock   sync.Mutex
+		starts = make(map[string]time.Time)
+	)
 	return func(name string, from gobreaker.State, to gobreaker.State) {
 		if m.CircuitBreakerStatus != nil {
 			switch to {
@@ -143,11 +397,17 @@ func onStateChanged(m Measures) func(string, gobreaker.State, gobreaker.State) {
 			}
 		}
 
+		lock.Lock()
+		defer lock.Unlock()
 		if from == gobreaker.StateClosed && to == gobreaker.StateOpen {
-			start = time.Now()
-		} else if to == gobreaker.StateClosed && m.CircuitBreakerOpenDuration != nil {
-			openTime := time.Since(start).Seconds()
-			m.CircuitBreakerOpenDuration.With(prometheus.Labels{circuitBreakerLabel: name}).Observe(openTime)
+			starts[name] = time.Now()
+		} else if to == gobreaker.StateClosed {
+			start, found := starts[name]
+			delete(starts, name)
+			if found && m.CircuitBreakerOpenDuration != nil {
+				openTime := time.Since(start).Seconds()
+				m.CircuitBreakerOpenDuration.With(prometheus.Labels{circuitBreakerLabel: name}).Observe(openTime)
+			}
 		}
 	}
 }
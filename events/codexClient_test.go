@@ -1,21 +1,29 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/sony/gobreaker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/candlelight"
 	"github.com/xmidt-org/interpreter"
 	"github.com/xmidt-org/touchstone/touchtest"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/ratelimit"
 	"go.uber.org/zap"
 )
@@ -25,6 +33,7 @@ func TestGetEvents(t *testing.T) {
 	t.Run("client error", testClientErr)
 	t.Run("unmarshal error", testUnmarshalErr)
 	t.Run("success", testSuccess)
+	t.Run("tracing", testTracing)
 }
 
 func testUnmarshalErr(t *testing.T) {
@@ -43,7 +52,7 @@ func testUnmarshalErr(t *testing.T) {
 		Auth:           auth,
 		RateLimiter:    ratelimit.NewUnlimited(),
 	}
-	eventsList := c.GetEvents("some-deviceID")
+	eventsList := c.GetEvents(context.Background(), "some-deviceID")
 	assert.NotNil(eventsList)
 	assert.Empty(eventsList)
 }
@@ -61,7 +70,7 @@ func testClientErr(t *testing.T) {
 		Auth:           auth,
 		RateLimiter:    ratelimit.NewUnlimited(),
 	}
-	eventsList := c.GetEvents("some-deviceID")
+	eventsList := c.GetEvents(context.Background(), "some-deviceID")
 	assert.NotNil(eventsList)
 	assert.Empty(eventsList)
 }
@@ -78,7 +87,7 @@ func testBuildRequestErr(t *testing.T) {
 		Auth:           auth,
 		RateLimiter:    ratelimit.NewUnlimited(),
 	}
-	eventsList := c.GetEvents("some-deviceID")
+	eventsList := c.GetEvents(context.Background(), "some-deviceID")
 	assert.NotNil(eventsList)
 	assert.Empty(eventsList)
 }
@@ -120,7 +129,7 @@ func testSuccess(t *testing.T) {
 		Auth:           auth,
 		RateLimiter:    ratelimit.NewUnlimited(),
 	}
-	eventsList := c.GetEvents("some-deviceID")
+	eventsList := c.GetEvents(context.Background(), "some-deviceID")
 	assert.Equal(events, eventsList)
 }
 
@@ -152,6 +161,13 @@ func TestDoRequest(t *testing.T) {
 			expectedStatusCode: 209,
 			expectedBody:       []byte("test body"),
 		},
+		{
+			description:        "unsuccessful status",
+			client:             new(mockClient),
+			expectedStatusCode: 503,
+			expectedBody:       []byte("unavailable"),
+			expectedErr:        errUnsuccessfulStatus,
+		},
 		{
 			description:        "client error",
 			client:             new(mockClient),
@@ -204,7 +220,7 @@ func TestDoRequest(t *testing.T) {
 				Client:  tc.client,
 				Metrics: m,
 			}
-			body, err := c.doRequest(request, current)
+			body, err := c.doRequest(request, current, readBody)
 			if tc.expectedErr != nil {
 				assert.NotNil(err)
 				assert.Contains(err.Error(), tc.expectedErr.Error())
@@ -252,7 +268,7 @@ func TestBuildGETRequest(t *testing.T) {
 			if tc.auth != nil {
 				tc.auth.On("Acquire").Return(tc.expectedAuthString, tc.expectedAuthErr)
 			}
-			req, err := buildGETRequest(tc.address, tc.auth)
+			req, err := buildGETRequest(tc.address, tc.auth, nil)
 			if tc.errExpected == nil {
 				assert.Equal(http.MethodGet, req.Method)
 				assert.Equal(req.Header.Get("Authorization"), tc.expectedAuthString)
@@ -319,7 +335,7 @@ func TestExecuteRequest(t *testing.T) {
 				RateLimiter:    ratelimit.NewUnlimited(),
 				Metrics:        m,
 			}
-			body, err := c.executeRequest(req)
+			body, err := c.executeRequest(req, c.CircuitBreaker)
 			if tc.clientErr != nil {
 				assert.Equal(tc.clientErr, err)
 				assert.Nil(body)
@@ -389,3 +405,79 @@ func TestOnStateChanged(t *testing.T) {
 	}
 
 }
+
+func TestOnStateChangedOpenDuration(t *testing.T) {
+	assert := assert.New(t)
+	m := Measures{
+		CircuitBreakerOpenDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "circuitBreakerOpenDuration",
+			Help:    "circuitBreakerOpenDuration",
+			Buckets: []float64{0.05, 10},
+		}, []string{circuitBreakerLabel}),
+	}
+	s := onStateChanged(m)
+
+	s("primary", gobreaker.StateClosed, gobreaker.StateOpen)
+	time.Sleep(100 * time.Millisecond)
+	s("secondary", gobreaker.StateClosed, gobreaker.StateOpen)
+	s("secondary", gobreaker.StateHalfOpen, gobreaker.StateClosed)
+	s("primary", gobreaker.StateHalfOpen, gobreaker.StateClosed)
+
+	// closing a circuit breaker that was never opened isn't observed.
+	s("other", gobreaker.StateHalfOpen, gobreaker.StateClosed)
+
+	assert.Equal(2, testutil.CollectAndCount(m.CircuitBreakerOpenDuration))
+	for name, expectedFast := range map[string]uint64{"primary": 0, "secondary": 1} {
+		histogram := &dto.Metric{}
+		observer, err := m.CircuitBreakerOpenDuration.GetMetricWithLabelValues(name)
+		assert.Nil(err)
+		assert.Nil(observer.(prometheus.Metric).Write(histogram))
+		assert.Equal(uint64(1), histogram.GetHistogram().GetSampleCount(), name)
+		assert.Equal(expectedFast, histogram.GetHistogram().GetBucket()[0].GetCumulativeCount(), name)
+	}
+}
+
+func testTracing(t *testing.T) {
+	assert := assert.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	tracing, err := candlelight.New(candlelight.Config{
+		Provider: "test",
+		Providers: map[string]candlelight.ProviderConstructor{
+			"test": func(candlelight.Config) (oteltrace.TracerProvider, error) {
+				return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), nil
+			},
+		},
+	})
+	if !assert.Nil(err) {
+		return
+	}
+
+	ctx, parent := tracing.TracerProvider().Tracer("test").Start(context.Background(), "parent")
+	client := new(mockClient)
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	resp := httptest.NewRecorder()
+	resp.WriteString("[]")
+	client.On("Do", mock.MatchedBy(func(r *http.Request) bool {
+		return strings.Contains(r.Header.Get("traceparent"), parent.SpanContext().TraceID().String())
+	})).Return(resp.Result(), nil) // nolint:bodyclose
+	c := CodexClient{
+		Logger:         zap.NewNop(),
+		Client:         client,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		Tracing:        tracing,
+	}
+	c.GetEvents(ctx, "some-deviceID")
+	parent.End()
+
+	client.AssertExpectations(t)
+	spans := recorder.Ended()
+	if !assert.Len(spans, 2) {
+		return
+	}
+	assert.Equal("codex get events", spans[0].Name())
+	assert.Equal(parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Contains(spans[0].Attributes(), attribute.String(deviceIDAttribute, "some-deviceID"))
+}
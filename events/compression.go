@@ -0,0 +1,59 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/compression"
+	"github.com/xmidt-org/httpaux"
+)
+
+// CompressionConfig configures requesting compressed responses from codex.
+type CompressionConfig struct {
+	// Enabled turns on requesting gzip or deflate compressed responses from codex.
+	Enabled bool
+}
+
+// compressionClient requests gzip or deflate compressed responses, decompressing them as they are read.
+type compressionClient struct {
+	next  httpaux.Client
+	bytes *prometheus.CounterVec
+}
+
+func (c compressionClient) Do(request *http.Request) (*http.Response, error) {
+	request.Header.Set("Accept-Encoding", compression.AcceptEncoding)
+	response, err := c.next.Do(request)
+	if err != nil || response.Body == nil {
+		return response, err
+	}
+
+	body, err := compression.NewReader(response.Header.Get("Content-Encoding"), response.Body, c.bytes)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+
+	response.Body = body
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+	return response, nil
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/glaukos/compression"
+)
+
+func TestCompressionClient(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"msg_type":4}`), 50)
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(data)
+	w.Close()
+
+	tests := []struct {
+		description string
+		encoding    string
+		body        []byte
+		expectedErr bool
+	}{
+		{
+			description: "Gzip response",
+			encoding:    "gzip",
+			body:        compressed.Bytes(),
+		},
+		{
+			description: "Uncompressed response",
+			body:        data,
+		},
+		{
+			description: "Unsupported encoding",
+			encoding:    "br",
+			body:        data,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(compression.AcceptEncoding, r.Header.Get("Accept-Encoding"))
+				if len(tc.encoding) > 0 {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				w.Write(tc.body)
+			}))
+			defer server.Close()
+
+			counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testBytes", Help: "testBytes"}, []string{compression.EncodingLabel, compression.CompressionLabel})
+			client := compressionClient{next: &http.Client{}, bytes: counter}
+			request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			response, err := client.Do(request)
+			if tc.expectedErr {
+				assert.ErrorIs(err, compression.ErrUnsupportedEncoding)
+				assert.Nil(response)
+				return
+			}
+
+			if !assert.Nil(err) {
+				return
+			}
+
+			defer response.Body.Close()
+			body, err := io.ReadAll(response.Body)
+			assert.Nil(err)
+			assert.Equal(data, body)
+			assert.Empty(response.Header.Get("Content-Encoding"))
+			assert.Equal(float64(len(data)), testutil.ToFloat64(counter.WithLabelValues(compression.Encoding(tc.encoding), compression.Uncompressed)))
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sony/gobreaker"
+	"github.com/xmidt-org/bascule/acquire"
+	"github.com/xmidt-org/candlelight"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	codexEventSourceType   = "codex"
+	graphQLEventSourceType = "graphql"
+	fileEventSourceType    = "file"
+)
+
+var (
+	errInvalidEventSource = errors.New("invalid event source")
+	errInvalidEventsFile  = errors.New("invalid events file")
+)
+
+// EventSource gets the history of events related to devices.
+type EventSource interface {
+	// GetEvents gets the events related to a device.
+	GetEvents(ctx context.Context, device string) []interpreter.Event
+
+	// GetEventsBatch gets the lists of events related to each device, keyed by device id.
+	GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event
+}
+
+// ParserEventSource is an EventSource that can attribute its lookups to the parsers making them.
+type ParserEventSource interface {
+	EventSource
+
+	// ForParser returns an EventSource that attributes its lookups to the parser given.
+	ForParser(parser string) EventSource
+}
+
+// EventSourceConfig determines where the history of events related to devices is gotten from.
+type EventSourceConfig struct {
+	// Type is the backend events are gotten from, either "codex", "graphql", or "file".  Any service implementing
+	// codex's device events API, such as a local cache or a mock server, can be used with the codex type by setting
+	// codex.address.  The graphql type gets events from codex's GraphQL endpoint instead.  Defaults to codex.
+	Type string
+
+	// GraphQL configures the graphql type, which otherwise uses the codex config.
+	GraphQL GraphQLEventSourceConfig
+
+	// File configures the file type.
+	File FileEventSourceConfig
+
+	// Aliases configures merging the histories of the ids a device reports under.
+	Aliases AliasConfig
+
+	// Trim configures trimming the histories to what the parsers need.
+	Trim TrimmingConfig
+}
+
+// FileEventSourceConfig configures getting the history of events from a JSON file, such as when running glaukos
+// without codex.
+type FileEventSourceConfig struct {
+	// Path is the JSON file with the lists of events related to each device, keyed by device id.
+	Path string
+}
+
+// EventSourceIn provides everything needed to create the event source.
+type EventSourceIn struct {
+	fx.In
+	Config         EventSourceConfig
+	CodexConfig    CodexConfig
+	CircuitBreaker *gobreaker.CircuitBreaker
+	OnStateChange  func(string, gobreaker.State, gobreaker.State)
+	CodexAuth      acquire.Acquirer
+	Signer         *requestSigner
+	Recent         *RecentEventStore
+	Measures       Measures
+	Tracing        candlelight.Tracing
+	Logger         *zap.Logger
+}
+
+// createEventSource creates the event source of the configured type, merging the histories of aliases and
+// trimming the histories if they are enabled.
+func createEventSource(in EventSourceIn) (EventSource, error) {
+	source, err := newEventSource(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Config.Aliases.Enabled {
+		source = aliasEventSource{next: source, resolver: newAliasResolver(in.Config.Aliases, in.Logger)}
+	}
+
+	if in.Config.Trim.Enabled {
+		source = newTrimmingEventSource(source)
+	}
+
+	return source, nil
+}
+
+// newEventSource creates the event source of the configured type.
+func newEventSource(in EventSourceIn) (EventSource, error) {
+	switch in.Config.Type {
+	case "", codexEventSourceType:
+		return createCodexClient(in.CodexConfig, in.CircuitBreaker, in.OnStateChange, in.CodexAuth, in.Signer, in.Recent, in.Measures, in.Tracing, in.Logger)
+	case graphQLEventSourceType:
+		client, err := createCodexClient(in.CodexConfig, in.CircuitBreaker, in.OnStateChange, in.CodexAuth, in.Signer, in.Recent, in.Measures, in.Tracing, in.Logger)
+		if err != nil {
+			return nil, err
+		}
+
+		client.GraphQL = newGraphQLQuery(in.Config.GraphQL)
+		return client, nil
+	case fileEventSourceType:
+		in.Logger.Info("getting the history of events from a file", zap.String("path", in.Config.File.Path))
+		return newFileEventSource(in.Config.File)
+	default:
+		return nil, fmt.Errorf("%w: %q", errInvalidEventSource, in.Config.Type)
+	}
+}
+
+// fileEventSource gets the history of events from the lists of events loaded from a file.
+type fileEventSource struct {
+	events map[string][]interpreter.Event
+}
+
+func newFileEventSource(config FileEventSourceConfig) (*fileEventSource, error) {
+	data, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidEventsFile, err)
+	}
+
+	var eventLists map[string][]interpreter.Event
+	if err := json.Unmarshal(data, &eventLists); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidEventsFile, err)
+	}
+
+	events := make(map[string][]interpreter.Event, len(eventLists))
+	for device, eventList := range eventLists {
+		events[deviceid.Normalize(device)] = eventList
+	}
+
+	return &fileEventSource{events: events}, nil
+}
+
+// GetEvents implements the EventSource interface.
+func (f *fileEventSource) GetEvents(_ context.Context, device string) []interpreter.Event {
+	eventList := f.events[deviceid.Normalize(device)]
+	result := make([]interpreter.Event, len(eventList))
+	copy(result, eventList)
+	return result
+}
+
+// GetEventsBatch implements the EventSource interface.
+func (f *fileEventSource) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	results := make(map[string][]interpreter.Event, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		results[deviceID] = f.GetEvents(ctx, deviceID)
+	}
+
+	return results
+}
@@ -0,0 +1,144 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/bascule/acquire"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+func writeEventsFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCreateEventSource(t *testing.T) {
+	events := map[string][]interpreter.Event{
+		"mac:112233445566": {{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abcd"}},
+	}
+	data, err := json.Marshal(events)
+	if !assert.Nil(t, err) {
+		return
+	}
+	validFile := writeEventsFile(t, string(data))
+	invalidFile := writeEventsFile(t, "not json")
+
+	tests := []struct {
+		description   string
+		config        EventSourceConfig
+		expectedCodex bool
+		expectedFile  bool
+		expectedAlias bool
+		expectedErr   error
+	}{
+		{
+			description:   "Default",
+			expectedCodex: true,
+		},
+		{
+			description:   "Codex",
+			config:        EventSourceConfig{Type: "codex"},
+			expectedCodex: true,
+		},
+		{
+			description:   "GraphQL",
+			config:        EventSourceConfig{Type: "graphql"},
+			expectedCodex: true,
+		},
+		{
+			description:  "File",
+			config:       EventSourceConfig{Type: "file", File: FileEventSourceConfig{Path: validFile}},
+			expectedFile: true,
+		},
+		{
+			description: "Aliases",
+			config: EventSourceConfig{Type: "file", File: FileEventSourceConfig{Path: validFile},
+				Aliases: AliasConfig{Enabled: true}},
+			expectedAlias: true,
+		},
+		{
+			description: "Missing file",
+			config:      EventSourceConfig{Type: "file", File: FileEventSourceConfig{Path: filepath.Join(t.TempDir(), "missing.json")}},
+			expectedErr: errInvalidEventsFile,
+		},
+		{
+			description: "Invalid file",
+			config:      EventSourceConfig{Type: "file", File: FileEventSourceConfig{Path: invalidFile}},
+			expectedErr: errInvalidEventsFile,
+		},
+		{
+			description: "Invalid type",
+			config:      EventSourceConfig{Type: "cassandra"},
+			expectedErr: errInvalidEventSource,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			source, err := createEventSource(EventSourceIn{
+				Config:         tc.config,
+				CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test"}),
+				CodexAuth:      &acquire.DefaultAcquirer{},
+				Measures: Measures{
+					CircuitBreakerStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "testStatus", Help: "testStatus"}, []string{circuitBreakerLabel}),
+				},
+				Logger: zap.NewNop(),
+			})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(source)
+				return
+			}
+
+			assert.Nil(err)
+			aliases, isAlias := source.(aliasEventSource)
+			assert.Equal(tc.expectedAlias, isAlias)
+			if isAlias {
+				source = aliases.next
+				tc.expectedFile = true
+			}
+
+			codex, isCodex := source.(*CodexClient)
+			assert.Equal(tc.expectedCodex, isCodex)
+			if isCodex {
+				assert.Equal(tc.config.Type == graphQLEventSourceType, codex.GraphQL != nil)
+			}
+			_, isFile := source.(*fileEventSource)
+			assert.Equal(tc.expectedFile, isFile)
+		})
+	}
+}
+
+func TestFileEventSource(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abcd"}}
+	data, err := json.Marshal(map[string][]interpreter.Event{"MAC:11:22:33:44:55:66": events})
+	if !assert.Nil(err) {
+		return
+	}
+
+	source, err := newFileEventSource(FileEventSourceConfig{Path: writeEventsFile(t, string(data))})
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal(events, source.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Empty(source.GetEvents(context.Background(), "mac:aabbccddeeff"))
+	assert.NotNil(source.GetEvents(context.Background(), "mac:aabbccddeeff"))
+	assert.Equal(map[string][]interpreter.Event{
+		"mac:112233445566": events,
+		"mac:aabbccddeeff": {},
+	}, source.GetEventsBatch(context.Background(), []string{"mac:112233445566", "mac:aabbccddeeff"}))
+}
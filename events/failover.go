@@ -0,0 +1,161 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+const (
+	backendLabel = "backend"
+
+	successfulLookup = "success"
+
+	defaultFailureThreshold = 3
+	defaultProbeInterval    = 30 * time.Second
+)
+
+// FailoverConfig configures failing over from the primary codex to secondary codex instances while the primary
+// is failing.
+type FailoverConfig struct {
+	// Secondaries are the addresses of the codex instances failed over to, in order.  If this is empty, every
+	// request is sent to the primary.
+	Secondaries []string
+
+	// FailureThreshold is the number of consecutive failed requests to a codex before failing over to the next.
+	// Requests rejected by a codex's open circuit breaker fail over right away.  Defaults to 3.
+	FailureThreshold int
+
+	// ProbeInterval is how often a request is sent to the primary while failed over, failing back to it once a
+	// request succeeds.  Defaults to 30s.
+	ProbeInterval time.Duration
+}
+
+// codexBackend is a codex instance requests can be sent to.
+type codexBackend struct {
+	address        string
+	circuitBreaker *gobreaker.CircuitBreaker
+}
+
+// codexBackends chooses the codex that requests are sent to, failing over from the primary to the secondaries
+// while the primary fails and periodically probing the primary for recovery.
+type codexBackends struct {
+	// backends are the primary followed by the secondaries.
+	backends      []codexBackend
+	threshold     int
+	probeInterval time.Duration
+	requests      *prometheus.CounterVec
+	current       func() time.Time
+	logger        *zap.Logger
+
+	lock      sync.Mutex
+	active    int
+	failures  int
+	lastProbe time.Time
+}
+
+func newCodexBackends(config FailoverConfig, primary codexBackend, secondaries []codexBackend, requests *prometheus.CounterVec, logger *zap.Logger) *codexBackends {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaultFailureThreshold
+	}
+
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = defaultProbeInterval
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &codexBackends{
+		backends:      append([]codexBackend{primary}, secondaries...),
+		threshold:     config.FailureThreshold,
+		probeInterval: config.ProbeInterval,
+		requests:      requests,
+		current:       time.Now,
+		logger:        logger,
+	}
+}
+
+// next returns the index of the backend the next request is sent to.  While failed over, a request is sent to the
+// primary once every probe interval.
+func (b *codexBackends) next() (int, codexBackend) {
+	now := b.current()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.active != 0 && now.Sub(b.lastProbe) >= b.probeInterval {
+		b.lastProbe = now
+		return 0, b.backends[0]
+	}
+
+	return b.active, b.backends[b.active]
+}
+
+// report records the outcome of a request sent to the backend.  The active backend is failed over once it has
+// failed enough requests in a row, or right away if its circuit breaker rejected the request, while a successful
+// probe of the primary fails back to it.
+func (b *codexBackends) report(i int, err error) {
+	if b.requests != nil {
+		result := successfulLookup
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			result = rejectedLookup
+		} else if err != nil {
+			result = failedLookup
+		}
+		b.requests.With(prometheus.Labels{backendLabel: b.backends[i].address, lookupResultLabel: result}).Add(1.0)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err == nil {
+		if i == 0 && b.active != 0 {
+			b.logger.Info("codex primary recovered, failing back", zap.String("from", b.backends[b.active].address), zap.String("to", b.backends[0].address))
+			b.active = 0
+		}
+
+		if i == b.active {
+			b.failures = 0
+		}
+		return
+	}
+
+	// failed probes don't affect the active backend.
+	if i != b.active {
+		return
+	}
+
+	b.failures++
+	rejected := errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+	if b.failures < b.threshold && !rejected {
+		return
+	}
+
+	next := (b.active + 1) % len(b.backends)
+	b.logger.Warn("codex failing, failing over", zap.Error(err), zap.String("from", b.backends[b.active].address), zap.String("to", b.backends[next].address))
+	if b.active == 0 {
+		b.lastProbe = b.current()
+	}
+	b.active = next
+	b.failures = 0
+}
@@ -0,0 +1,197 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+)
+
+func newTestBackends(config FailoverConfig) *codexBackends {
+	backends := newCodexBackends(config,
+		codexBackend{address: "primary", circuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "primary"})},
+		[]codexBackend{
+			{address: "secondary-1", circuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "secondary-1"})},
+			{address: "secondary-2", circuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "secondary-2"})},
+		},
+		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testBackendRequests", Help: "testBackendRequests"}, []string{backendLabel, lookupResultLabel}),
+		nil,
+	)
+	return backends
+}
+
+func TestCodexBackends(t *testing.T) {
+	testErr := errors.New("test error")
+	tests := []struct {
+		description    string
+		errs           []error
+		expectedActive string
+	}{
+		{
+			description:    "Success",
+			errs:           []error{nil, nil, nil},
+			expectedActive: "primary",
+		},
+		{
+			description:    "Failures under threshold",
+			errs:           []error{testErr, testErr, nil, testErr, testErr},
+			expectedActive: "primary",
+		},
+		{
+			description:    "Failures reach threshold",
+			errs:           []error{testErr, testErr, testErr},
+			expectedActive: "secondary-1",
+		},
+		{
+			description:    "Circuit breaker open",
+			errs:           []error{gobreaker.ErrOpenState},
+			expectedActive: "secondary-1",
+		},
+		{
+			description:    "Secondary failing",
+			errs:           []error{gobreaker.ErrOpenState, gobreaker.ErrTooManyRequests},
+			expectedActive: "secondary-2",
+		},
+		{
+			description:    "All failing",
+			errs:           []error{gobreaker.ErrOpenState, gobreaker.ErrOpenState, gobreaker.ErrOpenState},
+			expectedActive: "primary",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			backends := newTestBackends(FailoverConfig{ProbeInterval: time.Hour})
+			for _, err := range tc.errs {
+				i, _ := backends.next()
+				backends.report(i, err)
+			}
+
+			_, backend := backends.next()
+			assert.Equal(tc.expectedActive, backend.address)
+		})
+	}
+}
+
+func TestCodexBackendsProbe(t *testing.T) {
+	assert := assert.New(t)
+	backends := newTestBackends(FailoverConfig{ProbeInterval: time.Minute})
+	now := time.Now()
+	backends.current = func() time.Time { return now }
+	backends.report(0, gobreaker.ErrOpenState)
+
+	// the primary isn't probed until the probe interval has passed.
+	i, backend := backends.next()
+	assert.Equal(1, i)
+	assert.Equal("secondary-1", backend.address)
+	backends.report(i, nil)
+
+	now = now.Add(time.Minute)
+	i, backend = backends.next()
+	assert.Equal(0, i)
+	assert.Equal("primary", backend.address)
+
+	// a failed probe keeps the secondary active.
+	backends.report(i, errors.New("test error"))
+	i, backend = backends.next()
+	assert.Equal("secondary-1", backend.address)
+	backends.report(i, nil)
+
+	now = now.Add(time.Minute)
+	i, _ = backends.next()
+	backends.report(i, nil)
+	_, backend = backends.next()
+	assert.Equal("primary", backend.address)
+
+	assert.Equal(1.0, testutil.ToFloat64(backends.requests.With(prometheus.Labels{backendLabel: "primary", lookupResultLabel: rejectedLookup})))
+	assert.Equal(1.0, testutil.ToFloat64(backends.requests.With(prometheus.Labels{backendLabel: "primary", lookupResultLabel: failedLookup})))
+	assert.Equal(1.0, testutil.ToFloat64(backends.requests.With(prometheus.Labels{backendLabel: "primary", lookupResultLabel: successfulLookup})))
+	assert.Equal(2.0, testutil.ToFloat64(backends.requests.With(prometheus.Labels{backendLabel: "secondary-1", lookupResultLabel: successfulLookup})))
+}
+
+func TestGetEventsFailover(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abcd"}}
+	// the primary is unreachable.
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer secondary.Close()
+
+	primaryCB := gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "primary"})
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	c := CodexClient{
+		Address:        primary.URL,
+		Logger:         zap.NewNop(),
+		Client:         http.DefaultClient,
+		CircuitBreaker: primaryCB,
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		Metrics: Measures{
+			CircuitBreakerRejectedCount: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testRejected", Help: "testRejected"}, []string{circuitBreakerLabel}),
+		},
+	}
+	c.Backends = newCodexBackends(FailoverConfig{FailureThreshold: 1, ProbeInterval: time.Hour},
+		codexBackend{address: primary.URL, circuitBreaker: primaryCB},
+		[]codexBackend{{address: secondary.URL, circuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "secondary"})}},
+		nil, nil,
+	)
+
+	assert.Empty(c.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Equal(events, c.GetEvents(context.Background(), "mac:112233445566"))
+}
+
+func TestGetEventsFailoverServerError(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abcd"}}
+	// the primary is reachable, but failing.
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("[]"))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer secondary.Close()
+
+	primaryCB := gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "primary"})
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	c := CodexClient{
+		Address:        primary.URL,
+		Logger:         zap.NewNop(),
+		Client:         http.DefaultClient,
+		CircuitBreaker: primaryCB,
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		Metrics: Measures{
+			CircuitBreakerRejectedCount: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testRejected", Help: "testRejected"}, []string{circuitBreakerLabel}),
+		},
+	}
+	c.Backends = newCodexBackends(FailoverConfig{FailureThreshold: 2, ProbeInterval: time.Hour},
+		codexBackend{address: primary.URL, circuitBreaker: primaryCB},
+		[]codexBackend{{address: secondary.URL, circuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "secondary"})}},
+		nil, nil,
+	)
+
+	assert.Empty(c.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Empty(c.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Equal(events, c.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Equal(uint32(2), primaryCB.Counts().TotalFailures)
+}
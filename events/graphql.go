@@ -0,0 +1,276 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xmidt-org/bascule/acquire"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	defaultGraphQLPath = "/api/v1/graphql"
+
+	// defaultGraphQLQuery requests only the fields of a device's events that parsers use.
+	defaultGraphQLQuery = `query ($deviceID: String!) {
+  events(deviceID: $deviceID) {
+    destination
+    birthdate
+    transactionUUID
+    bootTime: metadata(key: "/boot-time")
+  }
+}`
+
+	graphQLDeviceIDVariable = "deviceID"
+)
+
+var (
+	errGraphQL          = errors.New("graphql query failed")
+	errNotGraphQLObject = errors.New("graphql response isn't an object")
+
+	// errStopDecoding stops decoding a streamed response once it has been truncated.
+	errStopDecoding = errors.New("stop decoding")
+)
+
+// GraphQLEventSourceConfig configures getting the history of events from codex's GraphQL endpoint, requesting
+// only the fields parsers use rather than the full events.
+type GraphQLEventSourceConfig struct {
+	// Path is the path of codex's GraphQL endpoint.  Defaults to /api/v1/graphql.
+	Path string
+
+	// Query is the GraphQL query sent, given the device id in the deviceID variable.  It must return the device's
+	// events in data.events, with the destination, birthdate, transactionUUID, and bootTime of each.  Defaults to
+	// querying the events field for these.
+	Query string
+}
+
+// graphQLQuery builds and decodes the GraphQL queries for a device's events.
+type graphQLQuery struct {
+	path  string
+	query string
+}
+
+func newGraphQLQuery(config GraphQLEventSourceConfig) *graphQLQuery {
+	if len(config.Path) == 0 {
+		config.Path = defaultGraphQLPath
+	}
+
+	if !strings.HasPrefix(config.Path, "/") {
+		config.Path = "/" + config.Path
+	}
+
+	if len(config.Query) == 0 {
+		config.Query = defaultGraphQLQuery
+	}
+
+	return &graphQLQuery{
+		path:  config.Path,
+		query: config.Query,
+	}
+}
+
+type graphQLRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables"`
+}
+
+type graphQLEvent struct {
+	Destination     string      `json:"destination"`
+	Birthdate       int64       `json:"birthdate"`
+	TransactionUUID string      `json:"transactionUUID"`
+	BootTime        json.Number `json:"bootTime"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data struct {
+		Events []graphQLEvent `json:"events"`
+	} `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+// buildRequest builds the request querying codex at the address for the device's events.
+func (q *graphQLQuery) buildRequest(address string, device string, auth acquire.Acquirer, signer *requestSigner) (*http.Request, error) {
+	body, err := json.Marshal(graphQLRequest{
+		Query:     q.query,
+		Variables: map[string]string{graphQLDeviceIDVariable: device},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := buildRequest(http.MethodPost, address+q.path, bytes.NewReader(body), auth, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	return request, nil
+}
+
+// decode converts the events in the response into events with only the fields requested.
+func (q *graphQLQuery) decode(data []byte) ([]interpreter.Event, error) {
+	var response graphQLResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	if err := graphQLErrors(response.Errors); err != nil {
+		return nil, err
+	}
+
+	eventList := make([]interpreter.Event, 0, len(response.Data.Events))
+	for _, e := range response.Data.Events {
+		eventList = append(eventList, e.event())
+	}
+
+	return eventList, nil
+}
+
+// decodeStream decodes the events in the response as they are read, keeping only the events the stream needs
+// and truncating the response once it exceeds the stream's limits.  If start isn't zero, the events received
+// before it are discarded too.
+func (q *graphQLQuery) decodeStream(r io.Reader, stream *eventStream, start time.Time) (streamedEvents, error) {
+	var (
+		result = streamedEvents{events: make([]interpreter.Event, 0)}
+		errs   []graphQLError
+	)
+
+	decoder := json.NewDecoder(stream.limit(r))
+	err := decodeObject(decoder, func(key string) error {
+		switch key {
+		case "data":
+			return decodeObject(decoder, func(key string) error {
+				if key != "events" {
+					return skipValue(decoder)
+				}
+
+				var err error
+				if result, err = stream.decodeList(decoder, start, decodeGraphQLEvent); err == nil && len(result.truncated) > 0 {
+					return errStopDecoding
+				}
+				return err
+			})
+		case "errors":
+			return decoder.Decode(&errs)
+		default:
+			return skipValue(decoder)
+		}
+	})
+
+	// the events decoded before the response was truncated are kept, unless errors were already decoded.
+	if errors.Is(err, errResponseTooLarge) {
+		result = stream.truncate(result, bytesTruncate)
+	} else if err != nil && !errors.Is(err, errStopDecoding) {
+		return streamedEvents{}, err
+	}
+
+	if err := graphQLErrors(errs); err != nil {
+		return streamedEvents{}, err
+	}
+
+	return result, nil
+}
+
+// event converts the event into an event with only the fields requested.
+func (e graphQLEvent) event() interpreter.Event {
+	event := interpreter.Event{
+		MsgType:         4,
+		Destination:     e.Destination,
+		Birthdate:       e.Birthdate,
+		TransactionUUID: e.TransactionUUID,
+		Metadata:        map[string]string{},
+	}
+
+	if len(e.BootTime) > 0 {
+		event.Metadata[interpreter.BootTimeKey] = e.BootTime.String()
+	}
+
+	return event
+}
+
+func decodeGraphQLEvent(decoder *json.Decoder) (interpreter.Event, error) {
+	var e graphQLEvent
+	if err := decoder.Decode(&e); err != nil {
+		return interpreter.Event{}, err
+	}
+
+	return e.event(), nil
+}
+
+// graphQLErrors returns the errors in a response as one error, or nil if there are none.
+func graphQLErrors(errs []graphQLError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+	}
+	return fmt.Errorf("%w: %s", errGraphQL, strings.Join(messages, "; "))
+}
+
+// decodeObject decodes the JSON object read by the decoder, calling decodeKey to decode the value of each key.
+// A null object has no keys.
+func decodeObject(decoder *json.Decoder, decodeKey func(string) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token == nil {
+		return nil
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return errNotGraphQLObject
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		key, _ := token.(string)
+		if err := decodeKey(key); err != nil {
+			return err
+		}
+	}
+
+	_, err = decoder.Token()
+	return err
+}
+
+// skipValue reads past the next JSON value.
+func skipValue(decoder *json.Decoder) error {
+	var value json.RawMessage
+	return decoder.Decode(&value)
+}
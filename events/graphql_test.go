@@ -0,0 +1,256 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+)
+
+func TestNewGraphQLQuery(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(&graphQLQuery{path: defaultGraphQLPath, query: defaultGraphQLQuery}, newGraphQLQuery(GraphQLEventSourceConfig{}))
+	assert.Equal(&graphQLQuery{path: "/gql", query: "{ events }"}, newGraphQLQuery(GraphQLEventSourceConfig{Path: "gql", Query: "{ events }"}))
+}
+
+func TestGraphQLBuildRequest(t *testing.T) {
+	assert := assert.New(t)
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	request, err := newGraphQLQuery(GraphQLEventSourceConfig{}).buildRequest("http://codex", "mac:112233445566", auth, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal(http.MethodPost, request.Method)
+	assert.Equal("http://codex/api/v1/graphql", request.URL.String())
+	assert.Equal("application/json", request.Header.Get("Content-Type"))
+	assert.Equal("test", request.Header.Get("Authorization"))
+
+	var body graphQLRequest
+	assert.Nil(json.NewDecoder(request.Body).Decode(&body))
+	assert.Equal(graphQLRequest{Query: defaultGraphQLQuery, Variables: map[string]string{"deviceID": "mac:112233445566"}}, body)
+}
+
+func TestGraphQLDecode(t *testing.T) {
+	tests := []struct {
+		description    string
+		data           string
+		expectedEvents []interpreter.Event
+		expectedErr    error
+	}{
+		{
+			description: "Success",
+			data: `{"data": {"events": [
+				{"destination": "event:device-status/mac:112233445566/online", "birthdate": 1617152053278595600, "transactionUUID": "abcd", "bootTime": "1617152000"},
+				{"destination": "event:device-status/mac:112233445566/offline", "birthdate": 1617152053278595601, "transactionUUID": "efgh", "bootTime": 1617152001},
+				{"destination": "event:device-status/mac:112233445566/offline", "birthdate": 1617152053278595602, "transactionUUID": "ijkl", "bootTime": null}
+			]}}`,
+			expectedEvents: []interpreter.Event{
+				{MsgType: 4, Destination: "event:device-status/mac:112233445566/online", Birthdate: 1617152053278595600, TransactionUUID: "abcd", Metadata: map[string]string{interpreter.BootTimeKey: "1617152000"}},
+				{MsgType: 4, Destination: "event:device-status/mac:112233445566/offline", Birthdate: 1617152053278595601, TransactionUUID: "efgh", Metadata: map[string]string{interpreter.BootTimeKey: "1617152001"}},
+				{MsgType: 4, Destination: "event:device-status/mac:112233445566/offline", Birthdate: 1617152053278595602, TransactionUUID: "ijkl", Metadata: map[string]string{}},
+			},
+		},
+		{
+			description:    "No events",
+			data:           `{"data": {"events": []}}`,
+			expectedEvents: []interpreter.Event{},
+		},
+		{
+			description: "GraphQL errors",
+			data:        `{"errors": [{"message": "unknown field"}, {"message": "bad query"}]}`,
+			expectedErr: errGraphQL,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			events, err := newGraphQLQuery(GraphQLEventSourceConfig{}).decode([]byte(tc.data))
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(events)
+				return
+			}
+
+			assert.Nil(err)
+			assert.Equal(tc.expectedEvents, events)
+		})
+	}
+
+	_, err := newGraphQLQuery(GraphQLEventSourceConfig{}).decode([]byte("not json"))
+	assert.NotNil(t, err)
+}
+
+func TestGraphQLDecodeStream(t *testing.T) {
+	const data = `{"data": {"count": 3, "events": [
+		{"destination": "event:device-status/mac:112233445566/online", "birthdate": 100, "transactionUUID": "abcd", "bootTime": "50"},
+		{"destination": "event:device-status/mac:112233445566/offline", "birthdate": 200, "transactionUUID": "efgh", "bootTime": 51},
+		{"destination": "event:device-status/mac:112233445566/online", "birthdate": 300, "transactionUUID": "ijkl"}
+	]}, "extensions": {"cost": 1}}`
+
+	events := []interpreter.Event{
+		{MsgType: 4, Destination: "event:device-status/mac:112233445566/online", Birthdate: 100, TransactionUUID: "abcd", Metadata: map[string]string{interpreter.BootTimeKey: "50"}},
+		{MsgType: 4, Destination: "event:device-status/mac:112233445566/offline", Birthdate: 200, TransactionUUID: "efgh", Metadata: map[string]string{interpreter.BootTimeKey: "51"}},
+		{MsgType: 4, Destination: "event:device-status/mac:112233445566/online", Birthdate: 300, TransactionUUID: "ijkl", Metadata: map[string]string{}},
+	}
+
+	tests := []struct {
+		description       string
+		data              string
+		config            StreamingConfig
+		limits            ResponseLimitsConfig
+		expectedEvents    []interpreter.Event
+		expectedTruncated string
+		expectedErr       error
+	}{
+		{
+			description:    "Success",
+			data:           data,
+			limits:         ResponseLimitsConfig{MaxEvents: 5},
+			expectedEvents: events,
+		},
+		{
+			description:    "Discarded destinations",
+			data:           data,
+			config:         StreamingConfig{Enabled: true, Destinations: []string{"/online$"}},
+			expectedEvents: []interpreter.Event{events[0], events[2]},
+		},
+		{
+			description:       "Max events",
+			data:              data,
+			limits:            ResponseLimitsConfig{MaxEvents: 2},
+			expectedEvents:    events[:2],
+			expectedTruncated: eventsTruncate,
+		},
+		{
+			description:       "Max bytes",
+			data:              data,
+			limits:            ResponseLimitsConfig{MaxBytes: 200},
+			expectedEvents:    events[:1],
+			expectedTruncated: bytesTruncate,
+		},
+		{
+			description:       "Max bytes before events",
+			data:              data,
+			limits:            ResponseLimitsConfig{MaxBytes: 10},
+			expectedEvents:    []interpreter.Event{},
+			expectedTruncated: bytesTruncate,
+		},
+		{
+			description: "GraphQL errors",
+			data:        `{"data": null, "errors": [{"message": "unknown field"}]}`,
+			limits:      ResponseLimitsConfig{MaxEvents: 5},
+			expectedErr: errGraphQL,
+		},
+		{
+			description: "Not an object",
+			data:        `[]`,
+			limits:      ResponseLimitsConfig{MaxEvents: 5},
+			expectedErr: errNotGraphQLObject,
+		},
+		{
+			description: "Events not a list",
+			data:        `{"data": {"events": {}}}`,
+			limits:      ResponseLimitsConfig{MaxEvents: 5},
+			expectedErr: errNotEventList,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			stream, err := newEventStream(tc.config, tc.limits, nil, nil)
+			if !assert.Nil(err) {
+				return
+			}
+
+			result, err := newGraphQLQuery(GraphQLEventSourceConfig{}).decodeStream(strings.NewReader(tc.data), stream, time.Time{})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				return
+			}
+
+			assert.Nil(err)
+			assert.Equal(tc.expectedEvents, result.events)
+			assert.Equal(tc.expectedTruncated, result.truncated)
+		})
+	}
+}
+
+func TestGetEventsGraphQLLimits(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"data": {"events": [
+			{"destination": "event:device-status/mac:112233445566/online", "birthdate": 100, "transactionUUID": "abcd", "bootTime": "50"},
+			{"destination": "event:device-status/mac:112233445566/offline", "birthdate": 200, "transactionUUID": "efgh", "bootTime": "50"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	stream, err := newEventStream(StreamingConfig{}, ResponseLimitsConfig{MaxEvents: 1}, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	c := CodexClient{
+		Address:        server.URL,
+		Logger:         zap.NewNop(),
+		Client:         http.DefaultClient,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		GraphQL:        newGraphQLQuery(GraphQLEventSourceConfig{}),
+		Stream:         stream,
+	}
+
+	assert.Equal([]interpreter.Event{
+		{MsgType: 4, Destination: "event:device-status/mac:112233445566/online", Birthdate: 100, TransactionUUID: "abcd", Metadata: map[string]string{interpreter.BootTimeKey: "50"}},
+	}, c.GetEvents(context.Background(), "mac:112233445566"))
+}
+
+func TestGetEventsGraphQL(t *testing.T) {
+	assert := assert.New(t)
+	var received graphQLRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != defaultGraphQLPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &received)
+		w.Write([]byte(`{"data": {"events": [{"destination": "event:device-status/mac:112233445566/online", "birthdate": 100, "transactionUUID": "abcd", "bootTime": "50"}]}}`))
+	}))
+	defer server.Close()
+
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	c := CodexClient{
+		Address:        server.URL,
+		Logger:         zap.NewNop(),
+		Client:         http.DefaultClient,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		GraphQL:        newGraphQLQuery(GraphQLEventSourceConfig{}),
+	}
+
+	assert.Equal([]interpreter.Event{
+		{MsgType: 4, Destination: "event:device-status/mac:112233445566/online", Birthdate: 100, TransactionUUID: "abcd", Metadata: map[string]string{interpreter.BootTimeKey: "50"}},
+	}, c.GetEvents(context.Background(), "MAC:112233445566"))
+	assert.Equal(map[string]string{"deviceID": "mac:112233445566"}, received.Variables)
+}
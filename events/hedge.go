@@ -0,0 +1,274 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/httpaux"
+	"go.uber.org/ratelimit"
+)
+
+const (
+	defaultHedgePercentile   = 0.95
+	defaultHedgeInitialDelay = time.Second
+	defaultHedgeMinDelay     = 10 * time.Millisecond
+	defaultHedgeWindow       = 1000
+	defaultHedgeMinSamples   = 100
+
+	// hedgeRecomputeInterval is the number of latencies observed between recomputing the hedge delay.
+	hedgeRecomputeInterval = 16
+)
+
+// HedgingConfig configures sending a second request to codex when the first is slower than most, using whichever
+// response arrives first.
+type HedgingConfig struct {
+	// Enabled turns on hedging codex requests.
+	Enabled bool
+
+	// Percentile of recent codex latencies after which a request is hedged, between 0 and 1.  Defaults to 0.95.
+	Percentile float64
+
+	// InitialDelay is the delay used until enough latencies have been observed.  Defaults to 1s.
+	InitialDelay time.Duration
+
+	// MinDelay is the shortest delay before a request is hedged.  Defaults to 10ms.
+	MinDelay time.Duration
+
+	// Window is the number of recent latencies the percentile is computed from.  Defaults to 1000.
+	Window int
+
+	// MinSamples is the number of latencies observed before the percentile is used.  Defaults to 100.
+	MinSamples int
+}
+
+// latencyTracker tracks the recent latencies of codex requests to determine how long to wait before hedging.
+type latencyTracker struct {
+	percentile   float64
+	minDelay     time.Duration
+	minSamples   int
+	lock         sync.Mutex
+	samples      []time.Duration
+	next         int
+	count        int
+	sinceCompute int
+	delay        time.Duration
+}
+
+func newLatencyTracker(config HedgingConfig) *latencyTracker {
+	if config.Percentile <= 0 || config.Percentile >= 1 {
+		config.Percentile = defaultHedgePercentile
+	}
+
+	if config.InitialDelay <= 0 {
+		config.InitialDelay = defaultHedgeInitialDelay
+	}
+
+	if config.MinDelay <= 0 {
+		config.MinDelay = defaultHedgeMinDelay
+	}
+
+	if config.Window <= 0 {
+		config.Window = defaultHedgeWindow
+	}
+
+	if config.MinSamples <= 0 {
+		config.MinSamples = defaultHedgeMinSamples
+	}
+
+	if config.MinSamples > config.Window {
+		config.MinSamples = config.Window
+	}
+
+	return &latencyTracker{
+		percentile: config.Percentile,
+		minDelay:   config.MinDelay,
+		minSamples: config.MinSamples,
+		samples:    make([]time.Duration, config.Window),
+		delay:      config.InitialDelay,
+	}
+}
+
+// Observe records the latency of a completed request.
+func (t *latencyTracker) Observe(latency time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.samples[t.next] = latency
+	t.next = (t.next + 1) % len(t.samples)
+	if t.count < len(t.samples) {
+		t.count++
+	}
+
+	t.sinceCompute++
+	if t.count >= t.minSamples && (t.count == t.minSamples || t.sinceCompute >= hedgeRecomputeInterval) {
+		t.compute()
+	}
+}
+
+// Delay returns how long to wait for a response before hedging the request.
+func (t *latencyTracker) Delay() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.delay
+}
+
+// compute sets the delay to the percentile of the recorded latencies.
+func (t *latencyTracker) compute() {
+	sorted := make([]time.Duration, t.count)
+	copy(sorted, t.samples[:t.count])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(t.percentile*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	t.delay = sorted[i]
+	if t.delay < t.minDelay {
+		t.delay = t.minDelay
+	}
+	t.sinceCompute = 0
+}
+
+type hedgeResult struct {
+	index    int
+	response *http.Response
+	err      error
+	latency  time.Duration
+}
+
+// hedgingClient sends a second request when the first hasn't completed within the tracked delay, returning the
+// first successful response and cancelling the other request.
+type hedgingClient struct {
+	next      httpaux.Client
+	latencies *latencyTracker
+	hedged    prometheus.Counter
+	cancelled prometheus.Counter
+
+	// limiter is the rate limiter of codex requests, which the second request takes a token from before it is
+	// sent, since the first request's token only covers it.  If this is nil, second requests aren't limited.
+	limiter ratelimit.Limiter
+}
+
+func (c hedgingClient) Do(request *http.Request) (*http.Response, error) {
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	send := func(limited bool) {
+		ctx, cancel := context.WithCancel(request.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			if limited && c.limiter != nil {
+				c.limiter.Take()
+				// the first request may have completed while waiting for the token.
+				if err := ctx.Err(); err != nil {
+					results <- hedgeResult{index: index, err: err}
+					return
+				}
+			}
+
+			begin := time.Now()
+			response, err := c.next.Do(request.Clone(ctx))
+			results <- hedgeResult{index: index, response: response, err: err, latency: time.Since(begin)}
+		}()
+	}
+
+	send(false)
+	timer := time.NewTimer(c.latencies.Delay())
+	defer timer.Stop()
+
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if pending == 1 && len(cancels) == 1 {
+				send(true)
+				pending++
+				if c.hedged != nil {
+					c.hedged.Inc()
+				}
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil && pending > 0 {
+				// the other request may still succeed.
+				cancels[result.index]()
+				continue
+			}
+
+			c.cancelPending(results, cancels, result.index, pending)
+			if result.err != nil {
+				cancels[result.index]()
+				return nil, result.err
+			}
+
+			c.latencies.Observe(result.latency)
+			// the request's context must outlive the response, which is read after it is returned.
+			if result.response.Body == nil {
+				cancels[result.index]()
+				return result.response, nil
+			}
+			result.response.Body = cancelOnClose{ReadCloser: result.response.Body, cancel: cancels[result.index]}
+			return result.response, nil
+		}
+	}
+}
+
+// cancelPending cancels the requests that haven't completed, discarding their responses once they do.
+func (c hedgingClient) cancelPending(results <-chan hedgeResult, cancels []context.CancelFunc, winner int, pending int) {
+	for i, cancel := range cancels {
+		if i != winner {
+			cancel()
+		}
+	}
+
+	if pending == 0 {
+		return
+	}
+
+	if c.cancelled != nil {
+		c.cancelled.Add(float64(pending))
+	}
+
+	go func() {
+		for i := 0; i < pending; i++ {
+			if result := <-results; result.response != nil && result.response.Body != nil {
+				result.response.Body.Close()
+			}
+		}
+	}()
+}
+
+// cancelOnClose cancels a request's context once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
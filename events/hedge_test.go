@@ -0,0 +1,245 @@
+package events
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type clientFunc func(*http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestLatencyTracker(t *testing.T) {
+	assert := assert.New(t)
+	tracker := newLatencyTracker(HedgingConfig{
+		Percentile:   0.9,
+		InitialDelay: time.Minute,
+		MinDelay:     5 * time.Millisecond,
+		Window:       20,
+		MinSamples:   10,
+	})
+
+	for i := 1; i < 10; i++ {
+		tracker.Observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(time.Minute, tracker.Delay())
+
+	tracker.Observe(10 * time.Millisecond)
+	assert.Equal(9*time.Millisecond, tracker.Delay())
+
+	// the oldest latencies are forgotten once the window is full.
+	for i := 0; i < 2*hedgeRecomputeInterval; i++ {
+		tracker.Observe(time.Millisecond)
+	}
+	assert.Equal(5*time.Millisecond, tracker.Delay())
+}
+
+func TestNewLatencyTrackerDefaults(t *testing.T) {
+	assert := assert.New(t)
+	tracker := newLatencyTracker(HedgingConfig{Percentile: 2, MinSamples: 5000})
+	assert.Equal(defaultHedgePercentile, tracker.percentile)
+	assert.Equal(defaultHedgeMinDelay, tracker.minDelay)
+	assert.Equal(defaultHedgeInitialDelay, tracker.Delay())
+	assert.Len(tracker.samples, defaultHedgeWindow)
+	assert.Equal(defaultHedgeWindow, tracker.minSamples)
+}
+
+func TestHedgingClient(t *testing.T) {
+	tests := []struct {
+		description       string
+		delays            []time.Duration
+		errs              []error
+		expectedBody      string
+		expectedErr       bool
+		expectedCalls     int32
+		expectedHedged    float64
+		expectedCancelled float64
+	}{
+		{
+			description:   "Fast",
+			delays:        []time.Duration{0, 0},
+			errs:          []error{nil, nil},
+			expectedBody:  "0",
+			expectedCalls: 1,
+		},
+		{
+			description:       "Hedge wins",
+			delays:            []time.Duration{time.Minute, 0},
+			errs:              []error{nil, nil},
+			expectedBody:      "1",
+			expectedCalls:     2,
+			expectedHedged:    1,
+			expectedCancelled: 1,
+		},
+		{
+			description:       "Original wins",
+			delays:            []time.Duration{50 * time.Millisecond, time.Minute},
+			errs:              []error{nil, nil},
+			expectedBody:      "0",
+			expectedCalls:     2,
+			expectedHedged:    1,
+			expectedCancelled: 1,
+		},
+		{
+			description:    "Original fails after hedge",
+			delays:         []time.Duration{50 * time.Millisecond, 100 * time.Millisecond},
+			errs:           []error{errors.New("test error"), nil},
+			expectedBody:   "1",
+			expectedCalls:  2,
+			expectedHedged: 1,
+		},
+		{
+			description:   "Fails",
+			delays:        []time.Duration{0, 0},
+			errs:          []error{errors.New("test error"), nil},
+			expectedErr:   true,
+			expectedCalls: 1,
+		},
+		{
+			description:    "Both fail",
+			delays:         []time.Duration{50 * time.Millisecond, 0},
+			errs:           []error{errors.New("test error"), errors.New("test error")},
+			expectedErr:    true,
+			expectedCalls:  2,
+			expectedHedged: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var calls int32
+			next := clientFunc(func(request *http.Request) (*http.Response, error) {
+				i := atomic.AddInt32(&calls, 1) - 1
+				select {
+				case <-time.After(tc.delays[i]):
+				case <-request.Context().Done():
+					return nil, request.Context().Err()
+				}
+
+				if tc.errs[i] != nil {
+					return nil, tc.errs[i]
+				}
+
+				response := httptest.NewRecorder()
+				response.WriteString(strconv.Itoa(int(i)))
+				return response.Result(), nil
+			})
+
+			hedged := prometheus.NewCounter(prometheus.CounterOpts{Name: "testHedged"})
+			cancelled := prometheus.NewCounter(prometheus.CounterOpts{Name: "testCancelled"})
+			client := hedgingClient{
+				next:      next,
+				latencies: newLatencyTracker(HedgingConfig{InitialDelay: 20 * time.Millisecond}),
+				hedged:    hedged,
+				cancelled: cancelled,
+			}
+
+			response, err := client.Do(httptest.NewRequest(http.MethodGet, "/", nil))
+			if tc.expectedErr {
+				assert.NotNil(err)
+				assert.Nil(response)
+			} else if assert.Nil(err) && assert.NotNil(response) {
+				body, err := io.ReadAll(response.Body)
+				assert.Nil(err)
+				assert.Nil(response.Body.Close())
+				assert.Equal(tc.expectedBody, string(body))
+			}
+
+			assert.Equal(tc.expectedCalls, atomic.LoadInt32(&calls))
+			assert.Equal(tc.expectedHedged, testutil.ToFloat64(hedged))
+			assert.Equal(tc.expectedCancelled, testutil.ToFloat64(cancelled))
+		})
+	}
+}
+
+// testLimiter counts the tokens taken, blocking until it is released.
+type testLimiter struct {
+	taken   int32
+	release chan struct{}
+}
+
+func (l *testLimiter) Take() time.Time {
+	atomic.AddInt32(&l.taken, 1)
+	<-l.release
+	return time.Now()
+}
+
+func TestHedgingClientRateLimit(t *testing.T) {
+	tests := []struct {
+		description   string
+		released      bool
+		expectedBody  string
+		expectedCalls int32
+	}{
+		{
+			description:   "Token taken",
+			released:      true,
+			expectedBody:  "1",
+			expectedCalls: 2,
+		},
+		{
+			description:   "Waiting for token",
+			expectedBody:  "0",
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var calls int32
+			next := clientFunc(func(request *http.Request) (*http.Response, error) {
+				i := atomic.AddInt32(&calls, 1) - 1
+				if i == 0 {
+					select {
+					case <-time.After(100 * time.Millisecond):
+					case <-request.Context().Done():
+						return nil, request.Context().Err()
+					}
+				}
+
+				response := httptest.NewRecorder()
+				response.WriteString(strconv.Itoa(int(i)))
+				return response.Result(), nil
+			})
+
+			limiter := &testLimiter{release: make(chan struct{})}
+			if tc.released {
+				close(limiter.release)
+			} else {
+				defer close(limiter.release)
+			}
+
+			client := hedgingClient{
+				next:      next,
+				latencies: newLatencyTracker(HedgingConfig{InitialDelay: 20 * time.Millisecond}),
+				limiter:   limiter,
+			}
+
+			response, err := client.Do(httptest.NewRequest(http.MethodGet, "/", nil))
+			if assert.Nil(err) && assert.NotNil(response) {
+				body, err := io.ReadAll(response.Body)
+				assert.Nil(err)
+				assert.Nil(response.Body.Close())
+				assert.Equal(tc.expectedBody, string(body))
+			}
+
+			// only the hedged request takes a token.
+			assert.Equal(int32(1), atomic.LoadInt32(&limiter.taken))
+			assert.Equal(tc.expectedCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
@@ -19,6 +19,7 @@ package events
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/glaukos/compression"
 	"github.com/xmidt-org/touchstone"
 	"go.uber.org/fx"
 )
@@ -35,6 +36,17 @@ type Measures struct {
 	CircuitBreakerStatus        *prometheus.GaugeVec   `name:"circuit_breaker_status"`
 	CircuitBreakerRejectedCount *prometheus.CounterVec `name:"circuit_breaker_rejected_count"`
 	CircuitBreakerOpenDuration  prometheus.ObserverVec `name:"circuit_breaker_open_duration"`
+	ParserLookupFailureCount    *prometheus.CounterVec `name:"codex_parser_lookup_failures_count" optional:"true"`
+	CacheLookupCount            *prometheus.CounterVec `name:"codex_cache_lookups_count" optional:"true"`
+	RequestAttemptCount         *prometheus.CounterVec `name:"codex_request_attempts_count"`
+	InFlightRequests            prometheus.Gauge       `name:"codex_in_flight_requests"`
+	EffectiveRateLimit          prometheus.Gauge       `name:"codex_effective_rate_limit" optional:"true"`
+	HedgedRequestCount          prometheus.Counter     `name:"codex_hedged_requests_count" optional:"true"`
+	CancelledRequestCount       prometheus.Counter     `name:"codex_cancelled_requests_count" optional:"true"`
+	BackendRequestCount         *prometheus.CounterVec `name:"codex_backend_requests_count" optional:"true"`
+	StreamDiscardedCount        *prometheus.CounterVec `name:"codex_streamed_events_discarded_count" optional:"true"`
+	TruncatedResponseCount      *prometheus.CounterVec `name:"codex_truncated_responses_count" optional:"true"`
+	ResponseBytesCount          *prometheus.CounterVec `name:"codex_response_bytes_count" optional:"true"`
 }
 
 // ProvideMetrics builds the queue-related metrics and makes them available to the container.
@@ -70,5 +82,208 @@ func ProvideMetrics() fx.Option {
 			},
 			circuitBreakerLabel,
 		),
+		touchstone.CounterVec(
+			prometheus.CounterOpts{
+				Name: "codex_request_attempts_count",
+				Help: "Number of attempts of codex requests, including retries, labeled by the attempt number and status code",
+			},
+			attemptLabel,
+			responseCodeLabel,
+		),
+		touchstone.Gauge(
+			prometheus.GaugeOpts{
+				Name: "codex_in_flight_requests",
+				Help: "The number of requests to codex currently in flight",
+			},
+		),
+		fx.Provide(
+			fx.Annotated{
+				Name: "codex_parser_lookup_failures_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (*prometheus.CounterVec, error) {
+					if !config.ReportParserLookupFailures {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "codex_parser_lookup_failures_count",
+							Help: "Number of codex lookups rejected by the circuit breaker or failed, labeled by the parser that triggered them",
+						},
+						parserLabel, lookupResultLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_effective_rate_limit",
+				Target: func(f *touchstone.Factory, config CodexConfig) (prometheus.Gauge, error) {
+					if !config.RateLimit.Adaptive.Enabled {
+						return nil, nil
+					}
+
+					return f.NewGauge(
+						prometheus.GaugeOpts{
+							Name: "codex_effective_rate_limit",
+							Help: "The current rate limit of codex requests in requests per second, as adapted to codex's latency and failures",
+						},
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_hedged_requests_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (prometheus.Counter, error) {
+					if !config.Hedging.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounter(
+						prometheus.CounterOpts{
+							Name: "codex_hedged_requests_count",
+							Help: "Number of second requests sent to codex because the first was slower than usual",
+						},
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_cancelled_requests_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (prometheus.Counter, error) {
+					if !config.Hedging.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounter(
+						prometheus.CounterOpts{
+							Name: "codex_cancelled_requests_count",
+							Help: "Number of hedged codex requests cancelled because the other request completed first",
+						},
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_backend_requests_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (*prometheus.CounterVec, error) {
+					if len(config.Failover.Secondaries) == 0 {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "codex_backend_requests_count",
+							Help: "Number of requests sent to each codex when failing over, labeled by the codex address and whether they succeeded, failed, or were rejected",
+						},
+						backendLabel, lookupResultLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_streamed_events_discarded_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (*prometheus.CounterVec, error) {
+					if !config.Streaming.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "codex_streamed_events_discarded_count",
+							Help: "Number of events in codex's responses discarded while streaming, labeled by whether their destination, boot-time, or birthdate wasn't needed",
+						},
+						discardReasonLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_truncated_responses_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (*prometheus.CounterVec, error) {
+					if config.Limits.MaxBytes <= 0 && config.Limits.MaxEvents <= 0 {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "codex_truncated_responses_count",
+							Help: "Number of codex responses truncated for exceeding the maximum bytes or events, labeled by the limit exceeded",
+						},
+						truncateReasonLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_response_bytes_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (*prometheus.CounterVec, error) {
+					if !config.Compression.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "codex_response_bytes_count",
+							Help: "Number of bytes of codex's responses read, labeled by content encoding and whether they were compressed or uncompressed",
+						},
+						compression.EncodingLabel, compression.CompressionLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_auth_token_age_seconds",
+				Target: func(f *touchstone.Factory, config CodexConfig) (prometheus.Gauge, error) {
+					if !config.Auth.Cache.Enabled {
+						return nil, nil
+					}
+
+					return f.NewGauge(
+						prometheus.GaugeOpts{
+							Name: "codex_auth_token_age_seconds",
+							Help: "The time since the cached codex auth token was acquired in s, as of the latest request",
+						},
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_auth_token_refresh_errors_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (prometheus.Counter, error) {
+					if !config.Auth.Cache.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounter(
+						prometheus.CounterOpts{
+							Name: "codex_auth_token_refresh_errors_count",
+							Help: "Number of failures to refresh the cached codex auth token",
+						},
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_cache_lookups_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (*prometheus.CounterVec, error) {
+					if config.Cache.TTL <= 0 {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "codex_cache_lookups_count",
+							Help: "Number of lookups of a device's events in the codex cache, labeled by whether they were a hit or miss",
+						},
+						cacheResultLabel,
+					)
+				},
+			},
+			fx.Annotated{
+				Name: "codex_recent_events_lookups_count",
+				Target: func(f *touchstone.Factory, config CodexConfig) (*prometheus.CounterVec, error) {
+					if !config.RecentEvents.Enabled {
+						return nil, nil
+					}
+
+					return f.NewCounterVec(
+						prometheus.CounterOpts{
+							Name: "codex_recent_events_lookups_count",
+							Help: "Number of lookups of a device's recent events in redis, labeled by whether they were a hit, miss, incomplete, or error",
+						},
+						cacheResultLabel,
+					)
+				},
+			},
+		),
 	)
 }
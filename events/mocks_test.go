@@ -1,8 +1,10 @@
 package events
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -26,3 +28,17 @@ func (m *mockClient) Do(req *http.Request) (*http.Response, error) {
 	}
 	return nil, args.Error(1)
 }
+
+type mockRecentEventsClient struct {
+	mock.Mock
+}
+
+func (m *mockRecentEventsClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	callArgs := m.Called(keys, args)
+	return callArgs.Get(0).(*redis.Cmd)
+}
+
+func (m *mockRecentEventsClient) LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	callArgs := m.Called(key, start, stop)
+	return callArgs.Get(0).(*redis.StringSliceCmd)
+}
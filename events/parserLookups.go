@@ -0,0 +1,108 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	parserLabel       = "parser_type"
+	lookupResultLabel = "result"
+
+	rejectedLookup = "rejected"
+	failedLookup   = "failed"
+
+	unknownParser = "unknown"
+	otherParser   = "other"
+
+	// maxParserLabels bounds the number of parser label values.  Lookups from parsers beyond this
+	// are attributed to "other".
+	maxParserLabels = 20
+)
+
+// ParserClient gets events from codex on behalf of a parser, attributing the lookups that are rejected
+// by the circuit breaker or that fail to the parser.
+type ParserClient struct {
+	client *CodexClient
+	parser string
+}
+
+// ForParser returns a ParserClient that attributes lookups to the parser given.
+func (c *CodexClient) ForParser(parser string) EventSource {
+	return &ParserClient{
+		client: c,
+		parser: parser,
+	}
+}
+
+// GetEvents queries codex for events related to a device.
+func (p *ParserClient) GetEvents(ctx context.Context, device string) []interpreter.Event {
+	return p.client.getEvents(ctx, device, p.parser)
+}
+
+// parserLabels guards the cardinality of the parser label.
+type parserLabels struct {
+	lock   sync.Mutex
+	labels map[string]bool
+}
+
+// label returns the label value to use for the parser.
+func (p *parserLabels) label(parser string) string {
+	if len(parser) == 0 {
+		return unknownParser
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.labels[parser] {
+		return parser
+	}
+
+	if len(p.labels) >= maxParserLabels {
+		return otherParser
+	}
+
+	if p.labels == nil {
+		p.labels = make(map[string]bool)
+	}
+
+	p.labels[parser] = true
+	return parser
+}
+
+// addParserLookupFailure counts a lookup that was rejected by the circuit breaker or failed, labeled
+// by the parser that triggered it.
+func (c *CodexClient) addParserLookupFailure(parser string, err error) {
+	if c.Metrics.ParserLookupFailureCount == nil {
+		return
+	}
+
+	result := failedLookup
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		result = rejectedLookup
+	}
+
+	c.Metrics.ParserLookupFailureCount.With(prometheus.Labels{parserLabel: c.parserLabels.label(parser), lookupResultLabel: result}).Add(1.0)
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+)
+
+func TestParserLookupFailures(t *testing.T) {
+	assert := assert.New(t)
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	client := new(mockClient)
+	client.On("Do", mock.Anything).Return(httptest.NewRecorder().Result(), errors.New("test error")) // nolint:bodyclose
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "testLookupFailures",
+		Help: "testLookupFailures",
+	}, []string{parserLabel, lookupResultLabel})
+
+	c := &CodexClient{
+		Logger: zap.NewNop(),
+		Client: client,
+		// the first failure opens the circuit breaker, so the following lookups are rejected.
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:        "test circuit breaker",
+			ReadyToTrip: func(gobreaker.Counts) bool { return true },
+		}),
+		Auth:        auth,
+		RateLimiter: ratelimit.NewUnlimited(),
+		Metrics: Measures{
+			CircuitBreakerRejectedCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "testRejectedCount",
+				Help: "testRejectedCount",
+			}, []string{circuitBreakerLabel}),
+			ParserLookupFailureCount: counter,
+		},
+	}
+
+	assert.Empty(c.ForParser("reboot").GetEvents(context.Background(), "some-deviceID"))
+	assert.Empty(c.ForParser("session").GetEvents(context.Background(), "some-deviceID"))
+	assert.Empty(c.ForParser("session").GetEvents(context.Background(), "some-deviceID"))
+	assert.Empty(c.GetEvents(context.Background(), "some-deviceID"))
+
+	client.AssertNumberOfCalls(t, "Do", 1)
+	assert.Equal(1.0, testutil.ToFloat64(counter.WithLabelValues("reboot", failedLookup)))
+	assert.Equal(0.0, testutil.ToFloat64(counter.WithLabelValues("reboot", rejectedLookup)))
+	assert.Equal(0.0, testutil.ToFloat64(counter.WithLabelValues("session", failedLookup)))
+	assert.Equal(2.0, testutil.ToFloat64(counter.WithLabelValues("session", rejectedLookup)))
+	assert.Equal(1.0, testutil.ToFloat64(counter.WithLabelValues(unknownParser, rejectedLookup)))
+}
+
+func TestParserLabels(t *testing.T) {
+	assert := assert.New(t)
+	var labels parserLabels
+	assert.Equal(unknownParser, labels.label(""))
+	for i := 0; i < maxParserLabels; i++ {
+		parser := fmt.Sprintf("parser-%d", i)
+		assert.Equal(parser, labels.label(parser))
+	}
+
+	assert.Equal(otherParser, labels.label("one-too-many"))
+	assert.Equal("parser-0", labels.label("parser-0"))
+}
@@ -18,6 +18,7 @@
 package events
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -25,10 +26,13 @@ import (
 	"github.com/sony/gobreaker"
 	"github.com/xmidt-org/arrange"
 	"github.com/xmidt-org/bascule/acquire"
+	"github.com/xmidt-org/candlelight"
+	"github.com/xmidt-org/httpaux"
 	"github.com/xmidt-org/httpaux/retry"
 	"go.uber.org/fx"
 	"go.uber.org/ratelimit"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 // CodexConfig determines the auth and address for connecting to the codex cluster.
@@ -38,6 +42,56 @@ type CodexConfig struct {
 	MaxRetryCount  int
 	RateLimit      RateLimitConfig
 	CircuitBreaker CircuitBreakerConfig
+	LogSampleRate  float64
+	Signing        SigningConfig
+
+	// ReportParserLookupFailures enables counting the codex lookups that are rejected by the circuit
+	// breaker or fail, labeled by the parser that triggered them.
+	ReportParserLookupFailures bool
+
+	// Cache configures caching the events codex returns for a device.
+	Cache CacheConfig
+
+	// BatchConcurrency is the maximum number of requests in flight when getting the events of a batch of
+	// devices.  Defaults to 10.
+	BatchConcurrency int
+
+	// Retry configures retrying failed requests, taking precedence over MaxRetryCount.
+	Retry RetryConfig
+
+	// RecentEvents configures keeping the recent history of devices in redis to look up before codex.
+	RecentEvents RecentEventsConfig
+
+	// Transport configures the connections made to codex.
+	Transport TransportConfig
+
+	// Hedging configures sending a second request when codex is slower to respond than usual.
+	Hedging HedgingConfig
+
+	// Failover configures failing over to secondary codex instances while the primary at Address is failing.
+	Failover FailoverConfig
+
+	// TimeRange configures only getting the events of a device received within a window, rather than its entire
+	// history.
+	TimeRange TimeRangeConfig
+
+	// Streaming configures decoding codex's responses one event at a time, discarding the events that aren't needed.
+	Streaming StreamingConfig
+
+	// Limits configures truncating codex's responses that are too large, rather than reading them entirely into
+	// memory.
+	Limits ResponseLimitsConfig
+
+	// Compression configures requesting compressed responses from codex.
+	Compression CompressionConfig
+}
+
+// RecentEventsIn provides everything needed to create the recent event store.
+type RecentEventsIn struct {
+	fx.In
+	Config  CodexConfig
+	Lookups *prometheus.CounterVec `name:"codex_recent_events_lookups_count" optional:"true"`
+	Logger  *zap.Logger
 }
 
 // CircuitBreakerConfig deals with configuration for the circuit breaker.
@@ -52,12 +106,19 @@ type CircuitBreakerConfig struct {
 type RateLimitConfig struct {
 	Requests int
 	Tick     time.Duration
+
+	// Adaptive configures lowering the rate when codex is slow or failing, treating Requests as the maximum.
+	Adaptive AdaptiveRateLimitConfig
 }
 
 // AuthAcquirerConfig is the auth config for the client making requests to get a device's history of events.
 type AuthAcquirerConfig struct {
 	JWT   acquire.RemoteBearerTokenAcquirerOptions
 	Basic string
+
+	// Cache configures caching the token, refreshing it in the background rather than acquiring it for every
+	// request.
+	Cache TokenCacheConfig
 }
 
 // Provide bundles everything needed for setting up all of the event objects
@@ -67,18 +128,48 @@ func Provide() fx.Option {
 		ProvideMetrics(),
 		fx.Provide(
 			arrange.UnmarshalKey("codex", CodexConfig{}),
-			determineCodexTokenAcquirer,
+			arrange.UnmarshalKey("eventSource", EventSourceConfig{}),
+			provideCodexAuth,
 			createCircuitBreaker,
 			onStateChanged,
-			createCodexClient,
+			newRequestSigner,
+			func(in RecentEventsIn) *RecentEventStore {
+				return NewRecentEventStore(in.Config.RecentEvents, in.Lookups, in.Logger)
+			},
+			createEventSource,
 		),
 	)
 
 }
 
-func createCodexClient(config CodexConfig, cb *gobreaker.CircuitBreaker, codexAuth acquire.Acquirer, measures Measures, logger *zap.Logger) *CodexClient {
+func createCodexClient(config CodexConfig, cb *gobreaker.CircuitBreaker, onStateChange func(string, gobreaker.State, gobreaker.State), codexAuth acquire.Acquirer, signer *requestSigner, recent *RecentEventStore, measures Measures, tracing candlelight.Tracing, logger *zap.Logger) (*CodexClient, error) {
+	transport, err := newTransport(config.Transport)
+	if err != nil {
+		return nil, err
+	}
+
 	var limiter ratelimit.Limiter
-	if config.RateLimit.Requests <= 0 {
+	var next httpaux.Client = &http.Client{Transport: transport}
+
+	// requests are signed as each attempt is sent, underneath the retries and hedging.
+	if signer != nil {
+		next = signingClient{next: next, signer: signer}
+	}
+
+	if config.Compression.Enabled {
+		next = compressionClient{next: next, bytes: measures.ResponseBytesCount}
+	}
+
+	next = inFlightClient{next: next, inFlight: measures.InFlightRequests}
+	if config.RateLimit.Adaptive.Enabled {
+		adaptive, err := newAdaptiveLimiter(config.RateLimit, measures.EffectiveRateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		limiter = adaptive
+		next = adaptiveClient{next: next, limiter: adaptive}
+	} else if config.RateLimit.Requests <= 0 {
 		limiter = ratelimit.NewUnlimited()
 	} else {
 		if config.RateLimit.Tick <= 0 {
@@ -87,26 +178,64 @@ func createCodexClient(config CodexConfig, cb *gobreaker.CircuitBreaker, codexAu
 
 		limiter = ratelimit.New(config.RateLimit.Requests, ratelimit.Per(config.RateLimit.Tick), ratelimit.WithoutSlack)
 	}
-	retryConfig := retry.Config{
-		Retries:  config.MaxRetryCount,
-		Interval: time.Second * 30,
+
+	if config.Hedging.Enabled {
+		next = hedgingClient{
+			next:      next,
+			latencies: newLatencyTracker(config.Hedging),
+			hedged:    measures.HedgedRequestCount,
+			cancelled: measures.CancelledRequestCount,
+			limiter:   limiter,
+		}
 	}
 
-	client := retry.New(retryConfig, new(http.Client))
+	client := retry.New(newRetryConfig(config), tracingClient{
+		next: attemptClient{next: next, attempts: measures.RequestAttemptCount},
+	})
 
 	if measures.CircuitBreakerStatus != nil {
 		measures.CircuitBreakerStatus.With(prometheus.Labels{circuitBreakerLabel: cb.Name()}).Set(0.0)
 	}
 
-	return &CodexClient{
-		Address:        config.Address,
-		Auth:           codexAuth,
-		Client:         client,
-		Logger:         logger,
-		RateLimiter:    limiter,
-		Metrics:        measures,
-		CircuitBreaker: cb,
+	stream, err := newEventStream(config.Streaming, config.Limits, measures.StreamDiscardedCount, measures.TruncatedResponseCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// each secondary has its own circuit breaker, so that the primary failing doesn't reject its requests.
+	var backends *codexBackends
+	if len(config.Failover.Secondaries) > 0 {
+		secondaries := make([]codexBackend, 0, len(config.Failover.Secondaries))
+		for _, address := range config.Failover.Secondaries {
+			secondaryCB := newCircuitBreaker(fmt.Sprintf("Codex Circuit Breaker (%s)", address), config.CircuitBreaker, onStateChange)
+			if measures.CircuitBreakerStatus != nil {
+				measures.CircuitBreakerStatus.With(prometheus.Labels{circuitBreakerLabel: secondaryCB.Name()}).Set(0.0)
+			}
+			secondaries = append(secondaries, codexBackend{address: address, circuitBreaker: secondaryCB})
+		}
+
+		backends = newCodexBackends(config.Failover, codexBackend{address: config.Address, circuitBreaker: cb}, secondaries, measures.BackendRequestCount, logger)
 	}
+
+	return &CodexClient{
+		Address:          config.Address,
+		Auth:             codexAuth,
+		Client:           client,
+		Logger:           logger,
+		RateLimiter:      limiter,
+		Metrics:          measures,
+		CircuitBreaker:   cb,
+		signer:           signer,
+		LogSampleRate:    config.LogSampleRate,
+		Cache:            newEventCache(config.Cache, measures.CacheLookupCount),
+		Recent:           recent,
+		BatchConcurrency: config.BatchConcurrency,
+		Tracing:          tracing,
+		Backends:         backends,
+		TimeRange:        newTimeRange(config.TimeRange),
+		Stream:           stream,
+		truncatedLogs:    rate.Sometimes{Interval: truncatedLogInterval},
+	}, nil
 }
 
 func determineCodexTokenAcquirer(logger *zap.Logger, config CodexConfig) (acquire.Acquirer, error) {
@@ -128,14 +257,16 @@ func determineCodexTokenAcquirer(logger *zap.Logger, config CodexConfig) (acquir
 }
 
 func createCircuitBreaker(config CodexConfig, onStateChange func(string, gobreaker.State, gobreaker.State)) *gobreaker.CircuitBreaker {
-	c := config.CircuitBreaker
+	return newCircuitBreaker("Codex Circuit Breaker", config.CircuitBreaker, onStateChange)
+}
 
+func newCircuitBreaker(name string, c CircuitBreakerConfig, onStateChange func(string, gobreaker.State, gobreaker.State)) *gobreaker.CircuitBreaker {
 	if c.ConsecutiveFailuresAllowed == 0 {
 		c.ConsecutiveFailuresAllowed = 1
 	}
 
 	settings := gobreaker.Settings{
-		Name:        "Codex Circuit Breaker",
+		Name:        name,
 		MaxRequests: c.MaxRequests,
 		Interval:    c.Interval,
 		Timeout:     c.Timeout,
@@ -11,6 +11,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xmidt-org/bascule/acquire"
+	"github.com/xmidt-org/candlelight"
 )
 
 func TestCodexTokenAcquirer(t *testing.T) {
@@ -136,6 +137,9 @@ func TestCreateCodexClient(t *testing.T) {
 	tests := []struct {
 		description string
 		config      CodexConfig
+		expectedErr error
+
+		expectedBackends []string
 	}{
 		{
 			description: "0 rate limit req",
@@ -174,6 +178,52 @@ func TestCreateCodexClient(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "invalid tls",
+			config: CodexConfig{
+				Address: "test",
+				Transport: TransportConfig{
+					TLS: TransportTLSConfig{CAFile: "does-not-exist.pem"},
+				},
+			},
+			expectedErr: errInvalidCodexTLS,
+		},
+		{
+			description: "adaptive rate limit without maximum",
+			config: CodexConfig{
+				Address: "test",
+				RateLimit: RateLimitConfig{
+					Adaptive: AdaptiveRateLimitConfig{Enabled: true},
+				},
+			},
+			expectedErr: errInvalidAdaptiveRateLimit,
+		},
+		{
+			description: "adaptive rate limit",
+			config: CodexConfig{
+				Address: "test",
+				RateLimit: RateLimitConfig{
+					Requests: 10,
+					Adaptive: AdaptiveRateLimitConfig{Enabled: true},
+				},
+			},
+		},
+		{
+			description: "invalid streaming destination",
+			config: CodexConfig{
+				Address:   "test",
+				Streaming: StreamingConfig{Enabled: true, Destinations: []string{"(online"}},
+			},
+			expectedErr: errInvalidStreamDestination,
+		},
+		{
+			description: "failover",
+			config: CodexConfig{
+				Address:  "test",
+				Failover: FailoverConfig{Secondaries: []string{"secondary-1", "secondary-2"}},
+			},
+			expectedBackends: []string{"test", "secondary-1", "secondary-2"},
+		},
 	}
 
 	for _, tc := range tests {
@@ -188,13 +238,37 @@ func TestCreateCodexClient(t *testing.T) {
 			auth := &acquire.DefaultAcquirer{}
 			logger := zap.NewNop()
 			cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test"})
-			client := createCodexClient(tc.config, cb, auth, m, logger)
-			assert.NotNil(client)
+			client, err := createCodexClient(tc.config, cb, nil, auth, nil, nil, m, candlelight.Tracing{}, logger)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(client)
+				return
+			}
+
+			assert.Nil(err)
+			if !assert.NotNil(client) {
+				return
+			}
 			assert.Equal(tc.config.Address, client.Address)
 			assert.Equal(auth, client.Auth)
 			assert.Equal(m, client.Metrics)
 			assert.Equal(cb, client.CircuitBreaker)
-			assert.Equal(0.0, testutil.ToFloat64(m.CircuitBreakerStatus))
+			if len(tc.expectedBackends) == 0 {
+				assert.Nil(client.Backends)
+				assert.Equal(0.0, testutil.ToFloat64(m.CircuitBreakerStatus))
+				return
+			}
+
+			if !assert.NotNil(client.Backends) {
+				return
+			}
+			var addresses []string
+			for _, backend := range client.Backends.backends {
+				addresses = append(addresses, backend.address)
+			}
+			assert.Equal(tc.expectedBackends, addresses)
+			assert.Equal(cb, client.Backends.backends[0].circuitBreaker)
+			assert.Equal(len(tc.expectedBackends), testutil.CollectAndCount(m.CircuitBreakerStatus))
 		})
 	}
 }
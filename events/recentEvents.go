@@ -0,0 +1,222 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/xmidt-org/glaukos/deviceid"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/zap"
+)
+
+const (
+	recentHit        = "hit"
+	recentMiss       = "miss"
+	recentIncomplete = "incomplete"
+	recentError      = "error"
+
+	defaultRecentKeyPrefix  = "glaukos:events:"
+	defaultRecentTimeout    = time.Second
+	defaultRecentTTL        = 24 * time.Hour
+	defaultRecentMaxEvents  = 100
+	defaultRecentBootCycles = 2
+)
+
+// addRecentEventScript pushes the event onto the device's list, trimming the list to its maximum length and
+// resetting its expiration, all in one round trip.
+const addRecentEventScript = `redis.call('LPUSH', KEYS[1], ARGV[1])
+redis.call('LTRIM', KEYS[1], 0, tonumber(ARGV[2]) - 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1`
+
+// RecentEventsConfig configures keeping the recent history of each device in redis, recorded from the events
+// glaukos receives, so that parsers can skip codex when the history is complete.
+type RecentEventsConfig struct {
+	// Enabled turns on recording incoming events and looking them up before codex.
+	Enabled bool
+
+	// Address is the host:port of the redis server.
+	Address string
+
+	// Username and Password authenticate with the redis server, if set.
+	Username string
+	Password string
+
+	// DB is the redis database used.  Defaults to 0.
+	DB int
+
+	// KeyPrefix is prepended to the device ids used as keys in redis.  Defaults to "glaukos:events:".
+	KeyPrefix string
+
+	// Timeout is the maximum time to connect to, read from, or write to redis.  Defaults to 1s.
+	Timeout time.Duration
+
+	// TTL is how long a device's history is kept after its last event.  Defaults to 24h.
+	TTL time.Duration
+
+	// MaxEvents is the maximum number of events kept for each device, after which the oldest events are
+	// dropped.  Defaults to 100.
+	MaxEvents int
+
+	// BootCycles is the number of different boot-times a device's history must include to be used instead of
+	// codex, so that the history includes the device's previous boot cycle.  Defaults to 2.
+	BootCycles int
+}
+
+type recentEventsClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+}
+
+// RecentEventStore keeps the recent events of each device in redis.
+type RecentEventStore struct {
+	client     recentEventsClient
+	prefix     string
+	ttl        time.Duration
+	maxEvents  int
+	bootCycles int
+	lookups    *prometheus.CounterVec
+	logger     *zap.Logger
+}
+
+// NewRecentEventStore creates a RecentEventStore, returning nil if it is disabled.  Lookups are counted by result
+// in lookups, if it isn't nil.
+func NewRecentEventStore(config RecentEventsConfig, lookups *prometheus.CounterVec, logger *zap.Logger) *RecentEventStore {
+	if !config.Enabled {
+		return nil
+	}
+
+	if len(config.KeyPrefix) == 0 {
+		config.KeyPrefix = defaultRecentKeyPrefix
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = defaultRecentTimeout
+	}
+
+	if config.TTL <= 0 {
+		config.TTL = defaultRecentTTL
+	}
+
+	if config.MaxEvents <= 0 {
+		config.MaxEvents = defaultRecentMaxEvents
+	}
+
+	if config.BootCycles <= 0 {
+		config.BootCycles = defaultRecentBootCycles
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:         config.Address,
+		Username:     config.Username,
+		Password:     config.Password,
+		DB:           config.DB,
+		DialTimeout:  config.Timeout,
+		ReadTimeout:  config.Timeout,
+		WriteTimeout: config.Timeout,
+	})
+
+	return &RecentEventStore{
+		client:     client,
+		prefix:     config.KeyPrefix,
+		ttl:        config.TTL,
+		maxEvents:  config.MaxEvents,
+		bootCycles: config.BootCycles,
+		lookups:    lookups,
+		logger:     logger,
+	}
+}
+
+// Add records the event in its device's history.  Events without a device id are ignored.
+func (s *RecentEventStore) Add(ctx context.Context, e interpreter.Event) {
+	deviceID, err := deviceid.FromEvent(e)
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		s.logger.Error("failed to encode recent event", zap.Error(err), zap.String("event id", e.TransactionUUID))
+		return
+	}
+
+	err = s.client.Eval(ctx, addRecentEventScript, []string{s.key(deviceID)}, data, s.maxEvents, s.ttl.Milliseconds()).Err()
+	if err != nil {
+		s.logger.Warn("failed to record recent event", zap.Error(err), zap.String("event id", e.TransactionUUID))
+	}
+}
+
+// get returns the device's recent events, newest first, returning false if they don't span enough boot cycles
+// to be used instead of codex.
+func (s *RecentEventStore) get(ctx context.Context, device string) ([]interpreter.Event, bool) {
+	values, err := s.client.LRange(ctx, s.key(device), 0, -1).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		s.logger.Warn("failed to get recent events", zap.Error(err), zap.String("device id", device))
+		s.addLookup(recentError)
+		return nil, false
+	}
+
+	if len(values) == 0 {
+		s.addLookup(recentMiss)
+		return nil, false
+	}
+
+	events := make([]interpreter.Event, 0, len(values))
+	bootTimes := make(map[int64]bool)
+	for _, value := range values {
+		var e interpreter.Event
+		if err := json.Unmarshal([]byte(value), &e); err != nil {
+			s.logger.Warn("failed to decode recent event", zap.Error(err), zap.String("device id", device))
+			s.addLookup(recentError)
+			return nil, false
+		}
+
+		if bootTime, err := e.BootTime(); err == nil && bootTime > 0 {
+			bootTimes[bootTime] = true
+		}
+		events = append(events, e)
+	}
+
+	if len(bootTimes) < s.bootCycles {
+		s.addLookup(recentIncomplete)
+		return nil, false
+	}
+
+	s.addLookup(recentHit)
+	return events, true
+}
+
+func (s *RecentEventStore) key(device string) string {
+	return s.prefix + deviceid.Normalize(device)
+}
+
+func (s *RecentEventStore) addLookup(result string) {
+	if s.lookups != nil {
+		s.lookups.With(prometheus.Labels{cacheResultLabel: result}).Add(1.0)
+	}
+}
@@ -0,0 +1,230 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+)
+
+func newTestRecentEventStore(client recentEventsClient, lookups *prometheus.CounterVec) *RecentEventStore {
+	return &RecentEventStore{
+		client:     client,
+		prefix:     defaultRecentKeyPrefix,
+		ttl:        time.Hour,
+		maxEvents:  10,
+		bootCycles: 2,
+		lookups:    lookups,
+		logger:     zap.NewNop(),
+	}
+}
+
+func TestNewRecentEventStore(t *testing.T) {
+	tests := []struct {
+		description        string
+		config             RecentEventsConfig
+		expectedNil        bool
+		expectedPrefix     string
+		expectedTTL        time.Duration
+		expectedMaxEvents  int
+		expectedBootCycles int
+	}{
+		{
+			description: "Disabled",
+			config:      RecentEventsConfig{Address: "localhost:6379"},
+			expectedNil: true,
+		},
+		{
+			description:        "Defaults",
+			config:             RecentEventsConfig{Enabled: true, Address: "localhost:6379"},
+			expectedPrefix:     defaultRecentKeyPrefix,
+			expectedTTL:        defaultRecentTTL,
+			expectedMaxEvents:  defaultRecentMaxEvents,
+			expectedBootCycles: defaultRecentBootCycles,
+		},
+		{
+			description: "Configured",
+			config: RecentEventsConfig{
+				Enabled:    true,
+				Address:    "localhost:6379",
+				KeyPrefix:  "prefix:",
+				TTL:        time.Hour,
+				MaxEvents:  5,
+				BootCycles: 3,
+			},
+			expectedPrefix:     "prefix:",
+			expectedTTL:        time.Hour,
+			expectedMaxEvents:  5,
+			expectedBootCycles: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			store := NewRecentEventStore(tc.config, nil, nil)
+			if tc.expectedNil {
+				assert.Nil(store)
+				return
+			}
+
+			if !assert.NotNil(store) {
+				return
+			}
+			assert.Equal(tc.expectedPrefix, store.prefix)
+			assert.Equal(tc.expectedTTL, store.ttl)
+			assert.Equal(tc.expectedMaxEvents, store.maxEvents)
+			assert.Equal(tc.expectedBootCycles, store.bootCycles)
+			assert.NotNil(store.logger)
+		})
+	}
+}
+
+func TestRecentEventStoreAdd(t *testing.T) {
+	tests := []struct {
+		description   string
+		event         interpreter.Event
+		evalErr       error
+		expectedCalls int
+	}{
+		{
+			description:   "Success",
+			event:         interpreter.Event{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abc"},
+			expectedCalls: 1,
+		},
+		{
+			description:   "Redis error",
+			event:         interpreter.Event{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abc"},
+			evalErr:       errors.New("test error"),
+			expectedCalls: 1,
+		},
+		{
+			description: "No device id",
+			event:       interpreter.Event{Destination: "some-destination", TransactionUUID: "abc"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockRecentEventsClient)
+			data, err := json.Marshal(tc.event)
+			if !assert.Nil(err) {
+				return
+			}
+
+			client.On("Eval", []string{defaultRecentKeyPrefix + "mac:112233445566"}, []interface{}{data, 10, int64(3600000)}).Return(redis.NewCmdResult(int64(1), tc.evalErr))
+			store := newTestRecentEventStore(client, nil)
+			store.Add(context.Background(), tc.event)
+			client.AssertNumberOfCalls(t, "Eval", tc.expectedCalls)
+		})
+	}
+}
+
+func TestRecentEventStoreGet(t *testing.T) {
+	newEvent := func(bootTime string) string {
+		e := interpreter.Event{
+			Destination: "event:device-status/mac:112233445566/online",
+			Metadata:    map[string]string{interpreter.BootTimeKey: bootTime},
+		}
+		data, _ := json.Marshal(e)
+		return string(data)
+	}
+
+	tests := []struct {
+		description    string
+		values         []string
+		redisErr       error
+		expectedFound  bool
+		expectedLength int
+		expectedResult string
+	}{
+		{
+			description:    "Hit",
+			values:         []string{newEvent("200"), newEvent("200"), newEvent("100")},
+			expectedFound:  true,
+			expectedLength: 3,
+			expectedResult: recentHit,
+		},
+		{
+			description:    "Miss",
+			redisErr:       redis.Nil,
+			expectedResult: recentMiss,
+		},
+		{
+			description:    "Empty",
+			values:         []string{},
+			expectedResult: recentMiss,
+		},
+		{
+			description:    "Incomplete",
+			values:         []string{newEvent("200"), newEvent("200")},
+			expectedResult: recentIncomplete,
+		},
+		{
+			description:    "Redis error",
+			redisErr:       errors.New("test error"),
+			expectedResult: recentError,
+		},
+		{
+			description:    "Decode error",
+			values:         []string{"not json"},
+			expectedResult: recentError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockRecentEventsClient)
+			client.On("LRange", defaultRecentKeyPrefix+"mac:112233445566", int64(0), int64(-1)).Return(redis.NewStringSliceResult(tc.values, tc.redisErr))
+			lookups := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testLookups"}, []string{cacheResultLabel})
+			store := newTestRecentEventStore(client, lookups)
+
+			events, found := store.get(context.Background(), "MAC:112233445566")
+			assert.Equal(tc.expectedFound, found)
+			assert.Len(events, tc.expectedLength)
+			assert.Equal(1.0, testutil.ToFloat64(lookups.With(prometheus.Labels{cacheResultLabel: tc.expectedResult})))
+		})
+	}
+}
+
+func TestGetEventsRecent(t *testing.T) {
+	assert := assert.New(t)
+	e := interpreter.Event{
+		Destination: "event:device-status/mac:112233445566/online",
+		Metadata:    map[string]string{interpreter.BootTimeKey: "200"},
+	}
+	previous := interpreter.Event{
+		Destination: "event:device-status/mac:112233445566/online",
+		Metadata:    map[string]string{interpreter.BootTimeKey: "100"},
+	}
+	data, _ := json.Marshal(e)
+	previousData, _ := json.Marshal(previous)
+
+	recentClient := new(mockRecentEventsClient)
+	recentClient.On("LRange", mock.Anything, mock.Anything, mock.Anything).Return(redis.NewStringSliceResult([]string{string(data), string(previousData)}, nil))
+	client := new(mockClient)
+	c := CodexClient{
+		Logger:         zap.NewNop(),
+		Client:         client,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           new(mockAcquirer),
+		RateLimiter:    ratelimit.NewUnlimited(),
+		Recent:         newTestRecentEventStore(recentClient, nil),
+	}
+
+	assert.Equal([]interpreter.Event{e, previous}, c.GetEvents(context.Background(), "mac:112233445566"))
+	client.AssertNotCalled(t, "Do", mock.Anything)
+}
@@ -0,0 +1,124 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xmidt-org/httpaux"
+)
+
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Signature-Timestamp"
+	minSigningKeyLength    = 32
+)
+
+var errSigningKeyTooShort = errors.New("signing key is too short")
+
+// SigningConfig configures the HMAC signing of requests sent to codex.
+type SigningConfig struct {
+	// Key is the secret used to sign requests.  If this is empty, requests are not signed.
+	Key string
+
+	// SignatureHeader is the header the signature is set on.  Defaults to X-Signature.
+	SignatureHeader string
+
+	// TimestampHeader is the header the signing timestamp is set on.  Defaults to X-Signature-Timestamp.
+	TimestampHeader string
+
+	// ReplaceAuth determines whether the signature replaces the Authorization header rather than
+	// being sent along with it.
+	ReplaceAuth bool
+}
+
+// requestSigner computes an HMAC-SHA256 signature over the method, path, and a timestamp of a request.
+type requestSigner struct {
+	key             []byte
+	signatureHeader string
+	timestampHeader string
+	replaceAuth     bool
+	now             func() time.Time
+}
+
+func newRequestSigner(config CodexConfig) (*requestSigner, error) {
+	signing := config.Signing
+	if len(signing.Key) == 0 {
+		return nil, nil
+	}
+
+	if len(signing.Key) < minSigningKeyLength {
+		return nil, fmt.Errorf("%w: must be at least %d bytes", errSigningKeyTooShort, minSigningKeyLength)
+	}
+
+	if len(signing.SignatureHeader) == 0 {
+		signing.SignatureHeader = defaultSignatureHeader
+	}
+
+	if len(signing.TimestampHeader) == 0 {
+		signing.TimestampHeader = defaultTimestampHeader
+	}
+
+	return &requestSigner{
+		key:             []byte(signing.Key),
+		signatureHeader: signing.SignatureHeader,
+		timestampHeader: signing.TimestampHeader,
+		replaceAuth:     signing.ReplaceAuth,
+		now:             time.Now,
+	}, nil
+}
+
+// Sign sets the timestamp and signature headers on the request.
+func (s *requestSigner) Sign(request *http.Request) {
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	request.Header.Set(s.timestampHeader, timestamp)
+	request.Header.Set(s.signatureHeader, s.signature(canonicalRequest(request, timestamp)))
+}
+
+func (s *requestSigner) signature(canonical string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signingClient signs each request as it is sent, so that every retry, hedged request, and failover attempt is
+// signed with a fresh timestamp rather than the one from when the request was built.
+type signingClient struct {
+	next   httpaux.Client
+	signer *requestSigner
+}
+
+func (c signingClient) Do(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	c.signer.Sign(request)
+	return c.next.Do(request)
+}
+
+// canonicalRequest builds the string that is signed: the method, path (with query), and timestamp
+// separated by newlines.
+func canonicalRequest(request *http.Request, timestamp string) string {
+	return strings.Join([]string{request.Method, request.URL.RequestURI(), timestamp}, "\n")
+}
@@ -0,0 +1,165 @@
+package events
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/httpaux/retry"
+)
+
+const (
+	testSigningKey        = "0123456789abcdef0123456789abcdef"
+	testSigningAddress    = "http://codex-test/api/v1/device/mac:112233445566/events"
+	testExpectedSignature = "9312751350e7637f3bbd1870ae4620b6c19e49f8b763556f42a51fdf6b903520"
+)
+
+func TestNewRequestSigner(t *testing.T) {
+	tests := []struct {
+		description             string
+		config                  SigningConfig
+		expectedNil             bool
+		expectedSignatureHeader string
+		expectedTimestampHeader string
+		expectedErr             error
+	}{
+		{
+			description: "No key",
+			expectedNil: true,
+		},
+		{
+			description: "Key too short",
+			config:      SigningConfig{Key: "short"},
+			expectedNil: true,
+			expectedErr: errSigningKeyTooShort,
+		},
+		{
+			description:             "Default headers",
+			config:                  SigningConfig{Key: testSigningKey},
+			expectedSignatureHeader: defaultSignatureHeader,
+			expectedTimestampHeader: defaultTimestampHeader,
+		},
+		{
+			description:             "Custom headers",
+			config:                  SigningConfig{Key: testSigningKey, SignatureHeader: "X-Sig", TimestampHeader: "X-Ts"},
+			expectedSignatureHeader: "X-Sig",
+			expectedTimestampHeader: "X-Ts",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			signer, err := newRequestSigner(CodexConfig{Signing: tc.config})
+			assert.True(errors.Is(err, tc.expectedErr))
+			if tc.expectedNil {
+				assert.Nil(signer)
+				return
+			}
+
+			assert.Equal(tc.expectedSignatureHeader, signer.signatureHeader)
+			assert.Equal(tc.expectedTimestampHeader, signer.timestampHeader)
+		})
+	}
+}
+
+func TestBuildGETRequestSigned(t *testing.T) {
+	tests := []struct {
+		description       string
+		replaceAuth       bool
+		expectedAuthorize bool
+	}{
+		{
+			description:       "With auth",
+			expectedAuthorize: true,
+		},
+		{
+			description: "Replace auth",
+			replaceAuth: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			signer, err := newRequestSigner(CodexConfig{Signing: SigningConfig{Key: testSigningKey, ReplaceAuth: tc.replaceAuth}})
+			assert.Nil(err)
+
+			auth := new(mockAcquirer)
+			if tc.expectedAuthorize {
+				auth.On("Acquire").Return("test", nil)
+			}
+
+			req, err := buildGETRequest(testSigningAddress, auth, signer)
+			assert.Nil(err)
+			assert.Equal(http.MethodGet, req.Method)
+
+			// requests are signed when they are sent, not when they are built.
+			assert.Empty(req.Header.Get(defaultTimestampHeader))
+			assert.Empty(req.Header.Get(defaultSignatureHeader))
+			if tc.expectedAuthorize {
+				assert.Equal("test", req.Header.Get("Authorization"))
+			} else {
+				assert.Empty(req.Header.Get("Authorization"))
+			}
+			auth.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestSignerSign(t *testing.T) {
+	assert := assert.New(t)
+	signer, err := newRequestSigner(CodexConfig{Signing: SigningConfig{Key: testSigningKey}})
+	assert.Nil(err)
+	signer.now = func() time.Time { return time.Unix(1614708001, 0) }
+
+	req, err := http.NewRequest(http.MethodGet, testSigningAddress, nil)
+	assert.Nil(err)
+	signer.Sign(req)
+	assert.Equal("1614708001", req.Header.Get(defaultTimestampHeader))
+	assert.Equal(testExpectedSignature, req.Header.Get(defaultSignatureHeader))
+}
+
+func TestSigningClient(t *testing.T) {
+	assert := assert.New(t)
+	var timestamps, signatures []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamps = append(timestamps, r.Header.Get(defaultTimestampHeader))
+		signatures = append(signatures, r.Header.Get(defaultSignatureHeader))
+		if len(timestamps) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	signer, err := newRequestSigner(CodexConfig{Signing: SigningConfig{Key: testSigningKey}})
+	assert.Nil(err)
+	now := time.Unix(1614708001, 0)
+	signer.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	// each retry is signed with a new timestamp.
+	client := retry.New(newRetryConfig(CodexConfig{Retry: RetryConfig{MaxAttempts: 2, Interval: time.Millisecond, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}}), signingClient{next: server.Client(), signer: signer})
+	request, err := buildGETRequest(server.URL+"/api/v1/device/mac:112233445566/events", nil, &requestSigner{replaceAuth: true})
+	assert.Nil(err)
+	response, err := client.Do(request)
+	if !assert.Nil(err) {
+		return
+	}
+	response.Body.Close()
+
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal([]string{"1614708061", "1614708121"}, timestamps)
+	if assert.Len(signatures, 2) {
+		assert.NotEqual(signatures[0], signatures[1])
+		assert.Equal(signer.signature(canonicalRequest(request, "1614708121")), signatures[1])
+	}
+
+	// the request sent isn't modified.
+	assert.Empty(request.Header.Get(defaultTimestampHeader))
+}
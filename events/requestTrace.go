@@ -0,0 +1,109 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/httpaux"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type requestTraceKey struct{}
+
+// requestTrace records the outcome of each attempt made for a single codex request.
+type requestTrace struct {
+	lock       sync.Mutex
+	attempts   int
+	statusCode int
+}
+
+func (t *requestTrace) record(statusCode int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.attempts++
+	t.statusCode = statusCode
+}
+
+// retries returns the number of attempts made after the first one.
+func (t *requestTrace) retries() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.attempts == 0 {
+		return 0
+	}
+	return t.attempts - 1
+}
+
+func (t *requestTrace) status() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.statusCode
+}
+
+func withRequestTrace(ctx context.Context, trace *requestTrace) context.Context {
+	return context.WithValue(ctx, requestTraceKey{}, trace)
+}
+
+func getRequestTrace(ctx context.Context) *requestTrace {
+	trace, _ := ctx.Value(requestTraceKey{}).(*requestTrace)
+	return trace
+}
+
+// tracingClient records each attempt in the request's trace, if there is one, and as an event of the
+// request's span.  It should be wrapped by the retry client so that every attempt is recorded.
+type tracingClient struct {
+	next httpaux.Client
+}
+
+func (c tracingClient) Do(request *http.Request) (*http.Response, error) {
+	response, err := c.next.Do(request)
+	statusCode := -1
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+
+	oteltrace.SpanFromContext(request.Context()).AddEvent("attempt", oteltrace.WithAttributes(semconv.HTTPStatusCodeKey.Int(statusCode)))
+	if trace := getRequestTrace(request.Context()); trace != nil {
+		trace.record(statusCode)
+	}
+
+	return response, err
+}
+
+// logSampledRequest logs the outcome of a sampled codex request.
+func logSampledRequest(logger *zap.Logger, deviceID string, request *http.Request, trace *requestTrace, latency time.Duration, err error) {
+	fields := []zap.Field{
+		zap.String("device id", deviceID),
+		zap.String("url", request.URL.String()),
+		zap.Int("status", trace.status()),
+		zap.Duration("latency", latency),
+		zap.Int("retries", trace.retries()),
+	}
+
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+
+	logger.Info("sampled codex request", fields...)
+}
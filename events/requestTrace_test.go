@@ -0,0 +1,144 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/httpaux/retry"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTracingClient(t *testing.T) {
+	tests := []struct {
+		description     string
+		statusCode      int
+		clientErr       error
+		retries         int
+		expectedStatus  int
+		expectedRetries int
+	}{
+		{
+			description:    "Success",
+			statusCode:     http.StatusOK,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:     "Retried",
+			statusCode:      http.StatusGatewayTimeout,
+			retries:         2,
+			expectedStatus:  http.StatusGatewayTimeout,
+			expectedRetries: 2,
+		},
+		{
+			description:    "No response",
+			clientErr:      errors.New("test error"),
+			expectedStatus: -1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			client := new(mockClient)
+			if tc.clientErr != nil {
+				client.On("Do", mock.Anything).Return(nil, tc.clientErr)
+			} else {
+				resp := httptest.NewRecorder()
+				resp.Code = tc.statusCode
+				client.On("Do", mock.Anything).Return(resp.Result(), nil) // nolint:bodyclose
+			}
+
+			retryClient := retry.New(retry.Config{Retries: tc.retries, Interval: time.Millisecond}, tracingClient{next: client})
+			trace := new(requestTrace)
+			request, err := http.NewRequestWithContext(withRequestTrace(context.Background(), trace), http.MethodGet, "test-codex/test", nil)
+			assert.Nil(err)
+			retryClient.Do(request) // nolint:bodyclose
+			assert.Equal(tc.expectedStatus, trace.status())
+			assert.Equal(tc.expectedRetries, trace.retries())
+		})
+	}
+}
+
+func TestTracingClientNoTrace(t *testing.T) {
+	client := new(mockClient)
+	client.On("Do", mock.Anything).Return(nil, errors.New("test error"))
+	request, err := http.NewRequest(http.MethodGet, "test-codex/test", nil)
+	assert.Nil(t, err)
+	_, err = tracingClient{next: client}.Do(request) // nolint:bodyclose
+	assert.NotNil(t, err)
+	assert.Nil(t, getRequestTrace(request.Context()))
+}
+
+func TestGetEventsSampledLog(t *testing.T) {
+	tests := []struct {
+		description   string
+		sampleRate    float64
+		random        float64
+		expectedLines int
+	}{
+		{
+			description: "Sampling off",
+			random:      0.0,
+		},
+		{
+			description:   "Sampled",
+			sampleRate:    0.5,
+			random:        0.2,
+			expectedLines: 1,
+		},
+		{
+			description: "Not sampled",
+			sampleRate:  0.5,
+			random:      0.7,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			core, logs := observer.New(zapcore.InfoLevel)
+			client := new(mockClient)
+			auth := new(mockAcquirer)
+			auth.On("Acquire").Return("test", nil)
+			resp := httptest.NewRecorder()
+			resp.WriteHeader(http.StatusNotFound)
+			resp.WriteString("[]")
+			client.On("Do", mock.Anything).Return(resp.Result(), nil) // nolint:bodyclose
+
+			c := CodexClient{
+				Address:        "http://codex",
+				Logger:         zap.New(core),
+				Client:         tracingClient{next: client},
+				CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+				Auth:           auth,
+				RateLimiter:    ratelimit.NewUnlimited(),
+				LogSampleRate:  tc.sampleRate,
+				random:         func() float64 { return tc.random },
+			}
+
+			c.GetEvents(context.Background(), "mac:112233445566")
+			sampled := logs.FilterMessage("sampled codex request").All()
+			assert.Len(sampled, tc.expectedLines)
+			if tc.expectedLines == 0 {
+				return
+			}
+
+			fields := sampled[0].ContextMap()
+			assert.Equal("mac:112233445566", fields["device id"])
+			assert.Equal("http://codex/api/v1/device/mac:112233445566/events", fields["url"])
+			assert.Equal(int64(http.StatusNotFound), fields["status"])
+			assert.Equal(int64(0), fields["retries"])
+			assert.Contains(fields, "latency")
+		})
+	}
+}
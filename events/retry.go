@@ -0,0 +1,125 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/httpaux"
+	"github.com/xmidt-org/httpaux/retry"
+)
+
+const (
+	attemptLabel = "attempt"
+
+	defaultRetryInterval = 30 * time.Second
+)
+
+// RetryConfig configures retrying codex requests that fail with a retryable status code or a temporary error.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts of a request, including the first.  If 0, the request is
+	// retried up to MaxRetryCount times.
+	MaxAttempts int
+
+	// Interval is the time waited before the first retry.  Defaults to 30s.
+	Interval time.Duration
+
+	// Multiplier is the factor each wait is multiplied by for the next retry, for exponential backoff.  If 0,
+	// every retry waits the Interval.
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to this fraction above or below it.  It must be between 0 and 1,
+	// otherwise no jitter is used.
+	Jitter float64
+
+	// RetryableStatusCodes are the response status codes that are retried.  Defaults to 408, 429, and 504.
+	RetryableStatusCodes []int
+}
+
+// newRetryConfig builds the retry client's configuration, falling back to MaxRetryCount when the retry
+// config doesn't set the maximum attempts.
+func newRetryConfig(config CodexConfig) retry.Config {
+	retries := config.MaxRetryCount
+	if config.Retry.MaxAttempts > 0 {
+		retries = config.Retry.MaxAttempts - 1
+	}
+
+	interval := config.Retry.Interval
+	if interval <= 0 {
+		interval = defaultRetryInterval
+	}
+
+	return retry.Config{
+		Retries:    retries,
+		Interval:   interval,
+		Multiplier: config.Retry.Multiplier,
+		Jitter:     config.Retry.Jitter,
+		Check:      newRetryCheck(config.Retry.RetryableStatusCodes),
+	}
+}
+
+// newRetryCheck returns a check that retries responses with one of the status codes given, as well as temporary
+// errors.  If no status codes are given, the retry package's default check is used.
+func newRetryCheck(statusCodes []int) retry.Check {
+	if len(statusCodes) == 0 {
+		return retry.DefaultCheck
+	}
+
+	retryable := make(map[int]bool, len(statusCodes))
+	for _, statusCode := range statusCodes {
+		retryable[statusCode] = true
+	}
+
+	return func(response *http.Response, err error) bool {
+		if response != nil && retryable[response.StatusCode] {
+			return true
+		}
+
+		return retry.DefaultCheck(nil, err)
+	}
+}
+
+// attemptClient counts each attempt of a codex request by its attempt number and status code.  It should be
+// wrapped by the retry client so that every attempt is counted.
+type attemptClient struct {
+	next     httpaux.Client
+	attempts *prometheus.CounterVec
+}
+
+func (c attemptClient) Do(request *http.Request) (*http.Response, error) {
+	response, err := c.next.Do(request)
+	if c.attempts == nil {
+		return response, err
+	}
+
+	attempt := 1
+	if state := retry.GetState(request.Context()); state != nil {
+		attempt = state.Attempt() + 1
+	}
+
+	statusCode := "-1"
+	if response != nil {
+		statusCode = strconv.Itoa(response.StatusCode)
+	}
+
+	c.attempts.With(prometheus.Labels{attemptLabel: strconv.Itoa(attempt), responseCodeLabel: statusCode}).Add(1.0)
+	return response, err
+}
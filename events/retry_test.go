@@ -0,0 +1,192 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xmidt-org/bascule/acquire"
+	"github.com/xmidt-org/candlelight"
+	"github.com/xmidt-org/httpaux/retry"
+	"go.uber.org/zap"
+)
+
+func TestNewRetryConfig(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         CodexConfig
+		expectedConfig retry.Config
+	}{
+		{
+			description:    "Defaults",
+			expectedConfig: retry.Config{Interval: defaultRetryInterval},
+		},
+		{
+			description:    "Max retry count",
+			config:         CodexConfig{MaxRetryCount: 2},
+			expectedConfig: retry.Config{Retries: 2, Interval: defaultRetryInterval},
+		},
+		{
+			description: "Retry config",
+			config: CodexConfig{
+				MaxRetryCount: 2,
+				Retry: RetryConfig{
+					MaxAttempts: 5,
+					Interval:    time.Second,
+					Multiplier:  2.0,
+					Jitter:      0.1,
+				},
+			},
+			expectedConfig: retry.Config{Retries: 4, Interval: time.Second, Multiplier: 2.0, Jitter: 0.1},
+		},
+		{
+			description:    "Single attempt",
+			config:         CodexConfig{MaxRetryCount: 2, Retry: RetryConfig{MaxAttempts: 1}},
+			expectedConfig: retry.Config{Interval: defaultRetryInterval},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			config := newRetryConfig(tc.config)
+			assert.NotNil(config.Check)
+			config.Check = nil
+			assert.Equal(tc.expectedConfig, config)
+		})
+	}
+}
+
+func TestNewRetryCheck(t *testing.T) {
+	temporaryErr := &net.DNSError{IsTemporary: true}
+	tests := []struct {
+		description   string
+		statusCodes   []int
+		statusCode    int
+		err           error
+		expectedRetry bool
+	}{
+		{
+			description:   "Default retryable status",
+			statusCode:    http.StatusTooManyRequests,
+			expectedRetry: true,
+		},
+		{
+			description: "Default non-retryable status",
+			statusCode:  http.StatusServiceUnavailable,
+		},
+		{
+			description:   "Configured retryable status",
+			statusCodes:   []int{http.StatusServiceUnavailable},
+			statusCode:    http.StatusServiceUnavailable,
+			expectedRetry: true,
+		},
+		{
+			description: "Configured non-retryable status",
+			statusCodes: []int{http.StatusServiceUnavailable},
+			statusCode:  http.StatusTooManyRequests,
+		},
+		{
+			description:   "Temporary error",
+			statusCodes:   []int{http.StatusServiceUnavailable},
+			err:           temporaryErr,
+			expectedRetry: true,
+		},
+		{
+			description: "Cancelled",
+			statusCodes: []int{http.StatusServiceUnavailable},
+			err:         context.Canceled,
+		},
+		{
+			description: "Success",
+			statusCodes: []int{http.StatusServiceUnavailable},
+			statusCode:  http.StatusOK,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			var response *http.Response
+			if tc.statusCode > 0 {
+				response = &http.Response{StatusCode: tc.statusCode}
+			}
+
+			assert.Equal(t, tc.expectedRetry, newRetryCheck(tc.statusCodes)(response, tc.err))
+		})
+	}
+}
+
+func TestAttemptClient(t *testing.T) {
+	assert := assert.New(t)
+	attempts := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "attempts"}, []string{attemptLabel, responseCodeLabel})
+	client := new(mockClient)
+	timeout := httptest.NewRecorder()
+	timeout.Code = http.StatusGatewayTimeout
+	client.On("Do", mock.Anything).Return(timeout.Result(), nil).Twice() // nolint:bodyclose
+	client.On("Do", mock.Anything).Return(nil, errors.New("test error")).Once()
+
+	retryClient := retry.New(retry.Config{Retries: 3, Interval: time.Millisecond}, attemptClient{next: client, attempts: attempts})
+	request, err := http.NewRequest(http.MethodGet, "test-codex/test", nil)
+	assert.Nil(err)
+	_, err = retryClient.Do(request) // nolint:bodyclose
+	assert.NotNil(err)
+
+	client.AssertExpectations(t)
+	assert.Equal(1.0, testutil.ToFloat64(attempts.WithLabelValues("1", "504")))
+	assert.Equal(1.0, testutil.ToFloat64(attempts.WithLabelValues("2", "504")))
+	assert.Equal(1.0, testutil.ToFloat64(attempts.WithLabelValues("3", "-1")))
+
+	// without a retry client, every request is the first attempt.
+	client.On("Do", mock.Anything).Return(timeout.Result(), nil).Once()  // nolint:bodyclose
+	_, err = attemptClient{next: client, attempts: attempts}.Do(request) // nolint:bodyclose
+	assert.Nil(err)
+	assert.Equal(2.0, testutil.ToFloat64(attempts.WithLabelValues("1", "504")))
+}
+
+func TestGetEventsCancelsRetries(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := CodexConfig{
+		Address: server.URL,
+		Retry: RetryConfig{
+			MaxAttempts:          3,
+			Interval:             time.Hour,
+			RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+		},
+	}
+
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test"})
+	client, err := createCodexClient(config, cb, nil, &acquire.DefaultAcquirer{}, nil, nil, Measures{}, candlelight.Tracing{}, zap.NewNop())
+	if !assert.Nil(err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Empty(client.GetEvents(ctx, "some-deviceID"))
+	}()
+
+	// give the request time to reach its first retry's wait.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("request wasn't cancelled with its context")
+	}
+}
@@ -0,0 +1,271 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	discardReasonLabel = "reason"
+
+	destinationDiscard = "destination"
+	bootTimeDiscard    = "boot_time"
+	timeRangeDiscard   = "time_range"
+
+	truncateReasonLabel = "reason"
+
+	bytesTruncate  = "max_bytes"
+	eventsTruncate = "max_events"
+
+	// truncatedLogInterval is how often truncated responses are logged.
+	truncatedLogInterval = time.Minute
+)
+
+var (
+	errInvalidStreamDestination = errors.New("invalid streaming destination regex")
+	errNotEventList             = errors.New("response isn't a list of events")
+	errResponseTooLarge         = errors.New("response exceeds the maximum size")
+)
+
+// StreamingConfig configures decoding codex's responses one event at a time, discarding the events parsers don't
+// need as they are decoded rather than holding a device's entire history in memory.
+type StreamingConfig struct {
+	// Enabled turns on streaming codex's responses.
+	Enabled bool
+
+	// Destinations are regexes of the destinations of the events kept.  If this is empty, events are kept
+	// regardless of their destination.
+	Destinations []string
+
+	// BootTimeWindow discards the events whose boot-time is longer than this before the lookup.  Events without
+	// a boot-time are kept.  If this is 0, events are kept regardless of their boot-time.
+	BootTimeWindow time.Duration
+}
+
+// ResponseLimitsConfig configures the limits of codex's responses, past which they are truncated rather than
+// read entirely into memory.
+type ResponseLimitsConfig struct {
+	// MaxBytes is the maximum number of bytes of a response read.  The events decoded within it are kept.  If
+	// this is 0, the size of responses isn't limited.
+	MaxBytes int64
+
+	// MaxEvents is the maximum number of events kept from a response.  If this is 0, the number of events isn't
+	// limited.
+	MaxEvents int
+}
+
+// eventStream decodes codex's responses one event at a time, discarding the events that aren't needed and
+// truncating the responses that exceed the limits.
+type eventStream struct {
+	destinations   []*regexp.Regexp
+	bootTimeWindow time.Duration
+	maxBytes       int64
+	maxEvents      int
+	discarded      *prometheus.CounterVec
+	truncated      *prometheus.CounterVec
+	current        func() time.Time
+}
+
+// newEventStream creates a new eventStream, returning nil if streaming isn't enabled and responses aren't limited.
+// Events are only discarded if streaming is enabled.
+func newEventStream(config StreamingConfig, limits ResponseLimitsConfig, discarded *prometheus.CounterVec, truncated *prometheus.CounterVec) (*eventStream, error) {
+	if !config.Enabled {
+		if limits.MaxBytes <= 0 && limits.MaxEvents <= 0 {
+			return nil, nil
+		}
+
+		config = StreamingConfig{}
+	}
+
+	destinations := make([]*regexp.Regexp, 0, len(config.Destinations))
+	for _, destination := range config.Destinations {
+		r, err := regexp.Compile(destination)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidStreamDestination, err)
+		}
+		destinations = append(destinations, r)
+	}
+
+	return &eventStream{
+		destinations:   destinations,
+		bootTimeWindow: config.BootTimeWindow,
+		maxBytes:       limits.MaxBytes,
+		maxEvents:      limits.MaxEvents,
+		discarded:      discarded,
+		truncated:      truncated,
+		current:        time.Now,
+	}, nil
+}
+
+// streamedEvents are the events decoded from a response, along with why the response was truncated, if it was.
+type streamedEvents struct {
+	events    []interpreter.Event
+	truncated string
+}
+
+// decode decodes the list of events read, keeping only the events needed.  If start isn't zero, the events
+// received before it are discarded too.  Once a limit is exceeded, the events already decoded are returned along
+// with the reason the response was truncated.
+func (s *eventStream) decode(r io.Reader, start time.Time) (streamedEvents, error) {
+	return s.decodeList(json.NewDecoder(s.limit(r)), start, decodeEvent)
+}
+
+// limit limits the bytes read from r to the maximum size of a response, if there is one.
+func (s *eventStream) limit(r io.Reader) io.Reader {
+	if s.maxBytes > 0 {
+		return &maxBytesReader{reader: r, remaining: s.maxBytes}
+	}
+
+	return r
+}
+
+// decodeList decodes the list of events read by the decoder, decoding each event with decodeNext and keeping only
+// the events needed.  If start isn't zero, the events received before it are discarded too.  Once a limit is
+// exceeded, the events already decoded are returned along with the reason the response was truncated.
+func (s *eventStream) decodeList(decoder *json.Decoder, start time.Time, decodeNext func(*json.Decoder) (interpreter.Event, error)) (streamedEvents, error) {
+	result := streamedEvents{events: make([]interpreter.Event, 0)}
+	token, err := decoder.Token()
+	if errors.Is(err, errResponseTooLarge) {
+		return s.truncate(result, bytesTruncate), nil
+	}
+
+	if err != nil {
+		return streamedEvents{}, err
+	}
+
+	if token == nil {
+		return result, nil
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return streamedEvents{}, errNotEventList
+	}
+
+	var bootTimeCutoff int64
+	if s.bootTimeWindow > 0 {
+		bootTimeCutoff = s.current().Add(-1 * s.bootTimeWindow).Unix()
+	}
+
+	for decoder.More() {
+		if s.maxEvents > 0 && len(result.events) >= s.maxEvents {
+			return s.truncate(result, eventsTruncate), nil
+		}
+
+		event, err := decodeNext(decoder)
+		if errors.Is(err, errResponseTooLarge) {
+			return s.truncate(result, bytesTruncate), nil
+		} else if err != nil {
+			return streamedEvents{}, err
+		}
+
+		if reason := s.discardReason(event, start, bootTimeCutoff); len(reason) > 0 {
+			if s.discarded != nil {
+				s.discarded.With(prometheus.Labels{discardReasonLabel: reason}).Add(1.0)
+			}
+			continue
+		}
+		result.events = append(result.events, event)
+	}
+
+	if _, err := decoder.Token(); errors.Is(err, errResponseTooLarge) {
+		return s.truncate(result, bytesTruncate), nil
+	} else if err != nil {
+		return streamedEvents{}, err
+	}
+
+	return result, nil
+}
+
+func decodeEvent(decoder *json.Decoder) (interpreter.Event, error) {
+	var event interpreter.Event
+	err := decoder.Decode(&event)
+	return event, err
+}
+
+// truncate counts the response truncated for the reason given.
+func (s *eventStream) truncate(result streamedEvents, reason string) streamedEvents {
+	if s.truncated != nil {
+		s.truncated.With(prometheus.Labels{truncateReasonLabel: reason}).Add(1.0)
+	}
+
+	result.truncated = reason
+	return result
+}
+
+// discardReason returns why the event isn't needed, or an empty string if it should be kept.
+func (s *eventStream) discardReason(event interpreter.Event, start time.Time, bootTimeCutoff int64) string {
+	if len(s.destinations) > 0 && !s.matchesDestination(event.Destination) {
+		return destinationDiscard
+	}
+
+	if bootTimeCutoff > 0 {
+		if bootTime, err := event.BootTime(); err == nil && bootTime > 0 && bootTime < bootTimeCutoff {
+			return bootTimeDiscard
+		}
+	}
+
+	if !start.IsZero() && event.Birthdate > 0 && event.Birthdate < start.UnixNano() {
+		return timeRangeDiscard
+	}
+
+	return ""
+}
+
+func (s *eventStream) matchesDestination(destination string) bool {
+	for _, r := range s.destinations {
+		if r.MatchString(destination) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxBytesReader reads up to a maximum number of bytes, returning errResponseTooLarge once more are read.
+type maxBytesReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		// check whether there is anything past the limit.
+		var b [1]byte
+		if n, err := m.reader.Read(b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, errResponseTooLarge
+	}
+
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+
+	n, err := m.reader.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
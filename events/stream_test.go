@@ -0,0 +1,279 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/time/rate"
+)
+
+func TestNewEventStream(t *testing.T) {
+	assert := assert.New(t)
+	stream, err := newEventStream(StreamingConfig{}, ResponseLimitsConfig{}, nil, nil)
+	assert.Nil(stream)
+	assert.Nil(err)
+
+	stream, err = newEventStream(StreamingConfig{Enabled: true, Destinations: []string{"online$", "offline$"}}, ResponseLimitsConfig{}, nil, nil)
+	assert.Nil(err)
+	if assert.NotNil(stream) {
+		assert.Len(stream.destinations, 2)
+	}
+
+	stream, err = newEventStream(StreamingConfig{Enabled: true, Destinations: []string{"(online"}}, ResponseLimitsConfig{}, nil, nil)
+	assert.ErrorIs(err, errInvalidStreamDestination)
+	assert.Nil(stream)
+
+	// limits decode responses incrementally even without streaming, without discarding events.
+	stream, err = newEventStream(StreamingConfig{Destinations: []string{"online$"}}, ResponseLimitsConfig{MaxEvents: 10}, nil, nil)
+	assert.Nil(err)
+	if assert.NotNil(stream) {
+		assert.Empty(stream.destinations)
+		assert.Equal(10, stream.maxEvents)
+	}
+}
+
+func TestEventStreamDecode(t *testing.T) {
+	now := time.Now()
+	online := interpreter.Event{
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "online",
+		Birthdate:       now.Add(-1 * time.Hour).UnixNano(),
+		Metadata:        map[string]string{interpreter.BootTimeKey: strconv.FormatInt(now.Add(-2*time.Hour).Unix(), 10)},
+	}
+	oldBoot := interpreter.Event{
+		Destination:     "event:device-status/mac:112233445566/online",
+		TransactionUUID: "old boot",
+		Birthdate:       now.Add(-1 * time.Hour).UnixNano(),
+		Metadata:        map[string]string{interpreter.BootTimeKey: strconv.FormatInt(now.Add(-100*time.Hour).Unix(), 10)},
+	}
+	oldBirthdate := interpreter.Event{
+		Destination:     "event:device-status/mac:112233445566/offline",
+		TransactionUUID: "old birthdate",
+		Birthdate:       now.Add(-5 * time.Hour).UnixNano(),
+	}
+	other := interpreter.Event{
+		Destination:     "event:device-status/mac:112233445566/some-event",
+		TransactionUUID: "other",
+	}
+	allEvents := []interpreter.Event{online, oldBoot, oldBirthdate, other}
+	data, err := json.Marshal(allEvents)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	tests := []struct {
+		description       string
+		config            StreamingConfig
+		limits            ResponseLimitsConfig
+		body              string
+		start             time.Time
+		expectedEvents    []interpreter.Event
+		expectedDiscarded map[string]float64
+		expectedTruncated string
+		expectedErr       bool
+	}{
+		{
+			description:    "No filtering",
+			config:         StreamingConfig{Enabled: true},
+			body:           string(data),
+			expectedEvents: allEvents,
+		},
+		{
+			description:       "Destinations",
+			config:            StreamingConfig{Enabled: true, Destinations: []string{"/online$", "/offline$"}},
+			body:              string(data),
+			expectedEvents:    []interpreter.Event{online, oldBoot, oldBirthdate},
+			expectedDiscarded: map[string]float64{destinationDiscard: 1},
+		},
+		{
+			description:       "Boot-time window",
+			config:            StreamingConfig{Enabled: true, BootTimeWindow: 48 * time.Hour},
+			body:              string(data),
+			expectedEvents:    []interpreter.Event{online, oldBirthdate, other},
+			expectedDiscarded: map[string]float64{bootTimeDiscard: 1},
+		},
+		{
+			description:       "Time range",
+			config:            StreamingConfig{Enabled: true},
+			body:              string(data),
+			start:             now.Add(-2 * time.Hour),
+			expectedEvents:    []interpreter.Event{online, oldBoot, other},
+			expectedDiscarded: map[string]float64{timeRangeDiscard: 1},
+		},
+		{
+			description:       "Max events",
+			config:            StreamingConfig{Enabled: true, Destinations: []string{"/online$", "/offline$"}},
+			limits:            ResponseLimitsConfig{MaxEvents: 2},
+			body:              string(data),
+			expectedEvents:    []interpreter.Event{online, oldBoot},
+			expectedTruncated: eventsTruncate,
+		},
+		{
+			description:    "Max events not exceeded",
+			limits:         ResponseLimitsConfig{MaxEvents: 4},
+			body:           string(data),
+			expectedEvents: allEvents,
+		},
+		{
+			description:       "Max bytes",
+			limits:            ResponseLimitsConfig{MaxBytes: int64(len(data) - 10)},
+			body:              string(data),
+			expectedEvents:    []interpreter.Event{online, oldBoot, oldBirthdate},
+			expectedTruncated: bytesTruncate,
+		},
+		{
+			description:       "Max bytes before any events",
+			limits:            ResponseLimitsConfig{MaxBytes: 10},
+			body:              string(data),
+			expectedEvents:    []interpreter.Event{},
+			expectedTruncated: bytesTruncate,
+		},
+		{
+			description:    "Max bytes not exceeded",
+			limits:         ResponseLimitsConfig{MaxBytes: int64(len(data))},
+			body:           string(data),
+			expectedEvents: allEvents,
+		},
+		{
+			description:    "Empty",
+			config:         StreamingConfig{Enabled: true},
+			body:           "[]",
+			expectedEvents: []interpreter.Event{},
+		},
+		{
+			description:    "Null",
+			config:         StreamingConfig{Enabled: true},
+			body:           "null",
+			expectedEvents: []interpreter.Event{},
+		},
+		{
+			description: "Not a list",
+			config:      StreamingConfig{Enabled: true},
+			body:        `{"some key": "some-value"}`,
+			expectedErr: true,
+		},
+		{
+			description: "Truncated",
+			config:      StreamingConfig{Enabled: true},
+			body:        string(data[:len(data)-10]),
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			discarded := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testDiscarded", Help: "testDiscarded"}, []string{discardReasonLabel})
+			truncated := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "testTruncated", Help: "testTruncated"}, []string{truncateReasonLabel})
+			stream, err := newEventStream(tc.config, tc.limits, discarded, truncated)
+			if !assert.Nil(err) {
+				return
+			}
+			stream.current = func() time.Time { return now }
+
+			result, err := stream.decode(strings.NewReader(tc.body), tc.start)
+			if tc.expectedErr {
+				assert.NotNil(err)
+				assert.Nil(result.events)
+				return
+			}
+
+			assert.Nil(err)
+			assert.Equal(tc.expectedEvents, result.events)
+			assert.Equal(tc.expectedTruncated, result.truncated)
+			if len(tc.expectedTruncated) > 0 {
+				assert.Equal(1.0, testutil.ToFloat64(truncated.WithLabelValues(tc.expectedTruncated)))
+			}
+			for reason, expected := range tc.expectedDiscarded {
+				assert.Equal(expected, testutil.ToFloat64(discarded.WithLabelValues(reason)))
+			}
+		})
+	}
+}
+
+func TestGetEventsStreaming(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{
+		{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abcd"},
+		{Destination: "event:device-status/mac:112233445566/some-event", TransactionUUID: "efgh"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer server.Close()
+
+	stream, err := newEventStream(StreamingConfig{Enabled: true, Destinations: []string{"/online$"}}, ResponseLimitsConfig{}, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	c := CodexClient{
+		Address:        server.URL,
+		Logger:         zap.NewNop(),
+		Client:         http.DefaultClient,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		Stream:         stream,
+	}
+
+	assert.Equal([]interpreter.Event{events[0]}, c.GetEvents(context.Background(), "mac:112233445566"))
+}
+
+func TestGetEventsTruncated(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{
+		{Destination: "event:device-status/mac:112233445566/online", TransactionUUID: "abcd"},
+		{Destination: "event:device-status/mac:112233445566/offline", TransactionUUID: "efgh"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer server.Close()
+
+	stream, err := newEventStream(StreamingConfig{}, ResponseLimitsConfig{MaxEvents: 1}, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	c := CodexClient{
+		Address:        server.URL,
+		Logger:         zap.New(core),
+		Client:         http.DefaultClient,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		Stream:         stream,
+		truncatedLogs:  rate.Sometimes{Interval: time.Hour},
+	}
+
+	assert.Equal([]interpreter.Event{events[0]}, c.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Equal([]interpreter.Event{events[0]}, c.GetEvents(context.Background(), "mac:112233445566"))
+
+	// the warning is only logged once per interval.
+	truncatedLogs := logs.FilterMessage("truncated codex response exceeding limit").All()
+	if assert.Len(truncatedLogs, 1) {
+		assert.Equal("mac:112233445566", truncatedLogs[0].ContextMap()["device id"])
+		assert.Equal(eventsTruncate, truncatedLogs[0].ContextMap()["limit"])
+	}
+}
@@ -0,0 +1,111 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/xmidt-org/interpreter"
+)
+
+const defaultTimeRangeParameter = "after"
+
+// TimeRangeConfig configures only getting the events of a device received within a window before the lookup,
+// rather than its entire history.
+type TimeRangeConfig struct {
+	// Window is how far back a device's events are gotten.  If this is 0, a device's entire history is gotten.
+	Window time.Duration
+
+	// Parsers overrides the window for the lookups of specific parsers, keyed by parser name.
+	Parsers map[string]time.Duration
+
+	// Parameter is the query parameter the start of the window is sent to codex in, as a unix timestamp in
+	// seconds.  The graphql event source doesn't send the window, only dropping the events returned outside of it.
+	// Defaults to after.
+	Parameter string
+}
+
+// timeRange narrows lookups to the window configured for the parser making them.
+type timeRange struct {
+	window    time.Duration
+	parsers   map[string]time.Duration
+	parameter string
+	current   func() time.Time
+}
+
+// newTimeRange creates a new timeRange, returning nil if no windows are configured.
+func newTimeRange(config TimeRangeConfig) *timeRange {
+	if config.Window <= 0 && len(config.Parsers) == 0 {
+		return nil
+	}
+
+	if len(config.Parameter) == 0 {
+		config.Parameter = defaultTimeRangeParameter
+	}
+
+	return &timeRange{
+		window:    config.Window,
+		parsers:   config.Parsers,
+		parameter: config.Parameter,
+		current:   time.Now,
+	}
+}
+
+// windowFor returns the window of the parser's lookups, which is 0 if they aren't narrowed.
+func (t *timeRange) windowFor(parser string) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	if window, found := t.parsers[parser]; found && window > 0 {
+		return window
+	}
+
+	if t.window > 0 {
+		return t.window
+	}
+
+	return 0
+}
+
+// start returns the start of the window ending now.
+func (t *timeRange) start(window time.Duration) time.Time {
+	return t.current().Add(-1 * window)
+}
+
+// addParameter adds the start of the window to the query of the address.
+func (t *timeRange) addParameter(address string, start time.Time) string {
+	return address + "?" + url.Values{t.parameter: []string{strconv.FormatInt(start.Unix(), 10)}}.Encode()
+}
+
+// filterEvents returns the events received at or after the start, in case the backend doesn't narrow its
+// response.  Events without a birthdate are kept.
+func filterEvents(events []interpreter.Event, start time.Time) []interpreter.Event {
+	cutoff := start.UnixNano()
+	filtered := make([]interpreter.Event, 0, len(events))
+	for _, e := range events {
+		if e.Birthdate > 0 && e.Birthdate < cutoff {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	return filtered
+}
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"go.uber.org/ratelimit"
+	"go.uber.org/zap"
+)
+
+func TestTimeRangeWindowFor(t *testing.T) {
+	tests := []struct {
+		description    string
+		config         TimeRangeConfig
+		parser         string
+		expectedWindow time.Duration
+	}{
+		{
+			description: "Not configured",
+			parser:      "reboot_duration_parser",
+		},
+		{
+			description:    "Default window",
+			config:         TimeRangeConfig{Window: 48 * time.Hour},
+			parser:         "reboot_duration_parser",
+			expectedWindow: 48 * time.Hour,
+		},
+		{
+			description:    "Parser window",
+			config:         TimeRangeConfig{Window: 48 * time.Hour, Parsers: map[string]time.Duration{"crash_loop_parser": time.Hour}},
+			parser:         "crash_loop_parser",
+			expectedWindow: time.Hour,
+		},
+		{
+			description:    "Other parser",
+			config:         TimeRangeConfig{Window: 48 * time.Hour, Parsers: map[string]time.Duration{"crash_loop_parser": time.Hour}},
+			parser:         "reboot_duration_parser",
+			expectedWindow: 48 * time.Hour,
+		},
+		{
+			description: "Only parser windows",
+			config:      TimeRangeConfig{Parsers: map[string]time.Duration{"crash_loop_parser": time.Hour}},
+			parser:      "reboot_duration_parser",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expectedWindow, newTimeRange(tc.config).windowFor(tc.parser))
+		})
+	}
+}
+
+func TestNewTimeRange(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(newTimeRange(TimeRangeConfig{}))
+
+	tr := newTimeRange(TimeRangeConfig{Window: time.Hour})
+	if !assert.NotNil(tr) {
+		return
+	}
+	assert.Equal(defaultTimeRangeParameter, tr.parameter)
+	assert.Equal("http://codex/events?after=100", tr.addParameter("http://codex/events", time.Unix(100, 0)))
+}
+
+func TestFilterEvents(t *testing.T) {
+	start := time.Unix(100, 0)
+	events := []interpreter.Event{
+		{TransactionUUID: "before", Birthdate: start.Add(-1 * time.Second).UnixNano()},
+		{TransactionUUID: "at", Birthdate: start.UnixNano()},
+		{TransactionUUID: "after", Birthdate: start.Add(time.Second).UnixNano()},
+		{TransactionUUID: "no birthdate"},
+	}
+
+	assert.Equal(t, []interpreter.Event{events[1], events[2], events[3]}, filterEvents(events, start))
+}
+
+func TestGetEventsTimeRange(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	events := []interpreter.Event{
+		{TransactionUUID: "old", Birthdate: now.Add(-3 * time.Hour).UnixNano()},
+		{TransactionUUID: "recent", Birthdate: now.Add(-30 * time.Minute).UnixNano()},
+	}
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer server.Close()
+
+	auth := new(mockAcquirer)
+	auth.On("Acquire").Return("test", nil)
+	c := CodexClient{
+		Address:        server.URL,
+		Logger:         zap.NewNop(),
+		Client:         http.DefaultClient,
+		CircuitBreaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{Name: "test circuit breaker"}),
+		Auth:           auth,
+		RateLimiter:    ratelimit.NewUnlimited(),
+		Cache:          newEventCache(CacheConfig{TTL: time.Hour}, nil),
+		TimeRange:      newTimeRange(TimeRangeConfig{Window: 2 * time.Hour, Parsers: map[string]time.Duration{"crash_loop_parser": time.Hour}}),
+	}
+	c.TimeRange.current = func() time.Time { return now }
+
+	assert.Equal([]interpreter.Event{events[1]}, c.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Equal([]interpreter.Event{events[1]}, c.ForParser("crash_loop_parser").GetEvents(context.Background(), "mac:112233445566"))
+
+	// each window is cached separately.
+	assert.Equal([]interpreter.Event{events[1]}, c.GetEvents(context.Background(), "mac:112233445566"))
+	assert.Equal([]string{
+		"after=" + strconv.FormatInt(now.Add(-2*time.Hour).Unix(), 10),
+		"after=" + strconv.FormatInt(now.Add(-1*time.Hour).Unix(), 10),
+	}, queries)
+}
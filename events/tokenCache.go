@@ -0,0 +1,238 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/bascule/acquire"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTokenRefreshInterval = 5 * time.Minute
+	defaultTokenMaxAge          = 15 * time.Minute
+	defaultTokenRetryInterval   = 10 * time.Second
+)
+
+// TokenCacheConfig configures caching the token used to authorize codex requests, rather than acquiring it for
+// every request.
+type TokenCacheConfig struct {
+	// Enabled turns on caching the token.
+	Enabled bool
+
+	// RefreshInterval is how long a token is used before it is refreshed in the background, while requests keep
+	// using it.  Defaults to 5m.
+	RefreshInterval time.Duration
+
+	// MaxAge is how long a token keeps being used while refreshing it fails, after which requests wait for a new
+	// token.  It is never shorter than RefreshInterval.  A JWT is never used past the expiration in its claims,
+	// regardless of MaxAge.  Defaults to 15m.
+	MaxAge time.Duration
+
+	// RetryInterval is how long to wait after a failed refresh before refreshing in the background again.
+	// Defaults to 10s.
+	RetryInterval time.Duration
+}
+
+// CodexAuthIn provides everything needed to create the acquirer of the token used to authorize codex requests.
+type CodexAuthIn struct {
+	fx.In
+	Config        CodexConfig
+	TokenAge      prometheus.Gauge   `name:"codex_auth_token_age_seconds" optional:"true"`
+	RefreshErrors prometheus.Counter `name:"codex_auth_token_refresh_errors_count" optional:"true"`
+	Logger        *zap.Logger
+}
+
+// provideCodexAuth creates the acquirer of codex's auth, caching its token if configured to.
+func provideCodexAuth(in CodexAuthIn) (acquire.Acquirer, error) {
+	auth, err := determineCodexTokenAcquirer(in.Logger, in.Config)
+	if err != nil || !in.Config.Auth.Cache.Enabled {
+		return auth, err
+	}
+
+	return newCachingAcquirer(in.Config.Auth.Cache, auth, in.TokenAge, in.RefreshErrors, in.Logger), nil
+}
+
+// cachingAcquirer caches the token of another acquirer, refreshing it in the background once it is older than the
+// refresh interval so that requests don't wait on the auth service.  Only one refresh is made at a time, and the
+// previous token is used until the max age if refreshing fails.  A token is never used past the expiration in its
+// claims, and its age is kept when refreshing returns the same token, as the acquirer wrapped usually caches it too.
+type cachingAcquirer struct {
+	next            acquire.Acquirer
+	refreshInterval time.Duration
+	maxAge          time.Duration
+	retryInterval   time.Duration
+	tokenAge        prometheus.Gauge
+	refreshErrors   prometheus.Counter
+	logger          *zap.Logger
+	current         func() time.Time
+
+	// refreshLock is held while acquiring a new token from next.
+	refreshLock sync.Mutex
+
+	lock        sync.Mutex
+	token       string
+	acquired    time.Time
+	expires     time.Time
+	lastAttempt time.Time
+	refreshing  bool
+}
+
+func newCachingAcquirer(config TokenCacheConfig, next acquire.Acquirer, tokenAge prometheus.Gauge, refreshErrors prometheus.Counter, logger *zap.Logger) *cachingAcquirer {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultTokenRefreshInterval
+	}
+
+	if config.MaxAge <= 0 {
+		config.MaxAge = defaultTokenMaxAge
+	}
+
+	if config.MaxAge < config.RefreshInterval {
+		config.MaxAge = config.RefreshInterval
+	}
+
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaultTokenRetryInterval
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &cachingAcquirer{
+		next:            next,
+		refreshInterval: config.RefreshInterval,
+		maxAge:          config.MaxAge,
+		retryInterval:   config.RetryInterval,
+		tokenAge:        tokenAge,
+		refreshErrors:   refreshErrors,
+		logger:          logger,
+		current:         time.Now,
+	}
+}
+
+// Acquire returns the cached token, starting a background refresh if it is older than the refresh interval or
+// expires within it.  If there isn't an unexpired token younger than the max age, it waits for a new one.
+func (c *cachingAcquirer) Acquire() (string, error) {
+	now := c.current()
+	c.lock.Lock()
+	token, acquired, expires := c.token, c.acquired, c.expires
+	age := now.Sub(acquired)
+	if len(token) > 0 && age < c.maxAge && (expires.IsZero() || now.Before(expires)) {
+		stale := age >= c.refreshInterval || (!expires.IsZero() && expires.Sub(now) < c.refreshInterval)
+		if stale && !c.refreshing && now.Sub(c.lastAttempt) >= c.retryInterval {
+			c.refreshing = true
+			go c.refreshInBackground(acquired)
+		}
+
+		c.lock.Unlock()
+		c.observeAge(age)
+		return token, nil
+	}
+
+	c.lock.Unlock()
+	token, acquired, err := c.refresh(acquired)
+	if err != nil {
+		return "", err
+	}
+
+	c.observeAge(c.current().Sub(acquired))
+	return token, nil
+}
+
+func (c *cachingAcquirer) refreshInBackground(seen time.Time) {
+	c.refresh(seen)
+	c.lock.Lock()
+	c.refreshing = false
+	c.lock.Unlock()
+}
+
+// refresh acquires a new token, unless the token acquired at seen was already replaced while waiting for another
+// refresh.  If acquiring fails, the previous token is kept.  If the same token is acquired again, it keeps the time
+// it was first acquired and background refreshes wait for the retry interval, like after a failure.
+func (c *cachingAcquirer) refresh(seen time.Time) (string, time.Time, error) {
+	c.refreshLock.Lock()
+	defer c.refreshLock.Unlock()
+
+	c.lock.Lock()
+	token, acquired := c.token, c.acquired
+	c.lock.Unlock()
+	if len(token) > 0 && !acquired.Equal(seen) {
+		return token, acquired, nil
+	}
+
+	newToken, err := c.next.Acquire()
+	now := c.current()
+	if err != nil {
+		c.logger.Warn("failed to refresh codex auth token", zap.Error(err), zap.Duration("token age", now.Sub(acquired)))
+		if c.refreshErrors != nil {
+			c.refreshErrors.Inc()
+		}
+
+		c.lock.Lock()
+		c.lastAttempt = now
+		c.lock.Unlock()
+		return token, acquired, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if newToken == c.token {
+		c.lastAttempt = now
+		return c.token, c.acquired, nil
+	}
+
+	c.token, c.acquired, c.expires = newToken, now, tokenExpiration(newToken)
+	return newToken, now, nil
+}
+
+// tokenExpiration returns the expiration of a JWT bearer token from its exp claim, without verifying the token.  It
+// returns the zero time if the token isn't a JWT or doesn't have an expiration.
+func tokenExpiration(token string) time.Time {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
+
+func (c *cachingAcquirer) observeAge(age time.Duration) {
+	if c.tokenAge != nil {
+		c.tokenAge.Set(age.Seconds())
+	}
+}
@@ -0,0 +1,244 @@
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/bascule/acquire"
+	"go.uber.org/zap"
+)
+
+func TestCachingAcquirer(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	next := new(mockAcquirer)
+	tokenAge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testTokenAge", Help: "testTokenAge"})
+	refreshErrors := prometheus.NewCounter(prometheus.CounterOpts{Name: "testRefreshErrors", Help: "testRefreshErrors"})
+	acquirer := newCachingAcquirer(TokenCacheConfig{RefreshInterval: time.Minute, MaxAge: 5 * time.Minute, RetryInterval: 10 * time.Second}, next, tokenAge, refreshErrors, zap.NewNop())
+	acquirer.current = func() time.Time { return now }
+
+	// the first request waits for a token.
+	next.On("Acquire").Return("Bearer first", nil).Once()
+	token, err := acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal("Bearer first", token)
+
+	// younger tokens are used without being refreshed.
+	now = now.Add(30 * time.Second)
+	token, err = acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal("Bearer first", token)
+	assert.Equal(30.0, testutil.ToFloat64(tokenAge))
+	next.AssertNumberOfCalls(t, "Acquire", 1)
+
+	// older tokens are used while refreshing in the background, keeping the previous token when refreshing fails.
+	refreshErr := errors.New("auth service unavailable")
+	next.On("Acquire").Return("", refreshErr).Once()
+	now = now.Add(time.Minute)
+	token, err = acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal("Bearer first", token)
+	assert.Eventually(func() bool { return testutil.ToFloat64(refreshErrors) == 1.0 }, time.Second, time.Millisecond)
+	assert.Eventually(func() bool {
+		acquirer.lock.Lock()
+		defer acquirer.lock.Unlock()
+		return !acquirer.refreshing
+	}, time.Second, time.Millisecond)
+
+	// refreshes aren't retried until the retry interval has passed.
+	token, err = acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal("Bearer first", token)
+	next.AssertNumberOfCalls(t, "Acquire", 2)
+
+	next.On("Acquire").Return("Bearer second", nil).Once()
+	now = now.Add(10 * time.Second)
+	acquirer.Acquire()
+	assert.Eventually(func() bool {
+		token, _ := acquirer.Acquire()
+		return token == "Bearer second"
+	}, time.Second, time.Millisecond)
+
+	// tokens older than the max age aren't used if refreshing fails.
+	next.On("Acquire").Return("", refreshErr).Once()
+	now = now.Add(5 * time.Minute)
+	token, err = acquirer.Acquire()
+	assert.ErrorIs(err, refreshErr)
+	assert.Empty(token)
+	assert.Equal(2.0, testutil.ToFloat64(refreshErrors))
+	next.AssertExpectations(t)
+}
+
+func TestCachingAcquirerSameToken(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	next := new(mockAcquirer)
+	tokenAge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testTokenAge", Help: "testTokenAge"})
+	acquirer := newCachingAcquirer(TokenCacheConfig{RefreshInterval: time.Minute, MaxAge: 5 * time.Minute, RetryInterval: 10 * time.Second}, next, tokenAge, nil, zap.NewNop())
+	acquirer.current = func() time.Time { return now }
+
+	next.On("Acquire").Return("Bearer first", nil).Twice()
+	token, err := acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal("Bearer first", token)
+
+	// refreshing to the same token keeps its age, and waits for the retry interval to refresh again.
+	now = now.Add(2 * time.Minute)
+	acquirer.Acquire()
+	assert.Eventually(func() bool {
+		acquirer.lock.Lock()
+		defer acquirer.lock.Unlock()
+		return !acquirer.refreshing
+	}, time.Second, time.Millisecond)
+
+	token, err = acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal("Bearer first", token)
+	assert.Equal(120.0, testutil.ToFloat64(tokenAge))
+	next.AssertNumberOfCalls(t, "Acquire", 2)
+
+	// the same token isn't used past the max age.
+	next.On("Acquire").Return("Bearer second", nil).Once()
+	now = now.Add(3 * time.Minute)
+	token, err = acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal("Bearer second", token)
+	assert.Equal(0.0, testutil.ToFloat64(tokenAge))
+	next.AssertExpectations(t)
+}
+
+func TestCachingAcquirerTokenExpiration(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	next := new(mockAcquirer)
+	acquirer := newCachingAcquirer(TokenCacheConfig{RefreshInterval: 5 * time.Minute, MaxAge: 15 * time.Minute, RetryInterval: 10 * time.Second}, next, nil, nil, zap.NewNop())
+	acquirer.current = func() time.Time { return now }
+
+	first := "Bearer " + testJWT(t, now.Add(2*time.Minute))
+	next.On("Acquire").Return(first, nil).Once()
+	token, err := acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal(first, token)
+
+	// tokens expiring within the refresh interval are refreshed in the background.
+	second := "Bearer " + testJWT(t, now.Add(time.Hour))
+	next.On("Acquire").Return(second, nil).Once()
+	acquirer.Acquire()
+	assert.Eventually(func() bool {
+		token, _ := acquirer.Acquire()
+		return token == second
+	}, time.Second, time.Millisecond)
+
+	// expired tokens aren't used, even when younger than the max age.
+	acquirer.lock.Lock()
+	acquirer.token, acquirer.acquired, acquirer.expires = first, now, tokenExpiration(first)
+	acquirer.lock.Unlock()
+	now = now.Add(3 * time.Minute)
+	next.On("Acquire").Return(second, nil).Once()
+	token, err = acquirer.Acquire()
+	assert.Nil(err)
+	assert.Equal(second, token)
+	next.AssertExpectations(t)
+}
+
+func TestTokenExpiration(t *testing.T) {
+	expires := time.Unix(1700000000, 0)
+	tests := []struct {
+		description string
+		token       string
+		expected    time.Time
+	}{
+		{
+			description: "Bearer JWT",
+			token:       "Bearer " + testJWT(t, expires),
+			expected:    expires,
+		},
+		{
+			description: "JWT",
+			token:       testJWT(t, expires),
+			expected:    expires,
+		},
+		{
+			description: "No exp",
+			token:       "a." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test"}`)) + ".c",
+		},
+		{
+			description: "Not a JWT",
+			token:       "Bearer first",
+		},
+		{
+			description: "Invalid payload",
+			token:       "a.!!!.c",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tokenExpiration(tc.token))
+		})
+	}
+}
+
+func testJWT(t *testing.T, expires time.Time) string {
+	payload, err := json.Marshal(map[string]int64{"exp": expires.Unix()})
+	assert.Nil(t, err)
+	return "header." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestNewCachingAcquirerDefaults(t *testing.T) {
+	tests := []struct {
+		description             string
+		config                  TokenCacheConfig
+		expectedRefreshInterval time.Duration
+		expectedMaxAge          time.Duration
+		expectedRetryInterval   time.Duration
+	}{
+		{
+			description:             "Defaults",
+			expectedRefreshInterval: defaultTokenRefreshInterval,
+			expectedMaxAge:          defaultTokenMaxAge,
+			expectedRetryInterval:   defaultTokenRetryInterval,
+		},
+		{
+			description:             "Max age shorter than refresh interval",
+			config:                  TokenCacheConfig{RefreshInterval: time.Hour, MaxAge: time.Minute, RetryInterval: time.Second},
+			expectedRefreshInterval: time.Hour,
+			expectedMaxAge:          time.Hour,
+			expectedRetryInterval:   time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			acquirer := newCachingAcquirer(tc.config, new(mockAcquirer), nil, nil, nil)
+			assert.Equal(tc.expectedRefreshInterval, acquirer.refreshInterval)
+			assert.Equal(tc.expectedMaxAge, acquirer.maxAge)
+			assert.Equal(tc.expectedRetryInterval, acquirer.retryInterval)
+			assert.NotNil(acquirer.logger)
+		})
+	}
+}
+
+func TestProvideCodexAuth(t *testing.T) {
+	assert := assert.New(t)
+	config := CodexConfig{Auth: AuthAcquirerConfig{Basic: "Basic dGVzdDp0ZXN0"}}
+	auth, err := provideCodexAuth(CodexAuthIn{Config: config, Logger: zap.NewNop()})
+	assert.Nil(err)
+	assert.IsType(&acquire.FixedValueAcquirer{}, auth)
+
+	config.Auth.Cache.Enabled = true
+	auth, err = provideCodexAuth(CodexAuthIn{Config: config, Logger: zap.NewNop()})
+	assert.Nil(err)
+	if assert.IsType(&cachingAcquirer{}, auth) {
+		token, err := auth.Acquire()
+		assert.Nil(err)
+		assert.Equal("Basic dGVzdDp0ZXN0", token)
+	}
+}
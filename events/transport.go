@@ -0,0 +1,202 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xmidt-org/httpaux"
+)
+
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 100
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+	defaultDialKeepAlive       = 30 * time.Second
+)
+
+var errInvalidCodexTLS = errors.New("invalid codex tls config")
+
+// TransportConfig configures the connections made to codex.
+type TransportConfig struct {
+	// MaxIdleConns is the maximum number of idle connections kept open across all hosts.  Defaults to 100.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept open to each host.  Defaults to 100,
+	// rather than the 2 of the default transport, so that connections aren't closed and reopened at high event
+	// rates.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost is the maximum number of connections to each host, including those in use.  If this is 0,
+	// connections aren't limited.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept open.  Defaults to 90s.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout is the maximum time to establish a connection.  Defaults to 30s.
+	DialTimeout time.Duration
+
+	// TLSHandshakeTimeout is the maximum time to complete a TLS handshake.  Defaults to 10s.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the maximum time to wait for codex's response headers after the request is
+	// written.  If this is 0, there is no timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// DisableHTTP2 keeps requests on HTTP/1.1 rather than using HTTP/2 when codex supports it.
+	DisableHTTP2 bool
+
+	// TLS configures the TLS connections to codex.
+	TLS TransportTLSConfig
+}
+
+// TransportTLSConfig configures the TLS connections to codex.
+type TransportTLSConfig struct {
+	// CAFile is the PEM file of the certificate authorities trusted when verifying codex.  If this is empty, the
+	// system's certificate authorities are used.
+	CAFile string
+
+	// CertificateFile and KeyFile are the PEM files of the client certificate presented to codex, if set.
+	CertificateFile string
+	KeyFile         string
+
+	// ServerName overrides the name used to verify codex's certificate.
+	ServerName string
+
+	// InsecureSkipVerify turns off verifying codex's certificate.  This should only be used for testing.
+	InsecureSkipVerify bool
+}
+
+// newTransport creates the transport used to send requests to codex, returning an error if the TLS files
+// can't be loaded.
+func newTransport(config TransportConfig) (*http.Transport, error) {
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = defaultMaxIdleConns
+	}
+
+	if config.MaxIdleConnsPerHost <= 0 {
+		config.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+
+	if config.IdleConnTimeout <= 0 {
+		config.IdleConnTimeout = defaultIdleConnTimeout
+	}
+
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+
+	if config.TLSHandshakeTimeout <= 0 {
+		config.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	}
+
+	tlsConfig, err := newTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: defaultDialKeepAlive,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       tlsConfig,
+		// a custom TLS config or dialer turns off HTTP/2 unless it is forced.
+		ForceAttemptHTTP2: !config.DisableHTTP2,
+	}
+
+	if config.DisableHTTP2 {
+		// a non-nil, empty map keeps the transport from upgrading to HTTP/2.
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return transport, nil
+}
+
+// newTLSConfig creates the TLS config used to connect to codex, returning nil if nothing is configured.
+func newTLSConfig(config TransportTLSConfig) (*tls.Config, error) {
+	if config == (TransportTLSConfig{}) {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify, // nolint:gosec
+	}
+
+	if len(config.CAFile) > 0 {
+		data, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read ca file: %v", errInvalidCodexTLS, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%w: no certificates found in ca file %q", errInvalidCodexTLS, config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(config.CertificateFile) > 0 || len(config.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(config.CertificateFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to load client certificate: %v", errInvalidCodexTLS, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// inFlightClient tracks the number of codex requests in flight.
+type inFlightClient struct {
+	next     httpaux.Client
+	inFlight prometheus.Gauge
+}
+
+func (c inFlightClient) Do(request *http.Request) (*http.Response, error) {
+	if c.inFlight == nil {
+		return c.next.Do(request)
+	}
+
+	c.inFlight.Inc()
+	defer c.inFlight.Dec()
+	return c.next.Do(request)
+}
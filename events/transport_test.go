@@ -0,0 +1,147 @@
+package events
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		description         string
+		config              TransportConfig
+		expectedIdleConns   int
+		expectedIdlePerHost int
+		expectedIdleTimeout time.Duration
+		expectedHTTP2       bool
+	}{
+		{
+			description:         "Defaults",
+			expectedIdleConns:   defaultMaxIdleConns,
+			expectedIdlePerHost: defaultMaxIdleConnsPerHost,
+			expectedIdleTimeout: defaultIdleConnTimeout,
+			expectedHTTP2:       true,
+		},
+		{
+			description: "Configured",
+			config: TransportConfig{
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 5,
+				IdleConnTimeout:     time.Minute,
+				DisableHTTP2:        true,
+			},
+			expectedIdleConns:   10,
+			expectedIdlePerHost: 5,
+			expectedIdleTimeout: time.Minute,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			transport, err := newTransport(tc.config)
+			if !assert.Nil(err) || !assert.NotNil(transport) {
+				return
+			}
+
+			assert.Equal(tc.expectedIdleConns, transport.MaxIdleConns)
+			assert.Equal(tc.expectedIdlePerHost, transport.MaxIdleConnsPerHost)
+			assert.Equal(tc.expectedIdleTimeout, transport.IdleConnTimeout)
+			assert.Equal(tc.expectedHTTP2, transport.ForceAttemptHTTP2)
+			assert.Equal(tc.expectedHTTP2, transport.TLSNextProto == nil)
+			assert.Nil(transport.TLSClientConfig)
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if !assert.Nil(t, os.WriteFile(caFile, caData, 0600)) {
+		return
+	}
+
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if !assert.Nil(t, os.WriteFile(emptyFile, []byte("not a certificate"), 0600)) {
+		return
+	}
+
+	tests := []struct {
+		description string
+		config      TransportTLSConfig
+		expectedErr error
+	}{
+		{
+			description: "Trusted ca",
+			config:      TransportTLSConfig{CAFile: caFile},
+		},
+		{
+			description: "Missing ca file",
+			config:      TransportTLSConfig{CAFile: filepath.Join(dir, "missing.pem")},
+			expectedErr: errInvalidCodexTLS,
+		},
+		{
+			description: "No certificates in ca file",
+			config:      TransportTLSConfig{CAFile: emptyFile},
+			expectedErr: errInvalidCodexTLS,
+		},
+		{
+			description: "Missing client certificate",
+			config:      TransportTLSConfig{CertificateFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")},
+			expectedErr: errInvalidCodexTLS,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			transport, err := newTransport(TransportConfig{TLS: tc.config})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(transport)
+				return
+			}
+
+			if !assert.Nil(err) {
+				return
+			}
+
+			// the server's certificate is only trusted through the configured ca.
+			response, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if !assert.Nil(err) {
+				return
+			}
+			response.Body.Close()
+			assert.Equal(http.StatusOK, response.StatusCode)
+		})
+	}
+}
+
+func TestInFlightClient(t *testing.T) {
+	assert := assert.New(t)
+	inFlight := prometheus.NewGauge(prometheus.GaugeOpts{Name: "testInFlight"})
+	client := new(mockClient)
+	client.On("Do", mock.Anything).Run(func(mock.Arguments) {
+		assert.Equal(1.0, testutil.ToFloat64(inFlight))
+	}).Return(httptest.NewRecorder().Result(), nil) // nolint:bodyclose
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := inFlightClient{next: client, inFlight: inFlight}.Do(request) // nolint:bodyclose
+	assert.Nil(err)
+	assert.Equal(0.0, testutil.ToFloat64(inFlight))
+}
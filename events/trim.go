@@ -0,0 +1,212 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xmidt-org/interpreter"
+)
+
+const (
+	// minTrimSessions is the fewest sessions that can be kept, since the finders search both the current
+	// and the previous session.
+	minTrimSessions = 2
+)
+
+// TrimConfig is how much of a device's history of events a parser needs, relative to the device's newest event.
+// The zero value needs the entire history.
+type TrimConfig struct {
+	// MaxAge is how far before the newest event's birthdate the events needed are born.  If this is 0, events are
+	// needed regardless of age.
+	MaxAge time.Duration
+
+	// MaxSessions is how many of the most recent sessions are needed.  Values below 2 are raised to 2.  If this is
+	// 0, every session is needed.
+	MaxSessions int
+}
+
+// TrimmingConfig configures trimming the histories of events before they are parsed, reducing the number of events
+// each parser's finders scan.
+type TrimmingConfig struct {
+	// Enabled turns on trimming each device's history to what the most demanding parser needs.
+	Enabled bool
+}
+
+// TrimmingEventSource is an EventSource that trims the histories of events to what the parsers using it need.
+type TrimmingEventSource interface {
+	EventSource
+
+	// Require keeps the part of the histories that a parser needs.  Parsers must call this before getting events.
+	Require(needs TrimConfig)
+}
+
+// historyNeeds is the combined needs of the parsers getting histories from a trimmingEventSource.
+type historyNeeds struct {
+	lock     sync.RWMutex
+	trim     TrimConfig
+	required bool
+}
+
+// require widens the trim to also keep what the needs given need.  A bound of 0 needed by any parser removes the
+// bound.
+func (h *historyNeeds) require(needs TrimConfig) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if !h.required {
+		h.trim, h.required = needs, true
+		return
+	}
+
+	if needs.MaxAge <= 0 || (h.trim.MaxAge > 0 && needs.MaxAge > h.trim.MaxAge) {
+		h.trim.MaxAge = needs.MaxAge
+	}
+
+	if needs.MaxSessions <= 0 || (h.trim.MaxSessions > 0 && needs.MaxSessions > h.trim.MaxSessions) {
+		h.trim.MaxSessions = needs.MaxSessions
+	}
+}
+
+func (h *historyNeeds) get() TrimConfig {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.trim
+}
+
+// trimmingEventSource trims the histories of events from the event source it wraps once, so that every parser
+// scans the trimmed history.  The histories are trimmed relative to each device's newest event, so parsing an
+// event older than the device's newest sessions, such as after a backlog in the queue, keeps fewer of its
+// sessions.
+type trimmingEventSource struct {
+	next  EventSource
+	needs *historyNeeds
+}
+
+func newTrimmingEventSource(next EventSource) trimmingEventSource {
+	return trimmingEventSource{next: next, needs: new(historyNeeds)}
+}
+
+// GetEvents implements the EventSource interface.
+func (t trimmingEventSource) GetEvents(ctx context.Context, device string) []interpreter.Event {
+	return trimEvents(t.next.GetEvents(ctx, device), t.needs.get())
+}
+
+// GetEventsBatch implements the EventSource interface.
+func (t trimmingEventSource) GetEventsBatch(ctx context.Context, deviceIDs []string) map[string][]interpreter.Event {
+	trim := t.needs.get()
+	results := t.next.GetEventsBatch(ctx, deviceIDs)
+	for deviceID, events := range results {
+		results[deviceID] = trimEvents(events, trim)
+	}
+
+	return results
+}
+
+// Require implements the TrimmingEventSource interface.
+func (t trimmingEventSource) Require(needs TrimConfig) {
+	t.needs.require(needs)
+}
+
+// ForParser implements the ParserEventSource interface, attributing the lookups to the parser if the wrapped
+// event source can.
+func (t trimmingEventSource) ForParser(parser string) EventSource {
+	if p, ok := t.next.(ParserEventSource); ok {
+		return trimmingEventSource{next: p.ForParser(parser), needs: t.needs}
+	}
+
+	return t
+}
+
+// trimEvents returns the events that fall within the bounds of the config, relative to the newest event.
+// Events missing the boot-time or birthdate used by a bound are kept.
+func trimEvents(events []interpreter.Event, config TrimConfig) []interpreter.Event {
+	if config.MaxAge <= 0 && config.MaxSessions <= 0 {
+		return events
+	}
+
+	var oldestBirthdate int64
+	if config.MaxAge > 0 {
+		if newest := newestBirthdate(events); newest > 0 {
+			oldestBirthdate = time.Unix(0, newest).Add(-1 * config.MaxAge).UnixNano()
+		}
+	}
+
+	var oldestBootTime int64
+	if config.MaxSessions > 0 {
+		oldestBootTime = oldestSessionBootTime(events, config.MaxSessions)
+	}
+
+	if oldestBirthdate <= 0 && oldestBootTime <= 0 {
+		return events
+	}
+
+	trimmed := make([]interpreter.Event, 0, len(events))
+	for _, event := range events {
+		if oldestBirthdate > 0 && event.Birthdate > 0 && event.Birthdate < oldestBirthdate {
+			continue
+		}
+
+		if bootTime, _ := event.BootTime(); oldestBootTime > 0 && bootTime > 0 && bootTime < oldestBootTime {
+			continue
+		}
+
+		trimmed = append(trimmed, event)
+	}
+
+	return trimmed
+}
+
+func newestBirthdate(events []interpreter.Event) int64 {
+	var newest int64
+	for _, event := range events {
+		if event.Birthdate > newest {
+			newest = event.Birthdate
+		}
+	}
+
+	return newest
+}
+
+// oldestSessionBootTime returns the boot-time of the oldest session to keep, or 0 if every session should be kept.
+func oldestSessionBootTime(events []interpreter.Event, maxSessions int) int64 {
+	if maxSessions < minTrimSessions {
+		maxSessions = minTrimSessions
+	}
+
+	bootTimes := make(map[int64]bool)
+	for _, event := range events {
+		if bootTime, _ := event.BootTime(); bootTime > 0 {
+			bootTimes[bootTime] = true
+		}
+	}
+
+	if len(bootTimes) <= maxSessions {
+		return 0
+	}
+
+	sorted := make([]int64, 0, len(bootTimes))
+	for bootTime := range bootTimes {
+		sorted = append(sorted, bootTime)
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] > sorted[b] })
+
+	return sorted[maxSessions-1]
+}
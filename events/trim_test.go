@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/interpreter/history"
+	"github.com/xmidt-org/interpreter/validation"
+)
+
+const (
+	testTrimDeviceID         = "mac:112233445566"
+	onlineEventType          = "online"
+	rebootPendingEventType   = "reboot-pending"
+	fullyManageableEventType = "fully-manageable"
+)
+
+func sessionEvent(eventType string, bootTime time.Time, birthdate time.Time, id string) interpreter.Event {
+	return interpreter.Event{
+		Destination:     fmt.Sprintf("event:device-status/%s/%s", testTrimDeviceID, eventType),
+		Birthdate:       birthdate.UnixNano(),
+		TransactionUUID: id,
+		Metadata: map[string]string{
+			interpreter.BootTimeKey: fmt.Sprint(bootTime.Unix()),
+		},
+	}
+}
+
+func TestTrimEvents(t *testing.T) {
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(t, err)
+	bootTimes := []time.Time{now.Add(-72 * time.Hour), now.Add(-48 * time.Hour), now.Add(-24 * time.Hour), now.Add(-1 * time.Hour)}
+	var events []interpreter.Event
+	for i, bootTime := range bootTimes {
+		events = append(events,
+			sessionEvent(onlineEventType, bootTime, bootTime.Add(time.Minute), fmt.Sprintf("online-%d", i)),
+			sessionEvent(rebootPendingEventType, bootTime, bootTime.Add(time.Hour), fmt.Sprintf("reboot-%d", i)),
+		)
+	}
+	events = append(events, interpreter.Event{TransactionUUID: "no-times"})
+	currentEvent := sessionEvent(fullyManageableEventType, bootTimes[3], now, "current")
+
+	tests := []struct {
+		description   string
+		config        TrimConfig
+		expectedCount int
+	}{
+		{
+			description:   "No trimming",
+			expectedCount: len(events),
+		},
+		{
+			description:   "Max sessions",
+			config:        TrimConfig{MaxSessions: 3},
+			expectedCount: 7,
+		},
+		{
+			description:   "Max sessions raised to minimum",
+			config:        TrimConfig{MaxSessions: 1},
+			expectedCount: 5,
+		},
+		{
+			description:   "Max sessions larger than history",
+			config:        TrimConfig{MaxSessions: 10},
+			expectedCount: len(events),
+		},
+		{
+			description:   "Max age",
+			config:        TrimConfig{MaxAge: 30 * time.Hour},
+			expectedCount: 5,
+		},
+		{
+			description:   "Max age and sessions",
+			config:        TrimConfig{MaxAge: 60 * time.Hour, MaxSessions: 2},
+			expectedCount: 5,
+		},
+	}
+
+	finder := history.LastSessionFinder(validation.DestinationValidator(rebootPendingEventType))
+	expectedEvent, err := finder.Find(events, currentEvent)
+	assert.Nil(t, err)
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			trimmed := trimEvents(events, tc.config)
+			assert.Len(trimmed, tc.expectedCount)
+
+			// the previous session's events needed by the finders are kept
+			foundEvent, err := finder.Find(trimmed, currentEvent)
+			assert.Nil(err)
+			assert.Equal(expectedEvent, foundEvent)
+		})
+	}
+}
+
+func TestTrimEventsMissingBootTime(t *testing.T) {
+	assert := assert.New(t)
+	events := []interpreter.Event{{TransactionUUID: "1"}, {TransactionUUID: "2"}}
+	trimmed := trimEvents(events, TrimConfig{MaxSessions: 2, MaxAge: time.Hour})
+	assert.Equal(events, trimmed)
+}
+
+func TestHistoryNeeds(t *testing.T) {
+	tests := []struct {
+		description  string
+		needs        []TrimConfig
+		expectedTrim TrimConfig
+	}{
+		{
+			description: "No parsers",
+		},
+		{
+			description:  "One parser",
+			needs:        []TrimConfig{{MaxSessions: 2}},
+			expectedTrim: TrimConfig{MaxSessions: 2},
+		},
+		{
+			description:  "Most demanding parser",
+			needs:        []TrimConfig{{MaxSessions: 2, MaxAge: time.Hour}, {MaxSessions: 5, MaxAge: 30 * time.Minute}, {MaxSessions: 3, MaxAge: 2 * time.Hour}},
+			expectedTrim: TrimConfig{MaxSessions: 5, MaxAge: 2 * time.Hour},
+		},
+		{
+			description:  "Unbounded needs",
+			needs:        []TrimConfig{{MaxSessions: 2, MaxAge: time.Hour}, {MaxSessions: 5}, {MaxAge: 2 * time.Hour}},
+			expectedTrim: TrimConfig{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			needs := new(historyNeeds)
+			for _, n := range tc.needs {
+				needs.require(n)
+			}
+
+			assert.Equal(t, tc.expectedTrim, needs.get())
+		})
+	}
+}
+
+func TestTrimmingEventSource(t *testing.T) {
+	assert := assert.New(t)
+	now, err := time.Parse(time.RFC3339Nano, "2021-03-02T18:00:00Z")
+	assert.Nil(err)
+	var history []interpreter.Event
+	for i := 0; i < 4; i++ {
+		bootTime := now.Add(time.Duration(i-4) * time.Hour)
+		history = append(history, sessionEvent(onlineEventType, bootTime, bootTime.Add(time.Minute), fmt.Sprint(i)))
+	}
+
+	file := &fileEventSource{events: map[string][]interpreter.Event{testTrimDeviceID: history}}
+	source := newTrimmingEventSource(file)
+	ctx := context.Background()
+
+	// without any parser needs, the histories aren't trimmed.
+	assert.Equal(history, source.GetEvents(ctx, testTrimDeviceID))
+
+	source.Require(TrimConfig{MaxSessions: 2})
+	source.Require(TrimConfig{MaxSessions: 3})
+	assert.Equal(history[1:], source.GetEvents(ctx, testTrimDeviceID))
+	assert.Equal(map[string][]interpreter.Event{testTrimDeviceID: history[1:]}, source.GetEventsBatch(ctx, []string{testTrimDeviceID}))
+
+	// the needs are shared with the sources attributing lookups to parsers.
+	codexSource := trimmingEventSource{next: &CodexClient{}, needs: source.needs}
+	parserSource, ok := codexSource.ForParser("reboot").(trimmingEventSource)
+	if assert.True(ok) {
+		assert.Equal(&ParserClient{client: &CodexClient{}, parser: "reboot"}, parserSource.next)
+		assert.Same(source.needs, parserSource.needs)
+	}
+}
@@ -0,0 +1,150 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+const (
+	rebootPendingEventType   = "reboot-pending"
+	offlineEventType         = "offline"
+	onlineEventType          = "online"
+	fullyManageableEventType = "fully-manageable"
+
+	hwModelKey          = "/hw-model"
+	fwNameKey           = "/fw-name"
+	lastRebootReasonKey = "/hw-last-reboot-reason"
+)
+
+// sequence is the order of the events sent by each device in every reboot cycle.
+var sequence = []string{rebootPendingEventType, offlineEventType, onlineEventType, fullyManageableEventType}
+
+var (
+	hwModels      = []string{"hw-model-a", "hw-model-b", "hw-model-c"}
+	fwNames       = []string{"fw-1.0", "fw-1.1", "fw-2.0"}
+	rebootReasons = []string{"Software_upgrade", "Power-On", "Operator_reboot"}
+)
+
+type device struct {
+	id        string
+	hwModel   string
+	fwName    string
+	step      int
+	bootTime  time.Time
+	sessionID string
+}
+
+// generator generates device-status events for a fleet of simulated devices.  Devices take turns sending their
+// next event, so each device goes through complete reboot cycles: reboot-pending, offline, online with a new
+// boot-time, then fully-manageable.
+type generator struct {
+	devices   []*device
+	next      int
+	partnerID string
+	random    *rand.Rand
+	current   func() time.Time
+}
+
+func newGenerator(devices int, partnerID string, seed int64, current func() time.Time) *generator {
+	random := rand.New(rand.NewSource(seed)) // nolint:gosec
+	now := current()
+	g := &generator{
+		devices:   make([]*device, devices),
+		partnerID: partnerID,
+		random:    random,
+		current:   current,
+	}
+
+	for i := range g.devices {
+		g.devices[i] = &device{
+			id:      fmt.Sprintf("mac:%012x", random.Int63n(1<<48)),
+			hwModel: hwModels[random.Intn(len(hwModels))],
+			fwName:  fwNames[random.Intn(len(fwNames))],
+			// devices start partway through a boot cycle of up to a day.
+			step:      random.Intn(len(sequence)),
+			bootTime:  now.Add(-1 * time.Duration(random.Int63n(int64(24*time.Hour)))).Truncate(time.Second),
+			sessionID: g.newID(),
+		}
+	}
+
+	return g
+}
+
+// Next returns the next event of the next device.
+func (g *generator) Next() wrp.Message {
+	d := g.devices[g.next]
+	g.next = (g.next + 1) % len(g.devices)
+
+	eventType := sequence[d.step]
+	d.step = (d.step + 1) % len(sequence)
+
+	now := g.current()
+	metadata := map[string]string{
+		hwModelKey: d.hwModel,
+		fwNameKey:  d.fwName,
+	}
+
+	if eventType == onlineEventType {
+		// boot-times are in seconds, and every boot cycle needs a later boot-time than the last.
+		bootTime := now.Truncate(time.Second)
+		if !bootTime.After(d.bootTime) {
+			bootTime = d.bootTime.Add(time.Second)
+		}
+		d.bootTime = bootTime
+		d.sessionID = g.newID()
+	}
+
+	if eventType == onlineEventType || eventType == fullyManageableEventType {
+		metadata[lastRebootReasonKey] = rebootReasons[g.random.Intn(len(rebootReasons))]
+	}
+
+	// an event can't be sent before the device booted.
+	if now.Before(d.bootTime) {
+		now = d.bootTime
+	}
+	metadata[interpreter.BootTimeKey] = strconv.FormatInt(d.bootTime.Unix(), 10)
+
+	payload, _ := json.Marshal(map[string]string{
+		"id": d.id,
+		"ts": now.UTC().Format(time.RFC3339Nano),
+	})
+
+	return wrp.Message{
+		Type:            wrp.SimpleEventMessageType,
+		Source:          "dns:talaria.loadgen",
+		Destination:     fmt.Sprintf("event:device-status/%s/%s", d.id, eventType),
+		TransactionUUID: g.newID(),
+		ContentType:     "application/json",
+		Metadata:        metadata,
+		Payload:         payload,
+		PartnerIDs:      []string{g.partnerID},
+		SessionID:       d.sessionID,
+	}
+}
+
+func (g *generator) newID() string {
+	return fmt.Sprintf("%016x%016x", g.random.Uint64(), g.random.Uint64())
+}
@@ -0,0 +1,80 @@
+package loadgen
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/interpreter"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestGenerator(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	current := func() time.Time {
+		now = now.Add(100 * time.Millisecond)
+		return now
+	}
+
+	g := newGenerator(3, "partner", 1, current)
+	lastEvents := make(map[string]interpreter.Event)
+	for i := 0; i < 3*len(sequence)*3; i++ {
+		msg := g.Next()
+		event, err := interpreter.NewEvent(msg)
+		if !assert.Nil(err) {
+			return
+		}
+
+		deviceID, err := event.DeviceID()
+		assert.Nil(err)
+		eventType, err := event.EventType()
+		assert.Nil(err)
+		bootTime, err := event.BootTime()
+		assert.Nil(err)
+		assert.Equal(wrp.SimpleEventMessageType, msg.Type)
+		assert.Equal([]string{"partner"}, event.PartnerIDs)
+		assert.GreaterOrEqual(event.Birthdate, time.Unix(bootTime, 0).UnixNano())
+
+		if last, found := lastEvents[deviceID]; found {
+			lastType, _ := last.EventType()
+			lastBootTime, _ := last.BootTime()
+			assert.Equal(sequence[(indexOf(lastType)+1)%len(sequence)], eventType)
+			assert.Greater(event.Birthdate, last.Birthdate)
+			if eventType == onlineEventType {
+				assert.Greater(bootTime, lastBootTime)
+				assert.NotEqual(last.SessionID, event.SessionID)
+			} else {
+				assert.Equal(lastBootTime, bootTime)
+			}
+		}
+		lastEvents[deviceID] = event
+	}
+	assert.Len(lastEvents, 3)
+}
+
+func TestGeneratorSeed(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	current := func() time.Time { return now }
+	first := newGenerator(5, "partner", 7, current)
+	second := newGenerator(5, "partner", 7, current)
+	other := newGenerator(5, "partner", 8, current)
+	for i := 0; i < 5; i++ {
+		msg := first.Next()
+		assert.Equal(msg, second.Next())
+		assert.NotEqual(msg.Destination, other.Next().Destination)
+		assert.True(strings.HasPrefix(msg.Destination, "event:device-status/mac:"))
+	}
+}
+
+func indexOf(eventType string) int {
+	for i, t := range sequence {
+		if t == eventType {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,264 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xmidt-org/httpaux"
+	"github.com/xmidt-org/wrp-go/v3"
+	"go.uber.org/ratelimit"
+)
+
+// Command is the glaukos subcommand that runs the load generator.
+const Command = "loadgen"
+
+const (
+	defaultDevices     = 100
+	defaultRate        = 100
+	defaultConcurrency = 10
+	defaultPartnerID   = "loadgen"
+	defaultTimeout     = 10 * time.Second
+)
+
+var (
+	errMissingURL    = errors.New("url is required")
+	errInvalidFormat = errors.New("invalid format")
+)
+
+// Config configures the load generator.
+type Config struct {
+	// URL is the glaukos events endpoint the events are sent to.
+	URL string
+
+	// Authorization is the value of the Authorization header sent with each event, if set.
+	Authorization string
+
+	// Devices is the number of simulated devices.  Defaults to 100.
+	Devices int
+
+	// Rate is the number of events sent per second.  Defaults to 100.
+	Rate int
+
+	// Duration is how long events are sent for.  If this is 0, events are sent until Count is reached or the
+	// generator is interrupted.
+	Duration time.Duration
+
+	// Count is the number of events sent.  If this is 0, events are sent until Duration passes or the generator
+	// is interrupted.
+	Count int
+
+	// Concurrency is the maximum number of requests in flight.  Defaults to 10.
+	Concurrency int
+
+	// Format is the format events are encoded in: msgpack or json.  Defaults to msgpack.
+	Format string
+
+	// PartnerID is the partner id of the simulated devices.  Defaults to loadgen.
+	PartnerID string
+
+	// Seed seeds the generated devices and events, so that runs with the same seed send the same devices.
+	Seed int64
+
+	// Timeout is the maximum time given to each request.  Defaults to 10s.
+	Timeout time.Duration
+}
+
+// Result summarizes a run of the load generator.
+type Result struct {
+	Sent        int64
+	Failed      int64
+	StatusCodes map[int]int64
+	Elapsed     time.Duration
+}
+
+// Run sends generated events to glaukos at the configured rate until the duration passes, the count is reached,
+// or the context is cancelled.
+func Run(ctx context.Context, config Config, client httpaux.Client) (Result, error) {
+	if len(config.URL) == 0 {
+		return Result{}, errMissingURL
+	}
+
+	format := wrp.Msgpack
+	switch config.Format {
+	case "", "msgpack":
+	case "json":
+		format = wrp.JSON
+	default:
+		return Result{}, fmt.Errorf("%w: %q", errInvalidFormat, config.Format)
+	}
+
+	if config.Devices <= 0 {
+		config.Devices = defaultDevices
+	}
+
+	if config.Rate <= 0 {
+		config.Rate = defaultRate
+	}
+
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+
+	if len(config.PartnerID) == 0 {
+		config.PartnerID = defaultPartnerID
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+		defer cancel()
+	}
+
+	r := runner{
+		config:      config,
+		format:      format,
+		client:      client,
+		statusCodes: make(map[int]int64),
+	}
+
+	begin := time.Now()
+	messages := make(chan wrp.Message)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range messages {
+				r.send(ctx, msg)
+			}
+		}()
+	}
+
+	g := newGenerator(config.Devices, config.PartnerID, config.Seed, time.Now)
+	limiter := ratelimit.New(config.Rate)
+	for i := 0; config.Count <= 0 || i < config.Count; i++ {
+		limiter.Take()
+		msg := g.Next()
+		select {
+		case messages <- msg:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	close(messages)
+	wg.Wait()
+	return r.result(time.Since(begin)), nil
+}
+
+type runner struct {
+	config Config
+	format wrp.Format
+	client httpaux.Client
+
+	sent        atomic.Int64
+	failed      atomic.Int64
+	lock        sync.Mutex
+	statusCodes map[int]int64
+}
+
+// send encodes and sends the message, counting the outcome.
+func (r *runner) send(ctx context.Context, msg wrp.Message) {
+	var body []byte
+	if err := wrp.NewEncoderBytes(&body, r.format).Encode(&msg); err != nil {
+		r.failed.Add(1)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.URL, bytes.NewReader(body))
+	if err != nil {
+		r.failed.Add(1)
+		return
+	}
+
+	request.Header.Set("Content-Type", r.format.ContentType())
+	if len(r.config.Authorization) > 0 {
+		request.Header.Set("Authorization", r.config.Authorization)
+	}
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		r.failed.Add(1)
+		return
+	}
+
+	io.Copy(io.Discard, response.Body) // nolint:errcheck
+	response.Body.Close()
+
+	r.sent.Add(1)
+	r.lock.Lock()
+	r.statusCodes[response.StatusCode]++
+	r.lock.Unlock()
+}
+
+func (r *runner) result(elapsed time.Duration) Result {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	statusCodes := make(map[int]int64, len(r.statusCodes))
+	for code, count := range r.statusCodes {
+		statusCodes[code] = count
+	}
+
+	return Result{
+		Sent:        r.sent.Load(),
+		Failed:      r.failed.Load(),
+		StatusCodes: statusCodes,
+		Elapsed:     elapsed,
+	}
+}
+
+// WriteTo writes a summary of the result.
+func (r Result) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "sent: %d\n", r.Sent)
+	fmt.Fprintf(&buf, "failed: %d\n", r.Failed)
+	fmt.Fprintf(&buf, "elapsed: %s\n", r.Elapsed.Round(time.Millisecond))
+	if r.Elapsed > 0 {
+		fmt.Fprintf(&buf, "rate: %.1f events/s\n", float64(r.Sent+r.Failed)/r.Elapsed.Seconds())
+	}
+
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&buf, "status %d: %d\n", code, r.StatusCodes[code])
+	}
+
+	return buf.WriteTo(w)
+}
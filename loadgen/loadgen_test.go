@@ -0,0 +1,123 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		description string
+		config      Config
+		expectedErr error
+		format      wrp.Format
+	}{
+		{
+			description: "Msgpack",
+			config:      Config{Count: 20, Rate: 1000, Devices: 5, Authorization: "Basic dGVzdDp0ZXN0"},
+			format:      wrp.Msgpack,
+		},
+		{
+			description: "JSON",
+			config:      Config{Count: 20, Rate: 1000, Format: "json"},
+			format:      wrp.JSON,
+		},
+		{
+			description: "Invalid format",
+			config:      Config{Count: 20, Format: "xml"},
+			expectedErr: errInvalidFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var received, rejected atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				var msg wrp.Message
+				if r.Header.Get("Authorization") != tc.config.Authorization ||
+					r.Header.Get("Content-Type") != tc.format.ContentType() ||
+					wrp.NewDecoderBytes(body, tc.format).Decode(&msg) != nil {
+					rejected.Add(1)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				// every other event is rejected to check the status codes are counted.
+				if received.Add(1)%2 == 0 {
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer server.Close()
+
+			tc.config.URL = server.URL
+			result, err := Run(context.Background(), tc.config, server.Client())
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				return
+			}
+
+			assert.Nil(err)
+			assert.Equal(int64(20), received.Load())
+			assert.Zero(rejected.Load())
+			assert.Equal(int64(20), result.Sent)
+			assert.Zero(result.Failed)
+			assert.Equal(map[int]int64{http.StatusAccepted: 10, http.StatusTooManyRequests: 10}, result.StatusCodes)
+		})
+	}
+}
+
+func TestRunMissingURL(t *testing.T) {
+	_, err := Run(context.Background(), Config{}, http.DefaultClient)
+	assert.ErrorIs(t, err, errMissingURL)
+}
+
+func TestRunDuration(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	begin := time.Now()
+	result, err := Run(context.Background(), Config{URL: server.URL, Duration: 200 * time.Millisecond, Rate: 50}, server.Client())
+	assert.Nil(err)
+	assert.Less(time.Since(begin), time.Second)
+	assert.InDelta(10, result.Sent+result.Failed, 3)
+}
+
+func TestRunUnreachable(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	result, err := Run(context.Background(), Config{URL: server.URL, Count: 3, Rate: 1000}, http.DefaultClient)
+	assert.Nil(err)
+	assert.Zero(result.Sent)
+	assert.Equal(int64(3), result.Failed)
+}
+
+func TestResultWriteTo(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	_, err := Result{
+		Sent:        10,
+		Failed:      2,
+		StatusCodes: map[int]int64{http.StatusTooManyRequests: 4, http.StatusAccepted: 6},
+		Elapsed:     2 * time.Second,
+	}.WriteTo(&buf)
+	assert.Nil(err)
+	assert.Equal("sent: 10\nfailed: 2\nelapsed: 2s\nrate: 6.0 events/s\nstatus 202: 6\nstatus 429: 4\n", buf.String())
+}
@@ -0,0 +1,60 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package loadgen
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/pflag"
+)
+
+// Main parses the command line arguments of the loadgen subcommand and runs the load generator until it is done
+// or interrupted, writing a summary to out.
+func Main(args []string, out io.Writer) error {
+	var config Config
+	fs := pflag.NewFlagSet(Command, pflag.ContinueOnError)
+	fs.StringVarP(&config.URL, "url", "u", "http://localhost:4200/api/v1/events", "the glaukos events endpoint to send events to")
+	fs.StringVarP(&config.Authorization, "authorization", "a", "", "the Authorization header sent with each event")
+	fs.IntVarP(&config.Devices, "devices", "n", defaultDevices, "the number of simulated devices")
+	fs.IntVarP(&config.Rate, "rate", "r", defaultRate, "the number of events sent per second")
+	fs.DurationVarP(&config.Duration, "duration", "d", 0, "how long to send events for, or 0 to send until interrupted")
+	fs.IntVarP(&config.Count, "count", "c", 0, "the number of events to send, or 0 to send until interrupted")
+	fs.IntVar(&config.Concurrency, "concurrency", defaultConcurrency, "the maximum number of requests in flight")
+	fs.StringVar(&config.Format, "format", "msgpack", "the format events are encoded in: msgpack or json")
+	fs.StringVar(&config.PartnerID, "partner-id", defaultPartnerID, "the partner id of the simulated devices")
+	fs.Int64Var(&config.Seed, "seed", 0, "seeds the generated devices, so that runs with the same seed send the same devices")
+	fs.DurationVar(&config.Timeout, "timeout", defaultTimeout, "the maximum time given to each request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := Run(ctx, config, http.DefaultClient)
+	if err != nil {
+		return err
+	}
+
+	_, err = result.WriteTo(out)
+	return err
+}
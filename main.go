@@ -18,7 +18,6 @@
 package main
 
 import (
-	"crypto/sha1" // nolint:gosec
 	"errors"
 	"fmt"
 	"net/http"
@@ -34,14 +33,21 @@ import (
 	"github.com/go-kit/log"
 	"github.com/xmidt-org/arrange"
 	"github.com/xmidt-org/arrange/arrangehttp"
+	"github.com/xmidt-org/bascule"
 	"github.com/xmidt-org/bascule/basculehttp"
+	"github.com/xmidt-org/candlelight"
 	"github.com/xmidt-org/glaukos/eventmetrics"
+	"github.com/xmidt-org/glaukos/eventmetrics/amqp"
+	"github.com/xmidt-org/glaukos/eventmetrics/kafka"
+	"github.com/xmidt-org/glaukos/eventmetrics/parsers"
+	"github.com/xmidt-org/glaukos/eventmetrics/sqs"
+	"github.com/xmidt-org/glaukos/eventmetrics/storage"
+	"github.com/xmidt-org/glaukos/loadgen"
 	"github.com/xmidt-org/httpaux"
 	"github.com/xmidt-org/sallust"
 	"github.com/xmidt-org/sallust/sallustkit"
 	"github.com/xmidt-org/touchstone"
 	"github.com/xmidt-org/touchstone/touchhttp"
-	"github.com/xmidt-org/wrp-listener/hashTokenFactory"
 	secretGetter "github.com/xmidt-org/wrp-listener/secret"
 	"github.com/xmidt-org/wrp-listener/webhookClient"
 
@@ -65,10 +71,35 @@ var (
 type SecretConfig struct {
 	Header    string
 	Delimiter string
+
+	// Algorithms are the HMAC algorithms accepted for the signatures of incoming requests: sha1, sha256, or
+	// sha512.  Defaults to sha1.
+	Algorithms []string
+}
+
+// AuthChainIn provides everything needed to build the chain authenticating incoming events.
+type AuthChainIn struct {
+	fx.In
+	Factories     map[bascule.Authorization]basculehttp.TokenFactory
+	SecretConfig  SecretConfig
+	WebhookConfig WebhookConfig
+	JWTConfig     JWTConfig
+	DefaultKeyID  string `name:"default_key_id"`
+	Logger        *zap.Logger
+	Options       basculehttp.COptionsIn
+	Lifecycle     fx.Lifecycle
 }
 
 // nolint:funlen // this is main provide function to hooks up all of the uberfx wiring
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == loadgen.Command {
+		if err := loadgen.Main(os.Args[2:], os.Stdout); err != nil && !errors.Is(err, pflag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// setup command line options and configuration from file
 	f := pflag.NewFlagSet(applicationName, pflag.ContinueOnError)
 	setupFlagSet(f)
@@ -90,6 +121,10 @@ func main() {
 	app := fx.New(
 		arrange.ForViper(v, decodeOption),
 		eventmetrics.Provide(),
+		kafka.Provide(),
+		amqp.Provide(),
+		sqs.Provide(),
+		storage.Provide(),
 		basculehttp.ProvideLogger(),
 		touchhttp.Provide(),
 		touchstone.Provide(),
@@ -109,6 +144,10 @@ func main() {
 		fx.Provide(
 			ProvideConsts,
 			arrange.UnmarshalKey("prometheus", touchstone.Config{}),
+			// exemplars are only exposed using the OpenMetrics format.
+			func(config parsers.ExemplarsConfig) touchhttp.Config {
+				return touchhttp.Config{EnableOpenMetrics: config.Enabled}
+			},
 			arrange.UnmarshalKey("log", sallust.Config{}),
 			func(config sallust.Config) (*zap.Logger, error) {
 				return config.Build()
@@ -118,23 +157,54 @@ func main() {
 					Zap: logger,
 				}
 			},
+			arrange.UnmarshalKey("tracing", candlelight.Config{}),
+			newTracing,
+			arrange.UnmarshalKey("servers.grpc", eventmetrics.GRPCConfig{}),
+			arrange.UnmarshalKey("servers.pprof", eventmetrics.PprofConfig{}),
+			eventmetrics.NewTokenValidator,
 			arrange.UnmarshalKey("webhook", WebhookConfig{}),
 			arrange.UnmarshalKey("secret", SecretConfig{}),
 			func(config WebhookConfig) webhookClient.SecretGetter {
 				return secretGetter.NewConstantSecret(config.Request.Config.Secret)
 			},
-			func(sg webhookClient.SecretGetter) (basculehttp.TokenFactory, error) {
-				return hashTokenFactory.New("sha1", sha1.New, sg)
+			func(sc SecretConfig, sg webhookClient.SecretGetter) (map[bascule.Authorization]basculehttp.TokenFactory, error) {
+				return newHashTokenFactories(sc.Algorithms, sg)
 			},
-			func(htf basculehttp.TokenFactory, sc SecretConfig, wc WebhookConfig, logger *zap.Logger, options basculehttp.COptionsIn) (alice.Chain, error) {
-				if sc.Header != "" && wc.Request.Config.Secret != "" {
-					options.Options = append(options.Options,
-						basculehttp.WithTokenFactory("sha1", htf),
-						basculehttp.WithHeaderName(sc.Header),
-						basculehttp.WithHeaderDelimiter(sc.Delimiter),
+			fx.Annotated{
+				Name: "event_request_signer",
+				Target: func(sc SecretConfig, config WebhookConfig) (eventmetrics.RequestSigner, error) {
+					return newEventRequestSigner(sc, config.Request.Config.Secret)
+				},
+			},
+			arrange.UnmarshalKey("jwt", JWTConfig{}),
+			func(in AuthChainIn) (alice.Chain, error) {
+				hmacEnabled := in.SecretConfig.Header != "" && in.WebhookConfig.Request.Config.Secret != ""
+				hmacOptions := in.Options.Options
+				if hmacEnabled {
+					for algorithm, htf := range in.Factories {
+						hmacOptions = append(hmacOptions, basculehttp.WithTokenFactory(algorithm, htf))
+					}
+					hmacOptions = append(hmacOptions,
+						basculehttp.WithHeaderName(in.SecretConfig.Header),
+						basculehttp.WithHeaderDelimiter(in.SecretConfig.Delimiter),
 					)
 				}
-				return alice.New(basculehttp.SetLogger(logger), basculehttp.NewConstructor(options.Options...)), nil
+
+				constructor := basculehttp.NewConstructor(hmacOptions...)
+				if in.JWTConfig.Enabled {
+					bearer, err := newBearerConstructor(in.JWTConfig, in.DefaultKeyID, in.Lifecycle, in.Options.Options...)
+					if err != nil {
+						return alice.Chain{}, err
+					}
+
+					if hmacEnabled {
+						constructor = selectConstructor(in.SecretConfig.Header, constructor, bearer)
+					} else {
+						constructor = bearer
+					}
+				}
+
+				return alice.New(basculehttp.SetLogger(in.Logger), constructor), nil
 			},
 			func(config WebhookConfig) webhookClient.BasicConfig {
 				return webhookClient.BasicConfig{
@@ -154,6 +224,11 @@ func main() {
 		fx.Invoke(
 			BuildMetricsRoutes,
 			eventmetrics.ConfigureRoutes,
+			eventmetrics.ConfigureReadyRoutes,
+			eventmetrics.ConfigureKillSwitchRoutes,
+			eventmetrics.ConfigureAdminRoutes,
+			eventmetrics.StartGRPCServer,
+			eventmetrics.StartPprofServer,
 			func(pr *webhookClient.PeriodicRegisterer) {
 				pr.Start()
 			},
@@ -0,0 +1,46 @@
+/**
+ * Copyright 2021 Comcast Cable Communications Management, LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"context"
+
+	"github.com/xmidt-org/candlelight"
+	"go.uber.org/fx"
+)
+
+// newTracing creates the tracing used to trace events from the request they were received in through
+// parsing and codex lookups.  Spans that haven't been exported yet are flushed on shutdown.
+func newTracing(config candlelight.Config, lc fx.Lifecycle) (candlelight.Tracing, error) {
+	if len(config.ApplicationName) == 0 {
+		config.ApplicationName = applicationName
+	}
+
+	tracing, err := candlelight.New(config)
+	if err != nil {
+		return candlelight.Tracing{}, err
+	}
+
+	if tp, ok := tracing.TracerProvider().(interface{ Shutdown(context.Context) error }); ok {
+		lc.Append(fx.Hook{
+			OnStop: tp.Shutdown,
+		})
+	}
+
+	return tracing, nil
+}